@@ -4,7 +4,36 @@ import "github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo"
 
 type API = ecgo.API
 type CompileResult = ecgo.CompileResult
+type UnsatisfiedConstraintError = ecgo.UnsatisfiedConstraintError
+type ConstraintSystem = ecgo.ConstraintSystem
+type Progress = ecgo.Progress
+type ResourceEstimate = ecgo.ResourceEstimate
 
 var Compile = ecgo.Compile
+var CompileContext = ecgo.CompileContext
+var CompileBatch = ecgo.CompileBatch
+var NewBuilder = ecgo.NewBuilder
+var NewBuilderWithOptions = ecgo.NewBuilderWithOptions
+var CheckWitness = ecgo.CheckWitness
+var EstimateResources = ecgo.EstimateResources
 var DeserializeLayeredCircuit = ecgo.DeserializeLayeredCircuit
 var DeserializeInputSolver = ecgo.DeserializeInputSolver
+var DeserializeWitness = ecgo.DeserializeWitness
+var DeserializePublicWitness = ecgo.DeserializePublicWitness
+var ReadTags = ecgo.ReadTags
+var WithSubCircuitExtraction = ecgo.WithSubCircuitExtraction
+var WithCommonSubexpressionElimination = ecgo.WithCommonSubexpressionElimination
+var WithConstantFolding = ecgo.WithConstantFolding
+var WithDeadCodeElimination = ecgo.WithDeadCodeElimination
+var WithReassociation = ecgo.WithReassociation
+var WithPadding = ecgo.WithPadding
+var WithWorkers = ecgo.WithWorkers
+var WithLowMemory = ecgo.WithLowMemory
+var WithCompileCache = ecgo.WithCompileCache
+var WithPublicInputSlot = ecgo.WithPublicInputSlot
+var WithPublicInputGroup = ecgo.WithPublicInputGroup
+var WithSourceLocations = ecgo.WithSourceLocations
+var WithDebugPrints = ecgo.WithDebugPrints
+var WithProfile = ecgo.WithProfile
+var WithProgress = ecgo.WithProgress
+var WithSnapshots = ecgo.WithSnapshots
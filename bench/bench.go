@@ -0,0 +1,207 @@
+// Package bench holds reference circuits, whose benchmarks measure the compile time, the size of
+// the layered circuits and the witness solving time, so that performance regressions across
+// releases are visible:
+//
+//	go test -run '^$' -bench . -benchtime 3x ./bench
+//
+// The circuits exercise the main shapes of workloads: a chain of Keccak hashes is deep and
+// bit-oriented, a batch of Poseidon2 Merkle openings is made of many calls to a single subcircuit,
+// a matrix product is wide and regular, and the multiplications of secp256k1 field elements are
+// dominated by the range checks of the emulated arithmetic.
+package bench
+
+import (
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/emulated"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/circuit-std-go/keccak"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/circuit-std-go/linalg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/circuit-std-go/merkle"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+)
+
+// Circuit is a reference circuit.
+type Circuit struct {
+	Name  string
+	Field *big.Int
+	// New returns the empty circuit, to be compiled.
+	New func() frontend.Circuit
+	// Assignment returns a satisfying assignment of the circuit, to be solved.
+	Assignment func() frontend.Circuit
+}
+
+// The sizes of the reference circuits. They're part of the benchmarks, so changing them makes
+// the results incomparable with the ones of previous releases.
+const (
+	KeccakChainLength = 8
+	MerkleDepth       = 8
+	MerkleOpenings    = 16
+	MatMulSize        = 64
+	EmulatedMuls      = 16
+)
+
+// Circuits are the reference circuits, in the order of the benchmarks.
+var Circuits = []Circuit{
+	{Name: "keccak_chain", Field: ecc.BN254.ScalarField(), New: func() frontend.Circuit { return &KeccakChain{} }, Assignment: keccakChainAssignment},
+	{Name: "poseidon2_merkle", Field: m31.ScalarField, New: newPoseidonMerkle, Assignment: poseidonMerkleAssignment},
+	{Name: "matmul", Field: m31.ScalarField, New: newMatMul, Assignment: matMulAssignment},
+	{Name: "emulated_secp256k1", Field: ecc.BN254.ScalarField(), New: func() frontend.Circuit { return &EmulatedMul{} }, Assignment: emulatedMulAssignment},
+}
+
+// KeccakChain hashes Input KeccakChainLength times with Keccak-256, each hash being the input of
+// the next one, and checks that the last one is Output.
+type KeccakChain struct {
+	Input  [32]frontend.Variable
+	Output [32]frontend.Variable `gnark:",public"`
+}
+
+func (c *KeccakChain) Define(api frontend.API) error {
+	h := c.Input[:]
+	for i := 0; i < KeccakChainLength; i++ {
+		h = keccak.Keccak256(api, h)
+	}
+	for i := range h {
+		api.AssertIsEqual(h[i], c.Output[i])
+	}
+	return nil
+}
+
+func keccakChainAssignment() frontend.Circuit {
+	res := &KeccakChain{}
+	h := make([]byte, 32)
+	for i := range h {
+		h[i] = byte(i)
+		res.Input[i] = h[i]
+	}
+	for i := 0; i < KeccakChainLength; i++ {
+		k := sha3.NewLegacyKeccak256()
+		k.Write(h)
+		h = k.Sum(nil)
+	}
+	for i := range h {
+		res.Output[i] = h[i]
+	}
+	return res
+}
+
+// PoseidonMerkle verifies MerkleOpenings openings of a tree of depth MerkleDepth, hashed with
+// Poseidon2 over M31.
+type PoseidonMerkle struct {
+	Root     []frontend.Variable `gnark:",public"`
+	Openings []merkle.Opening
+}
+
+func (c *PoseidonMerkle) Define(api frontend.API) error {
+	merkle.VerifyBatch(api, merkle.Poseidon2, c.Root, c.Openings)
+	return nil
+}
+
+func newPoseidonMerkle() frontend.Circuit {
+	c := &PoseidonMerkle{Root: make([]frontend.Variable, merkle.Poseidon2.NodeSize)}
+	for i := 0; i < MerkleOpenings; i++ {
+		c.Openings = append(c.Openings, merkle.NewOpening(merkle.Poseidon2, MerkleDepth))
+	}
+	return c
+}
+
+func poseidonMerkleAssignment() frontend.Circuit {
+	leaves := make([][]uint64, 1<<MerkleDepth)
+	for i := range leaves {
+		leaves[i] = make([]uint64, merkle.Poseidon2.NodeSize)
+		for j := range leaves[i] {
+			leaves[i][j] = uint64(i*31 + j*7 + 1)
+		}
+	}
+	tree := merkle.NewTree(merkle.Poseidon2, leaves)
+	res := &PoseidonMerkle{Root: tree.RootAssignment()}
+	for i := 0; i < MerkleOpenings; i++ {
+		res.Openings = append(res.Openings, tree.Assignment(i*len(leaves)/MerkleOpenings+i%2))
+	}
+	return res
+}
+
+// MatMul checks that C is the product of the MatMulSize x MatMulSize matrices A and B.
+type MatMul struct {
+	A, B [][]frontend.Variable
+	C    [][]frontend.Variable `gnark:",public"`
+}
+
+func (c *MatMul) Define(api frontend.API) error {
+	res := linalg.MatMul(api, c.A, c.B)
+	for i := range res {
+		for j := range res[i] {
+			api.AssertIsEqual(res[i][j], c.C[i][j])
+		}
+	}
+	return nil
+}
+
+func newMatrix() [][]frontend.Variable {
+	res := make([][]frontend.Variable, MatMulSize)
+	for i := range res {
+		res[i] = make([]frontend.Variable, MatMulSize)
+	}
+	return res
+}
+
+func newMatMul() frontend.Circuit {
+	return &MatMul{A: newMatrix(), B: newMatrix(), C: newMatrix()}
+}
+
+func matMulAssignment() frontend.Circuit {
+	res := &MatMul{A: newMatrix(), B: newMatrix(), C: newMatrix()}
+	p := m31.ScalarField.Uint64()
+	for i := 0; i < MatMulSize; i++ {
+		for j := 0; j < MatMulSize; j++ {
+			res.A[i][j] = uint64(i*j+1) % p
+			res.B[i][j] = uint64(i+2*j) % p
+		}
+	}
+	for i := 0; i < MatMulSize; i++ {
+		for j := 0; j < MatMulSize; j++ {
+			var s uint64
+			for l := 0; l < MatMulSize; l++ {
+				s = (s + res.A[i][l].(uint64)*res.B[l][j].(uint64)) % p
+			}
+			res.C[i][j] = s
+		}
+	}
+	return res
+}
+
+// EmulatedMul checks that Z = X * Y^EmulatedMuls in the base field of secp256k1, emulated over
+// BN254.
+type EmulatedMul struct {
+	X, Y emulated.Element[emulated.Secp256k1Fp]
+	Z    emulated.Element[emulated.Secp256k1Fp] `gnark:",public"`
+}
+
+func (c *EmulatedMul) Define(api frontend.API) error {
+	f, err := emulated.NewField[emulated.Secp256k1Fp](api)
+	if err != nil {
+		return err
+	}
+	x := &c.X
+	for i := 0; i < EmulatedMuls; i++ {
+		x = f.Mul(x, &c.Y)
+	}
+	f.AssertIsEqual(x, &c.Z)
+	return nil
+}
+
+func emulatedMulAssignment() frontend.Circuit {
+	p := emulated.Secp256k1Fp{}.Modulus()
+	x, _ := new(big.Int).SetString("12345678901234567890123456789012345678901234567890", 10)
+	y, _ := new(big.Int).SetString("98765432109876543210987654321098765432109876543210", 10)
+	z := new(big.Int).Exp(y, big.NewInt(EmulatedMuls), p)
+	z.Mul(z, x).Mod(z, p)
+	return &EmulatedMul{
+		X: emulated.ValueOf[emulated.Secp256k1Fp](x),
+		Y: emulated.ValueOf[emulated.Secp256k1Fp](y),
+		Z: emulated.ValueOf[emulated.Secp256k1Fp](z),
+	}
+}
@@ -0,0 +1,105 @@
+package bench
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+	"github.com/consensys/gnark/test"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+)
+
+func TestAssignments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("solves the reference circuits in the test engine")
+	}
+	for _, c := range Circuits {
+		if err := test.IsSolved(c.New(), c.Assignment(), c.Field); err != nil {
+			t.Fatalf("%s: %v", c.Name, err)
+		}
+	}
+}
+
+// build defines the circuit with the ecgo builder, like ecgo.Compile before the layering
+func build(c Circuit) *irsource.RootCircuit {
+	root := builder.NewRoot(c.Field, frontend.CompileConfig{})
+	circuit := c.New()
+	schema.Walk(circuit, irwg.TVariable, func(f schema.LeafInfo, v reflect.Value) error {
+		if f.Visibility == schema.Public {
+			v.Set(reflect.ValueOf(root.PublicVariable(f)))
+		} else {
+			v.Set(reflect.ValueOf(root.SecretVariable(f)))
+		}
+		return nil
+	})
+	if err := circuit.Define(root); err != nil {
+		panic(err)
+	}
+	return root.Finalize()
+}
+
+// BenchmarkBuild measures the definition of the reference circuits, which doesn't need the Rust
+// library, and reports the number of instructions of their source IR.
+func BenchmarkBuild(b *testing.B) {
+	for _, c := range Circuits {
+		b.Run(c.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			n := 0
+			for i := 0; i < b.N; i++ {
+				n = 0
+				for _, sub := range build(c).Circuits {
+					n += len(sub.Instructions)
+				}
+			}
+			b.ReportMetric(float64(n), "instructions")
+		})
+	}
+}
+
+// BenchmarkCompile measures the compilation of the reference circuits, and reports the size of
+// their layered circuits.
+func BenchmarkCompile(b *testing.B) {
+	for _, c := range Circuits {
+		b.Run(c.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			var res *ecgo.CompileResult
+			for i := 0; i < b.N; i++ {
+				var err error
+				res, err = ecgo.Compile(c.Field, c.New())
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			s := res.Stats()
+			b.ReportMetric(float64(s.TotalGates()), "gates")
+			b.ReportMetric(float64(s.NumLayers), "layers")
+			b.ReportMetric(float64(s.MaxWidth), "width")
+		})
+	}
+}
+
+// BenchmarkSolve measures the witness solving of the assignments of the reference circuits.
+func BenchmarkSolve(b *testing.B) {
+	for _, c := range Circuits {
+		b.Run(c.Name, func(b *testing.B) {
+			res, err := ecgo.Compile(c.Field, c.New())
+			if err != nil {
+				b.Fatal(err)
+			}
+			solver := res.GetInputSolver()
+			assignment := c.Assignment()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := solver.SolveInputAuto(assignment); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Package ecdsa verifies ECDSA signatures over secp256k1.
+//
+// Coordinates and scalars are non-native field elements, handled by gnark's emulated arithmetic.
+// When compiled with ecgo, the range checks of the limbs are lookups in a shared table of the
+// root circuit (see builder.Check), so Verify should be called from the root circuit: in
+// subcircuits, range checks fall back to a much more expensive binary decomposition.
+package ecdsa
+
+import (
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/secp256k1"
+	"github.com/consensys/gnark-crypto/ecc/secp256k1/ecdsa"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra/emulated/sw_emulated"
+	"github.com/consensys/gnark/std/math/emulated"
+)
+
+// Fp is the base field of secp256k1, and Fr its scalar field.
+type (
+	Fp = emulated.Secp256k1Fp
+	Fr = emulated.Secp256k1Fr
+)
+
+// PublicKey is a point of secp256k1.
+type PublicKey = sw_emulated.AffinePoint[Fp]
+
+// Signature is an ECDSA signature (r, s).
+type Signature struct {
+	R, S emulated.Element[Fr]
+}
+
+// Verify asserts that sig is a valid signature of the message hash msg, already reduced to the
+// scalar field (see ecdsa.HashToInt), for the public key pk: with u1 = msg/s and u2 = r/s, the
+// x coordinate of [u1]G + [u2]pk must be r modulo the group order.
+func Verify(api frontend.API, pk *PublicKey, msg *emulated.Element[Fr], sig *Signature) {
+	curve, err := sw_emulated.New[Fp, Fr](api, sw_emulated.GetSecp256k1Params())
+	if err != nil {
+		panic(err)
+	}
+	// fields are cached by the builder, so several verifications share their checks
+	fr, err := emulated.NewField[Fr](api)
+	if err != nil {
+		panic(err)
+	}
+	fp, err := emulated.NewField[Fp](api)
+	if err != nil {
+		panic(err)
+	}
+	sInv := fr.Inverse(&sig.S)
+	u1 := fr.MulMod(msg, sInv)
+	u2 := fr.MulMod(&sig.R, sInv)
+	q := curve.JointScalarMulBase(pk, u2, u1)
+
+	// x is compared to r as an integer, which rejects the valid signatures with x = r + n,
+	// occurring with probability about 2^-128
+	x := fp.ToBits(fp.Reduce(&q.X))
+	r := fr.ToBits(&sig.R)
+	for i := range r {
+		api.AssertIsEqual(x[i], r[i])
+	}
+}
+
+// ValueOfPublicKey returns the assignment of a public key.
+func ValueOfPublicKey(pk *ecdsa.PublicKey) PublicKey {
+	return ValueOfPoint(&pk.A)
+}
+
+// ValueOfPoint returns the assignment of a point.
+func ValueOfPoint(p *secp256k1.G1Affine) PublicKey {
+	var x, y big.Int
+	p.X.BigInt(&x)
+	p.Y.BigInt(&y)
+	return PublicKey{
+		X: emulated.ValueOf[Fp](&x),
+		Y: emulated.ValueOf[Fp](&y),
+	}
+}
+
+// ValueOfSignature returns the assignment of a signature serialized by ecdsa.PrivateKey.Sign.
+func ValueOfSignature(sigBin []byte) (Signature, error) {
+	var sig ecdsa.Signature
+	if _, err := sig.SetBytes(sigBin); err != nil {
+		return Signature{}, err
+	}
+	r := new(big.Int).SetBytes(sig.R[:])
+	s := new(big.Int).SetBytes(sig.S[:])
+	return Signature{
+		R: emulated.ValueOf[Fr](r),
+		S: emulated.ValueOf[Fr](s),
+	}, nil
+}
+
+// ValueOfMessage returns the assignment of the hash of msg, as computed by ecdsa.PublicKey.Verify
+// without a custom hash function.
+func ValueOfMessage(msg []byte) emulated.Element[Fr] {
+	return emulated.ValueOf[Fr](ecdsa.HashToInt(msg))
+}
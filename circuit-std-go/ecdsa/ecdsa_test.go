@@ -0,0 +1,114 @@
+package ecdsa
+
+import (
+	"crypto/rand"
+	"reflect"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/secp256k1/ecdsa"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/schema"
+	"github.com/consensys/gnark/std/math/emulated"
+	"github.com/consensys/gnark/test"
+)
+
+type verifyCircuit struct {
+	Pub PublicKey
+	Msg emulated.Element[Fr]
+	Sig Signature
+}
+
+func (c *verifyCircuit) Define(api frontend.API) error {
+	Verify(api, &c.Pub, &c.Msg, &c.Sig)
+	return nil
+}
+
+func signedAssignment(t testing.TB, msg []byte) *verifyCircuit {
+	key, err := ecdsa.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigBin, err := key.Sign(msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := ValueOfSignature(sigBin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &verifyCircuit{
+		Pub: ValueOfPublicKey(&key.PublicKey),
+		Msg: ValueOfMessage(msg),
+		Sig: sig,
+	}
+}
+
+func TestVerify(t *testing.T) {
+	if testing.Short() {
+		t.Skip("slow in the test engine")
+	}
+	assignment := signedAssignment(t, []byte("hello"))
+	if err := test.IsSolved(&verifyCircuit{}, assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+	assignment.Msg = ValueOfMessage([]byte("hellp"))
+	if test.IsSolved(&verifyCircuit{}, assignment, ecc.BN254.ScalarField()) == nil {
+		t.Fatal("expected the signature of another message to be rejected")
+	}
+}
+
+// buildVerify builds the verification circuit with the ecgo builder, like ecgo.Compile
+func buildVerify() *irsource.RootCircuit {
+	root := builder.NewRoot(ecc.BN254.ScalarField(), frontend.CompileConfig{})
+	c := &verifyCircuit{}
+	schema.Walk(c, irwg.TVariable, func(f schema.LeafInfo, v reflect.Value) error {
+		v.Set(reflect.ValueOf(root.SecretVariable(f)))
+		return nil
+	})
+	if err := c.Define(root); err != nil {
+		panic(err)
+	}
+	return root.Finalize()
+}
+
+func TestVerifyWithBuilder(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a large circuit")
+	}
+	rc := buildVerify()
+	if rc.Circuits[0].NumInputs != 5*4 || len(rc.Circuits[0].Instructions) == 0 {
+		t.Fatal("unexpected circuit")
+	}
+}
+
+// BenchmarkVerify compares the size of the circuit built by ecgo, in instructions of the source IR,
+// to the number of constraints of the R1CS built by gnark for groth16.
+func BenchmarkVerify(b *testing.B) {
+	b.Run("ecgo", func(b *testing.B) {
+		n := 0
+		for i := 0; i < b.N; i++ {
+			rc := buildVerify()
+			n = 0
+			for _, c := range rc.Circuits {
+				n += len(c.Instructions)
+			}
+		}
+		b.ReportMetric(float64(n), "instructions")
+	})
+	b.Run("groth16", func(b *testing.B) {
+		n := 0
+		for i := 0; i < b.N; i++ {
+			cs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &verifyCircuit{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			n = cs.GetNbConstraints()
+		}
+		b.ReportMetric(float64(n), "constraints")
+	})
+}
@@ -0,0 +1,179 @@
+// Package fixedpoint implements signed fixed-point arithmetic, as used by quantized neural
+// networks. A number of a Format is a signed integer v of Bits bits, including the sign, which
+// represents v / 2^Frac. It's stored as the field element v, negative numbers being p - |v|, so
+// additions are field additions. A Format with no fractional bits is integer arithmetic.
+//
+// The results of the operations are range checked with frontend.Rangechecker, which the ecgo
+// builder implements with lookups in its range table, so an overflow makes the circuit
+// unsatisfiable instead of wrapping around. Products are computed in the field before being
+// rescaled, so 2*Bits must be less than the bit length of the field: Bits is at most 15 with
+// M31, and 64 fits easily in BN254.
+package fixedpoint
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/rangecheck"
+)
+
+func init() {
+	solver.RegisterHint(divModHint, signHint)
+}
+
+// Format is the layout of fixed-point numbers.
+type Format struct {
+	// Bits is the width of the numbers, including the sign bit.
+	Bits int
+	// Frac is the number of fractional bits.
+	Frac int
+}
+
+// Encode returns the field element representing x, rounded to the nearest number of the format,
+// in a field of the given modulus. It panics if x is out of the range of the format.
+func (f Format) Encode(x float64, modulus *big.Int) *big.Int {
+	v := math.Round(math.Ldexp(x, f.Frac))
+	if v < -math.Ldexp(1, f.Bits-1) || v >= math.Ldexp(1, f.Bits-1) {
+		panic(fmt.Sprintf("fixedpoint: %v is out of range of %d bits with %d fractional bits", x, f.Bits, f.Frac))
+	}
+	res, _ := new(big.Float).SetFloat64(v).Int(nil)
+	return res.Mod(res, modulus)
+}
+
+// Decode returns the number represented by the field element x of a field of the given modulus.
+func (f Format) Decode(x *big.Int, modulus *big.Int) float64 {
+	v := new(big.Int).Mod(x, modulus)
+	if v.Cmp(new(big.Int).Rsh(modulus, 1)) > 0 {
+		v.Sub(v, modulus)
+	}
+	r, _ := new(big.Float).SetInt(v).Float64()
+	return math.Ldexp(r, -f.Frac)
+}
+
+// API performs fixed-point arithmetic in a circuit. Operands must be numbers of its format: the
+// results of its operations, constants from Constant, or inputs checked with AssertIsValid.
+type API struct {
+	api frontend.API
+	rc  frontend.Rangechecker
+	f   Format
+}
+
+// New returns an API for numbers of the format f.
+func New(api frontend.API, f Format) *API {
+	if f.Bits <= 0 || f.Frac < 0 || f.Frac >= f.Bits {
+		panic(fmt.Sprintf("fixedpoint: invalid format of %d bits with %d fractional bits", f.Bits, f.Frac))
+	}
+	if 2*f.Bits >= api.Compiler().FieldBitLen() {
+		panic(fmt.Sprintf("fixedpoint: %d bits don't fit in a field of %d bits", f.Bits, api.Compiler().FieldBitLen()))
+	}
+	return &API{api: api, rc: rangecheck.New(api), f: f}
+}
+
+// Constant returns the number nearest to x.
+func (a *API) Constant(x float64) frontend.Variable {
+	return a.f.Encode(x, a.api.Compiler().Field())
+}
+
+// offset returns 2^(bits-1), which maps signed numbers of bits bits to unsigned ones
+func offset(bits int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+}
+
+// checkSigned asserts that x is a signed number of bits bits.
+func (a *API) checkSigned(x frontend.Variable, bits int) {
+	a.rc.Check(a.api.Add(x, offset(bits)), bits)
+}
+
+// AssertIsValid asserts that x is a number of the format, e.g. for inputs of the circuit.
+func (a *API) AssertIsValid(x frontend.Variable) {
+	a.checkSigned(x, a.f.Bits)
+}
+
+// Add returns x + y, asserting that it doesn't overflow.
+func (a *API) Add(x, y frontend.Variable) frontend.Variable {
+	res := a.api.Add(x, y)
+	a.checkSigned(res, a.f.Bits)
+	return res
+}
+
+// Sub returns x - y, asserting that it doesn't overflow.
+func (a *API) Sub(x, y frontend.Variable) frontend.Variable {
+	res := a.api.Sub(x, y)
+	a.checkSigned(res, a.f.Bits)
+	return res
+}
+
+// Mul returns x * y rounded towards negative infinity, asserting that it doesn't overflow.
+func (a *API) Mul(x, y frontend.Variable) frontend.Variable {
+	// the product is a signed number of 2*Bits-1 bits, made unsigned to be divided
+	o := offset(2*a.f.Bits - 1)
+	t := a.api.Add(a.api.Mul(x, y), o)
+	qr, err := a.api.Compiler().NewHint(divModHint, 2, t, a.f.Frac)
+	if err != nil {
+		panic(err)
+	}
+	q, r := qr[0], qr[1]
+	a.rc.Check(r, a.f.Frac)
+	a.api.AssertIsEqual(a.api.Add(a.api.Mul(q, new(big.Int).Lsh(big.NewInt(1), uint(a.f.Frac))), r), t)
+	res := a.api.Sub(q, new(big.Int).Rsh(o, uint(a.f.Frac)))
+	a.checkSigned(res, a.f.Bits)
+	return res
+}
+
+// IsNegative returns 1 if x < 0 and 0 otherwise.
+func (a *API) IsNegative(x frontend.Variable) frontend.Variable {
+	return a.isNegative(x, a.f.Bits)
+}
+
+// isNegative returns the sign of x, a signed number of bits bits: x + 2^(bits-1) is decomposed
+// into its top bit, which is 1 iff x >= 0, and bits-1 low bits.
+func (a *API) isNegative(x frontend.Variable, bits int) frontend.Variable {
+	o := offset(bits)
+	u := a.api.Add(x, o)
+	s, err := a.api.Compiler().NewHint(signHint, 1, u, bits)
+	if err != nil {
+		panic(err)
+	}
+	nonNegative := s[0]
+	a.api.AssertIsBoolean(nonNegative)
+	a.rc.Check(a.api.Sub(u, a.api.Mul(nonNegative, o)), bits-1)
+	return a.api.Sub(1, nonNegative)
+}
+
+// Relu returns max(x, 0).
+func (a *API) Relu(x frontend.Variable) frontend.Variable {
+	return a.api.Mul(x, a.api.Sub(1, a.IsNegative(x)))
+}
+
+// IsLess returns 1 if x < y and 0 otherwise.
+func (a *API) IsLess(x, y frontend.Variable) frontend.Variable {
+	// the difference of two numbers has one more bit
+	return a.isNegative(a.api.Sub(x, y), a.f.Bits+1)
+}
+
+// Max returns the largest of x and y.
+func (a *API) Max(x, y frontend.Variable) frontend.Variable {
+	return a.api.Select(a.IsLess(x, y), y, x)
+}
+
+// AssertIsLessOrEqual asserts that x <= y.
+func (a *API) AssertIsLessOrEqual(x, y frontend.Variable) {
+	a.rc.Check(a.api.Sub(y, x), a.f.Bits)
+}
+
+// divModHint computes the quotient and the remainder of inputs[0] by 2^inputs[1]
+func divModHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	k := uint(inputs[1].Uint64())
+	outputs[0].Rsh(inputs[0], k)
+	outputs[1].Sub(inputs[0], new(big.Int).Lsh(outputs[0], k))
+	return nil
+}
+
+// signHint returns the bit inputs[1]-1 of inputs[0]
+func signHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	outputs[0].SetUint64(uint64(inputs[0].Bit(int(inputs[1].Int64()) - 1)))
+	return nil
+}
@@ -0,0 +1,102 @@
+package fixedpoint
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/field/m31"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+type opsCircuit struct {
+	f    Format
+	X, Y frontend.Variable
+	// Sum, Prod, Relu of X, IsLess(X, Y) and Max(X, Y)
+	Expected [5]frontend.Variable `gnark:",public"`
+}
+
+func (c *opsCircuit) Define(api frontend.API) error {
+	fp := New(api, c.f)
+	fp.AssertIsValid(c.X)
+	fp.AssertIsValid(c.Y)
+	api.AssertIsEqual(fp.Add(c.X, c.Y), c.Expected[0])
+	api.AssertIsEqual(fp.Mul(c.X, c.Y), c.Expected[1])
+	api.AssertIsEqual(fp.Relu(c.X), c.Expected[2])
+	api.AssertIsEqual(fp.IsLess(c.X, c.Y), c.Expected[3])
+	api.AssertIsEqual(fp.Max(c.X, c.Y), c.Expected[4])
+	fp.AssertIsLessOrEqual(fp.Sub(c.X, fp.Constant(1)), fp.Max(c.X, c.Y))
+	return nil
+}
+
+func testOps(t *testing.T, f Format, modulus *big.Int) {
+	for _, c := range []struct {
+		x, y                         float64
+		sum, prod, relu, less, maxXY float64
+	}{
+		{1.5, 2.25, 3.75, 3.375, 1.5, 1, 2.25},
+		{-1.5, 2.25, 0.75, -3.375, 0, 1, 2.25},
+		{-1.5, -2.5, -4, 3.75, 0, 0, -1.5},
+		{0, -0.5, -0.5, 0, 0, 0, 0},
+	} {
+		circuit := &opsCircuit{f: f}
+		assignment := &opsCircuit{X: f.Encode(c.x, modulus), Y: f.Encode(c.y, modulus)}
+		for i, v := range []float64{c.sum, c.prod, c.relu} {
+			assignment.Expected[i] = f.Encode(v, modulus)
+		}
+		assignment.Expected[3] = int(c.less)
+		assignment.Expected[4] = f.Encode(c.maxXY, modulus)
+		if err := test.IsSolved(circuit, assignment, modulus); err != nil {
+			t.Fatalf("%v, %v: %v", c.x, c.y, err)
+		}
+		assignment.Expected[1] = f.Encode(c.prod+1, modulus)
+		if err := test.IsSolved(circuit, assignment, modulus); err == nil {
+			t.Fatalf("%v, %v: expected a wrong product to be rejected", c.x, c.y)
+		}
+	}
+}
+
+func TestOpsM31(t *testing.T) {
+	testOps(t, Format{Bits: 12, Frac: 4}, m31.ScalarField)
+}
+
+func TestOpsBN254(t *testing.T) {
+	testOps(t, Format{Bits: 32, Frac: 16}, ecc.BN254.ScalarField())
+}
+
+type mulCircuit struct {
+	f       Format
+	X, Y, Z frontend.Variable
+}
+
+func (c *mulCircuit) Define(api frontend.API) error {
+	fp := New(api, c.f)
+	api.AssertIsEqual(fp.Mul(c.X, c.Y), c.Z)
+	return nil
+}
+
+func TestMulRounding(t *testing.T) {
+	f, modulus := Format{Bits: 12, Frac: 4}, m31.ScalarField
+	// 0.0625 * 0.5 = 0.03125 is rounded down to 0, and -0.0625 * 0.5 to -0.0625
+	for _, c := range []struct{ x, y, z float64 }{{0.0625, 0.5, 0}, {-0.0625, 0.5, -0.0625}} {
+		assignment := &mulCircuit{X: f.Encode(c.x, modulus), Y: f.Encode(c.y, modulus), Z: f.Encode(c.z, modulus)}
+		if err := test.IsSolved(&mulCircuit{f: f}, assignment, modulus); err != nil {
+			t.Fatalf("%v * %v: %v", c.x, c.y, err)
+		}
+	}
+	// 100 * 100 overflows 12 bits with 4 fractional bits
+	assignment := &mulCircuit{X: f.Encode(100, modulus), Y: f.Encode(100, modulus), Z: 0}
+	if err := test.IsSolved(&mulCircuit{f: f}, assignment, modulus); err == nil {
+		t.Fatal("expected an overflow to be rejected")
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	f := Format{Bits: 16, Frac: 8}
+	for _, x := range []float64{0, 1, -1, 3.5, -127.99609375} {
+		if y := f.Decode(f.Encode(x, m31.ScalarField), m31.ScalarField); y != x {
+			t.Fatalf("Decode(Encode(%v)) = %v", x, y)
+		}
+	}
+}
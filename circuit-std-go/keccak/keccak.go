@@ -0,0 +1,146 @@
+// Package keccak implements Keccak-256 for this compiler. The state is kept as 1600 bits, so
+// theta and chi are wide layers of bit operations, and the permutation round is a subcircuit
+// that is reused by all rounds of all blocks.
+package keccak
+
+import (
+	"github.com/consensys/gnark/frontend"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+)
+
+const (
+	laneBits  = 64
+	stateBits = 25 * laneBits
+	rateBytes = 136
+	nbRounds  = 24
+)
+
+var roundConstants = [nbRounds]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// rotation offsets, indexed by x + 5y
+var rhoOffsets = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+// Keccak256 returns the 32 bytes of the Keccak-256 hash (the original padding, as used by
+// Ethereum, not SHA3-256) of data, given as bytes. The bytes are range checked.
+func Keccak256(api frontend.API, data []frontend.Variable) []frontend.Variable {
+	msg := make([]frontend.Variable, 0, (len(data)/rateBytes+1)*rateBytes*8)
+	for _, b := range data {
+		msg = append(msg, api.ToBinary(b, 8)...)
+	}
+	// pad10*1 with the 0x01 domain byte
+	padBytes := rateBytes - len(data)%rateBytes
+	for i := 0; i < padBytes*8; i++ {
+		bit := 0
+		if i == 0 || i == padBytes*8-1 {
+			bit = 1
+		}
+		msg = append(msg, bit)
+	}
+
+	state := make([]frontend.Variable, stateBits)
+	for i := range state {
+		state[i] = 0
+	}
+	for block := 0; block < len(msg); block += rateBytes * 8 {
+		for i := 0; i < rateBytes*8; i++ {
+			state[i] = xor(api, state[i], msg[block+i])
+		}
+		state = permute(api, state)
+	}
+
+	res := make([]frontend.Variable, 32)
+	for i := range res {
+		res[i] = api.FromBinary(state[i*8 : i*8+8]...)
+	}
+	return res
+}
+
+func permute(api frontend.API, state []frontend.Variable) []frontend.Variable {
+	for r := 0; r < nbRounds; r++ {
+		input := make([]frontend.Variable, 0, stateBits+laneBits)
+		input = append(input, state...)
+		for z := 0; z < laneBits; z++ {
+			input = append(input, (roundConstants[r]>>z)&1)
+		}
+		if sub, ok := api.(builder.SubCircuitAPI); ok {
+			state = sub.MemorizedSimpleCall(round, input)
+		} else {
+			state = round(api, input)
+		}
+	}
+	return state
+}
+
+func xor(api frontend.API, a, b frontend.Variable) frontend.Variable {
+	return api.Sub(api.Add(a, b), api.Mul(2, api.Mul(a, b)))
+}
+
+func bit(state []frontend.Variable, x, y, z int) frontend.Variable {
+	return state[((x%5)+5*(y%5))*laneBits+z]
+}
+
+// round applies one round of Keccak-f[1600]. The input is the state followed by the bits of the
+// round constant, so the same subcircuit serves all rounds.
+func round(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	a := input[:stateBits]
+	rc := input[stateBits:]
+
+	// theta
+	c := make([]frontend.Variable, 5*laneBits)
+	for x := 0; x < 5; x++ {
+		for z := 0; z < laneBits; z++ {
+			t := xor(api, bit(a, x, 0, z), bit(a, x, 1, z))
+			t2 := xor(api, bit(a, x, 2, z), bit(a, x, 3, z))
+			c[x*laneBits+z] = xor(api, xor(api, t, t2), bit(a, x, 4, z))
+		}
+	}
+	d := make([]frontend.Variable, 5*laneBits)
+	for x := 0; x < 5; x++ {
+		for z := 0; z < laneBits; z++ {
+			d[x*laneBits+z] = xor(api, c[((x+4)%5)*laneBits+z], c[((x+1)%5)*laneBits+(z+laneBits-1)%laneBits])
+		}
+	}
+
+	// theta, rho and pi: b[y][2x+3y] = rot(a[x][y] ^ d[x], r[x][y])
+	b := make([]frontend.Variable, stateBits)
+	for x := 0; x < 5; x++ {
+		for y := 0; y < 5; y++ {
+			r := rhoOffsets[x+5*y]
+			nx, ny := y, (2*x+3*y)%5
+			for z := 0; z < laneBits; z++ {
+				sz := (z + laneBits - r) % laneBits
+				b[(nx+5*ny)*laneBits+z] = xor(api, bit(a, x, y, sz), d[x*laneBits+sz])
+			}
+		}
+	}
+
+	// chi and iota
+	res := make([]frontend.Variable, stateBits)
+	for x := 0; x < 5; x++ {
+		for y := 0; y < 5; y++ {
+			for z := 0; z < laneBits; z++ {
+				nb := api.Sub(1, bit(b, x+1, y, z))
+				v := xor(api, bit(b, x, y, z), api.Mul(nb, bit(b, x+2, y, z)))
+				if x == 0 && y == 0 {
+					v = xor(api, v, rc[z])
+				}
+				res[(x+5*y)*laneBits+z] = v
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,47 @@
+package keccak
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"golang.org/x/crypto/sha3"
+)
+
+type keccakCircuit struct {
+	Data   []frontend.Variable
+	Digest [32]frontend.Variable
+}
+
+func (c *keccakCircuit) Define(api frontend.API) error {
+	res := Keccak256(api, c.Data)
+	for i := range res {
+		api.AssertIsEqual(res[i], c.Digest[i])
+	}
+	return nil
+}
+
+func TestKeccak256(t *testing.T) {
+	for _, n := range []int{0, 3, 135, 136, 200} {
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = byte(i*7 + 1)
+		}
+		h := sha3.NewLegacyKeccak256()
+		h.Write(data)
+		digest := h.Sum(nil)
+
+		assignment := &keccakCircuit{Data: make([]frontend.Variable, n)}
+		for i := range data {
+			assignment.Data[i] = data[i]
+		}
+		for i := range digest {
+			assignment.Digest[i] = digest[i]
+		}
+		circuit := &keccakCircuit{Data: make([]frontend.Variable, n)}
+		if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("length %d: %v", n, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package linalg provides linear algebra gadgets for circuits over matrices of field elements,
+// like the layers of neural networks.
+//
+// MatMul splits the product into tiles: the product of a TileSize x TileSize block of A by a
+// block of B is a memoized subcircuit, so a large product is a few distinct subcircuits called
+// many times side by side, and the partial products of each output tile are summed by a
+// balanced tree. The depth of the layered circuit is logarithmic in the inner dimension, and its
+// layers are wide and regular.
+package linalg
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark/frontend"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+)
+
+// TileSize is the size of the blocks of MatMul.
+const TileSize = 16
+
+// vectorAPI is implemented by the ecgo builder, whose vector operations are single instructions
+type vectorAPI interface {
+	AddVec(a, b []frontend.Variable) []frontend.Variable
+	InnerProduct(a, b []frontend.Variable) frontend.Variable
+}
+
+// MatMul returns the product of the m x k matrix A by the k x n matrix B, as m rows of n
+// elements. Rows of a matrix must all have the same length.
+func MatMul(api frontend.API, A, B [][]frontend.Variable) [][]frontend.Variable {
+	return MatMulTiled(api, A, B, TileSize)
+}
+
+// MatMulTiled is MatMul with blocks of tile x tile elements. Larger tiles give fewer but larger
+// subcircuits, and shallower accumulation trees.
+func MatMulTiled(api frontend.API, A, B [][]frontend.Variable, tile int) [][]frontend.Variable {
+	if tile <= 0 {
+		panic("linalg: tile size must be positive")
+	}
+	m, k := dims(A)
+	k2, n := dims(B)
+	if k != k2 {
+		panic(fmt.Sprintf("linalg: cannot multiply a %dx%d matrix by a %dx%d matrix", m, k, k2, n))
+	}
+	C := make([][]frontend.Variable, m)
+	for i := range C {
+		C[i] = make([]frontend.Variable, n)
+		for j := range C[i] {
+			C[i][j] = 0
+		}
+	}
+	if k == 0 {
+		return C
+	}
+	for i0 := 0; i0 < m; i0 += tile {
+		for j0 := 0; j0 < n; j0 += tile {
+			var partials [][][]frontend.Variable
+			for l0 := 0; l0 < k; l0 += tile {
+				a := block(A, i0, l0, tile)
+				b := transpose(block(B, l0, j0, tile))
+				partials = append(partials, builder.MemorizedCallN(api, mulTile, struct{}{}, tileOperands{A: a, Bt: b}))
+			}
+			sum := sumTree(api, partials)
+			for i := range sum {
+				copy(C[i0+i][j0:], sum[i])
+			}
+		}
+	}
+	return C
+}
+
+// tileOperands are the blocks multiplied by mulTile, B being transposed so that each output is
+// the inner product of a row of A and a row of Bt
+type tileOperands struct {
+	A  [][]frontend.Variable
+	Bt [][]frontend.Variable
+}
+
+func mulTile(api frontend.API, _ struct{}, in tileOperands) [][]frontend.Variable {
+	res := make([][]frontend.Variable, len(in.A))
+	for i, row := range in.A {
+		res[i] = make([]frontend.Variable, len(in.Bt))
+		for j, col := range in.Bt {
+			res[i][j] = innerProduct(api, row, col)
+		}
+	}
+	return res
+}
+
+func innerProduct(api frontend.API, a, b []frontend.Variable) frontend.Variable {
+	if v, ok := api.(vectorAPI); ok {
+		return v.InnerProduct(a, b)
+	}
+	var res frontend.Variable = 0
+	for i := range a {
+		res = api.MulAcc(res, a[i], b[i])
+	}
+	return res
+}
+
+// sumTree adds matrices of the same shape by a balanced tree
+func sumTree(api frontend.API, ms [][][]frontend.Variable) [][]frontend.Variable {
+	for len(ms) > 1 {
+		next := make([][][]frontend.Variable, 0, (len(ms)+1)/2)
+		for i := 0; i+1 < len(ms); i += 2 {
+			next = append(next, add(api, ms[i], ms[i+1]))
+		}
+		if len(ms)%2 == 1 {
+			next = append(next, ms[len(ms)-1])
+		}
+		ms = next
+	}
+	return ms[0]
+}
+
+func add(api frontend.API, a, b [][]frontend.Variable) [][]frontend.Variable {
+	res := make([][]frontend.Variable, len(a))
+	for i := range a {
+		if v, ok := api.(vectorAPI); ok {
+			res[i] = v.AddVec(a[i], b[i])
+			continue
+		}
+		res[i] = make([]frontend.Variable, len(a[i]))
+		for j := range a[i] {
+			res[i][j] = api.Add(a[i][j], b[i][j])
+		}
+	}
+	return res
+}
+
+// dims returns the numbers of rows and columns of a matrix, panicking if it's ragged
+func dims(M [][]frontend.Variable) (int, int) {
+	if len(M) == 0 {
+		return 0, 0
+	}
+	for _, row := range M {
+		if len(row) != len(M[0]) {
+			panic("linalg: rows of a matrix must have the same length")
+		}
+	}
+	return len(M), len(M[0])
+}
+
+// block returns the block of M of at most size x size elements starting at row i and column j
+func block(M [][]frontend.Variable, i, j, size int) [][]frontend.Variable {
+	rows := M[i:min(i+size, len(M))]
+	res := make([][]frontend.Variable, len(rows))
+	for r, row := range rows {
+		res[r] = row[j:min(j+size, len(row))]
+	}
+	return res
+}
+
+func transpose(M [][]frontend.Variable) [][]frontend.Variable {
+	if len(M) == 0 {
+		return nil
+	}
+	res := make([][]frontend.Variable, len(M[0]))
+	for j := range res {
+		res[j] = make([]frontend.Variable, len(M))
+		for i := range M {
+			res[j][i] = M[i][j]
+		}
+	}
+	return res
+}
@@ -0,0 +1,100 @@
+package linalg
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/field/m31"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+	"github.com/consensys/gnark/test"
+)
+
+type matMulCircuit struct {
+	tile int
+	A    [][]frontend.Variable
+	B    [][]frontend.Variable
+	C    [][]frontend.Variable `gnark:",public"`
+}
+
+func (c *matMulCircuit) Define(api frontend.API) error {
+	C := MatMulTiled(api, c.A, c.B, c.tile)
+	for i := range C {
+		for j := range C[i] {
+			api.AssertIsEqual(C[i][j], c.C[i][j])
+		}
+	}
+	return nil
+}
+
+func matrix(m, n int, f func(i, j int) int) [][]frontend.Variable {
+	M := make([][]frontend.Variable, m)
+	for i := range M {
+		M[i] = make([]frontend.Variable, n)
+		for j := range M[i] {
+			if f != nil {
+				M[i][j] = f(i, j)
+			}
+		}
+	}
+	return M
+}
+
+func newMatMulCircuit(m, k, n, tile int) *matMulCircuit {
+	return &matMulCircuit{tile: tile, A: matrix(m, k, nil), B: matrix(k, n, nil), C: matrix(m, n, nil)}
+}
+
+func TestMatMul(t *testing.T) {
+	const m, k, n = 5, 7, 3
+	a := func(i, j int) int { return i*k + j }
+	b := func(i, j int) int { return (i + 2*j) % 5 }
+	c := func(i, j int) int {
+		s := 0
+		for l := 0; l < k; l++ {
+			s += a(i, l) * b(l, j)
+		}
+		return s
+	}
+	assignment := &matMulCircuit{A: matrix(m, k, a), B: matrix(k, n, b), C: matrix(m, n, c)}
+	for _, tile := range []int{1, 2, 4, 16} {
+		if err := test.IsSolved(newMatMulCircuit(m, k, n, tile), assignment, m31.ScalarField); err != nil {
+			t.Fatalf("tile %d: %v", tile, err)
+		}
+	}
+	assignment.C[4][2] = c(4, 2) + 1
+	if err := test.IsSolved(newMatMulCircuit(m, k, n, 2), assignment, m31.ScalarField); err == nil {
+		t.Fatal("expected a wrong product to be rejected")
+	}
+}
+
+func TestMatMulTiles(t *testing.T) {
+	root := builder.NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	A, B := matrix(8, 8, nil), matrix(8, 8, nil)
+	for _, M := range [][][]frontend.Variable{A, B} {
+		for i := range M {
+			for j := range M[i] {
+				M[i][j] = root.SecretVariable(schema.LeafInfo{})
+			}
+		}
+	}
+	for _, row := range MatMulTiled(root, A, B, 4) {
+		for _, x := range row {
+			root.Output(x)
+		}
+	}
+	rc := root.Finalize()
+	if len(rc.Circuits) != 2 {
+		t.Fatalf("expected a single tile circuit, got %d circuits", len(rc.Circuits))
+	}
+	calls := 0
+	for _, insn := range rc.Circuits[0].Instructions {
+		if insn.Type == irsource.SubCircuitCall {
+			calls++
+		}
+	}
+	// 2x2 output tiles, each the sum of 2 partial products
+	if calls != 8 {
+		t.Fatalf("expected 8 tile products, got %d", calls)
+	}
+}
@@ -0,0 +1,187 @@
+// Package memory implements a random access memory for circuits, with Load and Store at
+// addresses which are variables, checked by offline memory checking instead of a multiplexer
+// over the whole memory at each access.
+//
+// Every initial value and every access is a row (address, time, value, write) of a trace, the
+// initial values being writes at time 0 and the i-th access happening at time i+1. Once the
+// circuit is defined, the trace is sorted by address and time by a hint, and the sorted trace is
+// asserted to be a permutation of the trace with AssertRowPermutation. Then consecutive rows of
+// the sorted trace are either the same address at a later time, where a read must return the
+// previous value, or the next address at time 0, its initial value. The cost is a few
+// constraints and range checks per row, plus the permutation argument: it's linear in the size of
+// the memory plus the number of accesses, instead of their product.
+//
+// The values returned by Load are computed by a hint from the initial values and the previous
+// stores, so the input solver does work quadratic in the number of accesses; the circuit itself
+// is linear.
+package memory
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+	"sort"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/rangecheck"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+)
+
+func init() {
+	solver.RegisterHint(loadHint, sortHint)
+}
+
+// RAM is a memory of a fixed number of cells.
+type RAM struct {
+	api  frontend.API
+	init []frontend.Variable
+	ops  []access
+	// checked is set once the trace is checked, after which the memory can't be accessed
+	checked bool
+}
+
+type access struct {
+	addr, value frontend.Variable
+	write       bool
+}
+
+// New returns a memory whose cell i initially holds init[i]. It must be called on the root
+// circuit, whose API implements builder.API, e.g. an ecgo builder or its test engine. The
+// accesses are checked by a deferred function of api.
+func New(api frontend.API, init []frontend.Variable) *RAM {
+	if _, ok := api.(builder.API); !ok {
+		panic("memory: the API must implement builder.API")
+	}
+	if len(init) == 0 {
+		panic("memory: the memory must have at least one cell")
+	}
+	m := &RAM{api: api, init: append([]frontend.Variable{}, init...)}
+	api.Compiler().Defer(func(api frontend.API) error {
+		m.check(api)
+		return nil
+	})
+	return m
+}
+
+// Size returns the number of cells of the memory.
+func (m *RAM) Size() int {
+	return len(m.init)
+}
+
+// Load returns the value of the cell at addr. Accessing a cell outside of the memory makes the
+// circuit unsatisfiable.
+func (m *RAM) Load(addr frontend.Variable) frontend.Variable {
+	if m.checked {
+		panic("memory: Load after the memory was checked")
+	}
+	inputs := append([]frontend.Variable{addr, len(m.init)}, m.init...)
+	for _, op := range m.ops {
+		if op.write {
+			inputs = append(inputs, op.addr, op.value)
+		}
+	}
+	v, err := m.api.Compiler().NewHint(loadHint, 1, inputs...)
+	if err != nil {
+		panic(err)
+	}
+	m.ops = append(m.ops, access{addr: addr, value: v[0]})
+	return v[0]
+}
+
+// Store sets the cell at addr to value.
+func (m *RAM) Store(addr, value frontend.Variable) {
+	if m.checked {
+		panic("memory: Store after the memory was checked")
+	}
+	m.ops = append(m.ops, access{addr: addr, value: value, write: true})
+}
+
+// check asserts that the accesses are consistent, see the package documentation.
+func (m *RAM) check(api frontend.API) {
+	m.checked = true
+	if len(m.ops) == 0 {
+		return
+	}
+	const width = 4
+	trace := make([][]frontend.Variable, 0, len(m.init)+len(m.ops))
+	for i, v := range m.init {
+		trace = append(trace, []frontend.Variable{i, 0, v, 1})
+	}
+	for i, op := range m.ops {
+		w := 0
+		if op.write {
+			w = 1
+		}
+		trace = append(trace, []frontend.Variable{op.addr, i + 1, op.value, w})
+	}
+	inputs := make([]frontend.Variable, 0, width*len(trace))
+	for _, row := range trace {
+		inputs = append(inputs, row...)
+	}
+	flat, err := api.Compiler().NewHint(sortHint, len(inputs), inputs...)
+	if err != nil {
+		panic(err)
+	}
+	sorted := make([][]frontend.Variable, len(trace))
+	for i := range sorted {
+		sorted[i] = flat[width*i : width*(i+1)]
+	}
+	api.(builder.API).AssertRowPermutation(trace, sorted)
+
+	// the first row is the initial value of cell 0
+	api.AssertIsEqual(sorted[0][0], 0)
+	api.AssertIsEqual(sorted[0][1], 0)
+	timeBits := bits.Len(uint(len(m.ops)))
+	rc := rangecheck.New(api)
+	for i := 1; i < len(sorted); i++ {
+		prev, cur := sorted[i-1], sorted[i]
+		// the address is the same, or the next one starting at time 0
+		next := api.Sub(cur[0], prev[0])
+		api.AssertIsBoolean(next)
+		api.AssertIsEqual(api.Mul(next, cur[1]), 0)
+		// at the same address, the time increases
+		rc.Check(api.Mul(api.Sub(1, next), api.Sub(cur[1], prev[1], 1)), timeBits)
+		// a read returns the previous value; rows at time 0 are writes
+		api.AssertIsEqual(api.Mul(api.Sub(1, cur[3]), api.Sub(cur[2], prev[2])), 0)
+	}
+}
+
+// loadHint returns the value of the cell inputs[0], given the number of cells n, the n initial
+// values and the (address, value) of the previous stores
+func loadHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	addr := inputs[0]
+	n := inputs[1].Int64()
+	if !addr.IsInt64() || addr.Int64() >= n {
+		return fmt.Errorf("memory: address %s is outside of a memory of %d cells", addr, n)
+	}
+	outputs[0].Set(inputs[2+addr.Int64()])
+	stores := inputs[2+n:]
+	for i := 0; i+1 < len(stores); i += 2 {
+		if stores[i].Cmp(addr) == 0 {
+			outputs[0].Set(stores[i+1])
+		}
+	}
+	return nil
+}
+
+// sortHint sorts rows of 4 columns by their first two columns
+func sortHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	rows := make([][]*big.Int, len(inputs)/4)
+	for i := range rows {
+		rows[i] = inputs[4*i : 4*(i+1)]
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if c := rows[i][0].Cmp(rows[j][0]); c != 0 {
+			return c < 0
+		}
+		return rows[i][1].Cmp(rows[j][1]) < 0
+	})
+	for i, row := range rows {
+		for j, x := range row {
+			outputs[4*i+j].Set(x)
+		}
+	}
+	return nil
+}
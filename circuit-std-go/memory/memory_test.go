@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/test"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/field/m31"
+	"github.com/consensys/gnark/frontend"
+)
+
+// programCircuit swaps the cells A and B, then adds the cell C to the cell A
+type programCircuit struct {
+	Init    [4]frontend.Variable
+	A, B, C frontend.Variable
+	// the final memory
+	Final [4]frontend.Variable `gnark:",public"`
+}
+
+func (c *programCircuit) Define(api frontend.API) error {
+	m := New(api, c.Init[:])
+	a, b := m.Load(c.A), m.Load(c.B)
+	m.Store(c.A, b)
+	m.Store(c.B, a)
+	m.Store(c.A, api.Add(m.Load(c.A), m.Load(c.C)))
+	for i := range c.Final {
+		api.AssertIsEqual(m.Load(i), c.Final[i])
+	}
+	return nil
+}
+
+func TestRAM(t *testing.T) {
+	init := [4]frontend.Variable{10, 20, 30, 40}
+	for _, c := range []struct {
+		a, b, cc int
+		final    [4]frontend.Variable
+		ok       bool
+	}{
+		{0, 2, 3, [4]frontend.Variable{70, 20, 10, 40}, true},
+		{1, 1, 1, [4]frontend.Variable{10, 40, 30, 40}, true},
+		{3, 0, 0, [4]frontend.Variable{40, 20, 30, 50}, true},
+		{0, 2, 3, [4]frontend.Variable{70, 20, 30, 40}, false},
+		{0, 4, 3, [4]frontend.Variable{}, false},
+	} {
+		assignment := &programCircuit{Init: init, A: c.a, B: c.b, C: c.cc, Final: c.final}
+		if err := test.IsSolved(&programCircuit{}, assignment, m31.ScalarField); (err == nil) != c.ok {
+			t.Fatalf("%+v: unexpected result %v", c, err)
+		}
+	}
+}
+
+func TestRAMCompiles(t *testing.T) {
+	e, err := ecgo.EstimateResources(m31.ScalarField, &programCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Constraints == 0 {
+		t.Fatal("expected the accesses to be checked")
+	}
+}
@@ -0,0 +1,212 @@
+// Package merkle verifies batches of Merkle openings against a common root.
+//
+// Each level of each opening is a call to the same subcircuit, which orders the node and its
+// sibling according to the path bit and compresses them. With N openings of depth D, the circuit
+// therefore contains N*D calls to a single level circuit, which the layering places side by side:
+// the layers stay wide and regular, which is what GKR provers are good at.
+package merkle
+
+import (
+	"github.com/consensys/gnark/frontend"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/circuit-std-go/keccak"
+	poseidon2M31 "github.com/PolyhedraZK/ExpanderCompilerCollection/circuit-std-go/poseidon2-m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+)
+
+// Hasher is the compression function of a tree.
+type Hasher struct {
+	// NodeSize is the number of field elements of a node.
+	NodeSize int
+	// CompressNative computes the parent of two nodes outside of a circuit.
+	CompressNative func(left, right []uint64) []uint64
+
+	// level takes the node, its sibling and the path bit, and returns the parent
+	level builder.SubCircuitSimpleFunc
+}
+
+var (
+	// Poseidon2 hashes nodes of 8 M31 elements with poseidon2M31.Compress. It requires the M31 field.
+	Poseidon2 = &Hasher{
+		NodeSize:       poseidon2M31.Width / 2,
+		CompressNative: poseidon2CompressNative,
+		level:          poseidon2Level,
+	}
+	// Keccak hashes nodes of 32 bytes, a parent being the Keccak-256 hash of the concatenation of
+	// its children, as in most Ethereum Merkle trees.
+	Keccak = &Hasher{
+		NodeSize:       32,
+		CompressNative: keccakCompressNative,
+		level:          keccakLevel,
+	}
+)
+
+func poseidon2Level(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	left, right := order(api, input, poseidon2M31.Width/2)
+	return poseidon2M31.Compress(api, left, right)
+}
+
+func keccakLevel(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	left, right := order(api, input, 32)
+	return keccak.Keccak256(api, append(left, right...))
+}
+
+// order returns (node, sibling) if the path bit is 0 and (sibling, node) otherwise
+func order(api frontend.API, input []frontend.Variable, n int) ([]frontend.Variable, []frontend.Variable) {
+	node, sibling, bit := input[:n], input[n:2*n], input[2*n]
+	left := make([]frontend.Variable, n)
+	right := make([]frontend.Variable, n)
+	for i := 0; i < n; i++ {
+		// left = node + bit * (sibling - node), right = node + sibling - left
+		d := api.Mul(bit, api.Sub(sibling[i], node[i]))
+		left[i] = api.Add(node[i], d)
+		right[i] = api.Sub(sibling[i], d)
+	}
+	return left, right
+}
+
+func poseidon2CompressNative(left, right []uint64) []uint64 {
+	var state [poseidon2M31.Width]uint64
+	copy(state[:], left)
+	copy(state[len(left):], right)
+	out := poseidon2M31.PermuteNative(state)
+	res := make([]uint64, poseidon2M31.Width/2)
+	for i := range res {
+		res[i] = (out[i] + state[i]) % (1<<31 - 1)
+	}
+	return res
+}
+
+func keccakCompressNative(left, right []uint64) []uint64 {
+	h := sha3.NewLegacyKeccak256()
+	for _, x := range append(append([]uint64{}, left...), right...) {
+		h.Write([]byte{byte(x)})
+	}
+	res := make([]uint64, 32)
+	for i, b := range h.Sum(nil) {
+		res[i] = uint64(b)
+	}
+	return res
+}
+
+// Opening is the proof that Leaf is at position Index of a tree.
+type Opening struct {
+	Leaf []frontend.Variable
+	// Index is the position of the leaf. Its bits, from the least significant one, give the
+	// side of the node at each level: 1 if it's the right child.
+	Index frontend.Variable
+	// Path holds the siblings of the nodes, from the leaf level up to the children of the root.
+	Path [][]frontend.Variable
+}
+
+// NewOpening allocates an opening of a tree of the given depth, to be used in circuit structs.
+func NewOpening(h *Hasher, depth int) Opening {
+	o := Opening{Leaf: make([]frontend.Variable, h.NodeSize), Path: make([][]frontend.Variable, depth)}
+	for i := range o.Path {
+		o.Path[i] = make([]frontend.Variable, h.NodeSize)
+	}
+	return o
+}
+
+// VerifyBatch asserts that all openings are valid for root. Openings may have different depths.
+// Index bits are range checked, so an opening can't claim an index outside of its tree.
+func VerifyBatch(api frontend.API, h *Hasher, root []frontend.Variable, openings []Opening) {
+	if len(root) != h.NodeSize {
+		panic("merkle: invalid root size")
+	}
+	for _, o := range openings {
+		if len(o.Leaf) != h.NodeSize {
+			panic("merkle: invalid leaf size")
+		}
+		bits := api.ToBinary(o.Index, len(o.Path))
+		node := o.Leaf
+		for d, sibling := range o.Path {
+			if len(sibling) != h.NodeSize {
+				panic("merkle: invalid sibling size")
+			}
+			input := append(append(append([]frontend.Variable{}, node...), sibling...), bits[d])
+			node = call(api, h.level, input)
+		}
+		for i := range root {
+			api.AssertIsEqual(node[i], root[i])
+		}
+	}
+}
+
+// Verify asserts that a single opening is valid for root.
+func Verify(api frontend.API, h *Hasher, root []frontend.Variable, o Opening) {
+	VerifyBatch(api, h, root, []Opening{o})
+}
+
+func call(api frontend.API, f builder.SubCircuitSimpleFunc, input []frontend.Variable) []frontend.Variable {
+	if sub, ok := api.(builder.SubCircuitAPI); ok {
+		return sub.MemorizedSimpleCall(f, input)
+	}
+	return f(api, input)
+}
+
+// Tree is a complete binary tree computed outside of a circuit, to produce openings.
+type Tree struct {
+	// levels[0] holds the leaves and the last level the root
+	levels [][][]uint64
+}
+
+// NewTree builds the tree of the given leaves, whose number must be a power of two.
+func NewTree(h *Hasher, leaves [][]uint64) *Tree {
+	if len(leaves) == 0 || len(leaves)&(len(leaves)-1) != 0 {
+		panic("merkle: the number of leaves must be a power of two")
+	}
+	t := &Tree{levels: [][][]uint64{leaves}}
+	for cur := leaves; len(cur) > 1; {
+		next := make([][]uint64, len(cur)/2)
+		for i := range next {
+			next[i] = h.CompressNative(cur[2*i], cur[2*i+1])
+		}
+		t.levels = append(t.levels, next)
+		cur = next
+	}
+	return t
+}
+
+// Depth returns the number of levels above the leaves.
+func (t *Tree) Depth() int {
+	return len(t.levels) - 1
+}
+
+// Root returns the root of the tree.
+func (t *Tree) Root() []uint64 {
+	return t.levels[len(t.levels)-1][0]
+}
+
+// Path returns the siblings of the leaf at index, from the leaf level up.
+func (t *Tree) Path(index int) [][]uint64 {
+	res := make([][]uint64, t.Depth())
+	for d := range res {
+		res[d] = t.levels[d][index^1]
+		index >>= 1
+	}
+	return res
+}
+
+// Assignment returns the assignment of the opening of the leaf at index.
+func (t *Tree) Assignment(index int) Opening {
+	o := Opening{Leaf: values(t.levels[0][index]), Index: index}
+	for _, s := range t.Path(index) {
+		o.Path = append(o.Path, values(s))
+	}
+	return o
+}
+
+// RootAssignment returns the assignment of the root.
+func (t *Tree) RootAssignment() []frontend.Variable {
+	return values(t.Root())
+}
+
+func values(x []uint64) []frontend.Variable {
+	res := make([]frontend.Variable, len(x))
+	for i := range x {
+		res[i] = x[i]
+	}
+	return res
+}
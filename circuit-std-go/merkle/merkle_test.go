@@ -0,0 +1,116 @@
+package merkle
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/field/m31"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+	"github.com/consensys/gnark/test"
+)
+
+type batchCircuit struct {
+	h        *Hasher
+	Root     []frontend.Variable
+	Openings []Opening
+}
+
+func (c *batchCircuit) Define(api frontend.API) error {
+	VerifyBatch(api, c.h, c.Root, c.Openings)
+	return nil
+}
+
+func newBatchCircuit(h *Hasher, depth, n int) *batchCircuit {
+	c := &batchCircuit{h: h, Root: make([]frontend.Variable, h.NodeSize)}
+	for i := 0; i < n; i++ {
+		c.Openings = append(c.Openings, NewOpening(h, depth))
+	}
+	return c
+}
+
+func testLeaves(h *Hasher, n int) [][]uint64 {
+	leaves := make([][]uint64, n)
+	for i := range leaves {
+		leaves[i] = make([]uint64, h.NodeSize)
+		for j := range leaves[i] {
+			leaves[i][j] = uint64(i*31+j*7+1) % 256
+		}
+	}
+	return leaves
+}
+
+func testVerifyBatch(t *testing.T, h *Hasher, modulus *big.Int) {
+	tree := NewTree(h, testLeaves(h, 8))
+	indices := []int{0, 5, 7}
+	assignment := &batchCircuit{Root: tree.RootAssignment()}
+	for _, i := range indices {
+		assignment.Openings = append(assignment.Openings, tree.Assignment(i))
+	}
+	circuit := newBatchCircuit(h, tree.Depth(), len(indices))
+	if err := test.IsSolved(circuit, assignment, modulus); err != nil {
+		t.Fatal(err)
+	}
+	// a valid opening at the wrong index
+	assignment.Openings[1].Index = 4
+	if err := test.IsSolved(circuit, assignment, modulus); err == nil {
+		t.Fatal("expected an opening at the wrong index to be rejected")
+	}
+	assignment.Openings[1].Index = 5
+	assignment.Openings[1].Leaf[0] = 200
+	if err := test.IsSolved(circuit, assignment, modulus); err == nil {
+		t.Fatal("expected a wrong leaf to be rejected")
+	}
+}
+
+func TestVerifyBatchPoseidon2(t *testing.T) {
+	testVerifyBatch(t, Poseidon2, m31.ScalarField)
+}
+
+func TestVerifyBatchKeccak(t *testing.T) {
+	testVerifyBatch(t, Keccak, ecc.BN254.ScalarField())
+}
+
+func TestLevelsShareSubCircuit(t *testing.T) {
+	root := builder.NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	c := newBatchCircuit(Poseidon2, 4, 3)
+	for i := range c.Root {
+		c.Root[i] = root.SecretVariable(schema.LeafInfo{})
+	}
+	for _, o := range c.Openings {
+		for i := range o.Leaf {
+			o.Leaf[i] = root.SecretVariable(schema.LeafInfo{})
+		}
+		for _, s := range o.Path {
+			for i := range s {
+				s[i] = root.SecretVariable(schema.LeafInfo{})
+			}
+		}
+	}
+	for i := range c.Openings {
+		c.Openings[i].Index = root.SecretVariable(schema.LeafInfo{})
+	}
+	c.Define(root)
+	rc := root.Finalize()
+	// root, level, external round and internal round
+	if len(rc.Circuits) != 4 {
+		t.Fatalf("expected 4 circuits, got %d", len(rc.Circuits))
+	}
+	calls := map[uint64]int{}
+	for _, insn := range rc.Circuits[0].Instructions {
+		if insn.Type == irsource.SubCircuitCall {
+			calls[insn.ExtraId]++
+		}
+	}
+	if len(calls) != 1 {
+		t.Fatalf("expected the root to call a single level circuit, got %v", calls)
+	}
+	for _, n := range calls {
+		if n != 4*3 {
+			t.Fatalf("expected %d level calls, got %d", 4*3, n)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package poseidon2M31
+
+func mulMod(a, b uint64) uint64 { return a * b % modulus }
+
+func sBoxNative(x uint64) uint64 {
+	x2 := mulMod(x, x)
+	return mulMod(mulMod(x2, x2), x)
+}
+
+func externalLinearLayerNative(s *[Width]uint64) {
+	var t [Width]uint64
+	for c := 0; c < Width; c += 4 {
+		for i := 0; i < 4; i++ {
+			t[c+i] = (m4[i][0]*s[c] + m4[i][1]*s[c+1] + m4[i][2]*s[c+2] + m4[i][3]*s[c+3]) % modulus
+		}
+	}
+	for i := 0; i < 4; i++ {
+		sum := (t[i] + t[4+i] + t[8+i] + t[12+i]) % modulus
+		for c := 0; c < Width; c += 4 {
+			s[c+i] = (t[c+i] + sum) % modulus
+		}
+	}
+}
+
+func internalLinearLayerNative(s *[Width]uint64) {
+	var sum uint64
+	for _, x := range s {
+		sum += x
+	}
+	sum %= modulus
+	for i := range s {
+		s[i] = (sum + mulMod(internalDiag[i], s[i])) % modulus
+	}
+}
+
+// PermuteNative computes the permutation outside of a circuit, on canonical M31 elements.
+func PermuteNative(state [Width]uint64) [Width]uint64 {
+	externalLinearLayerNative(&state)
+	external := func(r int) {
+		for i := range state {
+			state[i] = sBoxNative((state[i] + externalConstants[r][i]) % modulus)
+		}
+		externalLinearLayerNative(&state)
+	}
+	for r := 0; r < ExternalRounds/2; r++ {
+		external(r)
+	}
+	for r := 0; r < InternalRounds; r++ {
+		state[0] = sBoxNative((state[0] + internalConstants[r]) % modulus)
+		internalLinearLayerNative(&state)
+	}
+	for r := ExternalRounds / 2; r < ExternalRounds; r++ {
+		external(r)
+	}
+	return state
+}
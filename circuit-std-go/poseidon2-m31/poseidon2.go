@@ -0,0 +1,170 @@
+// Package poseidon2M31 implements the Poseidon2 permutation of width 16 over M31. Each external
+// and internal round is a subcircuit, and the round constants are passed as subcircuit inputs,
+// so a circuit hashing many times, e.g. a Merkle tree, only contains two distinct round circuits.
+//
+// The permutation follows the structure of the Poseidon2 paper: x^5 S-box, 8 external rounds
+// using circ(2M4, M4, M4, M4) and 14 internal rounds using 1*1^T + diag(internalDiag). Round
+// constants are derived from a seed with Keccak-256, like in the poseidon-m31 package.
+package poseidon2M31
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/consensys/gnark/frontend"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/customgates"
+)
+
+const (
+	Width          = 16
+	ExternalRounds = 8
+	InternalRounds = 14
+
+	modulus = (1 << 31) - 1
+)
+
+var (
+	POW_5_GATE_ID     uint64 = 12345
+	POW_5_COST_PSEUDO int    = 20
+
+	externalConstants [ExternalRounds][Width]uint64
+	internalConstants [InternalRounds]uint64
+	internalDiag      = [Width]uint64{modulus - 2, 1, 2, 4, 8, 16, 32, 64, 128, 256, 1024, 4096, 8192, 16384, 32768, 65536}
+	m4                = [4][4]uint64{{5, 7, 1, 3}, {4, 6, 1, 1}, {1, 3, 5, 7}, {1, 1, 4, 6}}
+)
+
+func Power5(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	a := new(big.Int).Mul(inputs[0], inputs[0])
+	a.Mul(a, a)
+	a.Mul(a, inputs[0])
+	outputs[0] = a
+	return nil
+}
+
+func init() {
+	seed := []byte("poseidon2_seed_Mersenne 31_16")
+	hasher := sha3.NewLegacyKeccak256()
+	next := func() uint64 {
+		hasher.Reset()
+		hasher.Write(seed)
+		seed = hasher.Sum(nil)
+		return uint64(binary.LittleEndian.Uint32(seed[:4])) % modulus
+	}
+	for i := range externalConstants {
+		for j := range externalConstants[i] {
+			externalConstants[i][j] = next()
+		}
+	}
+	for i := range internalConstants {
+		internalConstants[i] = next()
+	}
+	customgates.Register(POW_5_GATE_ID, Power5, POW_5_COST_PSEUDO)
+}
+
+type customGateAPI interface {
+	CustomGate(gateType uint64, inputs ...frontend.Variable) frontend.Variable
+}
+
+func sBox(api frontend.API, x frontend.Variable) frontend.Variable {
+	if c, ok := api.(customGateAPI); ok {
+		return c.CustomGate(POW_5_GATE_ID, x)
+	}
+	x2 := api.Mul(x, x)
+	return api.Mul(x2, x2, x)
+}
+
+func call(api frontend.API, f builder.SubCircuitSimpleFunc, input []frontend.Variable) []frontend.Variable {
+	if sub, ok := api.(builder.SubCircuitAPI); ok {
+		return sub.MemorizedSimpleCall(f, input)
+	}
+	return f(api, input)
+}
+
+func externalLinearLayer(api frontend.API, state []frontend.Variable) []frontend.Variable {
+	// apply M4 to each chunk of 4, then add the sum of the chunks to each of them
+	t := make([]frontend.Variable, Width)
+	for c := 0; c < Width; c += 4 {
+		for i := 0; i < 4; i++ {
+			t[c+i] = api.Add(
+				api.Mul(m4[i][0], state[c]), api.Mul(m4[i][1], state[c+1]),
+				api.Mul(m4[i][2], state[c+2]), api.Mul(m4[i][3], state[c+3]))
+		}
+	}
+	res := make([]frontend.Variable, Width)
+	for i := 0; i < 4; i++ {
+		sum := api.Add(t[i], t[4+i], t[8+i], t[12+i])
+		for c := 0; c < Width; c += 4 {
+			res[c+i] = api.Add(t[c+i], sum)
+		}
+	}
+	return res
+}
+
+func internalLinearLayer(api frontend.API, state []frontend.Variable) []frontend.Variable {
+	sum := api.Add(state[0], state[1], state[2:]...)
+	res := make([]frontend.Variable, Width)
+	for i := range res {
+		res[i] = api.Add(sum, api.Mul(internalDiag[i], state[i]))
+	}
+	return res
+}
+
+// externalRound takes the state followed by the round constants
+func externalRound(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	state := make([]frontend.Variable, Width)
+	for i := range state {
+		state[i] = sBox(api, api.Add(input[i], input[Width+i]))
+	}
+	return externalLinearLayer(api, state)
+}
+
+// internalRound takes the state followed by the round constant
+func internalRound(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	state := append([]frontend.Variable{}, input[:Width]...)
+	state[0] = sBox(api, api.Add(state[0], input[Width]))
+	return internalLinearLayer(api, state)
+}
+
+// Permute applies the Poseidon2 permutation to a state of Width elements.
+func Permute(api frontend.API, state []frontend.Variable) []frontend.Variable {
+	if len(state) != Width {
+		panic("poseidon2: invalid state width")
+	}
+	state = externalLinearLayer(api, state)
+	round := func(r int) {
+		input := append([]frontend.Variable{}, state...)
+		for _, c := range externalConstants[r] {
+			input = append(input, c)
+		}
+		state = call(api, externalRound, input)
+	}
+	for r := 0; r < ExternalRounds/2; r++ {
+		round(r)
+	}
+	for r := 0; r < InternalRounds; r++ {
+		input := append(append([]frontend.Variable{}, state...), internalConstants[r])
+		state = call(api, internalRound, input)
+	}
+	for r := ExternalRounds / 2; r < ExternalRounds; r++ {
+		round(r)
+	}
+	return state
+}
+
+// Compress hashes two nodes of 8 elements into one, as needed for Merkle trees: it permutes
+// their concatenation, adds it back (feed-forward) and truncates the result.
+func Compress(api frontend.API, left, right []frontend.Variable) []frontend.Variable {
+	if len(left) != Width/2 || len(right) != Width/2 {
+		panic("poseidon2: invalid node width")
+	}
+	input := append(append([]frontend.Variable{}, left...), right...)
+	out := Permute(api, input)
+	res := make([]frontend.Variable, Width/2)
+	for i := range res {
+		res[i] = api.Add(out[i], input[i])
+	}
+	return res
+}
@@ -0,0 +1,66 @@
+package poseidon2M31
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/field/m31"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+	"github.com/consensys/gnark/test"
+)
+
+type permutationCircuit struct {
+	State  [Width]frontend.Variable
+	Digest [Width]frontend.Variable
+}
+
+func (c *permutationCircuit) Define(api frontend.API) error {
+	out := Permute(api, c.State[:])
+	for i := range out {
+		api.AssertIsEqual(out[i], c.Digest[i])
+	}
+	return nil
+}
+
+func TestPermute(t *testing.T) {
+	var state [Width]uint64
+	for i := range state {
+		state[i] = uint64(i) * 123456789 % modulus
+	}
+	digest := PermuteNative(state)
+	if digest == state {
+		t.Fatal("permutation should change the state")
+	}
+	assignment := &permutationCircuit{}
+	for i := range state {
+		assignment.State[i] = state[i]
+		assignment.Digest[i] = digest[i]
+	}
+	if err := test.IsSolved(&permutationCircuit{}, assignment, m31.ScalarField); err != nil {
+		t.Fatal(err)
+	}
+	assignment.Digest[3] = (digest[3] + 1) % modulus
+	if err := test.IsSolved(&permutationCircuit{}, assignment, m31.ScalarField); err == nil {
+		t.Fatal("expected a wrong digest to be rejected")
+	}
+}
+
+func TestRoundsAreSubCircuits(t *testing.T) {
+	root := builder.NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	left := make([]frontend.Variable, Width/2)
+	right := make([]frontend.Variable, Width/2)
+	for i := range left {
+		left[i] = root.SecretVariable(schema.LeafInfo{})
+		right[i] = root.SecretVariable(schema.LeafInfo{})
+	}
+	node := Compress(root, left, right)
+	node = Compress(root, node, right)
+	for _, x := range node {
+		root.Output(x)
+	}
+	// root, external round and internal round
+	if n := len(root.Finalize().Circuits); n != 3 {
+		t.Fatalf("expected 3 circuits, got %d", n)
+	}
+}
@@ -0,0 +1,245 @@
+// Package sha256 implements SHA-256 for this compiler. Words are represented by 8 nibbles, and
+// the bitwise operations, rotations and range checks are lookups in a single table instead of
+// per-bit constraints. The table is checked with a LogUp argument, so the gadget must be called
+// from the root circuit. Additions of words must not wrap around the field, so the field must
+// have more than 40 bits, e.g. BN254.
+package sha256
+
+import (
+	"github.com/consensys/gnark/frontend"
+)
+
+// word holds 8 little-endian nibbles
+type word [8]frontend.Variable
+
+var k = [64]uint32{
+	0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1, 0x923f82a4, 0xab1c5ed5,
+	0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3, 0x72be5d74, 0x80deb1fe, 0x9bdc06a7, 0xc19bf174,
+	0xe49b69c1, 0xefbe4786, 0x0fc19dc6, 0x240ca1cc, 0x2de92c6f, 0x4a7484aa, 0x5cb0a9dc, 0x76f988da,
+	0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7, 0xc6e00bf3, 0xd5a79147, 0x06ca6351, 0x14292967,
+	0x27b70a85, 0x2e1b2138, 0x4d2c6dfc, 0x53380d13, 0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85,
+	0xa2bfe8a1, 0xa81a664b, 0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070,
+	0x19a4c116, 0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a, 0x5b9cca4f, 0x682e6ff3,
+	0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208, 0x90befffa, 0xa4506ceb, 0xbef9a3f7, 0xc67178f2,
+}
+
+var initialHash = [8]uint32{
+	0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19,
+}
+
+type hasher struct {
+	api frontend.API
+	t   table
+}
+
+func constWord(x uint32) word {
+	var w word
+	for i := range w {
+		w[i] = (x >> (4 * i)) & 15
+	}
+	return w
+}
+
+// xorAnd returns a^b and a&b
+func (h *hasher) xorAnd(a, b word) (word, word) {
+	inputs := make([]frontend.Variable, 0, 16)
+	for i := range a {
+		inputs = append(inputs, a[i], b[i])
+	}
+	out, err := h.api.Compiler().NewHint(nibbleOpsHint, 16, inputs...)
+	if err != nil {
+		panic(err)
+	}
+	var x, y word
+	for i := range a {
+		x[i], y[i] = out[2*i], out[2*i+1]
+		h.t.Query(0, a[i], b[i], x[i], y[i])
+	}
+	return x, y
+}
+
+func (h *hasher) xor(a, b word) word {
+	x, _ := h.xorAnd(a, b)
+	return x
+}
+
+func (h *hasher) and(a, b word) word {
+	_, y := h.xorAnd(a, b)
+	return y
+}
+
+func (h *hasher) not(a word) word {
+	var res word
+	for i := range a {
+		res[i] = h.api.Sub(15, a[i])
+	}
+	return res
+}
+
+// shift returns the rotation of a right by r bits, or the logical shift if rotate is false
+func (h *hasher) shift(a word, r int, rotate bool) word {
+	q, s := r/4, r%4
+	// index of the j-th nibble of a, if it's not shifted out
+	nibble := func(j int) (int, bool) {
+		if j >= 8 && !rotate {
+			return 0, false
+		}
+		return j % 8, true
+	}
+	var res word
+	if s == 0 {
+		for i := range res {
+			if j, ok := nibble(i + q); ok {
+				res[i] = a[j]
+			} else {
+				res[i] = 0
+			}
+		}
+		return res
+	}
+	inputs := append([]frontend.Variable{s}, a[:]...)
+	out, err := h.api.Compiler().NewHint(splitHint, 16, inputs...)
+	if err != nil {
+		panic(err)
+	}
+	lo := make([]frontend.Variable, 8)
+	hi := make([]frontend.Variable, 8)
+	for i := range a {
+		lo[i], hi[i] = out[2*i], out[2*i+1]
+		h.t.Query(s, a[i], lo[i], hi[i], 0)
+	}
+	for i := range res {
+		// the low 4-s bits come from the high part of nibble i+q, the others from the low part of the next one
+		var low, high frontend.Variable = 0, 0
+		if j, ok := nibble(i + q); ok {
+			low = hi[j]
+		}
+		if j, ok := nibble(i + q + 1); ok {
+			high = lo[j]
+		}
+		res[i] = h.api.Add(low, h.api.Mul(high, 1<<(4-s)))
+	}
+	return res
+}
+
+// add returns the sum of the words modulo 2^32
+func (h *hasher) add(words ...word) word {
+	if len(words) > 15 {
+		panic("sha256: too many words in addition")
+	}
+	var total frontend.Variable = 0
+	for _, w := range words {
+		for i := range w {
+			total = h.api.Add(total, h.api.Mul(w[i], uint64(1)<<(4*i)))
+		}
+	}
+	out, err := h.api.Compiler().NewHint(decomposeHint, 9, 8, total)
+	if err != nil {
+		panic(err)
+	}
+	var res word
+	var recomposed frontend.Variable = 0
+	for i := range res {
+		res[i] = out[i]
+		h.t.Query(0, res[i], 0, res[i], 0)
+		recomposed = h.api.Add(recomposed, h.api.Mul(res[i], uint64(1)<<(4*i)))
+	}
+	h.t.Query(0, out[8], 0, out[8], 0)
+	h.api.AssertIsEqual(h.api.Add(recomposed, h.api.Mul(out[8], uint64(1)<<32)), total)
+	return res
+}
+
+func (h *hasher) compress(state [8]word, block []word) [8]word {
+	w := make([]word, 64)
+	copy(w, block)
+	for i := 16; i < 64; i++ {
+		s0 := h.xor(h.xor(h.shift(w[i-15], 7, true), h.shift(w[i-15], 18, true)), h.shift(w[i-15], 3, false))
+		s1 := h.xor(h.xor(h.shift(w[i-2], 17, true), h.shift(w[i-2], 19, true)), h.shift(w[i-2], 10, false))
+		w[i] = h.add(w[i-16], s0, w[i-7], s1)
+	}
+	a, b, c, d, e, f, g, hh := state[0], state[1], state[2], state[3], state[4], state[5], state[6], state[7]
+	for i := 0; i < 64; i++ {
+		S1 := h.xor(h.xor(h.shift(e, 6, true), h.shift(e, 11, true)), h.shift(e, 25, true))
+		// the two terms have disjoint bits, so their xor is a sum
+		ef := h.and(e, f)
+		neg := h.and(h.not(e), g)
+		var ch word
+		for j := range ch {
+			ch[j] = h.api.Add(ef[j], neg[j])
+		}
+		temp1 := h.add(hh, S1, ch, constWord(k[i]), w[i])
+		S0 := h.xor(h.xor(h.shift(a, 2, true), h.shift(a, 13, true)), h.shift(a, 22, true))
+		// maj(a, b, c) = (a & b) + (c & (a ^ b)), again with disjoint bits
+		axb, ab := h.xorAnd(a, b)
+		cab := h.and(c, axb)
+		var maj word
+		for j := range maj {
+			maj[j] = h.api.Add(ab[j], cab[j])
+		}
+		temp2 := h.add(S0, maj)
+		hh, g, f, e, d, c, b, a = g, f, e, h.add(d, temp1), c, b, a, h.add(temp1, temp2)
+	}
+	next := [8]word{a, b, c, d, e, f, g, hh}
+	for i := range next {
+		next[i] = h.add(state[i], next[i])
+	}
+	return next
+}
+
+// Sum256 returns the 32 bytes of the SHA-256 digest of data, given as bytes which are range checked.
+func Sum256(api frontend.API, data []frontend.Variable) []frontend.Variable {
+	if api.Compiler().FieldBitLen() <= 40 {
+		panic("sha256: the field is too small")
+	}
+	h := &hasher{api: api, t: getTable(api)}
+
+	// split bytes into nibbles, and pad the message
+	nibbles := make([]frontend.Variable, 0, 2*(len(data)+72))
+	for _, x := range data {
+		out, err := api.Compiler().NewHint(decomposeHint, 3, 2, x)
+		if err != nil {
+			panic(err)
+		}
+		h.t.Query(0, out[0], 0, out[0], 0)
+		h.t.Query(0, out[1], 0, out[1], 0)
+		api.AssertIsEqual(out[2], 0)
+		api.AssertIsEqual(api.Add(out[0], api.Mul(out[1], 16)), x)
+		nibbles = append(nibbles, out[0], out[1])
+	}
+	bitLen := uint64(len(data)) * 8
+	padded := []byte{0x80}
+	for (len(data)+len(padded))%64 != 56 {
+		padded = append(padded, 0)
+	}
+	for i := 7; i >= 0; i-- {
+		padded = append(padded, byte(bitLen>>(8*i)))
+	}
+	for _, x := range padded {
+		nibbles = append(nibbles, x&15, x>>4)
+	}
+
+	var state [8]word
+	for i := range state {
+		state[i] = constWord(initialHash[i])
+	}
+	for start := 0; start < len(nibbles); start += 128 {
+		block := make([]word, 16)
+		for i := range block {
+			// big endian bytes, each byte being (low nibble, high nibble)
+			for j := 0; j < 4; j++ {
+				byteIdx := start + 8*i + 2*(3-j)
+				block[i][2*j] = nibbles[byteIdx]
+				block[i][2*j+1] = nibbles[byteIdx+1]
+			}
+		}
+		state = h.compress(state, block)
+	}
+
+	res := make([]frontend.Variable, 0, 32)
+	for _, w := range state {
+		for j := 3; j >= 0; j-- {
+			res = append(res, api.Add(w[2*j], api.Mul(w[2*j+1], 16)))
+		}
+	}
+	return res
+}
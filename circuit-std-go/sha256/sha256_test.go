@@ -0,0 +1,71 @@
+package sha256
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+	"github.com/consensys/gnark/test"
+)
+
+type sha256Circuit struct {
+	Data   []frontend.Variable
+	Digest [32]frontend.Variable
+}
+
+func (c *sha256Circuit) Define(api frontend.API) error {
+	res := Sum256(api, c.Data)
+	for i := range res {
+		api.AssertIsEqual(res[i], c.Digest[i])
+	}
+	return nil
+}
+
+func TestSum256(t *testing.T) {
+	for _, n := range []int{0, 3, 55, 56, 64, 100} {
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = byte(i*13 + 5)
+		}
+		digest := sha256.Sum256(data)
+		assignment := &sha256Circuit{Data: make([]frontend.Variable, n)}
+		for i := range data {
+			assignment.Data[i] = data[i]
+		}
+		for i := range digest {
+			assignment.Digest[i] = digest[i]
+		}
+		circuit := &sha256Circuit{Data: make([]frontend.Variable, n)}
+		err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField(), test.SetAllVariablesAsConstants())
+		if err != nil {
+			t.Fatalf("length %d: %v", n, err)
+		}
+		assignment.Digest[0] = (int(digest[0]) + 1) % 256
+		if test.IsSolved(circuit, assignment, ecc.BN254.ScalarField(), test.SetAllVariablesAsConstants()) == nil {
+			t.Fatalf("length %d: expected a wrong digest to be rejected", n)
+		}
+	}
+}
+
+func TestSum256WithBuilder(t *testing.T) {
+	root := builder.NewRoot(ecc.BN254.ScalarField(), frontend.CompileConfig{})
+	data := []frontend.Variable{root.SecretVariable(schema.LeafInfo{}), 7}
+	for _, x := range Sum256(root, data) {
+		root.Output(x)
+	}
+	Sum256(root, data)
+	nbRandom := 0
+	for _, in := range root.Finalize().Circuits[0].Instructions {
+		if in.Type == irsource.ConstantLike && in.ExtraId == 1 {
+			nbRandom++
+		}
+	}
+	// both hashes share a single multi-column table
+	if nbRandom != 2 {
+		t.Fatalf("expected 2 random values, got %d", nbRandom)
+	}
+}
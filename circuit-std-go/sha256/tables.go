@@ -0,0 +1,121 @@
+package sha256
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+)
+
+// The lookup table has rows of 5 columns. Rows (0, x, y, x^y, x&y) for nibbles x and y give
+// bitwise operations and range checks, and rows (s, n, lo, hi, 0) for s in 1..3 split a nibble n
+// into its s low bits and 4-s high bits.
+const tableWidth = 5
+
+func init() {
+	solver.RegisterHint(nibbleOpsHint, splitHint, decomposeHint)
+}
+
+// nibbleOpsHint computes x^y and x&y for pairs of nibbles
+func nibbleOpsHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	for i := 0; i+1 < len(inputs); i += 2 {
+		x, y := inputs[i].Uint64(), inputs[i+1].Uint64()
+		outputs[i].SetUint64(x ^ y)
+		outputs[i+1].SetUint64(x & y)
+	}
+	return nil
+}
+
+// splitHint splits the nibbles inputs[1:] into their inputs[0] low bits and the remaining bits
+func splitHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	s := inputs[0].Uint64()
+	for i, x := range inputs[1:] {
+		n := x.Uint64()
+		outputs[2*i].SetUint64(n & (1<<s - 1))
+		outputs[2*i+1].SetUint64(n >> s)
+	}
+	return nil
+}
+
+// decomposeHint decomposes inputs[1] into inputs[0] nibbles, the last output being the remainder
+func decomposeHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	n := int(inputs[0].Int64())
+	x := new(big.Int).Set(inputs[1])
+	for i := 0; i < n; i++ {
+		outputs[i].SetUint64(x.Uint64() & 15)
+		x.Rsh(x, 4)
+	}
+	outputs[n].Set(x)
+	return nil
+}
+
+type table interface {
+	Query(row ...frontend.Variable)
+}
+
+type tableKey struct{}
+
+type keyValueStore interface {
+	SetKeyValue(key, value any)
+	GetKeyValue(key any) any
+}
+
+func allRows() [][]frontend.Variable {
+	rows := [][]frontend.Variable{}
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			rows = append(rows, []frontend.Variable{0, x, y, x ^ y, x & y})
+		}
+	}
+	for s := 1; s < 4; s++ {
+		for n := 0; n < 16; n++ {
+			rows = append(rows, []frontend.Variable{s, n, n & (1<<s - 1), n >> s, 0})
+		}
+	}
+	return rows
+}
+
+// getTable returns the lookup table of the circuit, creating it on first use. With the ecgo
+// builder it's a LogUp table, shared by all hashes of the circuit. With other APIs whose
+// variables have known values, like gnark's test engine, the rows are checked directly.
+func getTable(api frontend.API) table {
+	if b, ok := api.(builder.API); ok {
+		kv := api.(keyValueStore)
+		if t, ok := kv.GetKeyValue(tableKey{}).(table); ok {
+			return t
+		}
+		t := b.NewTable(tableWidth)
+		for _, row := range allRows() {
+			t.Insert(row...)
+		}
+		kv.SetKeyValue(tableKey{}, t)
+		return t
+	}
+	rows := make(map[string]bool)
+	for _, row := range allRows() {
+		rows[fmt.Sprint(row)] = true
+	}
+	return &checkedTable{api: api, rows: rows}
+}
+
+type checkedTable struct {
+	api  frontend.API
+	rows map[string]bool
+}
+
+func (t *checkedTable) Query(row ...frontend.Variable) {
+	values := make([]frontend.Variable, len(row))
+	for i, x := range row {
+		v, ok := t.api.Compiler().ConstantValue(x)
+		if !ok {
+			panic("sha256: lookups require the ecgo builder, or a test engine with constant variables")
+		}
+		values[i] = int(v.Int64())
+	}
+	if !t.rows[fmt.Sprint(values)] {
+		t.api.AssertIsEqual(1, 0)
+	}
+}
@@ -0,0 +1,150 @@
+// Package sort sorts rows of variables in circuits, or asserts that they're sorted, with a
+// configurable comparator.
+//
+// Sort is a Batcher odd-even merge sorting network. Each comparator is a call to a memoized
+// compare-exchange subcircuit, and the comparators of a stage are independent, so n rows are
+// sorted in O(log^2 n) stages of n/2 parallel calls: the depth stays polylogarithmic instead of
+// the linear depth of insertion-like hand-rolled sorts. Checking that rows are sorted is much
+// cheaper than sorting them: AssertSorted is a single comparison per pair of consecutive rows.
+package sort
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/rangecheck"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+)
+
+func init() {
+	solver.RegisterHint(lessHint)
+}
+
+// Comparator orders rows. It's a parameter of the compare-exchange subcircuit (see
+// builder.MemorizedCallN), so it must not hold variables.
+type Comparator interface {
+	// IsLess returns 1 if row a must be sorted before row b, and 0 otherwise.
+	IsLess(api frontend.API, a, b []frontend.Variable) frontend.Variable
+}
+
+// Unsigned compares rows by their column Column, an unsigned integer of Bits bits. Values which
+// don't fit in Bits bits make the circuit unsatisfiable. 2^Bits must be less than half of the
+// modulus.
+type Unsigned struct {
+	Bits   int
+	Column int
+}
+
+// IsLess implements Comparator.
+func (u Unsigned) IsLess(api frontend.API, a, b []frontend.Variable) frontend.Variable {
+	x, y := a[u.Column], b[u.Column]
+	res, err := api.Compiler().NewHint(lessHint, 1, x, y)
+	if err != nil {
+		panic(err)
+	}
+	lt := res[0]
+	api.AssertIsBoolean(lt)
+	// y - x - 1 fits in Bits bits if x < y, and x - y otherwise
+	d := api.Add(api.Mul(lt, api.Sub(api.Mul(2, api.Sub(y, x)), 1)), api.Sub(x, y))
+	rangecheck.New(api).Check(d, u.Bits)
+	return lt
+}
+
+// lessHint returns 1 if inputs[0] < inputs[1]
+func lessHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	if inputs[0].Cmp(inputs[1]) < 0 {
+		outputs[0].SetInt64(1)
+	} else {
+		outputs[0].SetInt64(0)
+	}
+	return nil
+}
+
+// pair is the input and the output of the compare-exchange subcircuit
+type pair struct {
+	A []frontend.Variable
+	B []frontend.Variable
+}
+
+// compareExchange returns the rows of p in the order of c, a first if they're equal
+func compareExchange[C Comparator](api frontend.API, c C, p pair) pair {
+	swap := c.IsLess(api, p.B, p.A)
+	res := pair{A: make([]frontend.Variable, len(p.A)), B: make([]frontend.Variable, len(p.B))}
+	for i := range p.A {
+		// A + swap * (B - A), and A + B - that
+		d := api.Mul(swap, api.Sub(p.B[i], p.A[i]))
+		res.A[i] = api.Add(p.A[i], d)
+		res.B[i] = api.Sub(p.B[i], d)
+	}
+	return res
+}
+
+// Network returns the comparators of the Batcher odd-even merge sorting network of n elements,
+// by stage. The comparators of a stage are independent, and each comparator (i, j) with i < j
+// puts the smallest element in i.
+func Network(n int) [][][2]int {
+	var stages [][][2]int
+	for p := 1; p < n; p <<= 1 {
+		for k := p; k >= 1; k >>= 1 {
+			var stage [][2]int
+			for j := k % p; j+k < n; j += 2 * k {
+				for i := 0; i < k && i+j+k < n; i++ {
+					if (i+j)/(2*p) == (i+j+k)/(2*p) {
+						stage = append(stage, [2]int{i + j, i + j + k})
+					}
+				}
+			}
+			if len(stage) > 0 {
+				stages = append(stages, stage)
+			}
+		}
+	}
+	return stages
+}
+
+// Sort returns the rows sorted by c. Rows must all have the same number of columns. The sort isn't
+// stable: rows which c doesn't order may be output in any order.
+func Sort[C Comparator](api frontend.API, rows [][]frontend.Variable, c C) [][]frontend.Variable {
+	checkRows(rows)
+	res := append([][]frontend.Variable{}, rows...)
+	for _, stage := range Network(len(rows)) {
+		for _, cmp := range stage {
+			i, j := cmp[0], cmp[1]
+			p := builder.MemorizedCallN(api, compareExchange[C], c, pair{A: res[i], B: res[j]})
+			res[i], res[j] = p.A, p.B
+		}
+	}
+	return res
+}
+
+// SortValues sorts single values, see Sort.
+func SortValues[C Comparator](api frontend.API, values []frontend.Variable, c C) []frontend.Variable {
+	rows := make([][]frontend.Variable, len(values))
+	for i := range values {
+		rows[i] = []frontend.Variable{values[i]}
+	}
+	res := make([]frontend.Variable, len(values))
+	for i, row := range Sort(api, rows, c) {
+		res[i] = row[0]
+	}
+	return res
+}
+
+// AssertSorted asserts that no row must be sorted before the previous one.
+func AssertSorted[C Comparator](api frontend.API, rows [][]frontend.Variable, c C) {
+	checkRows(rows)
+	for i := 1; i < len(rows); i++ {
+		api.AssertIsEqual(c.IsLess(api, rows[i], rows[i-1]), 0)
+	}
+}
+
+func checkRows(rows [][]frontend.Variable) {
+	for _, row := range rows {
+		if len(row) != len(rows[0]) {
+			panic(fmt.Sprintf("sort: rows of %d and %d columns", len(rows[0]), len(row)))
+		}
+	}
+}
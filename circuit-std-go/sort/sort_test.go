@@ -0,0 +1,91 @@
+package sort
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/field/m31"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+	"github.com/consensys/gnark/test"
+)
+
+func TestNetworkSorts(t *testing.T) {
+	// by the 0-1 principle, a network sorting all sequences of 0 and 1 sorts everything
+	for n := 0; n <= 12; n++ {
+		network := Network(n)
+		for x := 0; x < 1<<n; x++ {
+			v := make([]int, n)
+			for i := range v {
+				v[i] = x >> i & 1
+			}
+			for _, stage := range network {
+				used := make(map[int]bool)
+				for _, c := range stage {
+					if used[c[0]] || used[c[1]] {
+						t.Fatalf("n=%d: comparators of a stage share an element", n)
+					}
+					used[c[0]], used[c[1]] = true, true
+					if v[c[0]] > v[c[1]] {
+						v[c[0]], v[c[1]] = v[c[1]], v[c[0]]
+					}
+				}
+			}
+			for i := 1; i < n; i++ {
+				if v[i-1] > v[i] {
+					t.Fatalf("n=%d: %b isn't sorted", n, x)
+				}
+			}
+		}
+	}
+}
+
+type sortCircuit struct {
+	In     [7][2]frontend.Variable
+	Sorted [7][2]frontend.Variable `gnark:",public"`
+}
+
+func (c *sortCircuit) Define(api frontend.API) error {
+	rows := make([][]frontend.Variable, len(c.In))
+	expected := make([][]frontend.Variable, len(c.In))
+	for i := range rows {
+		rows[i], expected[i] = c.In[i][:], c.Sorted[i][:]
+	}
+	cmp := Unsigned{Bits: 16}
+	for i, row := range Sort(api, rows, cmp) {
+		api.AssertIsEqual(row[0], c.Sorted[i][0])
+		api.AssertIsEqual(row[1], c.Sorted[i][1])
+	}
+	AssertSorted(api, expected, cmp)
+	return nil
+}
+
+func TestSort(t *testing.T) {
+	in := [7][2]frontend.Variable{{5, 0}, {3, 1}, {9, 2}, {4, 3}, {0, 4}, {65535, 5}, {7, 6}}
+	sorted := [7][2]frontend.Variable{{0, 4}, {3, 1}, {4, 3}, {5, 0}, {7, 6}, {9, 2}, {65535, 5}}
+	if err := test.IsSolved(&sortCircuit{}, &sortCircuit{In: in, Sorted: sorted}, m31.ScalarField); err != nil {
+		t.Fatal(err)
+	}
+	sorted[0], sorted[1] = sorted[1], sorted[0]
+	if err := test.IsSolved(&sortCircuit{}, &sortCircuit{In: in, Sorted: sorted}, m31.ScalarField); err == nil {
+		t.Fatal("expected unsorted rows to be rejected")
+	}
+	in[5][0] = 65536
+	if err := test.IsSolved(&sortCircuit{}, &sortCircuit{In: in, Sorted: sorted}, m31.ScalarField); err == nil {
+		t.Fatal("expected a key wider than 16 bits to be rejected")
+	}
+}
+
+func TestSortSharesSubCircuit(t *testing.T) {
+	root := builder.NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	values := make([]frontend.Variable, 16)
+	for i := range values {
+		values[i] = root.SecretVariable(schema.LeafInfo{})
+	}
+	for _, x := range SortValues(root, values, Unsigned{Bits: 8}) {
+		root.Output(x)
+	}
+	if n := len(root.Finalize().Circuits); n != 2 {
+		t.Fatalf("expected a single compare-exchange circuit, got %d circuits", n)
+	}
+}
@@ -0,0 +1,18 @@
+// Command ecc compiles the circuits of the registry package, writes the layered circuit and the
+// input solver, and solves witnesses, see package cli. Circuits are loaded from Go plugins:
+//
+//	go build -buildmode=plugin -o mycircuit.so ./mycircuit
+//	ecc compile -plugin mycircuit.so -circuit mycircuit -out build
+//	ecc solve -plugin mycircuit.so -circuit mycircuit -inputsolver build/inputsolver.txt -assignment assignment.json
+//	ecc stats -layered build/circuit.txt
+package main
+
+import (
+	"os"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/cli"
+)
+
+func main() {
+	os.Exit(cli.Main(os.Args[1:], os.Stdout, os.Stderr))
+}
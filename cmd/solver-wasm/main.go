@@ -0,0 +1,108 @@
+//go:build js && wasm
+
+// Command solver-wasm is the witness solver built to WebAssembly, so that browsers can solve
+// witnesses locally with the input solver written by ecc compile, and only send the witness to a
+// proving service. The compiler isn't included, since it needs the Rust library.
+//
+//	GOOS=js GOARCH=wasm go build -o solver.wasm ./cmd/solver-wasm
+//	cp "$(go env GOROOT)/misc/wasm/wasm_exec.js" .
+//
+// The JavaScript support file is in lib/wasm instead of misc/wasm since Go 1.24.
+//
+// Once the module runs, it defines a global ecgoLoadSolver function, which deserializes an input
+// solver from a Uint8Array and returns an object whose solve method takes the values of the secret
+// and the public variables of an assignment, each as an array of decimal or 0x-prefixed strings in
+// declaration order, and returns the serialized witness as a Uint8Array:
+//
+//	const solver = ecgoLoadSolver(new Uint8Array(await (await fetch("inputsolver.txt")).arrayBuffer()));
+//	const { witness, error } = solver.solve(["3", "5"], ["0x7"]);
+//
+// Errors are returned in the error field instead of the result. Hints are called in the browser,
+// so circuits with custom hints need a copy of this command registering them.
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"syscall/js"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+)
+
+func main() {
+	js.Global().Set("ecgoLoadSolver", js.FuncOf(func(this js.Value, args []js.Value) any {
+		return guard(func() (map[string]any, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("expected the input solver, got %d arguments", len(args))
+			}
+			rc := irwg.DeserializeRootCircuit(bytesOf(args[0]))
+			return map[string]any{
+				"numPublicInputs": rc.NumPublicInputs,
+				"solve": js.FuncOf(func(this js.Value, args []js.Value) any {
+					return guard(func() (map[string]any, error) {
+						return solve(rc, args)
+					})
+				}),
+			}, nil
+		})
+	}))
+	// keep the module alive, so that the functions can be called
+	select {}
+}
+
+func solve(rc *irwg.RootCircuit, args []js.Value) (map[string]any, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("expected the secret and the public inputs, got %d arguments", len(args))
+	}
+	secret, err := integers(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("secret inputs: %w", err)
+	}
+	public, err := integers(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("public inputs: %w", err)
+	}
+	w, err := rc.SolveInputValues(secret, public)
+	if err != nil {
+		return nil, err
+	}
+	buf := w.Serialize()
+	res := js.Global().Get("Uint8Array").New(len(buf))
+	js.CopyBytesToJS(res, buf)
+	return map[string]any{"witness": res}, nil
+}
+
+// guard returns the result of f, or an object holding its error, also when it panics, e.g. on a
+// malformed input solver
+func guard(f func() (map[string]any, error)) (res any) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = map[string]any{"error": fmt.Sprint(r)}
+		}
+	}()
+	m, err := f()
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	return m
+}
+
+func bytesOf(v js.Value) []byte {
+	buf := make([]byte, v.Get("length").Int())
+	js.CopyBytesToGo(buf, v)
+	return buf
+}
+
+// integers parses an array of strings in any base accepted by big.Int.SetString with base 0
+func integers(v js.Value) ([]*big.Int, error) {
+	res := make([]*big.Int, v.Get("length").Int())
+	for i := range res {
+		s := v.Index(i).String()
+		x, ok := new(big.Int).SetString(s, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q at %d", s, i)
+		}
+		res[i] = x
+	}
+	return res, nil
+}
@@ -3,16 +3,26 @@
 package ecgo
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"math/big"
+	"os"
+	"path/filepath"
 	"reflect"
+	"runtime/debug"
+	"sync"
 
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/passes"
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/rust"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/customgates"
+	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/schema"
 	"github.com/consensys/gnark/logger"
@@ -33,23 +43,116 @@ type CompileResult struct {
 	irs  *irsource.RootCircuit
 	irwg *irwg.RootCircuit
 	lc   *layered.RootCircuit
+
+	// file containing the serialized layered circuit in low memory mode, from which lc is loaded
+	// when needed, and whether it's a temporary file removed by Close
+	lcFile     string
+	ownsLcFile bool
+	// guards the loading of lc from lcFile
+	lcMu sync.Mutex
+
+	// names of the public inputs, by slot
+	publicLayout []string
+
+	// named outputs, see Tags
+	tags []builder.OutputTag
+
+	// features which can't be detected from the gates, see Features
+	features layered.Features
+
+	// see SkippedBooleanAssertions
+	skippedBooleans int
+
+	// variables of the circuit, see Schema
+	schema *schema.Schema
+
+	circuitHash [32]byte
+
+	// number of copies of the circuit in the layered circuit, see CompileBatch
+	batch int
+
+	// constraint system compiled by gnark, see WithPlonk
+	plonk constraint.SparseR1CS
 }
 
 // Compile is similar to gnark's frontend.Compile. It compiles the given circuit and returns
 // a pointer to CompileResult along with any error encountered during the compilation process.
 func Compile(field *big.Int, circuit frontend.Circuit, opts ...frontend.CompileOption) (*CompileResult, error) {
+	return CompileContext(context.Background(), field, circuit, opts...)
+}
+
+// CompileContext is like Compile, but stops once ctx is done and returns its error. The context
+// is checked periodically while the circuit is defined and between the phases of the compilation,
+// but the layering itself is not interrupted.
+func CompileContext(ctx context.Context, field *big.Int, circuit frontend.Circuit, opts ...frontend.CompileOption) (*CompileResult, error) {
 	log := logger.Logger()
 	log.Info().Msg("compiling circuit")
 
-	opt := frontend.CompileConfig{CompressThreshold: 0}
-	for _, o := range opts {
-		if err := o(&opt); err != nil {
-			log.Err(err).Msg("applying compile option")
-			return nil, fmt.Errorf("apply option: %w", err)
+	opt, config, err := applyOptions(opts)
+	if err != nil {
+		log.Err(err).Msg("applying compile option")
+		return nil, err
+	}
+	return compileConfigured(ctx, field, circuit, opt, config)
+}
+
+// compileConfigured compiles circuit like CompileContext, with the options already applied.
+func compileConfigured(ctx context.Context, fieldOrder *big.Int, circuit frontend.Circuit, opt frontend.CompileConfig, config *compileConfig) (res *CompileResult, err error) {
+	if err := field.CheckCompilable(fieldOrder); err != nil {
+		return nil, err
+	}
+	// the schema is read before the variables of the circuit are set
+	s, err := circuitSchema(circuit)
+	if err != nil {
+		return nil, err
+	}
+	p := &progress{ctx: ctx, f: config.progress}
+	if config.trace != nil {
+		p.trace = newTracer(config.trace)
+		defer func() { p.trace.end(err) }()
+	}
+	if config.snapshotDir != "" {
+		res, err = compileWithSnapshots(fieldOrder, circuit, opt, config, p)
+	} else {
+		var root *builder.Root
+		var layout []string
+		var publicOrder []int
+		root, layout, publicOrder, err = defineRoot(fieldOrder, circuit, opt, config, p)
+		if err != nil {
+			return nil, err
+		}
+		res, err = compileRoot(root, config, p, layout, publicOrder)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.schema = s
+	if config.plonk {
+		if res.plonk, err = compilePlonk(fieldOrder, circuit); err != nil {
+			return nil, fmt.Errorf("gnark SparseR1CS: %w", err)
 		}
 	}
+	return res, nil
+}
 
-	root := builder.NewRoot(field, opt)
+// defineRoot defines circuit with a new root builder, or the one forked by CompileVariants, and
+// returns it with the layout of the public inputs, see publicInputOrder.
+func defineRoot(field *big.Int, circuit frontend.Circuit, opt frontend.CompileConfig, config *compileConfig, p *progress) (*builder.Root, []string, []int, error) {
+	if err := p.report("define", 0); err != nil {
+		return nil, nil, nil, err
+	}
+	root := config.fork
+	if root == nil {
+		var err error
+		if root, err = newRoot(field, opt); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	root.SetSourceLocationDepth(config.locationDepth)
+	root.SetDebugPrints(!config.noDebugPrints)
+	root.SetGrowth(config.growth)
+	root.SetHooks(config.hooks)
+	root.SetProgress(p.building)
 	schema.Walk(circuit, irwg.TVariable, func(f schema.LeafInfo, tInput reflect.Value) error {
 		if tInput.CanSet() {
 			if f.Visibility == schema.Unset {
@@ -62,31 +165,352 @@ func Compile(field *big.Int, circuit frontend.Circuit, opts ...frontend.CompileO
 		}
 		return errors.New("can't set val " + f.FullName())
 	})
+	publicLeaves := []schema.LeafInfo{}
+	publicInputs := []reflect.Value{}
+	publicNames := []string{}
 	schema.Walk(circuit, irwg.TVariable, func(f schema.LeafInfo, tInput reflect.Value) error {
 		if tInput.CanSet() {
 			if f.Visibility == schema.Unset {
 				return errors.New("can't set val " + f.FullName() + " visibility is unset")
 			}
 			if f.Visibility == schema.Public {
-				tInput.Set(reflect.ValueOf(root.PublicVariable(f)))
+				publicLeaves = append(publicLeaves, f)
+				publicInputs = append(publicInputs, tInput)
+				publicNames = append(publicNames, f.FullName())
 			}
 			return nil
 		}
 		return errors.New("can't set val " + f.FullName())
 	})
-
-	err := circuit.Define(root)
+	slots, err := config.publicLayout.order(publicNames)
 	if err != nil {
+		return nil, nil, nil, fmt.Errorf("public input layout: %w", err)
+	}
+	for i, slot := range slots {
+		publicInputs[i].Set(reflect.ValueOf(root.PublicVariableAt(publicLeaves[i], slot)))
+	}
+	publicOrder, layout := publicInputOrder(publicNames, slots)
+
+	if err := define(circuit, root); err != nil {
+		return nil, nil, nil, err
+	}
+	if config.defined != nil {
+		config.defined(root)
+	}
+	return root, layout, publicOrder, nil
+}
+
+// optimize runs the pipeline of config on rc.
+func optimize(rc *irsource.RootCircuit, config *compileConfig, p *progress) error {
+	log := logger.Logger()
+	for _, pass := range config.passes() {
+		if err := p.report(pass.Name(), numInstructions(rc)); err != nil {
+			return err
+		}
+		n := pass.Run(rc, passes.WithWorkers(config.workers))
+		p.ran(n)
+		log.Info().Str("pass", pass.Name()).Int("nbChanges", n).Msg("ran optimization pass")
+	}
+	return nil
+}
+
+// compileRoot compiles the circuit defined with root, whose public inputs are laid out by layout
+// and publicOrder, see publicInputLayout.
+func compileRoot(root *builder.Root, config *compileConfig, p *progress, layout []string, publicOrder []int) (*CompileResult, error) {
+	if err := p.report("finalize", 0); err != nil {
 		return nil, err
 	}
 	rc := root.Finalize()
-	_ = rc
+	root.ResetArena()
+	if err := optimize(rc, config, p); err != nil {
+		return nil, err
+	}
 	//os.WriteFile("p1.txt", irsource.SerializeRootCircuit(rc), 0644)
-	irwg, lc, err := rust.Compile(rc)
+	if err := config.limits.checkEstimate(rc); err != nil {
+		return nil, err
+	}
+	if err := p.report("layering", numInstructions(rc)); err != nil {
+		return nil, err
+	}
+	res, err := layer(rc, config)
+	if err != nil {
+		return nil, err
+	}
+	return finishCompile(res, root.Tags(), rootFeatures(root), root.SkippedBooleanAssertions(), config, p, layout, publicOrder)
+}
+
+// layer compiles the optimized IR rc to a layered circuit with the Rust compiler.
+func layer(rc *irsource.RootCircuit, config *compileConfig) (*CompileResult, error) {
+	switch {
+	case config.cacheDir != "":
+		return compileCached(rc, config.cacheDir, config.rust, config.lowMemory)
+	case config.lowMemory:
+		return compileLowMemory(rc, config.rust, config.spillDir)
+	default:
+		return compile(rc, config.rust)
+	}
+}
+
+// rootFeatures returns the features of the circuit defined with root which can't be detected
+// from the gates of the layered circuit
+func rootFeatures(root *builder.Root) layered.Features {
+	if root.HasLookupTables() {
+		return layered.FeatureLookups
+	}
+	return 0
+}
+
+// finishCompile applies the steps following the layering to res, whose root circuit has the
+// given tags and features, see rootFeatures, and public inputs laid out by layout and publicOrder.
+func finishCompile(res *CompileResult, tags []builder.OutputTag, features layered.Features, skippedBooleans int, config *compileConfig, p *progress, layout []string, publicOrder []int) (*CompileResult, error) {
+	log := logger.Logger()
+	res.publicLayout = layout
+	res.tags = tags
+	res.features = features
+	res.skippedBooleans = skippedBooleans
+	// the number of gates is only computed if it's reported
+	gates := func() int {
+		if !p.reported() {
+			return 0
+		}
+		return int(res.Stats().TotalGates())
+	}
+	if config.relays != layered.KeepRelays {
+		lc, err := res.LayeredCircuit()
+		if err != nil {
+			return nil, err
+		}
+		rerouted := lc.Reroute(config.relays)
+		if err := res.setLayeredCircuit(rerouted); err != nil {
+			return nil, err
+		}
+		log.Info().Uint64("before", lc.RelayStats().RelayGates).Uint64("after", rerouted.RelayStats().RelayGates).Msg("rerouted relay gates")
+	}
+	if config.outputClaims > 0 {
+		lc, err := res.LayeredCircuit()
+		if err != nil {
+			return nil, err
+		}
+		if err := res.setLayeredCircuit(lc.AggregateOutputs(config.outputClaims)); err != nil {
+			return nil, err
+		}
+		log.Info().Int("claims", config.outputClaims).Msg("aggregated outputs")
+	}
+	if !config.padding.IsDefault() {
+		if err := p.report("padding", gates()); err != nil {
+			return nil, err
+		}
+		lc, err := res.LayeredCircuit()
+		if err != nil {
+			return nil, err
+		}
+		if err := res.setLayeredCircuit(lc.Pad(config.padding)); err != nil {
+			return nil, err
+		}
+		log.Info().Msg("padded layers")
+	}
+	if err := config.limits.check(res); err != nil {
+		return nil, err
+	}
+	if config.profilePath != "" {
+		if err := p.report("profile", gates()); err != nil {
+			return nil, err
+		}
+		if err := config.writeProfile(res); err != nil {
+			return nil, err
+		}
+		log.Info().Str("path", config.profilePath).Msg("wrote circuit profile")
+	}
+	res.irwg.CircuitHash = res.circuitHash[:]
+	if !isIdentity(publicOrder) {
+		res.irwg.PublicInputOrder = publicOrder
+	}
+	p.done(gates())
+	res.releaseLayeredCircuit()
+	return res, nil
+}
+
+// CompileBatch is like Compile, but lays out n independent copies of the circuit side by side in
+// the layered circuit, for the data-parallel proving of Expander, see layered.RootCircuit.Replicate.
+// The copies share the structure of the circuit. The input solver still solves the inputs of a
+// single copy: ReplicateInputs lays out the witnesses of n assignments as the inputs of the copies.
+// The public inputs of copy k are named "k/Name" in PublicInputLayout, and its tags "k/Name" in Tags.
+func CompileBatch(field *big.Int, circuit frontend.Circuit, n int, opts ...frontend.CompileOption) (*CompileResult, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("the number of copies must be positive, got %d", n)
+	}
+	// the outputs are aggregated once the copies are laid out
+	claims := 0
+	opts = append(opts[:len(opts):len(opts)], ecgoOption(func(c *compileConfig) {
+		claims, c.outputClaims = c.outputClaims, 0
+	}))
+	res, err := Compile(field, circuit, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return &CompileResult{irs: rc, irwg: irwg, lc: lc}, nil
+	lc := res.GetLayeredCircuit()
+	last := lc.Circuits[lc.Layers[len(lc.Layers)-1]]
+	// outputs of each copy past the ones expected to be zero
+	stride := int(last.OutputLen) - lc.ExpectedNumOutputZeroes
+	replicated := lc.Replicate(n)
+	if claims > 0 {
+		replicated = replicated.AggregateOutputs(claims)
+	}
+	if err := res.setLayeredCircuit(replicated); err != nil {
+		return nil, err
+	}
+	// witnesses of a single copy are not witnesses of the layered circuit
+	res.irwg.CircuitHash = nil
+	layout := make([]string, 0, n*len(res.publicLayout))
+	for k := 0; k < n; k++ {
+		for _, name := range res.publicLayout {
+			layout = append(layout, fmt.Sprintf("%d/%s", k, name))
+		}
+	}
+	res.publicLayout = layout
+	tags := make([]builder.OutputTag, 0, n*len(res.tags))
+	for k := 0; k < n; k++ {
+		for _, t := range res.tags {
+			tags = append(tags, builder.OutputTag{Name: fmt.Sprintf("%d/%s", k, t.Name), Output: k*stride + t.Output})
+		}
+	}
+	res.tags = tags
+	res.batch = n
+	return res, nil
+}
+
+// setLayeredCircuit replaces the layered circuit of c by lc. In low memory mode, lc is written to
+// a new temporary file, since the current one may be an entry of the compile cache, which
+// replaces the current one if it's temporary too.
+func (c *CompileResult) setLayeredCircuit(lc *layered.RootCircuit) error {
+	if c.lcFile != "" {
+		f, err := os.CreateTemp(filepath.Dir(c.lcFile), "circuit-*.txt")
+		if err != nil {
+			return fmt.Errorf("create layered circuit file: %w", err)
+		}
+		_, err = f.Write(lc.Serialize())
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return fmt.Errorf("write layered circuit file: %w", err)
+		}
+		if c.ownsLcFile {
+			os.Remove(c.lcFile)
+		}
+		c.lcFile, c.ownsLcFile, c.lc = f.Name(), true, nil
+	} else {
+		c.lc = lc
+	}
+	c.circuitHash = lc.ContentHash()
+	return nil
+}
+
+// applyOptions applies gnark and ecgo compile options.
+func applyOptions(opts []frontend.CompileOption) (frontend.CompileConfig, *compileConfig, error) {
+	opt := frontend.CompileConfig{CompressThreshold: 0}
+	config := defaultCompileConfig()
+	compileConfigs.Store(&opt, config)
+	defer compileConfigs.Delete(&opt)
+	for _, o := range opts {
+		if err := o(&opt); err != nil {
+			return opt, nil, fmt.Errorf("apply option: %w", err)
+		}
+	}
+	return opt, config, nil
+}
+
+// define calls circuit.Define, turning the panics of the builder caused by recursive
+// subcircuits into errors.
+func define(circuit frontend.Circuit, root *builder.Root) (err error) {
+	defer recoverUsageError(&err)
+	return circuit.Define(root)
+}
+
+// newRoot is builder.NewRoot, returning an unsupported field as an error
+func newRoot(field *big.Int, opt frontend.CompileConfig) (root *builder.Root, err error) {
+	defer recoverUsageError(&err)
+	return builder.NewRoot(field, opt), nil
+}
+
+// recoverUsageError recovers into err the panics of the builder reporting a misuse of it (see
+// builder.IsUsageError) and the cancellation of the compilation. Other panics are bugs of the
+// compiler, and aren't recovered.
+func recoverUsageError(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	switch e := r.(type) {
+	case canceled:
+		*err = e.err
+		return
+	case error:
+		if builder.IsUsageError(e) {
+			*err = e
+			return
+		}
+	}
+	panic(r)
+}
+
+// publicInputOrder returns the index of the public input in each slot, and its name, given the
+// slot of each public input.
+func publicInputOrder(names []string, slots []int) ([]int, []string) {
+	order := make([]int, len(slots))
+	layout := make([]string, len(slots))
+	for i, slot := range slots {
+		order[slot] = i
+		layout[slot] = names[i]
+	}
+	return order, layout
+}
+
+func isIdentity(order []int) bool {
+	for i, j := range order {
+		if i != j {
+			return false
+		}
+	}
+	return true
+}
+
+func compile(rc *irsource.RootCircuit, opts rust.Options) (*CompileResult, error) {
+	irwg, lc, err := rust.Compile(rc, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &CompileResult{irs: rc, irwg: irwg, lc: lc, circuitHash: lc.ContentHash()}, nil
+}
+
+// compileLowMemory compiles rc with the Rust compiler writing the layered circuit to a temporary
+// file in dir, see WithLowMemory.
+func compileLowMemory(rc *irsource.RootCircuit, opts rust.Options, dir string) (*CompileResult, error) {
+	f, err := os.CreateTemp(dir, "circuit-*.txt")
+	if err != nil {
+		return nil, fmt.Errorf("create layered circuit file: %w", err)
+	}
+	path := f.Name()
+	f.Close()
+	irwg, err := rust.CompileToFile(rc, opts, path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	// the circuit is hashed in the mapped file, without loading it
+	m, err := layered.OpenMapped(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	res := &CompileResult{irs: rc, irwg: irwg, lcFile: path, ownsLcFile: true, circuitHash: m.ContentHash()}
+	if err := m.Close(); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	debug.FreeOSMemory()
+	return res, nil
 }
 
 // GetCircuitIr returns the intermediate representation (IR) of the compiled circuit as *ir.RootCircuit.
@@ -94,9 +518,167 @@ func (c *CompileResult) GetCircuitIr() *irsource.RootCircuit {
 	return c.irs
 }
 
-// GetInputSolver returns the InputSolver component of the compilation result as *ir.InputSolver.
+// GetLayeredCircuit returns the layered circuit of the compilation result. In low memory mode, it
+// panics if the circuit can't be read from LayeredCircuitFile, see LayeredCircuit.
 func (c *CompileResult) GetLayeredCircuit() *layered.RootCircuit {
-	return c.lc
+	lc, err := c.LayeredCircuit()
+	if err != nil {
+		panic(err)
+	}
+	return lc
+}
+
+// LayeredCircuit returns the layered circuit like GetLayeredCircuit, or the error reading it. In
+// low memory mode, it's read from LayeredCircuitFile the first time it's needed, and kept until
+// Close.
+func (c *CompileResult) LayeredCircuit() (lc *layered.RootCircuit, err error) {
+	c.lcMu.Lock()
+	defer c.lcMu.Unlock()
+	if c.lc != nil || c.lcFile == "" {
+		return c.lc, nil
+	}
+	buf, err := os.ReadFile(c.lcFile)
+	if err != nil {
+		return nil, fmt.Errorf("read layered circuit file: %w", err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			lc, err = nil, fmt.Errorf("invalid layered circuit file %s: %v", c.lcFile, r)
+		}
+	}()
+	c.lc = layered.DeserializeRootCircuit(buf)
+	return c.lc, nil
+}
+
+// releaseLayeredCircuit drops the layered circuit loaded from LayeredCircuitFile in low memory
+// mode, so that it's read again when needed
+func (c *CompileResult) releaseLayeredCircuit() {
+	c.lcMu.Lock()
+	defer c.lcMu.Unlock()
+	if c.lcFile != "" {
+		c.lc = nil
+	}
+}
+
+// Close removes the temporary file of the layered circuit written in low memory mode, see
+// WithLowMemory, and drops the circuit loaded from it. The entries of the compile cache and the
+// snapshots are kept. The layered circuit can't be read afterwards, while the other parts of the
+// result remain usable. Close does nothing for the results compiled in memory.
+func (c *CompileResult) Close() error {
+	c.lcMu.Lock()
+	defer c.lcMu.Unlock()
+	if c.lcFile == "" {
+		return nil
+	}
+	c.lc = nil
+	if !c.ownsLcFile {
+		return nil
+	}
+	c.ownsLcFile = false
+	if err := os.Remove(c.lcFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove layered circuit file: %w", err)
+	}
+	return nil
+}
+
+// Stats returns the statistics of the layered circuit, e.g. gates per layer and padding.
+func (c *CompileResult) Stats() *layered.Stats {
+	return c.GetLayeredCircuit().Stats()
+}
+
+// Features returns the features of the proving protocol the layered circuit relies on, the ones
+// detected from its gates and the lookups, see layered.RootCircuit.SerializeVersioned.
+func (c *CompileResult) Features() layered.Features {
+	return c.GetLayeredCircuit().Features() | c.features
+}
+
+// SkippedBooleanAssertions returns the number of boolean constraints saved by the builder, see
+// builder.Root.SkippedBooleanAssertions.
+func (c *CompileResult) SkippedBooleanAssertions() int {
+	return c.skippedBooleans
+}
+
+// ProvingCost returns the cost of proving the layered circuit predicted by m, e.g. a
+// layered.LinearCostModel fitted on the hardware of the prover.
+func (c *CompileResult) ProvingCost(m layered.CostModel) layered.Cost {
+	return c.Stats().Cost(m)
+}
+
+// CustomGateMetadata returns the metadata of the custom gates used by the layered circuit, to be
+// passed to a prover built with these gates. See customgates.SerializeMetadata for its encoding.
+func (c *CompileResult) CustomGateMetadata() ([]customgates.Metadata, error) {
+	return customgates.GetMetadata(c.GetLayeredCircuit().CustomGateTypes())
+}
+
+// LayeredCircuitFile returns the file containing the serialized layered circuit when compiled
+// with WithLowMemory, and an empty string otherwise.
+func (c *CompileResult) LayeredCircuitFile() string {
+	return c.lcFile
+}
+
+// PublicInputLayout returns the names of the public inputs, as returned by
+// schema.LeafInfo.FullName, in the order of their slots in the input layer of the circuit and in
+// witnesses. It can be checked against the layout expected by a verifier.
+func (c *CompileResult) PublicInputLayout() []string {
+	return c.publicLayout
+}
+
+// Tags returns the outputs named by API.Tag, which locate the tagged variables in the output
+// layer of the layered circuit. They aren't part of the serialized layered circuit, which is read
+// by the prover: write them next to it with WriteTags.
+func (c *CompileResult) Tags() []builder.OutputTag {
+	return c.tags
+}
+
+// Outputs evaluates the layered circuit on each witness of w, and returns the values given to
+// API.Output, as the verifier reads them on the output layer. The outputs of a circuit compiled
+// by CompileBatch hold the outputs of each copy in turn, laid out as by
+// layered.RootCircuit.Replicate. It returns an error if a witness doesn't
+// satisfy the circuit.
+func (c *CompileResult) Outputs(w *irwg.Witness) ([][]*big.Int, error) {
+	lc := c.GetLayeredCircuit()
+	a, b := w.NumInputsPerWitness, w.NumPublicInputsPerWitness
+	if len(w.Values) != w.NumWitnesses*(a+b) {
+		return nil, fmt.Errorf("expected %d values in the witness, got %d", w.NumWitnesses*(a+b), len(w.Values))
+	}
+	res := make([][]*big.Int, w.NumWitnesses)
+	for i := range res {
+		values := w.Values[i*(a+b) : (i+1)*(a+b)]
+		out, err := lc.Outputs(values[:a], values[a:])
+		if err != nil {
+			return nil, fmt.Errorf("witness %d: %w", i, err)
+		}
+		res[i] = out
+	}
+	return res, nil
+}
+
+// ContentHash returns the content hash of the layered circuit, see layered.RootCircuit.ContentHash.
+// Witnesses solved by the input solver carry it, and write it with Witness.SerializeWithHash, so
+// that they can be checked with Witness.CheckCircuitHash.
+func (c *CompileResult) ContentHash() [32]byte {
+	return c.circuitHash
+}
+
+// BatchSize returns the number of copies of the circuit in the layered circuit: n when compiled
+// by CompileBatch, and 1 otherwise.
+func (c *CompileResult) BatchSize() int {
+	if c.batch == 0 {
+		return 1
+	}
+	return c.batch
+}
+
+// ReplicateInputs lays out the witnesses solved by the input solver, BatchSize of them per
+// witness of the layered circuit, see irwg.ReplicateInputs. The result carries the content hash of
+// the layered circuit.
+func (c *CompileResult) ReplicateInputs(w *irwg.Witness) (*irwg.Witness, error) {
+	res, err := irwg.ReplicateInputs(w, c.BatchSize())
+	if err != nil {
+		return nil, err
+	}
+	res.CircuitHash = append([]byte(nil), c.circuitHash[:]...)
+	return res, nil
 }
 
 // GetLayeredCircuit returns the Layered Circuit component of the compilation result as *layered.RootCircuit.
@@ -115,3 +697,14 @@ func DeserializeLayeredCircuit(buf []byte) *layered.RootCircuit {
 func DeserializeInputSolver(buf []byte) *irwg.RootCircuit {
 	return irwg.DeserializeRootCircuit(buf)
 }
+
+// DeserializeWitness takes a byte buffer and returns a pointer to an irwg.Witness
+// which represents a deserialized witness.
+func DeserializeWitness(buf []byte) *irwg.Witness {
+	return irwg.DeserializeWitness(buf)
+}
+
+// DeserializePublicWitness reads the public inputs written by irwg.PublicWitness.Serialize.
+func DeserializePublicWitness(buf []byte) *irwg.PublicWitness {
+	return irwg.DeserializePublicWitness(buf)
+}
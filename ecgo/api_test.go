@@ -0,0 +1,124 @@
+package ecgo
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/passes"
+	"github.com/consensys/gnark/frontend"
+)
+
+type usageErrorCircuit struct {
+	X      frontend.Variable
+	define func(api frontend.API, x frontend.Variable)
+}
+
+func (c *usageErrorCircuit) Define(api frontend.API) error {
+	c.define(api, c.X)
+	return nil
+}
+
+func notASubCircuit(api frontend.API, x map[int]frontend.Variable) []frontend.Variable {
+	return nil
+}
+
+func TestCompileUsageErrors(t *testing.T) {
+	c := &usageErrorCircuit{define: func(api frontend.API, x frontend.Variable) {}}
+	if _, err := Compile(big.NewInt(101), c); !errors.Is(err, builder.ErrUnsupportedField) {
+		t.Fatalf("expected ErrUnsupportedField, got %v", err)
+	}
+	c = &usageErrorCircuit{define: func(api frontend.API, x frontend.Variable) {
+		api.(builder.SubCircuitAPI).MemorizedCall(notASubCircuit, map[int]frontend.Variable{0: x})
+	}}
+	if _, err := Compile(m31.ScalarField, c); !errors.Is(err, builder.ErrInvalidSubCircuit) {
+		t.Fatalf("expected ErrInvalidSubCircuit, got %v", err)
+	}
+	if err := CheckWitness(big.NewInt(101), c, c); !errors.Is(err, builder.ErrUnsupportedField) {
+		t.Fatalf("expected ErrUnsupportedField, got %v", err)
+	}
+}
+
+func TestCompilePipeline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var phases []string
+	instructions := 0
+	custom := passes.NewPass("count", func(rc *irsource.RootCircuit, _ ...passes.Option) int {
+		instructions = numInstructions(rc)
+		return 0
+	})
+	progress := WithProgress(func(p Progress) {
+		if len(phases) == 0 || phases[len(phases)-1] != p.Phase {
+			phases = append(phases, p.Phase)
+		}
+		if p.Phase == "layering" {
+			cancel()
+		}
+	})
+	_, err := CompileContext(ctx, m31.ScalarField, &estimateCircuit{}, WithPipeline(passes.Pipeline{passes.DCE, custom, passes.Fold}),
+		WithConstantFolding(false), progress)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the compilation to stop before the layering, got %v", err)
+	}
+	if got := strings.Join(phases, ","); got != "define,finalize,dce,count,layering" || instructions == 0 {
+		t.Fatalf("unexpected phases %s or instructions %d", got, instructions)
+	}
+}
+
+func TestLowMemoryFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "circuit.txt")
+	if err := os.WriteFile(path, verifierCircuit().Serialize(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res := &CompileResult{lcFile: path, ownsLcFile: true}
+	lc, err := res.LayeredCircuit()
+	if err != nil || lc.ContentHash() != verifierCircuit().ContentHash() {
+		t.Fatalf("unexpected layered circuit, error %v", err)
+	}
+	if res.GetLayeredCircuit() != lc {
+		t.Fatal("expected the loaded circuit to be kept")
+	}
+
+	// the replaced temporary file is removed
+	if err := res.setLayeredCircuit(lc.Pad(layered.Padding{Strategy: layered.PadToMaxWidth})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) || res.LayeredCircuitFile() == path {
+		t.Fatalf("expected the previous file to be removed, got %v", err)
+	}
+	path = res.LayeredCircuitFile()
+	if err := res.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected Close to remove the file, got %v", err)
+	}
+	if _, err := res.LayeredCircuit(); err == nil {
+		t.Fatal("expected the removed file to fail to load")
+	}
+
+	// files not owned by the result, e.g. of the cache, are kept, and invalid ones are errors
+	if err := os.WriteFile(path, []byte("not a circuit"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res = &CompileResult{lcFile: path}
+	if _, err := res.LayeredCircuit(); err == nil || !strings.Contains(err.Error(), "invalid layered circuit file") {
+		t.Fatalf("expected an invalid file, got %v", err)
+	}
+	if err := res.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected the file to be kept, got %v", err)
+	}
+}
@@ -3,6 +3,11 @@
 package builder
 
 import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
 	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/constraint/solver"
@@ -14,20 +19,48 @@ func init() {
 	solver.RegisterHint(IdentityHint)
 }
 
+var _ frontend.Committer = &builder{}
+
 // API defines a set of methods for interacting with the circuit builder.
 type API interface {
 	// ToSingleVariable converts an expression to a single base variable.
 	ToSingleVariable(frontend.Variable) frontend.Variable
 	// Output adds a variable to the circuit's output.
 	Output(frontend.Variable)
+	// Tag adds a variable to the circuit's output under a name kept in the compile result.
+	Tag(frontend.Variable, string)
 	// LayerOf returns an approximation of the layer in which a variable will be placed after compilation.
 	LayerOf(frontend.Variable) int // For debug usage.
 	// ToFirstLayer uses a hint to pull a variable back to the first layer.
 	ToFirstLayer(frontend.Variable) frontend.Variable
 	// GetRandomValue returns a random value for use within the circuit.
 	GetRandomValue() frontend.Variable
+	// Challenge returns the Fiat-Shamir challenge of the given name, drawn once the inputs are committed.
+	Challenge(name string) frontend.Variable
 	// CustomGate registers a hint, but it compiles to a custom gate in the layered circuit.
 	CustomGate(gateType uint64, inputs ...frontend.Variable) frontend.Variable
+	// NewTable returns a lookup table whose queries are checked with a LogUp argument.
+	NewTable(width int) *LookupTable
+	// Lshift returns a word of width bits shifted left by k bits, truncated to width bits.
+	Lshift(x frontend.Variable, k, width int) frontend.Variable
+	// Rshift returns a word of width bits shifted right by k bits.
+	Rshift(x frontend.Variable, k, width int) frontend.Variable
+	// Rotate returns a word of width bits rotated left by k bits, or right if k is negative.
+	Rotate(x frontend.Variable, k, width int) frontend.Variable
+	// SliceBits returns the bits lo to hi (excluded) of a word of width bits.
+	SliceBits(x frontend.Variable, lo, hi, width int) frontend.Variable
+	// AddVec returns the element-wise sum of two vectors of the same length.
+	AddVec(a, b []frontend.Variable) []frontend.Variable
+	// MulVec returns the element-wise product of two vectors of the same length.
+	MulVec(a, b []frontend.Variable) []frontend.Variable
+	// InnerProduct returns the inner product of two vectors of the same length.
+	InnerProduct(a, b []frontend.Variable) frontend.Variable
+	// AssertIsEqualVec asserts that two vectors of the same length are equal element-wise.
+	AssertIsEqualVec(a, b []frontend.Variable)
+	// AssertPermutation asserts that b is a permutation of a, with a grand-product argument.
+	AssertPermutation(a, b []frontend.Variable)
+	// AssertRowPermutation asserts that the rows of b are a permutation of the rows of a.
+	AssertRowPermutation(a, b [][]frontend.Variable)
 }
 
 // ---------------------------------------------------------------------------------------------
@@ -36,18 +69,14 @@ type API interface {
 // Add computes the sum i1+i2+...in and returns the result.
 func (builder *builder) Add(i1, i2 frontend.Variable, in ...frontend.Variable) frontend.Variable {
 	// extract frontend.Variables from input
-	vars := builder.toVariableIds(append([]frontend.Variable{i1, i2}, in...)...)
+	vars := builder.variadicIds(i1, i2, in)
 	return builder.add(vars, false)
 }
 
-func (builder *builder) MulAcc(a, b, c frontend.Variable) frontend.Variable {
-	return builder.Add(builder.Mul(b, c), a)
-}
-
 // Sub computes the difference between the given variables.
 // When more than two variables are provided, the difference is computed as i1 - Σ(i2...).
 func (builder *builder) Sub(i1, i2 frontend.Variable, in ...frontend.Variable) frontend.Variable {
-	vars := builder.toVariableIds(append([]frontend.Variable{i1, i2}, in...)...)
+	vars := builder.variadicIds(i1, i2, in)
 	return builder.add(vars, true)
 }
 
@@ -73,7 +102,7 @@ func (builder *builder) add(vars []int, sub bool) frontend.Variable {
 		}
 	}
 
-	coef := make([]constraint.Element, len(vars))
+	coef := builder.allocElements(len(vars))
 	coef[0] = builder.tOne
 	if sub {
 		for i := 1; i < len(vars); i++ {
@@ -84,7 +113,7 @@ func (builder *builder) add(vars []int, sub bool) frontend.Variable {
 			coef[i] = builder.tOne
 		}
 	}
-	builder.instructions = append(builder.instructions, irsource.Instruction{
+	builder.addInstruction(irsource.Instruction{
 		Type:        irsource.LinComb,
 		Inputs:      vars,
 		LinCombCoef: coef,
@@ -99,7 +128,7 @@ func (builder *builder) Neg(i frontend.Variable) frontend.Variable {
 		return builder.toVariable(builder.field.Neg(c))
 	}
 	coef := []constraint.Element{builder.field.Neg(builder.tOne)}
-	builder.instructions = append(builder.instructions, irsource.Instruction{
+	builder.addInstruction(irsource.Instruction{
 		Type:        irsource.LinComb,
 		Inputs:      []int{v},
 		LinCombCoef: coef,
@@ -109,7 +138,7 @@ func (builder *builder) Neg(i frontend.Variable) frontend.Variable {
 
 // Mul computes the product of the given variables.
 func (builder *builder) Mul(i1, i2 frontend.Variable, in ...frontend.Variable) frontend.Variable {
-	vars := builder.toVariableIds(append([]frontend.Variable{i1, i2}, in...)...)
+	vars := builder.variadicIds(i1, i2, in)
 	allConst := true
 	if sum, ok := builder.constantValue(vars[0]); ok {
 		for _, x := range vars[1:] {
@@ -124,7 +153,7 @@ func (builder *builder) Mul(i1, i2 frontend.Variable, in ...frontend.Variable) f
 			return builder.toVariable(sum)
 		}
 	}
-	builder.instructions = append(builder.instructions, irsource.Instruction{
+	builder.addInstruction(irsource.Instruction{
 		Type:   irsource.Mul,
 		Inputs: vars,
 	})
@@ -141,14 +170,14 @@ func (builder *builder) DivUnchecked(i1, i2 frontend.Variable) frontend.Variable
 	if ok1 && ok2 {
 		if c2.IsZero() {
 			if c1.IsZero() {
-				return builder.toVariable(constraint.Element{})
+				return builder.toVariable(builder.field.Zero())
 			}
 			panic("division by zero")
 		}
 		inv, _ := builder.field.Inverse(c2)
 		return builder.toVariable(builder.field.Mul(c1, inv))
 	}
-	builder.instructions = append(builder.instructions, irsource.Instruction{
+	builder.addInstruction(irsource.Instruction{
 		Type:    irsource.Div,
 		X:       v1,
 		Y:       v2,
@@ -171,7 +200,7 @@ func (builder *builder) Div(i1, i2 frontend.Variable) frontend.Variable {
 		inv, _ := builder.field.Inverse(c2)
 		return builder.toVariable(builder.field.Mul(c1, inv))
 	}
-	builder.instructions = append(builder.instructions, irsource.Instruction{
+	builder.addInstruction(irsource.Instruction{
 		Type:    irsource.Div,
 		X:       v1,
 		Y:       v2,
@@ -193,24 +222,106 @@ func (builder *builder) Inverse(i1 frontend.Variable) frontend.Variable {
 // n default value is fr.Bits the number of bits needed to represent a field element
 //
 // The result in in little endian (first bit= lsb)
+//
+// The bits are computed by a single hint, and checked by their booleanity and a single linear
+// combination, instead of gnark's chain of additions, so that the check is one layer deep. A
+// decomposition on the full width of the field also checks that the bits are less than the
+// modulus, with gnark's implementation.
 func (builder *builder) ToBinary(i1 frontend.Variable, n ...int) []frontend.Variable {
-	// TODO: Currently this doesn't support MarkBoolean
 	nbBits := builder.field.FieldBitLen()
 	if len(n) == 1 {
 		nbBits = n[0]
 		if nbBits < 0 {
 			panic("invalid n")
 		}
-	} else {
+	} else if len(n) > 1 {
 		panic("only one argument is supported")
 	}
 
-	return bits.ToBinary(builder, i1, bits.WithNbDigits(nbBits))
+	if c, ok := builder.ConstantValue(i1); ok {
+		if c.BitLen() > nbBits {
+			panic("constant doesn't fit in the requested number of bits")
+		}
+		res := make([]frontend.Variable, nbBits)
+		for i := range res {
+			res[i] = c.Bit(i)
+		}
+		return res
+	}
+	if nbBits >= builder.field.FieldBitLen() {
+		return bits.ToBinary(builder, i1, bits.WithNbDigits(nbBits))
+	}
+	if nbBits == 0 {
+		builder.AssertIsEqual(i1, 0)
+		return []frontend.Variable{}
+	}
+	if nbBits == 1 {
+		builder.AssertIsBoolean(i1)
+		return []frontend.Variable{i1}
+	}
+	res, err := builder.NewHint(RangeDecomposeHint, nbBits, nbBits, 1, i1)
+	if err != nil {
+		panic(err)
+	}
+	for _, b := range res {
+		builder.AssertIsBoolean(b)
+	}
+	builder.AssertIsEqual(builder.packBits(res), i1)
+	return res
 }
 
 // FromBinary packs the given variables, seen as a fr.Element in little endian, into a single variable.
+// The variables are asserted to be boolean, unless they are known to be, and packed by a single
+// linear combination.
 func (builder *builder) FromBinary(_b ...frontend.Variable) frontend.Variable {
-	return bits.FromBinary(builder, _b)
+	for _, b := range _b {
+		builder.AssertIsBoolean(b)
+	}
+	return builder.packBits(_b)
+}
+
+// packBits returns the sum of b[i] * 2^i, as a single linear combination
+func (builder *builder) packBits(b []frontend.Variable) frontend.Variable {
+	shifts := make([]int, len(b))
+	for i := range shifts {
+		shifts[i] = i
+	}
+	return builder.shiftedSum(b, shifts)
+}
+
+// shiftedSum returns the sum of x[i] * 2^shifts[i], as a single linear combination
+func (builder *builder) shiftedSum(x []frontend.Variable, shifts []int) frontend.Variable {
+	if len(x) == 0 {
+		return builder.toVariable(builder.field.Zero())
+	}
+	vars := builder.toVariableIds(x...)
+	coef := builder.allocElements(len(vars))
+	sum := builder.field.Zero()
+	allConst := true
+	for i, v := range vars {
+		coef[i] = builder.field.FromInterface(new(big.Int).Lsh(big.NewInt(1), uint(shifts[i])))
+		if c, ok := builder.constantValue(v); ok && allConst {
+			sum = builder.field.Add(sum, builder.field.Mul(coef[i], c))
+		} else {
+			allConst = false
+		}
+	}
+	if allConst {
+		return builder.toVariable(sum)
+	}
+	builder.addInstruction(irsource.Instruction{
+		Type:        irsource.LinComb,
+		Inputs:      vars,
+		LinCombCoef: coef,
+	})
+	return builder.addVar()
+}
+
+// addBooleanVar allocates the output of an instruction whose result is always boolean
+func (builder *builder) addBooleanVar() frontend.Variable {
+	v := builder.addVarId()
+	builder.booleans[v] = true
+	return builder.newVariable(v)
 }
 
 // Xor computes the logical XOR between two frontend.Variables.
@@ -225,17 +336,17 @@ func (builder *builder) Xor(_a, _b frontend.Variable) frontend.Variable {
 		builder.AssertIsBoolean(_b)
 		t := builder.field.Sub(c1, c2)
 		if t.IsZero() {
-			return builder.toVariable(constraint.Element{})
+			return builder.toVariable(builder.field.Zero())
 		}
 		return builder.toVariable(builder.tOne)
 	}
-	builder.instructions = append(builder.instructions, irsource.Instruction{
+	builder.addInstruction(irsource.Instruction{
 		Type:    irsource.BoolBinOp,
 		X:       a,
 		Y:       b,
 		ExtraId: 1,
 	})
-	return builder.addVar()
+	return builder.addBooleanVar()
 }
 
 // Or computes the logical OR between two frontend.Variables.
@@ -249,17 +360,17 @@ func (builder *builder) Or(_a, _b frontend.Variable) frontend.Variable {
 		builder.AssertIsBoolean(_a)
 		builder.AssertIsBoolean(_b)
 		if c1.IsZero() && c2.IsZero() {
-			return builder.toVariable(constraint.Element{})
+			return builder.toVariable(builder.field.Zero())
 		}
 		return builder.toVariable(builder.tOne)
 	}
-	builder.instructions = append(builder.instructions, irsource.Instruction{
+	builder.addInstruction(irsource.Instruction{
 		Type:    irsource.BoolBinOp,
 		X:       a,
 		Y:       b,
 		ExtraId: 2,
 	})
-	return builder.addVar()
+	return builder.addBooleanVar()
 }
 
 // And computes the logical AND between two frontend.Variables.
@@ -273,17 +384,17 @@ func (builder *builder) And(_a, _b frontend.Variable) frontend.Variable {
 		builder.AssertIsBoolean(_a)
 		builder.AssertIsBoolean(_b)
 		if c1.IsZero() || c2.IsZero() {
-			return builder.toVariable(constraint.Element{})
+			return builder.toVariable(builder.field.Zero())
 		}
 		return builder.toVariable(builder.tOne)
 	}
-	builder.instructions = append(builder.instructions, irsource.Instruction{
+	builder.addInstruction(irsource.Instruction{
 		Type:    irsource.BoolBinOp,
 		X:       a,
 		Y:       b,
 		ExtraId: 3,
 	})
-	return builder.addVar()
+	return builder.addBooleanVar()
 }
 
 // ---------------------------------------------------------------------------------------------
@@ -347,9 +458,9 @@ func (builder *builder) IsZero(i1 frontend.Variable) frontend.Variable {
 		if c.IsZero() {
 			return builder.toVariable(builder.tOne)
 		}
-		return builder.toVariable(constraint.Element{})
+		return builder.toVariable(builder.field.Zero())
 	}
-	builder.instructions = append(builder.instructions, irsource.Instruction{
+	builder.addInstruction(irsource.Instruction{
 		Type: irsource.IsZero,
 		X:    a,
 	})
@@ -358,35 +469,18 @@ func (builder *builder) IsZero(i1 frontend.Variable) frontend.Variable {
 
 // Cmp compares i1 and i2 and returns 1 if i1>i2, 0 if i1=i2, -1 if i1<i2.
 func (builder *builder) Cmp(i1, i2 frontend.Variable) frontend.Variable {
+	c1, ok1 := builder.ConstantValue(i1)
+	c2, ok2 := builder.ConstantValue(i2)
+	if ok1 && ok2 {
+		return builder.toVariable(c1.Cmp(c2))
+	}
 	nbBits := builder.field.FieldBitLen()
-	// in AssertIsLessOrEq we omitted comparison against modulus for the left
-	// side as if `a+r<b` implies `a<b`, then here we compute the inequality
-	// directly.
+	// both decompositions must be canonical, otherwise i1+p could be compared to i2
 	bi1 := bits.ToBinary(builder, i1, bits.WithNbDigits(nbBits))
 	bi2 := bits.ToBinary(builder, i2, bits.WithNbDigits(nbBits))
 
-	res := newVariable(builder.toVariableId(0))
-
-	for i := builder.field.FieldBitLen() - 1; i >= 0; i-- {
-
-		iszeroi1 := builder.IsZero(bi1[i])
-		iszeroi2 := builder.IsZero(bi2[i])
-
-		i1i2 := builder.And(bi1[i], iszeroi2)
-		i2i1 := builder.And(bi2[i], iszeroi1)
-
-		n := builder.Select(i2i1, -1, 0)
-		m := builder.Select(i1i2, 1, n)
-
-		res = newVariable(builder.toVariableId(builder.Select(builder.IsZero(res), m, res)))
-
-	}
-	return res
-}
-
-// Println is not implemented and will panic if called.
-func (builder *builder) Println(a ...frontend.Variable) {
-	panic("unimplemented")
+	lt, gt, _ := builder.compareBits(bi1, bi2)
+	return builder.Sub(gt, lt)
 }
 
 // Compiler returns itself as it implements the frontend.Compiler interface.
@@ -394,14 +488,23 @@ func (builder *builder) Compiler() frontend.Compiler {
 	return builder
 }
 
-// Commit is faulty in its current implementation as it merely returns a compile-time random number.
+// Commit implements frontend.Committer.
+// In the GKR protocol the whole input layer is committed before any challenge is drawn, so every
+// variable is already bound by the proof. The commitment is therefore materialized as a challenge
+// of the root circuit, named "commitment/<n>" for the n-th call, see Challenge for its contract.
+// A commitment is a single base field element, too small to be a sound challenge in fields
+// implementing field.Extension, like M31 and BabyBear: Commit returns an error over them, and
+// circuits should use NewTable or AssertRowPermutation, which sample in the extension.
 func (builder *builder) Commit(v ...frontend.Variable) (frontend.Variable, error) {
-	vars := builder.toVariableIds(v...)
-	builder.instructions = append(builder.instructions, irsource.Instruction{
-		Type:   irsource.Commit,
-		Inputs: vars,
-	})
-	return builder.addVar(), nil
+	if builder.root.builder != builder {
+		return nil, errors.New("Commit can only be called on root circuit")
+	}
+	if d, _ := field.ChallengeExtension(builder.field); d > 1 {
+		return nil, fmt.Errorf("Commit is unsound over a field of %d bits, whose challenges are sampled in an extension of degree %d", builder.field.FieldBitLen(), d)
+	}
+	x := builder.Challenge(fmt.Sprintf("commitment/%d", builder.root.commitments))
+	builder.root.commitments++
+	return x, nil
 }
 
 // SetGkrInfo is not implemented and will panic if called.
@@ -16,14 +16,14 @@ func (builder *builder) AssertIsEqual(i1, i2 frontend.Variable) {
 	v, xConstant := builder.constantValue(x)
 	if xConstant {
 		if !v.IsZero() {
-			panic("AssertIsEqual will never be satisfied on nonzero constant")
+			panic("AssertIsEqual will never be satisfied on nonzero constant" + builder.callerSuffix())
 		}
 		return
 	}
-	builder.constraints = append(builder.constraints, irsource.Constraint{
+	builder.addConstraint(irsource.Constraint{
 		Typ: irsource.Zero,
 		Var: x,
-	})
+	}, "AssertIsEqual", i1, i2)
 }
 
 // AssertIsDifferent constrains i1 and i2 to have different values.
@@ -32,14 +32,14 @@ func (builder *builder) AssertIsDifferent(i1, i2 frontend.Variable) {
 	v, xConstant := builder.constantValue(x)
 	if xConstant {
 		if v.IsZero() {
-			panic("AssertIsDifferent will never be satisfied on zero constant")
+			panic("AssertIsDifferent will never be satisfied on zero constant" + builder.callerSuffix())
 		}
 		return
 	}
-	builder.constraints = append(builder.constraints, irsource.Constraint{
+	builder.addConstraint(irsource.Constraint{
 		Typ: irsource.NonZero,
 		Var: x,
-	})
+	}, "AssertIsDifferent", i1, i2)
 }
 
 // AssertIsBoolean adds an assertion that the variable is either 0 or 1.
@@ -47,14 +47,20 @@ func (builder *builder) AssertIsBoolean(i1 frontend.Variable) {
 	x := builder.toVariableId(i1)
 	if b, ok := builder.constantValue(x); ok {
 		if !(b.IsZero() || builder.field.IsOne(b)) {
-			panic("assertIsBoolean failed: constant is not 0 or 1")
+			panic("assertIsBoolean failed: constant is not 0 or 1" + builder.callerSuffix())
 		}
 		return
 	}
-	builder.constraints = append(builder.constraints, irsource.Constraint{
+	// already constrained, or boolean by construction
+	if builder.booleans[x] {
+		builder.root.skippedBooleans++
+		return
+	}
+	builder.booleans[x] = true
+	builder.addConstraint(irsource.Constraint{
 		Typ: irsource.Bool,
 		Var: x,
-	})
+	}, "AssertIsBoolean", i1)
 }
 
 // AssertIsCrumb adds an assertion that the variable is a 2-bit value, also known as a crumb.
@@ -70,43 +76,59 @@ func (builder *builder) AssertIsLessOrEqual(v frontend.Variable, bound frontend.
 }
 
 func (builder *builder) mustBeLessOrEqVar(a, bound frontend.Variable) {
-	// here bound is NOT a constant,
-	// but a can be either constant or a wire.
+	// bound can be either constant or a wire, and so can a.
+	ca, ok1 := builder.ConstantValue(a)
+	cb, ok2 := builder.ConstantValue(bound)
+	if ok1 && ok2 {
+		if ca.Cmp(cb) > 0 {
+			panic("AssertIsLessOrEqual will never be satisfied on constants" + builder.callerSuffix())
+		}
+		return
+	}
+	// The bits of a don't need to be canonical: a non-canonical decomposition represents a+p,
+	// which is larger than any bound.
 
 	nbBits := builder.field.FieldBitLen()
 
-	aBits := bits.ToBinary(builder, a, bits.WithNbDigits(nbBits), bits.WithUnconstrainedOutputs(), bits.OmitModulusCheck())
+	aBits := bits.ToBinary(builder, a, bits.WithNbDigits(nbBits), bits.OmitModulusCheck())
 	boundBits := bits.ToBinary(builder, bound, bits.WithNbDigits(nbBits))
 
-	p := make([]frontend.Variable, nbBits+1)
-	p[nbBits] = newVariable(builder.toVariableId(1))
-
-	zero := newVariable(builder.toVariableId(0))
-
-	for i := nbBits - 1; i >= 0; i-- {
-
-		// if bound[i] == 0
-		// 		p[i] = p[i+1]
-		//		t = p[i+1]
-		// else
-		// 		p[i] = p[i+1] * a[i]
-		//		t = 0
-		v := builder.Mul(p[i+1], aBits[i])
-		p[i] = builder.Select(boundBits[i], v, p[i+1])
-
-		t := builder.Select(boundBits[i], zero, p[i+1])
-
-		// (1 - t - ai) * ai == 0
-		var l frontend.Variable
-		l = newVariable(builder.toVariableId(1))
-		l = builder.Sub(l, t, aBits[i])
-
-		// note if bound[i] == 1, this constraint is (1 - ai) * ai == 0
-		// → this is a boolean constraint
-		// if bound[i] == 0, t must be 0 or 1, thus ai must be 0 or 1 too
+	lt, _, eq := builder.compareBits(aBits, boundBits)
+	builder.AssertIsEqual(builder.Add(lt, eq), 1)
+}
 
-		builder.AssertIsEqual(builder.Mul(l, aBits[i]), zero)
+// compareBits compares the little endian bit decompositions a and b, and returns booleans for
+// a < b, a > b and a == b. Instead of scanning the bits sequentially, which gives one layer per
+// bit, the per-bit results are merged by a balanced tree, so the depth is logarithmic in the
+// number of bits and each layer is wide.
+func (builder *builder) compareBits(a, b []frontend.Variable) (lt, gt, eq frontend.Variable) {
+	if len(a) != len(b) || len(a) == 0 {
+		panic("compareBits: inputs must have the same non-zero length")
+	}
+	n := len(a)
+	lts := make([]frontend.Variable, n)
+	gts := make([]frontend.Variable, n)
+	eqs := make([]frontend.Variable, n)
+	for i := 0; i < n; i++ {
+		ab := builder.Mul(a[i], b[i])
+		lts[i] = builder.Sub(b[i], ab)
+		gts[i] = builder.Sub(a[i], ab)
+		eqs[i] = builder.Sub(1, lts[i], gts[i])
 	}
+	for len(lts) > 1 {
+		m := (len(lts) + 1) / 2
+		for i := 0; i < len(lts)/2; i++ {
+			lo, hi := 2*i, 2*i+1
+			lts[i] = builder.Add(lts[hi], builder.Mul(eqs[hi], lts[lo]))
+			gts[i] = builder.Add(gts[hi], builder.Mul(eqs[hi], gts[lo]))
+			eqs[i] = builder.Mul(eqs[hi], eqs[lo])
+		}
+		if len(lts)%2 == 1 {
+			lts[m-1], gts[m-1], eqs[m-1] = lts[len(lts)-1], gts[len(gts)-1], eqs[len(eqs)-1]
+		}
+		lts, gts, eqs = lts[:m], gts[:m], eqs[:m]
+	}
+	return lts[0], gts[0], eqs[0]
 }
 
 // MustBeLessOrEqCst asserts that the value represented by its bit decomposition is less than or equal to a constant bound.
@@ -127,36 +149,11 @@ func (builder *builder) MustBeLessOrEqCst(aBits []frontend.Variable, bound *big.
 		panic("AssertIsLessOrEqual: bound is too large, constraint will never be satisfied")
 	}
 
-	// t trailing bits in the bound
-	t := 0
+	boundBits := make([]frontend.Variable, nbBits)
 	for i := 0; i < nbBits; i++ {
-		if bound.Bit(i) == 0 {
-			break
-		}
-		t++
-	}
-
-	p := make([]frontend.Variable, nbBits+1)
-	// p[i] == 1 → a[j] == c[j] for all j ⩾ i
-	p[nbBits] = newVariable(builder.toVariableId(1))
-
-	for i := nbBits - 1; i >= t; i-- {
-		if bound.Bit(i) == 0 {
-			p[i] = p[i+1]
-		} else {
-			p[i] = builder.Mul(p[i+1], aBits[i])
-		}
-	}
-
-	for i := nbBits - 1; i >= 0; i-- {
-		if bound.Bit(i) == 0 {
-			// (1 - p(i+1) - ai) * ai == 0
-			l := builder.Sub(1, p[i+1])
-			l = builder.Sub(l, aBits[i])
-
-			builder.AssertIsEqual(builder.Mul(l, aBits[i]), newVariable(builder.toVariableId(0)))
-		} else {
-			builder.AssertIsBoolean(aBits[i])
-		}
+		builder.AssertIsBoolean(aBits[i])
+		boundBits[i] = bound.Bit(i)
 	}
+	lt, _, eq := builder.compareBits(aBits, boundBits)
+	builder.AssertIsEqual(builder.Add(lt, eq), 1)
 }
@@ -0,0 +1,87 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func TestCmpConstants(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	cases := []struct {
+		a, b int64
+		res  int64
+	}{
+		{5, 9, -1},
+		{9, 5, 1},
+		{7, 7, 0},
+		{0, m31.P - 1, -1},
+	}
+	for _, c := range cases {
+		r, ok := root.ConstantValue(root.Cmp(c.a, c.b))
+		if !ok {
+			t.Fatalf("Cmp(%d, %d) should be a constant", c.a, c.b)
+		}
+		expected := c.res
+		if expected < 0 {
+			expected += m31.P
+		}
+		if r.Int64() != expected {
+			t.Fatalf("Cmp(%d, %d) = %s, expected %d", c.a, c.b, r.String(), c.res)
+		}
+	}
+	root.AssertIsLessOrEqual(3, 3)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("AssertIsLessOrEqual(4, 3) should panic")
+		}
+	}()
+	root.AssertIsLessOrEqual(4, 3)
+}
+
+// mulDepth returns the maximum number of chained multiplications leading to each variable
+func mulDepth(c *irsource.Circuit) []int {
+	depth := make([]int, c.NumVariables()+1)
+	v := c.NumInputs + 1
+	for _, in := range c.Instructions {
+		d := 0
+		for _, x := range in.Operands() {
+			if depth[x] > d {
+				d = depth[x]
+			}
+		}
+		if in.Type == irsource.Mul {
+			d++
+		}
+		if in.Type == irsource.Hint {
+			d = 0
+		}
+		for j := 0; j < in.OutputCount(); j++ {
+			depth[v] = d
+			v++
+		}
+	}
+	return depth
+}
+
+func TestAssertIsLessOrEqualDepth(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	y := root.SecretVariable(schema.LeafInfo{})
+	root.AssertIsLessOrEqual(x, y)
+	c := root.Finalize().Circuits[0]
+	depth := mulDepth(c)
+	maxDepth := 0
+	for _, con := range c.Constraints {
+		if depth[con.Var] > maxDepth {
+			maxDepth = depth[con.Var]
+		}
+	}
+	// one multiplication per bit, then one per level of the tree over 31 bits
+	if maxDepth > 1+5 {
+		t.Fatalf("comparison has multiplicative depth %d", maxDepth)
+	}
+}
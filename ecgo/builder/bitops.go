@@ -0,0 +1,116 @@
+package builder
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+)
+
+func init() {
+	solver.RegisterHint(SplitBitsHint)
+}
+
+// SplitBitsHint splits inputs[len(inputs)-1] into one output per width in inputs[:len(inputs)-1],
+// from the least significant bits. The value must fit in the sum of the widths.
+func SplitBitsHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	x := new(big.Int).Set(inputs[len(inputs)-1])
+	total := 0
+	for i := range outputs {
+		w := uint(inputs[i].Int64())
+		mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), w), big.NewInt(1))
+		outputs[i].And(x, mask)
+		x.Rsh(x, w)
+		total += int(w)
+	}
+	if x.Sign() != 0 {
+		return fmt.Errorf("value doesn't fit in %d bits", total)
+	}
+	return nil
+}
+
+// splitBits asserts that x fits in the sum of the widths, and returns its parts of each width
+// from the least significant bits. The parts are computed by a single hint and range checked,
+// so that a shift or a rotation costs a few lookups and a linear combination instead of a
+// decomposition in bits.
+func (builder *builder) splitBits(api string, x frontend.Variable, widths ...int) []frontend.Variable {
+	total := 0
+	for _, w := range widths {
+		if w < 0 {
+			panic(fmt.Sprintf("%s: invalid number of bits", api))
+		}
+		total += w
+	}
+	if total >= builder.field.FieldBitLen() {
+		panic(fmt.Sprintf("%s: a word of %d bits doesn't fit in the field", api, total))
+	}
+	if c, ok := builder.ConstantValue(x); ok {
+		if c.BitLen() > total {
+			panic(fmt.Sprintf("%s: constant %s doesn't fit in %d bits%s", api, c, total, builder.callerSuffix()))
+		}
+		res := make([]frontend.Variable, len(widths))
+		for i, w := range widths {
+			res[i] = new(big.Int).And(c, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(w)), big.NewInt(1)))
+			c.Rsh(c, uint(w))
+		}
+		return res
+	}
+	inputs := make([]frontend.Variable, 0, len(widths)+1)
+	for _, w := range widths {
+		inputs = append(inputs, w)
+	}
+	parts, err := builder.NewHint(SplitBitsHint, len(widths), append(inputs, x)...)
+	if err != nil {
+		panic(err)
+	}
+	shifts := make([]int, len(widths))
+	for i, w := range widths {
+		builder.Check(parts[i], w)
+		if i > 0 {
+			shifts[i] = shifts[i-1] + widths[i-1]
+		}
+	}
+	builder.AssertIsEqual(builder.shiftedSum(parts, shifts), x)
+	return parts
+}
+
+// Lshift returns x shifted left by k bits, truncated to width bits. It asserts that x fits in
+// width bits.
+func (builder *builder) Lshift(x frontend.Variable, k, width int) frontend.Variable {
+	if k < 0 {
+		panic("Lshift: invalid shift")
+	}
+	k = min(k, width)
+	parts := builder.splitBits("Lshift", x, width-k, k)
+	return builder.shiftedSum(parts[:1], []int{k})
+}
+
+// Rshift returns x shifted right by k bits. It asserts that x fits in width bits.
+func (builder *builder) Rshift(x frontend.Variable, k, width int) frontend.Variable {
+	if k < 0 {
+		panic("Rshift: invalid shift")
+	}
+	k = min(k, width)
+	return builder.splitBits("Rshift", x, k, width-k)[1]
+}
+
+// Rotate returns x rotated left by k bits in a word of width bits, or right if k is negative
+// like bits.RotateLeft. It asserts that x fits in width bits.
+func (builder *builder) Rotate(x frontend.Variable, k, width int) frontend.Variable {
+	if width == 0 {
+		return builder.splitBits("Rotate", x, 0)[0]
+	}
+	k = ((k % width) + width) % width
+	parts := builder.splitBits("Rotate", x, width-k, k)
+	return builder.shiftedSum(parts, []int{k, 0})
+}
+
+// SliceBits returns the bits lo to hi (excluded) of x as a number. It asserts that x fits in
+// width bits.
+func (builder *builder) SliceBits(x frontend.Variable, lo, hi, width int) frontend.Variable {
+	if lo < 0 || hi < lo || hi > width {
+		panic(fmt.Sprintf("SliceBits: invalid range [%d, %d) of a word of %d bits", lo, hi, width))
+	}
+	return builder.splitBits("SliceBits", x, lo, hi-lo, width-hi)[1]
+}
@@ -0,0 +1,40 @@
+package builder
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func TestBitOperations(t *testing.T) {
+	const x = 0b1011_0110_0101_1100
+	for _, c := range []struct {
+		name     string
+		op       func(api *Root, x frontend.Variable) frontend.Variable
+		expected int64
+	}{
+		{"Lshift", func(api *Root, x frontend.Variable) frontend.Variable { return api.Lshift(x, 3, 16) }, 0b1011_0010_1110_0000},
+		{"Lshift all", func(api *Root, x frontend.Variable) frontend.Variable { return api.Lshift(x, 20, 16) }, 0},
+		{"Rshift", func(api *Root, x frontend.Variable) frontend.Variable { return api.Rshift(x, 5, 16) }, 0b101_1011_0010},
+		{"Rotate", func(api *Root, x frontend.Variable) frontend.Variable { return api.Rotate(x, 4, 16) }, 0b0110_0101_1100_1011},
+		{"Rotate right", func(api *Root, x frontend.Variable) frontend.Variable { return api.Rotate(x, -4, 16) }, 0b1100_1011_0110_0101},
+		{"SliceBits", func(api *Root, x frontend.Variable) frontend.Variable { return api.SliceBits(x, 2, 9, 16) }, 0b001_0111},
+	} {
+		for _, in := range []int64{x, x | 1<<16} {
+			root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+			v := root.SecretVariable(schema.LeafInfo{})
+			root.AssertIsEqual(c.op(root, v), c.expected)
+			err := evalRoot(root.Finalize(), []*big.Int{big.NewInt(in)})
+			if (err == nil) != (in == x) {
+				t.Fatalf("%s of %b: unexpected result %v", c.name, in, err)
+			}
+		}
+		root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+		if y, ok := root.ConstantValue(c.op(root, x)); !ok || y.Int64() != c.expected {
+			t.Fatalf("%s of a constant: expected %b, got %v", c.name, c.expected, y)
+		}
+	}
+}
@@ -7,6 +7,8 @@
 package builder
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
 	"reflect"
 
@@ -16,6 +18,7 @@ import (
 
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/customgates"
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/gnarkexpr"
 )
 
@@ -32,35 +35,58 @@ type builder struct {
 
 	instructions []irsource.Instruction
 	constraints  []irsource.Constraint
+	// origins[i] describes constraints[i]
+	origins []ConstraintOrigin
 
 	nbExternalInput int
 	maxVar          int
 	varConstId      []int
 	constValues     []constraint.Element
 
+	// variables known to be boolean, see MarkBoolean
+	booleans map[int]bool
+
 	// defers (for gnark API)
 	defers []func(frontend.API) error
 
+	// lookup tables, checked after the defers
+	tables []*LookupTable
+
 	// we have to implement kvstore.Store (required by gnark/internal/circuitdefer/defer.go:30)
 	db map[any]any
 
 	// output of sub circuit
 	output []int
+
+	// values of a subcircuit being built to look up in the range table of the root circuit,
+	// returned by the subcircuit after its outputs, see Check
+	rangeQueries []frontend.Variable
+	// number of outputs of a built subcircuit which are range queries
+	nbRangeOutputs int
+	// whether the outputs of the subcircuit are set, after which range queries can't be added
+	sealed bool
 }
 
-// newBuilder returns a builder with known number of external input
-func (r *Root) newBuilder(nbExternalInput int) *builder {
+// newBuilder returns a builder with known number of external input, with room for capacity
+// instructions, constraints and variables
+func (r *Root) newBuilder(nbExternalInput int, capacity int) *builder {
 	builder := builder{
 		field:           r.field,
 		root:            r,
 		db:              make(map[any]any),
+		booleans:        make(map[int]bool),
 		nbExternalInput: nbExternalInput,
 	}
 
 	builder.tOne = builder.field.One()
 
 	builder.maxVar = nbExternalInput
-	builder.varConstId = make([]int, nbExternalInput+1)
+	builder.varConstId = make([]int, nbExternalInput+1, nbExternalInput+1+max(capacity, 0))
+	if capacity > 0 {
+		builder.instructions = make([]irsource.Instruction, 0, capacity)
+		builder.constraints = make([]irsource.Constraint, 0, capacity)
+		builder.origins = make([]ConstraintOrigin, 0, capacity)
+	}
 	builder.constValues = make([]constraint.Element, 1)
 
 	return &builder
@@ -92,15 +118,24 @@ func (builder *builder) LayerOf(e frontend.Variable) int {
 // This is useful in scenarios where a variable is known to be boolean through a constraint
 // that is not api.AssertIsBoolean. If v is a constant, this is a no-op.
 func (builder *builder) MarkBoolean(v frontend.Variable) {
-	// TODO: noop
+	if e, ok := v.(gnarkexpr.Expr); ok {
+		builder.booleans[e.WireID()] = true
+	}
 }
 
 // IsBoolean returns true if given variable was marked as boolean in the compiler (see MarkBoolean)
 // Use with care; variable may not have been **constrained** to be boolean
 // This returns true if the v is a constant and v == 0 || v == 1.
 func (builder *builder) IsBoolean(v frontend.Variable) bool {
-	// TODO: noop
-	return false
+	e, ok := v.(gnarkexpr.Expr)
+	if !ok {
+		c := builder.field.FromInterface(v)
+		return c.IsZero() || builder.field.IsOne(c)
+	}
+	if c, ok := builder.constantValue(e.WireID()); ok {
+		return c.IsZero() || builder.field.IsOne(c)
+	}
+	return builder.booleans[e.WireID()]
 }
 
 // Compile is a placeholder for gnark API compatibility; it does nothing.
@@ -108,9 +143,15 @@ func (builder *builder) Compile() (constraint.ConstraintSystem, error) {
 	return nil, nil
 }
 
-// ConstantValue returns always returns (nil, false) now, since the Golang frontend doesn't know the values of variables.
+// ConstantValue returns the value of v if it's known at compile time, i.e. if v is a Go constant
+// or was computed from constants only. gnark gadgets use it to skip constraints on constants.
 func (builder *builder) ConstantValue(v frontend.Variable) (*big.Int, bool) {
-	coeff, ok := builder.constantValue(builder.toVariableId(v))
+	e, ok := v.(gnarkexpr.Expr)
+	if !ok {
+		// don't allocate a variable for Go constants
+		return builder.field.ToBigInt(builder.field.FromInterface(v)), true
+	}
+	coeff, ok := builder.constantValue(e.WireID())
 	if !ok {
 		return nil, false
 	}
@@ -127,16 +168,19 @@ func (builder *builder) constantValue(x int) (constraint.Element, bool) {
 
 func (builder *builder) addVarId() int {
 	builder.maxVar += 1
-	builder.varConstId = append(builder.varConstId, 0)
+	builder.varConstId = append(reserve(builder.varConstId, builder.root.growth.Factor), 0)
+	if builder.root.hooks.VariableCreated != nil {
+		builder.variableCreated(builder.maxVar)
+	}
 	return builder.maxVar
 }
 
 func (builder *builder) addVar() frontend.Variable {
-	return newVariable(builder.addVarId())
+	return builder.newVariable(builder.addVarId())
 }
 
 func (builder *builder) ceToId(x constraint.Element) int {
-	builder.instructions = append(builder.instructions, irsource.Instruction{
+	builder.addInstruction(irsource.Instruction{
 		Type:    irsource.ConstantLike,
 		ExtraId: 0,
 		Const:   x,
@@ -161,25 +205,39 @@ func (builder *builder) toVariableId(input interface{}) int {
 	case *constraint.Element:
 		return builder.ceToId(*t)
 	default:
+		if v := reflect.ValueOf(t); v.Kind() == reflect.Slice && v.Type().Elem().Implements(exprType) {
+			// a linear expression of a gnark builder, whose terms aren't variables of this one
+			panic(fmt.Errorf("%w: gnark expression of %d terms", ErrNonQuadratic, v.Len()))
+		}
 		// try to make it into a constant
 		c := builder.field.FromInterface(t)
 		return builder.ceToId(c)
 	}
 }
 
+var exprType = reflect.TypeOf((*gnarkexpr.Expr)(nil)).Elem()
+
 func (builder *builder) toVariable(input interface{}) frontend.Variable {
-	return newVariable(builder.toVariableId(input))
+	return builder.newVariable(builder.toVariableId(input))
 }
 
 // toVariables return frontend.Variable corresponding to inputs and the total size of the linear expressions
 func (builder *builder) toVariableIds(in ...frontend.Variable) []int {
-	r := make([]int, 0, len(in))
-	e := func(i frontend.Variable) {
-		v := builder.toVariableId(i)
-		r = append(r, v)
+	r := builder.allocInts(len(in))
+	for i := range in {
+		r[i] = builder.toVariableId(in[i])
 	}
-	for i := 0; i < len(in); i++ {
-		e(in[i])
+	return r
+}
+
+// variadicIds is toVariableIds for the arguments of variadic API functions, without building
+// a slice of all of them
+func (builder *builder) variadicIds(i1, i2 frontend.Variable, in []frontend.Variable) []int {
+	r := builder.allocInts(len(in) + 2)
+	r[0] = builder.toVariableId(i1)
+	r[1] = builder.toVariableId(i2)
+	for i := range in {
+		r[i+2] = builder.toVariableId(in[i])
 	}
 	return r
 }
@@ -196,25 +254,34 @@ func (builder *builder) toVariableIds(in ...frontend.Variable) []int {
 //
 // No new constraints are added to the newly created wire and must be added
 // manually in the circuit. Failing to do so leads to solver failure.
+//
+// f is also registered in gnark's hint registry, so that the InputSolver can find it
+// when solving in the same process. Other processes still have to register it.
 func (builder *builder) NewHint(f solver.Hint, nbOutputs int, inputs ...frontend.Variable) ([]frontend.Variable, error) {
+	if solver.GetRegisteredHint(solver.GetHintID(f)) == nil {
+		solver.RegisterHint(f)
+	}
 	return builder.newHintForId(solver.GetHintID(f), nbOutputs, inputs)
 }
 
-// NewHintForId is not implemented and will panic if called.
+// NewHintForId is the same as NewHint, but the hint is referred to by its id.
+// The corresponding function must be registered with solver.RegisterNamedHint before solving.
 func (builder *builder) NewHintForId(id solver.HintID, nbOutputs int, inputs ...frontend.Variable) ([]frontend.Variable, error) {
 	return builder.newHintForId(id, nbOutputs, inputs)
 }
 
 func (builder *builder) newHintForId(id solver.HintID, nbOutputs int, inputs []frontend.Variable) ([]frontend.Variable, error) {
+	if nbOutputs <= 0 {
+		return nil, errors.New("hint function must return at least one output")
+	}
 	hintInputs := builder.toVariableIds(inputs...)
 
-	builder.instructions = append(builder.instructions,
-		irsource.Instruction{
-			Type:       irsource.Hint,
-			ExtraId:    uint64(id),
-			Inputs:     hintInputs,
-			NumOutputs: nbOutputs,
-		},
+	builder.addInstruction(irsource.Instruction{
+		Type:       irsource.Hint,
+		ExtraId:    uint64(id),
+		Inputs:     hintInputs,
+		NumOutputs: nbOutputs,
+	},
 	)
 
 	res := make([]frontend.Variable, nbOutputs)
@@ -224,15 +291,19 @@ func (builder *builder) newHintForId(id solver.HintID, nbOutputs int, inputs []f
 	return res, nil
 }
 
+// CustomGate emits a custom gate of the given type, which must be registered with customgates.
+// It panics if the gate was registered with an arity different from the number of inputs.
 func (builder *builder) CustomGate(gateType uint64, inputs ...frontend.Variable) frontend.Variable {
+	if err := customgates.CheckArity(gateType, len(inputs)); err != nil {
+		panic(err)
+	}
 	hintInputs := builder.toVariableIds(inputs...)
 
-	builder.instructions = append(builder.instructions,
-		irsource.Instruction{
-			Type:    irsource.CustomGate,
-			ExtraId: gateType,
-			Inputs:  hintInputs,
-		},
+	builder.addInstruction(irsource.Instruction{
+		Type:    irsource.CustomGate,
+		ExtraId: gateType,
+		Inputs:  hintInputs,
+	},
 	)
 	return builder.addVar()
 }
@@ -298,7 +369,7 @@ func (builder *builder) GetKeyValue(key any) any {
 // GetRandomValue returns a random value determined during the proving time.
 // The return value cannot be used in hints, since it's unknown at the input solving phase
 func (builder *builder) GetRandomValue() frontend.Variable {
-	builder.instructions = append(builder.instructions, irsource.Instruction{
+	builder.addInstruction(irsource.Instruction{
 		Type:    irsource.ConstantLike,
 		ExtraId: 1,
 	})
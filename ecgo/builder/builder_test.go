@@ -0,0 +1,328 @@
+package builder
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/babybear"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/bn254"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/schema"
+	"github.com/consensys/gnark/std/multicommit"
+)
+
+func TestConstantValue(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	n := len(root.instructions)
+	if c, ok := root.ConstantValue(5); !ok || c.Int64() != 5 {
+		t.Fatal("expected a Go constant to be constant")
+	}
+	if len(root.instructions) != n {
+		t.Fatal("ConstantValue of a Go constant allocated a variable")
+	}
+	if c, ok := root.ConstantValue(root.Add(root.Mul(3, 4), 1)); !ok || c.Int64() != 13 {
+		t.Fatal("expected arithmetic on constants to be constant")
+	}
+	if _, ok := root.ConstantValue(root.Add(x, 1)); ok {
+		t.Fatal("expected a secret variable not to be constant")
+	}
+
+	b := root.ToBinary(6, 4)
+	for i, e := range []int64{0, 1, 1, 0} {
+		if c, ok := root.ConstantValue(b[i]); !ok || c.Int64() != e {
+			t.Fatalf("unexpected bit %d of a constant", i)
+		}
+	}
+}
+
+func TestIsBoolean(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	y := root.SecretVariable(schema.LeafInfo{})
+	if !root.IsBoolean(1) || root.IsBoolean(2) || root.IsBoolean(x) {
+		t.Fatal("unexpected IsBoolean")
+	}
+	root.AssertIsBoolean(x)
+	root.AssertIsBoolean(x)
+	if !root.IsBoolean(x) || len(root.constraints) != 1 {
+		t.Fatal("expected a single boolean constraint")
+	}
+	z := root.Xor(x, y)
+	if !root.IsBoolean(z) {
+		t.Fatal("expected the result of Xor to be boolean")
+	}
+	n := len(root.constraints)
+	root.AssertIsBoolean(z)
+	if len(root.constraints) != n || root.SkippedBooleanAssertions() != 2 {
+		t.Fatalf("expected 2 skipped boolean assertions, got %d", root.SkippedBooleanAssertions())
+	}
+	root.MarkBoolean(y)
+	if !root.IsBoolean(y) {
+		t.Fatal("expected a marked variable to be boolean")
+	}
+}
+
+// BenchmarkBuildCircuit builds a circuit with b.N multiplications and equality constraints, e.g.
+// with -benchtime 10000000x for 10M constraints. The variables are allocated in the arena of the
+// root, so the allocations are those of the instructions.
+func BenchmarkBuildCircuit(b *testing.B) {
+	b.ReportAllocs()
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	root.SetSourceLocationDepth(0)
+	x := root.SecretVariable(schema.LeafInfo{})
+	y := root.SecretVariable(schema.LeafInfo{})
+	for i := 0; i < b.N; i++ {
+		z := root.Mul(x, y)
+		root.AssertIsEqual(z, root.Add(x, y))
+		x, y = y, z
+	}
+	root.Finalize()
+	root.ResetArena()
+}
+
+// BenchmarkBuildCircuitGrowth builds the circuit of BenchmarkBuildCircuit with 1M constraints per
+// iteration, to compare the allocations of the default growth, a preallocated capacity and a
+// growth factor.
+func BenchmarkBuildCircuitGrowth(b *testing.B) {
+	const n = 1 << 20
+	for _, bc := range []struct {
+		name     string
+		capacity int
+		growth   Growth
+	}{
+		{"default", 0, Growth{}},
+		{"capacity", 2 * n, Growth{}},
+		{"factor", 0, Growth{Factor: 2}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				root := NewRoot(m31.ScalarField, frontend.CompileConfig{Capacity: bc.capacity})
+				root.SetSourceLocationDepth(0)
+				root.SetGrowth(bc.growth)
+				x := root.SecretVariable(schema.LeafInfo{})
+				y := root.SecretVariable(schema.LeafInfo{})
+				for j := 0; j < n; j++ {
+					z := root.Mul(x, y)
+					root.AssertIsEqual(z, root.Add(x, y))
+					x, y = y, z
+				}
+				root.ResetArena()
+			}
+		})
+	}
+}
+
+func TestGrowth(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{Capacity: 100})
+	if cap(root.instructions) != 100 || cap(root.constraints) != 100 || cap(root.varConstId) != 101 {
+		t.Fatalf("expected a capacity of 100, got %d %d %d", cap(root.instructions), cap(root.constraints), cap(root.varConstId))
+	}
+	root.SetGrowth(Growth{Factor: 3, SubCircuits: 10})
+	if len(root.registry.m) != 1 || root.registry.m[0].builder != root.builder {
+		t.Fatal("expected the root circuit to stay registered")
+	}
+	x := root.SecretVariable(schema.LeafInfo{})
+	for i := 0; i < 101; i++ {
+		x = root.Mul(x, x)
+	}
+	if cap(root.instructions) != 300 {
+		t.Fatalf("expected the instructions to grow by 3, got a capacity of %d", cap(root.instructions))
+	}
+	if s := reserve(make([]int, 1), 1.1); cap(s) != 2 {
+		t.Fatalf("expected a small slice to grow by at least one element, got a capacity of %d", cap(s))
+	}
+}
+
+func TestTag(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	root.Output(x)
+	root.Tag(root.Mul(x, x), "square")
+	root.Tag(x, "x")
+	tags := root.Tags()
+	if len(tags) != 2 || tags[0] != (OutputTag{Name: "square", Output: 1}) || tags[1] != (OutputTag{Name: "x", Output: 2}) {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	if len(root.output) != 3 {
+		t.Fatal("expected the tagged variables to be outputs")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a duplicate tag to panic")
+		}
+	}()
+	root.Tag(x, "x")
+}
+
+func TestChallenge(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	y := root.SecretVariable(schema.LeafInfo{})
+	alpha := root.Challenge("alpha")
+	beta := root.Challenge("beta")
+	if root.Challenge("alpha") != alpha || alpha == beta {
+		t.Fatal("expected a challenge per name")
+	}
+	if names := root.Challenges(); len(names) != 2 || names[0] != "alpha" || names[1] != "beta" {
+		t.Fatalf("unexpected challenges %v", names)
+	}
+	root.AssertIsEqual(root.Mul(x, alpha), root.Mul(y, alpha))
+	root.Output(beta)
+	rc := root.Finalize()
+	nbRandom := 0
+	for _, in := range rc.Circuits[0].Instructions {
+		if in.Type == irsource.ConstantLike && in.ExtraId == 1 {
+			nbRandom++
+		}
+	}
+	if nbRandom != 2 {
+		t.Fatalf("expected 2 random values, got %d", nbRandom)
+	}
+	if err := evalRoot(rc, bigInts(3, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := evalRoot(rc, bigInts(3, 4)); err == nil {
+		t.Fatal("expected different inputs to be rejected")
+	}
+}
+
+// assertPermutation asserts that b is a permutation of a with the product argument of a gnark
+// commitment: prod(c - a_i) = prod(c - b_i).
+func assertPermutation(api frontend.API, a, b []frontend.Variable) {
+	multicommit.WithCommitment(api, func(api frontend.API, c frontend.Variable) error {
+		pa, pb := frontend.Variable(1), frontend.Variable(1)
+		for i := range a {
+			pa = api.Mul(pa, api.Sub(c, a[i]))
+			pb = api.Mul(pb, api.Sub(c, b[i]))
+		}
+		api.AssertIsEqual(pa, pb)
+		return nil
+	}, append(a, b...)...)
+}
+
+func TestCommit(t *testing.T) {
+	root := NewRoot(bn254.ScalarField, frontend.CompileConfig{})
+	x := make([]frontend.Variable, 6)
+	for i := range x {
+		x[i] = root.SecretVariable(schema.LeafInfo{})
+	}
+	assertPermutation(root, x[:2], x[2:4])
+	assertPermutation(root, x[2:4], x[4:])
+	rc := root.Finalize()
+	if names := root.Challenges(); len(names) != 1 || names[0] != "commitment/0" {
+		t.Fatalf("unexpected challenges %v", names)
+	}
+	if err := evalRoot(rc, bigInts(1, 2, 2, 1, 1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := evalRoot(rc, bigInts(1, 2, 2, 1, 1, 3)); err == nil {
+		t.Fatal("expected a non-permutation to be rejected")
+	}
+
+	// a base field commitment is unsound in fields with a challenge extension
+	for _, f := range []*big.Int{m31.ScalarField, babybear.ScalarField} {
+		root := NewRoot(f, frontend.CompileConfig{})
+		if _, err := root.Commit(root.SecretVariable(schema.LeafInfo{})); err == nil {
+			t.Fatalf("expected Commit to fail over the field of order %v", f)
+		}
+		if len(root.Challenges()) != 0 {
+			t.Fatal("expected a failed Commit to draw no challenge")
+		}
+	}
+}
+
+func challengeInSubCircuit(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	return []frontend.Variable{api.Mul(input[0], api.(API).Challenge("alpha"))}
+}
+
+func TestChallengeInSubCircuit(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a challenge drawn in a subcircuit to panic")
+		}
+	}()
+	root.MemorizedSimpleCall(challengeInSubCircuit, []frontend.Variable{x})
+}
+
+func TestGnarkExpressionRejected(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	other, err := r1cs.NewBuilder(ecc.BN254.ScalarField(), frontend.CompileConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := other.Add(other.InternalVariable(1), other.InternalVariable(2))
+	defer func() {
+		if err, ok := recover().(error); !ok || !errors.Is(err, ErrNonQuadratic) {
+			t.Fatalf("expected ErrNonQuadratic, got %v", err)
+		}
+	}()
+	root.Mul(e, 2)
+}
+
+func TestLeadingPublicVariable(t *testing.T) {
+	build := func(leading bool) []byte {
+		root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+		var p [2]frontend.Variable
+		if leading {
+			p[0] = root.LeadingPublicVariable(schema.LeafInfo{})
+			p[1] = root.LeadingPublicVariable(schema.LeafInfo{})
+		}
+		x := root.SecretVariable(schema.LeafInfo{})
+		y := root.SecretVariable(schema.LeafInfo{})
+		if leading {
+			root.SetLeadingPublicSlots([]int{1, 0})
+		} else {
+			p[0] = root.PublicVariableAt(schema.LeafInfo{}, 1)
+			p[1] = root.PublicVariableAt(schema.LeafInfo{}, 0)
+		}
+		root.AssertIsEqual(root.Mul(x, p[1]), root.Add(p[0], y))
+		root.Output(root.Mul(p[0], x))
+		return irsource.SerializeRootCircuit(root.Finalize())
+	}
+	if !bytes.Equal(build(true), build(false)) {
+		t.Fatal("expected the leading public variables to be moved after the secret variables")
+	}
+}
+
+func TestToBinary(t *testing.T) {
+	for _, c := range []struct {
+		x  int64
+		ok bool
+	}{{13, true}, {255, true}, {256, false}} {
+		root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+		x := root.SecretVariable(schema.LeafInfo{})
+		linCombs := func() int {
+			n := 0
+			for _, in := range root.instructions {
+				if in.Type == irsource.LinComb {
+					n++
+				}
+			}
+			return n
+		}
+		b := root.ToBinary(x, 8)
+		// the recomposition, and the difference asserted to be zero
+		if linCombs() != 2 || len(root.constraints) != 9 {
+			t.Fatalf("unexpected %d linear combinations and %d constraints", linCombs(), len(root.constraints))
+		}
+		y := root.FromBinary(b...)
+		if linCombs() != 3 || len(root.constraints) != 9 {
+			t.Fatal("expected FromBinary of bits to be a single linear combination")
+		}
+		root.AssertIsEqual(y, x)
+		err := evalRoot(root.Finalize(), []*big.Int{big.NewInt(c.x)})
+		if (err == nil) != c.ok {
+			t.Fatalf("decomposition of %d on 8 bits: unexpected result %v", c.x, err)
+		}
+	}
+}
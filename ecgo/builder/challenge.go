@@ -0,0 +1,46 @@
+package builder
+
+import (
+	"github.com/consensys/gnark/frontend"
+)
+
+// Challenge returns the Fiat-Shamir challenge named name, the same variable every time it's
+// requested. See Root.Challenges.
+//
+// The protocol contract is the one of random coefficients in the layered circuit: the prover
+// commits the whole input layer, private and public inputs, then derives every challenge from
+// the transcript, so a challenge is uniform over the base field and independent of the other
+// ones, and no input can depend on it. In particular it's unknown while the inputs are solved: it
+// must not be passed to hints (the witness solver rejects it), and gadgets should accumulate
+// fractions rather than invert expressions of challenges, as the LogUp argument of NewTable does.
+//
+// An argument checking that a polynomial of degree n in a challenge vanishes, like a random
+// linear combination of n terms, is unsound with probability up to n/|F|: negligible over BN254
+// and BLS12-381, but about n*2^-64 over Goldilocks, n*2^-31 over M31 and BabyBear, and useless
+// over GF2. Fields implementing field.Extension are too small for a single challenge: draw
+// field.ChallengeExtension challenges as the coefficients of an extension element, as NewTable
+// and AssertRowPermutation do.
+//
+// Challenges can only be drawn by the root circuit, since a challenge drawn in a subcircuit would
+// be a different value in every call. They're passed to subcircuits as inputs.
+func (builder *builder) Challenge(name string) frontend.Variable {
+	if builder.root.builder != builder {
+		panic("Challenge can only be called on root circuit")
+	}
+	if x, ok := builder.root.challenges[name]; ok {
+		return x
+	}
+	x := builder.GetRandomValue()
+	if builder.root.challenges == nil {
+		builder.root.challenges = make(map[string]frontend.Variable)
+	}
+	builder.root.challenges[name] = x
+	builder.root.challengeNames = append(builder.root.challengeNames, name)
+	return x
+}
+
+// Challenges returns the names of the challenges drawn by Challenge, in the order of their first
+// request.
+func (r *Root) Challenges() []string {
+	return r.challengeNames
+}
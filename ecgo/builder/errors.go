@@ -0,0 +1,32 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+)
+
+// Errors reported by the builder when a circuit misuses it. The builder panics with errors
+// wrapping them, since the frontend.API has no error results, and Compile returns them, see
+// IsUsageError. Other panics of the builder are reserved for its internal invariants.
+var (
+	// ErrUnsupportedField reports a circuit over a field the compiler doesn't support.
+	ErrUnsupportedField = field.ErrUnsupportedField
+	// ErrNonQuadratic reports a frontend.Variable which the builder can't lower to its quadratic
+	// instructions, such as a gnark expression of several terms built by another gnark builder.
+	ErrNonQuadratic = errors.New("expression isn't a variable of the builder")
+	// ErrInvalidSubCircuit reports a subcircuit function or call which doesn't meet the
+	// requirements of MemorizedCall, MemorizedSimpleCall or MemorizedCallN.
+	ErrInvalidSubCircuit = errors.New("invalid subcircuit")
+)
+
+// IsUsageError reports whether err is a misuse of the builder, one of the errors above.
+func IsUsageError(err error) bool {
+	return errors.Is(err, ErrUnsupportedField) || errors.Is(err, ErrNonQuadratic) || errors.Is(err, ErrInvalidSubCircuit)
+}
+
+// invalidSubCircuit returns an ErrInvalidSubCircuit with the given message
+func invalidSubCircuit(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidSubCircuit, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,142 @@
+package builder
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/customgates"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/constraint/solver"
+)
+
+// evalRoot solves and checks a finalized circuit for the given secret inputs, with random
+// values drawn from a fixed seed. It returns an error if a hint fails or a constraint isn't met.
+func evalRoot(rc *irsource.RootCircuit, inputs []*big.Int) error {
+	e := &evaluator{rc: rc, rnd: rand.New(rand.NewSource(1))}
+	values := make([]constraint.Element, len(inputs))
+	for i, x := range inputs {
+		values[i] = rc.Field.FromInterface(x)
+	}
+	_, err := e.eval(0, values)
+	return err
+}
+
+type evaluator struct {
+	rc  *irsource.RootCircuit
+	rnd *rand.Rand
+}
+
+func (e *evaluator) eval(id uint64, inputs []constraint.Element) ([]constraint.Element, error) {
+	f := e.rc.Field
+	c := e.rc.Circuits[id]
+	values := append([]constraint.Element{{}}, inputs...)
+	isBool := func(x constraint.Element) bool {
+		return x.IsZero() || f.IsOne(x)
+	}
+	for i, in := range c.Instructions {
+		switch in.Type {
+		case irsource.LinComb:
+			r := in.Const
+			for j, x := range in.Inputs {
+				r = f.Add(r, f.Mul(in.LinCombCoef[j], values[x]))
+			}
+			values = append(values, r)
+		case irsource.Mul:
+			r := f.One()
+			for _, x := range in.Inputs {
+				r = f.Mul(r, values[x])
+			}
+			values = append(values, r)
+		case irsource.Div:
+			inv, ok := f.Inverse(values[in.Y])
+			if !ok && in.ExtraId == 0 {
+				return nil, fmt.Errorf("instruction %d: division by zero", i)
+			}
+			values = append(values, f.Mul(values[in.X], inv))
+		case irsource.BoolBinOp:
+			x, y := values[in.X], values[in.Y]
+			if !isBool(x) || !isBool(y) {
+				return nil, fmt.Errorf("instruction %d: operand is not boolean", i)
+			}
+			r := f.Add(x, y)
+			switch in.ExtraId {
+			case 1:
+				r = f.Sub(r, f.Mul(f.FromInterface(2), f.Mul(x, y)))
+			case 2:
+				r = f.Sub(r, f.Mul(x, y))
+			case 3:
+				r = f.Mul(x, y)
+			}
+			values = append(values, r)
+		case irsource.IsZero:
+			if values[in.X].IsZero() {
+				values = append(values, f.One())
+			} else {
+				values = append(values, f.Zero())
+			}
+		case irsource.Hint:
+			hintIn := make([]*big.Int, len(in.Inputs))
+			for j, x := range in.Inputs {
+				hintIn[j] = f.ToBigInt(values[x])
+			}
+			hintOut := make([]*big.Int, in.NumOutputs)
+			for j := range hintOut {
+				hintOut[j] = new(big.Int)
+			}
+			hint := solver.GetRegisteredHint(solver.HintID(in.ExtraId))
+			if hint == nil {
+				return nil, fmt.Errorf("instruction %d: unknown hint", i)
+			}
+			if err := hint(f.Field(), hintIn, hintOut); err != nil {
+				return nil, fmt.Errorf("instruction %d: %w", i, err)
+			}
+			for _, x := range hintOut {
+				values = append(values, f.FromInterface(x))
+			}
+		case irsource.ConstantLike:
+			if in.ExtraId == 1 {
+				values = append(values, f.FromInterface(new(big.Int).Rand(e.rnd, f.Field())))
+			} else {
+				values = append(values, in.Const)
+			}
+		case irsource.SubCircuitCall:
+			subIn := []constraint.Element{}
+			for _, x := range in.Inputs {
+				subIn = append(subIn, values[x])
+			}
+			out, err := e.eval(in.ExtraId, subIn)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, out...)
+		case irsource.CustomGate:
+			gateIn := make([]*big.Int, len(in.Inputs))
+			for j, x := range in.Inputs {
+				gateIn[j] = f.ToBigInt(values[x])
+			}
+			gateOut := []*big.Int{new(big.Int)}
+			if err := customgates.GetFunc(in.ExtraId)(f.Field(), gateIn, gateOut); err != nil {
+				return nil, err
+			}
+			values = append(values, f.FromInterface(gateOut[0]))
+		default:
+			return nil, fmt.Errorf("instruction %d: unsupported type %d", i, in.Type)
+		}
+	}
+	for _, con := range c.Constraints {
+		x := values[con.Var]
+		switch {
+		case con.Typ == irsource.Zero && !x.IsZero(),
+			con.Typ == irsource.NonZero && x.IsZero(),
+			con.Typ == irsource.Bool && !isBool(x):
+			return nil, fmt.Errorf("circuit %d: constraint of type %d on variable %d isn't met", id, con.Typ, con.Var)
+		}
+	}
+	res := []constraint.Element{}
+	for _, x := range c.Outputs {
+		res = append(res, values[x])
+	}
+	return res, nil
+}
@@ -0,0 +1,81 @@
+package builder
+
+import (
+	"github.com/consensys/gnark/frontend"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+)
+
+// extVar is an element of the extension in which the challenges of the builder's arguments are
+// sampled (see field.Extension), as its coefficients over the base field. In fields without an
+// extension, it has a single coefficient and its arithmetic is the base field arithmetic.
+type extVar []frontend.Variable
+
+// extRandom returns a random extension element, sampled during the proving time.
+func (builder *builder) extRandom() extVar {
+	d, _ := field.ChallengeExtension(builder.field)
+	res := make(extVar, d)
+	for i := range res {
+		res[i] = builder.GetRandomValue()
+	}
+	return res
+}
+
+// extFromBase embeds a base field element in the extension.
+func (builder *builder) extFromBase(x frontend.Variable) extVar {
+	d, _ := field.ChallengeExtension(builder.field)
+	res := make(extVar, d)
+	res[0] = x
+	for i := 1; i < d; i++ {
+		res[i] = 0
+	}
+	return res
+}
+
+func (builder *builder) extAdd(a, b extVar) extVar {
+	res := make(extVar, len(a))
+	for i := range a {
+		res[i] = builder.Add(a[i], b[i])
+	}
+	return res
+}
+
+func (builder *builder) extSub(a, b extVar) extVar {
+	res := make(extVar, len(a))
+	for i := range a {
+		res[i] = builder.Sub(a[i], b[i])
+	}
+	return res
+}
+
+// extMul multiplies two extension elements modulo x^d - w.
+func (builder *builder) extMul(a, b extVar) extVar {
+	d, w := field.ChallengeExtension(builder.field)
+	if d == 1 {
+		return extVar{builder.Mul(a[0], b[0])}
+	}
+	terms := make([][]frontend.Variable, d)
+	for i := range a {
+		for j := range b {
+			k := i + j
+			p := builder.Mul(a[i], b[j])
+			if k >= d {
+				k -= d
+				p = builder.Mul(p, w)
+			}
+			terms[k] = append(terms[k], p)
+		}
+	}
+	res := make(extVar, d)
+	for k, t := range terms {
+		res[k] = builder.Add(t[0], t[1], t[2:]...)
+	}
+	return res
+}
+
+// extAssertIsEqual asserts that two extension elements are equal, coefficient by coefficient.
+func (builder *builder) extAssertIsEqual(a, b extVar) {
+	for i := range a {
+		builder.AssertIsEqual(a[i], b[i])
+	}
+}
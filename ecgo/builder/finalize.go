@@ -2,22 +2,77 @@ package builder
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
 )
 
 // Finalize processes deferred functions, converts boolean and nonzero assertions to zero assertions,
-// and adds public variables to the output.
+// and adds public variables to the output. The source locations of the instructions and
+// constraints are resolved into the Locations of the result.
 func (r *Root) Finalize() *irsource.RootCircuit {
+	// deferred functions may register new subcircuits, so finalize until none is left, in
+	// increasing id order to keep the result deterministic
 	res := make(map[uint64]*irsource.Circuit)
-	for x, b := range r.registry.m {
-		res[x] = b.builder.Finalize()
+	for len(res) < len(r.registry.m) {
+		ids := []uint64{}
+		for x := range r.registry.m {
+			if _, ok := res[x]; !ok {
+				ids = append(ids, x)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, x := range ids {
+			res[x] = r.registry.m[x].builder.Finalize()
+		}
+	}
+	if len(r.leadingPublicSlots) != 0 {
+		r.moveLeadingPublicVariables(res[0])
 	}
 	return &irsource.RootCircuit{
 		NumPublicInputs:         r.nbPublicInputs,
 		ExpectedNumOutputZeroes: 0,
 		Circuits:                res,
 		Field:                   r.field,
+		Locations:               r.locations.sourceLocations(),
+	}
+}
+
+// moveLeadingPublicVariables turns the inputs allocated by LeadingPublicVariable into public
+// inputs read by the first instructions, and the secret variables into the first inputs: with p
+// leading public variables and s secret ones, input i <= p becomes variable s+i, and input p+i
+// becomes input i. The other variables keep their ids, so the circuit is the same as if the
+// secret variables had been created first.
+func (r *Root) moveLeadingPublicVariables(c *irsource.Circuit) {
+	p := len(r.leadingPublicSlots)
+	s := c.NumInputs - p
+	remap := func(x int) int {
+		if x == 0 || x > p+s {
+			return x
+		} else if x <= p {
+			return s + x
+		}
+		return x - p
+	}
+	insns := make([]irsource.Instruction, 0, p+len(c.Instructions))
+	for _, slot := range r.leadingPublicSlots {
+		insns = append(insns, irsource.Instruction{Type: irsource.ConstantLike, ExtraId: 2 + uint64(slot)})
+	}
+	for i := range c.Instructions {
+		insns = append(insns, c.Instructions[i].MapOperands(remap))
+	}
+	c.Instructions = insns
+	c.NumInputs = s
+	for i := range c.Constraints {
+		c.Constraints[i].Var = remap(c.Constraints[i].Var)
+	}
+	for i, x := range c.Outputs {
+		c.Outputs[i] = remap(x)
+	}
+	for _, o := range r.builder.origins {
+		for i := range o.Operands {
+			o.Operands[i].Var = remap(o.Operands[i].Var)
+		}
 	}
 }
 
@@ -30,6 +85,11 @@ func (builder *builder) Finalize() *irsource.Circuit {
 			panic(fmt.Sprintf("deferred function failed: %v", err))
 		}
 	}
+	for _, t := range builder.tables {
+		if err := t.finalize(); err != nil {
+			panic(fmt.Sprintf("lookup table check failed: %v", err))
+		}
+	}
 
 	return &irsource.Circuit{
 		Instructions: builder.instructions,
@@ -0,0 +1,77 @@
+package builder
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/frontend"
+)
+
+// Fork returns a new root for another circuit over the field of r, e.g. the same circuit with
+// other compile-time parameters, whose registry holds copies of the subcircuits built by r, so
+// that the gadgets memorized by both circuits are only built once. The subcircuits with deferred
+// functions or lookup tables, and their callers, are built again, since their bodies are only
+// final once r is finalized. The copies are independent of r, whose circuit may be finalized and
+// optimized afterwards. Fork can't be called while a subcircuit is being built.
+func (r *Root) Fork(config frontend.CompileConfig) *Root {
+	if len(r.registry.building) != 0 {
+		panic("Fork: subcircuits are being built")
+	}
+	res := NewRoot(r.field.Field(), config)
+	res.locations.ids = maps.Clone(r.locations.ids)
+	res.locations.stacks = slices.Clone(r.locations.stacks)
+	sr, other := res.registry, r.registry
+	// the callees of a subcircuit are registered before it
+	forked := map[uint64]bool{}
+	for _, id := range other.order {
+		if b := other.m[id].builder.fork(res, forked); b != nil {
+			sub := *other.m[id]
+			sub.builder = b
+			sr.m[id] = &sub
+			sr.order = append(sr.order, id)
+			forked[id] = true
+		}
+	}
+	for h, id := range other.structuralHash {
+		if forked[id] {
+			sr.structuralHash[h] = id
+		}
+	}
+	for x, id := range other.alias {
+		if forked[id] {
+			sr.alias[x] = id
+		}
+	}
+	maps.Copy(sr.fullHash, other.fullHash)
+	maps.Copy(sr.outputStructure, other.outputStructure)
+	maps.Copy(sr.outputTemplate, other.outputTemplate)
+	return res
+}
+
+// fork returns a copy of the built subcircuit b for the root r, or nil if its body may still
+// change, or if it calls a subcircuit which isn't in forked
+func (b *builder) fork(r *Root, forked map[uint64]bool) *builder {
+	if len(b.defers) != 0 || len(b.tables) != 0 {
+		return nil
+	}
+	res := *b
+	res.root = r
+	res.instructions = make([]irsource.Instruction, len(b.instructions))
+	for i, in := range b.instructions {
+		if in.Type == irsource.SubCircuitCall && !forked[in.ExtraId] {
+			return nil
+		}
+		in.Inputs = slices.Clone(in.Inputs)
+		in.LinCombCoef = slices.Clone(in.LinCombCoef)
+		res.instructions[i] = in
+	}
+	res.constraints = slices.Clone(b.constraints)
+	res.origins = slices.Clone(b.origins)
+	res.output = slices.Clone(b.output)
+	res.varConstId = slices.Clone(b.varConstId)
+	res.constValues = slices.Clone(b.constValues)
+	res.booleans = maps.Clone(b.booleans)
+	res.db = make(map[any]any)
+	return &res
+}
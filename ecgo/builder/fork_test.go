@@ -0,0 +1,60 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// forkBuilds counts the builds of countedSquare
+var forkBuilds int
+
+func countedSquare(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	forkBuilds++
+	return []frontend.Variable{api.Mul(input[0], input[0])}
+}
+
+func deferredSquare(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	api.Compiler().Defer(func(api frontend.API) error { return nil })
+	return countedSquare(api, input)
+}
+
+func TestFork(t *testing.T) {
+	forkBuilds = 0
+	// squares the input n times, and checks the result
+	define := func(root *Root, n int) {
+		x := root.SecretVariable(schema.LeafInfo{})
+		y := root.SecretVariable(schema.LeafInfo{})
+		for i := 0; i < n; i++ {
+			x = root.MemorizedSimpleCall(countedSquare, []frontend.Variable{x})[0]
+		}
+		x = root.MemorizedSimpleCall(deferredSquare, []frontend.Variable{x})[0]
+		root.AssertIsEqual(x, y)
+	}
+	a := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	define(a, 1)
+	b := a.Fork(frontend.CompileConfig{})
+	define(b, 2)
+	// countedSquare is shared, deferredSquare is built again
+	if forkBuilds != 3 {
+		t.Fatalf("expected 3 builds, got %d", forkBuilds)
+	}
+	rcA, rcB := a.Finalize(), b.Finalize()
+	if len(rcA.Circuits) != 3 || len(rcB.Circuits) != 3 {
+		t.Fatalf("unexpected circuits %d and %d", len(rcA.Circuits), len(rcB.Circuits))
+	}
+	// the optimization of a doesn't change the subcircuits of b
+	for id := range rcA.Circuits {
+		for _, in := range rcA.Circuits[id].Instructions {
+			clear(in.Inputs)
+		}
+	}
+	if err := evalRoot(rcB, bigInts(3, 6561)); err != nil {
+		t.Fatal(err)
+	}
+	if err := evalRoot(rcB, bigInts(3, 81)); err == nil {
+		t.Fatal("expected the assertion to fail")
+	}
+}
@@ -0,0 +1,120 @@
+package builder
+
+import (
+	"math/big"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+)
+
+func init() {
+	solver.RegisterHint(BatchInvertHint)
+}
+
+var _ frontend.BatchInverter = &builder{}
+
+// MulAcc returns a + b*c. If b or c is a constant, it's a single linear combination of a and the
+// other operand, so that scaled accumulations, like the matrix-vector products of linear algebra
+// with a constant matrix, grow by one instruction per term. Otherwise, it's a multiplication, and
+// an addition unless a is zero.
+func (builder *builder) MulAcc(a, b, c frontend.Variable) frontend.Variable {
+	vars := builder.toVariableIds(a, b, c)
+	x, y, z := vars[0], vars[1], vars[2]
+	cy, okY := builder.constantValue(y)
+	cz, okZ := builder.constantValue(z)
+	if okZ && !okY {
+		y, z, cy, cz, okY, okZ = z, y, cz, cy, okZ, okY
+	}
+	if okY {
+		if okZ {
+			return builder.Add(a, builder.toVariable(builder.field.Mul(cy, cz)))
+		}
+		var ids []int
+		var coef []constraint.Element
+		if cx, ok := builder.constantValue(x); ok && cx.IsZero() {
+			ids, coef = builder.allocInts(1), builder.allocElements(1)
+			ids[0], coef[0] = z, cy
+		} else {
+			ids, coef = builder.allocInts(2), builder.allocElements(2)
+			ids[0], ids[1] = x, z
+			coef[0], coef[1] = builder.tOne, cy
+		}
+		builder.addInstruction(irsource.Instruction{
+			Type:        irsource.LinComb,
+			Inputs:      ids,
+			LinCombCoef: coef,
+		})
+		return builder.addVar()
+	}
+	if cx, ok := builder.constantValue(x); ok && cx.IsZero() {
+		return builder.Mul(b, c)
+	}
+	return builder.Add(a, builder.Mul(b, c))
+}
+
+// BatchInvert returns the inverses of the elements of xs, which must be non-zero. They're
+// computed by a single hint, BatchInvertHint, with Montgomery's trick, and each is checked by a
+// multiplication, so that n inversions cost n multiplications in a single layer instead of n
+// divisions. The inverses of constants are computed at compile time.
+func (builder *builder) BatchInvert(xs []frontend.Variable) []frontend.Variable {
+	res := make([]frontend.Variable, len(xs))
+	var inputs []frontend.Variable
+	var idx []int
+	for i, x := range xs {
+		v := builder.toVariableId(x)
+		if c, ok := builder.constantValue(v); ok {
+			if c.IsZero() {
+				panic("division by zero")
+			}
+			inv, _ := builder.field.Inverse(c)
+			res[i] = builder.toVariable(inv)
+			continue
+		}
+		inputs = append(inputs, builder.newVariable(v))
+		idx = append(idx, i)
+	}
+	if len(inputs) == 0 {
+		return res
+	}
+	invs, err := builder.NewHint(BatchInvertHint, len(inputs), inputs...)
+	if err != nil {
+		panic(err)
+	}
+	products := builder.MulVec(inputs, invs)
+	for j, i := range idx {
+		builder.AssertIsEqual(products[j], 1)
+		res[i] = invs[j]
+	}
+	return res
+}
+
+// BatchInvertHint sets outputs[i] to the inverse of inputs[i] with Montgomery's trick: a single
+// modular inversion and 3(n-1) multiplications. The outputs of zero inputs are zero, so that the
+// constraints checking the inverses fail.
+func BatchInvertHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	// prefix products of the non-zero inputs
+	acc := big.NewInt(1)
+	prefix := make([]*big.Int, len(inputs))
+	for i, x := range inputs {
+		prefix[i] = new(big.Int).Set(acc)
+		if x.Sign() != 0 {
+			acc.Mul(acc, x).Mod(acc, field)
+		}
+	}
+	inv := new(big.Int).ModInverse(acc, field)
+	if inv == nil {
+		// an input is a non-zero multiple of the modulus
+		inv = big.NewInt(0)
+	}
+	for i := len(inputs) - 1; i >= 0; i-- {
+		if inputs[i].Sign() == 0 {
+			outputs[i].SetInt64(0)
+			continue
+		}
+		outputs[i].Mul(inv, prefix[i]).Mod(outputs[i], field)
+		inv.Mul(inv, inputs[i]).Mod(inv, field)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func TestMulAcc(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	y := root.SecretVariable(schema.LeafInfo{})
+	// 3*x + 5*y as a chain of scaled accumulations, one linear combination per term
+	acc := root.MulAcc(0, 3, x)
+	acc = root.MulAcc(acc, y, 5)
+	root.AssertIsEqual(acc, 3*2+5*7)
+	root.AssertIsEqual(root.MulAcc(x, x, y), 2+2*7)
+	if c, ok := root.ConstantValue(root.MulAcc(1, 2, 3)); !ok || c.Int64() != 7 {
+		t.Fatalf("MulAcc of constants: expected 7, got %v", c)
+	}
+	rc := root.Finalize()
+	nbMul, nbLinComb := 0, 0
+	for _, in := range rc.Circuits[0].Instructions {
+		switch in.Type {
+		case irsource.Mul:
+			nbMul++
+		case irsource.LinComb:
+			nbLinComb++
+		}
+	}
+	// the two terms of the chain, x+x*y and the two assertions, and the only product x*y
+	if nbMul != 1 || nbLinComb != 5 {
+		t.Fatalf("expected 1 product and 5 sums, got %d products and %d sums", nbMul, nbLinComb)
+	}
+	if err := evalRoot(rc, bigInts(2, 7)); err != nil {
+		t.Fatal(err)
+	}
+	if err := evalRoot(rc, bigInts(2, 8)); err == nil {
+		t.Fatal("expected an error for a wrong witness")
+	}
+}
+
+func TestBatchInvert(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	xs := make([]frontend.Variable, 3)
+	for i := range xs {
+		xs[i] = root.SecretVariable(schema.LeafInfo{})
+	}
+	invs := root.BatchInvert(append(xs, 2))
+	for i, inv := range invs {
+		root.AssertIsEqual(root.Mul(inv, append(xs, 2)[i]), 1)
+	}
+	if c, ok := root.ConstantValue(invs[3]); !ok || c.Int64() != (1<<31)/2 {
+		t.Fatalf("inverse of 2: expected %d, got %v", (1<<31)/2, c)
+	}
+	rc := root.Finalize()
+	nbHint := 0
+	for _, in := range rc.Circuits[0].Instructions {
+		if in.Type == irsource.Hint {
+			nbHint++
+		}
+	}
+	if nbHint != 1 {
+		t.Fatalf("expected a single hint, got %d", nbHint)
+	}
+	if err := evalRoot(rc, bigInts(3, 5, 1<<30)); err != nil {
+		t.Fatal(err)
+	}
+	if err := evalRoot(rc, bigInts(3, 0, 7)); err == nil {
+		t.Fatal("expected an error for the inverse of zero")
+	}
+}
+
+func TestBatchInvertHint(t *testing.T) {
+	inputs := bigInts(3, 0, 5, 1)
+	outputs := bigInts(0, 0, 0, 0)
+	if err := BatchInvertHint(m31.ScalarField, inputs, outputs); err != nil {
+		t.Fatal(err)
+	}
+	for i, x := range inputs {
+		if x.Sign() == 0 {
+			if outputs[i].Sign() != 0 {
+				t.Fatalf("output %d: expected 0 for a zero input, got %v", i, outputs[i])
+			}
+			continue
+		}
+		p := x.Int64() * outputs[i].Int64() % ((1 << 31) - 1)
+		if p != 1 {
+			t.Fatalf("output %d: %v is not the inverse of %v", i, outputs[i], x)
+		}
+	}
+}
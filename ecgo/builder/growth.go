@@ -0,0 +1,49 @@
+package builder
+
+import "maps"
+
+// Growth is the strategy with which the builders grow their instructions, constraints and
+// variables, see SetGrowth. The zero value keeps the growth of append.
+type Growth struct {
+	// Factor multiplies the capacity of a full slice when it grows, e.g. 2 to double it. Values
+	// up to 1 keep the growth of append, which tapers to 1.25 for large slices.
+	Factor float64
+	// SubCircuitCapacity is the number of instructions, constraints and variables preallocated
+	// by the builder of each subcircuit.
+	SubCircuitCapacity int
+	// SubCircuits is the expected number of distinct subcircuits, preallocated in the registry.
+	SubCircuits int
+}
+
+// SetGrowth sets the growth strategy of the builders created afterwards, and of the root builder
+// from its next growth on. The capacity of the root builder is set by frontend.CompileConfig.Capacity,
+// see NewRoot.
+func (r *Root) SetGrowth(g Growth) {
+	r.growth = g
+	if g.SubCircuits > len(r.registry.m) {
+		registry := newSubCircuitRegistry(g.SubCircuits)
+		maps.Copy(registry.m, r.registry.m)
+		maps.Copy(registry.outputStructure, r.registry.outputStructure)
+		maps.Copy(registry.outputTemplate, r.registry.outputTemplate)
+		maps.Copy(registry.fullHash, r.registry.fullHash)
+		maps.Copy(registry.structuralHash, r.registry.structuralHash)
+		maps.Copy(registry.alias, r.registry.alias)
+		registry.order = append(registry.order, r.registry.order...)
+		registry.building = r.registry.building
+		r.registry = registry
+	}
+}
+
+// reserve makes room for one more element in s, growing it by factor if it's full
+func reserve[T any](s []T, factor float64) []T {
+	if len(s) < cap(s) || factor <= 1 {
+		return s
+	}
+	n := int(float64(cap(s)) * factor)
+	if n <= cap(s) {
+		n = cap(s) + 1
+	}
+	res := make([]T, len(s), n)
+	copy(res, s)
+	return res
+}
@@ -0,0 +1,84 @@
+package builder
+
+import "github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+
+// Hooks observe the activity of the builders while a circuit is defined, so that external tools,
+// e.g. coverage tools, constraint auditors or linters of under-constrained variables, can follow
+// the build without forking this package, see Root.SetHooks. The hooks are called synchronously,
+// must not call the API, and may be nil.
+type Hooks struct {
+	// VariableCreated is called when a variable is created, by an instruction or as an input of
+	// the root circuit. The inputs of the subcircuits are their variables 1 to their number of
+	// inputs, and aren't reported.
+	VariableCreated func(VariableEvent)
+	// ConstraintAdded is called when a constraint is added, including the ones added by the
+	// deferred functions when the circuit is finalized.
+	ConstraintAdded func(ConstraintEvent)
+}
+
+// VariableEvent describes a variable reported to Hooks.VariableCreated.
+type VariableEvent struct {
+	// SubCircuit is the id of the subcircuit being built, 0 for the root circuit.
+	SubCircuit uint64
+	// Var is the id of the variable in its circuit, as in the IR.
+	Var int
+	// Input is whether the variable is a secret or leading public input of the root circuit.
+	Input bool
+
+	root *Root
+	loc  uint32
+}
+
+// Location returns the call stack which created the variable, as recorded for the instructions,
+// see Root.SetSourceLocationDepth.
+func (e *VariableEvent) Location() irsource.SourceLocation {
+	return e.root.resolvedLocation(e.loc)
+}
+
+// ConstraintEvent describes a constraint reported to Hooks.ConstraintAdded.
+type ConstraintEvent struct {
+	// SubCircuit is the id of the subcircuit being built, 0 for the root circuit.
+	SubCircuit uint64
+	// Constraint is the constraint, on a variable of the circuit SubCircuit.
+	Constraint irsource.Constraint
+	// Origin is the assertion which added the constraint, without its location, see Location.
+	Origin ConstraintOrigin
+
+	root *Root
+}
+
+// Location returns the call stack of the assertion which added the constraint.
+func (e *ConstraintEvent) Location() irsource.SourceLocation {
+	return e.root.resolvedLocation(e.Constraint.Loc)
+}
+
+// SetHooks sets the hooks called by the builders afterwards, replacing the previous ones.
+func (r *Root) SetHooks(h Hooks) {
+	r.hooks = h
+}
+
+// building returns the id of the subcircuit being built, 0 for the root circuit
+func (r *Root) building() uint64 {
+	if b := r.registry.building; len(b) != 0 {
+		return b[len(b)-1].id
+	}
+	return 0
+}
+
+// variableCreated reports the variable v to the hooks
+func (builder *builder) variableCreated(v int) {
+	r := builder.root
+	r.hooks.VariableCreated(VariableEvent{
+		SubCircuit: r.building(),
+		Var:        v,
+		Input:      v <= builder.nbExternalInput,
+		root:       r,
+		loc:        builder.captureLocation(),
+	})
+}
+
+// constraintAdded reports the constraint c with its origin o to the hooks
+func (builder *builder) constraintAdded(c irsource.Constraint, o ConstraintOrigin) {
+	r := builder.root
+	r.hooks.ConstraintAdded(ConstraintEvent{SubCircuit: r.building(), Constraint: c, Origin: o, root: r})
+}
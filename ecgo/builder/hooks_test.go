@@ -0,0 +1,55 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func TestHooks(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	var vars []VariableEvent
+	var cons []ConstraintEvent
+	root.SetHooks(Hooks{
+		VariableCreated: func(e VariableEvent) { vars = append(vars, e) },
+		ConstraintAdded: func(e ConstraintEvent) { cons = append(cons, e) },
+	})
+	x := root.SecretVariable(schema.LeafInfo{})
+	y := root.SecretVariable(schema.LeafInfo{})
+	z := root.MemorizedSimpleCall(func(api frontend.API, input []frontend.Variable) []frontend.Variable {
+		api.AssertIsBoolean(input[0])
+		return []frontend.Variable{api.Mul(input[0], input[1])}
+	}, []frontend.Variable{x, y})[0]
+	root.AssertIsEqual(z, 1)
+
+	if len(vars) < 4 || !vars[0].Input || !vars[1].Input || vars[2].Input {
+		t.Fatalf("unexpected variables %+v", vars)
+	}
+	var sub uint64
+	for _, e := range vars {
+		if e.SubCircuit != 0 {
+			sub = e.SubCircuit
+			if e.Var <= 2 {
+				t.Fatalf("the inputs of the subcircuit are reported: %+v", e)
+			}
+		}
+	}
+	if sub == 0 {
+		t.Fatal("expected a variable of the subcircuit")
+	}
+	if len(cons) != 2 {
+		t.Fatalf("expected 2 constraints, got %d", len(cons))
+	}
+	if cons[0].SubCircuit != sub || cons[0].Origin.Api != "AssertIsBoolean" || cons[0].Constraint.Var != 1 {
+		t.Fatalf("unexpected constraint of the subcircuit %+v", cons[0])
+	}
+	if cons[1].SubCircuit != 0 || cons[1].Origin.Api != "AssertIsEqual" {
+		t.Fatalf("unexpected constraint of the root circuit %+v", cons[1])
+	}
+	if loc := cons[1].Location(); len(loc) == 0 || !strings.HasSuffix(loc[0].File, "hooks_test.go") {
+		t.Fatalf("unexpected location %v", loc)
+	}
+}
@@ -0,0 +1,162 @@
+package builder
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+)
+
+// DefaultSourceLocationDepth is the number of frames recorded by default for each instruction
+// and constraint.
+const DefaultSourceLocationDepth = 1
+
+// MaxSourceLocationDepth is the maximum number of frames recorded for each instruction and constraint.
+const MaxSourceLocationDepth = 32
+
+// maxRawFrames also leaves room for the frames of this package, which are skipped when resolving
+const maxRawFrames = MaxSourceLocationDepth + 16
+
+type rawStack [maxRawFrames]uintptr
+
+// locations interns the call stacks of the instructions and constraints. Only the program
+// counters are captured while building: resolving them into frames is much slower, so that's
+// done once per distinct stack in Finalize.
+type locations struct {
+	depth int
+	ids   map[rawStack]uint32
+	// stacks[id-1] is the stack of id
+	stacks []rawStack
+	// if not 0, the location of everything added, see withLocation
+	override uint32
+}
+
+func newLocations() locations {
+	return locations{depth: DefaultSourceLocationDepth, ids: make(map[rawStack]uint32)}
+}
+
+// SetSourceLocationDepth sets the number of frames outside of this package recorded for each
+// instruction and constraint added afterwards, innermost first. 0 disables the recording,
+// which makes building large circuits faster.
+func (r *Root) SetSourceLocationDepth(depth int) {
+	if depth < 0 {
+		depth = 0
+	}
+	if depth > MaxSourceLocationDepth {
+		depth = MaxSourceLocationDepth
+	}
+	r.locations.depth = depth
+}
+
+// captureLocation returns the id of the current call stack, or 0 if locations are disabled
+func (builder *builder) captureLocation() uint32 {
+	l := &builder.root.locations
+	if l.override != 0 || l.depth == 0 {
+		return l.override
+	}
+	var stack rawStack
+	// skip runtime.Callers and captureLocation
+	runtime.Callers(2, stack[:])
+	return l.intern(&stack)
+}
+
+// intern returns the id of stack, adding it if it's new
+func (l *locations) intern(stack *rawStack) uint32 {
+	if id, ok := l.ids[*stack]; ok {
+		return id
+	}
+	l.stacks = append(l.stacks, *stack)
+	id := uint32(len(l.stacks))
+	l.ids[*stack] = id
+	return id
+}
+
+// merge interns the stacks of other, and returns the id in l of each id of other
+func (l *locations) merge(other *locations) []uint32 {
+	res := make([]uint32, len(other.stacks)+1)
+	for i := range other.stacks {
+		if l.override != 0 {
+			res[i+1] = l.override
+		} else {
+			res[i+1] = l.intern(&other.stacks[i])
+		}
+	}
+	return res
+}
+
+// withLocation calls f, attributing everything it adds to the location loc
+func (builder *builder) withLocation(loc uint32, f func()) {
+	l := &builder.root.locations
+	old := l.override
+	l.override = loc
+	defer func() { l.override = old }()
+	f()
+}
+
+// addInstruction appends in to the instructions, recording its source location
+func (builder *builder) addInstruction(in irsource.Instruction) {
+	in.Loc = builder.captureLocation()
+	builder.appendInstruction(in)
+}
+
+// appendInstruction appends in, whose source location is already set, to the instructions
+func (builder *builder) appendInstruction(in irsource.Instruction) {
+	builder.instructions = append(reserve(builder.instructions, builder.root.growth.Factor), in)
+	root := builder.root
+	root.nbInstructions++
+	if root.progress != nil && root.nbInstructions%ProgressInterval == 0 {
+		root.progress(root.nbInstructions)
+	}
+}
+
+// resolve returns the frames of the stack outside of this package, the tests of the package
+// being considered outside
+func (l *locations) resolve(stack *rawStack) irsource.SourceLocation {
+	n := 0
+	for n < len(stack) && stack[n] != 0 {
+		n++
+	}
+	res := irsource.SourceLocation{}
+	frames := runtime.CallersFrames(stack[:n])
+	for len(res) < l.depth {
+		f, more := frames.Next()
+		if f.Function != "" && (!strings.HasPrefix(f.Function, builderPkgPrefix) || strings.HasSuffix(f.File, "_test.go")) {
+			res = append(res, irsource.Frame{Function: f.Function, File: f.File, Line: f.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return res
+}
+
+// sourceLocations returns the locations referred to by the ids returned by captureLocation
+func (l *locations) sourceLocations() []irsource.SourceLocation {
+	res := make([]irsource.SourceLocation, len(l.stacks)+1)
+	for i := range l.stacks {
+		res[i+1] = l.resolve(&l.stacks[i])
+	}
+	return res
+}
+
+// callerOutside returns the innermost frame of the current call stack outside of this package
+func callerOutside() irsource.SourceLocation {
+	var stack rawStack
+	runtime.Callers(2, stack[:])
+	l := locations{depth: 1}
+	return l.resolve(&stack)
+}
+
+// callerSuffix returns " at file:line" for the current caller outside of this package, to make
+// build time panics easier to locate, or an empty string if locations are disabled.
+func (builder *builder) callerSuffix() string {
+	if builder.root.locations.depth == 0 {
+		return ""
+	}
+	loc := callerOutside()
+	if len(loc) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" at %s", loc)
+}
@@ -0,0 +1,104 @@
+package builder
+
+import (
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func currentLine() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+func locatedSquare(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	return []frontend.Variable{api.Mul(input[0], input[0])}
+}
+
+func TestSourceLocations(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	line := currentLine()
+	y := root.Mul(x, x)
+	root.AssertIsEqual(y, 4)
+	rc := root.Finalize()
+	c := rc.Circuits[0]
+
+	loc := rc.Location(c.Instructions[0].Loc)
+	if len(loc) != 1 || filepath.Base(loc[0].File) != "location_test.go" || loc[0].Line != line+1 {
+		t.Fatalf("unexpected location of the multiplication %v", loc)
+	}
+	if !strings.HasSuffix(loc[0].Function, ".TestSourceLocations") {
+		t.Fatalf("unexpected function %s", loc[0].Function)
+	}
+	if got := rc.Location(c.Constraints[0].Loc).String(); got != "location_test.go:"+strconv.Itoa(line+2) {
+		t.Fatalf("unexpected location of the constraint %s", got)
+	}
+	if o := root.ConstraintOrigins(0); len(o) != 1 || o[0].Location.String() != "location_test.go:"+strconv.Itoa(line+2) {
+		t.Fatalf("unexpected origins %+v", o)
+	}
+	if !strings.Contains(rc.Graphviz(0), "mul\\nlocation_test.go:"+strconv.Itoa(line+1)) {
+		t.Fatalf("the DOT export doesn't label the multiplication with its location")
+	}
+}
+
+func TestSourceLocationDepth(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	root.SetSourceLocationDepth(2)
+	x := root.SecretVariable(schema.LeafInfo{})
+	root.AssertIsBoolean(x)
+	rc := root.Finalize()
+	loc := rc.Location(rc.Circuits[0].Constraints[0].Loc)
+	if len(loc) != 2 || len(loc.Caller()) != 1 || !strings.HasPrefix(loc[1].Function, "testing.") {
+		t.Fatalf("expected the test and its caller, got %v", loc.Stack())
+	}
+
+	root = NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	root.SetSourceLocationDepth(0)
+	x = root.SecretVariable(schema.LeafInfo{})
+	root.AssertIsBoolean(root.Mul(x, x))
+	rc = root.Finalize()
+	if rc.Circuits[0].Instructions[0].Loc != 0 || rc.Circuits[0].Constraints[0].Loc != 0 || len(rc.Locations) != 1 {
+		t.Fatal("locations are recorded although they are disabled")
+	}
+	if got := rc.Location(0).String(); got != "unknown location" {
+		t.Fatalf("unexpected unknown location %s", got)
+	}
+}
+
+func TestSourceStats(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	line := currentLine()
+	a := root.MemorizedSimpleCall(locatedSquare, []frontend.Variable{x})[0]
+	b := root.MemorizedSimpleCall(locatedSquare, []frontend.Variable{a})[0]
+	root.AssertIsEqual(b, 16)
+	table := root.NewTable(1)
+	table.Insert(16)
+	table.Query(b)
+	rc := root.Finalize()
+
+	stats := map[string]irsource.SourceStat{}
+	for _, s := range rc.SourceStats() {
+		stats[s.Location] = s
+	}
+	_, squareLine := runtime.FuncForPC(reflect.ValueOf(locatedSquare).Pointer()).FileLine(reflect.ValueOf(locatedSquare).Pointer())
+	if s := stats["location_test.go:"+strconv.Itoa(squareLine+1)]; s.Instructions != 2 || s.Constraints != 0 {
+		t.Fatalf("expected the multiplication of the subcircuit to be counted once per call, got %+v", s)
+	}
+	if s := stats["location_test.go:"+strconv.Itoa(line+3)]; s.Constraints != 1 {
+		t.Fatalf("unexpected stats of the assertion %+v", s)
+	}
+	// the LogUp argument, checked in the extension, is attributed to NewTable
+	if s := stats["location_test.go:"+strconv.Itoa(line+4)]; s.Constraints != m31.ExtensionDegree || s.Instructions == 0 {
+		t.Fatalf("unexpected stats of the lookup table %+v", s)
+	}
+}
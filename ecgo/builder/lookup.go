@@ -0,0 +1,202 @@
+package builder
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+)
+
+func init() {
+	solver.RegisterHint(LookupCountHint)
+}
+
+// LookupTable is a table of rows of a fixed width. Queries assert that a row of variables is one
+// of the rows of the table. They are checked together by a LogUp argument when the circuit is
+// finalized: with a random challenge alpha and counts m_i of each table row t_i,
+// sum(m_i / (alpha - t_i)) = sum(1 / (alpha - q_j)) over all queries q_j, where multi-column
+// rows are combined with powers of another random challenge. In fields with a challenge
+// extension (see field.Extension), the challenges and the fractions are in the extension.
+// Tables and queries are only supported in the root circuit. Tables are checked after all the
+// deferred functions have run, so these may still query them.
+type LookupTable struct {
+	builder *builder
+	width   int
+	rows    [][]frontend.Variable
+	queries [][]frontend.Variable
+
+	// location of the NewTable call, which the LogUp argument is attributed to
+	loc uint32
+}
+
+// NewTable returns an empty lookup table whose rows have width columns.
+func (builder *builder) NewTable(width int) *LookupTable {
+	if builder.root.builder != builder {
+		panic("NewTable can only be called on root circuit")
+	}
+	if width <= 0 {
+		panic("lookup table width must be positive")
+	}
+	t := &LookupTable{builder: builder, width: width}
+	t.loc = builder.captureLocation()
+	builder.tables = append(builder.tables, t)
+	return t
+}
+
+// HasLookupTables returns whether the root circuit has lookup tables, including the one of the
+// range checks, whose queries are checked by a LogUp argument.
+func (r *Root) HasLookupTables() bool {
+	return len(r.tables) != 0
+}
+
+// NewDetachedTable returns a table that doesn't belong to a circuit, for APIs that check
+// queries themselves instead of with a LogUp argument, like the test engine. See Rows and Queries.
+func NewDetachedTable(width int) *LookupTable {
+	if width <= 0 {
+		panic("lookup table width must be positive")
+	}
+	return &LookupTable{width: width}
+}
+
+// Width returns the number of columns of the table.
+func (t *LookupTable) Width() int {
+	return t.width
+}
+
+// Rows returns the rows inserted so far.
+func (t *LookupTable) Rows() [][]frontend.Variable {
+	return t.rows
+}
+
+// Queries returns the queries made so far.
+func (t *LookupTable) Queries() [][]frontend.Variable {
+	return t.queries
+}
+
+// Insert appends a row to the table. The row may contain variables.
+func (t *LookupTable) Insert(row ...frontend.Variable) {
+	if len(row) != t.width {
+		panic(fmt.Sprintf("expected %d columns, got %d", t.width, len(row)))
+	}
+	t.rows = append(t.rows, row)
+}
+
+// Query asserts that row is a row of the table.
+func (t *LookupTable) Query(row ...frontend.Variable) {
+	if len(row) != t.width {
+		panic(fmt.Sprintf("expected %d columns, got %d", t.width, len(row)))
+	}
+	t.queries = append(t.queries, row)
+}
+
+// LookupCountHint computes, for each row of a table, the number of queries equal to it.
+// Inputs are the number of rows, the width, the rows and then the queries, flattened.
+// If several rows are equal, the first one gets all the queries.
+func LookupCountHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	nbRows := int(inputs[0].Int64())
+	width := int(inputs[1].Int64())
+	key := func(row []*big.Int) string {
+		s := ""
+		for _, x := range row {
+			s += x.String() + ","
+		}
+		return s
+	}
+	rowIndex := make(map[string]int)
+	for i := nbRows - 1; i >= 0; i-- {
+		rowIndex[key(inputs[2+i*width:2+(i+1)*width])] = i
+	}
+	for i := range outputs {
+		outputs[i].SetInt64(0)
+	}
+	queries := inputs[2+nbRows*width:]
+	for j := 0; j+width <= len(queries); j += width {
+		i, ok := rowIndex[key(queries[j:j+width])]
+		if !ok {
+			return fmt.Errorf("lookup query %d is not in the table", j/width)
+		}
+		outputs[i].Add(outputs[i], big.NewInt(1))
+	}
+	return nil
+}
+
+func (t *LookupTable) finalize() (err error) {
+	t.builder.withLocation(t.loc, func() { err = t.check() })
+	return err
+}
+
+func (t *LookupTable) check() error {
+	if len(t.queries) == 0 {
+		return nil
+	}
+	if len(t.rows) == 0 {
+		return fmt.Errorf("lookup table is empty but has %d queries", len(t.queries))
+	}
+	b := t.builder
+	inputs := []frontend.Variable{len(t.rows), t.width}
+	for _, row := range t.rows {
+		inputs = append(inputs, row...)
+	}
+	for _, q := range t.queries {
+		inputs = append(inputs, q...)
+	}
+	counts, err := b.NewHint(LookupCountHint, len(t.rows), inputs...)
+	if err != nil {
+		return err
+	}
+
+	alpha := b.extRandom()
+	var beta extVar
+	if t.width > 1 {
+		beta = b.extRandom()
+	}
+	combine := func(row []frontend.Variable) extVar {
+		res := b.extFromBase(row[t.width-1])
+		for j := t.width - 2; j >= 0; j-- {
+			res = b.extMul(res, beta)
+			res[0] = b.Add(res[0], row[j])
+		}
+		return b.extSub(alpha, res)
+	}
+	table := make([]fraction, len(t.rows))
+	for i, row := range t.rows {
+		table[i] = fraction{num: b.extFromBase(counts[i]), den: combine(row)}
+	}
+	queries := make([]fraction, len(t.queries))
+	for i, q := range t.queries {
+		queries[i] = fraction{num: b.extFromBase(1), den: combine(q)}
+	}
+	l := b.sumFractions(table)
+	r := b.sumFractions(queries)
+	n := len(b.origins)
+	b.extAssertIsEqual(b.extMul(l.num, r.den), b.extMul(r.num, l.den))
+	for i := n; i < len(b.origins); i++ {
+		b.origins[i].Api = "LookupTable"
+	}
+	return nil
+}
+
+type fraction struct {
+	num extVar
+	den extVar
+}
+
+// sumFractions adds the fractions by a balanced tree without any division, so that the
+// denominators may depend on random values.
+func (builder *builder) sumFractions(f []fraction) fraction {
+	for len(f) > 1 {
+		next := make([]fraction, 0, (len(f)+1)/2)
+		for i := 0; i+1 < len(f); i += 2 {
+			next = append(next, fraction{
+				num: builder.extAdd(builder.extMul(f[i].num, f[i+1].den), builder.extMul(f[i+1].num, f[i].den)),
+				den: builder.extMul(f[i].den, f[i+1].den),
+			})
+		}
+		if len(f)%2 == 1 {
+			next = append(next, f[len(f)-1])
+		}
+		f = next
+	}
+	return f[0]
+}
@@ -0,0 +1,87 @@
+package builder
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/babybear"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/bn254"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func bigInts(x ...int64) []*big.Int {
+	res := make([]*big.Int, len(x))
+	for i, v := range x {
+		res[i] = big.NewInt(v)
+	}
+	return res
+}
+
+func TestLookupCountHint(t *testing.T) {
+	// 3 rows of width 2, then 4 queries
+	inputs := bigInts(3, 2, 1, 10, 2, 20, 3, 30, 2, 20, 2, 20, 3, 30, 1, 10)
+	outputs := bigInts(0, 0, 0)
+	if err := LookupCountHint(m31.ScalarField, inputs, outputs); err != nil {
+		t.Fatal(err)
+	}
+	if outputs[0].Int64() != 1 || outputs[1].Int64() != 2 || outputs[2].Int64() != 1 {
+		t.Fatalf("unexpected counts %v", outputs)
+	}
+	inputs = bigInts(1, 1, 5, 6)
+	if err := LookupCountHint(m31.ScalarField, inputs, bigInts(0)); err == nil {
+		t.Fatal("expected an error for a query not in the table")
+	}
+}
+
+func TestLookupTableFinalize(t *testing.T) {
+	// BN254 samples the challenges in the base field
+	root := NewRoot(bn254.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	table := root.NewTable(1)
+	for i := 0; i < 16; i++ {
+		table.Insert(i)
+	}
+	table.Query(x)
+	table.Query(root.Add(x, 1))
+	c := root.Finalize().Circuits[0]
+	nbRandom, nbHint := 0, 0
+	for _, in := range c.Instructions {
+		if in.Type == irsource.ConstantLike && in.ExtraId == 1 {
+			nbRandom++
+		}
+		if in.Type == irsource.Hint {
+			nbHint++
+		}
+	}
+	if nbRandom != 1 || nbHint != 1 || len(c.Constraints) != 1 {
+		t.Fatalf("unexpected lowering: %d random values, %d hints, %d constraints", nbRandom, nbHint, len(c.Constraints))
+	}
+}
+
+func TestLookupTableExtension(t *testing.T) {
+	root := NewRoot(babybear.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	table := root.NewTable(2)
+	for i := 0; i < 16; i++ {
+		table.Insert(i, i*i)
+	}
+	table.Query(x, root.Mul(x, x))
+	table.Query(3, 9)
+	rc := root.Finalize()
+	c := rc.Circuits[0]
+	nbRandom := 0
+	for _, in := range c.Instructions {
+		if in.Type == irsource.ConstantLike && in.ExtraId == 1 {
+			nbRandom++
+		}
+	}
+	if nbRandom != 2*babybear.ExtensionDegree || len(c.Constraints) != babybear.ExtensionDegree {
+		t.Fatalf("unexpected lowering: %d random values, %d constraints", nbRandom, len(c.Constraints))
+	}
+	if err := evalRoot(rc, bigInts(5)); err != nil {
+		t.Fatal(err)
+	}
+}
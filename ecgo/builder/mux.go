@@ -0,0 +1,128 @@
+package builder
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+)
+
+func init() {
+	solver.RegisterHint(OneHotHint)
+}
+
+// OneHotHint sets outputs[i] to 1 if inputs[0] is i, and to 0 otherwise.
+func OneHotHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	if !inputs[0].IsInt64() || inputs[0].Int64() >= int64(len(outputs)) {
+		return fmt.Errorf("selector %s is not less than %d", inputs[0], len(outputs))
+	}
+	for i := range outputs {
+		outputs[i].SetInt64(0)
+	}
+	outputs[inputs[0].Int64()].SetInt64(1)
+	return nil
+}
+
+// muxCaller is implemented by the builders of this package, including Root.
+type muxCaller interface {
+	callBranches(branches []SubCircuitSimpleFunc, input []frontend.Variable) ([][]frontend.Variable, [][]int)
+}
+
+// Mux calls each of the branches once on input, as memorized subcircuits, and returns the
+// outputs of branches[sel], asserting that sel is less than len(branches). All the branches must
+// return the same number of outputs. This is the dispatch of a VM-style circuit, where sel is the
+// opcode: every branch is evaluated, and the selection costs one multiplication per branch and
+// output.
+//
+// Branches whose subcircuits are identical, including if they're the same function, are called
+// once and share their selection. Like MemorizedSimpleCall, branches are identified by their
+// function, so closures of the same function literal are the same branch.
+//
+// If api isn't an ecgo builder, e.g. in the gnark test engine, the branches are called directly.
+func Mux(api frontend.API, sel frontend.Variable, branches []SubCircuitSimpleFunc, input []frontend.Variable) []frontend.Variable {
+	if len(branches) == 0 {
+		panic("Mux needs at least one branch")
+	}
+	var outputs [][]frontend.Variable
+	var groups [][]int
+	if b, ok := api.(muxCaller); ok {
+		outputs, groups = b.callBranches(branches, input)
+	} else {
+		for i, f := range branches {
+			outputs = append(outputs, f(api, input))
+			groups = append(groups, []int{i})
+		}
+	}
+	for i, out := range outputs[1:] {
+		if len(out) != len(outputs[0]) {
+			panic(fmt.Sprintf("Mux: branch %d has %d outputs, branch %d has %d", groups[0][0], len(outputs[0]), groups[i+1][0], len(out)))
+		}
+	}
+
+	// sel is decomposed into a one-hot vector: booleans which sum to 1, whose index is sel
+	bits, err := api.Compiler().NewHint(OneHotHint, len(branches), sel)
+	if err != nil {
+		panic(err)
+	}
+	var sum, index frontend.Variable = 0, 0
+	for i, b := range bits {
+		api.AssertIsBoolean(b)
+		sum = api.Add(sum, b)
+		index = api.Add(index, api.Mul(b, i))
+	}
+	api.AssertIsEqual(sum, 1)
+	api.AssertIsEqual(index, sel)
+	if len(groups) == 1 {
+		return outputs[0]
+	}
+
+	selectors := make([]frontend.Variable, len(groups))
+	for g, group := range groups {
+		selectors[g] = bits[group[0]]
+		for _, i := range group[1:] {
+			selectors[g] = api.Add(selectors[g], bits[i])
+		}
+	}
+	res := make([]frontend.Variable, len(outputs[0]))
+	column := make([]frontend.Variable, len(groups))
+	for j := range res {
+		for g := range groups {
+			column[g] = outputs[g][j]
+		}
+		if v, ok := api.(API); ok {
+			res[j] = v.InnerProduct(selectors, column)
+			continue
+		}
+		res[j] = 0
+		for g := range groups {
+			res[j] = api.MulAcc(res[j], selectors[g], column[g])
+		}
+	}
+	return res
+}
+
+// callBranches calls each distinct subcircuit of the branches once. It returns the outputs of
+// each call, and the indices of the branches of each call.
+func (parent *builder) callBranches(branches []SubCircuitSimpleFunc, input []frontend.Variable) ([][]frontend.Variable, [][]int) {
+	vars := make([]frontend.Variable, len(input))
+	for i, x := range input {
+		vars[i] = parent.toVariable(x)
+	}
+	input = vars
+	var outputs [][]frontend.Variable
+	var groups [][]int
+	calls := make(map[uint64]int)
+	for i, f := range branches {
+		name := GetFuncName(f)
+		id := parent.defineSubCircuit(parent.simpleCircuitId(name, len(input)), name, len(input), f)
+		if g, ok := calls[id]; ok {
+			groups[g] = append(groups[g], i)
+			continue
+		}
+		calls[id] = len(groups)
+		groups = append(groups, []int{i})
+		outputs = append(outputs, parent.callSubCircuit(id, name, input, f))
+	}
+	return outputs, groups
+}
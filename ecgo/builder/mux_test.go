@@ -0,0 +1,74 @@
+package builder
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func muxAdd(api frontend.API, in []frontend.Variable) []frontend.Variable {
+	return []frontend.Variable{api.Add(in[0], in[1]), in[0]}
+}
+
+func muxMul(api frontend.API, in []frontend.Variable) []frontend.Variable {
+	return []frontend.Variable{api.Mul(in[0], in[1]), in[1]}
+}
+
+// muxAddAgain has the same body as muxAdd, so it's deduplicated
+func muxAddAgain(api frontend.API, in []frontend.Variable) []frontend.Variable {
+	return []frontend.Variable{api.Add(in[0], in[1]), in[0]}
+}
+
+func TestMux(t *testing.T) {
+	branches := []SubCircuitSimpleFunc{muxAdd, muxMul, muxAddAgain, muxMul}
+	for _, c := range []struct {
+		sel      int64
+		expected [2]int64
+		ok       bool
+	}{
+		{0, [2]int64{7, 3}, true},
+		{1, [2]int64{12, 4}, true},
+		{2, [2]int64{7, 3}, true},
+		{3, [2]int64{12, 4}, true},
+		{1, [2]int64{7, 3}, false},
+		{4, [2]int64{0, 0}, false},
+	} {
+		root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+		in := make([]frontend.Variable, 5)
+		for i := range in {
+			in[i] = root.SecretVariable(schema.LeafInfo{})
+		}
+		out := Mux(root, in[0], branches, in[1:3])
+		root.AssertIsEqual(out[0], in[3])
+		root.AssertIsEqual(out[1], in[4])
+		rc := root.Finalize()
+		calls := 0
+		for _, insn := range rc.Circuits[0].Instructions {
+			if insn.Type == irsource.SubCircuitCall {
+				calls++
+			}
+		}
+		if calls != 2 {
+			t.Fatalf("expected 2 distinct branches to be called, got %d calls", calls)
+		}
+		err := evalRoot(rc, []*big.Int{big.NewInt(c.sel), big.NewInt(3), big.NewInt(4), big.NewInt(c.expected[0]), big.NewInt(c.expected[1])})
+		if (err == nil) != c.ok {
+			t.Fatalf("sel %d, expected %v: unexpected result %v", c.sel, c.expected, err)
+		}
+	}
+}
+
+func TestMuxOutputCount(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected branches with different numbers of outputs to be rejected")
+		}
+	}()
+	Mux(root, x, []SubCircuitSimpleFunc{muxAdd, squareSum}, []frontend.Variable{x, x})
+}
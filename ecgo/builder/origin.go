@@ -0,0 +1,87 @@
+package builder
+
+import (
+	"math/big"
+	"reflect"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/gnarkexpr"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/profile"
+)
+
+// ConstraintOrigin describes the assertion that created a constraint, for debugging.
+type ConstraintOrigin struct {
+	// Api is the name of the assertion, e.g. AssertIsEqual.
+	Api string
+	// Location is the call stack of the assertion outside of this package. It's empty if source
+	// locations are disabled, see Root.SetSourceLocationDepth.
+	Location irsource.SourceLocation
+	// Operands are the arguments of the assertion.
+	Operands []Operand
+}
+
+// Operand is an argument of an assertion: a variable of the circuit, or a constant if Var is 0.
+type Operand struct {
+	Var   int
+	Const *big.Int
+}
+
+var builderPkgPrefix = reflect.TypeOf(builder{}).PkgPath() + "."
+
+// addConstraint adds c, recording the assertion api called with operands
+func (builder *builder) addConstraint(c irsource.Constraint, api string, operands ...frontend.Variable) {
+	o := ConstraintOrigin{Api: api, Operands: make([]Operand, len(operands))}
+	for i, v := range operands {
+		switch t := v.(type) {
+		case gnarkexpr.Expr:
+			o.Operands[i].Var = t.WireID()
+		case constraint.Element:
+			o.Operands[i].Const = builder.field.ToBigInt(t)
+		default:
+			o.Operands[i].Const = builder.field.ToBigInt(builder.field.FromInterface(t))
+		}
+	}
+	c.Loc = builder.captureLocation()
+	profile.RecordConstraint()
+	factor := builder.root.growth.Factor
+	builder.constraints = append(reserve(builder.constraints, factor), c)
+	builder.origins = append(reserve(builder.origins, factor), o)
+	if builder.root.hooks.ConstraintAdded != nil {
+		builder.constraintAdded(c, o)
+	}
+}
+
+// ConstraintOrigins returns the origins of the constraints of a finalized circuit, in the order of
+// its Constraints.
+func (r *Root) ConstraintOrigins(circuitId uint64) []ConstraintOrigin {
+	sub, ok := r.registry.m[circuitId]
+	if !ok {
+		return nil
+	}
+	res := make([]ConstraintOrigin, len(sub.builder.origins))
+	for i, o := range sub.builder.origins {
+		res[i] = o
+		res[i].Location = r.resolvedLocation(sub.builder.constraints[i].Loc)
+	}
+	return res
+}
+
+// resolvedLocation returns the source location of an id recorded by the builder
+func (r *Root) resolvedLocation(id uint32) irsource.SourceLocation {
+	if id == 0 || int(id) > len(r.locations.stacks) {
+		return nil
+	}
+	return r.locations.resolve(&r.locations.stacks[id-1])
+}
+
+// SubCircuitName returns the name of the function that built a subcircuit, and an empty string
+// for the root circuit.
+func (r *Root) SubCircuitName(circuitId uint64) string {
+	sub, ok := r.registry.m[circuitId]
+	if !ok {
+		return ""
+	}
+	return sub.name
+}
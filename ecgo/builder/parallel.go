@@ -0,0 +1,249 @@
+package builder
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/gnarkexpr"
+	"github.com/consensys/gnark/frontend"
+)
+
+// parallelDefiner is implemented by the builders of this package, including Root.
+type parallelDefiner interface {
+	parallelDefine(inputs [][]frontend.Variable, f SubCircuitSimpleFunc) [][]frontend.Variable
+}
+
+// ParallelDefine returns f(api, inputs[i]) for each input, calling f concurrently from several
+// goroutines. The builder isn't safe for concurrent use, so each call builds its own sub-builder,
+// with its own variables and subcircuits, and the sub-builders are merged into api in the order
+// of the inputs once all the calls returned. The circuit is equivalent to calling f on each input
+// in turn, though the range checks of a call are queried from the lookup table of api after its
+// other instructions, so the order of the instructions may differ.
+//
+// Like a subcircuit, f must only use the variables of its input, and not the state of the
+// enclosing circuit, e.g. its lookup tables. It may call subcircuits, which are deduplicated with
+// the ones of api when merging, but each call builds the subcircuits it needs on its own.
+// Deferred functions registered by a call run once f returns, in its goroutine. If a call panics,
+// ParallelDefine panics with the value of the first failing input once all the calls returned.
+//
+// If api isn't an ecgo builder, e.g. in the gnark test engine, f is called on each input in turn.
+func ParallelDefine(api frontend.API, inputs [][]frontend.Variable, f SubCircuitSimpleFunc) [][]frontend.Variable {
+	b, ok := api.(parallelDefiner)
+	if !ok {
+		res := make([][]frontend.Variable, len(inputs))
+		for i, in := range inputs {
+			res[i] = f(api, in)
+		}
+		return res
+	}
+	return b.parallelDefine(inputs, f)
+}
+
+// parallelCall is a call of ParallelDefine built by its own builder
+type parallelCall struct {
+	builder      *builder
+	output       []int
+	rangeQueries []int
+}
+
+func (parent *builder) parallelDefine(inputs [][]frontend.Variable, f SubCircuitSimpleFunc) [][]frontend.Variable {
+	ids := make([][]int, len(inputs))
+	for i, in := range inputs {
+		ids[i] = parent.toVariableIds(in...)
+	}
+	calls := make([]*parallelCall, len(inputs))
+	panics := make([]any, len(inputs))
+	// each goroutine only reads the root, which isn't modified until they all returned
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	for i := range inputs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer func() {
+				if r := recover(); r != nil {
+					panics[i] = r
+				}
+			}()
+			calls[i] = parent.root.buildParallelCall(len(ids[i]), f)
+		}(i)
+	}
+	wg.Wait()
+	for _, r := range panics {
+		if r != nil {
+			panic(r)
+		}
+	}
+	res := make([][]frontend.Variable, len(inputs))
+	for i, c := range calls {
+		res[i] = parent.mergeParallelCall(c, ids[i])
+	}
+	return res
+}
+
+// newParallelRoot returns a root holding the state of a call of ParallelDefine: its variables,
+// operand slabs, source locations and subcircuits. The root circuit stays the one of r, so that
+// the functions restricted to it panic.
+func (r *Root) newParallelRoot() *Root {
+	w := &Root{
+		builder:       r.builder,
+		field:         r.field,
+		config:        r.config,
+		registry:      newSubCircuitRegistry(0),
+		locations:     newLocations(),
+		noDebugPrints: r.noDebugPrints,
+		growth:        r.growth,
+		vars:          gnarkexpr.NewArena(),
+	}
+	w.locations.depth = r.locations.depth
+	// calls of the subcircuits being built can't be built in the goroutines either
+	w.registry.building = append([]buildingSubCircuit(nil), r.registry.building...)
+	return w
+}
+
+// buildParallelCall calls f in a new builder with n inputs, and runs its deferred functions
+func (r *Root) buildParallelCall(n int, f SubCircuitSimpleFunc) *parallelCall {
+	b := r.newParallelRoot().newBuilder(n, r.growth.SubCircuitCapacity)
+	input := make([]frontend.Variable, n)
+	for i := range input {
+		input[i] = b.newVariable(i + 1)
+	}
+	output := f(b, input)
+	c := &parallelCall{builder: b, output: make([]int, len(output))}
+	for i, v := range output {
+		c.output[i] = b.toVariableId(v)
+	}
+	for i := 0; i < len(b.defers); i++ {
+		if err := b.defers[i](b); err != nil {
+			panic(fmt.Sprintf("deferred function failed: %v", err))
+		}
+	}
+	b.defers = nil
+	for _, v := range b.rangeQueries {
+		c.rangeQueries = append(c.rangeQueries, b.toVariableId(v))
+	}
+	b.rangeQueries = nil
+	return c
+}
+
+// mergeParallelCall adds the instructions and constraints of c to parent, its inputs being the
+// variables input of parent, and returns its outputs
+func (parent *builder) mergeParallelCall(c *parallelCall, input []int) []frontend.Variable {
+	b := c.builder
+	locs := parent.root.locations.merge(&b.root.locations)
+	circuits := parent.root.mergeRegistry(b.root.registry, locs)
+
+	vars := make([]int, b.maxVar+1)
+	copy(vars[1:], input)
+	next := len(input) + 1
+	for i := range b.instructions {
+		in := b.instructions[i].MapOperands(func(x int) int { return vars[x] })
+		in.Loc = locs[in.Loc]
+		if in.Type == irsource.SubCircuitCall {
+			in.ExtraId = circuits[in.ExtraId]
+		}
+		parent.appendInstruction(in)
+		for j := 0; j < in.OutputCount(); j++ {
+			vars[next] = parent.addVarId()
+			next++
+		}
+		if in.Type == irsource.ConstantLike && in.ExtraId == 0 {
+			parent.constValues = append(parent.constValues, in.Const)
+			parent.varConstId[vars[next-1]] = len(parent.constValues) - 1
+		}
+	}
+	if next != len(vars) {
+		panic("variables of a parallel call without instruction")
+	}
+	for i, con := range b.constraints {
+		con.Var = vars[con.Var]
+		// the call didn't know that its input is boolean
+		if con.Typ == irsource.Bool && parent.booleans[con.Var] {
+			parent.root.skippedBooleans++
+			continue
+		}
+		con.Loc = locs[con.Loc]
+		o := b.origins[i]
+		for j := range o.Operands {
+			if o.Operands[j].Var != 0 {
+				o.Operands[j].Var = vars[o.Operands[j].Var]
+			}
+		}
+		parent.constraints = append(parent.constraints, con)
+		parent.origins = append(parent.origins, o)
+		if parent.root.hooks.ConstraintAdded != nil {
+			parent.constraintAdded(con, o)
+		}
+	}
+	for x := range b.booleans {
+		parent.booleans[vars[x]] = true
+	}
+	parent.root.skippedBooleans += b.root.skippedBooleans
+	for _, x := range c.rangeQueries {
+		parent.queryRange(parent.newVariable(vars[x]))
+	}
+	res := make([]frontend.Variable, len(c.output))
+	for i, x := range c.output {
+		res[i] = parent.newVariable(vars[x])
+	}
+	return res
+}
+
+// mergeRegistry adds the subcircuits of other, whose source locations are mapped by locs, to the
+// registry of r, and returns the id in r of each definition of other
+func (r *Root) mergeRegistry(other *SubCircuitRegistry, locs []uint32) map[uint64]uint64 {
+	sr := r.registry
+	for id, h := range other.fullHash {
+		if v, ok := sr.fullHash[id]; ok && v != h {
+			panic("subcircuit id collision")
+		}
+		sr.fullHash[id] = h
+	}
+	for id, s := range other.outputStructure {
+		if _, ok := sr.outputStructure[id]; !ok {
+			sr.outputStructure[id] = s
+		}
+	}
+	for id, t := range other.outputTemplate {
+		if _, ok := sr.outputTemplate[id]; !ok {
+			sr.outputTemplate[id] = t
+		}
+	}
+	ids := make(map[uint64]uint64)
+	// the callees of a subcircuit are registered before it
+	for _, id := range other.order {
+		sub := other.m[id]
+		b := sub.builder
+		for i := range b.instructions {
+			in := &b.instructions[i]
+			in.Loc = locs[in.Loc]
+			if in.Type == irsource.SubCircuitCall {
+				def, ok := ids[in.ExtraId]
+				if !ok {
+					panic("subcircuit registered before its callees")
+				}
+				in.ExtraId = def
+			}
+		}
+		for i := range b.constraints {
+			b.constraints[i].Loc = locs[b.constraints[i].Loc]
+		}
+		b.root = r
+		if def := sr.resolve(id); sr.m[def] != nil {
+			ids[id] = def
+			continue
+		}
+		ids[id] = sr.register(id, sub)
+	}
+	for x, y := range other.alias {
+		ids[x] = ids[y]
+		if _, ok := sr.m[sr.resolve(x)]; !ok {
+			sr.alias[x] = ids[y]
+		}
+	}
+	return ids
+}
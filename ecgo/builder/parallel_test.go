@@ -0,0 +1,106 @@
+package builder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// parallelGadget calls a subcircuit, range checks its input and asserts that its second input
+// is boolean
+func parallelGadget(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	s := api.(SubCircuitAPI).MemorizedSimpleCall(squareSum, input)
+	api.(frontend.Rangechecker).Check(input[0], 20)
+	api.AssertIsBoolean(input[1])
+	return []frontend.Variable{api.Add(s[0], 7), 3}
+}
+
+func TestParallelDefine(t *testing.T) {
+	build := func(parallel bool) *Root {
+		root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+		inputs := make([][]frontend.Variable, 8)
+		for i := range inputs {
+			inputs[i] = []frontend.Variable{root.SecretVariable(schema.LeafInfo{}), root.SecretVariable(schema.LeafInfo{})}
+		}
+		root.MemorizedSimpleCall(squareSum, inputs[0])
+		var outputs [][]frontend.Variable
+		if parallel {
+			outputs = ParallelDefine(root, inputs, parallelGadget)
+		} else {
+			for _, in := range inputs {
+				outputs = append(outputs, parallelGadget(root, in))
+			}
+		}
+		for _, out := range outputs {
+			root.Output(root.Mul(out[0], out[1]))
+		}
+		return root
+	}
+	seq, par := build(false).Finalize(), build(true).Finalize()
+	if len(par.Circuits) != len(seq.Circuits) || len(par.Circuits[0].Instructions) != len(seq.Circuits[0].Instructions) ||
+		len(par.Circuits[0].Constraints) != len(seq.Circuits[0].Constraints) {
+		t.Fatalf("expected the same circuit as a sequential definition, got %d circuits and %d instructions instead of %d and %d",
+			len(par.Circuits), len(par.Circuits[0].Instructions), len(seq.Circuits), len(seq.Circuits[0].Instructions))
+	}
+	inputs := bigInts(1, 0, 2, 1, 3, 0, 4, 1, 5, 0, 6, 1, 7, 0, 1<<19, 1)
+	if err := evalRoot(par, inputs); err != nil {
+		t.Fatal(err)
+	}
+	inputs[14].SetInt64(1 << 20)
+	if err := evalRoot(par, inputs); err == nil {
+		t.Fatal("expected the range check of a parallel call to be kept")
+	}
+	inputs[14].SetInt64(1)
+	inputs[15].SetInt64(2)
+	if err := evalRoot(par, inputs); err == nil {
+		t.Fatal("expected the assertions of a parallel call to be kept")
+	}
+}
+
+// The booleans known by the caller are only known by a parallel call once it's merged
+func TestParallelDefineBooleans(t *testing.T) {
+	build := func(parallel bool) *Root {
+		root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+		inputs := make([][]frontend.Variable, 4)
+		for i := range inputs {
+			inputs[i] = []frontend.Variable{root.SecretVariable(schema.LeafInfo{}), root.SecretVariable(schema.LeafInfo{})}
+			root.AssertIsBoolean(inputs[i][1])
+		}
+		gadget := func(api frontend.API, input []frontend.Variable) []frontend.Variable {
+			api.AssertIsBoolean(input[1])
+			return []frontend.Variable{api.Mul(input[0], input[1])}
+		}
+		if parallel {
+			ParallelDefine(root, inputs, gadget)
+		} else {
+			for _, in := range inputs {
+				gadget(root, in)
+			}
+		}
+		return root
+	}
+	seq, par := build(false), build(true)
+	if len(par.constraints) != 4 || len(seq.constraints) != 4 {
+		t.Fatalf("expected 4 boolean constraints, got %d and %d", len(par.constraints), len(seq.constraints))
+	}
+	if par.SkippedBooleanAssertions() != 4 || seq.SkippedBooleanAssertions() != 4 {
+		t.Fatalf("expected 4 skipped assertions, got %d and %d", par.SkippedBooleanAssertions(), seq.SkippedBooleanAssertions())
+	}
+}
+
+func TestParallelDefinePanic(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	defer func() {
+		if err, ok := recover().(error); !ok || !errors.Is(err, ErrInvalidSubCircuit) {
+			t.Fatalf("expected the panic of a parallel call, got %v", err)
+		}
+	}()
+	ParallelDefine(root, [][]frontend.Variable{{x}, {x}}, func(api frontend.API, input []frontend.Variable) []frontend.Variable {
+		api.(SubCircuitAPI).MemorizedCall(squareSum, 1, 2)
+		return input
+	})
+}
@@ -0,0 +1,81 @@
+package builder
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// AssertPermutation asserts that b is a permutation of a, i.e. that they're equal as multisets.
+// See AssertRowPermutation.
+func (builder *builder) AssertPermutation(a, b []frontend.Variable) {
+	rows := func(x []frontend.Variable) [][]frontend.Variable {
+		res := make([][]frontend.Variable, len(x))
+		for i := range x {
+			res[i] = x[i : i+1 : i+1]
+		}
+		return res
+	}
+	builder.AssertRowPermutation(rows(a), rows(b))
+}
+
+// AssertRowPermutation asserts that the rows of b are a permutation of the rows of a, e.g. the
+// (address, time, value) accesses of a memory sorted by address and by time. It's checked by a
+// grand-product argument: with a random challenge alpha, prod(alpha - a_i) = prod(alpha - b_i),
+// where rows are combined with powers of another random challenge. The challenges are in the
+// challenge extension of the field (see field.Extension), and the products are balanced trees so
+// the argument adds a logarithmic number of layers.
+// Like lookups, permutations are only supported in the root circuit.
+func (builder *builder) AssertRowPermutation(a, b [][]frontend.Variable) {
+	if builder.root.builder != builder {
+		panic("AssertPermutation can only be called on root circuit")
+	}
+	if len(a) != len(b) {
+		panic(fmt.Sprintf("AssertPermutation: %d rows can't be a permutation of %d rows", len(b), len(a)))
+	}
+	if len(a) == 0 {
+		return
+	}
+	width := len(a[0])
+	for _, rows := range [][][]frontend.Variable{a, b} {
+		for _, row := range rows {
+			if len(row) != width {
+				panic(fmt.Sprintf("AssertPermutation: expected %d columns, got %d", width, len(row)))
+			}
+		}
+	}
+
+	alpha := builder.extRandom()
+	var beta extVar
+	if width > 1 {
+		beta = builder.extRandom()
+	}
+	product := func(rows [][]frontend.Variable) extVar {
+		terms := make([]extVar, len(rows))
+		for i, row := range rows {
+			res := builder.extFromBase(row[width-1])
+			for j := width - 2; j >= 0; j-- {
+				res = builder.extMul(res, beta)
+				res[0] = builder.Add(res[0], row[j])
+			}
+			terms[i] = builder.extSub(alpha, res)
+		}
+		for len(terms) > 1 {
+			next := make([]extVar, 0, (len(terms)+1)/2)
+			for i := 0; i+1 < len(terms); i += 2 {
+				next = append(next, builder.extMul(terms[i], terms[i+1]))
+			}
+			if len(terms)%2 == 1 {
+				next = append(next, terms[len(terms)-1])
+			}
+			terms = next
+		}
+		return terms[0]
+	}
+	pa, pb := product(a), product(b)
+	n := len(builder.origins)
+	builder.extAssertIsEqual(pa, pb)
+	for i := n; i < len(builder.origins); i++ {
+		builder.origins[i].Api = "AssertPermutation"
+	}
+}
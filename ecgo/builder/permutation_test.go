@@ -0,0 +1,64 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/babybear"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func TestAssertPermutation(t *testing.T) {
+	for _, c := range []struct {
+		values []int64
+		ok     bool
+	}{
+		{[]int64{1, 2, 2, 5, 2, 5, 1, 2}, true},
+		{[]int64{1, 2, 2, 5, 2, 5, 1, 1}, false},
+		{[]int64{1, 2, 2, 5, 2, 5, 1, 3}, false},
+	} {
+		root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+		x := make([]frontend.Variable, len(c.values))
+		for i := range x {
+			x[i] = root.SecretVariable(schema.LeafInfo{})
+		}
+		root.AssertPermutation(x[:4], x[4:])
+		if err := evalRoot(root.Finalize(), bigInts(c.values...)); (err == nil) != c.ok {
+			t.Fatalf("%v: unexpected result %v", c.values, err)
+		}
+	}
+}
+
+func TestAssertRowPermutation(t *testing.T) {
+	for _, c := range []struct {
+		values []int64
+		ok     bool
+	}{
+		// (1, 2), (3, 4), (1, 2) and (1, 2), (1, 2), (3, 4)
+		{[]int64{1, 2, 3, 4, 1, 2, 1, 2, 1, 2, 3, 4}, true},
+		// the columns of (3, 4) are swapped
+		{[]int64{1, 2, 3, 4, 1, 2, 1, 2, 1, 2, 4, 3}, false},
+	} {
+		root := NewRoot(babybear.ScalarField, frontend.CompileConfig{})
+		rows := make([][]frontend.Variable, 6)
+		for i := range rows {
+			rows[i] = []frontend.Variable{root.SecretVariable(schema.LeafInfo{}), root.SecretVariable(schema.LeafInfo{})}
+		}
+		root.AssertRowPermutation(rows[:3], rows[3:])
+		rc := root.Finalize()
+		nbRandom := 0
+		for _, in := range rc.Circuits[0].Instructions {
+			if in.Type == irsource.ConstantLike && in.ExtraId == 1 {
+				nbRandom++
+			}
+		}
+		if nbRandom != 2*babybear.ExtensionDegree {
+			t.Fatalf("expected challenges in the extension, got %d random values", nbRandom)
+		}
+		if err := evalRoot(rc, bigInts(c.values...)); (err == nil) != c.ok {
+			t.Fatalf("%v: unexpected result %v", c.values, err)
+		}
+	}
+}
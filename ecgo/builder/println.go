@@ -0,0 +1,127 @@
+// Some content of this file is copied from gnark/frontend/cs/r1cs/api.go
+
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/gnarkexpr"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// DebugOutput is where the Println calls of circuits print during witness solving.
+var DebugOutput io.Writer = os.Stdout
+
+var debugOutputM sync.Mutex
+
+var tVariable = reflect.ValueOf(struct{ A frontend.Variable }{}).FieldByName("A").Type()
+
+// SetDebugPrints sets whether Println calls are compiled into the circuit. They are by default;
+// disabling them removes their hints and constraints from the compiled circuit.
+func (r *Root) SetDebugPrints(enabled bool) {
+	r.noDebugPrints = !enabled
+}
+
+// Println prints the values of the arguments when the witness is solved, prefixed by the
+// location of the call. Variables are printed as their value, structs containing variables as
+// their leaves, and other arguments with fmt.Sprint.
+//
+// Each call is compiled to a hint, evaluated during witness solving, whose output is
+// constrained to zero so that the compiler keeps it. The hint is registered in the current
+// process only: input solvers deserialized in another process can't find it, unless debug
+// prints are disabled, see Root.SetDebugPrints.
+func (builder *builder) Println(a ...frontend.Variable) {
+	if builder.root.noDebugPrints {
+		return
+	}
+	var sbb strings.Builder
+	var toResolve []frontend.Variable
+
+	// prefix log line with file.go:line
+	if loc := callerOutside(); len(loc) != 0 {
+		sbb.WriteString(strings.ReplaceAll(loc.String(), "%", "%%"))
+		sbb.WriteByte(' ')
+	}
+	for i, arg := range a {
+		if i > 0 {
+			sbb.WriteByte(' ')
+		}
+		if _, ok := arg.(gnarkexpr.Expr); ok {
+			sbb.WriteString("%s")
+			toResolve = append(toResolve, arg)
+		} else {
+			builder.printArg(&toResolve, &sbb, arg)
+		}
+	}
+	format := sbb.String()
+
+	// the hint id identifies the format, so it's the same for the same call on every run
+	h := sha256.Sum256([]byte("ecgo.Println\x00" + format))
+	id := solver.HintID(binary.LittleEndian.Uint32(h[:]))
+	if solver.GetRegisteredHint(id) == nil {
+		solver.RegisterNamedHint(printHint(format), id)
+	}
+	out, err := builder.NewHintForId(id, 1, toResolve...)
+	if err != nil {
+		panic(err)
+	}
+	builder.addConstraint(irsource.Constraint{
+		Typ: irsource.Zero,
+		Var: builder.toVariableId(out[0]),
+	}, "Println")
+}
+
+func (builder *builder) printArg(toResolve *[]frontend.Variable, sbb *strings.Builder, a frontend.Variable) {
+
+	leafCount, err := schema.Walk(a, tVariable, nil)
+	count := leafCount.Public + leafCount.Secret
+
+	// no variables in nested struct, we use fmt std print function
+	if count == 0 || err != nil {
+		// the result is a format string
+		sbb.WriteString(strings.ReplaceAll(fmt.Sprint(a), "%", "%%"))
+		return
+	}
+
+	sbb.WriteByte('{')
+	printer := func(f schema.LeafInfo, tValue reflect.Value) error {
+		count--
+		sbb.WriteString(f.FullName())
+		sbb.WriteString(": ")
+		sbb.WriteString("%s")
+		if count != 0 {
+			sbb.WriteString(", ")
+		}
+		*toResolve = append(*toResolve, tValue.Interface())
+		return nil
+	}
+	// ignoring error, printer() doesn't return errors
+	_, _ = schema.Walk(a, tVariable, printer)
+	sbb.WriteByte('}')
+}
+
+// printHint returns a hint printing its inputs with format, and returning 0
+func printHint(format string) solver.Hint {
+	return func(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+		args := make([]any, len(inputs))
+		for i, x := range inputs {
+			args[i] = x.String()
+		}
+		debugOutputM.Lock()
+		fmt.Fprintln(DebugOutput, fmt.Sprintf(format, args...))
+		debugOutputM.Unlock()
+		outputs[0].SetInt64(0)
+		return nil
+	}
+}
@@ -0,0 +1,61 @@
+package builder
+
+import (
+	"bytes"
+	"math/big"
+	"strconv"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+type printedPoint struct {
+	X, Y frontend.Variable
+}
+
+func TestPrintln(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	line := currentLine()
+	root.Println("x =", x, "p =", printedPoint{X: x, Y: 5}, "100%")
+	c := root.Finalize().Circuits[0]
+
+	var hint *irsource.Instruction
+	for i := range c.Instructions {
+		if c.Instructions[i].Type == irsource.Hint {
+			hint = &c.Instructions[i]
+		}
+	}
+	if hint == nil || len(hint.Inputs) != 3 || len(c.Constraints) != 1 || c.Constraints[0].Typ != irsource.Zero {
+		t.Fatalf("expected a hint constrained to zero, got %+v", c)
+	}
+
+	var out bytes.Buffer
+	old := DebugOutput
+	DebugOutput = &out
+	defer func() { DebugOutput = old }()
+	outputs := []*big.Int{big.NewInt(1)}
+	f := solver.GetRegisteredHint(solver.HintID(hint.ExtraId))
+	if err := f(m31.ScalarField, []*big.Int{big.NewInt(7), big.NewInt(7), big.NewInt(5)}, outputs); err != nil {
+		t.Fatal(err)
+	}
+	expected := "println_test.go:" + strconv.Itoa(line+1) + " x = 7 p = {X: 7, Y: 5} 100%\n"
+	if out.String() != expected || outputs[0].Sign() != 0 {
+		t.Fatalf("expected %q, got %q and output %s", expected, out.String(), outputs[0])
+	}
+}
+
+func TestPrintlnDisabled(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	root.SetDebugPrints(false)
+	x := root.SecretVariable(schema.LeafInfo{})
+	root.Println("x =", x)
+	c := root.Finalize().Circuits[0]
+	if len(c.Instructions) != 0 || len(c.Constraints) != 0 {
+		t.Fatalf("expected the call to be stripped, got %+v", c)
+	}
+}
@@ -0,0 +1,101 @@
+package builder
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+)
+
+func init() {
+	solver.RegisterHint(RangeDecomposeHint)
+}
+
+// rangeTableBits is the width of the limbs looked up in the range table of the root circuit
+const rangeTableBits = 8
+
+// RangeDecomposeHint decomposes inputs[2] into outputs, limbs of inputs[1] bits, little endian.
+// inputs[0] is the total width, which must be covered by the limbs.
+func RangeDecomposeHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	width := int(inputs[0].Int64())
+	limbBits := uint(inputs[1].Int64())
+	x := new(big.Int).Set(inputs[2])
+	if x.BitLen() > width {
+		return fmt.Errorf("value doesn't fit in %d bits", width)
+	}
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), limbBits), big.NewInt(1))
+	for i := range outputs {
+		outputs[i].And(x, mask)
+		x.Rsh(x, limbBits)
+	}
+	return nil
+}
+
+// Check implements frontend.Rangechecker, so that gnark gadgets (e.g. std/rangecheck and
+// std/math/emulated) use it instead of a commitment based range check, which involves
+// divisions by the commitment. It asserts that v fits in nbBits bits.
+//
+// v is decomposed into limbs which are looked up in a shared table of 2^rangeTableBits rows in
+// the root circuit. Subcircuits can't query tables, so they return the values to look up as
+// extra outputs, which their callers look up in turn. The range checks added by the deferred
+// functions of a subcircuit, once its outputs are set, use a binary decomposition.
+func (builder *builder) Check(v frontend.Variable, nbBits int) {
+	if nbBits < 0 {
+		panic("invalid number of bits")
+	}
+	if c, ok := builder.ConstantValue(v); ok {
+		if c.BitLen() > nbBits {
+			panic(fmt.Sprintf("range check failed: constant %s doesn't fit in %d bits%s", c, nbBits, builder.callerSuffix()))
+		}
+		return
+	}
+	if nbBits == 0 {
+		builder.AssertIsEqual(v, 0)
+		return
+	}
+	// the recomposition must not wrap around the modulus
+	if builder.sealed || nbBits >= builder.field.FieldBitLen() {
+		builder.ToBinary(v, nbBits)
+		return
+	}
+
+	nbLimbs := (nbBits + rangeTableBits - 1) / rangeTableBits
+	limbs, err := builder.NewHint(RangeDecomposeHint, nbLimbs, nbBits, rangeTableBits, v)
+	if err != nil {
+		panic(err)
+	}
+	acc := frontend.Variable(0)
+	for i := nbLimbs - 1; i >= 0; i-- {
+		builder.queryRange(limbs[i])
+		acc = builder.Add(builder.Mul(acc, 1<<rangeTableBits), limbs[i])
+	}
+	// the last limb must have the remaining bits only
+	if r := nbBits % rangeTableBits; r != 0 {
+		builder.queryRange(builder.Mul(limbs[nbLimbs-1], 1<<(rangeTableBits-r)))
+	}
+	builder.AssertIsEqual(acc, v)
+}
+
+// queryRange asserts that x fits in rangeTableBits bits, by a query to the range table in the
+// root circuit, and by returning x to the caller in a subcircuit.
+func (builder *builder) queryRange(x frontend.Variable) {
+	if builder.root.builder == builder {
+		builder.root.rangeTable().Query(x)
+	} else if builder.sealed {
+		builder.ToBinary(x, rangeTableBits)
+	} else {
+		builder.rangeQueries = append(builder.rangeQueries, x)
+	}
+}
+
+// rangeTable returns the table of all the values of rangeTableBits bits, creating it on first use.
+func (r *Root) rangeTable() *LookupTable {
+	if r.rangeChecks == nil {
+		r.rangeChecks = r.NewTable(1)
+		for i := 0; i < 1<<rangeTableBits; i++ {
+			r.rangeChecks.Insert(i)
+		}
+	}
+	return r.rangeChecks
+}
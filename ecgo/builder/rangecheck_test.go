@@ -0,0 +1,109 @@
+package builder
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+	"github.com/consensys/gnark/std/math/emulated"
+	"github.com/consensys/gnark/std/rangecheck"
+)
+
+func TestRangeCheck(t *testing.T) {
+	for _, c := range []struct {
+		x     int64
+		width int
+		ok    bool
+	}{
+		{200, 8, true},
+		{255, 8, true},
+		{256, 8, false},
+		{4095, 12, true},
+		{4096, 12, false},
+		{0, 1, true},
+		{1 << 40, 41, true},
+		{1 << 40, 40, false},
+	} {
+		root := NewRoot(ecc.BN254.ScalarField(), frontend.CompileConfig{})
+		x := root.SecretVariable(schema.LeafInfo{})
+		rangecheck.New(root).Check(x, c.width)
+		err := evalRoot(root.Finalize(), []*big.Int{big.NewInt(c.x)})
+		if (err == nil) != c.ok {
+			t.Fatalf("range check of %d on %d bits: unexpected result %v", c.x, c.width, err)
+		}
+	}
+}
+
+func TestEmulatedArithmetic(t *testing.T) {
+	p := emulated.Secp256k1Fp{}.Modulus()
+	a, _ := new(big.Int).SetString("55066263022277343669578718895168534326250603453777594175500187360389116729240", 10)
+	b, _ := new(big.Int).SetString("32670510020758816978083085130507043184471273380659243275938904335757337482424", 10)
+	c := new(big.Int).Mul(a, b)
+	c.Mod(c, p)
+	d := new(big.Int).Add(c, big.NewInt(1))
+	for _, v := range []struct {
+		c  *big.Int
+		ok bool
+	}{{c, true}, {d, false}} {
+		// secret inputs must be declared first
+		root := NewRoot(ecc.BN254.ScalarField(), frontend.CompileConfig{})
+		inputs := []*big.Int{}
+		limbs := [][]frontend.Variable{}
+		mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 64), big.NewInt(1))
+		for _, x := range []*big.Int{a, b, v.c} {
+			l := make([]frontend.Variable, 4)
+			for i := range l {
+				l[i] = root.SecretVariable(schema.LeafInfo{})
+				inputs = append(inputs, new(big.Int).And(new(big.Int).Rsh(x, uint(64*i)), mask))
+			}
+			limbs = append(limbs, l)
+		}
+		f, err := emulated.NewField[emulated.Secp256k1Fp](root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ea, eb, ec := f.NewElement(limbs[0]), f.NewElement(limbs[1]), f.NewElement(limbs[2])
+		f.AssertIsEqual(f.Mul(ea, eb), ec)
+		if root.rangeChecks == nil {
+			t.Fatal("expected emulated arithmetic to use the range table")
+		}
+		err = evalRoot(root.Finalize(), inputs)
+		if (err == nil) != v.ok {
+			t.Fatalf("expected ok=%v, got %v", v.ok, err)
+		}
+	}
+}
+
+func checkInSubCircuit(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	rangecheck.New(api).Check(input[0], 30)
+	return []frontend.Variable{api.Add(input[0], 1)}
+}
+
+func checkInNestedSubCircuit(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	return api.(*builder).MemorizedSimpleCall(checkInSubCircuit, input)
+}
+
+func TestRangeCheckInSubCircuit(t *testing.T) {
+	for _, c := range []struct {
+		x  int64
+		ok bool
+	}{{1<<30 - 1, true}, {1 << 30, false}} {
+		root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+		x := root.SecretVariable(schema.LeafInfo{})
+		y := root.MemorizedSimpleCall(checkInNestedSubCircuit, []frontend.Variable{x})
+		if len(y) != 1 {
+			t.Fatalf("expected the range queries not to be returned, got %d outputs", len(y))
+		}
+		root.AssertIsEqual(y[0], root.Add(x, 1))
+		if root.rangeChecks == nil {
+			t.Fatal("expected the subcircuit range check to use the range table")
+		}
+		err := evalRoot(root.Finalize(), []*big.Int{big.NewInt(c.x)})
+		if (err == nil) != c.ok {
+			t.Fatalf("range check of %d on 30 bits: unexpected result %v", c.x, err)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/gnarkexpr"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/schema"
 )
@@ -19,6 +20,46 @@ type Root struct {
 	registry *SubCircuitRegistry
 
 	nbPublicInputs int
+	// slots of the public variables allocated as inputs, see LeadingPublicVariable
+	leadingPublicSlots []int
+
+	// table of small values used by range checks, see Check
+	rangeChecks *LookupTable
+
+	// source locations of the instructions and constraints, see SetSourceLocationDepth
+	locations locations
+
+	// whether Println calls are ignored, see SetDebugPrints
+	noDebugPrints bool
+
+	// named outputs, see Tag, and the prefix of their names, see SetTagPrefix
+	tags      []OutputTag
+	tagPrefix string
+
+	// challenges drawn by name, see Challenge
+	challenges     map[string]frontend.Variable
+	challengeNames []string
+	// number of challenges drawn by Commit
+	commitments int
+
+	// number of AssertIsBoolean calls for which no constraint was added, see
+	// SkippedBooleanAssertions
+	skippedBooleans int
+
+	// number of instructions of all the builders, and the callback of SetProgress
+	nbInstructions int
+	progress       func(nbInstructions int)
+
+	// growth strategy of the builders, see SetGrowth
+	growth Growth
+
+	// callbacks observing the builders, see SetHooks
+	hooks Hooks
+
+	// variables of all the builders, see ResetArena
+	vars *gnarkexpr.Arena
+	// chunks from which the operands of the instructions are allocated
+	slabs slabs
 }
 
 // NewRoot returns a new Root instance.
@@ -27,9 +68,11 @@ func NewRoot(fieldorder *big.Int, config frontend.CompileConfig) *Root {
 		config: config,
 	}
 	root.field = field.GetFieldFromOrder(fieldorder)
-	root.registry = newSubCircuitRegistry()
+	root.registry = newSubCircuitRegistry(0)
+	root.locations = newLocations()
+	root.vars = gnarkexpr.NewArena()
 
-	root.builder = root.newBuilder(0)
+	root.builder = root.newBuilder(0, config.Capacity)
 	root.registry.m[0] = &SubCircuit{
 		builder: root.builder,
 	}
@@ -37,16 +80,70 @@ func NewRoot(fieldorder *big.Int, config frontend.CompileConfig) *Root {
 	return &root
 }
 
+// ProgressInterval is the number of instructions between two calls of the callback of SetProgress.
+const ProgressInterval = 1 << 16
+
+// SetProgress sets a callback called every ProgressInterval instructions added to the circuit or
+// its subcircuits, with the number of instructions so far. It may panic to abort the build.
+func (r *Root) SetProgress(f func(nbInstructions int)) {
+	r.progress = f
+}
+
+// SkippedBooleanAssertions returns the number of AssertIsBoolean calls, in the circuit and its
+// subcircuits, on variables already known to be boolean, for which no constraint was added: the
+// ones already asserted, e.g. by several gadgets, and the results of boolean operations or
+// MarkBoolean.
+func (r *Root) SkippedBooleanAssertions() int {
+	return r.skippedBooleans
+}
+
+// ResetArena releases the variables and the operand slabs allocated by the builders, once the
+// circuit is finalized. The variables held by the circuit stay valid, but no variable may be
+// created afterwards.
+func (r *Root) ResetArena() {
+	r.vars.Reset()
+	r.slabs = slabs{}
+}
+
 // PublicVariable creates a new public variable for the circuit.
 func (r *Root) PublicVariable(f schema.LeafInfo) frontend.Variable {
-	r.instructions = append(r.instructions, irsource.Instruction{
+	return r.PublicVariableAt(f, r.nbPublicInputs)
+}
+
+// PublicVariableAt creates a new public variable read from the given slot of the public inputs.
+// The caller must use each slot in [0, number of public inputs) exactly once.
+func (r *Root) PublicVariableAt(f schema.LeafInfo, slot int) frontend.Variable {
+	r.addInstruction(irsource.Instruction{
 		Type:    irsource.ConstantLike,
-		ExtraId: 2 + uint64(r.nbPublicInputs),
+		ExtraId: 2 + uint64(slot),
 	})
 	r.nbPublicInputs++
 	return r.addVar()
 }
 
+// LeadingPublicVariable creates a new public variable before the secret variables, as gnark's
+// frontend.Compile does, while the secret variables must be the first variables of the circuit.
+// The variable is allocated as an input, and Finalize moves it to the public inputs, read from
+// the next slot unless SetLeadingPublicSlots is called.
+func (r *Root) LeadingPublicVariable(f schema.LeafInfo) frontend.Variable {
+	if len(r.instructions) != 0 || r.nbExternalInput != len(r.leadingPublicSlots) {
+		panic("leading public variables must be created before the other variables")
+	}
+	r.leadingPublicSlots = append(r.leadingPublicSlots, r.nbPublicInputs)
+	r.nbPublicInputs++
+	r.builder.nbExternalInput++
+	return r.addVar()
+}
+
+// SetLeadingPublicSlots sets the slots of the public inputs read by the variables created by
+// LeadingPublicVariable, in the order of their creation.
+func (r *Root) SetLeadingPublicSlots(slots []int) {
+	if len(slots) != len(r.leadingPublicSlots) {
+		panic("a slot is required for each leading public variable")
+	}
+	copy(r.leadingPublicSlots, slots)
+}
+
 // SecretVariable creates a new secret variable for the circuit.
 func (r *Root) SecretVariable(f schema.LeafInfo) frontend.Variable {
 	r.builder.nbExternalInput++
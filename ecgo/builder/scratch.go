@@ -0,0 +1,60 @@
+package builder
+
+import (
+	"crypto/sha256"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// scratcher is implemented by the builders of this package, including Root.
+type scratcher interface {
+	scratch(input []frontend.Variable, f SubCircuitSimpleFunc) []frontend.Variable
+}
+
+// Scratch returns f(api, input), with the temporaries of f, the variables it creates, scoped to
+// the call: only the returned variables are visible to the rest of the circuit, and f must only
+// use the variables of its input, like a subcircuit.
+//
+// The scope is compiled as a call of a subcircuit, so that its temporaries only occupy the wires
+// of the layers of the call, which starts once all its inputs are computed, instead of being
+// computed as early as possible and relayed through the layers of the caller until their last
+// use. The scopes of the same structure share their subcircuit, so that the wire slots of the
+// temporaries are reused by all the calls of a gadget. Unlike MemorizedSimpleCall, f is called
+// each time, so it may depend on Go values changing between the calls, e.g. the constants of a
+// closure, which give scopes of different structures. f can't register deferred functions.
+//
+// If api isn't an ecgo builder, e.g. in the gnark test engine, f is called directly.
+func Scratch(api frontend.API, input []frontend.Variable, f SubCircuitSimpleFunc) []frontend.Variable {
+	b, ok := api.(scratcher)
+	if !ok {
+		return f(api, input)
+	}
+	return b.scratch(input, f)
+}
+
+// scratchBuildingId is the id under which the scopes nested depth levels deep are built, which
+// no subcircuit has, since the id of a scope is only known once it's built
+func scratchBuildingId(depth int) uint64 {
+	return ^uint64(depth)
+}
+
+func (parent *builder) scratch(input []frontend.Variable, f SubCircuitSimpleFunc) []frontend.Variable {
+	ids := parent.toVariableIds(input...)
+	name := GetFuncName(f)
+	sr := parent.root.registry
+	sr.enter(scratchBuildingId(len(sr.building)), name)
+	sub := parent.root.buildSubCircuit(name, len(ids), f)
+	sr.leave()
+	if len(sub.builder.defers) != 0 {
+		panic("Scratch: " + name + " registered deferred functions, which can't run in a scope")
+	}
+	// the id depends on the body only, so that the calls of ParallelDefine agree on it
+	h := sub.structuralHash()
+	circuitId := sr.getFullHashId(sha256.Sum256(append([]byte("scratch_"), h[:]...)))
+	if def := sr.resolve(circuitId); sr.m[def] != nil {
+		circuitId = def
+	} else {
+		circuitId = sr.register(circuitId, sub)
+	}
+	return parent.addSubCircuitCall(circuitId, ids)
+}
@@ -0,0 +1,59 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// scaledCube returns a gadget computing k*x^3 with temporaries, range checking x
+func scaledCube(k int) SubCircuitSimpleFunc {
+	return func(api frontend.API, input []frontend.Variable) []frontend.Variable {
+		api.(frontend.Rangechecker).Check(input[0], 8)
+		sq := api.Mul(input[0], input[0])
+		return []frontend.Variable{api.Mul(sq, input[0], k)}
+	}
+}
+
+func TestScratch(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	y := root.SecretVariable(schema.LeafInfo{})
+	a := Scratch(root, []frontend.Variable{x}, scaledCube(2))[0]
+	b := Scratch(root, []frontend.Variable{y}, scaledCube(2))[0]
+	c := Scratch(root, []frontend.Variable{x}, scaledCube(3))[0]
+	nested := Scratch(root, []frontend.Variable{a, b}, func(api frontend.API, input []frontend.Variable) []frontend.Variable {
+		return Scratch(api, []frontend.Variable{api.Add(input[0], input[1])}, scaledCube(1))
+	})[0]
+	root.AssertIsEqual(root.Add(a, b, c, nested), 0)
+	rc := root.Finalize()
+	// the structures of scaledCube for each k, and the scope calling one of them
+	if len(rc.Circuits) != 5 {
+		t.Fatalf("expected 5 circuits, got %d", len(rc.Circuits))
+	}
+	if err := evalRoot(rc, bigInts(2, 3)); err == nil {
+		t.Fatal("expected the assertion on the outputs to fail")
+	}
+	if err := evalRoot(rc, bigInts(256, 0)); err == nil || !strings.Contains(err.Error(), "fit in 8 bits") {
+		t.Fatalf("expected the range check of a scope to be kept, got %v", err)
+	}
+	if err := evalRoot(rc, bigInts(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScratchDefer(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "deferred functions") {
+			t.Fatalf("expected a panic for a deferred function, got %v", r)
+		}
+	}()
+	Scratch(root, nil, func(api frontend.API, input []frontend.Variable) []frontend.Variable {
+		api.Compiler().Defer(func(api frontend.API) error { return nil })
+		return nil
+	})
+}
@@ -0,0 +1,55 @@
+package builder
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// StepCircuit is a transition function, like the step of a VM, applied by RunSteps.
+type StepCircuit interface {
+	// Step returns the state following state, given the inputs of the step.
+	Step(api frontend.API, state []frontend.Variable, input []frontend.Variable) []frontend.Variable
+}
+
+// stepArgs are the arguments of the subcircuit of a step
+type stepArgs struct {
+	State []frontend.Variable
+	Input []frontend.Variable
+}
+
+func runStep[S StepCircuit](api frontend.API, c S, in stepArgs) []frontend.Variable {
+	res := c.Step(api, in.State, in.Input)
+	if len(res) != len(in.State) {
+		panic(fmt.Sprintf("RunSteps: step returned %d state variables, expected %d", len(res), len(in.State)))
+	}
+	return res
+}
+
+// RunSteps applies c to initial once per element of inputs, each step getting the state returned
+// by the previous one, and returns the final state.
+//
+// Each step is a call to the same memorized subcircuit, c being the parameters of the
+// subcircuit like with MemorizedCallN, so c must not hold variables. The intermediate states are
+// pulled back to the first layer (see ToFirstLayer): the input solver still computes them step by
+// step, but in the layered circuit every step only depends on the inputs, so the steps are
+// placed side by side in the same layers. The depth of the circuit is the depth of a single step
+// whatever the number of steps, and its layers are N copies of the step, which is the uniform
+// structure GKR provers are fastest on.
+//
+// If api isn't an ecgo builder, the steps are chained directly.
+func RunSteps[S StepCircuit](api frontend.API, c S, initial []frontend.Variable, inputs [][]frontend.Variable) []frontend.Variable {
+	b, layered := api.(API)
+	state := initial
+	for i, input := range inputs {
+		state = MemorizedCallN(api, runStep[S], c, stepArgs{State: state, Input: input})
+		if layered && i+1 < len(inputs) {
+			next := make([]frontend.Variable, len(state))
+			for j, x := range state {
+				next[j] = b.ToFirstLayer(x)
+			}
+			state = next
+		}
+	}
+	return state
+}
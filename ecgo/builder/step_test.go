@@ -0,0 +1,78 @@
+package builder
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// fibStep maps (a, b) to (b, k*a + b + x)
+type fibStep struct {
+	k int
+}
+
+func (s fibStep) Step(api frontend.API, state []frontend.Variable, input []frontend.Variable) []frontend.Variable {
+	return []frontend.Variable{state[1], api.Add(api.Mul(state[0], s.k), state[1], input[0])}
+}
+
+func TestRunSteps(t *testing.T) {
+	const n = 6
+	for _, ok := range []bool{true, false} {
+		root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+		initial := []frontend.Variable{root.SecretVariable(schema.LeafInfo{}), root.SecretVariable(schema.LeafInfo{})}
+		inputs := make([][]frontend.Variable, n)
+		for i := range inputs {
+			inputs[i] = []frontend.Variable{root.SecretVariable(schema.LeafInfo{})}
+		}
+		final := RunSteps(root, fibStep{k: 2}, initial, inputs)
+		// another parameter is another subcircuit
+		RunSteps(root, fibStep{k: 3}, initial, inputs[:1])
+		a, b := int64(1), int64(1)
+		values := []*big.Int{big.NewInt(a), big.NewInt(b)}
+		for i := int64(0); i < n; i++ {
+			a, b = b, 2*a+b+i
+			values = append(values, big.NewInt(i))
+		}
+		if !ok {
+			b++
+		}
+		root.AssertIsEqual(final[1], b)
+
+		rc := root.Finalize()
+		if len(rc.Circuits) != 3 {
+			t.Fatalf("expected 2 step circuits, got %d circuits", len(rc.Circuits)-1)
+		}
+		// the arguments of every step are inputs or hints, so the steps don't depend on each other
+		c := rc.Circuits[0]
+		independent := make(map[int]bool)
+		for i := 1; i <= c.NumInputs; i++ {
+			independent[i] = true
+		}
+		v := c.NumInputs + 1
+		calls := 0
+		for _, insn := range c.Instructions {
+			if insn.Type == irsource.SubCircuitCall {
+				calls++
+				for _, x := range insn.Inputs {
+					if !independent[x] {
+						t.Fatalf("step %d depends on a previous step", calls)
+					}
+				}
+			}
+			for j := 0; j < insn.OutputCount(); j++ {
+				independent[v+j] = insn.Type == irsource.Hint || insn.Type == irsource.ConstantLike
+			}
+			v += insn.OutputCount()
+		}
+		if calls != n+1 {
+			t.Fatalf("expected %d steps, got %d", n+1, calls)
+		}
+		if err := evalRoot(rc, values); (err == nil) != ok {
+			t.Fatalf("ok=%t: unexpected result %v", ok, err)
+		}
+	}
+}
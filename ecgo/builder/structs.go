@@ -0,0 +1,51 @@
+package builder
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// Leaves returns the variables held by x, a struct, array or slice of variables or a pointer to
+// one, with their full names, in the order of gnark's schema package, see schema.Walk.
+func Leaves(x any) ([]frontend.Variable, []string) {
+	var vars []frontend.Variable
+	var names []string
+	_, err := schema.Walk(x, tVariable, func(f schema.LeafInfo, v reflect.Value) error {
+		vars = append(vars, v.Interface())
+		names = append(names, f.FullName())
+		return nil
+	})
+	if err != nil {
+		panic(fmt.Sprintf("invalid value of variables: %v", err))
+	}
+	return vars, names
+}
+
+// AssertStructsEqual asserts that a and b are equal leaf by leaf, e.g. the state computed by a
+// state-transition circuit and the expected one. a and b are structs, arrays or slices of
+// variables, or pointers to them, with the same leaves, see Leaves. The equalities are asserted
+// at once with AssertIsEqualVec if api is an ecgo builder, and one by one otherwise.
+func AssertStructsEqual(api frontend.API, a, b any) {
+	x, xNames := Leaves(a)
+	y, yNames := Leaves(b)
+	if len(x) != len(y) {
+		panic(fmt.Sprintf("AssertStructsEqual: values of %d and %d variables", len(x), len(y)))
+	}
+	for i := range xNames {
+		if xNames[i] != yNames[i] {
+			panic(fmt.Sprintf("AssertStructsEqual: variable %d is %s in the first value and %s in the second one", i, xNames[i], yNames[i]))
+		}
+	}
+	if v, ok := api.(interface {
+		AssertIsEqualVec(a, b []frontend.Variable)
+	}); ok {
+		v.AssertIsEqualVec(x, y)
+		return
+	}
+	for i := range x {
+		api.AssertIsEqual(x[i], y[i])
+	}
+}
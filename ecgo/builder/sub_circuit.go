@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"runtime"
 	"strconv"
+	"strings"
 
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
 	"github.com/consensys/gnark/frontend"
@@ -22,6 +23,9 @@ type SubCircuitFunc interface{}
 // SubCircuit represents a subcircuit with its own builder and additional information.
 type SubCircuit struct {
 	builder *builder
+	name    string
+	// call site that built the subcircuit
+	site irsource.SourceLocation
 }
 
 // SubCircuitRegistry manages the subcircuit context of each possible subcircuit
@@ -29,10 +33,103 @@ type SubCircuit struct {
 // 1. function name
 // 2. value of non frontend.Variable args
 // 3. shape of slice of fontend.Variable args
+//
+// Subcircuits built by different calls may have identical bodies, in which case they are
+// deduplicated through their structural hash and all ids are aliased to the first definition.
 type SubCircuitRegistry struct {
 	m               map[uint64]*SubCircuit
 	outputStructure map[uint64]*sliceStructure
+	outputTemplate  map[uint64]reflect.Value
 	fullHash        map[uint64][32]byte
+	structuralHash  map[[32]byte]uint64
+	alias           map[uint64]uint64
+	// ids of the definitions in m, in the order of their registration
+	order []uint64
+
+	// subcircuits being built, outermost first
+	building []buildingSubCircuit
+}
+
+type buildingSubCircuit struct {
+	id   uint64
+	name string
+}
+
+// maxSubCircuitDepth is the maximum nesting of subcircuits being built at the same time
+const maxSubCircuitDepth = 1024
+
+// SubCircuitCycleError reports a subcircuit that calls itself, directly or through other
+// subcircuits, with arguments of the same shape. Such a circuit would never finish building.
+// Cycle holds the names of the functions involved, starting and ending with the repeated one.
+type SubCircuitCycleError struct {
+	Cycle []string
+}
+
+func (e *SubCircuitCycleError) Error() string {
+	return "subcircuit calls itself: " + strings.Join(e.Cycle, " -> ")
+}
+
+func (e *SubCircuitCycleError) Unwrap() error {
+	return ErrInvalidSubCircuit
+}
+
+// SubCircuitDepthError reports subcircuits nested deeper than the builder supports, usually
+// because of an unbounded recursion whose arguments change at each level.
+type SubCircuitDepthError struct {
+	Depth int
+	Name  string
+}
+
+func (e *SubCircuitDepthError) Error() string {
+	return fmt.Sprintf("subcircuits nested more than %d levels deep, at %s", e.Depth, e.Name)
+}
+
+func (e *SubCircuitDepthError) Unwrap() error {
+	return ErrInvalidSubCircuit
+}
+
+// SubCircuitSignatureError reports a call to a subcircuit with a number of inputs different from
+// the call that built it, which would otherwise read or write variables out of range. The ids of
+// subcircuits depend on the number of inputs, so it's the sign of an id collision.
+type SubCircuitSignatureError struct {
+	Name string
+	// Sites are the locations of the call that built the subcircuit and of the failing call.
+	Sites [2]irsource.SourceLocation
+	// Inputs are the numbers of inputs of both calls.
+	Inputs [2]int
+	// Outputs is the number of outputs of the subcircuit.
+	Outputs int
+}
+
+func (e *SubCircuitSignatureError) Error() string {
+	return fmt.Sprintf("subcircuit %s called with %d inputs at %s, but built with %d inputs and %d outputs at %s",
+		e.Name, e.Inputs[1], e.Sites[1], e.Inputs[0], e.Outputs, e.Sites[0])
+}
+
+func (e *SubCircuitSignatureError) Unwrap() error {
+	return ErrInvalidSubCircuit
+}
+
+// enter records that the subcircuit circuitId is being built, and panics with a
+// SubCircuitCycleError or SubCircuitDepthError if it can't be.
+func (sr *SubCircuitRegistry) enter(circuitId uint64, name string) {
+	for i, b := range sr.building {
+		if b.id == circuitId {
+			cycle := []string{}
+			for _, c := range sr.building[i:] {
+				cycle = append(cycle, c.name)
+			}
+			panic(&SubCircuitCycleError{Cycle: append(cycle, name)})
+		}
+	}
+	if len(sr.building) >= maxSubCircuitDepth {
+		panic(&SubCircuitDepthError{Depth: maxSubCircuitDepth, Name: name})
+	}
+	sr.building = append(sr.building, buildingSubCircuit{id: circuitId, name: name})
+}
+
+func (sr *SubCircuitRegistry) leave() {
+	sr.building = sr.building[:len(sr.building)-1]
 }
 
 // SubCircuitAPI defines methods for working with subcircuits.
@@ -41,12 +138,62 @@ type SubCircuitAPI interface {
 	MemorizedCall(SubCircuitFunc, ...interface{}) interface{}
 }
 
-func newSubCircuitRegistry() *SubCircuitRegistry {
+// newSubCircuitRegistry returns an empty registry with room for n subcircuits
+func newSubCircuitRegistry(n int) *SubCircuitRegistry {
 	return &SubCircuitRegistry{
-		m:               make(map[uint64]*SubCircuit),
-		outputStructure: make(map[uint64]*sliceStructure),
-		fullHash:        make(map[uint64][32]byte),
+		m:               make(map[uint64]*SubCircuit, n),
+		outputStructure: make(map[uint64]*sliceStructure, n),
+		outputTemplate:  make(map[uint64]reflect.Value, n),
+		fullHash:        make(map[uint64][32]byte, n),
+		structuralHash:  make(map[[32]byte]uint64, n),
+		alias:           make(map[uint64]uint64),
+		order:           make([]uint64, 0, n),
+	}
+}
+
+// resolve returns the id of the definition that circuitId refers to
+func (sr *SubCircuitRegistry) resolve(circuitId uint64) uint64 {
+	if id, ok := sr.alias[circuitId]; ok {
+		return id
 	}
+	return circuitId
+}
+
+// register adds a newly built subcircuit, and returns the id under which it is stored
+// if an identical subcircuit exists, circuitId becomes an alias of it
+func (sr *SubCircuitRegistry) register(circuitId uint64, sub *SubCircuit) uint64 {
+	// deferred functions may still modify the body, so it can't be hashed yet
+	if len(sub.builder.defers) != 0 {
+		sr.m[circuitId] = sub
+		sr.order = append(sr.order, circuitId)
+		return circuitId
+	}
+	h := sub.structuralHash()
+	if id, ok := sr.structuralHash[h]; ok {
+		sr.alias[circuitId] = id
+		return id
+	}
+	sr.structuralHash[h] = circuitId
+	sr.m[circuitId] = sub
+	sr.order = append(sr.order, circuitId)
+	return circuitId
+}
+
+// structuralHash identifies the body of a built subcircuit, see irsource.Circuit.StructuralHash
+func (sub *SubCircuit) structuralHash() [32]byte {
+	b := sub.builder
+	body := irsource.Circuit{
+		Instructions: b.instructions,
+		Constraints:  b.constraints,
+		Outputs:      b.output,
+		NumInputs:    b.nbExternalInput,
+	}
+	h := body.StructuralHash(b.field)
+	if b.nbRangeOutputs != 0 {
+		// the range queries are returned like outputs, but aren't outputs of the function
+		h = sha256.Sum256(binary.LittleEndian.AppendUint64(h[:], uint64(b.nbRangeOutputs)))
+	}
+	return h
 }
 
 func (sr *SubCircuitRegistry) getFullHashId(h [32]byte) uint64 {
@@ -61,54 +208,98 @@ func (sr *SubCircuitRegistry) getFullHashId(h [32]byte) uint64 {
 	return id
 }
 
+// defineSubCircuit builds the subcircuit f with n inputs under circuitId if it isn't built yet,
+// and returns the id of its definition.
+func (parent *builder) defineSubCircuit(circuitId uint64, name string, n int, f SubCircuitSimpleFunc) uint64 {
+	circuitId = parent.root.registry.resolve(circuitId)
+	if _, ok := parent.root.registry.m[circuitId]; ok {
+		return circuitId
+	}
+	parent.root.registry.enter(circuitId, name)
+	sub := parent.root.buildSubCircuit(name, n, f)
+	parent.root.registry.leave()
+	return parent.root.registry.register(circuitId, sub)
+}
+
+// buildSubCircuit builds the body of the subcircuit f with n inputs
+func (r *Root) buildSubCircuit(name string, n int, f SubCircuitSimpleFunc) *SubCircuit {
+	subBuilder := r.newBuilder(n, r.growth.SubCircuitCapacity)
+	subInput := make([]frontend.Variable, n)
+	for i := 0; i < n; i++ {
+		subInput[i] = subBuilder.newVariable(i + 1)
+	}
+	subOutput := f(subBuilder, subInput)
+	subBuilder.output = make([]int, len(subOutput), len(subOutput)+len(subBuilder.rangeQueries))
+	for i, v := range subOutput {
+		subBuilder.output[i] = subBuilder.toVariableId(v)
+	}
+	for _, v := range subBuilder.rangeQueries {
+		subBuilder.output = append(subBuilder.output, subBuilder.toVariableId(v))
+	}
+	subBuilder.nbRangeOutputs = len(subBuilder.rangeQueries)
+	subBuilder.rangeQueries = nil
+	subBuilder.sealed = true
+	return &SubCircuit{
+		builder: subBuilder,
+		name:    name,
+		site:    callerOutside(),
+	}
+}
+
 func (parent *builder) callSubCircuit(
 	circuitId uint64,
+	name string,
 	input_ []frontend.Variable,
 	f SubCircuitSimpleFunc,
 ) []frontend.Variable {
 	input := parent.toVariableIds(input_...)
-	if _, ok := parent.root.registry.m[circuitId]; !ok {
-		n := len(input)
-		subBuilder := parent.root.newBuilder(n)
-		subInput := make([]frontend.Variable, n)
-		for i := 0; i < n; i++ {
-			subInput[i] = newVariable(i + 1)
-		}
-		subOutput := f(subBuilder, subInput)
-		subBuilder.output = make([]int, len(subOutput))
-		for i, v := range subOutput {
-			subBuilder.output[i] = subBuilder.toVariableId(v)
-		}
-		sub := SubCircuit{
-			builder: subBuilder,
-		}
-		parent.root.registry.m[circuitId] = &sub
-	}
+	circuitId = parent.defineSubCircuit(circuitId, name, len(input), f)
 	sub := parent.root.registry.m[circuitId]
+	if len(input) != sub.builder.nbExternalInput {
+		panic(&SubCircuitSignatureError{
+			Name:    name,
+			Sites:   [2]irsource.SourceLocation{sub.site, callerOutside()},
+			Inputs:  [2]int{sub.builder.nbExternalInput, len(input)},
+			Outputs: len(sub.builder.output) - sub.builder.nbRangeOutputs,
+		})
+	}
+	return parent.addSubCircuitCall(circuitId, input)
+}
 
+// addSubCircuitCall calls the defined subcircuit circuitId on the variables input, and returns
+// its outputs, querying its range queries from the range table
+func (parent *builder) addSubCircuitCall(circuitId uint64, input []int) []frontend.Variable {
+	sub := parent.root.registry.m[circuitId]
 	output := make([]frontend.Variable, len(sub.builder.output))
 	for i := range sub.builder.output {
 		output[i] = parent.addVar()
 	}
 
-	parent.instructions = append(parent.instructions,
-		irsource.Instruction{
-			Type:       irsource.SubCircuitCall,
-			ExtraId:    circuitId,
-			Inputs:     input,
-			NumOutputs: len(output),
-		},
+	parent.addInstruction(irsource.Instruction{
+		Type:       irsource.SubCircuitCall,
+		ExtraId:    circuitId,
+		Inputs:     input,
+		NumOutputs: len(output),
+	},
 	)
 
-	return output
+	n := len(output) - sub.builder.nbRangeOutputs
+	for _, x := range output[n:] {
+		parent.queryRange(x)
+	}
+	return output[:n:n]
 }
 
 // MemorizedSimpleCall memorizes a call to a SubCircuitSimpleFunc.
 func (parent *builder) MemorizedSimpleCall(f SubCircuitSimpleFunc, input []frontend.Variable) []frontend.Variable {
 	name := GetFuncName(f)
-	h := sha256.Sum256([]byte(fmt.Sprintf("simple_%d(%s)_%d", len(name), name, len(input))))
-	circuitId := parent.root.registry.getFullHashId(h)
-	return parent.callSubCircuit(circuitId, input, f)
+	return parent.callSubCircuit(parent.simpleCircuitId(name, len(input)), name, input, f)
+}
+
+// simpleCircuitId returns the id of the subcircuit of the SubCircuitSimpleFunc name with n inputs
+func (parent *builder) simpleCircuitId(name string, n int) uint64 {
+	h := sha256.Sum256([]byte(fmt.Sprintf("simple_%d(%s)_%d", len(name), name, n)))
+	return parent.root.registry.getFullHashId(h)
 }
 
 var frontendAPIType = reflect.TypeOf((*frontend.API)(nil)).Elem()
@@ -233,17 +424,17 @@ func isTypeSimple(t reflect.Type) bool {
 func (parent *builder) MemorizedCall(fn SubCircuitFunc, inputs ...interface{}) interface{} {
 	fnVal := reflect.ValueOf(fn)
 	if fnVal.Kind() != reflect.Func {
-		panic("f is not a function")
+		panic(invalidSubCircuit("f is not a function"))
 	}
 	fnType := fnVal.Type()
 
 	// check function signature
 	numIn := fnType.NumIn()
 	if numIn == 0 {
-		panic("fn should have at least 1 argument")
+		panic(invalidSubCircuit("fn should have at least 1 argument"))
 	}
 	if !isTypeFrontendAPI(fnType.In(0)) {
-		panic("first argument should be a frontend.API")
+		panic(invalidSubCircuit("first argument should be a frontend.API"))
 	}
 	vars := []int{}
 	others := []int{}
@@ -256,18 +447,18 @@ func (parent *builder) MemorizedCall(fn SubCircuitFunc, inputs ...interface{}) i
 		} else if isTypeSimple(argType) {
 			others = append(others, i)
 		} else {
-			panic(fmt.Sprintf("input %d (%v) is not a slice of frontend.Variable or a simple type", i, argType))
+			panic(invalidSubCircuit("input %d (%v) is not a slice of frontend.Variable or a simple type", i, argType))
 		}
 	}
 	numOut := fnType.NumOut()
 	var outLevel int
 	if numOut > 1 {
-		panic(fmt.Sprintf("fn should return at most 1 value, got %d", numOut))
+		panic(invalidSubCircuit("fn should return at most 1 value, got %d", numOut))
 	} else if numOut == 1 {
 		outType := fnType.Out(0)
 		level, ok := getTypeSlicesOfVariables(outType)
 		if !ok {
-			panic("output is not a slice of frontend.Variable")
+			panic(invalidSubCircuit("output is not a slice of frontend.Variable"))
 		}
 		outLevel = level
 	}
@@ -275,7 +466,7 @@ func (parent *builder) MemorizedCall(fn SubCircuitFunc, inputs ...interface{}) i
 	// check if inputs match the function signature
 	variadic := fnType.IsVariadic()
 	if (!variadic && numIn != len(inputs)+1) || (variadic && len(inputs)+1 < numIn-1) {
-		panic(fmt.Sprintf("expected %d args, got %d", numIn, len(inputs)))
+		panic(invalidSubCircuit("expected %d args, got %d", numIn, len(inputs)))
 	}
 	var variadicElemType reflect.Type
 	if variadic {
@@ -287,11 +478,11 @@ func (parent *builder) MemorizedCall(fn SubCircuitFunc, inputs ...interface{}) i
 		inputType := inputVal.Type()
 		if i+1 < numIn-1 || !variadic {
 			if !inputType.AssignableTo(fnType.In(i + 1)) {
-				panic(fmt.Sprintf("input %d (%v) is not assignable to %v", i, inputType, fnType.In(i+1)))
+				panic(invalidSubCircuit("input %d (%v) is not assignable to %v", i, inputType, fnType.In(i+1)))
 			}
 		} else {
 			if !inputType.AssignableTo(variadicElemType) {
-				panic(fmt.Sprintf("input %d (%v) is not assignable to %v", i, inputType, variadicElemType))
+				panic(invalidSubCircuit("input %d (%v) is not assignable to %v", i, inputType, variadicElemType))
 			}
 		}
 		inputVals[i+1] = inputVal
@@ -318,7 +509,7 @@ func (parent *builder) MemorizedCall(fn SubCircuitFunc, inputs ...interface{}) i
 		h.Write([]byte("|"))
 	}
 	for _, i := range others {
-		vs := inputVals[i].String()
+		vs := fmt.Sprint(inputVals[i].Interface())
 		h.Write([]byte(strconv.Itoa(len(vs)) + vs))
 	}
 	var tmp [32]byte
@@ -349,7 +540,7 @@ func (parent *builder) MemorizedCall(fn SubCircuitFunc, inputs ...interface{}) i
 	}
 
 	// call sub-circuit
-	joinedOut := parent.callSubCircuit(circuitId, joinedVars, fnInner)
+	joinedOut := parent.callSubCircuit(circuitId, name, joinedVars, fnInner)
 	if outStructure == nil {
 		outStructure = parent.root.registry.outputStructure[circuitId]
 	} else {
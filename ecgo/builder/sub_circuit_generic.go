@@ -0,0 +1,183 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"reflect"
+	"strconv"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// subCircuitCaller is implemented by the builders of this package, including Root.
+type subCircuitCaller interface {
+	memorizedCall(name string, h [32]byte, input []frontend.Variable, f SubCircuitSimpleFunc) ([]frontend.Variable, reflect.Value)
+	setOutputTemplate(h [32]byte, template reflect.Value)
+}
+
+// MemorizedCallN calls f as a memorized subcircuit, like MemorizedCall, but with structured
+// arguments. in and the returned value may be any combination of structs, pointers, arrays
+// and slices of frontend.Variable, so f can return several values by returning a struct.
+// Their shape, i.e. slice lengths and non Variable fields, is part of the subcircuit identity.
+// params holds the constant parameters of f: each distinct value gives a distinct subcircuit,
+// specialized for it. It must not contain frontend.Variable, pointers or maps.
+//
+// If api isn't an ecgo builder, e.g. in the gnark test engine, f is called directly.
+func MemorizedCallN[P any, In any, Out any](api frontend.API, f func(api frontend.API, params P, in In) Out, params P, in In) Out {
+	b, ok := api.(subCircuitCaller)
+	if !ok {
+		return f(api, params, in)
+	}
+	paramsVal := reflect.ValueOf(&params).Elem()
+	if containsVariable(paramsVal.Type()) {
+		panic(invalidSubCircuit("params of MemorizedCallN must not contain frontend.Variable, pass them in the input"))
+	}
+
+	name := GetFuncName(f)
+	h := sha256.New()
+	h.Write([]byte(fmt.Sprintf("generic_%d(%s)_", len(name), name)))
+	ps := fmt.Sprintf("%T:%#v", params, params)
+	h.Write([]byte(strconv.Itoa(len(ps)) + ps + "|"))
+	inVal := reflect.ValueOf(&in).Elem()
+	vars := []frontend.Variable{}
+	flattenVariables(&vars, h, inVal)
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+
+	fnInner := func(subApi frontend.API, input []frontend.Variable) []frontend.Variable {
+		cur := 0
+		subIn := rebuildVariables(inVal, input, &cur).Interface().(In)
+		out := f(subApi, params, subIn)
+		outVal := reflect.ValueOf(&out).Elem()
+		b.setOutputTemplate(sum, outVal)
+		res := []frontend.Variable{}
+		flattenVariables(&res, nil, outVal)
+		return res
+	}
+	output, template := b.memorizedCall(name, sum, vars, fnInner)
+	cur := 0
+	return rebuildVariables(template, output, &cur).Interface().(Out)
+}
+
+func (parent *builder) memorizedCall(name string, h [32]byte, input []frontend.Variable, f SubCircuitSimpleFunc) ([]frontend.Variable, reflect.Value) {
+	circuitId := parent.root.registry.getFullHashId(h)
+	output := parent.callSubCircuit(circuitId, name, input, f)
+	return output, parent.root.registry.outputTemplate[circuitId]
+}
+
+func (parent *builder) setOutputTemplate(h [32]byte, template reflect.Value) {
+	parent.root.registry.outputTemplate[parent.root.registry.getFullHashId(h)] = template
+}
+
+// containsVariable returns whether values of type t may hold a frontend.Variable
+func containsVariable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if containsVariable(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	case reflect.Array, reflect.Slice, reflect.Pointer, reflect.Map, reflect.Chan:
+		return containsVariable(t.Elem())
+	default:
+		return false
+	}
+}
+
+// flattenVariables appends the frontend.Variable leaves of v to res, in field and index order,
+// and writes the shape of v and the values of its other leaves to h if it's not nil.
+func flattenVariables(res *[]frontend.Variable, h hash.Hash, v reflect.Value) {
+	write := func(s string) {
+		if h != nil {
+			h.Write([]byte(s))
+		}
+	}
+	if v.Type() == frontendVariableType {
+		*res = append(*res, v.Interface())
+		write("v.")
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		write("{")
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			flattenVariables(res, h, v.Field(i))
+			write(",")
+		}
+		write("}")
+	case reflect.Array, reflect.Slice:
+		write("[" + strconv.Itoa(v.Len()) + ":")
+		for i := 0; i < v.Len(); i++ {
+			flattenVariables(res, h, v.Index(i))
+		}
+		write("]")
+	case reflect.Pointer:
+		if v.IsNil() {
+			write("nil.")
+		} else {
+			write("*")
+			flattenVariables(res, h, v.Elem())
+		}
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.String:
+		s := fmt.Sprint(v.Interface())
+		write(strconv.Itoa(len(s)) + s)
+	default:
+		panic(invalidSubCircuit("unsupported type %v in subcircuit arguments", v.Type()))
+	}
+}
+
+// rebuildVariables returns a copy of template, with its frontend.Variable leaves replaced by
+// vars[*cur:], in the order of flattenVariables.
+func rebuildVariables(template reflect.Value, vars []frontend.Variable, cur *int) reflect.Value {
+	t := template.Type()
+	if t == frontendVariableType {
+		r := reflect.New(t).Elem()
+		r.Set(reflect.ValueOf(&vars[*cur]).Elem())
+		*cur++
+		return r
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		r := reflect.New(t).Elem()
+		r.Set(template)
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				r.Field(i).Set(rebuildVariables(template.Field(i), vars, cur))
+			}
+		}
+		return r
+	case reflect.Array:
+		r := reflect.New(t).Elem()
+		for i := 0; i < template.Len(); i++ {
+			r.Index(i).Set(rebuildVariables(template.Index(i), vars, cur))
+		}
+		return r
+	case reflect.Slice:
+		if template.IsNil() {
+			return template
+		}
+		r := reflect.MakeSlice(t, template.Len(), template.Len())
+		for i := 0; i < template.Len(); i++ {
+			r.Index(i).Set(rebuildVariables(template.Index(i), vars, cur))
+		}
+		return r
+	case reflect.Pointer:
+		if template.IsNil() {
+			return template
+		}
+		r := reflect.New(t.Elem())
+		r.Elem().Set(rebuildVariables(template.Elem(), vars, cur))
+		return r
+	default:
+		return template
+	}
+}
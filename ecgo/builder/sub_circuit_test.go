@@ -0,0 +1,198 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func squareSum(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	return []frontend.Variable{api.Add(api.Mul(input[0], input[0]), input[1])}
+}
+
+func squareSumCopy(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	return []frontend.Variable{api.Add(api.Mul(input[0], input[0]), input[1])}
+}
+
+func squareDiff(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	return []frontend.Variable{api.Sub(api.Mul(input[0], input[0]), input[1])}
+}
+
+func TestSubCircuitStructuralDedup(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	y := root.SecretVariable(schema.LeafInfo{})
+	a := root.MemorizedSimpleCall(squareSum, []frontend.Variable{x, y})
+	b := root.MemorizedSimpleCall(squareSumCopy, []frontend.Variable{y, x})
+	c := root.MemorizedSimpleCall(squareDiff, []frontend.Variable{x, y})
+	root.AssertIsEqual(a[0], b[0])
+	root.AssertIsEqual(a[0], c[0])
+	rc := root.Finalize()
+
+	// root, squareSum and squareDiff
+	if len(rc.Circuits) != 3 {
+		t.Fatalf("expected 3 circuits, got %d", len(rc.Circuits))
+	}
+	ids := []uint64{}
+	for _, insn := range rc.Circuits[0].Instructions {
+		if insn.Type == irsource.SubCircuitCall {
+			ids = append(ids, insn.ExtraId)
+		}
+	}
+	if len(ids) != 3 || ids[0] != ids[1] || ids[0] == ids[2] {
+		t.Fatalf("unexpected subcircuit calls %v", ids)
+	}
+	for _, id := range ids {
+		if _, ok := rc.Circuits[id]; !ok {
+			t.Fatalf("call to unknown subcircuit %d", id)
+		}
+	}
+}
+
+func TestFinalizeDeterministic(t *testing.T) {
+	build := func() [32]byte {
+		root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+		x := root.SecretVariable(schema.LeafInfo{})
+		y := root.SecretVariable(schema.LeafInfo{})
+		a := root.MemorizedSimpleCall(squareSum, []frontend.Variable{x, y})
+		b := root.MemorizedSimpleCall(squareDiff, []frontend.Variable{a[0], y})
+		root.AssertIsEqual(a[0], b[0])
+		return sha256.Sum256(irsource.SerializeRootCircuit(root.Finalize()))
+	}
+	h := build()
+	for i := 0; i < 20; i++ {
+		if build() != h {
+			t.Fatal("building the same circuit twice gave different serializations")
+		}
+	}
+}
+
+type pointIn struct {
+	X, Y frontend.Variable
+	Ext  []frontend.Variable
+}
+
+type pointOut struct {
+	Sum  frontend.Variable
+	Prod [2]frontend.Variable
+}
+
+type scaleParams struct {
+	Scale int
+	Name  string
+}
+
+func scaledPoint(api frontend.API, p scaleParams, in pointIn) pointOut {
+	s := api.Mul(api.Add(in.X, in.Y), p.Scale)
+	for _, e := range in.Ext {
+		s = api.Add(s, e)
+	}
+	return pointOut{Sum: s, Prod: [2]frontend.Variable{api.Mul(in.X, in.Y), api.Mul(in.Y, in.Y)}}
+}
+
+func TestMemorizedCallN(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	y := root.SecretVariable(schema.LeafInfo{})
+	a := MemorizedCallN(root, scaledPoint, scaleParams{2, "a"}, pointIn{X: x, Y: y, Ext: []frontend.Variable{x}})
+	b := MemorizedCallN(root, scaledPoint, scaleParams{2, "a"}, pointIn{X: y, Y: x, Ext: []frontend.Variable{y}})
+	c := MemorizedCallN(root, scaledPoint, scaleParams{3, "a"}, pointIn{X: x, Y: y, Ext: []frontend.Variable{x}})
+	d := MemorizedCallN(root, scaledPoint, scaleParams{2, "a"}, pointIn{X: x, Y: y})
+	root.AssertIsEqual(a.Sum, b.Sum)
+	root.AssertIsEqual(a.Prod[1], c.Prod[0])
+	root.AssertIsEqual(d.Sum, c.Sum)
+	rc := root.Finalize()
+
+	ids := []uint64{}
+	for _, insn := range rc.Circuits[0].Instructions {
+		if insn.Type == irsource.SubCircuitCall {
+			ids = append(ids, insn.ExtraId)
+			if insn.NumOutputs != 3 {
+				t.Fatalf("expected 3 outputs, got %d", insn.NumOutputs)
+			}
+		}
+	}
+	// the parameters and the length of Ext specialize the subcircuit
+	if len(ids) != 4 || ids[0] != ids[1] || ids[0] == ids[2] || ids[0] == ids[3] || ids[2] == ids[3] {
+		t.Fatalf("unexpected subcircuit calls %v", ids)
+	}
+	if len(rc.Circuits) != 4 {
+		t.Fatalf("expected 4 circuits, got %d", len(rc.Circuits))
+	}
+	if a.Sum == b.Sum || a.Prod[0] == b.Prod[0] {
+		t.Fatal("calls returned the same variables")
+	}
+}
+
+func evenCall(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	return api.(SubCircuitAPI).MemorizedSimpleCall(oddCall, input)
+}
+
+func oddCall(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	return api.(SubCircuitAPI).MemorizedSimpleCall(evenCall, input)
+}
+
+func unboundedCall(api frontend.API, n int, x []frontend.Variable) []frontend.Variable {
+	return api.(SubCircuitAPI).MemorizedCall(unboundedCall, n+1, x).([]frontend.Variable)
+}
+
+func TestSubCircuitCycle(t *testing.T) {
+	defer func() {
+		r := recover()
+		e, ok := r.(*SubCircuitCycleError)
+		if !ok {
+			t.Fatalf("expected a SubCircuitCycleError, got %v", r)
+		}
+		if len(e.Cycle) != 3 || !strings.HasSuffix(e.Cycle[0], "evenCall") || !strings.HasSuffix(e.Cycle[1], "oddCall") || e.Cycle[2] != e.Cycle[0] {
+			t.Fatalf("unexpected cycle %v", e.Cycle)
+		}
+	}()
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	root.MemorizedSimpleCall(evenCall, []frontend.Variable{x})
+}
+
+func TestSubCircuitDepth(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected a SubCircuitDepthError")
+		} else if _, ok := r.(*SubCircuitDepthError); !ok {
+			t.Fatalf("expected a SubCircuitDepthError, got %v", r)
+		}
+	}()
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	root.MemorizedCall(unboundedCall, 0, []frontend.Variable{x})
+}
+
+func TestSubCircuitSignature(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	y := root.SecretVariable(schema.LeafInfo{})
+	// the ids of the subcircuits depend on the number of inputs, so a mismatch needs a collision
+	root.callSubCircuit(42, "squareSum", []frontend.Variable{x, y}, squareSum)
+	defer func() {
+		r := recover()
+		e, ok := r.(*SubCircuitSignatureError)
+		if !ok {
+			t.Fatalf("expected a SubCircuitSignatureError, got %v", r)
+		}
+		if e.Inputs != [2]int{2, 1} || e.Outputs != 1 || e.Name != "squareSum" {
+			t.Fatalf("unexpected signatures %+v", e)
+		}
+		for _, site := range e.Sites {
+			if len(site) == 0 || !strings.HasSuffix(site[0].File, "sub_circuit_test.go") {
+				t.Fatalf("unexpected call sites %v", e.Sites)
+			}
+		}
+		if e.Sites[0][0].Line == e.Sites[1][0].Line {
+			t.Fatal("expected both call sites to be reported")
+		}
+	}()
+	root.callSubCircuit(42, "squareSum", []frontend.Variable{x}, squareSum)
+}
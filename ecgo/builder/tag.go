@@ -0,0 +1,43 @@
+package builder
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// OutputTag names an output of the root circuit, see Tag.
+type OutputTag struct {
+	Name string `json:"name"`
+	// Output is the index of the tagged variable in the outputs of the root circuit. In the
+	// layered circuit, it's the index of the wire in the output layer after the outputs expected
+	// to be zero.
+	Output int `json:"output"`
+}
+
+// Tag adds the given variable to the circuit's output under the given name, so that tools can
+// find the wire in the compiled circuit, see Root.Tags. Names must be unique.
+func (builder *builder) Tag(x frontend.Variable, name string) {
+	if builder.root.builder != builder {
+		panic("Tag can only be called on root circuit")
+	}
+	name = builder.root.tagPrefix + name
+	for _, t := range builder.root.tags {
+		if t.Name == name {
+			panic(fmt.Sprintf("duplicate tag %q", name))
+		}
+	}
+	builder.root.tags = append(builder.root.tags, OutputTag{Name: name, Output: len(builder.output)})
+	builder.Output(x)
+}
+
+// Tags returns the outputs named by Tag, in the order of the calls.
+func (r *Root) Tags() []OutputTag {
+	return r.tags
+}
+
+// SetTagPrefix sets a prefix added to the names of the next calls of Tag, e.g. to tell apart the
+// tags of several circuits defined in the same root.
+func (r *Root) SetTagPrefix(prefix string) {
+	r.tagPrefix = prefix
+}
@@ -1,7 +1,52 @@
 package builder
 
-import "github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/gnarkexpr"
+import (
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/gnarkexpr"
+	"github.com/consensys/gnark/constraint"
+)
 
-func newVariable(id int) gnarkexpr.Expr {
-	return gnarkexpr.NewVar(id)
+const (
+	intSlabSize     = 1 << 12
+	elementSlabSize = 1 << 10
+)
+
+// slabs are the chunks from which the builders of a root allocate the operands of the
+// instructions, see allocInts
+type slabs struct {
+	ints     []int
+	elements []constraint.Element
+}
+
+// newVariable returns the variable with the given id, allocated in the arena of the root
+func (builder *builder) newVariable(id int) gnarkexpr.Expr {
+	return builder.root.vars.Var(id)
+}
+
+// allocInts returns n zero ints carved from the slab of the root. The capacity of the result is
+// n, so that appending to it reallocates instead of overwriting the next slice.
+func (builder *builder) allocInts(n int) []int {
+	s := &builder.root.slabs
+	if n > intSlabSize/16 {
+		return make([]int, n)
+	}
+	if len(s.ints) < n {
+		s.ints = make([]int, intSlabSize)
+	}
+	res := s.ints[:n:n]
+	s.ints = s.ints[n:]
+	return res
+}
+
+// allocElements is like allocInts for field elements.
+func (builder *builder) allocElements(n int) []constraint.Element {
+	s := &builder.root.slabs
+	if n > elementSlabSize/16 {
+		return make([]constraint.Element, n)
+	}
+	if len(s.elements) < n {
+		s.elements = make([]constraint.Element, elementSlabSize)
+	}
+	res := s.elements[:n:n]
+	s.elements = s.elements[n:]
+	return res
 }
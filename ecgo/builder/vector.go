@@ -0,0 +1,148 @@
+package builder
+
+import (
+	"fmt"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+)
+
+// checkVecLen panics if the operands of a vector operation have different lengths
+func checkVecLen(name string, a, b []frontend.Variable) {
+	if len(a) != len(b) {
+		panic(fmt.Sprintf("%s: vectors of different lengths %d and %d", name, len(a), len(b)))
+	}
+}
+
+// AddVec returns the element-wise sum of a and b. The operands of all the additions are
+// allocated at once, and the additions are independent, so they're placed in a single layer.
+func (builder *builder) AddVec(a, b []frontend.Variable) []frontend.Variable {
+	checkVecLen("AddVec", a, b)
+	ids := builder.allocInts(2 * len(a))
+	coef := builder.allocElements(2 * len(a))
+	res := make([]frontend.Variable, len(a))
+	for i := range a {
+		x, y := builder.toVariableId(a[i]), builder.toVariableId(b[i])
+		if cx, ok := builder.constantValue(x); ok {
+			if cy, ok := builder.constantValue(y); ok {
+				res[i] = builder.toVariable(builder.field.Add(cx, cy))
+				continue
+			}
+		}
+		ids[2*i], ids[2*i+1] = x, y
+		coef[2*i], coef[2*i+1] = builder.tOne, builder.tOne
+		builder.addInstruction(irsource.Instruction{
+			Type:        irsource.LinComb,
+			Inputs:      ids[2*i : 2*i+2 : 2*i+2],
+			LinCombCoef: coef[2*i : 2*i+2 : 2*i+2],
+		})
+		res[i] = builder.addVar()
+	}
+	return res
+}
+
+// MulVec returns the element-wise product of a and b, like AddVec.
+func (builder *builder) MulVec(a, b []frontend.Variable) []frontend.Variable {
+	checkVecLen("MulVec", a, b)
+	ids := builder.allocInts(2 * len(a))
+	res := make([]frontend.Variable, len(a))
+	for i := range a {
+		x, y := builder.toVariableId(a[i]), builder.toVariableId(b[i])
+		if cx, ok := builder.constantValue(x); ok {
+			if cy, ok := builder.constantValue(y); ok {
+				res[i] = builder.toVariable(builder.field.Mul(cx, cy))
+				continue
+			}
+		}
+		ids[2*i], ids[2*i+1] = x, y
+		builder.addInstruction(irsource.Instruction{
+			Type:   irsource.Mul,
+			Inputs: ids[2*i : 2*i+2 : 2*i+2],
+		})
+		res[i] = builder.addVar()
+	}
+	return res
+}
+
+// AssertIsEqualVec asserts that a and b are equal element-wise. The differences are computed by
+// independent linear combinations whose operands are allocated at once, like AddVec, and the
+// elements which are the same variable, or equal constants, add no constraint.
+func (builder *builder) AssertIsEqualVec(a, b []frontend.Variable) {
+	checkVecLen("AssertIsEqualVec", a, b)
+	ids := builder.allocInts(2 * len(a))
+	coef := builder.allocElements(2 * len(a))
+	minusOne := builder.field.Neg(builder.tOne)
+	for i := range a {
+		x, y := builder.toVariableId(a[i]), builder.toVariableId(b[i])
+		if x == y {
+			continue
+		}
+		if cx, ok := builder.constantValue(x); ok {
+			if cy, ok := builder.constantValue(y); ok {
+				if cx != cy {
+					panic(fmt.Sprintf("AssertIsEqualVec will never be satisfied on different constants at index %d%s", i, builder.callerSuffix()))
+				}
+				continue
+			}
+		}
+		ids[2*i], ids[2*i+1] = x, y
+		coef[2*i], coef[2*i+1] = builder.tOne, minusOne
+		builder.addInstruction(irsource.Instruction{
+			Type:        irsource.LinComb,
+			Inputs:      ids[2*i : 2*i+2 : 2*i+2],
+			LinCombCoef: coef[2*i : 2*i+2 : 2*i+2],
+		})
+		builder.addConstraint(irsource.Constraint{
+			Typ: irsource.Zero,
+			Var: builder.addVarId(),
+		}, "AssertIsEqualVec", a[i], b[i])
+	}
+}
+
+// InnerProduct returns the sum of the products a[i]*b[i]. The products are summed by a single
+// linear combination, and products by a constant are coefficients of it rather than gates.
+func (builder *builder) InnerProduct(a, b []frontend.Variable) frontend.Variable {
+	checkVecLen("InnerProduct", a, b)
+	ids := builder.allocInts(3 * len(a))
+	terms := ids[:0:len(a)]
+	products := ids[len(a):]
+	coef := builder.allocElements(len(a))[:0]
+	sum := constraint.Element{}
+	for i := range a {
+		x, y := builder.toVariableId(a[i]), builder.toVariableId(b[i])
+		cx, xConst := builder.constantValue(x)
+		cy, yConst := builder.constantValue(y)
+		switch {
+		case xConst && yConst:
+			sum = builder.field.Add(sum, builder.field.Mul(cx, cy))
+		case xConst:
+			terms = append(terms, y)
+			coef = append(coef, cx)
+		case yConst:
+			terms = append(terms, x)
+			coef = append(coef, cy)
+		default:
+			products[2*i], products[2*i+1] = x, y
+			builder.addInstruction(irsource.Instruction{
+				Type:   irsource.Mul,
+				Inputs: products[2*i : 2*i+2 : 2*i+2],
+			})
+			terms = append(terms, builder.addVarId())
+			coef = append(coef, builder.tOne)
+		}
+	}
+	if !sum.IsZero() || len(terms) == 0 {
+		terms = append(terms, builder.toVariableId(sum))
+		coef = append(coef, builder.tOne)
+	}
+	if len(terms) == 1 && builder.field.IsOne(coef[0]) {
+		return builder.newVariable(terms[0])
+	}
+	builder.addInstruction(irsource.Instruction{
+		Type:        irsource.LinComb,
+		Inputs:      terms,
+		LinCombCoef: coef,
+	})
+	return builder.addVar()
+}
@@ -0,0 +1,104 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func TestVectorOperations(t *testing.T) {
+	const n = 5
+	for _, valid := range []bool{true, false} {
+		root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+		a := make([]frontend.Variable, n)
+		b := make([]frontend.Variable, n)
+		for i := range a {
+			a[i] = root.SecretVariable(schema.LeafInfo{})
+		}
+		for i := range b {
+			b[i] = root.SecretVariable(schema.LeafInfo{})
+		}
+		sum, prod := root.AddVec(a, b), root.MulVec(a, b)
+		for i := 0; i < n; i++ {
+			root.AssertIsEqual(sum[i], 2*i+1)
+			root.AssertIsEqual(prod[i], i*(i+1))
+		}
+		// 0*1 + 1*2 + 2*3 + 3*4 + 4*5, then a constant vector and a constant term
+		root.AssertIsEqual(root.InnerProduct(a, b), 40)
+		root.AssertIsEqual(root.InnerProduct(append([]frontend.Variable{2, 3}, a[:2]...), []frontend.Variable{5, a[1], 1, 1}), 14)
+		inputs := bigInts(0, 1, 2, 3, 4, 1, 2, 3, 4, 5)
+		if !valid {
+			inputs[9].SetInt64(6)
+		}
+		if err := evalRoot(root.Finalize(), inputs); (err == nil) != valid {
+			t.Fatalf("valid=%t: unexpected result %v", valid, err)
+		}
+	}
+}
+
+func TestInnerProductLowering(t *testing.T) {
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	y := root.SecretVariable(schema.LeafInfo{})
+	root.Output(root.InnerProduct([]frontend.Variable{x, 3, 2}, []frontend.Variable{y, x, 4}))
+	nbMul, nbLinComb := 0, 0
+	for _, in := range root.Finalize().Circuits[0].Instructions {
+		switch in.Type {
+		case irsource.Mul:
+			nbMul++
+		case irsource.LinComb:
+			nbLinComb++
+		}
+	}
+	if nbMul != 1 || nbLinComb != 1 {
+		t.Fatalf("expected a single product and a single sum, got %d products and %d sums", nbMul, nbLinComb)
+	}
+	if y, ok := root.ConstantValue(root.InnerProduct([]frontend.Variable{2, 3}, []frontend.Variable{4, 5})); !ok || y.Int64() != 23 {
+		t.Fatalf("InnerProduct of constants: expected 23, got %v", y)
+	}
+}
+
+type transitionState struct {
+	Pc    frontend.Variable
+	Regs  [2]frontend.Variable
+	Flags struct {
+		Halted frontend.Variable
+	}
+}
+
+func TestAssertStructsEqual(t *testing.T) {
+	for _, valid := range []bool{true, false} {
+		root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+		var s transitionState
+		s.Pc = root.SecretVariable(schema.LeafInfo{})
+		s.Regs[0] = root.SecretVariable(schema.LeafInfo{})
+		s.Regs[1] = root.SecretVariable(schema.LeafInfo{})
+		s.Flags.Halted = 0
+		next := transitionState{Pc: root.Add(s.Pc, 1), Regs: [2]frontend.Variable{s.Regs[1], s.Regs[0]}}
+		next.Flags.Halted = 0
+		AssertStructsEqual(root, &next, transitionState{Pc: 4, Regs: [2]frontend.Variable{5, s.Regs[0]}, Flags: s.Flags})
+		rc := root.Finalize()
+		// the constant flags and the same register add no constraint
+		if n := len(rc.Circuits[0].Constraints); n != 2 {
+			t.Fatalf("expected 2 constraints, got %d", n)
+		}
+		inputs := bigInts(3, 7, 5)
+		if !valid {
+			inputs[2].SetInt64(6)
+		}
+		if err := evalRoot(rc, inputs); (err == nil) != valid {
+			t.Fatalf("valid=%t: unexpected result %v", valid, err)
+		}
+	}
+
+	root := NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected values of different shapes to be rejected")
+		}
+	}()
+	AssertStructsEqual(root, []frontend.Variable{1, 2}, []frontend.Variable{1})
+}
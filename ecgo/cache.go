@@ -0,0 +1,114 @@
+package ecgo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/rust"
+	"github.com/consensys/gnark/logger"
+)
+
+// cacheFormatVersion must be changed whenever the content of cache entries changes
+const cacheFormatVersion = 1
+
+// cacheKey returns the key of the compilation of rc with the options of the Rust compiler. The source circuit is only known through
+// its serialized form, which captures everything Define did, so a change in the circuit code
+// that doesn't alter the constraints still hits the cache.
+func cacheKey(rc *irsource.RootCircuit, compilerVersion string, opts rust.Options) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "ecgo-cache-%d\n%s\n%d\n%+v\n", cacheFormatVersion, compilerVersion, field.GetFieldId(rc.Field), opts)
+	h.Write(irsource.SerializeRootCircuit(rc))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func cachePaths(dir string, key string) (string, string) {
+	return filepath.Join(dir, key+".irwg"), filepath.Join(dir, key+".lc")
+}
+
+// loadCached returns the cached compilation result for key, or nil if there's none. In low memory
+// mode, the layered circuit of the result is read from the cache entry when needed.
+func loadCached(rc *irsource.RootCircuit, dir string, key string, lowMemory bool) *CompileResult {
+	irwgPath, lcPath := cachePaths(dir, key)
+	irwgSer, err := os.ReadFile(irwgPath)
+	if err != nil {
+		return nil
+	}
+	lcSer, err := os.ReadFile(lcPath)
+	if err != nil {
+		return nil
+	}
+	return cachedResult(rc, irwg.DeserializeRootCircuit(irwgSer), lcSer, lcPath, lowMemory)
+}
+
+func cachedResult(rc *irsource.RootCircuit, irwg *irwg.RootCircuit, lcSer []byte, lcPath string, lowMemory bool) *CompileResult {
+	lc := layered.DeserializeRootCircuit(lcSer)
+	res := &CompileResult{irs: rc, irwg: irwg, circuitHash: lc.ContentHash()}
+	if lowMemory {
+		res.lcFile = lcPath
+	} else {
+		res.lc = lc
+	}
+	return res
+}
+
+// storeCached writes a cache entry. Files are written under a temporary name and renamed, so
+// that concurrent compilations never read a partial entry.
+func storeCached(dir string, key string, irwgSer []byte, lcSer []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	irwgPath, lcPath := cachePaths(dir, key)
+	// the layered circuit is written first, since entries are looked up by their input solver
+	if err := writeFileAtomic(lcPath, lcSer); err != nil {
+		return err
+	}
+	return writeFileAtomic(irwgPath, irwgSer)
+}
+
+// writeFileAtomic writes buf to a temporary file in the directory of path, and renames it to
+// path, so that readers never see a partial file.
+func writeFileAtomic(path string, buf []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// compileCached compiles rc with the Rust compiler, unless the result is in the cache in dir.
+func compileCached(rc *irsource.RootCircuit, dir string, opts rust.Options, lowMemory bool) (*CompileResult, error) {
+	log := logger.Logger()
+	key := cacheKey(rc, rust.Version(), opts)
+	if res := loadCached(rc, dir, key, lowMemory); res != nil {
+		log.Info().Str("key", key).Msg("loaded compilation from cache")
+		return res, nil
+	}
+	irwg, lcSer, err := rust.CompileSerialized(rc, opts)
+	if err != nil {
+		return nil, err
+	}
+	// the key may have changed if the library was updated by the compilation
+	key = cacheKey(rc, rust.Version(), opts)
+	if err := storeCached(dir, key, irwg.Serialize(), lcSer); err != nil {
+		return nil, fmt.Errorf("store compilation in cache: %w", err)
+	}
+	_, lcPath := cachePaths(dir, key)
+	return cachedResult(rc, irwg, lcSer, lcPath, lowMemory), nil
+}
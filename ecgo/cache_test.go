@@ -0,0 +1,120 @@
+package ecgo
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/rust"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func cacheTestCircuit(c int) *irsource.RootCircuit {
+	root := builder.NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	root.AssertIsEqual(root.Mul(x, x), c)
+	return root.Finalize()
+}
+
+func TestCompileCache(t *testing.T) {
+	dir := t.TempDir()
+	rc := cacheTestCircuit(4)
+	key := cacheKey(rc, rust.Version(), rust.Options{})
+	if key == cacheKey(cacheTestCircuit(9), rust.Version(), rust.Options{}) || key == cacheKey(rc, "other", rust.Options{}) ||
+		key == cacheKey(rc, rust.Version(), rust.Options{MaxQuadraticTerms: 4}) || key == cacheKey(rc, rust.Version(), rust.Options{MaxTerms: 4}) {
+		t.Fatal("cache keys of different compilations are equal")
+	}
+
+	solver := &irwg.RootCircuit{
+		Circuits: map[uint64]*irwg.Circuit{0: {NumInputs: 1, Outputs: []int{1}}},
+		Field:    &m31.Field{},
+	}
+	lc := &layered.RootCircuit{
+		Circuits: []*layered.Circuit{{
+			InputLen:  1,
+			OutputLen: 1,
+			Add:       []layered.GateAdd{{In: 0, Out: 0, Coef: big.NewInt(1), CoefType: 1}},
+		}},
+		Layers: []uint64{0},
+		Field:  m31.ScalarField,
+	}
+	if err := storeCached(dir, key, solver.Serialize(), lc.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the Rust compiler isn't needed on a hit
+	res, err := compileCached(rc, dir, rust.Options{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(res.GetLayeredCircuit().Serialize(), lc.Serialize()) || !bytes.Equal(res.GetInputSolver().Serialize(), solver.Serialize()) {
+		t.Fatal("cached compilation mismatch")
+	}
+	if res.ContentHash() != lc.ContentHash() {
+		t.Fatal("content hash mismatch")
+	}
+	res, err = compileCached(rc, dir, rust.Options{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.LayeredCircuitFile() == "" || res.GetLayeredCircuit().ContentHash() != lc.ContentHash() {
+		t.Fatal("low memory result doesn't use the cache entry")
+	}
+	// every path hashes the layered circuit the same way
+	if res.ContentHash() != lc.ContentHash() {
+		t.Fatal("content hash of the low memory result mismatch")
+	}
+	if err := res.setLayeredCircuit(lc); err != nil {
+		t.Fatal(err)
+	}
+	defer res.Close()
+	if res.ContentHash() != lc.ContentHash() {
+		t.Fatal("content hash of the replaced layered circuit mismatch")
+	}
+}
+
+func TestCompileCacheRustOptions(t *testing.T) {
+	if _, err := Compile(m31.ScalarField, &estimateCircuit{}, WithMaxQuadraticTerms(0)); err == nil || !strings.Contains(err.Error(), "quadratic terms") {
+		t.Fatal("expected an error for no quadratic terms")
+	}
+	if _, err := Compile(m31.ScalarField, &estimateCircuit{}, WithCompressThresholds(-1, 4)); err == nil || !strings.Contains(err.Error(), "thresholds") {
+		t.Fatal("expected an error for negative thresholds")
+	}
+	dir := t.TempDir()
+	_, config, err := applyOptions([]frontend.CompileOption{WithCompileCache(dir), WithMaxQuadraticTerms(8), WithCompressThresholds(32, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.rust != (rust.Options{MaxTerms: 32, MaxQuadraticTerms: 8}) {
+		t.Fatalf("unexpected options of the Rust compiler %+v", config.rust)
+	}
+
+	// the layering finds the entry compiled with the options, without the Rust compiler
+	rc := cacheTestCircuit(4)
+	solver := &irwg.RootCircuit{
+		Circuits: map[uint64]*irwg.Circuit{0: {NumInputs: 1, Outputs: []int{1}}},
+		Field:    &m31.Field{},
+	}
+	lc := &layered.RootCircuit{
+		Circuits: []*layered.Circuit{{InputLen: 1, OutputLen: 1}},
+		Layers:   []uint64{0},
+		Field:    m31.ScalarField,
+	}
+	if err := storeCached(dir, cacheKey(rc, rust.Version(), config.rust), solver.Serialize(), lc.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+	res, err := layer(rc, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ContentHash() != lc.ContentHash() {
+		t.Fatal("cached compilation mismatch")
+	}
+}
@@ -0,0 +1,273 @@
+package ecgo
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/customgates"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// UnsatisfiedConstraintError is returned by CheckWitness for the first constraint that an
+// assignment doesn't satisfy.
+type UnsatisfiedConstraintError struct {
+	// Origin is the assertion that created the constraint.
+	Origin builder.ConstraintOrigin
+	// SubCircuits holds the names of the nested subcircuits containing the constraint, from the
+	// outermost one. It's empty for constraints of the root circuit.
+	SubCircuits []string
+	// Values are the values of the operands of the assertion.
+	Values []*big.Int
+}
+
+func (e *UnsatisfiedConstraintError) Error() string {
+	var sb strings.Builder
+	if len(e.Origin.Location) != 0 {
+		fmt.Fprintf(&sb, "%s: ", e.Origin.Location)
+	}
+	values := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		values[i] = v.String()
+	}
+	fmt.Fprintf(&sb, "%s(%s) is not satisfied", e.Origin.Api, strings.Join(values, ", "))
+	if len(e.SubCircuits) != 0 {
+		fmt.Fprintf(&sb, " in subcircuit %s", strings.Join(e.SubCircuits, " > "))
+	}
+	for _, f := range e.Origin.Location.Caller() {
+		fmt.Fprintf(&sb, "\n\tcalled from %s:%d", filepath.Base(f.File), f.Line)
+	}
+	return sb.String()
+}
+
+// CheckWitness builds circuit and evaluates it on assignment, without compiling it to a layered
+// circuit. It returns an *UnsatisfiedConstraintError for the first constraint that isn't
+// satisfied, which locates the assertion that created it, or another error if the evaluation
+// itself fails, e.g. in a hint. Random values are sampled like at proving time.
+//
+// When several constraints fail, the reported one is in the order of the constraints of the
+// root circuit, a failing subcircuit call ranking after the constraints on earlier variables.
+func CheckWitness(field *big.Int, circuit, assignment frontend.Circuit, opts ...frontend.CompileOption) error {
+	opt, config, err := applyOptions(opts)
+	if err != nil {
+		return err
+	}
+	root, err := newRoot(field, opt)
+	if err != nil {
+		return err
+	}
+	root.SetSourceLocationDepth(config.locationDepth)
+	root.SetDebugPrints(!config.noDebugPrints)
+	root.SetGrowth(config.growth)
+	root.SetHooks(config.hooks)
+	_, err = schema.Walk(circuit, irwg.TVariable, func(f schema.LeafInfo, tInput reflect.Value) error {
+		if !tInput.CanSet() {
+			return errors.New("can't set val " + f.FullName())
+		}
+		if f.Visibility == schema.Secret {
+			tInput.Set(reflect.ValueOf(root.SecretVariable(f)))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	schema.Walk(circuit, irwg.TVariable, func(f schema.LeafInfo, tInput reflect.Value) error {
+		if f.Visibility == schema.Public {
+			tInput.Set(reflect.ValueOf(root.PublicVariable(f)))
+		}
+		return nil
+	})
+	if err := define(circuit, root); err != nil {
+		return err
+	}
+	rc := root.Finalize()
+
+	pub, sec := irwg.GetCircuitVariables(assignment, rc.Field)
+	if len(sec) != rc.Circuits[0].NumInputs || len(pub) != rc.NumPublicInputs {
+		return fmt.Errorf("expected %d secret and %d public inputs, got %d and %d",
+			rc.Circuits[0].NumInputs, rc.NumPublicInputs, len(sec), len(pub))
+	}
+	c := &checker{rc: rc, root: root, publicInputs: pub}
+	_, unsatisfied, err := c.eval(0, sec)
+	if err != nil {
+		return err
+	}
+	if unsatisfied != nil {
+		return unsatisfied
+	}
+	return nil
+}
+
+type checker struct {
+	rc           *irsource.RootCircuit
+	root         *builder.Root
+	publicInputs []constraint.Element
+}
+
+// errorf returns an error about the instruction i of the circuit id, prefixed by its source location
+func (c *checker) errorf(id uint64, i int, insn *irsource.Instruction, format string, args ...any) error {
+	prefix := fmt.Sprintf("instruction %d of circuit %d", i, id)
+	if loc := c.rc.Location(insn.Loc); len(loc) != 0 {
+		prefix = fmt.Sprintf("%s: %s", loc, prefix)
+	}
+	return fmt.Errorf("%s: %w", prefix, fmt.Errorf(format, args...))
+}
+
+// eval returns the outputs of the circuit, and its first unsatisfied constraint if any
+func (c *checker) eval(id uint64, inputs []constraint.Element) ([]constraint.Element, *UnsatisfiedConstraintError, error) {
+	f := c.rc.Field
+	circuit := c.rc.Circuits[id]
+	values := append([]constraint.Element{{}}, inputs...)
+	isBool := func(x constraint.Element) bool {
+		return x.IsZero() || f.IsOne(x)
+	}
+	toBigInts := func(vars []int) []*big.Int {
+		res := make([]*big.Int, len(vars))
+		for i, x := range vars {
+			res[i] = f.ToBigInt(values[x])
+		}
+		return res
+	}
+
+	// first unsatisfied constraint of a subcircuit, and the first variable defined by its call
+	var firstCall *UnsatisfiedConstraintError
+	firstCallVar := 0
+	for i, insn := range circuit.Instructions {
+		switch insn.Type {
+		case irsource.LinComb:
+			r := insn.Const
+			for j, x := range insn.Inputs {
+				r = f.Add(r, f.Mul(insn.LinCombCoef[j], values[x]))
+			}
+			values = append(values, r)
+		case irsource.Mul:
+			r := f.One()
+			for _, x := range insn.Inputs {
+				r = f.Mul(r, values[x])
+			}
+			values = append(values, r)
+		case irsource.Div:
+			inv, ok := f.Inverse(values[insn.Y])
+			if !ok && insn.ExtraId == 0 {
+				return nil, nil, c.errorf(id, i, &insn, "division by zero")
+			}
+			values = append(values, f.Mul(values[insn.X], inv))
+		case irsource.BoolBinOp:
+			x, y := values[insn.X], values[insn.Y]
+			if !isBool(x) || !isBool(y) {
+				return nil, nil, c.errorf(id, i, &insn, "boolean operation on non boolean values")
+			}
+			r := f.Add(x, y)
+			switch insn.ExtraId {
+			case 1:
+				r = f.Sub(r, f.Mul(f.FromInterface(2), f.Mul(x, y)))
+			case 2:
+				r = f.Sub(r, f.Mul(x, y))
+			case 3:
+				r = f.Mul(x, y)
+			}
+			values = append(values, r)
+		case irsource.IsZero:
+			if values[insn.X].IsZero() {
+				values = append(values, f.One())
+			} else {
+				values = append(values, f.Zero())
+			}
+		case irsource.Hint:
+			hint := solver.GetRegisteredHint(solver.HintID(insn.ExtraId))
+			if hint == nil {
+				return nil, nil, c.errorf(id, i, &insn, "hint %d is not registered", insn.ExtraId)
+			}
+			out := make([]*big.Int, insn.NumOutputs)
+			for j := range out {
+				out[j] = new(big.Int)
+			}
+			if err := hint(f.Field(), toBigInts(insn.Inputs), out); err != nil {
+				return nil, nil, c.errorf(id, i, &insn, "hint %s: %w", solver.GetHintName(hint), err)
+			}
+			for _, x := range out {
+				values = append(values, f.FromInterface(x))
+			}
+		case irsource.ConstantLike:
+			switch insn.ExtraId {
+			case 0:
+				values = append(values, insn.Const)
+			case 1:
+				r, err := rand.Int(rand.Reader, f.Field())
+				if err != nil {
+					return nil, nil, err
+				}
+				values = append(values, f.FromInterface(r))
+			default:
+				values = append(values, c.publicInputs[insn.ExtraId-2])
+			}
+		case irsource.SubCircuitCall:
+			subIn := make([]constraint.Element, len(insn.Inputs))
+			for j, x := range insn.Inputs {
+				subIn[j] = values[x]
+			}
+			out, unsatisfied, err := c.eval(insn.ExtraId, subIn)
+			if err != nil {
+				return nil, nil, err
+			}
+			if unsatisfied != nil && firstCall == nil {
+				unsatisfied.SubCircuits = append([]string{c.root.SubCircuitName(insn.ExtraId)}, unsatisfied.SubCircuits...)
+				firstCall = unsatisfied
+				firstCallVar = len(values)
+			}
+			values = append(values, out...)
+		case irsource.CustomGate:
+			out := []*big.Int{new(big.Int)}
+			if err := customgates.GetFunc(insn.ExtraId)(f.Field(), toBigInts(insn.Inputs), out); err != nil {
+				return nil, nil, c.errorf(id, i, &insn, "custom gate %d: %w", insn.ExtraId, err)
+			}
+			values = append(values, f.FromInterface(out[0]))
+		default:
+			return nil, nil, c.errorf(id, i, &insn, "unsupported instruction type %d", insn.Type)
+		}
+	}
+
+	outputs := make([]constraint.Element, len(circuit.Outputs))
+	for i, x := range circuit.Outputs {
+		outputs[i] = values[x]
+	}
+	origins := c.root.ConstraintOrigins(id)
+	for i, con := range circuit.Constraints {
+		if firstCall != nil && con.Var >= firstCallVar {
+			continue
+		}
+		x := values[con.Var]
+		switch {
+		case con.Typ == irsource.Zero && !x.IsZero(),
+			con.Typ == irsource.NonZero && x.IsZero(),
+			con.Typ == irsource.Bool && !isBool(x):
+		default:
+			continue
+		}
+		res := &UnsatisfiedConstraintError{Origin: builder.ConstraintOrigin{Api: "constraint"}, Values: []*big.Int{f.ToBigInt(x)}}
+		if i < len(origins) {
+			res.Origin = origins[i]
+			res.Values = make([]*big.Int, len(res.Origin.Operands))
+			for j, o := range res.Origin.Operands {
+				if o.Var != 0 {
+					res.Values[j] = f.ToBigInt(values[o.Var])
+				} else {
+					res.Values[j] = o.Const
+				}
+			}
+		}
+		return outputs, res, nil
+	}
+	return outputs, firstCall, nil
+}
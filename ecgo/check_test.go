@@ -0,0 +1,101 @@
+package ecgo
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+)
+
+func checkedSquare(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	api.AssertIsDifferent(input[0], 0)
+	return []frontend.Variable{api.Mul(input[0], input[0])}
+}
+
+type checkCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+	B frontend.Variable
+}
+
+func (c *checkCircuit) Define(api frontend.API) error {
+	sq := api.(API).MemorizedSimpleCall(checkedSquare, []frontend.Variable{c.X})[0]
+	api.AssertIsBoolean(c.B)
+	api.AssertIsEqual(api.Add(sq, c.B), c.Y)
+	return nil
+}
+
+func TestCheckWitness(t *testing.T) {
+	if err := CheckWitness(m31.ScalarField, &checkCircuit{}, &checkCircuit{X: 3, Y: 10, B: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var e *UnsatisfiedConstraintError
+	err := CheckWitness(m31.ScalarField, &checkCircuit{}, &checkCircuit{X: 3, Y: 11, B: 1})
+	if !errors.As(err, &e) {
+		t.Fatalf("expected an UnsatisfiedConstraintError, got %v", err)
+	}
+	if loc := e.Origin.Location; e.Origin.Api != "AssertIsEqual" || len(loc) != 1 || filepath.Base(loc[0].File) != "check_test.go" || loc[0].Line != 29 {
+		t.Fatalf("unexpected origin %+v", e.Origin)
+	}
+	if len(e.Values) != 2 || e.Values[0].Int64() != 10 || e.Values[1].Int64() != 11 || len(e.SubCircuits) != 0 {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	err = CheckWitness(m31.ScalarField, &checkCircuit{}, &checkCircuit{X: 3, Y: 11, B: 2})
+	if !errors.As(err, &e) || e.Origin.Api != "AssertIsBoolean" || e.Values[0].Int64() != 2 {
+		t.Fatalf("expected AssertIsBoolean to fail first, got %v", err)
+	}
+
+	err = CheckWitness(m31.ScalarField, &checkCircuit{}, &checkCircuit{X: 0, Y: 0, B: 0})
+	if !errors.As(err, &e) || e.Origin.Api != "AssertIsDifferent" || e.Origin.Location[0].Line != 16 {
+		t.Fatalf("expected AssertIsDifferent to fail, got %v", err)
+	}
+	if len(e.SubCircuits) != 1 || filepath.Ext(e.SubCircuits[0]) != ".checkedSquare" {
+		t.Fatalf("unexpected subcircuits %v", e.SubCircuits)
+	}
+
+	err = CheckWitness(m31.ScalarField, &checkCircuit{}, &checkCircuit{X: 3, Y: 11, B: 1}, WithSourceLocations(2))
+	if !errors.As(err, &e) || len(e.Origin.Location) != 2 || !strings.Contains(err.Error(), "\n\tcalled from ") {
+		t.Fatalf("expected the caller of Define in the error, got %v", err)
+	}
+	err = CheckWitness(m31.ScalarField, &checkCircuit{}, &checkCircuit{X: 3, Y: 11, B: 1}, WithSourceLocations(0))
+	if !errors.As(err, &e) || len(e.Origin.Location) != 0 || err.Error() != "AssertIsEqual(10, 11) is not satisfied" {
+		t.Fatalf("expected no location, got %v", err)
+	}
+}
+
+type printCircuit struct {
+	X frontend.Variable
+}
+
+func (c *printCircuit) Define(api frontend.API) error {
+	api.Println("square", api.Mul(c.X, c.X))
+	return nil
+}
+
+func TestCheckWitnessPrintln(t *testing.T) {
+	var out bytes.Buffer
+	old := builder.DebugOutput
+	builder.DebugOutput = &out
+	defer func() { builder.DebugOutput = old }()
+
+	if err := CheckWitness(m31.ScalarField, &printCircuit{}, &printCircuit{X: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out.String(), " square 9\n") {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	out.Reset()
+	if err := CheckWitness(m31.ScalarField, &printCircuit{}, &printCircuit{X: 3}, WithDebugPrints(false)); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected no output, got %q", out.String())
+	}
+}
@@ -0,0 +1,190 @@
+package circom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/bn254"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/test"
+)
+
+const n8 = 32
+
+// fileWriter writes the little-endian fields of the files of circom
+type fileWriter struct {
+	bytes.Buffer
+}
+
+func (w *fileWriter) uint32(v uint32) {
+	w.Write(binary.LittleEndian.AppendUint32(nil, v))
+}
+
+func (w *fileWriter) uint64(v uint64) {
+	w.Write(binary.LittleEndian.AppendUint64(nil, v))
+}
+
+func (w *fileWriter) bigInt(v *big.Int) {
+	b := make([]byte, n8)
+	v.FillBytes(b)
+	for i := 0; i < n8/2; i++ {
+		b[i], b[n8-1-i] = b[n8-1-i], b[i]
+	}
+	w.Write(b)
+}
+
+// file returns a file of circom with the given sections, in the order of their types
+func file(magic string, version uint32, sections ...*fileWriter) []byte {
+	var w fileWriter
+	w.WriteString(magic)
+	w.uint32(version)
+	w.uint32(uint32(len(sections)))
+	for i, s := range sections {
+		w.uint32(uint32(i + 1))
+		w.uint64(uint64(s.Len()))
+		w.Write(s.Bytes())
+	}
+	return w.Bytes()
+}
+
+// sampleR1CS returns a .r1cs file of the wires one, out, a, b and c, with the public output out
+// and the private inputs a and b, and the constraints a*b = c and 0 = c+3-out
+func sampleR1CS() []byte {
+	var header fileWriter
+	header.uint32(n8)
+	header.bigInt(bn254.ScalarField)
+	for _, v := range []uint32{5, 1, 0, 2} {
+		header.uint32(v)
+	}
+	header.uint64(5)
+	header.uint32(2)
+
+	var constraints fileWriter
+	lc := func(terms map[uint32]int64) {
+		constraints.uint32(uint32(len(terms)))
+		for w := uint32(0); w < 5; w++ {
+			if c, ok := terms[w]; ok {
+				constraints.uint32(w)
+				constraints.bigInt(new(big.Int).Mod(big.NewInt(c), bn254.ScalarField))
+			}
+		}
+	}
+	lc(map[uint32]int64{2: 1})
+	lc(map[uint32]int64{3: 1})
+	lc(map[uint32]int64{4: 1})
+	lc(nil)
+	lc(nil)
+	lc(map[uint32]int64{0: 3, 1: -1, 4: 1})
+
+	var labels fileWriter
+	for i := uint64(0); i < 5; i++ {
+		labels.uint64(i)
+	}
+	return file("r1cs", 1, &header, &constraints, &labels)
+}
+
+const sampleSym = `1,1,0,main.out
+2,2,0,main.a
+3,3,0,main.b
+4,4,0,main.c
+5,-1,0,main.unused
+`
+
+func sampleWitness(values ...int64) []byte {
+	var header fileWriter
+	header.uint32(n8)
+	header.bigInt(bn254.ScalarField)
+	header.uint32(uint32(len(values)))
+	var witness fileWriter
+	for _, v := range values {
+		witness.bigInt(big.NewInt(v))
+	}
+	return file("wtns", 2, &header, &witness)
+}
+
+func readSample(t *testing.T) *R1CS {
+	r, err := ReadR1CS(bytes.NewReader(sampleR1CS()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ReadSymbols(strings.NewReader(sampleSym)); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestReadR1CS(t *testing.T) {
+	r := readSample(t)
+	if r.Field.Cmp(bn254.ScalarField) != 0 || r.NumWires != 5 || r.NumPublic() != 1 || r.NumPrivateInputs != 2 {
+		t.Fatalf("unexpected header %+v", r)
+	}
+	if len(r.Constraints) != 2 || len(r.Constraints[1].A) != 0 || len(r.Constraints[1].C) != 3 {
+		t.Fatalf("unexpected constraints %+v", r.Constraints)
+	}
+	if len(r.Labels) != 5 || r.Names[0] != "" || r.Names[4] != "main.c" {
+		t.Fatalf("unexpected labels %v and names %q", r.Labels, r.Names)
+	}
+
+	buf := sampleR1CS()
+	if _, err := ReadR1CS(bytes.NewReader(buf[:len(buf)-8])); err == nil {
+		t.Fatal("expected an error for a truncated file")
+	}
+	if _, err := ReadR1CS(bytes.NewReader(sampleWitness(1))); err == nil || err.Error() != "not a r1cs file" {
+		t.Fatalf("expected an error for a witness file, got %v", err)
+	}
+	if err := r.ReadSymbols(strings.NewReader("1,9,0,main.x\n")); err == nil {
+		t.Fatal("expected an error for a wire out of range")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	r := readSample(t)
+	witness, prime, err := ReadWitness(bytes.NewReader(sampleWitness(1, 23, 4, 5, 20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prime.Cmp(bn254.ScalarField) != 0 || len(witness) != 5 || witness[4].Int64() != 20 {
+		t.Fatalf("unexpected witness %v", witness)
+	}
+	if err := r.Check(witness); err != nil {
+		t.Fatal(err)
+	}
+	witness[1] = big.NewInt(24)
+	err = r.Check(witness)
+	if err == nil || err.Error() != "constraint 1 is not satisfied, on main.out, main.c" {
+		t.Fatalf("expected the names of the signals, got %v", err)
+	}
+	if err := r.Check(witness[:4]); err == nil {
+		t.Fatal("expected an error for a short witness")
+	}
+}
+
+func TestDefine(t *testing.T) {
+	r := readSample(t)
+	for _, c := range []struct {
+		witness []int64
+		solved  bool
+	}{
+		{[]int64{1, 23, 4, 5, 20}, true},
+		{[]int64{1, 24, 4, 5, 20}, false},
+		{[]int64{1, 23, 4, 5, 21}, false},
+	} {
+		witness := make([]*big.Int, len(c.witness))
+		for i, v := range c.witness {
+			witness[i] = big.NewInt(v)
+		}
+		assignment, err := r.Assignment(witness)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = test.IsSolved(r.NewCircuit(), assignment, r.Field)
+		if (err == nil) != c.solved {
+			t.Fatalf("witness %v: expected solved %v, got %v", c.witness, c.solved, err)
+		}
+	}
+	if _, err := r.Assignment([]*big.Int{big.NewInt(2), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0)}); err == nil {
+		t.Fatal("expected an error for a wire 0 other than 1")
+	}
+}
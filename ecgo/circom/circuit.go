@@ -0,0 +1,104 @@
+package circom
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// Circuit is the gnark circuit of a constraint system, see NewCircuit.
+type Circuit struct {
+	// Public holds the public outputs, then the public inputs.
+	Public []frontend.Variable `gnark:",public"`
+	// Secret holds the private inputs, then the internal signals.
+	Secret []frontend.Variable
+	r1cs   *R1CS `gnark:"-"`
+}
+
+// NewCircuit returns a circuit asserting the constraints of r, to be compiled or used as an
+// assignment, see Assignment.
+func (r *R1CS) NewCircuit() *Circuit {
+	return &Circuit{
+		Public: make([]frontend.Variable, r.NumPublic()),
+		Secret: make([]frontend.Variable, r.NumWires-1-r.NumPublic()),
+		r1cs:   r,
+	}
+}
+
+var _ frontend.Circuit = &Circuit{}
+
+// innerProducter is implemented by the builders of ecgo, which sum a linear combination with a
+// single instruction
+type innerProducter interface {
+	InnerProduct(a, b []frontend.Variable) frontend.Variable
+}
+
+// Define asserts the constraints of the constraint system.
+func (c *Circuit) Define(api frontend.API) error {
+	r := c.r1cs
+	if r == nil {
+		return errors.New("the circuit must be created by R1CS.NewCircuit")
+	}
+	wires := make([]frontend.Variable, 0, r.NumWires)
+	wires = append(wires, 1)
+	wires = append(wires, c.Public...)
+	wires = append(wires, c.Secret...)
+	ip, _ := api.(innerProducter)
+	eval := func(lc LinearCombination) frontend.Variable {
+		if len(lc) == 0 {
+			return 0
+		}
+		coefs := make([]frontend.Variable, len(lc))
+		vars := make([]frontend.Variable, len(lc))
+		for i, t := range lc {
+			coefs[i], vars[i] = t.Coef, wires[t.Wire]
+		}
+		if ip != nil {
+			return ip.InnerProduct(coefs, vars)
+		}
+		res := api.Mul(coefs[0], vars[0])
+		for i := 1; i < len(lc); i++ {
+			res = api.Add(res, api.Mul(coefs[i], vars[i]))
+		}
+		return res
+	}
+	for _, k := range r.Constraints {
+		api.AssertIsEqual(api.Mul(eval(k.A), eval(k.B)), eval(k.C))
+	}
+	return nil
+}
+
+// Check checks that a witness of all the wires, like the ones of ReadWitness, satisfies the
+// constraints, and returns the first unsatisfied constraint with the names of its signals.
+func (r *R1CS) Check(witness []*big.Int) error {
+	if err := r.checkWitness(witness); err != nil {
+		return err
+	}
+	eval := func(lc LinearCombination) *big.Int {
+		res := new(big.Int)
+		tmp := new(big.Int)
+		for _, t := range lc {
+			res.Add(res, tmp.Mul(t.Coef, witness[t.Wire]))
+		}
+		return res.Mod(res, r.Field)
+	}
+	for i, k := range r.Constraints {
+		lhs := new(big.Int).Mul(eval(k.A), eval(k.B))
+		if lhs.Sub(lhs, eval(k.C)).Mod(lhs, r.Field).Sign() == 0 {
+			continue
+		}
+		var names []string
+		seen := make(map[uint32]bool)
+		for _, t := range k.terms() {
+			if t.Wire != 0 && !seen[t.Wire] {
+				seen[t.Wire] = true
+				names = append(names, r.wireName(t.Wire))
+			}
+		}
+		return fmt.Errorf("constraint %d is not satisfied, on %s", i, strings.Join(names, ", "))
+	}
+	return nil
+}
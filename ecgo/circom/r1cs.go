@@ -0,0 +1,243 @@
+// Package circom imports the circuits of circom, so that they're proven with Expander without
+// being rewritten in Go. ReadR1CS reads the constraint system of a .r1cs file, and the names of
+// its signals from a .sym file, and NewCircuit turns it into a gnark circuit asserting each
+// constraint, which ecgo.Compile lowers through the optimization passes and the layering like
+// any other circuit. The witnesses computed by the witness generators of circom are read from
+// .wtns files by ReadWitness, and Assignment makes them assignments of the circuit.
+//
+// The wires of the constraint system are laid out like in circom: wire 0 is the constant 1, then
+// come the public outputs, the public inputs, the private inputs and the internal signals. The
+// public outputs and inputs are the public variables of the circuit, and the other wires its
+// secret variables.
+package circom
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"slices"
+)
+
+// Term is a term of a linear combination, the value of a wire times a coefficient.
+type Term struct {
+	Wire uint32
+	Coef *big.Int
+}
+
+// LinearCombination is a sum of terms.
+type LinearCombination []Term
+
+// Constraint is the constraint A*B = C of an R1CS.
+type Constraint struct {
+	A, B, C LinearCombination
+}
+
+// terms returns the terms of the three linear combinations
+func (k Constraint) terms() LinearCombination {
+	return append(append(append(LinearCombination(nil), k.A...), k.B...), k.C...)
+}
+
+// R1CS is a constraint system read from a .r1cs file.
+type R1CS struct {
+	// Field is the prime of the field of the constraints.
+	Field *big.Int
+	// NumWires is the number of wires, including the constant wire 0.
+	NumWires         int
+	NumPublicOutputs int
+	NumPublicInputs  int
+	NumPrivateInputs int
+	Constraints      []Constraint
+	// Labels holds the label of each wire, the index of the signal in the .sym file.
+	Labels []uint64
+	// Names holds the name of the signal of each wire, e.g. "main.out", if the .sym file was
+	// read, or "" for the wires of no signal.
+	Names []string
+}
+
+// NumPublic returns the number of public wires, the public outputs and inputs.
+func (r *R1CS) NumPublic() int {
+	return r.NumPublicOutputs + r.NumPublicInputs
+}
+
+// section types of .r1cs and .wtns files
+const (
+	sectionHeader      = 1
+	sectionConstraints = 2
+	sectionWire2Label  = 3
+	sectionWitness     = 2
+)
+
+// binReader reads the little-endian fields of the files of circom, and records the first error
+type binReader struct {
+	buf []byte
+	err error
+}
+
+func (r *binReader) bytes(n int) []byte {
+	if r.err == nil && (n < 0 || n > len(r.buf)) {
+		r.err = io.ErrUnexpectedEOF
+	}
+	if r.err != nil {
+		// zeroes for the fixed-size fields
+		return make([]byte, max(0, min(n, 8)))
+	}
+	res := r.buf[:n]
+	r.buf = r.buf[n:]
+	return res
+}
+
+func (r *binReader) uint32() uint32 {
+	return binary.LittleEndian.Uint32(r.bytes(4))
+}
+
+func (r *binReader) uint64() uint64 {
+	return binary.LittleEndian.Uint64(r.bytes(8))
+}
+
+// bigInt reads a little-endian integer of n bytes
+func (r *binReader) bigInt(n int) *big.Int {
+	b := slices.Clone(r.bytes(n))
+	slices.Reverse(b)
+	return new(big.Int).SetBytes(b)
+}
+
+// readFieldSize reads the number of bytes of the field elements
+func readFieldSize(r *binReader) (int, error) {
+	n8 := r.uint32()
+	if r.err != nil {
+		return 0, r.err
+	}
+	if n8 == 0 || n8 > 64 {
+		return 0, fmt.Errorf("invalid field size of %d bytes", n8)
+	}
+	return int(n8), nil
+}
+
+// readSections checks the magic and the version of a file of circom, and returns its sections
+// by type
+func readSections(in io.Reader, magic string, version uint32) (map[uint32][]byte, error) {
+	buf, err := io.ReadAll(bufio.NewReader(in))
+	if err != nil {
+		return nil, err
+	}
+	r := &binReader{buf: buf}
+	if string(r.bytes(4)) != magic {
+		return nil, fmt.Errorf("not a %s file", magic)
+	}
+	if v := r.uint32(); r.err == nil && v != version {
+		return nil, fmt.Errorf("unsupported %s version %d, expected %d", magic, v, version)
+	}
+	n := r.uint32()
+	sections := make(map[uint32][]byte)
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		typ := r.uint32()
+		size := r.uint64()
+		if size > uint64(len(r.buf)) {
+			return nil, fmt.Errorf("section %d of type %d: %w", i, typ, io.ErrUnexpectedEOF)
+		}
+		sections[typ] = r.bytes(int(size))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return sections, nil
+}
+
+// ReadR1CS reads a constraint system from a .r1cs file of circom.
+func ReadR1CS(in io.Reader) (*R1CS, error) {
+	sections, err := readSections(in, "r1cs", 1)
+	if err != nil {
+		return nil, err
+	}
+	header, ok := sections[sectionHeader]
+	if !ok {
+		return nil, errors.New("missing r1cs header")
+	}
+	h := &binReader{buf: header}
+	n8, err := readFieldSize(h)
+	if err != nil {
+		return nil, fmt.Errorf("r1cs header: %w", err)
+	}
+	res := &R1CS{Field: h.bigInt(n8)}
+	res.NumWires = int(h.uint32())
+	res.NumPublicOutputs = int(h.uint32())
+	res.NumPublicInputs = int(h.uint32())
+	res.NumPrivateInputs = int(h.uint32())
+	h.uint64() // number of labels
+	nbConstraints := int(h.uint32())
+	if h.err != nil {
+		return nil, fmt.Errorf("r1cs header: %w", h.err)
+	}
+	if res.NumWires == 0 || 1+res.NumPublic()+res.NumPrivateInputs > res.NumWires {
+		return nil, fmt.Errorf("invalid r1cs header with %d wires and %d inputs and outputs", res.NumWires, res.NumPublic()+res.NumPrivateInputs)
+	}
+
+	c := &binReader{buf: sections[sectionConstraints]}
+	lc := func() LinearCombination {
+		n := int(c.uint32())
+		if c.err != nil || n > len(c.buf)/(4+n8) {
+			c.err = io.ErrUnexpectedEOF
+			return nil
+		}
+		res := make(LinearCombination, n)
+		for i := range res {
+			res[i] = Term{Wire: c.uint32(), Coef: c.bigInt(n8)}
+		}
+		return res
+	}
+	res.Constraints = make([]Constraint, 0, min(nbConstraints, len(c.buf)/12))
+	for i := 0; i < nbConstraints && c.err == nil; i++ {
+		k := Constraint{A: lc(), B: lc(), C: lc()}
+		for _, t := range k.terms() {
+			if int(t.Wire) >= res.NumWires {
+				return nil, fmt.Errorf("constraint %d: wire %d out of range", i, t.Wire)
+			}
+		}
+		res.Constraints = append(res.Constraints, k)
+	}
+	if c.err != nil {
+		return nil, fmt.Errorf("r1cs constraints: %w", c.err)
+	}
+
+	if labels, ok := sections[sectionWire2Label]; ok {
+		l := &binReader{buf: labels}
+		res.Labels = make([]uint64, res.NumWires)
+		for i := range res.Labels {
+			res.Labels[i] = l.uint64()
+		}
+		if l.err != nil {
+			return nil, fmt.Errorf("r1cs wire labels: %w", l.err)
+		}
+	}
+	return res, nil
+}
+
+// ReadR1CSFile reads the constraint system of a .r1cs file and, unless symPath is empty, the
+// names of its signals from a .sym file, see ReadSymbols.
+func ReadR1CSFile(path, symPath string) (*R1CS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	res, err := ReadR1CS(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if symPath == "" {
+		return res, nil
+	}
+	sym, err := os.Open(symPath)
+	if err != nil {
+		return nil, err
+	}
+	defer sym.Close()
+	if err := res.ReadSymbols(sym); err != nil {
+		return nil, fmt.Errorf("%s: %w", symPath, err)
+	}
+	return res, nil
+}
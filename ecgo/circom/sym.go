@@ -0,0 +1,53 @@
+package circom
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadSymbols reads the names of the signals from a .sym file of circom, whose lines are
+// "label,wire,component,name", and sets Names. The signals optimized away by circom have the
+// wire -1 and are skipped. If several signals share a wire, the wire is named by the first one.
+func (r *R1CS) ReadSymbols(in io.Reader) error {
+	names := make([]string, r.NumWires)
+	s := bufio.NewScanner(in)
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		fields := strings.SplitN(s.Text(), ",", 4)
+		if len(fields) != 4 {
+			return fmt.Errorf("line %d: expected label,wire,component,name", line)
+		}
+		wire, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid wire: %w", line, err)
+		}
+		if wire < 0 {
+			continue
+		}
+		if wire >= int64(r.NumWires) {
+			return fmt.Errorf("line %d: wire %d out of range", line, wire)
+		}
+		if names[wire] == "" {
+			names[wire] = fields[3]
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	r.Names = names
+	return nil
+}
+
+// wireName returns the name of a wire for the error messages
+func (r *R1CS) wireName(w uint32) string {
+	if int(w) < len(r.Names) && r.Names[w] != "" {
+		return r.Names[w]
+	}
+	return fmt.Sprintf("wire %d", w)
+}
@@ -0,0 +1,81 @@
+package circom
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+)
+
+// ReadWitness reads the values of all the wires from a .wtns file written by the witness
+// generators of circom, and the prime of their field.
+func ReadWitness(in io.Reader) ([]*big.Int, *big.Int, error) {
+	sections, err := readSections(in, "wtns", 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	header, ok := sections[sectionHeader]
+	if !ok {
+		return nil, nil, errors.New("missing wtns header")
+	}
+	h := &binReader{buf: header}
+	n8, err := readFieldSize(h)
+	if err != nil {
+		return nil, nil, fmt.Errorf("wtns header: %w", err)
+	}
+	prime := h.bigInt(n8)
+	n := int(h.uint32())
+	if h.err != nil {
+		return nil, nil, fmt.Errorf("wtns header: %w", h.err)
+	}
+	v := &binReader{buf: sections[sectionWitness]}
+	if n > len(v.buf)/n8 {
+		return nil, nil, fmt.Errorf("wtns values: %w", io.ErrUnexpectedEOF)
+	}
+	res := make([]*big.Int, n)
+	for i := range res {
+		res[i] = v.bigInt(n8)
+	}
+	return res, prime, nil
+}
+
+// ReadWitnessFile reads a .wtns file, see ReadWitness.
+func ReadWitnessFile(path string) ([]*big.Int, *big.Int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	res, prime, err := ReadWitness(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return res, prime, nil
+}
+
+func (r *R1CS) checkWitness(witness []*big.Int) error {
+	if len(witness) != r.NumWires {
+		return fmt.Errorf("expected a witness of %d wires, got %d", r.NumWires, len(witness))
+	}
+	if witness[0].Cmp(big.NewInt(1)) != 0 {
+		return fmt.Errorf("wire 0 must be 1, got %s", witness[0])
+	}
+	return nil
+}
+
+// Assignment returns the assignment of the circuit of r to the values of a witness of all the
+// wires, like the ones of ReadWitness.
+func (r *R1CS) Assignment(witness []*big.Int) (*Circuit, error) {
+	if err := r.checkWitness(witness); err != nil {
+		return nil, err
+	}
+	res := r.NewCircuit()
+	for i := range res.Public {
+		res.Public[i] = witness[1+i]
+	}
+	for i := range res.Secret {
+		res.Secret[i] = witness[1+len(res.Public)+i]
+	}
+	return res, nil
+}
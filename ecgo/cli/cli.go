@@ -0,0 +1,945 @@
+// Package cli implements the ecc command, which compiles registered circuits and solves their
+// witnesses, so that the artifacts can be produced without writing a compile harness.
+//
+// Circuits are registered with the registry package, either by a Go plugin loaded with the
+// -plugin flag, or by a custom binary:
+//
+//	func main() {
+//		registry.Register(registry.Circuit{Name: "mimc", Field: ecc.BN254.ScalarField(), New: ...})
+//		os.Exit(cli.Main(os.Args[1:], os.Stdout, os.Stderr))
+//	}
+package cli
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"plugin"
+	"strings"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/circom"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/export"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/golden"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/passes"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/registry"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/server"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/solidity"
+	"github.com/consensys/gnark/frontend"
+)
+
+const usage = `usage: ecc <command> [flags]
+
+commands:
+  list     list the registered circuits
+  compile  compile a circuit, and write the layered circuit and its input solver
+  solve    solve a witness from an assignment, with the input solver written by compile
+  stats    print the statistics of a layered circuit
+  diff     compare the layers and subcircuits of two layered circuits
+  export   write a layered circuit in the format of another prover
+  estimate estimate the memory and the size of the compilation of a circuit, without compiling it
+  count    count the constraints and gates of each gadget of a circuit, without compiling it
+  lint     report the secret inputs and hint outputs of a circuit which reach no constraint
+  golden   write the golden test vectors of the binary formats, for other implementations
+  worker   serve the evaluation of subcircuits to distributed solve commands
+  serve    serve the compilation and solving of the registered circuits to remote provers
+
+Run ecc <command> -h for the flags of a command.
+`
+
+// Main runs the ecc command with the given arguments, without the program name, and returns the
+// exit code.
+func Main(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	var err error
+	switch args[0] {
+	case "list":
+		err = list(args[1:], stdout, stderr)
+	case "compile":
+		err = compile(args[1:], stdout, stderr)
+	case "solve":
+		err = solve(args[1:], stdout, stderr)
+	case "stats":
+		err = stats(args[1:], stdout, stderr)
+	case "diff":
+		err = diff(args[1:], stdout, stderr)
+	case "export":
+		err = exportCircuit(args[1:], stdout, stderr)
+	case "estimate":
+		err = estimate(args[1:], stdout, stderr)
+	case "count":
+		err = count(args[1:], stdout, stderr)
+	case "lint":
+		err = lint(args[1:], stdout, stderr)
+	case "golden":
+		err = writeGolden(args[1:], stdout, stderr)
+	case "worker":
+		err = worker(args[1:], stdout, stderr)
+	case "serve":
+		err = serve(args[1:], stdout, stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "ecc %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// circuitFlags are the flags selecting a registered circuit, or a circuit of circom
+type circuitFlags struct {
+	name    string
+	plugins stringList
+	r1cs    string
+	sym     string
+	// circom is the constraint system read from -r1cs
+	circom *circom.R1CS
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func (f *circuitFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.name, "circuit", "", "name of the registered circuit")
+	fs.Var(&f.plugins, "plugin", "Go plugin registering circuits in its init functions, may be repeated")
+	fs.StringVar(&f.r1cs, "r1cs", "", "compile the .r1cs file of a circom circuit instead of a registered circuit")
+	fs.StringVar(&f.sym, "sym", "", "the .sym file of -r1cs, naming the signals in the errors")
+}
+
+func (f *circuitFlags) loadPlugins() error {
+	for _, p := range f.plugins {
+		if _, err := plugin.Open(p); err != nil {
+			return fmt.Errorf("load plugin: %w", err)
+		}
+	}
+	return nil
+}
+
+func (f *circuitFlags) circuit() (registry.Circuit, error) {
+	if err := f.loadPlugins(); err != nil {
+		return registry.Circuit{}, err
+	}
+	if f.r1cs != "" {
+		if f.name != "" {
+			return registry.Circuit{}, errors.New("-circuit and -r1cs are exclusive")
+		}
+		r, err := circom.ReadR1CSFile(f.r1cs, f.sym)
+		if err != nil {
+			return registry.Circuit{}, err
+		}
+		f.circom = r
+		return registry.Circuit{
+			Name:  strings.TrimSuffix(filepath.Base(f.r1cs), filepath.Ext(f.r1cs)),
+			Field: r.Field,
+			New:   func() frontend.Circuit { return r.NewCircuit() },
+		}, nil
+	}
+	if f.name == "" {
+		return registry.Circuit{}, fmt.Errorf("missing -circuit, registered circuits: %s", strings.Join(registry.Names(), ", "))
+	}
+	c, ok := registry.Get(f.name)
+	if !ok {
+		return registry.Circuit{}, fmt.Errorf("unknown circuit %q, registered circuits: %s", f.name, strings.Join(registry.Names(), ", "))
+	}
+	return c, nil
+}
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("ecc "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+func list(args []string, stdout, stderr io.Writer) error {
+	var cf circuitFlags
+	fs := newFlagSet("list", stderr)
+	fs.Var(&cf.plugins, "plugin", "Go plugin registering circuits in its init functions, may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := cf.loadPlugins(); err != nil {
+		return err
+	}
+	for _, name := range registry.Names() {
+		fmt.Fprintln(stdout, name)
+	}
+	return nil
+}
+
+var relayStrategies = map[string]layered.RelayStrategy{
+	"keep":      layered.KeepRelays,
+	"share":     layered.ShareRelays,
+	"recompute": layered.RecomputeRelays,
+}
+
+func compile(args []string, stdout, stderr io.Writer) error {
+	var cf circuitFlags
+	fs := newFlagSet("compile", stderr)
+	cf.register(fs)
+	out := fs.String("out", ".", "directory of the output files")
+	ir := fs.Bool("ir", false, "also write the optimized IR as JSON to ir.json, and in algebraic form to ir.txt")
+	explain := fs.Int("explain", -1, "print the expression tree feeding this constraint of the root circuit of the optimized IR")
+	sol := fs.Bool("solidity", false, "also write a Solidity verifier contract to verifier.sol")
+	verifierData := fs.Bool("verifier-data", false, "also write the data verifiers need instead of the circuit, its layer digests and public input layout, to verifier.json, see ecgo.ExtractVerifierData")
+	plonkCS := fs.Bool("plonk", false, "also compile the circuit with gnark's PLONK builder, and write its constraint system to circuit.scs, see ecgo.WithPlonk")
+	progress := fs.Bool("progress", false, "report the progress of the compilation on stderr")
+	trace := fs.String("trace", "", "write a JSON trace of the phases of the compilation, with their gates and memory, to this file, see ecgo.WithTrace")
+	var limits ecgo.Limits
+	fs.IntVar(&limits.MaxLayers, "max-layers", 0, "fail if the layered circuit has more layers, 0 for no limit")
+	fs.Uint64Var(&limits.MaxLayerWidth, "max-width", 0, "fail if a layer of the layered circuit has more wires, 0 for no limit")
+	fs.Uint64Var(&limits.MaxGates, "max-gates", 0, "fail if the layered circuit has more gates, 0 for no limit")
+	level := fs.Int("O", -1, "optimization level, see passes.Level, instead of the one of the circuit")
+	pipeline := fs.String("passes", "", "comma-separated optimization passes, including the ones registered by plugins, instead of -O")
+	versioned := fs.Bool("versioned", false, "write the layered circuit with a header holding its format version, field and features, which the Expander prover doesn't read")
+	compact := fs.Bool("compact", false, "write the layered circuit with a table of its constant coefficients, see layered.RootCircuit.SerializeCompact, which the Expander prover doesn't read")
+	equivalence := fs.String("equivalence", "", "JSON or CSV file of assignments on which to check that the layered circuit and gnark's R1CS agree, see CompileResult.CheckEquivalence")
+	trials := fs.Int("equivalence-trials", 16, "number of random mutations of each assignment of -equivalence")
+	redact := fs.Bool("redact", false, "leave the secret values out of the mismatch reported by -equivalence, see EquivalenceMismatch.Redact")
+	relays := fs.String("relays", "keep", "how values are carried across layers, keep, share or recompute, see ecgo.WithRelays")
+	claims := fs.Int("aggregate-outputs", 0, "combine the outputs expected to be zero into this many random claims, see ecgo.WithOutputAggregation, 0 to keep them")
+	maxTerms := fs.Int("max-terms", 0, "number of terms of an expression above which the Rust compiler compresses it, see ecgo.WithCompressThresholds, 0 for the default")
+	quadratic := fs.Int("max-quadratic-terms", 0, "number of degree 2 terms of an expression above which the Rust compiler compresses it, see ecgo.WithMaxQuadraticTerms, 0 for the default of the field")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *versioned && *compact {
+		return errors.New("-versioned and -compact can't be combined")
+	}
+	c, err := cf.circuit()
+	if err != nil {
+		return err
+	}
+	opts := c.Options
+	switch {
+	case *pipeline != "":
+		p, err := passes.ParsePipeline(*pipeline)
+		if err != nil {
+			return err
+		}
+		opts = append(opts[:len(opts):len(opts)], ecgo.WithPipeline(p))
+	case *level >= 0:
+		opts = append(opts[:len(opts):len(opts)], ecgo.WithOptimizationLevel(*level))
+	}
+	strategy, ok := relayStrategies[*relays]
+	if !ok {
+		return fmt.Errorf("unknown relay strategy %q, expected keep, share or recompute", *relays)
+	}
+	if strategy != layered.KeepRelays {
+		opts = append(opts[:len(opts):len(opts)], ecgo.WithRelays(strategy))
+	}
+	if *claims > 0 {
+		opts = append(opts[:len(opts):len(opts)], ecgo.WithOutputAggregation(*claims))
+	}
+	if *maxTerms != 0 {
+		opts = append(opts[:len(opts):len(opts)], ecgo.WithCompressThresholds(*maxTerms, 0))
+	}
+	if *quadratic != 0 {
+		opts = append(opts[:len(opts):len(opts)], ecgo.WithMaxQuadraticTerms(*quadratic))
+	}
+	if limits != (ecgo.Limits{}) {
+		opts = append(opts[:len(opts):len(opts)], ecgo.WithLimits(limits))
+	}
+	if *plonkCS {
+		opts = append(opts[:len(opts):len(opts)], ecgo.WithPlonk())
+	}
+	if *progress {
+		opts = append(opts[:len(opts):len(opts)], ecgo.WithProgress(func(p ecgo.Progress) {
+			fmt.Fprintf(stderr, "%3d%% %s, %d gates\n", p.Percent, p.Phase, p.Gates)
+		}))
+	}
+	if *trace != "" {
+		f, err := os.Create(*trace)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		opts = append(opts[:len(opts):len(opts)], ecgo.WithTrace(slog.NewJSONHandler(f, nil)))
+	}
+	// an interrupt stops the compilation between two phases
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	res, err := ecgo.CompileContext(ctx, c.Field, c.New(), opts...)
+	if err != nil {
+		return err
+	}
+	if *equivalence != "" {
+		assignments, err := irwg.ReadAssignmentsFile(c.New, *equivalence)
+		if err != nil {
+			return err
+		}
+		report, err := res.CheckEquivalence(c.New(), assignments, *trials)
+		var mismatch *ecgo.EquivalenceMismatch
+		if *redact && errors.As(err, &mismatch) {
+			mismatch.Redact()
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "the R1CS and the layered circuit agree on %d assignments, %d satisfying\n", report.Assignments, report.Satisfied)
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+	if *ir {
+		if err := writeIR(res, filepath.Join(*out, "ir.json")); err != nil {
+			return err
+		}
+		if err := writeIRText(res, filepath.Join(*out, "ir.txt")); err != nil {
+			return err
+		}
+	}
+	if *explain >= 0 {
+		tree, err := res.GetCircuitIr().ExplainConstraint(0, *explain, 0, res.IRNames())
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(stdout, tree)
+	}
+	if *sol {
+		if err := writeSolidity(c, res, filepath.Join(*out, "verifier.sol")); err != nil {
+			return err
+		}
+	}
+	circuitPath := filepath.Join(*out, "circuit.txt")
+	solverPath := filepath.Join(*out, "inputsolver.txt")
+	circuitBuf := res.GetLayeredCircuit().Serialize()
+	switch {
+	case *versioned:
+		circuitBuf = res.SerializeVersioned()
+	case *compact:
+		circuitBuf = res.GetLayeredCircuit().SerializeCompact()
+	}
+	if err := os.WriteFile(circuitPath, circuitBuf, 0o644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(solverPath, res.GetInputSolver().Serialize(), 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "wrote %s and %s\n", circuitPath, solverPath)
+	if len(res.Tags()) != 0 {
+		tagsPath := filepath.Join(*out, "tags.json")
+		if err := writeTags(res, tagsPath); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "wrote %s\n", tagsPath)
+	}
+	if res.Schema() != nil {
+		schemaPath := filepath.Join(*out, "schema.json")
+		if err := writeSchema(res, schemaPath); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "wrote %s\n", schemaPath)
+	}
+	if *verifierData {
+		dataPath := filepath.Join(*out, "verifier.json")
+		if err := writeExported(dataPath, ecgo.ExtractVerifierData(res).Write); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "wrote %s\n", dataPath)
+	}
+	if *plonkCS {
+		scsPath := filepath.Join(*out, "circuit.scs")
+		if err := writeExported(scsPath, res.WritePlonk); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "wrote %s\n", scsPath)
+	}
+	if layout := res.PublicInputLayout(); len(layout) != 0 {
+		fmt.Fprintf(stdout, "public inputs: %s\n", strings.Join(layout, ", "))
+	}
+	fmt.Fprint(stdout, res.Stats())
+	if n := res.SkippedBooleanAssertions(); n != 0 {
+		fmt.Fprintf(stdout, "skipped %d redundant boolean assertions\n", n)
+	}
+	return nil
+}
+
+func writeIR(res *ecgo.CompileResult, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := res.GetCircuitIr().Export(f, irsource.ExportJSON); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeIRText writes the circuits of the optimized IR in algebraic form, the variables of the
+// root circuit being named after their tags
+func writeIRText(res *ecgo.CompileResult, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	rc := res.GetCircuitIr()
+	for _, id := range rc.CircuitIds() {
+		var names irsource.Names
+		if id == 0 {
+			names = res.IRNames()
+		}
+		if err := rc.Dump(f, id, names); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+func writeTags(res *ecgo.CompileResult, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := res.WriteTags(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeSchema(res *ecgo.CompileResult, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := res.WriteSchema(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeSolidity(c registry.Circuit, res *ecgo.CompileResult, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := solidity.Generate(f, solidity.FromCompileResult(solidity.ContractName(c.Name), c.Field, res)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func solve(args []string, stdout, stderr io.Writer) error {
+	var cf circuitFlags
+	fs := newFlagSet("solve", stderr)
+	cf.register(fs)
+	assignmentPath := fs.String("assignment", "", "JSON or CSV file of the assignments, see irwg.ReadAssignmentsFile, or .wtns file of the witness of -r1cs")
+	solverPath := fs.String("inputsolver", "inputsolver.txt", "input solver written by compile")
+	out := fs.String("out", "witness.txt", "output witness file")
+	workers := fs.String("workers", "", "comma-separated addresses of workers evaluating the subcircuits, see ecc worker")
+	public := fs.String("public", "", "also write the public inputs alone to this file, for verifiers")
+	threads := fs.Int("threads", 1, "number of threads solving a single assignment, batching the calls to the same subcircuit")
+	keyPath := fs.String("key", "", "file of the AES key, of 16, 24 or 32 bytes, raw or hex-encoded after \"hex:\", encrypting the witness, see irwg.Witness.SerializeEncrypted")
+	withHash := fs.Bool("hash", false, "append the content hash of the circuit to the witness, which the Expander prover doesn't read, see irwg.Witness.SerializeWithHash")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var key []byte
+	if *keyPath != "" {
+		var err error
+		if key, err = readKey(*keyPath); err != nil {
+			return err
+		}
+	}
+	c, err := cf.circuit()
+	if err != nil {
+		return err
+	}
+	if *assignmentPath == "" {
+		return errors.New("missing -assignment")
+	}
+	var assignments []frontend.Circuit
+	if cf.circom != nil && filepath.Ext(*assignmentPath) == ".wtns" {
+		assignments, err = readWitness(cf.circom, *assignmentPath)
+	} else {
+		assignments, err = irwg.ReadAssignmentsFile(c.New, *assignmentPath)
+	}
+	if err != nil {
+		return err
+	}
+	solverBuf, err := os.ReadFile(*solverPath)
+	if err != nil {
+		return err
+	}
+	solver := ecgo.DeserializeInputSolver(solverBuf)
+	var witness *irwg.Witness
+	var stats *irwg.SolveStats
+	if *workers != "" {
+		witness, err = solveDistributed(solver, assignments, strings.Split(*workers, ","))
+	} else if len(assignments) == 1 {
+		witness, stats, err = solver.SolveInputStats(assignments[0], *threads)
+	} else {
+		witness, err = solver.SolveInputs(assignments)
+	}
+	if err != nil {
+		return err
+	}
+	buf := witness.Serialize()
+	if *withHash {
+		buf = witness.SerializeWithHash()
+	}
+	if key != nil {
+		if buf, err = witness.SerializeEncrypted(key); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(*out, buf, 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "wrote %d witnesses to %s\n", witness.NumWitnesses, *out)
+	if stats != nil && stats.Batches != 0 {
+		fmt.Fprintf(stdout, "solved %d of %d subcircuit calls in %d batches, %.1f instructions per dispatch\n", stats.BatchedCalls, stats.SubCircuitCalls, stats.Batches, stats.DispatchSpeedup())
+	}
+	if *public != "" {
+		if err := os.WriteFile(*public, witness.Public().Serialize(), 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "wrote the public inputs to %s\n", *public)
+	}
+	return nil
+}
+
+// hexKeyPrefix starts the key files holding the hexadecimal encoding of the key, see readKey
+const hexKeyPrefix = "hex:"
+
+// readKey reads a key from a file holding its bytes, or hexKeyPrefix followed by their
+// hexadecimal encoding. Raw keys are never decoded, even if they happen to be valid hex.
+func readKey(path string) ([]byte, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if encoded, ok := strings.CutPrefix(string(buf), hexKeyPrefix); ok {
+		if buf, err = hex.DecodeString(strings.TrimSpace(encoded)); err != nil {
+			return nil, fmt.Errorf("%s: invalid hex-encoded key: %w", path, err)
+		}
+	}
+	switch len(buf) {
+	case 16, 24, 32:
+		return buf, nil
+	}
+	return nil, fmt.Errorf("%s: expected a key of 16, 24 or 32 bytes, got %d", path, len(buf))
+}
+
+// readWitness reads the .wtns file of a witness of r, and checks it before solving
+func readWitness(r *circom.R1CS, path string) ([]frontend.Circuit, error) {
+	witness, prime, err := circom.ReadWitnessFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if prime.Cmp(r.Field) != 0 {
+		return nil, fmt.Errorf("%s: the witness is over another field than the circuit", path)
+	}
+	if err := r.Check(witness); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	assignment, err := r.Assignment(witness)
+	if err != nil {
+		return nil, err
+	}
+	return []frontend.Circuit{assignment}, nil
+}
+
+// solveDistributed solves the assignments with the subcircuits evaluated by the workers at addrs
+func solveDistributed(solver *irwg.RootCircuit, assignments []frontend.Circuit, addrs []string) (*irwg.Witness, error) {
+	var evaluators []irwg.SubCircuitEvaluator
+	for _, addr := range addrs {
+		client, err := irwg.DialWorker(addr, solver)
+		if err != nil {
+			return nil, fmt.Errorf("worker %s: %w", addr, err)
+		}
+		defer client.Close()
+		evaluators = append(evaluators, client)
+	}
+	var res *irwg.Witness
+	for _, assignment := range assignments {
+		w, err := solver.SolveInputDistributed(assignment, evaluators)
+		if err != nil {
+			return nil, err
+		}
+		if res == nil {
+			res = w
+		} else {
+			res.NumWitnesses++
+			res.Values = append(res.Values, w.Values...)
+		}
+	}
+	return res, nil
+}
+
+func worker(args []string, stdout, stderr io.Writer) error {
+	var cf circuitFlags
+	fs := newFlagSet("worker", stderr)
+	fs.Var(&cf.plugins, "plugin", "Go plugin registering the hints of the circuit, may be repeated")
+	solverPath := fs.String("inputsolver", "inputsolver.txt", "input solver written by compile")
+	listen := fs.String("listen", ":7070", "address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := cf.loadPlugins(); err != nil {
+		return err
+	}
+	solverBuf, err := os.ReadFile(*solverPath)
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	fmt.Fprintf(stdout, "serving %s on %s\n", *solverPath, l.Addr())
+	return irwg.ServeWorker(ecgo.DeserializeInputSolver(solverBuf), l)
+}
+
+func serve(args []string, stdout, stderr io.Writer) error {
+	var cf circuitFlags
+	fs := newFlagSet("serve", stderr)
+	fs.Var(&cf.plugins, "plugin", "Go plugin registering circuits and their hints in its init functions, may be repeated")
+	listen := fs.String("listen", ":7071", "address to listen on")
+	ttl := fs.Duration("witness-ttl", server.DefaultWitnessTTL, "how long a solved witness is held without being fetched")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := cf.loadPlugins(); err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	fmt.Fprintf(stdout, "serving %s on %s\n", strings.Join(registry.Names(), ", "), l.Addr())
+	s := server.NewService()
+	s.WitnessTTL = *ttl
+	return server.Serve(s, l)
+}
+
+// costFlag is the usage of the -cost flag, whose files hold the cost models of hardware profiles
+const costFlag = "JSON file of a layered.LinearCostModel to predict the proving cost with, may be repeated"
+
+// printCosts prints the costs predicted by the models of the given files
+func printCosts(stdout io.Writer, files []string, cost func(layered.CostModel) layered.Cost) error {
+	for _, f := range files {
+		buf, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		var m layered.LinearCostModel
+		if err := json.Unmarshal(buf, &m); err != nil {
+			return fmt.Errorf("cost model %s: %w", f, err)
+		}
+		fmt.Fprintf(stdout, "proving cost with %s: %s\n", strings.TrimSuffix(filepath.Base(f), filepath.Ext(f)), cost(&m))
+	}
+	return nil
+}
+
+func estimate(args []string, stdout, stderr io.Writer) error {
+	var cf circuitFlags
+	var costs stringList
+	fs := newFlagSet("estimate", stderr)
+	cf.register(fs)
+	fs.Var(&costs, "cost", costFlag)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c, err := cf.circuit()
+	if err != nil {
+		return err
+	}
+	e, err := ecgo.EstimateResources(c.Field, c.New(), c.Options...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(stdout, e)
+	return printCosts(stdout, costs, e.ProvingCost)
+}
+
+func count(args []string, stdout, stderr io.Writer) error {
+	var cf circuitFlags
+	fs := newFlagSet("count", stderr)
+	cf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c, err := cf.circuit()
+	if err != nil {
+		return err
+	}
+	n, err := ecgo.CountConstraints(c.Field, c.New(), c.Options...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(stdout, n)
+	return nil
+}
+
+func lint(args []string, stdout, stderr io.Writer) error {
+	var cf circuitFlags
+	fs := newFlagSet("lint", stderr)
+	cf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c, err := cf.circuit()
+	if err != nil {
+		return err
+	}
+	vars, err := ecgo.FindUnderConstrained(c.Field, c.New(), c.Options...)
+	if err != nil {
+		return err
+	}
+	for i := range vars {
+		fmt.Fprintln(stdout, vars[i].String())
+	}
+	if len(vars) != 0 {
+		return fmt.Errorf("%d under-constrained variables", len(vars))
+	}
+	fmt.Fprintln(stdout, "no under-constrained variable")
+	return nil
+}
+
+func writeGolden(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("golden", stderr)
+	out := fs.String("out", ".", "directory of the vectors and of their manifest")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+	if err := golden.Generate(*out); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "wrote %s\n", filepath.Join(*out, golden.ManifestFile))
+	return nil
+}
+
+func stats(args []string, stdout, stderr io.Writer) error {
+	var cf circuitFlags
+	var costs stringList
+	fs := newFlagSet("stats", stderr)
+	cf.register(fs)
+	lcFile := fs.String("layered", "", "layered circuit written by compile, instead of compiling -circuit")
+	fs.Var(&costs, "cost", costFlag)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var lc *layered.RootCircuit
+	if *lcFile != "" {
+		var h *layered.Header
+		var err error
+		lc, h, err = readLayered(*lcFile)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "format version: %d, features: %s\n", h.Version, h.Features|lc.Features())
+	} else {
+		var err error
+		lc, err = compileLayered(&cf)
+		if err != nil {
+			return err
+		}
+	}
+	s := lc.Stats()
+	fmt.Fprint(stdout, s)
+	fmt.Fprint(stdout, lc.RelayStats())
+	return printCosts(stdout, costs, s.Cost)
+}
+
+// readLayered reads a layered circuit written by compile, in any of its formats
+func readLayered(path string) (*layered.RootCircuit, *layered.Header, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	h, _, err := layered.ReadHeader(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := h.Check(layered.Supported); err != nil {
+		return nil, nil, err
+	}
+	return ecgo.DeserializeLayeredCircuit(buf), h, nil
+}
+
+// compileLayered compiles the circuit selected by cf with its options
+func compileLayered(cf *circuitFlags) (*layered.RootCircuit, error) {
+	c, err := cf.circuit()
+	if err != nil {
+		return nil, err
+	}
+	res, err := ecgo.Compile(c.Field, c.New(), c.Options...)
+	if err != nil {
+		return nil, err
+	}
+	return res.GetLayeredCircuit(), nil
+}
+
+func exportCircuit(args []string, stdout, stderr io.Writer) error {
+	var cf circuitFlags
+	fs := newFlagSet("export", stderr)
+	cf.register(fs)
+	lcFile := fs.String("layered", "", "layered circuit written by compile, instead of compiling -circuit")
+	format := fs.String("format", "json", "format of the exported circuit: "+strings.Join(export.Names(), ", ")+", or one registered by a plugin")
+	out := fs.String("out", "", "file of the exported circuit, instead of stdout")
+	witness := fs.String("witness", "", "witness written by solve to export to -witness-out, in -witness-layout")
+	witnessOut := fs.String("witness-out", "", "file of the exported witness")
+	witnessLayout := fs.String("witness-layout", "gpu", "layout of the exported witness: expander, or gpu for direct upload to GPU provers, see export.WriteWitness")
+	witnessAlign := fs.Int("witness-align", export.DefaultWitnessAlignment, "alignment in bytes of the rows of the gpu witness layout")
+	key := fs.String("key", "", "file of the AES key of an encrypted -witness")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*witness == "") != (*witnessOut == "") {
+		return errors.New("-witness and -witness-out must be given together")
+	}
+	layout, err := export.ParseWitnessLayout(*witnessLayout)
+	if err != nil {
+		return err
+	}
+	// the plugins may register formats
+	if err := cf.loadPlugins(); err != nil {
+		return err
+	}
+	if _, ok := export.Get(*format); !ok {
+		return fmt.Errorf("unknown export format %q, registered formats: %s", *format, strings.Join(export.Names(), ", "))
+	}
+	var lc *layered.RootCircuit
+	if *lcFile != "" {
+		lc, _, err = readLayered(*lcFile)
+	} else {
+		lc, err = compileLayered(&cf)
+	}
+	if err != nil {
+		return err
+	}
+	if *witness != "" {
+		w, err := readWitnessFile(*witness, *key)
+		if err != nil {
+			return err
+		}
+		if err := writeExported(*witnessOut, func(f io.Writer) error {
+			return export.WriteWitness(f, lc, w, export.WitnessOptions{Layout: layout, Alignment: *witnessAlign})
+		}); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "wrote %s\n", *witnessOut)
+	}
+	if *out == "" {
+		return export.Write(stdout, *format, lc)
+	}
+	if err := writeExported(*out, func(f io.Writer) error { return export.Write(f, *format, lc) }); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "wrote %s\n", *out)
+	return nil
+}
+
+// writeExported creates the file path and writes it with write
+func writeExported(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readWitnessFile reads a witness written by solve, encrypted with the key of the file keyPath
+// if it's not empty
+func readWitnessFile(path string, keyPath string) (w *irwg.Witness, err error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if irwg.IsEncryptedWitness(buf) {
+		if keyPath == "" {
+			return nil, fmt.Errorf("%s: the witness is encrypted, its key must be given with -key", path)
+		}
+		key, err := readKey(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		return irwg.DeserializeEncryptedWitness(buf, key)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			w, err = nil, fmt.Errorf("%s: invalid witness: %v", path, r)
+		}
+	}()
+	return irwg.DeserializeWitness(buf), nil
+}
+
+func diff(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("diff", stderr)
+	check := fs.Bool("check", false, "fail if the circuits differ")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: ecc diff [-check] a.txt b.txt, with layered circuits written by compile")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("expected two layered circuits")
+	}
+	var circuits [2]*layered.RootCircuit
+	for i := range circuits {
+		buf, err := os.ReadFile(fs.Arg(i))
+		if err != nil {
+			return err
+		}
+		circuits[i] = ecgo.DeserializeLayeredCircuit(buf)
+	}
+	d := layered.Diff(circuits[0], circuits[1])
+	fmt.Fprint(stdout, d)
+	if *check && !d.Empty() {
+		return errors.New("the circuits differ")
+	}
+	return nil
+}
+
+// ParseAssignments parses assignments of the circuit from JSON, see irwg.ParseAssignmentsJSON.
+func ParseAssignments(c registry.Circuit, buf []byte) ([]frontend.Circuit, error) {
+	return irwg.ParseAssignmentsJSON(c.New, buf)
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"bytes"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/export"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/registry"
+	"github.com/consensys/gnark/frontend"
+)
+
+type cliCircuit struct {
+	X frontend.Variable
+	Y [2]frontend.Variable `gnark:",public"`
+}
+
+func (c *cliCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Add(c.Y[0], c.Y[1]), c.X)
+	return nil
+}
+
+var cliTestCircuit = registry.Circuit{
+	Name:  "cli_test",
+	Field: m31.ScalarField,
+	New:   func() frontend.Circuit { return &cliCircuit{} },
+}
+
+func init() {
+	registry.Register(cliTestCircuit)
+}
+
+func TestParseAssignments(t *testing.T) {
+	res, err := ParseAssignments(cliTestCircuit, []byte(`{"X": 3, "Y_0": "1", "Y_1": "0x2"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := res[0].(*cliCircuit)
+	if len(res) != 1 || a.X.(interface{ String() string }).String() != "3" || a.Y[1].(interface{ String() string }).String() != "2" {
+		t.Fatalf("unexpected assignment %+v", a)
+	}
+
+	res, err = ParseAssignments(cliTestCircuit, []byte(`[{"X": 3, "Y_0": 1, "Y_1": 2}, {"X": 4, "Y_0": 2, "Y_1": 2}]`))
+	if err != nil || len(res) != 2 {
+		t.Fatalf("expected 2 assignments, got %d, %v", len(res), err)
+	}
+
+	if _, err := ParseAssignments(cliTestCircuit, []byte(`{"X": 3, "Y_0": 1}`)); err == nil || !strings.Contains(err.Error(), "missing value of Y_1") {
+		t.Fatalf("expected a missing value, got %v", err)
+	}
+	if _, err := ParseAssignments(cliTestCircuit, []byte(`{"X": 3, "Y_0": 1, "Y_1": 2, "Z": 1}`)); err == nil || !strings.Contains(err.Error(), "unknown variables Z") {
+		t.Fatalf("expected an unknown variable, got %v", err)
+	}
+	if _, err := ParseAssignments(cliTestCircuit, []byte(`{"X": "three", "Y_0": 1, "Y_1": 2}`)); err == nil {
+		t.Fatal("expected an invalid value")
+	}
+}
+
+func TestMain(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := Main([]string{"list"}, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "cli_test\n") {
+		t.Fatalf("list failed with %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	stderr.Reset()
+	if code := Main([]string{"compile", "-circuit", "nope"}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), `unknown circuit "nope"`) {
+		t.Fatalf("expected an unknown circuit, got %d: %s", code, stderr.String())
+	}
+	stderr.Reset()
+	if code := Main([]string{"compile", "-circuit", "cli_test", "-relays", "nope"}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), `unknown relay strategy "nope"`) {
+		t.Fatalf("expected an unknown relay strategy, got %d: %s", code, stderr.String())
+	}
+	stderr.Reset()
+	if code := Main([]string{"compile", "-circuit", "cli_test", "-r1cs", "circuit.r1cs"}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "-circuit and -r1cs are exclusive") {
+		t.Fatalf("expected exclusive circuits, got %d: %s", code, stderr.String())
+	}
+	stdout.Reset()
+	model := filepath.Join(t.TempDir(), "laptop.json")
+	if err := os.WriteFile(model, []byte(`{"AddGate": {"Nanoseconds": 1000}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := Main([]string{"estimate", "-circuit", "cli_test", "-cost", model}, &stdout, &stderr); code != 0 ||
+		!strings.Contains(stdout.String(), "peak memory") || !strings.Contains(stdout.String(), "proving cost with laptop: time: ") {
+		t.Fatalf("estimate failed with %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	stdout.Reset()
+	if code := Main([]string{"count", "-circuit", "cli_test"}, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "constraints: ") {
+		t.Fatalf("count failed with %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	stdout.Reset()
+	if code := Main([]string{"lint", "-circuit", "cli_test"}, &stdout, &stderr); code != 0 || stdout.String() != "no under-constrained variable\n" {
+		t.Fatalf("lint failed with %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	if code := Main([]string{"frobnicate"}, &stdout, &stderr); code != 2 {
+		t.Fatalf("expected a usage error, got %d", code)
+	}
+	if code := Main([]string{"solve", "-h"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected the help of solve, got %d", code)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, gates int) string {
+		c := &layered.Circuit{InputLen: 2, OutputLen: 1}
+		for i := 0; i < gates; i++ {
+			c.Add = append(c.Add, layered.GateAdd{In: uint64(i), Out: 0, Coef: big.NewInt(1), CoefType: 1})
+		}
+		rc := &layered.RootCircuit{Circuits: []*layered.Circuit{c}, Layers: []uint64{0}, Field: m31.ScalarField}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, rc.Serialize(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a, b := write("a.txt", 1), write("b.txt", 2)
+	var stdout, stderr bytes.Buffer
+	if code := Main([]string{"diff", "-check", a, a}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected no difference, got %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	stdout.Reset()
+	if code := Main([]string{"diff", "-check", a, b}, &stdout, &stderr); code != 1 || !strings.Contains(stdout.String(), "total gates: 1 -> 2 (+1)") {
+		t.Fatalf("expected a difference, got %d: %s%s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestExport(t *testing.T) {
+	c := &layered.Circuit{InputLen: 2, OutputLen: 1, Mul: []layered.GateMul{{In0: 0, In1: 1, Out: 0, Coef: big.NewInt(2), CoefType: 1}}}
+	rc := &layered.RootCircuit{NumActualOutputs: 1, Circuits: []*layered.Circuit{c}, Layers: []uint64{0}, Field: m31.ScalarField}
+	path := filepath.Join(t.TempDir(), "circuit.txt")
+	if err := os.WriteFile(path, rc.Serialize(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := Main([]string{"export", "-layered", path, "-format", "text"}, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "layer 2 1 1\nmul 0 0 1 2\n") {
+		t.Fatalf("export failed with %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	if code := Main([]string{"export", "-layered", path, "-format", "nope"}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), `unknown export format "nope"`) {
+		t.Fatalf("expected an unknown format, got %d: %s", code, stderr.String())
+	}
+	w := &irwg.Witness{NumWitnesses: 1, NumInputsPerWitness: 2, Field: m31.ScalarField, Values: []*big.Int{big.NewInt(3), big.NewInt(4)}}
+	witness := filepath.Join(filepath.Dir(path), "witness.txt")
+	gpu := filepath.Join(filepath.Dir(path), "witness.gpu")
+	if err := os.WriteFile(witness, w.Serialize(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := Main([]string{"export", "-layered", path, "-witness", witness, "-witness-out", gpu}, &stdout, &stderr); code != 0 {
+		t.Fatalf("export of the witness failed with %d: %s", code, stderr.String())
+	}
+	if buf, err := os.ReadFile(gpu); err != nil || len(buf) != 3*export.DefaultWitnessAlignment || !bytes.HasPrefix(buf, []byte("ECGOGPUW")) {
+		t.Fatalf("unexpected gpu witness, %v", err)
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	dir := t.TempDir()
+	for _, c := range []struct {
+		content string
+		size    int
+	}{
+		{"hex:" + strings.Repeat("ab", 32) + "\n", 32},
+		{strings.Repeat("k", 16), 16},
+		// a raw key which is valid hex isn't decoded
+		{strings.Repeat("ab", 16), 32},
+		{"hex:" + strings.Repeat("ab", 16), 16},
+		{"hex:abcd", 0},
+		{"hex:" + strings.Repeat("k", 32), 0},
+		{"abcd", 0},
+	} {
+		path := filepath.Join(dir, "key")
+		if err := os.WriteFile(path, []byte(c.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		key, err := readKey(path)
+		if c.size == 0 {
+			if err == nil {
+				t.Fatalf("expected an invalid key for %q", c.content)
+			}
+		} else if err != nil || len(key) != c.size {
+			t.Fatalf("expected a key of %d bytes for %q, got %d, %v", c.size, c.content, len(key), err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package ecgo
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/frontend"
+)
+
+// ConstraintCount is the number of constraints and gates of a circuit, see CountConstraints.
+type ConstraintCount struct {
+	// Instructions, Constraints and Gates are counted once per call of a subcircuit. Gates
+	// estimates the layered circuit like ResourceEstimate, without the relay gates.
+	Instructions uint64
+	Constraints  uint64
+	Gates        uint64
+	SubCircuits  int
+	// Gadgets are the counts of each gadget, sorted by decreasing gates.
+	Gadgets []GadgetCount
+}
+
+// GadgetCount is the cost attributed to a gadget, the innermost function recorded in the source
+// locations of the instructions and constraints it creates.
+type GadgetCount struct {
+	Gadget       string
+	Instructions uint64
+	Constraints  uint64
+	Gates        uint64
+}
+
+// unknownGadget is the gadget of the instructions without a source location
+const unknownGadget = "unknown"
+
+// CountConstraints defines the circuit like Compile and counts its constraints and gates per
+// gadget, without optimizing or layering it, so that it finishes in seconds for circuits taking
+// minutes to compile. Since the circuit isn't optimized, the counts are upper bounds of the ones
+// of the compiled circuit, e.g. the constants aren't folded. The gadgets are only known with the
+// source locations, see WithSourceLocations, which are recorded by default.
+func CountConstraints(fieldOrder *big.Int, circuit frontend.Circuit, opts ...frontend.CompileOption) (*ConstraintCount, error) {
+	opt, config, err := applyOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	p := &progress{ctx: context.Background(), f: config.progress}
+	root, _, _, err := defineRoot(fieldOrder, circuit, opt, config, p)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.report("finalize", 0); err != nil {
+		return nil, err
+	}
+	rc := root.Finalize()
+	root.ResetArena()
+	return countConstraints(rc), nil
+}
+
+// countConstraints counts the constraints and gates of rc per gadget
+func countConstraints(rc *irsource.RootCircuit) *ConstraintCount {
+	calls := rc.CallCounts()
+	gadgets := map[string]*GadgetCount{}
+	get := func(loc uint32) *GadgetCount {
+		key := gadgetName(rc.Location(loc))
+		g, ok := gadgets[key]
+		if !ok {
+			g = &GadgetCount{Gadget: key}
+			gadgets[key] = g
+		}
+		return g
+	}
+	res := &ConstraintCount{SubCircuits: len(rc.Circuits) - 1}
+	for _, id := range rc.CircuitIds() {
+		n := calls[id]
+		if n == 0 {
+			continue
+		}
+		c := rc.Circuits[id]
+		for i := range c.Instructions {
+			in := &c.Instructions[i]
+			if in.Type == irsource.SubCircuitCall {
+				continue
+			}
+			g := get(in.Loc)
+			g.Instructions += n
+			g.Gates += n * uint64(estimatedGates(in))
+		}
+		for _, con := range c.Constraints {
+			g := get(con.Loc)
+			g.Constraints += n
+			g.Gates += n
+		}
+	}
+	res.Gadgets = make([]GadgetCount, 0, len(gadgets))
+	for _, g := range gadgets {
+		res.Instructions += g.Instructions
+		res.Constraints += g.Constraints
+		res.Gates += g.Gates
+		res.Gadgets = append(res.Gadgets, *g)
+	}
+	sort.Slice(res.Gadgets, func(i, j int) bool {
+		if a, b := res.Gadgets[i].Gates, res.Gadgets[j].Gates; a != b {
+			return a > b
+		}
+		return res.Gadgets[i].Gadget < res.Gadgets[j].Gadget
+	})
+	return res
+}
+
+// gadgetName returns the function of the innermost frame of l, without the path of its package
+func gadgetName(l irsource.SourceLocation) string {
+	if len(l) == 0 {
+		return unknownGadget
+	}
+	name := l[0].Function
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+func (c *ConstraintCount) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "instructions: %d, constraints: %d, gates: ~%d, subcircuits: %d\n", c.Instructions, c.Constraints, c.Gates, c.SubCircuits)
+	for _, g := range c.Gadgets {
+		fmt.Fprintf(&sb, "%10d gates %8d constraints %8d instructions  %s\n", g.Gates, g.Constraints, g.Instructions, g.Gadget)
+	}
+	return sb.String()
+}
@@ -0,0 +1,29 @@
+package ecgo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+)
+
+func TestCountConstraints(t *testing.T) {
+	c, err := CountConstraints(m31.ScalarField, &estimateCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.SubCircuits != 1 || c.Constraints != 1 {
+		t.Fatalf("unexpected counts %+v", c)
+	}
+	var gates uint64
+	for _, g := range c.Gadgets {
+		// the 2 multiplications of each of the 4 calls of cube
+		if g.Gadget == "ecgo.cube" && (g.Instructions != 4 || g.Gates != 8) {
+			t.Fatalf("unexpected counts of cube %+v", g)
+		}
+		gates += g.Gates
+	}
+	if gates != c.Gates || !strings.Contains(c.String(), "ecgo.cube\n") || !strings.Contains(c.String(), "ecgo.(*estimateCircuit).Define") {
+		t.Fatalf("unexpected summary %q", c.String())
+	}
+}
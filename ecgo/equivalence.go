@@ -0,0 +1,188 @@
+package ecgo
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+)
+
+// EquivalenceReport summarizes a successful CheckEquivalence.
+type EquivalenceReport struct {
+	// Assignments is the number of checked assignments, the given ones and their mutations.
+	Assignments int
+	// Satisfied is the number of assignments satisfying both circuits.
+	Satisfied int
+}
+
+// EquivalenceMismatch is returned by CheckEquivalence for an assignment satisfying only one of
+// the gnark R1CS and the layered circuit.
+type EquivalenceMismatch struct {
+	// Index is the index of the given assignment the checked one derives from.
+	Index int
+	// Mutated is set when the checked assignment is a mutation of the given one.
+	Mutated bool
+	// Secret and Public are the values of the checked assignment, in declaration order.
+	Secret, Public []*big.Int
+	// R1CS and Layered are the reasons each circuit rejects the assignment, nil if it's satisfied.
+	R1CS, Layered error
+	// Redacted is set by Redact.
+	Redacted bool
+}
+
+// ErrRedacted replaces the reasons of an EquivalenceMismatch removed by Redact.
+var ErrRedacted = errors.New("reason redacted")
+
+// Redact removes the secret values from e, and replaces its reasons by ErrRedacted, since they
+// may quote secret values, so that the mismatch can be shared, e.g. in a bug report.
+func (e *EquivalenceMismatch) Redact() {
+	e.Secret = nil
+	if e.R1CS != nil {
+		e.R1CS = ErrRedacted
+	}
+	if e.Layered != nil {
+		e.Layered = ErrRedacted
+	}
+	e.Redacted = true
+}
+
+func (e *EquivalenceMismatch) Error() string {
+	which := "assignment"
+	if e.Mutated {
+		which = "mutation of assignment"
+	}
+	if e.Redacted {
+		if e.R1CS == nil {
+			return fmt.Sprintf("%s %d satisfies the R1CS but not the layered circuit (public %v, secret redacted)", which, e.Index, e.Public)
+		}
+		return fmt.Sprintf("%s %d satisfies the layered circuit but not the R1CS (public %v, secret redacted)", which, e.Index, e.Public)
+	}
+	if e.R1CS == nil {
+		return fmt.Sprintf("%s %d satisfies the R1CS but not the layered circuit: %v (secret %v, public %v)", which, e.Index, e.Layered, e.Secret, e.Public)
+	}
+	return fmt.Sprintf("%s %d satisfies the layered circuit but not the R1CS: %v (secret %v, public %v)", which, e.Index, e.R1CS, e.Secret, e.Public)
+}
+
+// CheckEquivalence compiles circuit with gnark's R1CS builder, and checks that each assignment
+// satisfies the R1CS if and only if it satisfies the layered circuit of c, which should be
+// compiled from the same circuit. Since random assignments almost never satisfy a circuit, each
+// assignment is also checked with mutations trials times, each setting a random input to a
+// random value; satisfying assignments make the check meaningful. The mutations are
+// deterministic, so that a mismatch is reproducible.
+//
+// It returns an *EquivalenceMismatch for the first assignment on which the circuits disagree.
+// The field must be supported by gnark, and the circuit must not use the methods of API, which
+// gnark doesn't implement.
+func (c *CompileResult) CheckEquivalence(circuit frontend.Circuit, assignments []frontend.Circuit, trials int) (*EquivalenceReport, error) {
+	f := c.irs.Field
+	ccs, err := compileR1CS(f.Field(), circuit)
+	if err != nil {
+		return nil, fmt.Errorf("gnark R1CS: %w", err)
+	}
+	r := rand.New(rand.NewSource(1))
+	report := &EquivalenceReport{}
+	for i, assignment := range assignments {
+		pub, sec := irwg.GetCircuitVariables(assignment, f)
+		public, secret := toBigInts(f, pub), toBigInts(f, sec)
+		for t := 0; t <= trials; t++ {
+			e := &EquivalenceMismatch{Index: i, Public: public, Secret: secret}
+			if t > 0 {
+				e.Public, e.Secret = mutate(r, f.Field(), public, secret)
+				e.Mutated = true
+			}
+			e.R1CS = solveR1CS(ccs, e.Public, e.Secret)
+			e.Layered = c.checkValues(e.Secret, e.Public)
+			if (e.R1CS == nil) != (e.Layered == nil) {
+				return nil, e
+			}
+			report.Assignments++
+			if e.R1CS == nil {
+				report.Satisfied++
+			}
+		}
+	}
+	return report, nil
+}
+
+// compileR1CS compiles circuit with gnark's R1CS builder, turning panics into errors, e.g. the
+// ones of the circuits calling API
+func compileR1CS(field *big.Int, circuit frontend.Circuit) (ccs constraint.ConstraintSystem, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return frontend.Compile(field, r1cs.NewBuilder, circuit)
+}
+
+// solveR1CS returns nil if the values satisfy ccs
+func solveR1CS(ccs constraint.ConstraintSystem, public, secret []*big.Int) error {
+	w, err := witness.New(ccs.Field())
+	if err != nil {
+		return err
+	}
+	values := make(chan any, len(public)+len(secret))
+	for _, x := range append(public[:len(public):len(public)], secret...) {
+		values <- x
+	}
+	close(values)
+	if err := w.Fill(len(public), len(secret), values); err != nil {
+		return err
+	}
+	return ccs.IsSolved(w)
+}
+
+// checkValues returns nil if the values satisfy the layered circuit
+func (c *CompileResult) checkValues(secret, public []*big.Int) error {
+	w, err := c.irwg.SolveInputValues(secret, public)
+	if err != nil {
+		return err
+	}
+	_, err = c.Outputs(w)
+	return err
+}
+
+// mutate returns copies of the values with a random one set to a random value, biased towards
+// its neighbours and the edge cases 0 and 1
+func mutate(r *rand.Rand, field *big.Int, public, secret []*big.Int) ([]*big.Int, []*big.Int) {
+	public = append([]*big.Int(nil), public...)
+	secret = append([]*big.Int(nil), secret...)
+	if len(public)+len(secret) == 0 {
+		return public, secret
+	}
+	i := r.Intn(len(public) + len(secret))
+	v := &public
+	if i >= len(public) {
+		i -= len(public)
+		v = &secret
+	}
+	x := new(big.Int)
+	switch r.Intn(4) {
+	case 0:
+		x.Add((*v)[i], big.NewInt(1))
+	case 1:
+		x.Sub((*v)[i], big.NewInt(1))
+	case 2:
+		x.SetInt64(r.Int63n(2))
+	default:
+		x.Rand(r, field)
+	}
+	(*v)[i] = x.Mod(x, field)
+	return public, secret
+}
+
+func toBigInts(f field.Field, values []constraint.Element) []*big.Int {
+	res := make([]*big.Int, len(values))
+	for i, x := range values {
+		res[i] = f.ToBigInt(x)
+	}
+	return res
+}
@@ -0,0 +1,72 @@
+package ecgo
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+)
+
+type equivalenceCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *equivalenceCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.X, c.X), c.Y)
+	return nil
+}
+
+func TestSolveR1CS(t *testing.T) {
+	ccs, err := compileR1CS(ecc.BN254.ScalarField(), &equivalenceCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := solveR1CS(ccs, []*big.Int{big.NewInt(27)}, []*big.Int{big.NewInt(3)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := solveR1CS(ccs, []*big.Int{big.NewInt(28)}, []*big.Int{big.NewInt(3)}); err == nil {
+		t.Fatal("expected an unsatisfied assignment to be rejected")
+	}
+
+	// gnark doesn't implement API
+	if _, err := compileR1CS(ecc.BN254.ScalarField(), &checkCircuit{}); err == nil {
+		t.Fatal("expected a circuit calling API to be rejected")
+	}
+}
+
+func TestMutate(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	public, secret := []*big.Int{big.NewInt(5)}, []*big.Int{big.NewInt(6), big.NewInt(7)}
+	for i := 0; i < 100; i++ {
+		p, s := mutate(r, m31.ScalarField, public, secret)
+		changed := 0
+		for j, x := range append(p, s...) {
+			if x.Sign() < 0 || x.Cmp(m31.ScalarField) >= 0 {
+				t.Fatalf("value %s out of the field", x)
+			}
+			if x.Cmp(append(public, secret...)[j]) != 0 {
+				changed++
+			}
+		}
+		if changed > 1 {
+			t.Fatalf("expected at most one value to change, got %v %v", p, s)
+		}
+	}
+	if public[0].Int64() != 5 || secret[0].Int64() != 6 || secret[1].Int64() != 7 {
+		t.Fatal("expected the values to be copied")
+	}
+	e := &EquivalenceMismatch{Index: 2, Mutated: true, Secret: secret, Public: public, Layered: errors.New("unsatisfied")}
+	if msg := e.Error(); !strings.HasPrefix(msg, "mutation of assignment 2 satisfies the R1CS but not the layered circuit") {
+		t.Fatalf("unexpected message %q", msg)
+	}
+	e.Redact()
+	if msg := e.Error(); msg != "mutation of assignment 2 satisfies the R1CS but not the layered circuit (public [5], secret redacted)" || e.Secret != nil || e.Layered != ErrRedacted || e.R1CS != nil {
+		t.Fatalf("unexpected redacted message %q", msg)
+	}
+}
@@ -0,0 +1,153 @@
+package ecgo
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"runtime"
+	"strings"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/consensys/gnark/frontend"
+)
+
+// ResourceEstimate is an estimate of the resources needed to compile a circuit, see
+// EstimateResources.
+type ResourceEstimate struct {
+	// Variables, Instructions and Constraints are counted in the optimized IR, once per circuit.
+	Variables    int
+	Instructions int
+	Constraints  int
+	SubCircuits  int
+	// Gates and Layers estimate the layered circuit like WriteProfile, counting the gates of each
+	// call of a subcircuit, without the relay gates between layers.
+	Gates  uint64
+	Layers int
+	// BuildMemory is the memory held by the definition of the circuit, in bytes.
+	BuildMemory uint64
+	// PeakMemory is an estimate of the peak memory of the compilation, in bytes.
+	PeakMemory uint64
+	// CircuitSize is an estimate of the size of the serialized layered circuit, in bytes. The
+	// gates of a subcircuit are stored once, however many times it's called.
+	CircuitSize uint64
+
+	// layers are the estimated layers, see ProvingCost
+	layers []layered.LayerStats
+}
+
+// layeringMemoryFactor is the ratio between the memory used by the layering of a circuit and the
+// size of the serialized result, the intermediate layered circuits being held until the end
+const layeringMemoryFactor = 4
+
+// EstimateResources defines and optimizes the circuit like Compile, without layering it, and
+// estimates the resources needed by its compilation. The memory held by the definition is
+// measured, while the memory of the layering and the size of the layered circuit are predicted
+// from the number of gates, so that a machine can be chosen before a long compilation.
+func EstimateResources(fieldOrder *big.Int, circuit frontend.Circuit, opts ...frontend.CompileOption) (*ResourceEstimate, error) {
+	opt, config, err := applyOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	p := &progress{ctx: context.Background(), f: config.progress}
+	root, _, _, err := defineRoot(fieldOrder, circuit, opt, config, p)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.report("finalize", 0); err != nil {
+		return nil, err
+	}
+	rc := root.Finalize()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(root)
+	root.ResetArena()
+	if err := optimize(rc, config, p); err != nil {
+		return nil, err
+	}
+
+	e := &ResourceEstimate{SubCircuits: len(rc.Circuits) - 1}
+	if after.HeapAlloc > before.HeapAlloc {
+		e.BuildMemory = after.HeapAlloc - before.HeapAlloc
+	}
+	for _, c := range rc.Circuits {
+		e.Variables += c.NumVariables()
+		e.Instructions += len(c.Instructions)
+		e.Constraints += len(c.Constraints)
+	}
+	var distinct uint64
+	e.Gates, distinct = estimatedGateCounts(rc)
+	e.Layers = estimatedLayers(rc)
+	e.layers = newProfiler(rc).estimatedLayerStats()
+
+	// a mul gate is 3 wires, the tag of its coefficient and the coefficient
+	gateSize := uint64(3*8 + 1 + field.GetFieldFromOrder(fieldOrder).SerializedLen())
+	e.CircuitSize = distinct * gateSize
+	e.PeakMemory = e.BuildMemory + uint64(len(irsource.SerializeRootCircuit(rc))) + layeringMemoryFactor*e.CircuitSize
+	return e, nil
+}
+
+// ProvingCost returns the cost of proving the circuit predicted by m from the estimated layers of
+// the circuit, without the padding and the relay gates between layers.
+func (e *ResourceEstimate) ProvingCost(m layered.CostModel) layered.Cost {
+	return (&layered.Stats{Layers: e.layers}).Cost(m)
+}
+
+// estimatedGateCounts returns the estimated number of gates of the layered circuit, counting
+// each call of a subcircuit, and the number of gates of the circuits called
+func estimatedGateCounts(rc *irsource.RootCircuit) (total, distinct uint64) {
+	calls := rc.CallCounts()
+	for id, c := range rc.Circuits {
+		if calls[id] == 0 {
+			continue
+		}
+		gates := uint64(len(c.Constraints))
+		for i := range c.Instructions {
+			gates += uint64(estimatedGates(&c.Instructions[i]))
+		}
+		distinct += gates
+		total += calls[id] * gates
+	}
+	return total, distinct
+}
+
+// estimatedLayers returns the depth of the deepest output or constraint of the root circuit
+func estimatedLayers(rc *irsource.RootCircuit) int {
+	root := rc.Circuits[0]
+	depth := newProfiler(rc).variableDepths(0)
+	d := 0
+	for _, x := range root.Outputs {
+		d = max(d, depth[x])
+	}
+	for _, con := range root.Constraints {
+		d = max(d, depth[con.Var])
+	}
+	// the output layer combines the outputs and constraints
+	return d + 1
+}
+
+func (e *ResourceEstimate) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "variables: %d, instructions: %d, constraints: %d, subcircuits: %d\n", e.Variables, e.Instructions, e.Constraints, e.SubCircuits)
+	fmt.Fprintf(&sb, "gates: ~%d, layers: ~%d\n", e.Gates, e.Layers)
+	fmt.Fprintf(&sb, "build memory: %s, peak memory: ~%s, layered circuit: ~%s\n", formatBytes(e.BuildMemory), formatBytes(e.PeakMemory), formatBytes(e.CircuitSize))
+	return sb.String()
+}
+
+// formatBytes formats n with a binary unit
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,54 @@
+package ecgo
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/consensys/gnark/frontend"
+)
+
+func cube(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	return []frontend.Variable{api.Mul(input[0], input[0], input[0])}
+}
+
+type estimateCircuit struct {
+	X [4]frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *estimateCircuit) Define(api frontend.API) error {
+	var sum frontend.Variable = 0
+	for _, x := range c.X {
+		sum = api.Add(sum, api.(API).MemorizedSimpleCall(cube, []frontend.Variable{x})[0])
+	}
+	api.AssertIsEqual(sum, c.Y)
+	return nil
+}
+
+func TestEstimateResources(t *testing.T) {
+	e, err := EstimateResources(m31.ScalarField, &estimateCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.SubCircuits != 1 || e.Constraints != 1 {
+		t.Fatalf("unexpected circuits %+v", e)
+	}
+	// the 2 multiplications of each of the 4 calls of cube
+	if e.Gates < 8 || e.Layers != 3 {
+		t.Fatalf("unexpected gates %d or layers %d", e.Gates, e.Layers)
+	}
+	if e.CircuitSize == 0 || e.PeakMemory < e.CircuitSize {
+		t.Fatalf("unexpected sizes %+v", e)
+	}
+	// the multiplications of cube and the layers of the estimate
+	c := e.ProvingCost(&layered.LinearCostModel{MulGate: layered.CostCoef{Nanoseconds: 1}, Layer: layered.CostCoef{Nanoseconds: 10}})
+	if c.Time != 38*time.Nanosecond {
+		t.Fatalf("unexpected proving cost %v", c)
+	}
+	if !strings.Contains(e.String(), "peak memory") {
+		t.Fatalf("unexpected summary %q", e.String())
+	}
+}
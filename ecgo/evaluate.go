@@ -0,0 +1,29 @@
+package ecgo
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// Evaluate runs the compiled circuit forward on the assignments, without proving: it solves their
+// witness with the input solver, and evaluates the layered circuit on it layer by layer, see
+// layered.RootCircuit.Eval. It returns the values of the output layer: the outputs expected to be
+// zero, which are zero when the assignments satisfy the circuit, followed by the values given to
+// API.Output and the padding of the layer. A circuit compiled by CompileBatch takes BatchSize
+// assignments, one per copy, and the other circuits a single one.
+func Evaluate(compiled *CompileResult, assignments ...frontend.Circuit) ([]*big.Int, error) {
+	if len(assignments) != compiled.BatchSize() {
+		return nil, fmt.Errorf("expected %d assignments, got %d", compiled.BatchSize(), len(assignments))
+	}
+	w, err := compiled.GetInputSolver().SolveInputs(assignments)
+	if err != nil {
+		return nil, err
+	}
+	if w, err = compiled.ReplicateInputs(w); err != nil {
+		return nil, err
+	}
+	a := w.NumInputsPerWitness
+	return compiled.GetLayeredCircuit().Eval(w.Values[:a], w.Values[a:])
+}
@@ -39,7 +39,12 @@ func (circuit *Circuit) Define(api frontend.API) error {
 
 func main() {
 	// Before we use custom gates, we must register it
-	customgates.Register(GATE_4TH_POWER_TYPE, Power4, GATE_4TH_POWER_COST)
+	customgates.RegisterSpec(GATE_4TH_POWER_TYPE, customgates.Spec{
+		Func:   Power4,
+		Cost:   GATE_4TH_POWER_COST,
+		Degree: 4,
+		Arity:  1,
+	})
 
 	circuit, err := ecgo.Compile(m31.ScalarField, &Circuit{})
 	if err != nil {
@@ -67,4 +72,11 @@ func main() {
 	os.WriteFile("inputsolver.txt", inputSolver.Serialize(), 0o644)
 	os.WriteFile("circuit.txt", c.Serialize(), 0o644)
 	os.WriteFile("witness.txt", witness.Serialize(), 0o644)
+
+	// The prover needs to know the custom gates used by the circuit
+	metadata, err := circuit.CustomGateMetadata()
+	if err != nil {
+		panic(err)
+	}
+	os.WriteFile("customgates.txt", customgates.SerializeMetadata(metadata), 0o644)
 }
@@ -0,0 +1,96 @@
+// Package export writes layered circuits in the formats of other GKR-based provers, so that the
+// circuits compiled by ecgo aren't tied to the native format of Expander. Each format is a
+// backend registered by name: the package registers expander, the native format, json, a generic
+// JSON layered format, and text, a line-oriented format in the style of the circuit files of
+// Libra and Virgo. Other backends register themselves in an init function of their package,
+// which is linked into the tool, e.g. a Go plugin loaded by the ecc command.
+//
+// The json and text formats have no subcircuits: the gates of each layer are inlined, see
+// layered.RootCircuit.FlatLayer, and the inputs of a gate are wires of the previous layer, or
+// the inputs of the witness for the first layer.
+//
+// WriteWitness writes the witnesses of a circuit in the native layout of Expander, or in a
+// structure-of-arrays layout for GPU provers.
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+// Backend writes layered circuits in a format.
+type Backend interface {
+	// Name identifies the format, e.g. "json".
+	Name() string
+	// Extension is the extension of the files of the format, e.g. ".json".
+	Extension() string
+	// Write writes the circuit to w. It returns an error if the format can't represent the
+	// circuit, e.g. its custom gates.
+	Write(w io.Writer, rc *layered.RootCircuit) error
+}
+
+var (
+	backends  = make(map[string]Backend)
+	backendsM sync.RWMutex
+)
+
+// Register adds a backend. It panics if its name is already taken.
+func Register(b Backend) {
+	backendsM.Lock()
+	defer backendsM.Unlock()
+	if _, ok := backends[b.Name()]; ok {
+		panic(fmt.Sprintf("export format %q is already registered", b.Name()))
+	}
+	backends[b.Name()] = b
+}
+
+// Get returns the backend registered with the given name.
+func Get(name string) (Backend, bool) {
+	backendsM.RLock()
+	defer backendsM.RUnlock()
+	b, ok := backends[name]
+	return b, ok
+}
+
+// Names returns the names of the registered backends in increasing order.
+func Names() []string {
+	backendsM.RLock()
+	defer backendsM.RUnlock()
+	res := make([]string, 0, len(backends))
+	for name := range backends {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Write writes the circuit to w in the format of the backend registered with the given name.
+func Write(w io.Writer, format string, rc *layered.RootCircuit) error {
+	b, ok := Get(format)
+	if !ok {
+		return fmt.Errorf("unknown export format %q, registered formats: %s", format, strings.Join(Names(), ", "))
+	}
+	return b.Write(w, rc)
+}
+
+func init() {
+	Register(expander{})
+	Register(jsonBackend{})
+	Register(text{})
+}
+
+// expander is the native format of Expander, see layered.RootCircuit.Serialize
+type expander struct{}
+
+func (expander) Name() string      { return "expander" }
+func (expander) Extension() string { return ".txt" }
+
+func (expander) Write(w io.Writer, rc *layered.RootCircuit) error {
+	_, err := w.Write(rc.Serialize())
+	return err
+}
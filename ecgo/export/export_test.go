@@ -0,0 +1,154 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+// sampleCircuit multiplies two pairs of inputs with two calls of a subcircuit, then adds the
+// products and a public input
+func sampleCircuit() *layered.RootCircuit {
+	sub := &layered.Circuit{
+		InputLen:  2,
+		OutputLen: 1,
+		Mul:       []layered.GateMul{{In0: 0, In1: 1, Out: 0, Coef: big.NewInt(1), CoefType: 1}},
+	}
+	l0 := &layered.Circuit{
+		InputLen:    4,
+		OutputLen:   2,
+		SubCircuits: []layered.SubCircuit{{Id: 0, Allocations: []layered.Allocation{{}, {InputOffset: 2, OutputOffset: 1}}}},
+	}
+	l1 := &layered.Circuit{
+		InputLen:  2,
+		OutputLen: 1,
+		Add: []layered.GateAdd{
+			{In: 0, Out: 0, Coef: big.NewInt(3), CoefType: 1},
+			{In: 1, Out: 0, Coef: big.NewInt(0), CoefType: 2},
+		},
+		Cst: []layered.GateCst{{Out: 0, Coef: big.NewInt(0), CoefType: 3, PublicInputId: 0}},
+	}
+	return &layered.RootCircuit{
+		NumPublicInputs:  1,
+		NumActualOutputs: 1,
+		Circuits:         []*layered.Circuit{sub, l0, l1},
+		Layers:           []uint64{1, 2},
+		Field:            m31.ScalarField,
+	}
+}
+
+func TestNames(t *testing.T) {
+	if names := strings.Join(Names(), ","); names != "expander,json,text" {
+		t.Fatalf("unexpected backends %s", names)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a duplicate backend")
+		}
+	}()
+	Register(text{})
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "json", sampleCircuit()); err != nil {
+		t.Fatal(err)
+	}
+	var c JSONCircuit
+	if err := json.Unmarshal(buf.Bytes(), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != JSONVersion || c.NumInputs != 4 || c.NumPublicInputs != 1 || len(c.Layers) != 2 {
+		t.Fatalf("unexpected circuit %+v", c)
+	}
+	l0 := c.Layers[0]
+	if len(l0.Gates) != 2 || l0.Gates[1].Type != "mul" || l0.Gates[1].In[0] != 2 || l0.Gates[1].Out != 1 {
+		t.Fatalf("expected the subcircuit calls to be inlined, got %+v", l0)
+	}
+	kinds := []string{}
+	for _, g := range c.Layers[1].Gates {
+		kinds = append(kinds, g.CoefKind)
+	}
+	if strings.Join(kinds, ",") != "constant,random,public" || c.Layers[1].Gates[0].Coef != "3" {
+		t.Fatalf("unexpected coefficients %+v", c.Layers[1].Gates)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "text", sampleCircuit()); err != nil {
+		t.Fatal(err)
+	}
+	want := `field 2147483647
+inputs 4 public 1
+outputs 1 zeroes 0
+layers 2
+layer 4 2 2
+mul 0 0 1 1
+mul 1 2 3 1
+layer 2 1 3
+add 0 0 3
+add 0 1 r
+cst 0 p0
+`
+	if buf.String() != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+}
+
+func TestWriteExpander(t *testing.T) {
+	rc := sampleCircuit()
+	var buf bytes.Buffer
+	if err := Write(&buf, "expander", rc); err != nil {
+		t.Fatal(err)
+	}
+	if layered.DeserializeRootCircuit(buf.Bytes()).ContentHash() != rc.ContentHash() {
+		t.Fatal("expected the native format")
+	}
+	if err := Write(&buf, "virgo", rc); err == nil || !strings.Contains(err.Error(), "registered formats: expander, json, text") {
+		t.Fatalf("expected an unknown format, got %v", err)
+	}
+}
+
+func TestWriteWitness(t *testing.T) {
+	rc := sampleCircuit()
+	// 3 witnesses of 3 inputs, padded to the 4 inputs of the first layer, and a public input
+	wit := &irwg.Witness{NumWitnesses: 3, NumInputsPerWitness: 3, NumPublicInputsPerWitness: 1, Field: m31.ScalarField}
+	for i := 0; i < 12; i++ {
+		wit.Values = append(wit.Values, big.NewInt(int64(i+1)))
+	}
+	var buf bytes.Buffer
+	if err := WriteWitness(&buf, rc, wit, WitnessOptions{Layout: WitnessGPU, Alignment: 64}); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	// the header, then 5 rows of 16 elements of 4 bytes
+	if len(b) != 64+5*64 || string(b[:8]) != "ECGOGPUW" || binary.LittleEndian.Uint64(b[24:]) != 16 || binary.LittleEndian.Uint64(b[56:]) != 64+4*64 {
+		t.Fatalf("unexpected header %x", b[:64])
+	}
+	elem := func(offset, wire, witness int) uint32 {
+		return binary.LittleEndian.Uint32(b[offset+(wire*16+witness)*4:])
+	}
+	if elem(64, 1, 2) != 10 || elem(64, 3, 2) != 0 || elem(64, 0, 3) != 0 || elem(64+4*64, 0, 1) != 8 {
+		t.Fatalf("unexpected matrices %x", b[64:])
+	}
+
+	buf.Reset()
+	if err := WriteWitness(&buf, rc, wit, WitnessOptions{}); err != nil || !bytes.Equal(buf.Bytes(), wit.Serialize()) {
+		t.Fatalf("expected the native layout, got %v", err)
+	}
+	if err := WriteWitness(&buf, rc, wit, WitnessOptions{Layout: WitnessGPU, Alignment: 100}); err == nil {
+		t.Fatal("expected an invalid alignment")
+	}
+	wit.NumInputsPerWitness = 5
+	if err := WriteWitness(&buf, rc, wit, WitnessOptions{}); err == nil {
+		t.Fatal("expected too many inputs")
+	}
+}
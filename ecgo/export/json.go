@@ -0,0 +1,110 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+// JSONVersion is the version of the json format, written in its version field. It's increased
+// on incompatible changes.
+const JSONVersion = 1
+
+// JSONCircuit is the document of the json format.
+type JSONCircuit struct {
+	Version int `json:"version"`
+	// Field is the modulus of the field, in decimal.
+	Field           string `json:"field"`
+	NumInputs       uint64 `json:"numInputs"`
+	NumPublicInputs int    `json:"numPublicInputs"`
+	// NumOutputs is the number of outputs of the last layer, the first NumOutputZeroes of which
+	// must be zero on a valid witness.
+	NumOutputs      int         `json:"numOutputs"`
+	NumOutputZeroes int         `json:"numOutputZeroes"`
+	Layers          []JSONLayer `json:"layers"`
+}
+
+// JSONLayer is a layer of the json format.
+type JSONLayer struct {
+	InputLen  uint64     `json:"inputLen"`
+	OutputLen uint64     `json:"outputLen"`
+	Gates     []JSONGate `json:"gates"`
+}
+
+// JSONGate is a gate of the json format, which adds its coefficient times the product of its
+// inputs to its output: the output of a mul gate is incremented by coef*in[0]*in[1], of an add
+// gate by coef*in[0], and of a cst gate by coef. Custom gates apply their gate type to their
+// inputs.
+type JSONGate struct {
+	Type string   `json:"type"`
+	In   []uint64 `json:"in,omitempty"`
+	Out  uint64   `json:"out"`
+	// CoefKind is "constant", with the coefficient in Coef, in decimal, "random", for a
+	// coefficient sampled by the verifier, or "public", for the public input PublicInput.
+	CoefKind    string `json:"coefKind"`
+	Coef        string `json:"coef,omitempty"`
+	PublicInput uint64 `json:"publicInput,omitempty"`
+	GateType    uint64 `json:"gateType,omitempty"`
+}
+
+// jsonBackend is the json format
+type jsonBackend struct{}
+
+func (jsonBackend) Name() string      { return "json" }
+func (jsonBackend) Extension() string { return ".json" }
+
+func (jsonBackend) Write(w io.Writer, rc *layered.RootCircuit) error {
+	res := &JSONCircuit{
+		Version:         JSONVersion,
+		Field:           rc.Field.String(),
+		NumInputs:       rc.Circuits[rc.Layers[0]].InputLen,
+		NumPublicInputs: rc.NumPublicInputs,
+		NumOutputs:      rc.NumActualOutputs,
+		NumOutputZeroes: rc.ExpectedNumOutputZeroes,
+	}
+	for i := range rc.Layers {
+		c := rc.FlatLayer(i)
+		l := JSONLayer{InputLen: c.InputLen, OutputLen: c.OutputLen}
+		gate := func(typ string, in []uint64, out uint64, coef *big.Int, coefType uint8, publicInputId uint64) error {
+			g := JSONGate{Type: typ, In: in, Out: out}
+			switch coefType {
+			case 1:
+				g.CoefKind, g.Coef = "constant", coef.String()
+			case 2:
+				g.CoefKind = "random"
+			case 3:
+				g.CoefKind, g.PublicInput = "public", publicInputId
+			default:
+				return fmt.Errorf("layer %d: unknown coefficient type %d", i, coefType)
+			}
+			l.Gates = append(l.Gates, g)
+			return nil
+		}
+		for _, g := range c.Mul {
+			if err := gate("mul", []uint64{g.In0, g.In1}, g.Out, g.Coef, g.CoefType, g.PublicInputId); err != nil {
+				return err
+			}
+		}
+		for _, g := range c.Add {
+			if err := gate("add", []uint64{g.In}, g.Out, g.Coef, g.CoefType, g.PublicInputId); err != nil {
+				return err
+			}
+		}
+		for _, g := range c.Cst {
+			if err := gate("cst", nil, g.Out, g.Coef, g.CoefType, g.PublicInputId); err != nil {
+				return err
+			}
+		}
+		for _, g := range c.Custom {
+			if err := gate("custom", g.In, g.Out, g.Coef, g.CoefType, g.PublicInputId); err != nil {
+				return err
+			}
+			l.Gates[len(l.Gates)-1].GateType = g.GateType
+		}
+		res.Layers = append(res.Layers, l)
+	}
+	return json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,77 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+// text is a line-oriented format in the style of the circuit files of Libra and Virgo:
+//
+//	field <modulus>
+//	inputs <number of inputs> public <number of public inputs>
+//	outputs <number of outputs> zeroes <number of outputs expected to be zero>
+//	layers <number of layers>
+//
+// followed, for each layer, by a line "layer <inputLen> <outputLen> <number of gates>" and one
+// line per gate:
+//
+//	mul <out> <in0> <in1> <coef>
+//	add <out> <in> <coef>
+//	cst <out> <coef>
+//	custom <gate type> <out> <coef> <in>...
+//
+// Coefficients are written in decimal, "r" for a coefficient sampled by the verifier, or "p<i>"
+// for public input i.
+type text struct{}
+
+func (text) Name() string      { return "text" }
+func (text) Extension() string { return ".circuit" }
+
+func (text) Write(w io.Writer, rc *layered.RootCircuit) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "field %s\n", rc.Field)
+	fmt.Fprintf(bw, "inputs %d public %d\n", rc.Circuits[rc.Layers[0]].InputLen, rc.NumPublicInputs)
+	fmt.Fprintf(bw, "outputs %d zeroes %d\n", rc.NumActualOutputs, rc.ExpectedNumOutputZeroes)
+	fmt.Fprintf(bw, "layers %d\n", len(rc.Layers))
+	for i := range rc.Layers {
+		c := rc.FlatLayer(i)
+		fmt.Fprintf(bw, "layer %d %d %d\n", c.InputLen, c.OutputLen, len(c.Mul)+len(c.Add)+len(c.Cst)+len(c.Custom))
+		var err error
+		coef := func(coef *big.Int, coefType uint8, publicInputId uint64) string {
+			switch coefType {
+			case 1:
+				return coef.String()
+			case 2:
+				return "r"
+			case 3:
+				return fmt.Sprintf("p%d", publicInputId)
+			}
+			err = fmt.Errorf("layer %d: unknown coefficient type %d", i, coefType)
+			return ""
+		}
+		for _, g := range c.Mul {
+			fmt.Fprintf(bw, "mul %d %d %d %s\n", g.Out, g.In0, g.In1, coef(g.Coef, g.CoefType, g.PublicInputId))
+		}
+		for _, g := range c.Add {
+			fmt.Fprintf(bw, "add %d %d %s\n", g.Out, g.In, coef(g.Coef, g.CoefType, g.PublicInputId))
+		}
+		for _, g := range c.Cst {
+			fmt.Fprintf(bw, "cst %d %s\n", g.Out, coef(g.Coef, g.CoefType, g.PublicInputId))
+		}
+		for _, g := range c.Custom {
+			fmt.Fprintf(bw, "custom %d %d %s", g.GateType, g.Out, coef(g.Coef, g.CoefType, g.PublicInputId))
+			for _, x := range g.In {
+				fmt.Fprintf(bw, " %d", x)
+			}
+			fmt.Fprintln(bw)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
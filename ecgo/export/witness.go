@@ -0,0 +1,168 @@
+package export
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+// WitnessLayout is the layout of the witnesses written by WriteWitness.
+type WitnessLayout int
+
+const (
+	// WitnessExpander is the native layout of Expander, see irwg.Witness.Serialize.
+	WitnessExpander WitnessLayout = iota
+	// WitnessGPU is a structure-of-arrays layout, aligned and padded for direct upload to GPU
+	// provers, see WriteWitness.
+	WitnessGPU
+)
+
+var witnessLayoutNames = map[string]WitnessLayout{"expander": WitnessExpander, "gpu": WitnessGPU}
+
+// ParseWitnessLayout returns the layout named name, expander or gpu.
+func ParseWitnessLayout(name string) (WitnessLayout, error) {
+	l, ok := witnessLayoutNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown witness layout %q, expected expander or gpu", name)
+	}
+	return l, nil
+}
+
+// DefaultWitnessAlignment is the alignment of the blocks of the gpu layout if none is given.
+const DefaultWitnessAlignment = 256
+
+// WitnessOptions select the layout of the witnesses written by WriteWitness.
+type WitnessOptions struct {
+	Layout WitnessLayout
+	// Alignment is the alignment in bytes of the rows of the gpu layout, a power of 2 of at
+	// least 64, or 0 for DefaultWitnessAlignment.
+	Alignment int
+}
+
+// gpuWitnessMagic starts the files of the gpu layout
+const gpuWitnessMagic = "ECGOGPUW"
+
+// GPUWitnessVersion is the version of the gpu layout, written in its header. It's increased on
+// incompatible changes.
+const GPUWitnessVersion = 1
+
+// gpuWitnessHeaderLen is the length of the header of the gpu layout
+const gpuWitnessHeaderLen = 64
+
+// WriteWitness writes the witnesses wit of the circuit rc to w in the layout of opts.
+//
+// The gpu layout holds the inputs of the first layer of rc and the public inputs, each as a
+// matrix with a row per wire and a column per witness, so that the values of a wire for all the
+// witnesses are contiguous, as read by provers evaluating the witnesses in parallel. All
+// integers are little-endian, and its memory map is:
+//
+//	offset  size  content
+//	0       8     the magic "ECGOGPUW"
+//	8       4     the version, GPUWitnessVersion
+//	12      4     E, the size of an element: the serialized length of the field, rounded up
+//	              to a multiple of 4
+//	16      8     N, the number of witnesses
+//	24      8     S, the stride of a row in elements: N rounded up so that S*E is a multiple
+//	              of the alignment
+//	32      8     I, the number of input wires, the input length of the first layer of rc
+//	40      8     P, the number of public inputs
+//	48      8     the offset of the input matrix, the alignment
+//	56      8     the offset of the public input matrix, the alignment plus I*S*E
+//
+// The header is padded with zeros to the alignment. Then the element of the wire j and the
+// witness k of a matrix is at its offset plus (j*S + k)*E, as a field element of E bytes. The
+// padding of the rows, k >= N, and the input wires beyond the inputs of the witnesses are zero,
+// so that every row and matrix is aligned and the input layer is padded to its length.
+func WriteWitness(w io.Writer, rc *layered.RootCircuit, wit *irwg.Witness, opts WitnessOptions) error {
+	if wit.Field.Cmp(rc.Field) != 0 {
+		return fmt.Errorf("the witness is over another field than the circuit")
+	}
+	inputLen := rc.Circuits[rc.Layers[0]].InputLen
+	if uint64(wit.NumInputsPerWitness) > inputLen || wit.NumPublicInputsPerWitness != rc.NumPublicInputs {
+		return fmt.Errorf("the witness has %d inputs and %d public inputs, the circuit %d and %d",
+			wit.NumInputsPerWitness, wit.NumPublicInputsPerWitness, inputLen, rc.NumPublicInputs)
+	}
+	switch opts.Layout {
+	case WitnessExpander:
+		_, err := w.Write(wit.Serialize())
+		return err
+	case WitnessGPU:
+		return writeGPUWitness(w, wit, inputLen, opts.Alignment)
+	}
+	return fmt.Errorf("unknown witness layout %d", opts.Layout)
+}
+
+func writeGPUWitness(w io.Writer, wit *irwg.Witness, inputLen uint64, align int) error {
+	if align == 0 {
+		align = DefaultWitnessAlignment
+	}
+	if align < gpuWitnessHeaderLen || align&(align-1) != 0 {
+		return fmt.Errorf("the alignment must be a power of 2 of at least %d, got %d", gpuWitnessHeaderLen, align)
+	}
+	serializedLen := field.GetFieldFromOrder(wit.Field).SerializedLen()
+	elemLen := (serializedLen + 3) / 4 * 4
+	// the rows are a multiple of lanes elements, whose size is a multiple of the alignment
+	lanes := align / gcd(align, elemLen)
+	n := wit.NumWitnesses
+	stride := (n + lanes - 1) / lanes * lanes
+	rowLen := uint64(stride * elemLen)
+	header := make([]byte, align)
+	copy(header, gpuWitnessMagic)
+	binary.LittleEndian.PutUint32(header[8:], GPUWitnessVersion)
+	binary.LittleEndian.PutUint32(header[12:], uint32(elemLen))
+	binary.LittleEndian.PutUint64(header[16:], uint64(n))
+	binary.LittleEndian.PutUint64(header[24:], uint64(stride))
+	binary.LittleEndian.PutUint64(header[32:], inputLen)
+	binary.LittleEndian.PutUint64(header[40:], uint64(wit.NumPublicInputsPerWitness))
+	binary.LittleEndian.PutUint64(header[48:], uint64(align))
+	binary.LittleEndian.PutUint64(header[56:], uint64(align)+inputLen*rowLen)
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+	perWitness := wit.NumInputsPerWitness + wit.NumPublicInputsPerWitness
+	row := make([]byte, rowLen)
+	writeRows := func(first, count, rows int) error {
+		for j := 0; j < rows; j++ {
+			clear(row)
+			if j < count {
+				for k := 0; k < n; k++ {
+					putElement(row[k*elemLen:(k+1)*elemLen], wit.Values[k*perWitness+first+j])
+				}
+			}
+			if _, err := bw.Write(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := writeRows(0, wit.NumInputsPerWitness, int(inputLen)); err != nil {
+		return err
+	}
+	if err := writeRows(wit.NumInputsPerWitness, wit.NumPublicInputsPerWitness, wit.NumPublicInputsPerWitness); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// putElement writes x to dst, little-endian
+func putElement(dst []byte, x *big.Int) {
+	x.FillBytes(dst)
+	for i, j := 0, len(dst)-1; i < j; i, j = i+1, j-1 {
+		dst[i], dst[j] = dst[j], dst[i]
+	}
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
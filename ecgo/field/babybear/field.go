@@ -0,0 +1,113 @@
+package babybear
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils"
+	"github.com/consensys/gnark/constraint"
+)
+
+// P is the BabyBear prime 2^31 - 2^27 + 1
+const P = 0x78000001
+
+// ExtensionDegree and ExtensionW define the extension F[x]/(x^4 - 11) in which random
+// challenges are sampled, a single base field element being too small for them to be sound
+const (
+	ExtensionDegree = 4
+	ExtensionW      = 11
+)
+
+var Pbig = big.NewInt(P)
+var ScalarField = Pbig
+
+type Field struct{}
+
+func (engine *Field) FromInterface(i interface{}) constraint.Element {
+	b := utils.FromInterface(i)
+	b.Mod(&b, Pbig)
+	return constraint.Element{b.Uint64()}
+}
+
+func (engine *Field) ToBigInt(c constraint.Element) *big.Int {
+	return big.NewInt(int64(c[0]))
+}
+
+func (engine *Field) Mul(a, b constraint.Element) constraint.Element {
+	return constraint.Element{a[0] * b[0] % P}
+}
+
+func (engine *Field) Add(a, b constraint.Element) constraint.Element {
+	res := a[0] + b[0]
+	if res >= P {
+		res -= P
+	}
+	return constraint.Element{res}
+}
+
+func (engine *Field) Sub(a, b constraint.Element) constraint.Element {
+	res := int64(a[0]) - int64(b[0])
+	if res < 0 {
+		res += P
+	}
+	return constraint.Element{uint64(res)}
+}
+
+func (engine *Field) Neg(a constraint.Element) constraint.Element {
+	if a[0] == 0 {
+		return a
+	}
+	return constraint.Element{P - a[0]}
+}
+
+func (engine *Field) Inverse(a constraint.Element) (constraint.Element, bool) {
+	if a[0] == 0 {
+		return a, false
+	}
+	var res uint64 = 1
+	b := a[0]
+	for i := P - 2; i > 0; i >>= 1 {
+		if (i & 1) != 0 {
+			res = res * b % P
+		}
+		b = b * b % P
+	}
+	return constraint.Element{res}, true
+}
+
+func (engine *Field) IsOne(a constraint.Element) bool {
+	return a[0] == 1
+}
+
+func (engine *Field) One() constraint.Element {
+	return constraint.Element{1}
+}
+
+func (engine *Field) Zero() constraint.Element {
+	return constraint.Element{0}
+}
+
+func (engine *Field) String(a constraint.Element) string {
+	return strconv.Itoa(int(a[0]))
+}
+
+func (engine *Field) Uint64(a constraint.Element) (uint64, bool) {
+	return a[0], true
+}
+
+func (engine *Field) Field() *big.Int {
+	return ScalarField
+}
+
+func (engine *Field) FieldBitLen() int {
+	return 31
+}
+
+func (engine *Field) SerializedLen() int {
+	return 4
+}
+
+// ChallengeExtension implements field.Extension.
+func (engine *Field) ChallengeExtension() (int, uint64) {
+	return ExtensionDegree, ExtensionW
+}
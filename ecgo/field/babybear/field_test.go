@@ -0,0 +1,30 @@
+package babybear
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/fieldtest"
+)
+
+func TestFromInterfaceReduction(t *testing.T) {
+	fieldtest.CheckFromInterface(t, &Field{})
+	f := &Field{}
+	cases := []struct {
+		in  interface{}
+		out uint64
+	}{
+		{P, 0},
+		{P + 5, 5},
+		{int64(-P), 0},
+	}
+	for _, c := range cases {
+		e := f.FromInterface(c.in)
+		if e[0] != c.out {
+			t.Fatalf("FromInterface(%v) = %d, expected %d", c.in, e[0], c.out)
+		}
+	}
+}
+
+func TestArithmeticMatchesBigInt(t *testing.T) {
+	fieldtest.CheckArithmetic(t, &Field{}, fieldtest.Uint64s(0, 1, 2, P-1, P-2, 1<<27, 1<<30), 1000, 27)
+}
@@ -0,0 +1,47 @@
+package bls12381_test
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/bls12381"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/fieldtest"
+	"github.com/consensys/gnark/frontend"
+)
+
+func TestArithmeticMatchesBigInt(t *testing.T) {
+	p := bls12381.ScalarField
+	samples := []*big.Int{big.NewInt(0), big.NewInt(1), new(big.Int).Sub(p, big.NewInt(3)), new(big.Int).Lsh(big.NewInt(1), 200)}
+	fieldtest.CheckArithmetic(t, &bls12381.Field{}, samples, 50, 381)
+}
+
+func TestFromInterfaceReduction(t *testing.T) {
+	fieldtest.CheckFromInterface(t, &bls12381.Field{})
+}
+
+type cubeCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *cubeCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.X, c.X), api.Div(c.Y, 2))
+	return nil
+}
+
+func TestSolveAndCompile(t *testing.T) {
+	p := bls12381.ScalarField
+	if err := ecgo.CheckWitness(p, &cubeCircuit{}, &cubeCircuit{X: 3, Y: 54}); err != nil {
+		t.Fatal(err)
+	}
+	var unsatisfied *ecgo.UnsatisfiedConstraintError
+	if err := ecgo.CheckWitness(p, &cubeCircuit{}, &cubeCircuit{X: 3, Y: 55}); !errors.As(err, &unsatisfied) {
+		t.Fatalf("expected an unsatisfied constraint, got %v", err)
+	}
+	if _, err := ecgo.Compile(p, &cubeCircuit{}); !errors.Is(err, field.ErrUnsupportedByCompiler) {
+		t.Fatalf("expected the field to be rejected by the compiler, got %v", err)
+	}
+}
@@ -104,6 +104,10 @@ func (engine *Field) One() constraint.Element {
 	return r
 }
 
+func (engine *Field) Zero() constraint.Element {
+	return constraint.Element{}
+}
+
 func (engine *Field) String(a constraint.Element) string {
 	e := (*fr.Element)(a[:])
 	return e.String()
@@ -1,22 +1,78 @@
 package field
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/babybear"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/bls12381"
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/bn254"
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/gf2"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/goldilocks"
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
 	"github.com/consensys/gnark/constraint"
 )
 
+// Field is the coefficient engine used by the builder and the witness solver.
+// Besides the arithmetic from constraint.Field, it exposes the modulus and
+// the serialization width, so that supporting a new field only requires a new
+// implementation registered below.
 type Field interface {
 	constraint.Field
+	Zero() constraint.Element
 	Field() *big.Int
 	FieldBitLen() int
 	SerializedLen() int
 }
 
+// Extension is implemented by fields too small for a random element to be a sound challenge.
+// ChallengeExtension returns the degree d and the constant w of the extension F[x]/(x^d - w) in
+// which the builder samples the challenges of its own arguments, like lookups. GF2 doesn't
+// implement it: x^d - w is reducible over GF2 for d > 1, so its extensions aren't of this form.
+type Extension interface {
+	ChallengeExtension() (degree int, w uint64)
+}
+
+// ChallengeExtension returns the extension of f in which challenges are sampled, of degree 1 if
+// f doesn't implement Extension.
+func ChallengeExtension(f Field) (int, uint64) {
+	if e, ok := f.(Extension); ok {
+		return e.ChallengeExtension()
+	}
+	return 1, 0
+}
+
+// ErrUnsupportedField is the error the functions below panic with when a field isn't supported.
+var ErrUnsupportedField = errors.New("unsupported field")
+
+// ErrUnsupportedByCompiler is wrapped by the error of CheckCompilable.
+var ErrUnsupportedByCompiler = errors.New("not supported by the compiler")
+
+// uncompiledFields are the fields supported by the builder and the witness solver, for which the
+// Rust compiler and Expander have no config yet, so that circuits over them can't be layered.
+// Their ids in GetFieldId are reserved for these configs, and unknown to the Rust library.
+var uncompiledFields = []struct {
+	order *big.Int
+	name  string
+}{
+	{bls12381.ScalarField, "BLS12-381"},
+	{goldilocks.ScalarField, "Goldilocks"},
+	{babybear.ScalarField, "BabyBear"},
+}
+
+// CheckCompilable returns an error wrapping ErrUnsupportedByCompiler if circuits over the field
+// of order x can't be compiled to layered circuits, see uncompiledFields. They can still be
+// defined and checked, e.g. with ecgo.CheckWitness.
+func CheckCompilable(x *big.Int) error {
+	for _, f := range uncompiledFields {
+		if x.Cmp(f.order) == 0 {
+			return fmt.Errorf("field %s: %w", f.name, ErrUnsupportedByCompiler)
+		}
+	}
+	return nil
+}
+
 func GetFieldFromOrder(x *big.Int) Field {
 	if x.Cmp(bn254.ScalarField) == 0 {
 		return &bn254.Field{}
@@ -27,7 +83,16 @@ func GetFieldFromOrder(x *big.Int) Field {
 	if x.Cmp(gf2.ScalarField) == 0 {
 		return &gf2.Field{}
 	}
-	panic(fmt.Sprintf("unknown field %v", x))
+	if x.Cmp(bls12381.ScalarField) == 0 {
+		return &bls12381.Field{}
+	}
+	if x.Cmp(goldilocks.ScalarField) == 0 {
+		return &goldilocks.Field{}
+	}
+	if x.Cmp(babybear.ScalarField) == 0 {
+		return &babybear.Field{}
+	}
+	panic(fmt.Errorf("%w %v", ErrUnsupportedField, x))
 }
 
 func GetFieldId(f Field) uint64 {
@@ -40,7 +105,16 @@ func GetFieldId(f Field) uint64 {
 	if f.Field().Cmp(gf2.ScalarField) == 0 {
 		return 3
 	}
-	panic(fmt.Sprintf("unsupported field %v", f))
+	if f.Field().Cmp(bls12381.ScalarField) == 0 {
+		return 4
+	}
+	if f.Field().Cmp(goldilocks.ScalarField) == 0 {
+		return 5
+	}
+	if f.Field().Cmp(babybear.ScalarField) == 0 {
+		return 6
+	}
+	panic(fmt.Errorf("%w %v", ErrUnsupportedField, f.Field()))
 }
 
 func GetFieldById(id uint64) Field {
@@ -51,6 +125,12 @@ func GetFieldById(id uint64) Field {
 		return &bn254.Field{}
 	case 3:
 		return &gf2.Field{}
+	case 4:
+		return &bls12381.Field{}
+	case 5:
+		return &goldilocks.Field{}
+	case 6:
+		return &babybear.Field{}
 	}
-	panic(fmt.Sprintf("unsupported field id %v", id))
+	panic(fmt.Errorf("%w id %v", ErrUnsupportedField, id))
 }
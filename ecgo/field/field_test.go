@@ -0,0 +1,28 @@
+package field
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/babybear"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/bls12381"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/bn254"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/gf2"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/goldilocks"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+)
+
+func TestCheckCompilable(t *testing.T) {
+	for _, x := range []*big.Int{m31.ScalarField, bn254.ScalarField, gf2.ScalarField} {
+		if err := CheckCompilable(x); err != nil {
+			t.Fatalf("expected field %v to be compilable, got %v", x, err)
+		}
+	}
+	for x, name := range map[*big.Int]string{bls12381.ScalarField: "BLS12-381", goldilocks.ScalarField: "Goldilocks", babybear.ScalarField: "BabyBear"} {
+		err := CheckCompilable(x)
+		if !errors.Is(err, ErrUnsupportedByCompiler) || err.Error() != "field "+name+": not supported by the compiler" {
+			t.Fatalf("expected field %s to be rejected, got %v", name, err)
+		}
+	}
+}
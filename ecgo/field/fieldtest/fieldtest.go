@@ -0,0 +1,102 @@
+// Package fieldtest checks the implementations of the fields against the arithmetic of big.Int,
+// for the tests of each field package. The field specific edge cases stay in their package.
+package fieldtest
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/consensys/gnark/constraint"
+)
+
+// Field is the arithmetic checked by this package. It's the part of field.Field the checks use,
+// declared here so that the field packages can use it without importing the field package.
+type Field interface {
+	constraint.Field
+	Field() *big.Int
+}
+
+// CheckArithmetic checks Add, Sub, Mul, Neg and Inverse on every pair of the samples, meant for
+// the edge cases of the field, and on consecutive pairs of n random elements drawn from seed.
+// Each result must be the canonical element of the value computed with big.Int, i.e. equal to
+// FromInterface of its reduction.
+func CheckArithmetic(t *testing.T, f Field, samples []*big.Int, n int, seed int64) {
+	t.Helper()
+	p := f.Field()
+	check := func(op string, got constraint.Element, want *big.Int) {
+		t.Helper()
+		want.Mod(want, p)
+		if got != f.FromInterface(want) {
+			t.Fatalf("%s: got %s, expected %s", op, f.String(got), want.String())
+		}
+	}
+	checkPair := func(ab, bb *big.Int) {
+		t.Helper()
+		a, b := f.FromInterface(ab), f.FromInterface(bb)
+		check("add", f.Add(a, b), new(big.Int).Add(ab, bb))
+		check("sub", f.Sub(a, b), new(big.Int).Sub(ab, bb))
+		check("mul", f.Mul(a, b), new(big.Int).Mul(ab, bb))
+		check("neg", f.Neg(a), new(big.Int).Neg(ab))
+		if ab.Sign() != 0 {
+			inv, ok := f.Inverse(a)
+			if !ok {
+				t.Fatalf("inverse of %s should exist", ab.String())
+			}
+			check("inv", f.Mul(inv, a), big.NewInt(1))
+		}
+	}
+	for _, a := range samples {
+		for _, b := range samples {
+			checkPair(a, b)
+		}
+	}
+	r := rand.New(rand.NewSource(seed))
+	prev := new(big.Int).Rand(r, p)
+	for i := 0; i < n; i++ {
+		next := new(big.Int).Rand(r, p)
+		checkPair(prev, next)
+		prev = next
+	}
+	if _, ok := f.Inverse(f.FromInterface(0)); ok {
+		t.Fatal("inverse of zero should not exist")
+	}
+}
+
+// CheckFromInterface checks that FromInterface reduces the values of each type it accepts
+// modulo the order of the field.
+func CheckFromInterface(t *testing.T, f Field) {
+	t.Helper()
+	p := f.Field()
+	big2 := func(e uint) *big.Int { return new(big.Int).Lsh(big.NewInt(1), e) }
+	for _, c := range []struct {
+		in   interface{}
+		want *big.Int
+	}{
+		{0, big.NewInt(0)},
+		{1, big.NewInt(1)},
+		{-1, big.NewInt(-1)},
+		{int64(-2), big.NewInt(-2)},
+		{uint64(1) << 63, big2(63)},
+		{uint64(1<<64 - 1), new(big.Int).SetUint64(1<<64 - 1)},
+		{new(big.Int).Set(p), big.NewInt(0)},
+		{new(big.Int).Add(p, big.NewInt(5)), big.NewInt(5)},
+		{big2(300), big2(300)},
+		{"0x" + p.Text(16), big.NewInt(0)},
+		{new(big.Int).Add(p, big.NewInt(7)).String(), big.NewInt(7)},
+	} {
+		want := new(big.Int).Mod(c.want, p)
+		if got := f.FromInterface(c.in); f.ToBigInt(got).Cmp(want) != 0 {
+			t.Fatalf("FromInterface(%v) = %s, expected %s", c.in, f.String(got), want.String())
+		}
+	}
+}
+
+// Uint64s returns the samples as big.Int, for CheckArithmetic.
+func Uint64s(samples ...uint64) []*big.Int {
+	res := make([]*big.Int, len(samples))
+	for i, x := range samples {
+		res[i] = new(big.Int).SetUint64(x)
+	}
+	return res
+}
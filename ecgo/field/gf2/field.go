@@ -68,6 +68,10 @@ func (engine *Field) One() constraint.Element {
 	return constraint.Element{1}
 }
 
+func (engine *Field) Zero() constraint.Element {
+	return constraint.Element{0}
+}
+
 func (engine *Field) String(a constraint.Element) string {
 	return strconv.Itoa(int(a[0]))
 }
@@ -0,0 +1,129 @@
+package goldilocks
+
+import (
+	"math/big"
+	"math/bits"
+	"strconv"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils"
+	"github.com/consensys/gnark/constraint"
+)
+
+// P is the Goldilocks prime 2^64 - 2^32 + 1
+const P = 0xffffffff00000001
+
+// epsilon is 2^64 mod P
+const epsilon = 0xffffffff
+
+var Pbig = new(big.Int).SetUint64(P)
+var ScalarField = Pbig
+
+type Field struct{}
+
+// modReduce reduces hi*2^64 + lo, using 2^64 = 2^32 - 1 and 2^96 = -1 mod P
+func modReduce(hi, lo uint64) uint64 {
+	hiHi, hiLo := hi>>32, hi&epsilon
+	t0, borrow := bits.Sub64(lo, hiHi, 0)
+	if borrow != 0 {
+		t0 -= epsilon
+	}
+	t1 := hiLo * epsilon
+	t2, carry := bits.Add64(t0, t1, 0)
+	if carry != 0 {
+		t2 += epsilon
+	}
+	if t2 >= P {
+		t2 -= P
+	}
+	return t2
+}
+
+func mul(a, b uint64) uint64 {
+	return modReduce(bits.Mul64(a, b))
+}
+
+func (engine *Field) FromInterface(i interface{}) constraint.Element {
+	b := utils.FromInterface(i)
+	b.Mod(&b, Pbig)
+	return constraint.Element{b.Uint64()}
+}
+
+func (engine *Field) ToBigInt(c constraint.Element) *big.Int {
+	return new(big.Int).SetUint64(c[0])
+}
+
+func (engine *Field) Mul(a, b constraint.Element) constraint.Element {
+	return constraint.Element{mul(a[0], b[0])}
+}
+
+func (engine *Field) Add(a, b constraint.Element) constraint.Element {
+	res, carry := bits.Add64(a[0], b[0], 0)
+	if carry != 0 {
+		res += epsilon
+	} else if res >= P {
+		res -= P
+	}
+	return constraint.Element{res}
+}
+
+func (engine *Field) Sub(a, b constraint.Element) constraint.Element {
+	res, borrow := bits.Sub64(a[0], b[0], 0)
+	if borrow != 0 {
+		res -= epsilon
+	}
+	return constraint.Element{res}
+}
+
+func (engine *Field) Neg(a constraint.Element) constraint.Element {
+	if a[0] == 0 {
+		return a
+	}
+	return constraint.Element{P - a[0]}
+}
+
+func (engine *Field) Inverse(a constraint.Element) (constraint.Element, bool) {
+	if a[0] == 0 {
+		return a, false
+	}
+	var res uint64 = 1
+	b := a[0]
+	for i := uint64(P - 2); i > 0; i >>= 1 {
+		if (i & 1) != 0 {
+			res = mul(res, b)
+		}
+		b = mul(b, b)
+	}
+	return constraint.Element{res}, true
+}
+
+func (engine *Field) IsOne(a constraint.Element) bool {
+	return a[0] == 1
+}
+
+func (engine *Field) One() constraint.Element {
+	return constraint.Element{1}
+}
+
+func (engine *Field) Zero() constraint.Element {
+	return constraint.Element{0}
+}
+
+func (engine *Field) String(a constraint.Element) string {
+	return strconv.FormatUint(a[0], 10)
+}
+
+func (engine *Field) Uint64(a constraint.Element) (uint64, bool) {
+	return a[0], true
+}
+
+func (engine *Field) Field() *big.Int {
+	return ScalarField
+}
+
+func (engine *Field) FieldBitLen() int {
+	return 64
+}
+
+func (engine *Field) SerializedLen() int {
+	return 8
+}
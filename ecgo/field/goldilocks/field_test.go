@@ -0,0 +1,32 @@
+package goldilocks
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/fieldtest"
+)
+
+func TestFromInterfaceReduction(t *testing.T) {
+	fieldtest.CheckFromInterface(t, &Field{})
+	f := &Field{}
+	cases := []struct {
+		in  interface{}
+		out uint64
+	}{
+		{uint64(P), 0},
+		{uint64(P) + 5, 5},
+		{uint64(1<<64 - 1), epsilon - 1},
+		{new(big.Int).Lsh(big.NewInt(1), 96), P - 1},
+	}
+	for _, c := range cases {
+		e := f.FromInterface(c.in)
+		if e[0] != c.out {
+			t.Fatalf("FromInterface(%v) = %d, expected %d", c.in, e[0], c.out)
+		}
+	}
+}
+
+func TestArithmeticMatchesBigInt(t *testing.T) {
+	fieldtest.CheckArithmetic(t, &Field{}, fieldtest.Uint64s(0, 1, 2, P-1, P-2, epsilon, epsilon+1, 1<<32, 1<<63, P-epsilon), 1000, 64)
+}
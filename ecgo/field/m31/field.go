@@ -10,6 +10,14 @@ import (
 
 const P = 0x7fffffff
 
+// ExtensionDegree and ExtensionW define the cubic extension F[x]/(x^3 - 5) in which random
+// challenges are sampled, the one of Expander's M31 config, a single base field element being too
+// small for them to be sound
+const (
+	ExtensionDegree = 3
+	ExtensionW      = 5
+)
+
 var Pbig = big.NewInt(P)
 var ScalarField = Pbig
 
@@ -80,6 +88,10 @@ func (engine *Field) One() constraint.Element {
 	return constraint.Element{1}
 }
 
+func (engine *Field) Zero() constraint.Element {
+	return constraint.Element{0}
+}
+
 func (engine *Field) String(a constraint.Element) string {
 	return strconv.Itoa(int(a[0]))
 }
@@ -99,3 +111,8 @@ func (engine *Field) FieldBitLen() int {
 func (engine *Field) SerializedLen() int {
 	return 4
 }
+
+// ChallengeExtension implements field.Extension.
+func (engine *Field) ChallengeExtension() (int, uint64) {
+	return ExtensionDegree, ExtensionW
+}
@@ -0,0 +1,123 @@
+package fuzz
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"strings"
+
+	"github.com/consensys/gnark/frontend"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/test"
+)
+
+// Failure is the error returned by Check when the compiled circuit disagrees with the direct
+// evaluation of the DAG.
+type Failure struct {
+	// Stage is the step that failed: "compile", "engine", "solve" or "outputs".
+	Stage          string
+	DAG            *DAG
+	Inputs, Public []*big.Int
+	// Want are the outputs of DAG.Eval, nil when it divides by zero.
+	Want []*big.Int
+	// Got are the outputs of the layered circuit or of the test engine, if any.
+	Got []*big.Int
+	Err error
+}
+
+func (f *Failure) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "fuzz: %s", f.Stage)
+	if f.Err != nil {
+		fmt.Fprintf(&sb, ": %v", f.Err)
+	}
+	if f.Inputs != nil {
+		fmt.Fprintf(&sb, "\ninputs: %v\npublic: %v\nwant: %v\ngot: %v", f.Inputs, f.Public, f.Want, f.Got)
+	}
+	fmt.Fprintf(&sb, "\n%s", f.DAG)
+	return sb.String()
+}
+
+func (f *Failure) Unwrap() error {
+	return f.Err
+}
+
+// Check compiles the circuit of d with the given options, then for each of trials random inputs
+// drawn from r, evaluates it with the test engine and solves its witness, and compares their
+// outputs, and the ones of the layered circuit, with DAG.Eval. When Eval divides by zero, the
+// engine and the solver must fail too. It returns a *Failure describing the first disagreement.
+func Check(field *big.Int, d *DAG, r *rand.Rand, trials int, opts ...frontend.CompileOption) error {
+	res, err := compile(field, d, opts)
+	if err != nil {
+		return &Failure{Stage: "compile", DAG: d, Err: err}
+	}
+	solver := res.GetInputSolver()
+	for t := 0; t < trials; t++ {
+		f := &Failure{DAG: d, Inputs: make([]*big.Int, d.NumInputs), Public: make([]*big.Int, d.NumPublic)}
+		for i := range f.Inputs {
+			f.Inputs[i] = RandomValue(r, field)
+		}
+		for i := range f.Public {
+			f.Public[i] = RandomValue(r, field)
+		}
+		want, evalErr := d.Eval(field, f.Inputs, f.Public)
+		f.Want = want
+		assignment := d.Assignment(f.Inputs, f.Public)
+
+		e := test.NewEngine(field)
+		f.Err = e.Run(d.Circuit(), assignment)
+		f.Got = e.Outputs()
+		if (f.Err != nil) != (evalErr != nil) || (evalErr == nil && !equal(f.Got, want)) {
+			f.Stage = "engine"
+			return f
+		}
+
+		w, err := solver.SolveInputAuto(assignment)
+		f.Got, f.Err = nil, err
+		if evalErr != nil {
+			if err == nil {
+				f.Stage = "solve"
+				f.Err = fmt.Errorf("expected the solver to fail with %v", evalErr)
+				return f
+			}
+			continue
+		}
+		if err != nil {
+			f.Stage = "solve"
+			return f
+		}
+		outputs, err := res.Outputs(w)
+		if err != nil || !equal(outputs[0], want) {
+			f.Stage = "outputs"
+			f.Err = err
+			if err == nil {
+				f.Got = outputs[0]
+			}
+			return f
+		}
+	}
+	return nil
+}
+
+// compile compiles the circuit of d, turning panics into errors
+func compile(field *big.Int, d *DAG, opts []frontend.CompileOption) (res *ecgo.CompileResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return ecgo.Compile(field, d.Circuit(), opts...)
+}
+
+func equal(a, b []*big.Int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Cmp(b[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,296 @@
+// Package fuzz generates random arithmetic circuits and cross-checks their compilation, so that
+// miscompilations are caught automatically. A circuit is a random expression DAG over the secret
+// and public inputs; Check compiles it, solves witnesses of random inputs, and compares the
+// outputs of the layered circuit with the ones computed directly on big integers:
+//
+//	go test -run '^$' -fuzz FuzzCompile ./ecgo/fuzz
+//
+// Failing seeds are kept by the Go fuzzing engine in testdata/fuzz, and the error of Check prints
+// the DAG and the inputs, so that a failure can be reduced to a regression test.
+package fuzz
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"strings"
+
+	"github.com/consensys/gnark/frontend"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo"
+)
+
+// Op is the operation of a node of a DAG.
+type Op int
+
+const (
+	OpInput Op = iota
+	OpPublic
+	OpConst
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpNeg
+	numOps
+)
+
+var opNames = [numOps]string{"input", "public", "const", "add", "sub", "mul", "div", "neg"}
+
+func (op Op) String() string {
+	if op < 0 || op >= numOps {
+		return fmt.Sprintf("op(%d)", int(op))
+	}
+	return opNames[op]
+}
+
+// Node is a node of a DAG. Its operands are earlier nodes.
+type Node struct {
+	Op Op
+	// X and Y are the operands of the arithmetic operations, Y is unused by OpNeg.
+	X, Y int
+	// Index is the index of the input of OpInput and OpPublic.
+	Index int
+	// Value is the value of OpConst.
+	Value *big.Int
+}
+
+// DAG is a random expression DAG, whose outputs are given to API.Output by its circuit.
+type DAG struct {
+	NumInputs, NumPublic int
+	Nodes                []Node
+	Outputs              []int
+}
+
+// Config is the shape of the generated DAGs.
+type Config struct {
+	NumInputs, NumPublic int
+	// NumNodes is the number of operation nodes, after the nodes of the inputs.
+	NumNodes   int
+	NumOutputs int
+	// Weights are the relative frequencies of the operations, indexed by Op. OpInput and OpPublic
+	// are ignored, since every input has a node.
+	Weights [numOps]int
+}
+
+// DefaultConfig generates DAGs of a few dozen nodes, mostly additions and multiplications.
+var DefaultConfig = Config{
+	NumInputs:  4,
+	NumPublic:  2,
+	NumNodes:   40,
+	NumOutputs: 4,
+	Weights: [numOps]int{
+		OpConst: 1,
+		OpAdd:   4,
+		OpSub:   2,
+		OpMul:   4,
+		OpDiv:   1,
+		OpNeg:   1,
+	},
+}
+
+// Generate returns a random DAG of the given shape over field. The same source yields the same
+// DAG. The denominators of divisions depend on the inputs, so that they aren't folded by the
+// builder; a division by zero is found when solving the witness.
+func Generate(r *rand.Rand, field *big.Int, cfg Config) *DAG {
+	total := 0
+	for op := OpConst; op < numOps; op++ {
+		total += cfg.Weights[op]
+	}
+	if total == 0 || cfg.NumInputs+cfg.NumPublic == 0 {
+		panic("fuzz: the config generates no operation")
+	}
+	d := &DAG{NumInputs: cfg.NumInputs, NumPublic: cfg.NumPublic}
+	// dynamic[i] is set when node i depends on an input
+	var dynamic []bool
+	for i := 0; i < cfg.NumInputs; i++ {
+		d.Nodes = append(d.Nodes, Node{Op: OpInput, Index: i})
+		dynamic = append(dynamic, true)
+	}
+	for i := 0; i < cfg.NumPublic; i++ {
+		d.Nodes = append(d.Nodes, Node{Op: OpPublic, Index: i})
+		dynamic = append(dynamic, true)
+	}
+	for len(d.Nodes) < cfg.NumInputs+cfg.NumPublic+cfg.NumNodes {
+		op := pickOp(r, &cfg.Weights, total)
+		n := Node{Op: op, X: r.Intn(len(d.Nodes)), Y: r.Intn(len(d.Nodes))}
+		switch op {
+		case OpConst:
+			n = Node{Op: op, Value: RandomValue(r, field)}
+		case OpNeg:
+			n.Y = 0
+		case OpDiv:
+			for !dynamic[n.Y] {
+				n.Y = r.Intn(len(d.Nodes))
+			}
+		}
+		d.Nodes = append(d.Nodes, n)
+		dynamic = append(dynamic, op != OpConst && (dynamic[n.X] || (op != OpNeg && dynamic[n.Y])))
+	}
+	// the last nodes are the deepest ones, the other outputs are picked anywhere
+	for i := 0; i < cfg.NumOutputs; i++ {
+		if i%2 == 0 && i/2 < len(d.Nodes) {
+			d.Outputs = append(d.Outputs, len(d.Nodes)-1-i/2)
+		} else {
+			d.Outputs = append(d.Outputs, r.Intn(len(d.Nodes)))
+		}
+	}
+	return d
+}
+
+func pickOp(r *rand.Rand, weights *[numOps]int, total int) Op {
+	x := r.Intn(total)
+	for op := OpConst; op < numOps; op++ {
+		if x < weights[op] {
+			return op
+		}
+		x -= weights[op]
+	}
+	panic("unreachable")
+}
+
+// RandomValue returns a random element of the field, biased towards the edge cases: 0, 1, -1
+// and small values.
+func RandomValue(r *rand.Rand, field *big.Int) *big.Int {
+	switch r.Intn(6) {
+	case 0:
+		return big.NewInt(0)
+	case 1:
+		return big.NewInt(1)
+	case 2:
+		return new(big.Int).Sub(field, big.NewInt(1))
+	case 3:
+		return big.NewInt(r.Int63n(256))
+	default:
+		return new(big.Int).Rand(r, field)
+	}
+}
+
+// ErrDivisionByZero is returned by Eval when a denominator is zero, in which case the witness
+// can't be solved.
+var ErrDivisionByZero = errors.New("division by zero")
+
+// Eval evaluates the DAG over field on big integers, and returns the values of its outputs.
+func (d *DAG) Eval(field *big.Int, inputs, public []*big.Int) ([]*big.Int, error) {
+	if len(inputs) != d.NumInputs || len(public) != d.NumPublic {
+		return nil, fmt.Errorf("expected %d inputs and %d public inputs, got %d and %d", d.NumInputs, d.NumPublic, len(inputs), len(public))
+	}
+	v := make([]*big.Int, len(d.Nodes))
+	for i, n := range d.Nodes {
+		res := new(big.Int)
+		switch n.Op {
+		case OpInput:
+			res.Set(inputs[n.Index])
+		case OpPublic:
+			res.Set(public[n.Index])
+		case OpConst:
+			res.Set(n.Value)
+		case OpAdd:
+			res.Add(v[n.X], v[n.Y])
+		case OpSub:
+			res.Sub(v[n.X], v[n.Y])
+		case OpMul:
+			res.Mul(v[n.X], v[n.Y])
+		case OpDiv:
+			if res.ModInverse(v[n.Y], field) == nil {
+				return nil, fmt.Errorf("node %d: %w", i, ErrDivisionByZero)
+			}
+			res.Mul(res, v[n.X])
+		case OpNeg:
+			res.Neg(v[n.X])
+		default:
+			return nil, fmt.Errorf("node %d: unknown operation %s", i, n.Op)
+		}
+		v[i] = res.Mod(res, field)
+	}
+	res := make([]*big.Int, len(d.Outputs))
+	for i, o := range d.Outputs {
+		res[i] = v[o]
+	}
+	return res, nil
+}
+
+// String returns the nodes of the DAG one per line, e.g. "v7 = mul v3 v5".
+func (d *DAG) String() string {
+	var sb strings.Builder
+	for i, n := range d.Nodes {
+		fmt.Fprintf(&sb, "v%d = %s", i, n.Op)
+		switch n.Op {
+		case OpInput, OpPublic:
+			fmt.Fprintf(&sb, " %d", n.Index)
+		case OpConst:
+			fmt.Fprintf(&sb, " %s", n.Value)
+		case OpNeg:
+			fmt.Fprintf(&sb, " v%d", n.X)
+		default:
+			fmt.Fprintf(&sb, " v%d v%d", n.X, n.Y)
+		}
+		sb.WriteByte('\n')
+	}
+	sb.WriteString("outputs:")
+	for _, o := range d.Outputs {
+		fmt.Fprintf(&sb, " v%d", o)
+	}
+	return sb.String()
+}
+
+// Circuit is the circuit of a DAG, see DAG.Circuit.
+type Circuit struct {
+	Inputs []frontend.Variable
+	Public []frontend.Variable `gnark:",public"`
+
+	dag *DAG
+}
+
+// Circuit returns the empty circuit of the DAG, to be compiled.
+func (d *DAG) Circuit() *Circuit {
+	return &Circuit{
+		Inputs: make([]frontend.Variable, d.NumInputs),
+		Public: make([]frontend.Variable, d.NumPublic),
+		dag:    d,
+	}
+}
+
+// Assignment returns the assignment of the circuit of the DAG for the given inputs.
+func (d *DAG) Assignment(inputs, public []*big.Int) *Circuit {
+	c := d.Circuit()
+	for i, x := range inputs {
+		c.Inputs[i] = x
+	}
+	for i, x := range public {
+		c.Public[i] = x
+	}
+	return c
+}
+
+// Define computes the nodes of the DAG with api, and gives its outputs to API.Output.
+func (c *Circuit) Define(api frontend.API) error {
+	v := make([]frontend.Variable, len(c.dag.Nodes))
+	for i, n := range c.dag.Nodes {
+		switch n.Op {
+		case OpInput:
+			v[i] = c.Inputs[n.Index]
+		case OpPublic:
+			v[i] = c.Public[n.Index]
+		case OpConst:
+			v[i] = n.Value
+		case OpAdd:
+			v[i] = api.Add(v[n.X], v[n.Y])
+		case OpSub:
+			v[i] = api.Sub(v[n.X], v[n.Y])
+		case OpMul:
+			v[i] = api.Mul(v[n.X], v[n.Y])
+		case OpDiv:
+			v[i] = api.Div(v[n.X], v[n.Y])
+		case OpNeg:
+			v[i] = api.Neg(v[n.X])
+		default:
+			return fmt.Errorf("node %d: unknown operation %s", i, n.Op)
+		}
+	}
+	for _, o := range c.dag.Outputs {
+		api.(ecgo.API).Output(v[o])
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package fuzz
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/test"
+)
+
+var fields = []*big.Int{m31.ScalarField, ecc.BN254.ScalarField()}
+
+func TestGenerate(t *testing.T) {
+	d := Generate(rand.New(rand.NewSource(1)), m31.ScalarField, DefaultConfig)
+	if d.String() != Generate(rand.New(rand.NewSource(1)), m31.ScalarField, DefaultConfig).String() {
+		t.Fatal("expected the same seed to generate the same DAG")
+	}
+	cfg := DefaultConfig
+	if len(d.Nodes) != cfg.NumInputs+cfg.NumPublic+cfg.NumNodes || len(d.Outputs) != cfg.NumOutputs {
+		t.Fatalf("unexpected shape: %d nodes, %d outputs", len(d.Nodes), len(d.Outputs))
+	}
+	if d.Outputs[0] != len(d.Nodes)-1 {
+		t.Fatal("expected the last node to be an output")
+	}
+	for i, n := range d.Nodes[cfg.NumInputs+cfg.NumPublic:] {
+		if n.Op < OpConst || n.Op >= numOps || n.X >= cfg.NumInputs+cfg.NumPublic+i || n.Y >= cfg.NumInputs+cfg.NumPublic+i {
+			t.Fatalf("invalid node %+v", n)
+		}
+	}
+
+	cfg.Weights = [numOps]int{OpDiv: 1}
+	d = Generate(rand.New(rand.NewSource(2)), m31.ScalarField, cfg)
+	for _, n := range d.Nodes[cfg.NumInputs+cfg.NumPublic:] {
+		if n.Op != OpDiv {
+			t.Fatalf("expected only divisions, got %s", n.Op)
+		}
+	}
+}
+
+// The engine implements the API by evaluating it, so it must agree with Eval, including on the
+// divisions by zero.
+func TestEvalEngine(t *testing.T) {
+	for _, field := range fields {
+		for seed := int64(0); seed < 50; seed++ {
+			r := rand.New(rand.NewSource(seed))
+			d := Generate(r, field, DefaultConfig)
+			inputs, public := []*big.Int{}, []*big.Int{}
+			for i := 0; i < d.NumInputs; i++ {
+				inputs = append(inputs, RandomValue(r, field))
+			}
+			for i := 0; i < d.NumPublic; i++ {
+				public = append(public, RandomValue(r, field))
+			}
+			want, err := d.Eval(field, inputs, public)
+			e := test.NewEngine(field)
+			runErr := e.Run(d.Circuit(), d.Assignment(inputs, public))
+			if err != nil {
+				if !errors.Is(err, ErrDivisionByZero) || runErr == nil {
+					t.Fatalf("seed %d: expected a division by zero, got %v and %v", seed, err, runErr)
+				}
+				continue
+			}
+			if runErr != nil || !equal(e.Outputs(), want) {
+				t.Fatalf("seed %d: expected %v, got %v, error %v\n%s", seed, want, e.Outputs(), runErr, d)
+			}
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	p := m31.ScalarField
+	d := &DAG{
+		NumInputs: 1,
+		NumPublic: 1,
+		Nodes: []Node{
+			{Op: OpInput},
+			{Op: OpPublic},
+			{Op: OpConst, Value: big.NewInt(3)},
+			{Op: OpMul, X: 0, Y: 2},
+			{Op: OpDiv, X: 3, Y: 1},
+			{Op: OpNeg, X: 4},
+			{Op: OpSub, X: 5, Y: 0},
+		},
+		Outputs: []int{6, 4},
+	}
+	out, err := d.Eval(p, []*big.Int{big.NewInt(4)}, []*big.Int{big.NewInt(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// -(4*3/2) - 4 = -10
+	if !equal(out, []*big.Int{new(big.Int).Sub(p, big.NewInt(10)), big.NewInt(6)}) {
+		t.Fatalf("unexpected outputs %v", out)
+	}
+	if _, err := d.Eval(p, []*big.Int{big.NewInt(4)}, []*big.Int{big.NewInt(0)}); !errors.Is(err, ErrDivisionByZero) {
+		t.Fatalf("expected a division by zero, got %v", err)
+	}
+}
+
+// TestBuild defines random circuits with the ecgo builder, which checks that denominators are
+// never folded to a constant zero.
+func TestBuild(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		d := Generate(rand.New(rand.NewSource(seed)), m31.ScalarField, DefaultConfig)
+		root := builder.NewRoot(m31.ScalarField, frontend.CompileConfig{})
+		c := d.Circuit()
+		schema.Walk(c, irwg.TVariable, func(f schema.LeafInfo, v reflect.Value) error {
+			if f.Visibility == schema.Public {
+				v.Set(reflect.ValueOf(root.PublicVariable(f)))
+			} else {
+				v.Set(reflect.ValueOf(root.SecretVariable(f)))
+			}
+			return nil
+		})
+		if err := c.Define(root); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(root.Finalize().Circuits[0].Outputs); n != len(d.Outputs) {
+			t.Fatalf("seed %d: expected %d outputs, got %d", seed, len(d.Outputs), n)
+		}
+	}
+}
+
+// FuzzCompile cross-checks the compilation of random circuits, see Check. It has no seed corpus,
+// since compiling needs the Rust library, so it only runs with -fuzz.
+func FuzzCompile(f *testing.F) {
+	f.Fuzz(func(t *testing.T, seed int64, bn254 bool) {
+		field := m31.ScalarField
+		if bn254 {
+			field = ecc.BN254.ScalarField()
+		}
+		r := rand.New(rand.NewSource(seed))
+		if err := Check(field, Generate(r, field, DefaultConfig), r, 4); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package ecgo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// gnarkBuilder is the frontend.Builder returned by NewBuilder. gnark creates the public variables
+// before the secret ones, so they are created with builder.Root.LeadingPublicVariable.
+type gnarkBuilder struct {
+	*builder.Root
+	config      *compileConfig
+	progress    *progress
+	publicNames []string
+}
+
+// NewBuilder is a frontend.NewBuilder compiling circuits with ecgo, so that gnark circuits can be
+// compiled unchanged with
+//
+//	cs, err := frontend.Compile(field, ecgo.NewBuilder, circuit)
+//
+// The constraint system returned by frontend.Compile is a *ConstraintSystem holding the
+// CompileResult. The options of this package can't be given to frontend.Compile, see
+// NewBuilderWithOptions.
+func NewBuilder(field *big.Int, config frontend.CompileConfig) (frontend.Builder, error) {
+	return newGnarkBuilder(field, config, defaultCompileConfig())
+}
+
+// NewBuilderWithOptions returns a frontend.NewBuilder like NewBuilder, compiling with the given
+// options of this package.
+func NewBuilderWithOptions(opts ...frontend.CompileOption) frontend.NewBuilder {
+	return func(field *big.Int, _ frontend.CompileConfig) (frontend.Builder, error) {
+		opt, config, err := applyOptions(opts)
+		if err != nil {
+			return nil, err
+		}
+		return newGnarkBuilder(field, opt, config)
+	}
+}
+
+func newGnarkBuilder(f *big.Int, opt frontend.CompileConfig, config *compileConfig) (b frontend.Builder, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				b, err = nil, e
+			} else {
+				b, err = nil, fmt.Errorf("%v", r)
+			}
+		}
+	}()
+	field.GetFieldFromOrder(f)
+	p := &progress{ctx: context.Background(), f: config.progress}
+	p.report("define", 0)
+	root := builder.NewRoot(f, opt)
+	root.SetSourceLocationDepth(config.locationDepth)
+	root.SetDebugPrints(!config.noDebugPrints)
+	root.SetGrowth(config.growth)
+	root.SetHooks(config.hooks)
+	root.SetProgress(p.building)
+	return &gnarkBuilder{Root: root, config: config, progress: p}, nil
+}
+
+// PublicVariable creates a new public variable for the circuit.
+func (b *gnarkBuilder) PublicVariable(f schema.LeafInfo) frontend.Variable {
+	b.publicNames = append(b.publicNames, f.FullName())
+	return b.LeadingPublicVariable(f)
+}
+
+// Compile compiles the circuit once gnark has called Define, and returns a *ConstraintSystem.
+func (b *gnarkBuilder) Compile() (constraint.ConstraintSystem, error) {
+	slots, err := b.config.publicLayout.order(b.publicNames)
+	if err != nil {
+		return nil, fmt.Errorf("public input layout: %w", err)
+	}
+	b.SetLeadingPublicSlots(slots)
+	publicOrder, layout := publicInputOrder(b.publicNames, slots)
+	res, err := compileRoot(b.Root, b.config, b.progress, layout, publicOrder)
+	if err != nil {
+		return nil, err
+	}
+	return &ConstraintSystem{res: res}, nil
+}
+
+// ConstraintSystem is the constraint.ConstraintSystem returned by frontend.Compile with
+// NewBuilder. It only gives access to the compiled circuit: the methods not listed here panic,
+// the witness being solved and proven with the CompileResult instead.
+type ConstraintSystem struct {
+	constraint.ConstraintSystem
+	res *CompileResult
+}
+
+// Result returns the result of the compilation.
+func (cs *ConstraintSystem) Result() *CompileResult {
+	return cs.res
+}
+
+// Field returns the modulus of the field of the circuit.
+func (cs *ConstraintSystem) Field() *big.Int {
+	return cs.res.GetLayeredCircuit().Field
+}
+
+// FieldBitLen returns the bit length of the modulus of the field of the circuit.
+func (cs *ConstraintSystem) FieldBitLen() int {
+	return cs.Field().BitLen()
+}
+
+// GetNbPublicVariables returns the number of public inputs of the circuit.
+func (cs *ConstraintSystem) GetNbPublicVariables() int {
+	return cs.res.GetCircuitIr().NumPublicInputs
+}
+
+// GetNbSecretVariables returns the number of secret inputs of the circuit.
+func (cs *ConstraintSystem) GetNbSecretVariables() int {
+	return cs.res.GetCircuitIr().Circuits[0].NumInputs
+}
+
+// WriteTo writes the serialized layered circuit.
+func (cs *ConstraintSystem) WriteTo(w io.Writer) (int64, error) {
+	return bytes.NewReader(cs.res.GetLayeredCircuit().Serialize()).WriteTo(w)
+}
@@ -0,0 +1,269 @@
+// Package golden generates golden test vectors of the binary formats shared with the Expander
+// prover: small canonical layered circuits, witnesses of them in the format of
+// irwg.Witness.Serialize, and the expected evaluation of each witness. Other implementations, e.g.
+// the Rust Expander repository, consume them as conformance tests. The vectors of the testdata
+// directory are regenerated with go generate, through the golden command of ecc.
+//
+// The circuits are built directly in the layered format, without random coefficients, so that
+// they don't depend on the compiler and their evaluation is deterministic.
+package golden
+
+//go:generate go run ../../cmd/ecc golden -out testdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/bn254"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+// Version is the version of the manifest written by Generate. It's increased on incompatible
+// changes of the manifest or of the layout of the vectors.
+const Version = 2
+
+// ManifestFile is the name of the manifest written by Generate in its directory.
+const ManifestFile = "vectors.json"
+
+// Manifest lists the vectors written by Generate.
+type Manifest struct {
+	Version int      `json:"version"`
+	Vectors []Vector `json:"vectors"`
+}
+
+// Vector is a circuit and witnesses of it. The files are relative to the directory of the
+// manifest.
+type Vector struct {
+	Name string `json:"name"`
+	// Field is the modulus of the field, in decimal.
+	Field string `json:"field"`
+	// Circuit is the layered circuit, see layered.RootCircuit.Serialize.
+	Circuit string `json:"circuit"`
+	// CircuitHash is the content hash of the circuit, in hex, see layered.RootCircuit.ContentHash.
+	CircuitHash string `json:"circuitHash"`
+	// Witness holds the witnesses, see irwg.Witness.Serialize.
+	Witness string `json:"witness"`
+	// Expected is the evaluation of each witness, in the order of the witness file.
+	Expected []Evaluation `json:"expected"`
+}
+
+// Evaluation is the expected evaluation of a witness.
+type Evaluation struct {
+	// Valid is whether the outputs expected to be zero are zero, i.e. whether a proof of the
+	// witness must verify.
+	Valid bool `json:"valid"`
+	// Outputs are the values of the output layer, in decimal.
+	Outputs []string `json:"outputs"`
+}
+
+// canonical is a circuit of the vectors, with the inputs and public inputs of its witnesses
+type canonical struct {
+	name      string
+	circuit   *layered.RootCircuit
+	witnesses [][2][]int64
+}
+
+// neg returns -x in the field f
+func neg(f *big.Int, x int64) *big.Int {
+	return new(big.Int).Sub(f, big.NewInt(x))
+}
+
+func constant(x *big.Int) layered.GateCst {
+	return layered.GateCst{Coef: x, CoefType: 1}
+}
+
+// arithmetic checks x0*x1 == x2 + 5 + p0 in the field f: the first layer computes x0*x1 and
+// x2 + 5 + p0, and the second one their difference
+func arithmetic(name string, f *big.Int) canonical {
+	cst := constant(big.NewInt(5))
+	cst.Out = 1
+	l0 := &layered.Circuit{
+		InputLen:  4,
+		OutputLen: 2,
+		Mul:       []layered.GateMul{{In0: 0, In1: 1, Out: 0, Coef: big.NewInt(1), CoefType: 1}},
+		Add:       []layered.GateAdd{{In: 2, Out: 1, Coef: big.NewInt(1), CoefType: 1}},
+		Cst:       []layered.GateCst{cst, {Out: 1, Coef: big.NewInt(0), CoefType: 3, PublicInputId: 0}},
+	}
+	l1 := &layered.Circuit{
+		InputLen:  2,
+		OutputLen: 1,
+		Add: []layered.GateAdd{
+			{In: 0, Out: 0, Coef: big.NewInt(1), CoefType: 1},
+			{In: 1, Out: 0, Coef: neg(f, 1), CoefType: 1},
+		},
+	}
+	return canonical{
+		name: name,
+		circuit: &layered.RootCircuit{
+			NumPublicInputs:         1,
+			NumActualOutputs:        1,
+			ExpectedNumOutputZeroes: 1,
+			Circuits:                []*layered.Circuit{l0, l1},
+			Layers:                  []uint64{0, 1},
+			Field:                   f,
+		},
+		// 3*4 == 5 + 5 + 2, and 3*4 != 1 + 5 + 2
+		witnesses: [][2][]int64{{{3, 4, 5, 0}, {2}}, {{3, 4, 1, 0}, {2}}},
+	}
+}
+
+// subCircuit checks x0*x1 == x2*x3 with two calls of a subcircuit multiplying its inputs
+func subCircuit() canonical {
+	f := m31.ScalarField
+	sub := &layered.Circuit{
+		InputLen:  2,
+		OutputLen: 1,
+		Mul:       []layered.GateMul{{In0: 0, In1: 1, Out: 0, Coef: big.NewInt(1), CoefType: 1}},
+	}
+	l0 := &layered.Circuit{
+		InputLen:    4,
+		OutputLen:   2,
+		SubCircuits: []layered.SubCircuit{{Id: 0, Allocations: []layered.Allocation{{}, {InputOffset: 2, OutputOffset: 1}}}},
+	}
+	l1 := &layered.Circuit{
+		InputLen:  2,
+		OutputLen: 1,
+		Add: []layered.GateAdd{
+			{In: 0, Out: 0, Coef: big.NewInt(1), CoefType: 1},
+			{In: 1, Out: 0, Coef: neg(f, 1), CoefType: 1},
+		},
+	}
+	return canonical{
+		name: "subcircuit_m31",
+		circuit: &layered.RootCircuit{
+			NumActualOutputs:        1,
+			ExpectedNumOutputZeroes: 1,
+			Circuits:                []*layered.Circuit{sub, l0, l1},
+			Layers:                  []uint64{1, 2},
+			Field:                   f,
+		},
+		witnesses: [][2][]int64{{{2, 6, 3, 4}, nil}, {{2, 6, 3, 5}, nil}},
+	}
+}
+
+// relay checks 3*x0^2 == x1, relaying x1 through the layer squaring x0
+func relay() canonical {
+	f := m31.ScalarField
+	l0 := &layered.Circuit{
+		InputLen:  2,
+		OutputLen: 2,
+		Mul:       []layered.GateMul{{In0: 0, In1: 0, Out: 0, Coef: big.NewInt(3), CoefType: 1}},
+		Add:       []layered.GateAdd{{In: 1, Out: 1, Coef: big.NewInt(1), CoefType: 1}},
+	}
+	l1 := &layered.Circuit{
+		InputLen:  2,
+		OutputLen: 2,
+		Add: []layered.GateAdd{
+			{In: 0, Out: 0, Coef: big.NewInt(1), CoefType: 1},
+			{In: 1, Out: 0, Coef: neg(f, 1), CoefType: 1},
+			{In: 1, Out: 1, Coef: big.NewInt(1), CoefType: 1},
+		},
+	}
+	l2 := &layered.Circuit{
+		InputLen:  2,
+		OutputLen: 2,
+		Add: []layered.GateAdd{
+			{In: 0, Out: 0, Coef: big.NewInt(1), CoefType: 1},
+			{In: 1, Out: 1, Coef: big.NewInt(1), CoefType: 1},
+		},
+	}
+	return canonical{
+		name: "relay_m31",
+		circuit: &layered.RootCircuit{
+			// the second output is x1, given to API.Output
+			NumActualOutputs:        2,
+			ExpectedNumOutputZeroes: 1,
+			Circuits:                []*layered.Circuit{l0, l1, l2},
+			Layers:                  []uint64{0, 1, 2},
+			Field:                   f,
+		},
+		witnesses: [][2][]int64{{{7, 147}, nil}, {{7, 148}, nil}},
+	}
+}
+
+// canonicals returns the circuits of the vectors
+func canonicals() []canonical {
+	return []canonical{
+		arithmetic("arithmetic_m31", m31.ScalarField),
+		arithmetic("arithmetic_bn254", bn254.ScalarField),
+		subCircuit(),
+		relay(),
+	}
+}
+
+// vector writes the files of c to dir, and returns its entry in the manifest
+func (c *canonical) vector(dir string) (Vector, error) {
+	rc := c.circuit
+	hash := rc.ContentHash()
+	w := &irwg.Witness{
+		NumWitnesses:              len(c.witnesses),
+		NumInputsPerWitness:       int(rc.Circuits[rc.Layers[0]].InputLen),
+		NumPublicInputsPerWitness: rc.NumPublicInputs,
+		Field:                     rc.Field,
+	}
+	v := Vector{
+		Name:        c.name,
+		Field:       rc.Field.String(),
+		Circuit:     c.name + "/circuit.txt",
+		CircuitHash: fmt.Sprintf("%x", hash),
+		Witness:     c.name + "/witness.txt",
+	}
+	for _, iw := range c.witnesses {
+		input, public := bigInts(iw[0]), bigInts(iw[1])
+		w.Values = append(w.Values, input...)
+		w.Values = append(w.Values, public...)
+		out, err := rc.Eval(input, public)
+		if err != nil {
+			return Vector{}, fmt.Errorf("%s: %w", c.name, err)
+		}
+		e := Evaluation{Valid: true}
+		for i, x := range out {
+			if i < rc.ExpectedNumOutputZeroes && x.Sign() != 0 {
+				e.Valid = false
+			}
+			e.Outputs = append(e.Outputs, x.String())
+		}
+		v.Expected = append(v.Expected, e)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, c.name), 0o755); err != nil {
+		return Vector{}, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, v.Circuit), rc.Serialize(), 0o644); err != nil {
+		return Vector{}, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, v.Witness), w.Serialize(), 0o644); err != nil {
+		return Vector{}, err
+	}
+	return v, nil
+}
+
+func bigInts(x []int64) []*big.Int {
+	res := make([]*big.Int, len(x))
+	for i, v := range x {
+		res[i] = big.NewInt(v)
+	}
+	return res
+}
+
+// Generate writes the vectors to dir, a directory per circuit, and their manifest to the file
+// ManifestFile of dir. The output is deterministic.
+func Generate(dir string) error {
+	m := Manifest{Version: Version}
+	for _, c := range canonicals() {
+		v, err := c.vector(dir)
+		if err != nil {
+			return err
+		}
+		m.Vectors = append(m.Vectors, v)
+	}
+	buf, err := json.MarshalIndent(&m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestFile), append(buf, '\n'), 0o644)
+}
@@ -0,0 +1,78 @@
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+func TestUpToDate(t *testing.T) {
+	dir := t.TempDir()
+	if err := Generate(dir); err != nil {
+		t.Fatal(err)
+	}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		got, _ := os.ReadFile(path)
+		want, err := os.ReadFile(filepath.Join("testdata", rel))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("testdata/%s is out of date, run go generate", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestVectors checks the vectors of testdata like another implementation would
+func TestVectors(t *testing.T) {
+	buf, err := os.ReadFile(filepath.Join("testdata", ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(buf, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != Version || len(m.Vectors) == 0 {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+	for _, v := range m.Vectors {
+		circuit, _ := os.ReadFile(filepath.Join("testdata", v.Circuit))
+		witness, _ := os.ReadFile(filepath.Join("testdata", v.Witness))
+		rc := layered.DeserializeRootCircuit(circuit)
+		w := irwg.DeserializeWitness(witness)
+		hash := rc.ContentHash()
+		if fmt.Sprintf("%x", hash) != v.CircuitHash || rc.Field.String() != v.Field {
+			t.Fatalf("%s: unexpected circuit", v.Name)
+		}
+		n := w.NumInputsPerWitness + w.NumPublicInputsPerWitness
+		for k, e := range v.Expected {
+			values := w.Values[k*n : (k+1)*n]
+			out, err := rc.Eval(values[:w.NumInputsPerWitness], values[w.NumInputsPerWitness:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = rc.Outputs(values[:w.NumInputsPerWitness], values[w.NumInputsPerWitness:])
+			if (err == nil) != e.Valid || len(out) != len(e.Outputs) {
+				t.Fatalf("%s: unexpected validity of witness %d", v.Name, k)
+			}
+			for i, x := range out {
+				if x.String() != e.Outputs[i] {
+					t.Fatalf("%s: output %d of witness %d is %s, expected %s", v.Name, i, k, x, e.Outputs[i])
+				}
+			}
+		}
+	}
+}
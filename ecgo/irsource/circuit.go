@@ -1,6 +1,10 @@
 package irsource
 
-import "github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+import (
+	"sort"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+)
 
 type Circuit struct {
 	Instructions []Instruction
@@ -14,4 +18,28 @@ type RootCircuit struct {
 	ExpectedNumOutputZeroes int
 	Circuits                map[uint64]*Circuit
 	Field                   field.Field
+	// Locations are the source locations referred to by the Loc fields of instructions and
+	// constraints. Locations[0] is the unknown location.
+	Locations []SourceLocation
+}
+
+// CircuitIds returns the ids of the circuits in increasing order. Iterating over them instead of
+// the Circuits map keeps everything derived from the root circuit deterministic.
+func (rc *RootCircuit) CircuitIds() []uint64 {
+	ids := make([]uint64, 0, len(rc.Circuits))
+	for id := range rc.Circuits {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// NumVariables returns the number of variables of the circuit, excluding the placeholder variable 0.
+// Variables 1..NumInputs are the inputs, and each instruction defines the next OutputCount() variables.
+func (c *Circuit) NumVariables() int {
+	n := c.NumInputs
+	for i := range c.Instructions {
+		n += c.Instructions[i].OutputCount()
+	}
+	return n
 }
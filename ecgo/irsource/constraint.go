@@ -12,4 +12,7 @@ const (
 type Constraint struct {
 	Typ ConstraintType
 	Var int
+	// Loc is the index of the source location in RootCircuit.Locations, 0 if unknown.
+	// It's not serialized.
+	Loc uint32
 }
@@ -0,0 +1,150 @@
+package irsource
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ExportFormat selects the rendering produced by RootCircuit.Export.
+type ExportFormat int
+
+const (
+	// ExportJSON dumps all the circuits as JSON, for inspection, diffing and external tools.
+	// Variables are numbered like in the IR, and field elements are decimal strings. The export
+	// is lossy: it can't be read back.
+	ExportJSON ExportFormat = iota
+	// ExportGraphviz renders the root circuit as a DOT graph, see Graphviz.
+	ExportGraphviz
+	// ExportText writes all the circuits in human-readable algebra, see Dump.
+	ExportText
+)
+
+type jsonInstruction struct {
+	Type    string   `json:"type"`
+	Outputs []int    `json:"outputs"`
+	Inputs  []int    `json:"inputs,omitempty"`
+	Coefs   []string `json:"coefs,omitempty"`
+	Const   string   `json:"const,omitempty"`
+	// name of the boolean operation, or of the kind of constant
+	Op string `json:"op,omitempty"`
+	// id of the hint, called subcircuit, custom gate or public input slot
+	Id        *uint64 `json:"id,omitempty"`
+	Unchecked bool    `json:"unchecked,omitempty"`
+	Location  string  `json:"location,omitempty"`
+}
+
+type jsonConstraint struct {
+	Type     string `json:"type"`
+	Var      int    `json:"var"`
+	Location string `json:"location,omitempty"`
+}
+
+type jsonCircuit struct {
+	Id           uint64            `json:"id"`
+	NumInputs    int               `json:"numInputs"`
+	Instructions []jsonInstruction `json:"instructions"`
+	Constraints  []jsonConstraint  `json:"constraints"`
+	Outputs      []int             `json:"outputs"`
+}
+
+type jsonRootCircuit struct {
+	Field                   string        `json:"field"`
+	NumPublicInputs         int           `json:"numPublicInputs"`
+	ExpectedNumOutputZeroes int           `json:"expectedNumOutputZeroes"`
+	Circuits                []jsonCircuit `json:"circuits"`
+}
+
+var boolBinOpNames = map[uint64]string{1: "xor", 2: "or", 3: "and"}
+
+var constraintTypeNames = map[ConstraintType]string{
+	Zero:    "zero",
+	NonZero: "nonzero",
+	Bool:    "bool",
+}
+
+// Export writes a rendering of the circuit to w, for debugging and external tools.
+func (rc *RootCircuit) Export(w io.Writer, format ExportFormat) error {
+	switch format {
+	case ExportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rc.toJSON())
+	case ExportGraphviz:
+		_, err := io.WriteString(w, rc.Graphviz(0))
+		return err
+	case ExportText:
+		for _, id := range rc.CircuitIds() {
+			if err := rc.Dump(w, id, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown export format %d", format)
+}
+
+func (rc *RootCircuit) toJSON() *jsonRootCircuit {
+	res := &jsonRootCircuit{
+		Field:                   rc.Field.Field().String(),
+		NumPublicInputs:         rc.NumPublicInputs,
+		ExpectedNumOutputZeroes: rc.ExpectedNumOutputZeroes,
+	}
+	location := func(loc uint32) string {
+		if l := rc.Location(loc); len(l) != 0 {
+			return l.String()
+		}
+		return ""
+	}
+	for _, id := range rc.CircuitIds() {
+		c := rc.Circuits[id]
+		jc := jsonCircuit{
+			Id:           id,
+			NumInputs:    c.NumInputs,
+			Instructions: []jsonInstruction{},
+			Constraints:  []jsonConstraint{},
+			Outputs:      c.Outputs,
+		}
+		v := c.NumInputs + 1
+		for i := range c.Instructions {
+			in := &c.Instructions[i]
+			ji := jsonInstruction{Type: instructionNames[in.Type], Inputs: in.Operands(), Location: location(in.Loc)}
+			for j := 0; j < in.OutputCount(); j++ {
+				ji.Outputs = append(ji.Outputs, v)
+				v++
+			}
+			extraId := in.ExtraId
+			switch in.Type {
+			case LinComb:
+				for _, e := range in.LinCombCoef {
+					ji.Coefs = append(ji.Coefs, rc.Field.ToBigInt(e).String())
+				}
+				ji.Const = rc.Field.ToBigInt(in.Const).String()
+			case Div:
+				ji.Unchecked = in.ExtraId == 1
+			case BoolBinOp:
+				ji.Op = boolBinOpNames[in.ExtraId]
+			case ConstantLike:
+				switch in.ExtraId {
+				case 0:
+					ji.Op = "constant"
+					ji.Const = rc.Field.ToBigInt(in.Const).String()
+				case 1:
+					ji.Op = "random"
+				default:
+					ji.Op = "public"
+					extraId -= 2
+					ji.Id = &extraId
+				}
+			case Hint, SubCircuitCall, CustomGate:
+				ji.Id = &extraId
+			}
+			jc.Instructions = append(jc.Instructions, ji)
+		}
+		for _, con := range c.Constraints {
+			jc.Constraints = append(jc.Constraints, jsonConstraint{Type: constraintTypeNames[con.Typ], Var: con.Var, Location: location(con.Loc)})
+		}
+		res.Circuits = append(res.Circuits, jc)
+	}
+	return res
+}
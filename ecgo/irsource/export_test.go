@@ -0,0 +1,79 @@
+package irsource
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/constraint"
+)
+
+func sampleRootCircuit() *RootCircuit {
+	f := &m31.Field{}
+	return &RootCircuit{
+		NumPublicInputs: 1,
+		Field:           f,
+		Circuits: map[uint64]*Circuit{
+			0: {
+				NumInputs: 1,
+				Instructions: []Instruction{
+					{Type: ConstantLike, ExtraId: 2, Loc: 1},                                                         // 2 = public 0
+					{Type: Hint, ExtraId: 77, Inputs: []int{1}, NumOutputs: 2},                                       // 3, 4
+					{Type: SubCircuitCall, ExtraId: 5, Inputs: []int{3, 4}, NumOutputs: 1},                           // 5
+					{Type: LinComb, Inputs: []int{5, 2}, LinCombCoef: []constraint.Element{f.One(), f.Neg(f.One())}}, // 6
+					{Type: BoolBinOp, X: 3, Y: 4, ExtraId: 1},                                                        // 7
+				},
+				Constraints: []Constraint{{Typ: Zero, Var: 6, Loc: 1}, {Typ: Bool, Var: 7}},
+			},
+			5: {
+				NumInputs:    2,
+				Instructions: []Instruction{{Type: Mul, Inputs: []int{1, 2}}},
+				Outputs:      []int{3},
+			},
+		},
+		Locations: []SourceLocation{nil, {{Function: "main.main", File: "/src/main.go", Line: 12}}},
+	}
+}
+
+func TestExport(t *testing.T) {
+	rc := sampleRootCircuit()
+
+	var buf bytes.Buffer
+	if err := rc.Export(&buf, ExportJSON); err != nil {
+		t.Fatal(err)
+	}
+	var res jsonRootCircuit
+	if err := json.Unmarshal(buf.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Circuits) != 2 || res.Circuits[0].Id != 0 || res.Circuits[1].Id != 5 || res.Field != "2147483647" {
+		t.Fatalf("unexpected JSON export %s", buf.String())
+	}
+	insns := res.Circuits[0].Instructions
+	if insns[0].Op != "public" || *insns[0].Id != 0 || insns[0].Location != "main.go:12" {
+		t.Fatalf("unexpected public input %+v", insns[0])
+	}
+	if len(insns[1].Outputs) != 2 || insns[1].Outputs[1] != 4 || *insns[2].Id != 5 || insns[2].Outputs[0] != 5 {
+		t.Fatalf("unexpected hint or call %+v %+v", insns[1], insns[2])
+	}
+	if insns[3].Coefs[1] != "2147483646" || insns[4].Op != "xor" {
+		t.Fatalf("unexpected linear combination or boolean operation %+v %+v", insns[3], insns[4])
+	}
+	if c := res.Circuits[0].Constraints; c[0].Type != "zero" || c[0].Location != "main.go:12" || c[1].Type != "bool" {
+		t.Fatalf("unexpected constraints %+v", c)
+	}
+
+	buf.Reset()
+	if err := rc.Export(&buf, ExportGraphviz); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "call 5") || !strings.Contains(buf.String(), "const\\nmain.go:12") {
+		t.Fatalf("unexpected DOT export\n%s", buf.String())
+	}
+
+	if err := rc.Export(&buf, ExportFormat(42)); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,74 @@
+package irsource
+
+import (
+	"fmt"
+	"strings"
+)
+
+var instructionNames = map[InstructionType]string{
+	LinComb:             "lincomb",
+	Mul:                 "mul",
+	Div:                 "div",
+	BoolBinOp:           "boolop",
+	IsZero:              "iszero",
+	Commit:              "commit",
+	Hint:                "hint",
+	ConstantLike:        "const",
+	SubCircuitCall:      "call",
+	UnconstrainedBinOp:  "binop",
+	UnconstrainedSelect: "select",
+	CustomGate:          "custom",
+}
+
+var constraintNames = map[ConstraintType]string{
+	Zero:    "== 0",
+	NonZero: "!= 0",
+	Bool:    "bool",
+}
+
+// Graphviz generates a graphviz compatible DOT file for the given circuit: one node per input,
+// instruction and constraint, labelled with the source location it was created at, and one edge
+// per operand.
+func (rc *RootCircuit) Graphviz(circuitId uint64) string {
+	c := rc.Circuits[circuitId]
+	var sb strings.Builder
+	label := func(name string, loc uint32) string {
+		if l := rc.Location(loc); len(l) != 0 {
+			return fmt.Sprintf("%s\\n%s", name, l)
+		}
+		return name
+	}
+
+	sb.WriteString("digraph G{\n")
+	sb.WriteString("\trankdir=BT;\n")
+	// node of the instruction defining each variable
+	def := make([]string, 1, c.NumVariables()+1)
+	for i := 1; i <= c.NumInputs; i++ {
+		fmt.Fprintf(&sb, "\tV_%d[label=\"input %d\" style=filled fillcolor=lightgrey];\n", i, i)
+		def = append(def, fmt.Sprintf("V_%d", i))
+	}
+	for i := range c.Instructions {
+		in := &c.Instructions[i]
+		name := instructionNames[in.Type]
+		if in.Type == SubCircuitCall {
+			name = fmt.Sprintf("call %d", in.ExtraId)
+		}
+		fmt.Fprintf(&sb, "\tI_%d[label=\"%s\" shape=box];\n", i, label(name, in.Loc))
+		for _, x := range in.Operands() {
+			fmt.Fprintf(&sb, "\t%s -> I_%d;\n", def[x], i)
+		}
+		for j := 0; j < in.OutputCount(); j++ {
+			def = append(def, fmt.Sprintf("I_%d", i))
+		}
+	}
+	for i, con := range c.Constraints {
+		fmt.Fprintf(&sb, "\tC_%d[label=\"%s\" style=filled fillcolor=salmon];\n", i, label(constraintNames[con.Typ], con.Loc))
+		fmt.Fprintf(&sb, "\t%s -> C_%d;\n", def[con.Var], i)
+	}
+	for i, x := range c.Outputs {
+		fmt.Fprintf(&sb, "\tO_%d[label=\"output %d\" style=filled fillcolor=lightskyblue];\n", i, i)
+		fmt.Fprintf(&sb, "\t%s -> O_%d;\n", def[x], i)
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
@@ -20,6 +20,11 @@ const (
 	CustomGate
 )
 
+// DivHintId is the ExtraId of the builtin division hint: given x and y, it returns x / y, and 0
+// when y is zero, so that the constraints checking its result decide how a zero divisor is
+// handled. It's solved without the hint registry, and always allowed by irwg.HintPolicy.
+const DivHintId uint64 = 0xCCC000000001
+
 type Instruction struct {
 	Type        InstructionType
 	X           int
@@ -29,4 +34,50 @@ type Instruction struct {
 	ExtraId     uint64
 	LinCombCoef []constraint.Element
 	Const       constraint.Element
+	// Loc is the index of the source location in RootCircuit.Locations, 0 if unknown.
+	// It's not serialized.
+	Loc uint32
+}
+
+// OutputCount returns the number of variables defined by the instruction.
+func (i *Instruction) OutputCount() int {
+	switch i.Type {
+	case Hint, SubCircuitCall:
+		return i.NumOutputs
+	default:
+		return 1
+	}
+}
+
+// Operands returns the variables read by the instruction.
+func (i *Instruction) Operands() []int {
+	switch i.Type {
+	case Div, BoolBinOp:
+		return []int{i.X, i.Y}
+	case IsZero:
+		return []int{i.X}
+	case ConstantLike:
+		return nil
+	default:
+		return i.Inputs
+	}
+}
+
+// MapOperands returns a copy of the instruction whose operands are replaced by f.
+func (i *Instruction) MapOperands(f func(int) int) Instruction {
+	res := *i
+	switch i.Type {
+	case Div, BoolBinOp:
+		res.X = f(i.X)
+		res.Y = f(i.Y)
+	case IsZero:
+		res.X = f(i.X)
+	case ConstantLike:
+	default:
+		res.Inputs = make([]int, len(i.Inputs))
+		for j, x := range i.Inputs {
+			res.Inputs[j] = f(x)
+		}
+	}
+	return res
 }
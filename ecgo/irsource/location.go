@@ -0,0 +1,132 @@
+package irsource
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Frame is a function call of a source location.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// SourceLocation is the call stack of the code that created an instruction or a constraint,
+// innermost call first. It's empty if locations weren't recorded.
+type SourceLocation []Frame
+
+// String returns the innermost frame as file:line, with the base name of the file.
+func (l SourceLocation) String() string {
+	if len(l) == 0 {
+		return "unknown location"
+	}
+	return fmt.Sprintf("%s:%d", filepath.Base(l[0].File), l[0].Line)
+}
+
+// Caller returns the frames of the callers of the innermost frame.
+func (l SourceLocation) Caller() SourceLocation {
+	if len(l) <= 1 {
+		return nil
+	}
+	return l[1:]
+}
+
+// Stack returns all the frames, one per line, like a Go stack trace.
+func (l SourceLocation) Stack() string {
+	var sb strings.Builder
+	for _, f := range l {
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+	}
+	return sb.String()
+}
+
+// Location returns the source location of the given id, as stored in the Loc field of
+// instructions and constraints.
+func (rc *RootCircuit) Location(id uint32) SourceLocation {
+	if int(id) >= len(rc.Locations) {
+		return nil
+	}
+	return rc.Locations[id]
+}
+
+// CallCounts returns the number of times each circuit is instantiated in the root circuit. Circuits
+// which aren't called are missing.
+func (rc *RootCircuit) CallCounts() map[uint64]uint64 {
+	// propagate the counts from callers to callees in topological order
+	order := []uint64{}
+	visited := map[uint64]bool{}
+	var visit func(id uint64)
+	visit = func(id uint64) {
+		visited[id] = true
+		for _, in := range rc.Circuits[id].Instructions {
+			if in.Type == SubCircuitCall && !visited[in.ExtraId] {
+				visit(in.ExtraId)
+			}
+		}
+		order = append(order, id)
+	}
+	visit(0)
+	calls := map[uint64]uint64{0: 1}
+	for i := len(order) - 1; i >= 0; i-- {
+		id := order[i]
+		for _, in := range rc.Circuits[id].Instructions {
+			if in.Type == SubCircuitCall {
+				calls[in.ExtraId] += calls[id]
+			}
+		}
+	}
+	return calls
+}
+
+// SourceStat is the cost attributed to a source line.
+type SourceStat struct {
+	Location     string
+	Instructions uint64
+	Constraints  uint64
+}
+
+// SourceStats returns the number of instructions and constraints created by each source line,
+// as given by SourceLocation.String, counting subcircuits once per call. Subcircuit calls
+// themselves are not counted. The result is sorted by decreasing cost.
+func (rc *RootCircuit) SourceStats() []SourceStat {
+	calls := rc.CallCounts()
+	stats := map[string]*SourceStat{}
+	get := func(loc uint32) *SourceStat {
+		key := rc.Location(loc).String()
+		s, ok := stats[key]
+		if !ok {
+			s = &SourceStat{Location: key}
+			stats[key] = s
+		}
+		return s
+	}
+	for _, id := range rc.CircuitIds() {
+		n := calls[id]
+		if n == 0 {
+			continue
+		}
+		c := rc.Circuits[id]
+		for _, in := range c.Instructions {
+			if in.Type != SubCircuitCall {
+				get(in.Loc).Instructions += n
+			}
+		}
+		for _, con := range c.Constraints {
+			get(con.Loc).Constraints += n
+		}
+	}
+	res := make([]SourceStat, 0, len(stats))
+	for _, s := range stats {
+		res = append(res, *s)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if a, b := res[i].Instructions+res[i].Constraints, res[j].Instructions+res[j].Constraints; a != b {
+			return a > b
+		}
+		return res[i].Location < res[j].Location
+	})
+	return res
+}
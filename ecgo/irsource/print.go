@@ -0,0 +1,241 @@
+package irsource
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/consensys/gnark/constraint"
+)
+
+// Names maps variables of a circuit to the names the printers use for them, e.g. the names of
+// the tagged outputs. Variables without a name are printed as vN.
+type Names map[int]string
+
+func (n Names) get(x int) string {
+	if name, ok := n[x]; ok {
+		return name
+	}
+	return fmt.Sprintf("v%d", x)
+}
+
+// list returns the names of xs separated by commas
+func (n Names) list(xs []int) string {
+	res := make([]string, len(xs))
+	for j, x := range xs {
+		res[j] = n.get(x)
+	}
+	return strings.Join(res, ", ")
+}
+
+// String returns the constraint as an assertion on its variable, e.g. "assert v12 == 0".
+func (c Constraint) String() string {
+	return c.Format(nil)
+}
+
+// Format returns the constraint like String, with the variables named by names.
+func (c Constraint) Format(names Names) string {
+	switch c.Typ {
+	case Zero:
+		return fmt.Sprintf("assert %s == 0", names.get(c.Var))
+	case NonZero:
+		return fmt.Sprintf("assert %s != 0", names.get(c.Var))
+	case Bool:
+		return fmt.Sprintf("assert %s is boolean", names.get(c.Var))
+	}
+	return fmt.Sprintf("assert %s: unknown constraint %d", names.get(c.Var), c.Typ)
+}
+
+// Format returns the instruction as an assignment of the variables it defines, starting at
+// firstOutput, e.g. "v7 = v3 * v5" or "v8 = 2*v1 - v2 + 1". Constants are printed as integers
+// of field f, from -(p-1)/2 to (p-1)/2.
+func (i *Instruction) Format(f field.Field, firstOutput int, names Names) string {
+	outputs := make([]int, i.OutputCount())
+	for j := range outputs {
+		outputs[j] = firstOutput + j
+	}
+	return fmt.Sprintf("%s = %s", names.list(outputs), i.formatExpr(f, names))
+}
+
+// formatExpr returns the right-hand side of Format
+func (i *Instruction) formatExpr(f field.Field, names Names) string {
+	call := func(name string, xs []int) string {
+		return fmt.Sprintf("%s(%s)", name, names.list(xs))
+	}
+	switch i.Type {
+	case LinComb:
+		var sb strings.Builder
+		for j, x := range i.Inputs {
+			coef := signed(f, i.LinCombCoef[j])
+			writeTerm(&sb, coef, names.get(x))
+		}
+		if c := signed(f, i.Const); c.Sign() != 0 || sb.Len() == 0 {
+			writeTerm(&sb, c, "")
+		}
+		return sb.String()
+	case Mul:
+		factors := make([]string, len(i.Inputs))
+		for j, x := range i.Inputs {
+			factors[j] = names.get(x)
+		}
+		if len(factors) == 0 {
+			return "1"
+		}
+		return strings.Join(factors, " * ")
+	case Div:
+		if i.ExtraId == 1 {
+			return fmt.Sprintf("%s / %s (unchecked)", names.get(i.X), names.get(i.Y))
+		}
+		return fmt.Sprintf("%s / %s", names.get(i.X), names.get(i.Y))
+	case BoolBinOp:
+		return fmt.Sprintf("%s %s %s", names.get(i.X), boolBinOpNames[i.ExtraId], names.get(i.Y))
+	case IsZero:
+		return call("iszero", []int{i.X})
+	case Commit:
+		return call("commit", i.Inputs)
+	case Hint:
+		return call(fmt.Sprintf("hint#%d", i.ExtraId), i.Inputs)
+	case ConstantLike:
+		switch i.ExtraId {
+		case 0:
+			return signed(f, i.Const).String()
+		case 1:
+			return "random"
+		default:
+			return fmt.Sprintf("public[%d]", i.ExtraId-2)
+		}
+	case SubCircuitCall:
+		return call(fmt.Sprintf("circuit#%d", i.ExtraId), i.Inputs)
+	case UnconstrainedBinOp:
+		return call(fmt.Sprintf("binop#%d", i.ExtraId), i.Inputs)
+	case UnconstrainedSelect:
+		return call("select", i.Inputs)
+	case CustomGate:
+		return call(fmt.Sprintf("custom#%d", i.ExtraId), i.Inputs)
+	}
+	return call(fmt.Sprintf("unknown#%d", i.Type), i.Operands())
+}
+
+// signed returns x as an integer from -(p-1)/2 to (p-1)/2
+func signed(f field.Field, x constraint.Element) *big.Int {
+	v := f.ToBigInt(x)
+	if new(big.Int).Lsh(v, 1).Cmp(f.Field()) > 0 {
+		v.Sub(v, f.Field())
+	}
+	return v
+}
+
+// writeTerm appends coef*name to the sum in sb, or coef alone if name is empty
+func writeTerm(sb *strings.Builder, coef *big.Int, name string) {
+	abs := new(big.Int).Abs(coef)
+	switch {
+	case sb.Len() == 0 && coef.Sign() < 0:
+		sb.WriteString("-")
+	case sb.Len() != 0 && coef.Sign() < 0:
+		sb.WriteString(" - ")
+	case sb.Len() != 0:
+		sb.WriteString(" + ")
+	}
+	switch {
+	case name == "":
+		sb.WriteString(abs.String())
+	case abs.Cmp(big.NewInt(1)) == 0:
+		sb.WriteString(name)
+	default:
+		fmt.Fprintf(sb, "%s*%s", abs, name)
+	}
+}
+
+// Dump writes the circuit circuitId in human-readable algebra: its inputs, then one line per
+// instruction and per constraint, in the order of the circuit, and its outputs.
+func (rc *RootCircuit) Dump(w io.Writer, circuitId uint64, names Names) error {
+	c, ok := rc.Circuits[circuitId]
+	if !ok {
+		return fmt.Errorf("unknown circuit %d", circuitId)
+	}
+	var sb strings.Builder
+	inputs := make([]int, c.NumInputs)
+	for j := range inputs {
+		inputs[j] = j + 1
+	}
+	fmt.Fprintf(&sb, "circuit %d(%s)\n", circuitId, names.list(inputs))
+	v := c.NumInputs + 1
+	for i := range c.Instructions {
+		fmt.Fprintf(&sb, "\t%s\n", c.Instructions[i].Format(rc.Field, v, names))
+		v += c.Instructions[i].OutputCount()
+	}
+	for _, con := range c.Constraints {
+		fmt.Fprintf(&sb, "\t%s\n", con.Format(names))
+	}
+	fmt.Fprintf(&sb, "\treturn %s\n", names.list(c.Outputs))
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// ExplainConstraint returns the expression tree feeding constraint i of circuit circuitId: the
+// constraint, then the instruction defining its variable and, indented below it, those defining
+// the operands, down to depth instructions, or the whole tree if depth is 0. A variable defined by
+// an instruction already printed is marked "(see above)", and the inputs of the circuit are marked
+// "(input)".
+func (rc *RootCircuit) ExplainConstraint(circuitId uint64, i int, depth int, names Names) (string, error) {
+	c, ok := rc.Circuits[circuitId]
+	if !ok {
+		return "", fmt.Errorf("unknown circuit %d", circuitId)
+	}
+	if i < 0 || i >= len(c.Constraints) {
+		return "", fmt.Errorf("constraint %d out of range, circuit %d has %d constraints", i, circuitId, len(c.Constraints))
+	}
+	// instruction and first output defining each variable
+	def := make([]int, c.NumVariables()+1)
+	first := make([]int, len(c.Instructions))
+	v := c.NumInputs + 1
+	for j := range c.Instructions {
+		first[j] = v
+		for k := 0; k < c.Instructions[j].OutputCount(); k++ {
+			def[v] = j + 1
+			v++
+		}
+	}
+
+	var sb strings.Builder
+	con := c.Constraints[i]
+	sb.WriteString(con.Format(names))
+	if l := rc.Location(con.Loc); len(l) != 0 {
+		fmt.Fprintf(&sb, "  // %s", l)
+	}
+	sb.WriteString("\n")
+	printed := make(map[int]bool)
+	var explain func(x int, level int)
+	explain = func(x int, level int) {
+		indent := strings.Repeat("  ", level)
+		if x == 0 || def[x] == 0 {
+			fmt.Fprintf(&sb, "%s%s (input)\n", indent, names.get(x))
+			return
+		}
+		j := def[x] - 1
+		if printed[j] {
+			fmt.Fprintf(&sb, "%s%s (see above)\n", indent, names.get(x))
+			return
+		}
+		printed[j] = true
+		in := &c.Instructions[j]
+		fmt.Fprintf(&sb, "%s%s", indent, in.Format(rc.Field, first[j], names))
+		if l := rc.Location(in.Loc); len(l) != 0 {
+			fmt.Fprintf(&sb, "  // %s", l)
+		}
+		sb.WriteString("\n")
+		if depth != 0 && level >= depth {
+			if len(in.Operands()) != 0 {
+				fmt.Fprintf(&sb, "%s  ...\n", indent)
+			}
+			return
+		}
+		for _, y := range in.Operands() {
+			explain(y, level+1)
+		}
+	}
+	explain(con.Var, 1)
+	return sb.String(), nil
+}
@@ -0,0 +1,74 @@
+package irsource
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/constraint"
+)
+
+func TestDump(t *testing.T) {
+	rc := sampleRootCircuit()
+	var buf bytes.Buffer
+	if err := rc.Dump(&buf, 0, Names{6: "diff"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"circuit 0(v1)\n",
+		"\tv2 = public[0]\n",
+		"\tv3, v4 = hint#77(v1)\n",
+		"\tv5 = circuit#5(v3, v4)\n",
+		"\tdiff = v5 - v2\n",
+		"\tv7 = v3 xor v4\n",
+		"\tassert diff == 0\n",
+		"\tassert v7 is boolean\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %q in the dump\n%s", want, buf.String())
+		}
+	}
+
+	f := &m31.Field{}
+	in := Instruction{Type: LinComb, Inputs: []int{1, 2}, LinCombCoef: []constraint.Element{f.FromInterface(-3), f.FromInterface(2)}, Const: f.FromInterface(5)}
+	if s := in.Format(f, 3, nil); s != "v3 = -3*v1 + 2*v2 + 5" {
+		t.Fatalf("unexpected linear combination %q", s)
+	}
+	if s := (Constraint{Typ: NonZero, Var: 4}).String(); s != "assert v4 != 0" {
+		t.Fatalf("unexpected constraint %q", s)
+	}
+
+	buf.Reset()
+	if err := rc.Export(&buf, ExportText); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "circuit 5(v1, v2)\n\tv3 = v1 * v2\n\treturn v3\n") {
+		t.Fatalf("expected the subcircuit in the text export\n%s", buf.String())
+	}
+}
+
+func TestExplainConstraint(t *testing.T) {
+	rc := sampleRootCircuit()
+	s, err := rc.ExplainConstraint(0, 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `assert v6 == 0  // main.go:12
+  v6 = v5 - v2
+    v5 = circuit#5(v3, v4)
+      v3, v4 = hint#77(v1)
+        v1 (input)
+      v4 (see above)
+    v2 = public[0]  // main.go:12
+`
+	if s != want {
+		t.Fatalf("unexpected tree\n%s\nexpected\n%s", s, want)
+	}
+	if s, _ = rc.ExplainConstraint(0, 0, 1, nil); !strings.HasSuffix(s, "  v6 = v5 - v2\n    ...\n") {
+		t.Fatalf("expected the tree to stop at depth 1\n%s", s)
+	}
+	if _, err := rc.ExplainConstraint(0, 2, 0, nil); err == nil {
+		t.Fatal("expected a missing constraint to be rejected")
+	}
+}
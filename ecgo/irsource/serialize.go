@@ -1,8 +1,11 @@
 package irsource
 
 import (
+	"crypto/sha256"
+
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils"
+	"github.com/consensys/gnark/constraint"
 )
 
 func serializeInstruction(o *utils.OutputBuf, i *Instruction, field field.Field) {
@@ -80,9 +83,9 @@ func serializeRootCircuit(o *utils.OutputBuf, c *RootCircuit, field field.Field)
 	o.AppendUint64(uint64(c.NumPublicInputs))
 	o.AppendUint64(uint64(c.ExpectedNumOutputZeroes))
 	o.AppendUint64(uint64(len(c.Circuits)))
-	for k, c := range c.Circuits {
+	for _, k := range c.CircuitIds() {
 		o.AppendUint64(uint64(k))
-		serializeCircuit(o, c, field)
+		serializeCircuit(o, c.Circuits[k], field)
 	}
 }
 
@@ -92,3 +95,91 @@ func SerializeRootCircuit(c *RootCircuit) []byte {
 	serializeRootCircuit(o, c, c.Field)
 	return o.Bytes()
 }
+
+// StructuralHash returns a hash of the body of the circuit. Variable ids inside a circuit are
+// assigned sequentially, so two circuits built by the same sequence of operations share the same hash.
+func (c *Circuit) StructuralHash(field field.Field) [32]byte {
+	o := &utils.OutputBuf{}
+	serializeCircuit(o, c, field)
+	return sha256.Sum256(o.Bytes())
+}
+
+func deserializeInstruction(i *utils.InputBuf, field field.Field) Instruction {
+	ins := Instruction{Type: InstructionType(i.ReadUint8())}
+	switch ins.Type {
+	case LinComb:
+		n := i.ReadUint64()
+		ins.Inputs = make([]int, n)
+		for j := range ins.Inputs {
+			ins.Inputs[j] = int(i.ReadUint64())
+		}
+		ins.LinCombCoef = make([]constraint.Element, n)
+		for j := range ins.LinCombCoef {
+			ins.LinCombCoef[j] = i.ReadFieldElement(field)
+		}
+		ins.Const = i.ReadFieldElement(field)
+	case Mul, Commit:
+		ins.Inputs = i.ReadIntSlice()
+	case Div, BoolBinOp:
+		ins.X = int(i.ReadUint64())
+		ins.Y = int(i.ReadUint64())
+		ins.ExtraId = uint64(i.ReadUint8())
+	case IsZero:
+		ins.X = int(i.ReadUint64())
+	case Hint, SubCircuitCall:
+		ins.ExtraId = i.ReadUint64()
+		ins.Inputs = i.ReadIntSlice()
+		ins.NumOutputs = int(i.ReadUint64())
+	case ConstantLike:
+		switch i.ReadUint8() {
+		case 1:
+			ins.Const = i.ReadFieldElement(field)
+		case 2:
+			ins.ExtraId = 1
+		default:
+			ins.ExtraId = 2 + i.ReadUint64()
+		}
+	case CustomGate:
+		ins.ExtraId = i.ReadUint64()
+		ins.Inputs = i.ReadIntSlice()
+	default:
+		panic("invalid binary format")
+	}
+	return ins
+}
+
+func deserializeCircuit(i *utils.InputBuf, field field.Field) *Circuit {
+	c := &Circuit{}
+	c.Instructions = make([]Instruction, i.ReadUint64())
+	for j := range c.Instructions {
+		c.Instructions[j] = deserializeInstruction(i, field)
+	}
+	c.Constraints = make([]Constraint, i.ReadUint64())
+	for j := range c.Constraints {
+		c.Constraints[j].Typ = ConstraintType(i.ReadUint8())
+		c.Constraints[j].Var = int(i.ReadUint64())
+	}
+	c.Outputs = i.ReadIntSlice()
+	c.NumInputs = int(i.ReadUint64())
+	return c
+}
+
+// DeserializeRootCircuit reads a circuit written by SerializeRootCircuit. The source locations
+// aren't serialized, so they are all unknown in the result.
+func DeserializeRootCircuit(buf []byte) *RootCircuit {
+	i := utils.NewInputBuf(buf)
+	field := field.GetFieldById(i.ReadUint64())
+	rc := &RootCircuit{Field: field, Circuits: make(map[uint64]*Circuit)}
+	rc.NumPublicInputs = int(i.ReadUint64())
+	rc.ExpectedNumOutputZeroes = int(i.ReadUint64())
+	n := i.ReadUint64()
+	for j := uint64(0); j < n; j++ {
+		k := i.ReadUint64()
+		rc.Circuits[k] = deserializeCircuit(i, field)
+	}
+	if !i.IsEnd() {
+		panic("invalid binary format")
+	}
+	rc.Locations = []SourceLocation{nil}
+	return rc
+}
@@ -0,0 +1,22 @@
+package irsource
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSerializeRoundTrip(t *testing.T) {
+	rc := sampleRootCircuit()
+	buf := SerializeRootCircuit(rc)
+	rc2 := DeserializeRootCircuit(buf)
+	if !bytes.Equal(SerializeRootCircuit(rc2), buf) {
+		t.Fatal("deserialized circuit differs from the original one")
+	}
+	in := rc2.Circuits[0].Instructions[4]
+	if in.X != 3 || in.Y != 4 || in.ExtraId != 1 || rc2.Circuits[0].Constraints[1].Typ != Bool {
+		t.Fatalf("unexpected instruction %+v", in)
+	}
+	if len(rc2.Location(rc2.Circuits[0].Instructions[0].Loc)) != 0 {
+		t.Fatal("expected the source locations to be unknown")
+	}
+}
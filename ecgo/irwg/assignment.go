@@ -0,0 +1,152 @@
+package irwg
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// ParseAssignmentsJSON parses assignments of the circuit returned by newCircuit from JSON, so
+// that they can be prepared without Go, e.g. by a Python script. The JSON is an object, or an
+// array of objects for several assignments, mapping the full name of each variable of the
+// circuit, as returned by schema.LeafInfo.FullName (e.g. "X" or "Hash_3"), to its value: a
+// number, or a string in any base accepted by big.Int.SetString with base 0.
+func ParseAssignmentsJSON(newCircuit func() frontend.Circuit, buf []byte) ([]frontend.Circuit, error) {
+	var objects []map[string]json.RawMessage
+	var single map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &single); err == nil {
+		objects = append(objects, single)
+	} else if err := json.Unmarshal(buf, &objects); err != nil {
+		return nil, fmt.Errorf("parse assignment: %w", err)
+	}
+	res := make([]frontend.Circuit, len(objects))
+	for i, raw := range objects {
+		values := make(map[string]string, len(raw))
+		for name, v := range raw {
+			s, err := jsonValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("assignment %d: value of %s: %w", i, name, err)
+			}
+			values[name] = s
+		}
+		a, err := newAssignment(newCircuit, values)
+		if err != nil {
+			return nil, fmt.Errorf("assignment %d: %w", i, err)
+		}
+		res[i] = a
+	}
+	return res, nil
+}
+
+// ParseAssignmentsCSV parses assignments of the circuit returned by newCircuit from CSV, with a
+// header row holding the full names of the variables, like ParseAssignmentsJSON, and a row of
+// values for each assignment.
+func ParseAssignmentsCSV(newCircuit func() frontend.Circuit, r io.Reader) ([]frontend.Circuit, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse assignment: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("parse assignment: missing header row")
+	}
+	header := rows[0]
+	res := make([]frontend.Circuit, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		values := make(map[string]string, len(header))
+		for j, name := range header {
+			values[strings.TrimSpace(name)] = strings.TrimSpace(row[j])
+		}
+		a, err := newAssignment(newCircuit, values)
+		if err != nil {
+			return nil, fmt.Errorf("assignment %d: %w", i, err)
+		}
+		res = append(res, a)
+	}
+	return res, nil
+}
+
+// ReadAssignmentsFile reads assignments of the circuit returned by newCircuit from a CSV file if
+// its extension is .csv, or from a JSON file otherwise.
+func ReadAssignmentsFile(newCircuit func() frontend.Circuit, path string) ([]frontend.Circuit, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return ParseAssignmentsCSV(newCircuit, bytes.NewReader(buf))
+	}
+	return ParseAssignmentsJSON(newCircuit, buf)
+}
+
+// SolveInputFile solves the witnesses of the assignments of the file, read by
+// ReadAssignmentsFile, like SolveInputs.
+func (rc *RootCircuit) SolveInputFile(newCircuit func() frontend.Circuit, path string) (*Witness, error) {
+	assignments, err := ReadAssignmentsFile(newCircuit, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(assignments) == 1 {
+		return rc.SolveInputAuto(assignments[0])
+	}
+	return rc.SolveInputs(assignments)
+}
+
+// newAssignment returns the circuit of newCircuit with the given values, by full name
+func newAssignment(newCircuit func() frontend.Circuit, values map[string]string) (frontend.Circuit, error) {
+	assignment := newCircuit()
+	unknown := make(map[string]bool)
+	for name := range values {
+		unknown[name] = true
+	}
+	_, err := schema.Walk(assignment, TVariable, func(f schema.LeafInfo, tInput reflect.Value) error {
+		name := f.FullName()
+		s, ok := values[name]
+		if !ok || s == "" {
+			return fmt.Errorf("missing value of %s", name)
+		}
+		delete(unknown, name)
+		v, ok := new(big.Int).SetString(s, 0)
+		if !ok {
+			return fmt.Errorf("value of %s: invalid integer %q", name, s)
+		}
+		tInput.Set(reflect.ValueOf(v))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(unknown) != 0 {
+		names := make([]string, 0, len(unknown))
+		for name := range unknown {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown variables %s", strings.Join(names, ", "))
+	}
+	return assignment, nil
+}
+
+// jsonValue returns the digits of a JSON number or the content of a JSON string
+func jsonValue(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return "", errors.New("expected a number or a string")
+		}
+		s = n.String()
+	}
+	return s, nil
+}
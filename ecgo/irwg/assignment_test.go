@@ -0,0 +1,64 @@
+package irwg
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+)
+
+func newPublicTestCircuit() frontend.Circuit {
+	return &publicTestCircuit{}
+}
+
+func TestParseAssignments(t *testing.T) {
+	res, err := ParseAssignmentsJSON(newPublicTestCircuit, []byte(`[{"X": 3, "Y": "0x5"}, {"X": "7", "Y": 1}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].(*publicTestCircuit).Y.(*big.Int).Int64() != 5 || res[1].(*publicTestCircuit).X.(*big.Int).Int64() != 7 {
+		t.Fatalf("unexpected assignments %v", res)
+	}
+
+	res, err = ParseAssignmentsCSV(newPublicTestCircuit, strings.NewReader("Y, X\n5, 3\n1,0x7\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].(*publicTestCircuit).Y.(*big.Int).Int64() != 5 || res[1].(*publicTestCircuit).X.(*big.Int).Int64() != 7 {
+		t.Fatalf("unexpected assignments %v", res)
+	}
+	for input, msg := range map[string]string{
+		"X,Y\n3,\n":      "assignment 0: missing value of Y",
+		"X,Y,Z\n3,1,2\n": "unknown variables Z",
+		"X,Y\n3,1\n2\n":  "wrong number of fields",
+		"X,Y\n3,three\n": `invalid integer "three"`,
+	} {
+		if _, err := ParseAssignmentsCSV(newPublicTestCircuit, strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("expected %q for %q, got %v", msg, input, err)
+		}
+	}
+}
+
+func TestSolveInputFile(t *testing.T) {
+	solver.RegisterHint(squareHint)
+	rc := hintRootCircuit(uint64(solver.GetHintID(squareHint)))
+	newCircuit := func() frontend.Circuit { return &hintTestCircuit{} }
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.json": `{"X": 3}`, "a.csv": "X\n3\n4\n"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		w, err := rc.SolveInputFile(newCircuit, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w.Values[0].Int64() != 3 || w.Values[1].Int64() != 9 || w.NumWitnesses != len(w.Values)/2 {
+			t.Fatalf("unexpected witness of %s: %v", name, w.Values)
+		}
+	}
+}
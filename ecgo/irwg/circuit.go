@@ -1,6 +1,10 @@
 package irwg
 
-import "github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+import (
+	"sort"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+)
 
 type Circuit struct {
 	Instructions []Instruction
@@ -14,4 +18,26 @@ type RootCircuit struct {
 	ExpectedNumOutputZeroes int
 	Circuits                map[uint64]*Circuit
 	Field                   field.Field
+
+	// PublicInputOrder is the layout of the public inputs: slot i holds the public input declared
+	// at position PublicInputOrder[i] of the assignment. If nil, the declaration order is used.
+	PublicInputOrder []int
+
+	// CircuitHash is the content hash of the layered circuit compiled along with this solver, if
+	// known. It's copied to the solved witnesses.
+	CircuitHash []byte
+
+	// HintPolicy bounds and restricts the hints run while solving inputs. If nil, every
+	// registered hint runs without a time limit.
+	HintPolicy *HintPolicy
+}
+
+// CircuitIds returns the ids of the circuits in increasing order.
+func (rc *RootCircuit) CircuitIds() []uint64 {
+	ids := make([]uint64, 0, len(rc.Circuits))
+	for id := range rc.Circuits {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
 }
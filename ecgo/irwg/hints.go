@@ -0,0 +1,120 @@
+package irwg
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint/solver"
+)
+
+// ErrHintNotAllowed is returned when solving inputs calls a hint which isn't allowed by the
+// HintPolicy of the solver.
+var ErrHintNotAllowed = errors.New("hint not allowed")
+
+// ErrHintTimeout is returned when a hint runs longer than the Timeout of the HintPolicy.
+var ErrHintTimeout = errors.New("hint timed out")
+
+// HintPolicy bounds and restricts the hints called while solving inputs. Hints run arbitrary Go
+// code registered in the process, and an input solver may come from an untrusted circuit
+// definition: it can call any registered hint, with any inputs. A policy restricts it to the
+// hints expected from the circuit, and stops waiting for hints which don't terminate.
+//
+// Hints which panic are reported as errors whether there's a policy or not.
+type HintPolicy struct {
+	// Timeout is the maximum duration of each hint call, or 0 for no limit. Go code can't be
+	// interrupted, so a hint timing out keeps running in the background on copies of its inputs
+	// and outputs, while the solving fails with ErrHintTimeout.
+	Timeout time.Duration
+	// Allowed is the set of hints which may be called, see Allow. If nil, every registered hint
+	// is allowed. The builtin division hint is always allowed.
+	Allowed map[solver.HintID]bool
+}
+
+// Allow adds hints to the allowed hints of the policy.
+func (p *HintPolicy) Allow(hints ...solver.Hint) {
+	if p.Allowed == nil {
+		p.Allowed = make(map[solver.HintID]bool)
+	}
+	for _, h := range hints {
+		p.Allowed[solver.GetHintID(h)] = true
+	}
+}
+
+// callHint runs the hint of the given id according to the policy of the solver
+func (rc *RootCircuit) callHint(hintId uint64, field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	// The only required builtin hint (Div)
+	if hintId == irsource.DivHintId {
+		if len(inputs) != 2 || len(outputs) != 1 {
+			return errors.New("Div hint requires 2 inputs and 1 output")
+		}
+		x := (&big.Int{}).Mod(inputs[0], field)
+		y := (&big.Int{}).Mod(inputs[1], field)
+		if y.Cmp(big.NewInt(0)) == 0 {
+			outputs[0] = big.NewInt(0)
+			return nil
+		}
+		a := (&big.Int{}).ModInverse(y, field)
+		a.Mul(a, x)
+		a.Mod(a, field)
+		outputs[0] = a
+		return nil
+	}
+	p := rc.HintPolicy
+	if p != nil && p.Allowed != nil && !p.Allowed[solver.HintID(hintId)] {
+		return fmt.Errorf("hint %d: %w", hintId, ErrHintNotAllowed)
+	}
+	hint := solver.GetRegisteredHint(solver.HintID(hintId))
+	if hint == nil {
+		return fmt.Errorf("hint %d is not registered, please register it with solver.RegisterHint", hintId)
+	}
+	var err error
+	if p == nil || p.Timeout <= 0 {
+		err = runHint(hint, field, inputs, outputs)
+	} else {
+		err = runHintWithTimeout(hint, field, inputs, outputs, p.Timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("hint %s: %w", solver.GetHintName(hint), err)
+	}
+	return nil
+}
+
+// runHint calls hint, converting a panic to an error
+func runHint(hint solver.Hint, field *big.Int, inputs []*big.Int, outputs []*big.Int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return hint(field, inputs, outputs)
+}
+
+// runHintWithTimeout calls hint like runHint, and gives up after timeout. The hint works on
+// copies of its arguments, so that it can't modify them once the solving moved on.
+func runHintWithTimeout(hint solver.Hint, field *big.Int, inputs []*big.Int, outputs []*big.Int, timeout time.Duration) error {
+	f := new(big.Int).Set(field)
+	in := make([]*big.Int, len(inputs))
+	for i, x := range inputs {
+		in[i] = new(big.Int).Set(x)
+	}
+	out := make([]*big.Int, len(outputs))
+	for i := range out {
+		out[i] = new(big.Int)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- runHint(hint, f, in, out)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		copy(outputs, out)
+		return err
+	case <-timer.C:
+		return fmt.Errorf("%w after %s", ErrHintTimeout, timeout)
+	}
+}
@@ -0,0 +1,138 @@
+package irwg
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// PublicWitness is the part of a Witness seen by verifiers: the public inputs of each witness, in
+// the order of their slots in the circuit, without the secret inputs.
+type PublicWitness struct {
+	NumWitnesses              int
+	NumPublicInputsPerWitness int
+	Field                     *big.Int
+	Values                    []*big.Int
+
+	// CircuitHash is the content hash of the layered circuit, as in Witness.
+	CircuitHash []byte
+}
+
+// SolvePublicInputs extracts the public inputs of the assignments, in slot order, without solving
+// the secret inputs: the secret variables of the assignments may be left unset, and no hint is
+// called.
+func (rc *RootCircuit) SolvePublicInputs(assignments ...frontend.Circuit) (*PublicWitness, error) {
+	res := &PublicWitness{
+		NumWitnesses:              len(assignments),
+		NumPublicInputsPerWitness: rc.NumPublicInputs,
+		Field:                     rc.Field.Field(),
+		CircuitHash:               rc.CircuitHash,
+	}
+	for i, assignment := range assignments {
+		vecPub, err := getPublicVariables(assignment, rc.Field)
+		if err == nil {
+			vecPub, err = rc.orderPublicInputs(vecPub)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("assignment %d: %w", i, err)
+		}
+		if len(vecPub) != rc.NumPublicInputs {
+			return nil, fmt.Errorf("assignment %d: expected %d public inputs, got %d", i, rc.NumPublicInputs, len(vecPub))
+		}
+		for _, x := range vecPub {
+			res.Values = append(res.Values, rc.Field.ToBigInt(x))
+		}
+	}
+	return res, nil
+}
+
+// getPublicVariables returns the values of the public variables of the assignment, in declaration
+// order
+func getPublicVariables(assignment frontend.Circuit, field field.Field) ([]constraint.Element, error) {
+	res := []constraint.Element{}
+	_, err := schema.Walk(assignment, TVariable, func(leaf schema.LeafInfo, tValue reflect.Value) error {
+		if leaf.Visibility != schema.Public {
+			return nil
+		}
+		if tValue.IsNil() {
+			return fmt.Errorf("missing assignment of %s", leaf.FullName())
+		}
+		res = append(res, field.FromInterface(tValue.Interface()))
+		return nil
+	})
+	return res, err
+}
+
+// Public returns the public inputs of the witnesses of w.
+func (w *Witness) Public() *PublicWitness {
+	a := w.NumInputsPerWitness
+	b := w.NumPublicInputsPerWitness
+	res := &PublicWitness{
+		NumWitnesses:              w.NumWitnesses,
+		NumPublicInputsPerWitness: b,
+		Field:                     w.Field,
+		Values:                    make([]*big.Int, 0, w.NumWitnesses*b),
+		CircuitHash:               w.CircuitHash,
+	}
+	for i := 0; i < w.NumWitnesses; i++ {
+		res.Values = append(res.Values, w.Values[i*(a+b)+a:(i+1)*(a+b)]...)
+	}
+	return res
+}
+
+// Inputs returns the public inputs of each witness.
+func (w *PublicWitness) Inputs() [][]*big.Int {
+	b := w.NumPublicInputsPerWitness
+	res := make([][]*big.Int, w.NumWitnesses)
+	for i := range res {
+		res[i] = w.Values[i*b : (i+1)*b]
+	}
+	return res
+}
+
+// Serialize converts the public witness into a byte slice. The encoding is the one of
+// Witness.Serialize without the secret inputs: NumWitnesses and NumPublicInputsPerWitness as
+// little-endian uint64, the field modulus on 32 bytes, then all values as little-endian field
+// elements, followed by the circuit hash if it's known.
+func (w *PublicWitness) Serialize() []byte {
+	o := utils.OutputBuf{}
+	o.AppendUint64(uint64(w.NumWitnesses))
+	o.AppendUint64(uint64(w.NumPublicInputsPerWitness))
+	o.AppendBigInt(32, w.Field)
+	bnlen := field.GetFieldFromOrder(w.Field).SerializedLen()
+	for _, x := range w.Values {
+		o.AppendBigInt(bnlen, x)
+	}
+	if w.CircuitHash != nil {
+		o.AppendBytes(w.CircuitHash)
+	}
+	return o.Bytes()
+}
+
+// DeserializePublicWitness reads a PublicWitness produced by Serialize.
+func DeserializePublicWitness(buf []byte) *PublicWitness {
+	i := utils.NewInputBuf(buf)
+	w := &PublicWitness{}
+	w.NumWitnesses = int(i.ReadUint64())
+	w.NumPublicInputsPerWitness = int(i.ReadUint64())
+	w.Field = i.ReadBigInt(32)
+	bnlen := field.GetFieldFromOrder(w.Field).SerializedLen()
+	n := w.NumWitnesses * w.NumPublicInputsPerWitness
+	w.Values = make([]*big.Int, n)
+	for j := 0; j < n; j++ {
+		w.Values[j] = i.ReadBigInt(bnlen)
+	}
+	if !i.IsEnd() {
+		w.CircuitHash = i.ReadBytes(CircuitHashLen)
+	}
+	if !i.IsEnd() {
+		panic("invalid binary format")
+	}
+	return w
+}
@@ -0,0 +1,56 @@
+package irwg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+)
+
+func TestSolvePublicInputs(t *testing.T) {
+	rc := &RootCircuit{
+		Circuits: map[uint64]*Circuit{
+			0: {
+				Instructions: []Instruction{
+					{Type: ConstantLike, ExtraId: 2},
+				},
+				Outputs: []int{1},
+			},
+		},
+		Field:            &m31.Field{},
+		NumPublicInputs:  2,
+		PublicInputOrder: []int{1, 0},
+		CircuitHash:      bytes.Repeat([]byte{7}, CircuitHashLen),
+	}
+	pw, err := rc.SolvePublicInputs(&publicTestCircuit{X: 3, Y: 5}, &publicTestCircuit{X: 4, Y: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := pw.Inputs()
+	if len(inputs) != 2 || inputs[0][0].Int64() != 5 || inputs[0][1].Int64() != 3 || inputs[1][0].Int64() != 6 || inputs[1][1].Int64() != 4 {
+		t.Fatalf("unexpected public inputs %v", inputs)
+	}
+
+	w, err := rc.SolveInputs([]frontend.Circuit{&publicTestCircuit{X: 3, Y: 5}, &publicTestCircuit{X: 4, Y: 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w.Public().Serialize(), pw.Serialize()) {
+		t.Fatal("expected the public part of the witness to match")
+	}
+
+	pw2 := DeserializePublicWitness(pw.Serialize())
+	if pw2.NumWitnesses != 2 || pw2.NumPublicInputsPerWitness != 2 || pw2.Field.Cmp(m31.ScalarField) != 0 || !bytes.Equal(pw2.CircuitHash, rc.CircuitHash) {
+		t.Fatal("public witness header mismatch")
+	}
+	for i := range pw.Values {
+		if pw.Values[i].Cmp(pw2.Values[i]) != 0 {
+			t.Fatalf("value %d mismatch", i)
+		}
+	}
+
+	if _, err := rc.SolvePublicInputs(&publicTestCircuit{X: 3}); err == nil {
+		t.Fatal("expected an error for an unset public input")
+	}
+}
@@ -59,9 +59,9 @@ func serializeCircuit(o *utils.OutputBuf, c *Circuit, field field.Field) {
 
 func serializeRootCircuit(o *utils.OutputBuf, c *RootCircuit, field field.Field) {
 	o.AppendUint64(uint64(len(c.Circuits)))
-	for k, c := range c.Circuits {
+	for _, k := range c.CircuitIds() {
 		o.AppendUint64(uint64(k))
-		serializeCircuit(o, c, field)
+		serializeCircuit(o, c.Circuits[k], field)
 	}
 }
 
@@ -71,6 +71,14 @@ func SerializeRootCircuit(c *RootCircuit) []byte {
 	o.AppendUint64(uint64(c.NumPublicInputs))
 	o.AppendUint64(uint64(c.ExpectedNumOutputZeroes))
 	serializeRootCircuit(o, c, c.Field)
+	// the layout and the hash are only written when set, so that the default encoding stays the
+	// one of the Rust compiler
+	if c.PublicInputOrder != nil || c.CircuitHash != nil {
+		o.AppendIntSlice(c.PublicInputOrder)
+	}
+	if c.CircuitHash != nil {
+		o.AppendBytes(c.CircuitHash)
+	}
 	return o.Bytes()
 }
 
@@ -152,6 +160,15 @@ func DeserializeRootCircuit(buf []byte) *RootCircuit {
 	fieldId := i.ReadUint64()
 	field := field.GetFieldById(fieldId)
 	rc := deserializeRootCircuit(field, i)
+	if !i.IsEnd() {
+		rc.PublicInputOrder = i.ReadIntSlice()
+		if len(rc.PublicInputOrder) == 0 {
+			rc.PublicInputOrder = nil
+		}
+	}
+	if !i.IsEnd() {
+		rc.CircuitHash = i.ReadBytes(CircuitHashLen)
+	}
 	if !i.IsEnd() {
 		panic("invalid binary format")
 	}
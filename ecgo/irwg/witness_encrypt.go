@@ -0,0 +1,92 @@
+package irwg
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+)
+
+// encryptedWitnessMagic starts the encrypted witnesses, which can't be mistaken for plaintext
+// ones since these start with the number of witnesses
+const encryptedWitnessMagic = "ECGOWENC\x01"
+
+// ErrWitnessKey is returned when an encrypted witness can't be decrypted with the given key,
+// because the key is wrong or the witness was altered.
+var ErrWitnessKey = errors.New("witness can't be decrypted with this key")
+
+// witnessAEAD returns AES-GCM with a key of 16, 24 or 32 bytes
+func witnessAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("witness key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// SerializeEncrypted serializes w like SerializeWithHash, then encrypts it with AES-GCM and a key of 16,
+// 24 or 32 bytes provided by the caller, so that the secret inputs aren't written in plaintext.
+// The result holds a magic header, a random nonce and the sealed witness, and is read by
+// DeserializeEncryptedWitness.
+func (w *Witness) SerializeEncrypted(key []byte) ([]byte, error) {
+	aead, err := witnessAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]byte, len(encryptedWitnessMagic)+aead.NonceSize())
+	copy(res, encryptedWitnessMagic)
+	nonce := res[len(encryptedWitnessMagic):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(res, nonce, w.SerializeWithHash(), []byte(encryptedWitnessMagic)), nil
+}
+
+// IsEncryptedWitness reports whether buf was produced by SerializeEncrypted.
+func IsEncryptedWitness(buf []byte) bool {
+	return bytes.HasPrefix(buf, []byte(encryptedWitnessMagic))
+}
+
+// DeserializeEncryptedWitness decrypts and reads a Witness produced by SerializeEncrypted. It
+// returns ErrWitnessKey if the key doesn't match.
+func DeserializeEncryptedWitness(buf []byte, key []byte) (w *Witness, err error) {
+	if !IsEncryptedWitness(buf) {
+		return nil, errors.New("not an encrypted witness")
+	}
+	aead, err := witnessAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	buf = buf[len(encryptedWitnessMagic):]
+	if len(buf) < aead.NonceSize() {
+		return nil, ErrWitnessKey
+	}
+	plain, err := aead.Open(nil, buf[:aead.NonceSize()], buf[aead.NonceSize():], []byte(encryptedWitnessMagic))
+	if err != nil {
+		return nil, ErrWitnessKey
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			w, err = nil, fmt.Errorf("decrypted witness: %v", r)
+		}
+	}()
+	return DeserializeWitness(plain), nil
+}
+
+// Redacted returns a copy of w with the secret inputs set to zero, keeping its shape and public
+// inputs, e.g. to attach a witness to a bug report. It can't be proven.
+func (w *Witness) Redacted() *Witness {
+	res := *w
+	res.Values = slices.Clone(w.Values)
+	n := w.NumInputsPerWitness + w.NumPublicInputsPerWitness
+	for i := 0; i < w.NumWitnesses; i++ {
+		for j := 0; j < w.NumInputsPerWitness; j++ {
+			res.Values[i*n+j] = new(big.Int)
+		}
+	}
+	return &res
+}
@@ -0,0 +1,70 @@
+package irwg
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+)
+
+func TestWitnessEncrypted(t *testing.T) {
+	w := &Witness{
+		NumWitnesses:              2,
+		NumInputsPerWitness:       2,
+		NumPublicInputsPerWitness: 1,
+		Field:                     m31.ScalarField,
+		Values:                    []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4), big.NewInt(5), big.NewInt(6)},
+		CircuitHash:               bytes.Repeat([]byte{7}, CircuitHashLen),
+	}
+	key := bytes.Repeat([]byte{1}, 32)
+	buf, err := w.SerializeEncrypted(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncryptedWitness(buf) || IsEncryptedWitness(w.Serialize()) {
+		t.Fatal("expected only the encrypted witness to be detected")
+	}
+	if bytes.Contains(buf, w.Serialize()[8*3+32:]) {
+		t.Fatal("expected the values to be encrypted")
+	}
+	w2, err := DeserializeEncryptedWitness(buf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w2.SerializeWithHash(), w.SerializeWithHash()) {
+		t.Fatal("round trip mismatch")
+	}
+
+	if _, err := DeserializeEncryptedWitness(buf, bytes.Repeat([]byte{2}, 32)); !errors.Is(err, ErrWitnessKey) {
+		t.Fatalf("expected a wrong key, got %v", err)
+	}
+	buf[len(buf)-1] ^= 1
+	if _, err := DeserializeEncryptedWitness(buf, key); !errors.Is(err, ErrWitnessKey) {
+		t.Fatalf("expected an altered witness to be rejected, got %v", err)
+	}
+	if _, err := w.SerializeEncrypted(key[:10]); err == nil {
+		t.Fatal("expected an invalid key size")
+	}
+}
+
+func TestWitnessRedacted(t *testing.T) {
+	w := &Witness{
+		NumWitnesses:              2,
+		NumInputsPerWitness:       2,
+		NumPublicInputsPerWitness: 1,
+		Field:                     m31.ScalarField,
+		Values:                    []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4), big.NewInt(5), big.NewInt(6)},
+	}
+	r := w.Redacted()
+	want := []int64{0, 0, 3, 0, 0, 6}
+	for i, v := range r.Values {
+		if v.Int64() != want[i] {
+			t.Fatalf("value %d: expected %d, got %s", i, want[i], v)
+		}
+	}
+	if w.Values[0].Int64() != 1 {
+		t.Fatal("expected the witness to be left unchanged")
+	}
+}
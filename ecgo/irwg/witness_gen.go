@@ -1,7 +1,9 @@
 package irwg
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"math/big"
 	"reflect"
 
@@ -9,7 +11,6 @@ import (
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils"
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/customgates"
 	"github.com/consensys/gnark/constraint"
-	"github.com/consensys/gnark/constraint/solver"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/schema"
 )
@@ -21,8 +22,18 @@ type Witness struct {
 	NumPublicInputsPerWitness int
 	Field                     *big.Int
 	Values                    []*big.Int
+
+	// CircuitHash is the content hash of the layered circuit the witness was solved for, see
+	// layered.RootCircuit.ContentHash. It's nil if the input solver doesn't know its circuit.
+	CircuitHash []byte
 }
 
+// CircuitHashLen is the length of circuit hashes.
+const CircuitHashLen = 32
+
+// ErrCircuitMismatch is returned by CheckCircuitHash when a witness was solved for another circuit.
+var ErrCircuitMismatch = errors.New("witness was solved for a different circuit")
+
 var TVariable reflect.Type
 
 func init() {
@@ -60,9 +71,28 @@ func GetCircuitVariables(assignment frontend.Circuit, field field.Field) ([]cons
 	return resPub, resSec
 }
 
-func (rc *RootCircuit) solveInput(assignment frontend.Circuit) ([]*big.Int, int, int, error) {
+func (rc *RootCircuit) solveInput(assignment frontend.Circuit, threads int) ([]*big.Int, int, int, error) {
+	return rc.solveInputWith(assignment, func(inputs, publicInputs []constraint.Element) ([]constraint.Element, error) {
+		if threads > 1 {
+			return rc.evalParallel(inputs, publicInputs, threads, &SolveStats{})
+		}
+		return rc.eval(inputs, publicInputs)
+	})
+}
+
+// solveInputWith solves the input of the assignment, evaluating the root circuit with eval
+func (rc *RootCircuit) solveInputWith(assignment frontend.Circuit, eval func(inputs, publicInputs []constraint.Element) ([]constraint.Element, error)) ([]*big.Int, int, int, error) {
 	vecPub, vecSec := GetCircuitVariables(assignment, rc.Field)
-	res, err := rc.eval(vecSec, vecPub)
+	return rc.solveValuesWith(vecSec, vecPub, eval)
+}
+
+// solveValuesWith solves the input of the secret and public input values, in declaration order
+func (rc *RootCircuit) solveValuesWith(vecSec, vecPub []constraint.Element, eval func(inputs, publicInputs []constraint.Element) ([]constraint.Element, error)) ([]*big.Int, int, int, error) {
+	vecPub, err := rc.orderPublicInputs(vecPub)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	res, err := eval(vecSec, vecPub)
 	if err != nil {
 		return nil, 0, 0, err
 	}
@@ -76,13 +106,34 @@ func (rc *RootCircuit) solveInput(assignment frontend.Circuit) ([]*big.Int, int,
 	return witness, len(res), len(vecPub), nil
 }
 
+// orderPublicInputs lays out the public inputs of an assignment, in declaration order, in their
+// slots, see PublicInputOrder
+func (rc *RootCircuit) orderPublicInputs(vecPub []constraint.Element) ([]constraint.Element, error) {
+	if rc.PublicInputOrder == nil {
+		return vecPub, nil
+	}
+	if len(rc.PublicInputOrder) != len(vecPub) {
+		return nil, fmt.Errorf("expected %d public inputs, got %d", len(rc.PublicInputOrder), len(vecPub))
+	}
+	ordered := make([]constraint.Element, len(vecPub))
+	for i, j := range rc.PublicInputOrder {
+		ordered[i] = vecPub[j]
+	}
+	return ordered, nil
+}
+
 // SolveInput is the entry point to solve the final input of the given assignment using a specified number of threads.
-func (rc *RootCircuit) SolveInput(assignment frontend.Circuit, _ int) (*Witness, error) {
-	return rc.SolveInputAuto(assignment)
+// With more than one thread, independent instructions of the root circuit are evaluated concurrently.
+func (rc *RootCircuit) SolveInput(assignment frontend.Circuit, threads int) (*Witness, error) {
+	return rc.solveInputWithThreads(assignment, threads)
 }
 
 func (rc *RootCircuit) SolveInputAuto(assignment frontend.Circuit) (*Witness, error) {
-	witness, lenSec, lenPub, err := rc.solveInput(assignment)
+	return rc.solveInputWithThreads(assignment, 1)
+}
+
+func (rc *RootCircuit) solveInputWithThreads(assignment frontend.Circuit, threads int) (*Witness, error) {
+	witness, lenSec, lenPub, err := rc.solveInput(assignment, threads)
 	if err != nil {
 		return nil, err
 	}
@@ -92,6 +143,35 @@ func (rc *RootCircuit) SolveInputAuto(assignment frontend.Circuit) (*Witness, er
 		NumPublicInputsPerWitness: lenPub,
 		Field:                     rc.Field.Field(),
 		Values:                    witness,
+		CircuitHash:               rc.CircuitHash,
+	}, nil
+}
+
+// SolveInputValues solves the input of the values of the secret and public variables of an
+// assignment, each in declaration order, like GetCircuitVariables returns them. It solves
+// witnesses without the Go type of the circuit, e.g. in the WebAssembly solver of cmd/solver-wasm.
+func (rc *RootCircuit) SolveInputValues(secret, public []*big.Int) (*Witness, error) {
+	root, ok := rc.Circuits[0]
+	if !ok {
+		return nil, errors.New("missing root circuit")
+	}
+	if len(secret) != root.NumInputs {
+		return nil, fmt.Errorf("expected %d secret inputs, got %d", root.NumInputs, len(secret))
+	}
+	if len(public) != rc.NumPublicInputs {
+		return nil, fmt.Errorf("expected %d public inputs, got %d", rc.NumPublicInputs, len(public))
+	}
+	witness, lenSec, lenPub, err := rc.solveValuesWith(rc.toElements(secret), rc.toElements(public), rc.eval)
+	if err != nil {
+		return nil, err
+	}
+	return &Witness{
+		NumWitnesses:              1,
+		NumInputsPerWitness:       lenSec,
+		NumPublicInputsPerWitness: lenPub,
+		Field:                     rc.Field.Field(),
+		Values:                    witness,
+		CircuitHash:               rc.CircuitHash,
 	}, nil
 }
 
@@ -102,7 +182,7 @@ func (rc *RootCircuit) SolveInputs(assignments []frontend.Circuit) (*Witness, er
 	lenSec := 0
 	lenPub := 0
 	for _, assignment := range assignments {
-		witness, lenSec, lenPub, err = rc.solveInput(assignment)
+		witness, lenSec, lenPub, err = rc.solveInput(assignment, 1)
 		if err != nil {
 			return nil, err
 		}
@@ -114,6 +194,7 @@ func (rc *RootCircuit) SolveInputs(assignments []frontend.Circuit) (*Witness, er
 		NumPublicInputsPerWitness: lenPub,
 		Field:                     rc.Field.Field(),
 		Values:                    witnesses,
+		CircuitHash:               rc.CircuitHash,
 	}, nil
 }
 
@@ -123,65 +204,11 @@ func (rc *RootCircuit) eval(inputs []constraint.Element, publicInputs []constrai
 
 func (rc *RootCircuit) evalSub(circuitId uint64, inputs []constraint.Element, publicInputs []constraint.Element) ([]constraint.Element, error) {
 	values := append([]constraint.Element{{}}, inputs...)
-	for _, insn := range rc.Circuits[circuitId].Instructions {
-		switch insn.Type {
-		case LinComb:
-			res := insn.Const
-			for i, x := range insn.Inputs {
-				res = rc.Field.Add(res, rc.Field.Mul(values[x], insn.LinCombCoef[i]))
-			}
-			values = append(values, res)
-		case Mul:
-			res := rc.Field.One()
-			for _, x := range insn.Inputs {
-				res = rc.Field.Mul(res, values[x])
-			}
-			values = append(values, res)
-		case Hint:
-			hint_inputs := []*big.Int{}
-			for _, x := range insn.Inputs {
-				hint_inputs = append(hint_inputs, rc.Field.ToBigInt(values[x]))
-			}
-			hint_outputs := make([]*big.Int, insn.NumOutputs)
-			for i := range hint_outputs {
-				hint_outputs[i] = big.NewInt(0)
-			}
-			err := callHint(insn.ExtraId, rc.Field.Field(), hint_inputs, hint_outputs)
-			if err != nil {
-				return nil, err
-			}
-			for _, x := range hint_outputs {
-				values = append(values, rc.Field.FromInterface(x))
-			}
-		case ConstantLike:
-			if insn.ExtraId == 0 {
-				values = append(values, insn.Const)
-			} else if insn.ExtraId == 1 {
-				return nil, errors.New("random constant not supported")
-			} else {
-				values = append(values, publicInputs[insn.ExtraId-2])
-			}
-		case SubCircuitCall:
-			sub_inputs := []constraint.Element{}
-			for _, x := range insn.Inputs {
-				sub_inputs = append(sub_inputs, values[x])
-			}
-			sub_outputs, err := rc.evalSub(insn.ExtraId, sub_inputs, publicInputs)
-			if err != nil {
-				return nil, err
-			}
-			values = append(values, sub_outputs...)
-		case CustomGate:
-			custom_inputs := []*big.Int{}
-			for _, x := range insn.Inputs {
-				custom_inputs = append(custom_inputs, rc.Field.ToBigInt(values[x]))
-			}
-			custom_outputs := make([]*big.Int, 1)
-			err := customgates.GetFunc(insn.ExtraId)(rc.Field.Field(), custom_inputs, custom_outputs)
-			if err != nil {
-				return nil, err
-			}
-			values = append(values, rc.Field.FromInterface(custom_outputs[0]))
+	var err error
+	for i := range rc.Circuits[circuitId].Instructions {
+		values, err = rc.evalInstruction(&rc.Circuits[circuitId].Instructions[i], values, values, publicInputs)
+		if err != nil {
+			return nil, err
 		}
 	}
 	outputs := []constraint.Element{}
@@ -191,28 +218,80 @@ func (rc *RootCircuit) evalSub(circuitId uint64, inputs []constraint.Element, pu
 	return outputs, nil
 }
 
-func callHint(hintId uint64, field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
-	// The only required builtin hint (Div)
-	if hintId == 0xCCC000000001 {
-		if len(inputs) != 2 || len(outputs) != 1 {
-			return errors.New("Div hint requires 2 inputs and 1 output")
+// evalInstruction evaluates insn, whose operands are read from values, and appends its outputs to dst.
+func (rc *RootCircuit) evalInstruction(insn *Instruction, values []constraint.Element, dst []constraint.Element, publicInputs []constraint.Element) ([]constraint.Element, error) {
+	switch insn.Type {
+	case LinComb:
+		res := insn.Const
+		for i, x := range insn.Inputs {
+			res = rc.Field.Add(res, rc.Field.Mul(values[x], insn.LinCombCoef[i]))
 		}
-		x := (&big.Int{}).Mod(inputs[0], field)
-		y := (&big.Int{}).Mod(inputs[1], field)
-		if y.Cmp(big.NewInt(0)) == 0 {
-			outputs[0] = big.NewInt(0)
-			return nil
+		dst = append(dst, res)
+	case Mul:
+		res := rc.Field.One()
+		for _, x := range insn.Inputs {
+			res = rc.Field.Mul(res, values[x])
+		}
+		dst = append(dst, res)
+	case Hint:
+		hint_inputs := []*big.Int{}
+		for _, x := range insn.Inputs {
+			hint_inputs = append(hint_inputs, rc.Field.ToBigInt(values[x]))
+		}
+		hint_outputs := make([]*big.Int, insn.NumOutputs)
+		for i := range hint_outputs {
+			hint_outputs[i] = big.NewInt(0)
+		}
+		err := rc.callHint(insn.ExtraId, rc.Field.Field(), hint_inputs, hint_outputs)
+		if err != nil {
+			return nil, err
+		}
+		for _, x := range hint_outputs {
+			if x == nil {
+				return nil, fmt.Errorf("hint %d returned a nil output", insn.ExtraId)
+			}
+			dst = append(dst, rc.Field.FromInterface(x))
+		}
+	case ConstantLike:
+		if insn.ExtraId == 0 {
+			dst = append(dst, insn.Const)
+		} else if insn.ExtraId == 1 {
+			return nil, errors.New("random constant not supported")
+		} else {
+			dst = append(dst, publicInputs[insn.ExtraId-2])
+		}
+	case SubCircuitCall:
+		sub_inputs := []constraint.Element{}
+		for _, x := range insn.Inputs {
+			sub_inputs = append(sub_inputs, values[x])
+		}
+		sub_outputs, err := rc.evalSub(insn.ExtraId, sub_inputs, publicInputs)
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, sub_outputs...)
+	case CustomGate:
+		custom_inputs := []*big.Int{}
+		for _, x := range insn.Inputs {
+			custom_inputs = append(custom_inputs, rc.Field.ToBigInt(values[x]))
+		}
+		custom_outputs := make([]*big.Int, 1)
+		err := customgates.GetFunc(insn.ExtraId)(rc.Field.Field(), custom_inputs, custom_outputs)
+		if err != nil {
+			return nil, err
 		}
-		a := (&big.Int{}).ModInverse(y, field)
-		a.Mul(a, x)
-		a.Mod(a, field)
-		outputs[0] = a
-		return nil
+		dst = append(dst, rc.Field.FromInterface(custom_outputs[0]))
 	}
-	return solver.GetRegisteredHint(solver.HintID(hintId))(field, inputs, outputs)
+	return dst, nil
 }
 
 // Serialize converts the Witness into a byte slice for storage or transmission.
+//
+// The encoding is the one read by the Expander prover: NumWitnesses, NumInputsPerWitness and
+// NumPublicInputsPerWitness as little-endian uint64, the field modulus on 32 bytes, then all
+// values as little-endian field elements. Values are grouped by witness, and within a witness
+// the secret inputs of the input layer come first, followed by the public inputs. The circuit hash
+// isn't included, see SerializeWithHash.
 func (w *Witness) Serialize() []byte {
 	o := utils.OutputBuf{}
 	o.AppendUint64(uint64(w.NumWitnesses))
@@ -225,3 +304,83 @@ func (w *Witness) Serialize() []byte {
 	}
 	return o.Bytes()
 }
+
+// SerializeWithHash serializes the witness like Serialize, followed by the circuit hash if it's
+// known, so that DeserializeWitness restores it for CheckCircuitHash. The Expander prover doesn't
+// read the hash: the witnesses passed to it are serialized with Serialize.
+func (w *Witness) SerializeWithHash() []byte {
+	buf := w.Serialize()
+	if w.CircuitHash != nil {
+		buf = append(buf, w.CircuitHash...)
+	}
+	return buf
+}
+
+// CheckCircuitHash returns ErrCircuitMismatch if the witness was solved for a circuit with
+// another content hash. Witnesses without a circuit hash are accepted.
+func (w *Witness) CheckCircuitHash(hash [32]byte) error {
+	if w.CircuitHash != nil && !bytes.Equal(w.CircuitHash, hash[:]) {
+		return ErrCircuitMismatch
+	}
+	return nil
+}
+
+// ReplicateInputs groups the witnesses of w by n, into the witnesses of the circuit made of n
+// copies by layered.RootCircuit.Replicate: the inputs of copy k start at k times
+// NumInputsPerWitness, padded to a power of 2, and its public inputs at k times
+// NumPublicInputsPerWitness. The circuit hash is dropped, since the witnesses are for another
+// circuit.
+func ReplicateInputs(w *Witness, n int) (*Witness, error) {
+	if n <= 0 || w.NumWitnesses%n != 0 {
+		return nil, fmt.Errorf("can't group %d witnesses by %d", w.NumWitnesses, n)
+	}
+	a := w.NumInputsPerWitness
+	b := w.NumPublicInputsPerWitness
+	numInputs := 1
+	for numInputs < n*a {
+		numInputs *= 2
+	}
+	res := &Witness{
+		NumWitnesses:              w.NumWitnesses / n,
+		NumInputsPerWitness:       numInputs,
+		NumPublicInputsPerWitness: n * b,
+		Field:                     w.Field,
+		Values:                    make([]*big.Int, 0, w.NumWitnesses/n*(numInputs+n*b)),
+	}
+	for i := 0; i < res.NumWitnesses; i++ {
+		group := w.Values[i*n*(a+b) : (i+1)*n*(a+b)]
+		for k := 0; k < n; k++ {
+			res.Values = append(res.Values, group[k*(a+b):k*(a+b)+a]...)
+		}
+		for j := n * a; j < numInputs; j++ {
+			res.Values = append(res.Values, big.NewInt(0))
+		}
+		for k := 0; k < n; k++ {
+			res.Values = append(res.Values, group[k*(a+b)+a:(k+1)*(a+b)]...)
+		}
+	}
+	return res, nil
+}
+
+// DeserializeWitness reads a Witness produced by Serialize or SerializeWithHash.
+func DeserializeWitness(buf []byte) *Witness {
+	i := utils.NewInputBuf(buf)
+	w := &Witness{}
+	w.NumWitnesses = int(i.ReadUint64())
+	w.NumInputsPerWitness = int(i.ReadUint64())
+	w.NumPublicInputsPerWitness = int(i.ReadUint64())
+	w.Field = i.ReadBigInt(32)
+	bnlen := field.GetFieldFromOrder(w.Field).SerializedLen()
+	n := w.NumWitnesses * (w.NumInputsPerWitness + w.NumPublicInputsPerWitness)
+	w.Values = make([]*big.Int, n)
+	for j := 0; j < n; j++ {
+		w.Values[j] = i.ReadBigInt(bnlen)
+	}
+	if !i.IsEnd() {
+		w.CircuitHash = i.ReadBytes(CircuitHashLen)
+	}
+	if !i.IsEnd() {
+		panic("invalid binary format")
+	}
+	return w
+}
@@ -0,0 +1,163 @@
+package irwg
+
+import (
+	"slices"
+
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+)
+
+// minBatch is the number of calls of a level to the same subcircuit from which they're
+// evaluated as a batch
+const minBatch = 16
+
+// SolveStats reports how the subcircuit calls were evaluated while solving a witness, see
+// SolveInputStats.
+type SolveStats struct {
+	// SubCircuitCalls is the number of subcircuit calls of the root circuit.
+	SubCircuitCalls int
+	// Batches is the number of batches of calls to the same subcircuit, and BatchedCalls the
+	// number of calls they hold.
+	Batches      int
+	BatchedCalls int
+	// Instructions is the number of instructions of the batched calls, counted once per call, and
+	// Dispatches the number of times the solver dispatched on an instruction to evaluate them.
+	Instructions int
+	Dispatches   int
+}
+
+// DispatchSpeedup returns the number of instructions evaluated per dispatch in the batches, the
+// factor by which batching reduced the interpretation overhead, or 1 without batches.
+func (s *SolveStats) DispatchSpeedup() float64 {
+	if s.Dispatches == 0 {
+		return 1
+	}
+	return float64(s.Instructions) / float64(s.Dispatches)
+}
+
+func (s *SolveStats) add(o *SolveStats) {
+	s.SubCircuitCalls += o.SubCircuitCalls
+	s.Batches += o.Batches
+	s.BatchedCalls += o.BatchedCalls
+	s.Instructions += o.Instructions
+	s.Dispatches += o.Dispatches
+}
+
+// SolveInputStats solves the input of the assignment like SolveInput, and reports how the
+// subcircuit calls were evaluated. With more than one thread, the calls of a level to the same
+// subcircuit, e.g. the thousands of instances of a hash gadget, are evaluated as batches: each
+// instruction of the subcircuit is dispatched once and evaluated on all the calls of the batch,
+// whose values are contiguous in memory.
+func (rc *RootCircuit) SolveInputStats(assignment frontend.Circuit, threads int) (*Witness, *SolveStats, error) {
+	stats := &SolveStats{}
+	witness, lenSec, lenPub, err := rc.solveInputWith(assignment, func(inputs, publicInputs []constraint.Element) ([]constraint.Element, error) {
+		if threads > 1 {
+			return rc.evalParallel(inputs, publicInputs, threads, stats)
+		}
+		for _, insn := range rc.Circuits[0].Instructions {
+			if insn.Type == SubCircuitCall {
+				stats.SubCircuitCalls++
+			}
+		}
+		return rc.eval(inputs, publicInputs)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Witness{
+		NumWitnesses:              1,
+		NumInputsPerWitness:       lenSec,
+		NumPublicInputsPerWitness: lenPub,
+		Field:                     rc.Field.Field(),
+		Values:                    witness,
+		CircuitHash:               rc.CircuitHash,
+	}, stats, nil
+}
+
+// evalSubBatch evaluates n calls of circuit circuitId. inputs holds the inputs of the calls by
+// input then call: input i of call k is inputs[i*n+k]. The outputs are returned in the same
+// layout, and so are the values of the variables of the circuit during the evaluation, so that
+// the inner loop of each instruction runs over contiguous values.
+func (rc *RootCircuit) evalSubBatch(circuitId uint64, n int, inputs []constraint.Element, publicInputs []constraint.Element, stats *SolveStats) ([]constraint.Element, error) {
+	c := rc.Circuits[circuitId]
+	nbVars := c.NumInputs + 1
+	for i := range c.Instructions {
+		nbVars += c.Instructions[i].outputCount()
+	}
+	values := make([]constraint.Element, n, nbVars*n)
+	values = append(values, inputs...)
+	stats.Dispatches += len(c.Instructions)
+	stats.Instructions += len(c.Instructions) * n
+
+	for i := range c.Instructions {
+		insn := &c.Instructions[i]
+		switch insn.Type {
+		case LinComb:
+			out := values[len(values) : len(values)+n]
+			for k := range out {
+				out[k] = insn.Const
+			}
+			for j, x := range insn.Inputs {
+				in, coef := values[x*n:(x+1)*n], insn.LinCombCoef[j]
+				for k := range out {
+					out[k] = rc.Field.Add(out[k], rc.Field.Mul(in[k], coef))
+				}
+			}
+			values = values[:len(values)+n]
+		case Mul:
+			out := values[len(values) : len(values)+n]
+			one := rc.Field.One()
+			for k := range out {
+				out[k] = one
+			}
+			for _, x := range insn.Inputs {
+				in := values[x*n : (x+1)*n]
+				for k := range out {
+					out[k] = rc.Field.Mul(out[k], in[k])
+				}
+			}
+			values = values[:len(values)+n]
+		case SubCircuitCall:
+			subInputs := make([]constraint.Element, 0, len(insn.Inputs)*n)
+			for _, x := range insn.Inputs {
+				subInputs = append(subInputs, values[x*n:(x+1)*n]...)
+			}
+			outputs, err := rc.evalSubBatch(insn.ExtraId, n, subInputs, publicInputs, stats)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, outputs...)
+		default:
+			// hints, custom gates and constants are evaluated call by call, on local copies of
+			// their inputs
+			local := *insn
+			local.Inputs = make([]int, len(insn.Inputs))
+			for j := range local.Inputs {
+				local.Inputs[j] = j
+			}
+			start := len(values)
+			m := insn.outputCount()
+			values = slices.Grow(values, m*n)[:start+m*n]
+			in := make([]constraint.Element, len(insn.Inputs))
+			out := make([]constraint.Element, 0, m)
+			for k := 0; k < n; k++ {
+				for j, x := range insn.Inputs {
+					in[j] = values[x*n+k]
+				}
+				res, err := rc.evalInstruction(&local, in, out, publicInputs)
+				if err != nil {
+					return nil, err
+				}
+				for j, x := range res {
+					values[start+j*n+k] = x
+				}
+			}
+		}
+	}
+
+	outputs := make([]constraint.Element, 0, len(c.Outputs)*n)
+	for _, x := range c.Outputs {
+		outputs = append(outputs, values[x*n:(x+1)*n]...)
+	}
+	return outputs, nil
+}
@@ -0,0 +1,362 @@
+package irwg
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/rpc"
+	"sync"
+
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+)
+
+// SubCircuitEvaluator evaluates batches of calls to a subcircuit of a root circuit. It's used by
+// SolveInputDistributed to split the evaluation of the subcircuit instances across workers.
+type SubCircuitEvaluator interface {
+	// EvalSubCircuits evaluates circuit circuitId on each of the inputs, and returns the outputs of
+	// each call.
+	EvalSubCircuits(circuitId uint64, inputs [][]*big.Int, publicInputs []*big.Int) ([][]*big.Int, error)
+}
+
+// EvalSubCircuits evaluates the calls locally, making the root circuit a SubCircuitEvaluator. It
+// returns an error if the circuit is unknown, or if a call doesn't have the inputs of the circuit
+// or the public inputs of the root circuit, each in the field.
+func (rc *RootCircuit) EvalSubCircuits(circuitId uint64, inputs [][]*big.Int, publicInputs []*big.Int) ([][]*big.Int, error) {
+	if err := rc.checkSubCircuitCalls(circuitId, inputs, publicInputs); err != nil {
+		return nil, err
+	}
+	pub := rc.toElements(publicInputs)
+	if len(inputs) >= minBatch {
+		return rc.evalSubCircuitsBatch(circuitId, inputs, pub)
+	}
+	res := make([][]*big.Int, len(inputs))
+	for i, in := range inputs {
+		out, err := rc.evalSub(circuitId, rc.toElements(in), pub)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = make([]*big.Int, len(out))
+		for j, x := range out {
+			res[i][j] = rc.Field.ToBigInt(x)
+		}
+	}
+	return res, nil
+}
+
+// evalSubCircuitsBatch evaluates the calls of EvalSubCircuits as a batch, see evalSubBatch
+func (rc *RootCircuit) evalSubCircuitsBatch(circuitId uint64, inputs [][]*big.Int, publicInputs []constraint.Element) ([][]*big.Int, error) {
+	n := len(inputs)
+	nbInputs := rc.Circuits[circuitId].NumInputs
+	values := make([]constraint.Element, nbInputs*n)
+	for k, in := range inputs {
+		for j, x := range in {
+			values[j*n+k] = rc.Field.FromInterface(x)
+		}
+	}
+	outputs, err := rc.evalSubBatch(circuitId, n, values, publicInputs, &SolveStats{})
+	if err != nil {
+		return nil, err
+	}
+	m := len(outputs) / n
+	res := make([][]*big.Int, n)
+	for k := range res {
+		res[k] = make([]*big.Int, m)
+		for j := range res[k] {
+			res[k][j] = rc.Field.ToBigInt(outputs[j*n+k])
+		}
+	}
+	return res, nil
+}
+
+// checkSubCircuitCalls checks the arguments of EvalSubCircuits, which may come from a remote
+// solver
+func (rc *RootCircuit) checkSubCircuitCalls(circuitId uint64, inputs [][]*big.Int, publicInputs []*big.Int) error {
+	c, ok := rc.Circuits[circuitId]
+	if !ok {
+		return fmt.Errorf("unknown circuit %d", circuitId)
+	}
+	if len(publicInputs) != rc.NumPublicInputs {
+		return fmt.Errorf("expected %d public inputs, got %d", rc.NumPublicInputs, len(publicInputs))
+	}
+	if err := rc.checkElements(publicInputs); err != nil {
+		return fmt.Errorf("public inputs: %w", err)
+	}
+	for i, in := range inputs {
+		if len(in) != c.NumInputs {
+			return fmt.Errorf("call %d: expected %d inputs, got %d", i, c.NumInputs, len(in))
+		}
+		if err := rc.checkElements(in); err != nil {
+			return fmt.Errorf("call %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// checkElements checks that the values are elements of the field
+func (rc *RootCircuit) checkElements(xs []*big.Int) error {
+	p := rc.Field.Field()
+	for i, x := range xs {
+		if x == nil {
+			return fmt.Errorf("value %d is missing", i)
+		}
+		if x.Sign() < 0 || x.Cmp(p) >= 0 {
+			return fmt.Errorf("value %d is %s, out of the field", i, x)
+		}
+	}
+	return nil
+}
+
+func (rc *RootCircuit) toElements(xs []*big.Int) []constraint.Element {
+	res := make([]constraint.Element, len(xs))
+	for i, x := range xs {
+		res[i] = rc.Field.FromInterface(x)
+	}
+	return res
+}
+
+// SolveInputDistributed solves the input of the assignment like SolveInput, but the subcircuit
+// calls of the root circuit are split across the evaluators. The instructions of the root
+// circuit are evaluated level by level as in the parallel solver: at each level, the subcircuit
+// instances are partitioned into contiguous ranges, one per evaluator, which are evaluated
+// concurrently, and the other instructions are evaluated locally. The evaluators may be local,
+// like the root circuit itself, or remote workers returned by DialWorker.
+func (rc *RootCircuit) SolveInputDistributed(assignment frontend.Circuit, evaluators []SubCircuitEvaluator) (*Witness, error) {
+	if len(evaluators) == 0 {
+		return nil, errors.New("no evaluators")
+	}
+	witness, lenSec, lenPub, err := rc.solveInputWith(assignment, func(inputs, publicInputs []constraint.Element) ([]constraint.Element, error) {
+		return rc.evalDistributed(inputs, publicInputs, evaluators)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Witness{
+		NumWitnesses:              1,
+		NumInputsPerWitness:       lenSec,
+		NumPublicInputsPerWitness: lenPub,
+		Field:                     rc.Field.Field(),
+		Values:                    witness,
+		CircuitHash:               rc.CircuitHash,
+	}, nil
+}
+
+// subCircuitBatch is a range of the subcircuit calls to one circuit in a level, sent to one
+// evaluator
+type subCircuitBatch struct {
+	circuitId uint64
+	insns     []int
+}
+
+func (rc *RootCircuit) evalDistributed(inputs []constraint.Element, publicInputs []constraint.Element, evaluators []SubCircuitEvaluator) ([]constraint.Element, error) {
+	c := rc.Circuits[0]
+	varStart, levels := c.levels()
+	n := len(c.Instructions)
+
+	values := make([]constraint.Element, varStart[n])
+	copy(values[1:], inputs)
+	pub := make([]*big.Int, len(publicInputs))
+	for i, x := range publicInputs {
+		pub[i] = rc.Field.ToBigInt(x)
+	}
+	for _, level := range levels {
+		calls := make(map[uint64][]int)
+		var ids []uint64
+		for _, i := range level {
+			insn := &c.Instructions[i]
+			if insn.Type != SubCircuitCall {
+				if _, err := rc.evalInstruction(insn, values, values[varStart[i]:varStart[i]:varStart[i+1]], publicInputs); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if _, ok := calls[insn.ExtraId]; !ok {
+				ids = append(ids, insn.ExtraId)
+			}
+			calls[insn.ExtraId] = append(calls[insn.ExtraId], i)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		batches := make([][]subCircuitBatch, len(evaluators))
+		for _, id := range ids {
+			insns := calls[id]
+			chunk := (len(insns) + len(evaluators) - 1) / len(evaluators)
+			for k := range evaluators {
+				lo, hi := k*chunk, (k+1)*chunk
+				if hi > len(insns) {
+					hi = len(insns)
+				}
+				if lo >= hi {
+					break
+				}
+				batches[k] = append(batches[k], subCircuitBatch{circuitId: id, insns: insns[lo:hi]})
+			}
+		}
+		var wg sync.WaitGroup
+		errs := make([]error, len(evaluators))
+		for k := range evaluators {
+			if len(batches[k]) == 0 {
+				continue
+			}
+			wg.Add(1)
+			go func(k int) {
+				defer wg.Done()
+				for _, b := range batches[k] {
+					if err := rc.evalBatch(c, b, evaluators[k], values, varStart, pub); err != nil {
+						errs[k] = err
+						return
+					}
+				}
+			}(k)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	outputs := []constraint.Element{}
+	for _, x := range c.Outputs {
+		outputs = append(outputs, values[x])
+	}
+	return outputs, nil
+}
+
+// evalBatch evaluates the subcircuit calls of b with e, and writes their outputs to values
+func (rc *RootCircuit) evalBatch(c *Circuit, b subCircuitBatch, e SubCircuitEvaluator, values []constraint.Element, varStart []int, publicInputs []*big.Int) error {
+	inputs := make([][]*big.Int, len(b.insns))
+	for j, i := range b.insns {
+		inputs[j] = make([]*big.Int, len(c.Instructions[i].Inputs))
+		for k, x := range c.Instructions[i].Inputs {
+			inputs[j][k] = rc.Field.ToBigInt(values[x])
+		}
+	}
+	outputs, err := e.EvalSubCircuits(b.circuitId, inputs, publicInputs)
+	if err != nil {
+		return fmt.Errorf("subcircuit %d: %w", b.circuitId, err)
+	}
+	if len(outputs) != len(b.insns) {
+		return fmt.Errorf("subcircuit %d: %d calls evaluated, expected %d", b.circuitId, len(outputs), len(b.insns))
+	}
+	for j, i := range b.insns {
+		if len(outputs[j]) != varStart[i+1]-varStart[i] {
+			return fmt.Errorf("subcircuit %d: %d outputs, expected %d", b.circuitId, len(outputs[j]), varStart[i+1]-varStart[i])
+		}
+		for k, x := range outputs[j] {
+			if x == nil {
+				return fmt.Errorf("subcircuit %d: nil output", b.circuitId)
+			}
+			values[varStart[i]+k] = rc.Field.FromInterface(x)
+		}
+	}
+	return nil
+}
+
+// solverHash identifies the solver served by a worker, so that clients can check that they solve
+// the same circuit
+func solverHash(rc *RootCircuit) [32]byte {
+	return sha256.Sum256(rc.Serialize())
+}
+
+// EvalArgs is the request of a subcircuit evaluation to a worker.
+type EvalArgs struct {
+	SolverHash   [32]byte
+	CircuitId    uint64
+	Inputs       [][]*big.Int
+	PublicInputs []*big.Int
+}
+
+// EvalReply is the reply of a worker to an EvalArgs request.
+type EvalReply struct {
+	Outputs [][]*big.Int
+}
+
+// Worker evaluates subcircuit calls for remote solvers, see ServeWorker.
+type Worker struct {
+	rc   *RootCircuit
+	hash [32]byte
+}
+
+// NewWorker returns a worker evaluating the subcircuits of rc.
+func NewWorker(rc *RootCircuit) *Worker {
+	return &Worker{rc: rc, hash: solverHash(rc)}
+}
+
+// Eval is the RPC method evaluating the subcircuit calls of args. Invalid calls, and the panics
+// of the hints they call, are returned as errors, so that a bad request never stops the worker.
+func (w *Worker) Eval(args *EvalArgs, reply *EvalReply) (err error) {
+	if args.SolverHash != w.hash {
+		return errors.New("the worker serves another solver")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("evaluation of circuit %d panicked: %v", args.CircuitId, r)
+		}
+	}()
+	outputs, err := w.rc.EvalSubCircuits(args.CircuitId, args.Inputs, args.PublicInputs)
+	if err != nil {
+		return err
+	}
+	reply.Outputs = outputs
+	return nil
+}
+
+// ServeWorker serves the evaluation of the subcircuits of rc on connections accepted by l, over
+// net/rpc. Hints are called on the worker, so they must be registered in its process. It
+// returns when l is closed.
+func ServeWorker(rc *RootCircuit, l net.Listener) error {
+	s := rpc.NewServer()
+	if err := s.RegisterName("Worker", NewWorker(rc)); err != nil {
+		return err
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// WorkerClient is a SubCircuitEvaluator calling a remote worker.
+type WorkerClient struct {
+	client *rpc.Client
+	hash   [32]byte
+}
+
+// DialWorker connects to the worker listening at addr, which must serve the same solver as rc.
+func DialWorker(addr string, rc *RootCircuit) (*WorkerClient, error) {
+	client, err := rpc.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &WorkerClient{client: client, hash: solverHash(rc)}, nil
+}
+
+func (wc *WorkerClient) EvalSubCircuits(circuitId uint64, inputs [][]*big.Int, publicInputs []*big.Int) ([][]*big.Int, error) {
+	var reply EvalReply
+	err := wc.client.Call("Worker.Eval", &EvalArgs{
+		SolverHash:   wc.hash,
+		CircuitId:    circuitId,
+		Inputs:       inputs,
+		PublicInputs: publicInputs,
+	}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Outputs, nil
+}
+
+// Close closes the connection to the worker.
+func (wc *WorkerClient) Close() error {
+	return wc.client.Close()
+}
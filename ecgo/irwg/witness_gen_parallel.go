@@ -0,0 +1,198 @@
+package irwg
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/consensys/gnark/constraint"
+)
+
+// minParallelLevel is the number of instructions of a level below which it's evaluated by a single goroutine
+const minParallelLevel = 256
+
+func (insn *Instruction) outputCount() int {
+	switch insn.Type {
+	case Hint, SubCircuitCall:
+		return insn.NumOutputs
+	default:
+		return 1
+	}
+}
+
+// levels returns the first variable defined by each instruction of the circuit, followed by the
+// number of variables, and groups the instructions into levels, each instruction depending only
+// on instructions of previous levels.
+func (c *Circuit) levels() ([]int, [][]int) {
+	n := len(c.Instructions)
+	varStart := make([]int, n+1)
+	varStart[0] = c.NumInputs + 1
+	for i := range c.Instructions {
+		varStart[i+1] = varStart[i] + c.Instructions[i].outputCount()
+	}
+	varLevel := make([]int, varStart[n])
+	levels := [][]int{}
+	for i := range c.Instructions {
+		l := 0
+		for _, x := range c.Instructions[i].Inputs {
+			if varLevel[x] > l {
+				l = varLevel[x]
+			}
+		}
+		for v := varStart[i]; v < varStart[i+1]; v++ {
+			varLevel[v] = l + 1
+		}
+		if l == len(levels) {
+			levels = append(levels, nil)
+		}
+		levels[l] = append(levels[l], i)
+	}
+	return varStart, levels
+}
+
+// evalParallel evaluates the root circuit like eval. The instructions are grouped into levels,
+// each instruction depending only on instructions of previous levels, and the instructions of a
+// level are evaluated concurrently. The calls of a level to the same subcircuit are evaluated in
+// batches, see evalSubBatch, and recorded in stats.
+func (rc *RootCircuit) evalParallel(inputs []constraint.Element, publicInputs []constraint.Element, threads int, stats *SolveStats) ([]constraint.Element, error) {
+	c := rc.Circuits[0]
+	varStart, levels := c.levels()
+	n := len(c.Instructions)
+
+	values := make([]constraint.Element, varStart[n])
+	copy(values[1:], inputs)
+	eval := func(part []int, _ *SolveStats) error {
+		for _, i := range part {
+			if _, err := rc.evalInstruction(&c.Instructions[i], values, values[varStart[i]:varStart[i]:varStart[i+1]], publicInputs); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	evalCalls := func(part []int, stats *SolveStats) error {
+		return rc.evalCalls(c, part, values, varStart, publicInputs, stats)
+	}
+	for _, level := range levels {
+		rest, batches := c.splitBatches(level, stats)
+		var tasks []parallelTask
+		for _, batch := range batches {
+			tasks = append(tasks, chunkTasks(batch, threads, minBatch, evalCalls)...)
+		}
+		if len(rest) < minParallelLevel || threads <= 1 {
+			if len(rest) > 0 {
+				tasks = append(tasks, parallelTask{rest, eval})
+			}
+		} else {
+			tasks = append(tasks, chunkTasks(rest, threads, 1, eval)...)
+		}
+		if err := runTasks(tasks, threads, stats); err != nil {
+			return nil, err
+		}
+	}
+
+	outputs := []constraint.Element{}
+	for _, x := range c.Outputs {
+		outputs = append(outputs, values[x])
+	}
+	return outputs, nil
+}
+
+// splitBatches splits the instructions of a level into the groups of at least minBatch calls to
+// the same subcircuit, and the other instructions
+func (c *Circuit) splitBatches(level []int, stats *SolveStats) ([]int, [][]int) {
+	calls := make(map[uint64][]int)
+	for _, i := range level {
+		if insn := &c.Instructions[i]; insn.Type == SubCircuitCall {
+			calls[insn.ExtraId] = append(calls[insn.ExtraId], i)
+			stats.SubCircuitCalls++
+		}
+	}
+	var rest []int
+	var batches [][]int
+	for _, i := range level {
+		insn := &c.Instructions[i]
+		if insn.Type != SubCircuitCall || len(calls[insn.ExtraId]) < minBatch {
+			rest = append(rest, i)
+		} else if batch := calls[insn.ExtraId]; batch[0] == i {
+			batches = append(batches, batch)
+		}
+	}
+	return rest, batches
+}
+
+// evalCalls evaluates the calls to the same subcircuit of the instructions insns of c as a
+// batch, and writes their outputs to values
+func (rc *RootCircuit) evalCalls(c *Circuit, insns []int, values []constraint.Element, varStart []int, publicInputs []constraint.Element, stats *SolveStats) error {
+	n := len(insns)
+	first := &c.Instructions[insns[0]]
+	inputs := make([]constraint.Element, len(first.Inputs)*n)
+	for k, i := range insns {
+		for j, x := range c.Instructions[i].Inputs {
+			inputs[j*n+k] = values[x]
+		}
+	}
+	outputs, err := rc.evalSubBatch(first.ExtraId, n, inputs, publicInputs, stats)
+	if err != nil {
+		return err
+	}
+	for k, i := range insns {
+		for j := 0; j < varStart[i+1]-varStart[i]; j++ {
+			values[varStart[i]+j] = outputs[j*n+k]
+		}
+	}
+	stats.Batches++
+	stats.BatchedCalls += n
+	return nil
+}
+
+// parallelTask evaluates a part of a level
+type parallelTask struct {
+	part []int
+	eval func(part []int, stats *SolveStats) error
+}
+
+// chunkTasks splits part into up to threads tasks of at least minSize instructions
+func chunkTasks(part []int, threads int, minSize int, eval func(part []int, stats *SolveStats) error) []parallelTask {
+	chunk := max((len(part)+threads-1)/max(threads, 1), minSize)
+	var tasks []parallelTask
+	for lo := 0; lo < len(part); lo += chunk {
+		tasks = append(tasks, parallelTask{part[lo:min(lo+chunk, len(part))], eval})
+	}
+	return tasks
+}
+
+// runTasks runs the tasks on up to threads goroutines, and adds their stats to stats
+func runTasks(tasks []parallelTask, threads int, stats *SolveStats) error {
+	if len(tasks) == 1 || threads <= 1 {
+		for _, t := range tasks {
+			if err := t.eval(t.part, stats); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var wg sync.WaitGroup
+	var next atomic.Int64
+	errs := make([]error, threads)
+	taskStats := make([]SolveStats, threads)
+	for t := 0; t < min(threads, len(tasks)); t++ {
+		wg.Add(1)
+		go func(t int) {
+			defer wg.Done()
+			for {
+				k := int(next.Add(1)) - 1
+				if k >= len(tasks) || errs[t] != nil {
+					return
+				}
+				errs[t] = tasks[k].eval(tasks[k].part, &taskStats[t])
+			}
+		}(t)
+	}
+	wg.Wait()
+	for t := range errs {
+		if errs[t] != nil {
+			return errs[t]
+		}
+		stats.add(&taskStats[t])
+	}
+	return nil
+}
@@ -0,0 +1,397 @@
+package irwg
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+)
+
+type hintTestCircuit struct {
+	X frontend.Variable
+}
+
+func (c *hintTestCircuit) Define(api frontend.API) error {
+	return nil
+}
+
+func squareHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	outputs[0].Mul(inputs[0], inputs[0])
+	return nil
+}
+
+func hintRootCircuit(hintId uint64) *RootCircuit {
+	return &RootCircuit{
+		Circuits: map[uint64]*Circuit{
+			0: {
+				Instructions: []Instruction{
+					{Type: Hint, ExtraId: hintId, Inputs: []int{1}, NumOutputs: 1},
+				},
+				Outputs:   []int{1, 2},
+				NumInputs: 1,
+			},
+		},
+		Field: &m31.Field{},
+	}
+}
+
+func TestSolveInputWithHint(t *testing.T) {
+	solver.RegisterHint(squareHint)
+	rc := hintRootCircuit(uint64(solver.GetHintID(squareHint)))
+	w, err := rc.SolveInput(&hintTestCircuit{X: 1 << 20}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := new(big.Int).Mod(big.NewInt(1<<40), m31.ScalarField)
+	if len(w.Values) != 2 || w.Values[0].Int64() != 1<<20 || w.Values[1].Cmp(expected) != 0 {
+		t.Fatalf("unexpected witness %v", w.Values)
+	}
+}
+
+func TestSolveInputWithUnregisteredHint(t *testing.T) {
+	rc := hintRootCircuit(1234567)
+	if _, err := rc.SolveInput(&hintTestCircuit{X: 1}, 1); err == nil {
+		t.Fatal("expected an error for an unregistered hint")
+	}
+}
+
+func panicHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	panic("boom")
+}
+
+func slowHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	time.Sleep(time.Second)
+	return nil
+}
+
+func TestSolveInputHintPolicy(t *testing.T) {
+	solver.RegisterHint(squareHint)
+	solver.RegisterHint(panicHint, slowHint)
+	rc := hintRootCircuit(uint64(solver.GetHintID(panicHint)))
+	if _, err := rc.SolveInput(&hintTestCircuit{X: 1}, 1); err == nil || !strings.Contains(err.Error(), "panicHint") || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected a descriptive error for a panicking hint, got %v", err)
+	}
+
+	rc = hintRootCircuit(uint64(solver.GetHintID(squareHint)))
+	rc.HintPolicy = &HintPolicy{}
+	rc.HintPolicy.Allow(slowHint)
+	if _, err := rc.SolveInput(&hintTestCircuit{X: 1}, 1); !errors.Is(err, ErrHintNotAllowed) {
+		t.Fatalf("expected a hint outside the allowlist to be rejected, got %v", err)
+	}
+	rc.HintPolicy.Allow(squareHint)
+	if w, err := rc.SolveInput(&hintTestCircuit{X: 3}, 1); err != nil || w.Values[1].Int64() != 9 {
+		t.Fatalf("expected an allowed hint to run, got %v", err)
+	}
+
+	rc = hintRootCircuit(uint64(solver.GetHintID(slowHint)))
+	rc.HintPolicy = &HintPolicy{Timeout: 10 * time.Millisecond}
+	if _, err := rc.SolveInput(&hintTestCircuit{X: 1}, 1); !errors.Is(err, ErrHintTimeout) {
+		t.Fatalf("expected a slow hint to time out, got %v", err)
+	}
+	rc = hintRootCircuit(uint64(solver.GetHintID(squareHint)))
+	rc.HintPolicy = &HintPolicy{Timeout: time.Minute}
+	if w, err := rc.SolveInput(&hintTestCircuit{X: 3}, 1); err != nil || w.Values[1].Int64() != 9 {
+		t.Fatalf("expected a hint to run within its timeout, got %v", err)
+	}
+}
+
+func TestWitnessSerializeRoundTrip(t *testing.T) {
+	w := &Witness{
+		NumWitnesses:              2,
+		NumInputsPerWitness:       2,
+		NumPublicInputsPerWitness: 1,
+		Field:                     m31.ScalarField,
+		Values:                    []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4), big.NewInt(5), big.NewInt(m31.P - 1)},
+	}
+	buf := w.Serialize()
+	if len(buf) != 8*3+32+4*6 {
+		t.Fatalf("unexpected serialized length %d", len(buf))
+	}
+	w2 := DeserializeWitness(buf)
+	if w2.NumWitnesses != 2 || w2.NumInputsPerWitness != 2 || w2.NumPublicInputsPerWitness != 1 || w2.Field.Cmp(m31.ScalarField) != 0 {
+		t.Fatal("witness header mismatch")
+	}
+	for i := range w.Values {
+		if w.Values[i].Cmp(w2.Values[i]) != 0 {
+			t.Fatalf("value %d mismatch", i)
+		}
+	}
+}
+
+func TestSolveInputParallel(t *testing.T) {
+	solver.RegisterHint(squareHint)
+	f := &m31.Field{}
+	// a wide layer of hints followed by a sum
+	c := &Circuit{NumInputs: 1}
+	sumInsn := Instruction{Type: LinComb}
+	for i := 0; i < 1000; i++ {
+		c.Instructions = append(c.Instructions, Instruction{Type: Hint, ExtraId: uint64(solver.GetHintID(squareHint)), Inputs: []int{1}, NumOutputs: 1})
+		sumInsn.Inputs = append(sumInsn.Inputs, i+2)
+		sumInsn.LinCombCoef = append(sumInsn.LinCombCoef, f.FromInterface(i))
+	}
+	c.Instructions = append(c.Instructions, sumInsn)
+	c.Outputs = []int{1002}
+	rc := &RootCircuit{Circuits: map[uint64]*Circuit{0: c}, Field: f}
+	w1, err := rc.SolveInput(&hintTestCircuit{X: 3}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w4, err := rc.SolveInput(&hintTestCircuit{X: 3}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sum(i * 9) for i < 1000
+	if len(w1.Values) != 1 || w1.Values[0].Int64() != 9*999*1000/2 || w4.Values[0].Cmp(w1.Values[0]) != 0 {
+		t.Fatalf("unexpected outputs %v and %v", w1.Values, w4.Values)
+	}
+}
+
+type publicTestCircuit struct {
+	X frontend.Variable `gnark:",public"`
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *publicTestCircuit) Define(api frontend.API) error {
+	return nil
+}
+
+func TestSolveInputPublicInputOrder(t *testing.T) {
+	f := &m31.Field{}
+	rc := &RootCircuit{
+		Circuits: map[uint64]*Circuit{
+			0: {
+				Instructions: []Instruction{
+					{Type: ConstantLike, ExtraId: 2},
+				},
+				Outputs: []int{1},
+			},
+		},
+		Field:            f,
+		NumPublicInputs:  2,
+		PublicInputOrder: []int{1, 0},
+	}
+	rc = DeserializeRootCircuit(rc.Serialize())
+	w, err := rc.SolveInput(&publicTestCircuit{X: 3, Y: 5}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// slot 0 holds Y
+	if len(w.Values) != 3 || w.Values[0].Int64() != 5 || w.Values[1].Int64() != 5 || w.Values[2].Int64() != 3 {
+		t.Fatalf("unexpected witness %v", w.Values)
+	}
+}
+
+func TestWitnessCircuitHash(t *testing.T) {
+	solver.RegisterHint(squareHint)
+	rc := hintRootCircuit(uint64(solver.GetHintID(squareHint)))
+	var hash [32]byte
+	hash[0] = 1
+	rc.CircuitHash = hash[:]
+	rc = DeserializeRootCircuit(rc.Serialize())
+	w, err := rc.SolveInput(&hintTestCircuit{X: 2}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the hash is only written by SerializeWithHash, Serialize being the format of Expander
+	if len(w.SerializeWithHash()) != len(w.Serialize())+CircuitHashLen || DeserializeWitness(w.Serialize()).CircuitHash != nil {
+		t.Fatal("expected the circuit hash to be written by SerializeWithHash only")
+	}
+	w = DeserializeWitness(w.SerializeWithHash())
+	if err := w.CheckCircuitHash(hash); err != nil {
+		t.Fatal(err)
+	}
+	hash[0] = 2
+	if err := w.CheckCircuitHash(hash); err != ErrCircuitMismatch {
+		t.Fatalf("expected ErrCircuitMismatch, got %v", err)
+	}
+}
+
+// subCircuitRootCircuit returns a circuit summing the square of its input plus i over 100 calls
+// to a subcircuit
+func subCircuitRootCircuit(hintId uint64) *RootCircuit {
+	f := &m31.Field{}
+	sub := &Circuit{
+		NumInputs: 2,
+		Instructions: []Instruction{
+			{Type: Hint, ExtraId: hintId, Inputs: []int{1}, NumOutputs: 1},
+			{Type: LinComb, Inputs: []int{2, 3}, LinCombCoef: []constraint.Element{f.One(), f.One()}},
+		},
+		Outputs: []int{4},
+	}
+	root := &Circuit{NumInputs: 1}
+	sumInsn := Instruction{Type: LinComb}
+	for i := 0; i < 100; i++ {
+		root.Instructions = append(root.Instructions,
+			Instruction{Type: ConstantLike, Const: f.FromInterface(i)},
+			Instruction{Type: SubCircuitCall, ExtraId: 1, Inputs: []int{2*i + 2, 1}, NumOutputs: 1},
+		)
+		sumInsn.Inputs = append(sumInsn.Inputs, 2*i+3)
+		sumInsn.LinCombCoef = append(sumInsn.LinCombCoef, f.One())
+	}
+	root.Instructions = append(root.Instructions, sumInsn)
+	root.Outputs = []int{202}
+	return &RootCircuit{Circuits: map[uint64]*Circuit{0: root, 1: sub}, Field: f}
+}
+
+func TestSolveInputDistributed(t *testing.T) {
+	solver.RegisterHint(squareHint)
+	rc := subCircuitRootCircuit(uint64(solver.GetHintID(squareHint)))
+	expected, err := rc.SolveInput(&hintTestCircuit{X: 3}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sum(i^2 + 3) for i < 100
+	if len(expected.Values) != 1 || expected.Values[0].Int64() != 99*100*199/6+300 {
+		t.Fatalf("unexpected witness %v", expected.Values)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go ServeWorker(DeserializeRootCircuit(rc.Serialize()), l)
+	client, err := DialWorker(l.Addr().String(), rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	w, err := rc.SolveInputDistributed(&hintTestCircuit{X: 3}, []SubCircuitEvaluator{rc, client, client})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Values) != 1 || w.Values[0].Cmp(expected.Values[0]) != 0 {
+		t.Fatalf("unexpected witness %v, expected %v", w.Values, expected.Values)
+	}
+
+	other := hintRootCircuit(uint64(solver.GetHintID(squareHint)))
+	otherClient, err := DialWorker(l.Addr().String(), other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherClient.Close()
+	if _, err := rc.SolveInputDistributed(&hintTestCircuit{X: 3}, []SubCircuitEvaluator{otherClient}); err == nil {
+		t.Fatal("expected an error for a worker serving another solver")
+	}
+}
+
+func TestWorkerBadRequests(t *testing.T) {
+	solver.RegisterHint(squareHint)
+	rc := subCircuitRootCircuit(uint64(solver.GetHintID(squareHint)))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go ServeWorker(DeserializeRootCircuit(rc.Serialize()), l)
+	client, err := DialWorker(l.Addr().String(), rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	p := rc.Field.Field()
+	for _, c := range []struct {
+		circuitId    uint64
+		inputs       [][]*big.Int
+		publicInputs []*big.Int
+		err          string
+	}{
+		{7, [][]*big.Int{{big.NewInt(1), big.NewInt(3)}}, nil, "unknown circuit 7"},
+		{1, [][]*big.Int{{big.NewInt(1), big.NewInt(3)}, {big.NewInt(1)}}, nil, "call 1: expected 2 inputs, got 1"},
+		{1, [][]*big.Int{{big.NewInt(1), p}}, nil, "call 0: value 1 is"},
+		{1, [][]*big.Int{{big.NewInt(1), big.NewInt(-3)}}, nil, "out of the field"},
+		{1, [][]*big.Int{{big.NewInt(1), big.NewInt(3)}}, []*big.Int{big.NewInt(1)}, "expected 0 public inputs, got 1"},
+	} {
+		if _, err := client.EvalSubCircuits(c.circuitId, c.inputs, c.publicInputs); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("expected an error containing %q, got %v", c.err, err)
+		}
+	}
+	// gob doesn't send nil values, which local calls can pass
+	if _, err := rc.EvalSubCircuits(1, [][]*big.Int{{nil, big.NewInt(3)}}, nil); err == nil || !strings.Contains(err.Error(), "call 0: value 0 is missing") {
+		t.Fatalf("expected an error for a missing value, got %v", err)
+	}
+	// the worker still serves valid requests
+	outputs, err := client.EvalSubCircuits(1, [][]*big.Int{{big.NewInt(4), big.NewInt(3)}}, nil)
+	if err != nil || len(outputs) != 1 || outputs[0][0].Int64() != 19 {
+		t.Fatalf("unexpected outputs %v, error %v", outputs, err)
+	}
+}
+
+func TestSolveInputStats(t *testing.T) {
+	solver.RegisterHint(squareHint)
+	rc := subCircuitRootCircuit(uint64(solver.GetHintID(squareHint)))
+	expected, err := rc.SolveInput(&hintTestCircuit{X: 3}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, stats, err := rc.SolveInputStats(&hintTestCircuit{X: 3}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Values) != 1 || w.Values[0].Cmp(expected.Values[0]) != 0 {
+		t.Fatalf("unexpected witness %v, expected %v", w.Values, expected.Values)
+	}
+	// the 100 calls are split into 4 batches of 25
+	if stats.SubCircuitCalls != 100 || stats.BatchedCalls != 100 || stats.Batches != 4 || stats.DispatchSpeedup() != 25 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if _, stats, err = rc.SolveInputStats(&hintTestCircuit{X: 3}, 1); err != nil || stats.SubCircuitCalls != 100 || stats.Batches != 0 {
+		t.Fatalf("expected no batch with a single thread, got %+v, %v", stats, err)
+	}
+
+	inputs := make([][]*big.Int, 20)
+	for i := range inputs {
+		inputs[i] = []*big.Int{big.NewInt(int64(i)), big.NewInt(3)}
+	}
+	outputs, err := rc.EvalSubCircuits(1, inputs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 20 || outputs[19][0].Int64() != 19*19+3 {
+		t.Fatalf("unexpected outputs %v", outputs)
+	}
+}
+
+func BenchmarkSolveInputBatch(b *testing.B) {
+	solver.RegisterHint(squareHint)
+	rc := subCircuitRootCircuit(uint64(solver.GetHintID(squareHint)))
+	for _, threads := range []int{1, 4} {
+		b.Run(fmt.Sprintf("threads=%d", threads), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := rc.SolveInputStats(&hintTestCircuit{X: 3}, threads); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestSolveInputValues(t *testing.T) {
+	solver.RegisterHint(squareHint)
+	rc := hintRootCircuit(uint64(solver.GetHintID(squareHint)))
+	expected, err := rc.SolveInput(&hintTestCircuit{X: 7}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := rc.SolveInputValues([]*big.Int{big.NewInt(7)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(w.Serialize()) != string(expected.Serialize()) {
+		t.Fatalf("expected the witness of SolveInput, got %v", w.Values)
+	}
+	if _, err := rc.SolveInputValues(nil, nil); err == nil || !strings.Contains(err.Error(), "expected 1 secret inputs") {
+		t.Fatalf("expected missing inputs to fail, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package layered
+
+import "math/big"
+
+// AggregateOutputs returns the circuit with a layer appended, which combines the outputs expected
+// to be zero into claims outputs, each a random linear combination of all of them with
+// coefficients sampled at proving time. The verifier then checks claims outputs instead of
+// ExpectedNumOutputZeroes, which reduces its work and the proof size for very wide output layers,
+// e.g. over GF2, where each assertion is an output, or for the many copies of Replicate. The other
+// outputs follow the claims, in the same order.
+//
+// In large fields, a single claim is zero with negligible probability if an output isn't. Over
+// GF2, where the random coefficients are bits, each claim halves this probability. The circuits of
+// rc are shared with the result. If rc has at most claims outputs expected to be zero, it's
+// returned unchanged.
+func (rc *RootCircuit) AggregateOutputs(claims int) *RootCircuit {
+	if claims <= 0 {
+		panic("the number of claims must be positive")
+	}
+	zeroes := uint64(rc.ExpectedNumOutputZeroes)
+	if zeroes <= uint64(claims) {
+		return rc
+	}
+	last := rc.Circuits[rc.Layers[len(rc.Layers)-1]]
+	others := uint64(rc.NumActualOutputs) - zeroes
+	lc := &Circuit{
+		InputLen:  last.OutputLen,
+		OutputLen: nextPowerOfTwo(uint64(claims) + others),
+		Add:       make([]GateAdd, 0, uint64(claims)*zeroes+others),
+	}
+	for j := uint64(0); j < uint64(claims); j++ {
+		for i := uint64(0); i < zeroes; i++ {
+			lc.Add = append(lc.Add, GateAdd{In: i, Out: j, Coef: big.NewInt(0), CoefType: 2})
+		}
+	}
+	for i := uint64(0); i < others; i++ {
+		lc.Add = append(lc.Add, GateAdd{In: zeroes + i, Out: uint64(claims) + i, Coef: big.NewInt(1), CoefType: 1})
+	}
+	return &RootCircuit{
+		NumPublicInputs:         rc.NumPublicInputs,
+		NumActualOutputs:        claims + int(others),
+		ExpectedNumOutputZeroes: claims,
+		Circuits:                append(append([]*Circuit(nil), rc.Circuits...), lc),
+		Layers:                  append(append([]uint64(nil), rc.Layers...), uint64(len(rc.Circuits))),
+		Field:                   rc.Field,
+	}
+}
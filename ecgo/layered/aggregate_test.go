@@ -0,0 +1,51 @@
+package layered
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+)
+
+func TestAggregateOutputs(t *testing.T) {
+	// 4 outputs expected to be zero, followed by 4 outputs of API.Output
+	rc := outputsSample().Replicate(4)
+	agg := rc.AggregateOutputs(1)
+	if agg.ExpectedNumOutputZeroes != 1 || agg.NumActualOutputs != 5 || len(agg.Layers) != len(rc.Layers)+1 {
+		t.Fatalf("unexpected aggregated circuit with %d zeroes, %d outputs and %d layers", agg.ExpectedNumOutputZeroes, agg.NumActualOutputs, len(agg.Layers))
+	}
+	if last := agg.Circuits[agg.Layers[len(agg.Layers)-1]]; last.OutputLen != 8 || len(last.Add) != 8 {
+		t.Fatalf("unexpected aggregation layer of width %d with %d gates", last.OutputLen, len(last.Add))
+	}
+
+	p := new(big.Int).Sub(m31.ScalarField, big.NewInt(12))
+	input := bigInts(3, 4, 5, 0, 3, 4, 1, 0, 3, 4, 2, 0, 3, 4, 0, 0)
+	public := []*big.Int{p, p, p, p}
+	want, err := rc.Outputs(input, public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := agg.Outputs(input, public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != len(want) {
+		t.Fatalf("expected outputs %v, got %v", want, out)
+	}
+	for i := range out {
+		if out[i].Cmp(want[i]) != 0 {
+			t.Fatalf("expected outputs %v, got %v", want, out)
+		}
+	}
+
+	public[2] = big.NewInt(1)
+	if _, err := agg.Outputs(input, public); err == nil {
+		t.Fatal("expected a non-zero output to be caught by the claim")
+	}
+	if agg.AggregateOutputs(1) != agg {
+		t.Fatal("expected a circuit with a single claim to be unchanged")
+	}
+	if _, err := DeserializeRootCircuit(agg.Serialize()).Outputs(input, []*big.Int{p, p, p, p}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,100 @@
+package layered
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils"
+)
+
+// CompactMagic identifies the compact circuit files written by SerializeCompact. It differs from
+// MAGIC and HeaderMagic, so that readers tell them apart.
+const CompactMagic = 3914834606642317637
+
+// coefIndex is the tag of a constant coefficient stored in the table of a compact circuit
+const coefIndex = 4
+
+// SerializeCompact serializes the circuit like Serialize, except that the constant coefficients
+// are stored once, in a table following the field modulus, and referred to by their index:
+//
+//	CompactMagic | field modulus (32 bytes) | len(table) | table... | the rest of Serialize
+//
+// The table holds the distinct constants in the order of their first use, padded to the
+// SerializedLen of the field. A constant coefficient is tagged 4, followed by its index in the
+// table as a uvarint, which takes a single byte for the 128 first constants; the other
+// coefficients are encoded like in Serialize. Large circuits use a handful of distinct
+// coefficients, mostly 1 and -1, so over BN254 this saves about 30 bytes per gate.
+//
+// DeserializeRootCircuit and DetectFieldId read compact files, but the Expander prover and
+// OpenMapped don't: they must be converted back with ExpandCompact first.
+func (rc *RootCircuit) SerializeCompact() []byte {
+	bnlen := field.GetFieldFromOrder(rc.Field).SerializedLen()
+	var table []*big.Int
+	index := make(map[string]uint64)
+	body := utils.OutputBuf{}
+	rc.serializeBody(&body, func(o *utils.OutputBuf, coef *big.Int, coefType uint8, publicInputId uint64) {
+		if coefType != 1 {
+			serializeCoef(o, bnlen, coef, coefType, publicInputId)
+			return
+		}
+		key := string(coef.Bytes())
+		i, ok := index[key]
+		if !ok {
+			i = uint64(len(table))
+			index[key] = i
+			table = append(table, coef)
+		}
+		o.AppendUint8(coefIndex)
+		o.AppendUvarint(i)
+	})
+	o := utils.OutputBuf{}
+	o.AppendUint64(CompactMagic)
+	o.AppendBigInt(32, rc.Field)
+	o.AppendUint64(uint64(len(table)))
+	for _, x := range table {
+		o.AppendBigInt(bnlen, x)
+	}
+	o.AppendBytes(body.Bytes())
+	return o.Bytes()
+}
+
+// readCoefTable reads the table of a compact circuit, and returns the decoder of its coefficients
+func readCoefTable(in *utils.InputBuf, bnlen int) func(in *utils.InputBuf) (*big.Int, uint8, uint64) {
+	table := make([]*big.Int, in.ReadUint64())
+	for i := range table {
+		table[i] = in.ReadBigInt(bnlen)
+	}
+	return func(in *utils.InputBuf) (*big.Int, uint8, uint64) {
+		if in.PeekUint8() != coefIndex {
+			return deserializeCoef(in, bnlen)
+		}
+		in.ReadUint8()
+		i := in.ReadUvarint()
+		if i >= uint64(len(table)) {
+			panic("invalid coefficient index")
+		}
+		// the gates own their coefficients
+		return new(big.Int).Set(table[i]), 1, 0
+	}
+}
+
+// IsCompact reports whether buf is a circuit file written by SerializeCompact.
+func IsCompact(buf []byte) bool {
+	return len(buf) >= 8 && binary.LittleEndian.Uint64(buf) == CompactMagic
+}
+
+// ExpandCompact converts a circuit file written by SerializeCompact to the format of Serialize,
+// e.g. for the Expander prover. Other files are returned as is.
+func ExpandCompact(buf []byte) (res []byte, err error) {
+	if !IsCompact(buf) {
+		return buf, nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid compact circuit: %v", r)
+		}
+	}()
+	return DeserializeRootCircuit(buf).Serialize(), nil
+}
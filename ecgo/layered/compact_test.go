@@ -0,0 +1,78 @@
+package layered
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+)
+
+func TestSerializeCompact(t *testing.T) {
+	rc := sampleRootCircuit()
+	buf := rc.SerializeCompact()
+	if !IsCompact(buf) || IsCompact(rc.Serialize()) {
+		t.Fatal("expected only the compact file to be detected")
+	}
+	if !bytes.Equal(DeserializeRootCircuit(buf).Serialize(), rc.Serialize()) {
+		t.Fatal("deserialized circuit differs from the original one")
+	}
+	if id := DetectFieldId(buf); id != 1 {
+		t.Fatalf("expected field id 1, got %d", id)
+	}
+	h, payload, err := ReadHeader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Version != 0 || h.FieldId != 1 || !bytes.Equal(payload, rc.Serialize()) {
+		t.Fatalf("unexpected header %+v", h)
+	}
+	if _, err := newMapped(buf); err == nil {
+		t.Fatal("expected mapping a compact circuit to fail")
+	}
+}
+
+func TestSerializeCompactSize(t *testing.T) {
+	one, minusOne := big.NewInt(1), new(big.Int).Sub(ecc.BN254.ScalarField(), big.NewInt(1))
+	c := &Circuit{InputLen: 1024, OutputLen: 1024}
+	for i := uint64(0); i < 1024; i++ {
+		c.Mul = append(c.Mul, GateMul{In0: i, In1: (i + 1) % 1024, Out: i, Coef: one, CoefType: 1})
+		c.Add = append(c.Add, GateAdd{In: i, Out: i, Coef: minusOne, CoefType: 1})
+	}
+	rc := &RootCircuit{Circuits: []*Circuit{c}, Layers: []uint64{0}, Field: ecc.BN254.ScalarField()}
+	full, compact := rc.Serialize(), rc.SerializeCompact()
+	if len(compact)*2 > len(full) {
+		t.Fatalf("expected the compact file to be less than half the size, got %d and %d bytes", len(compact), len(full))
+	}
+	expanded, err := ExpandCompact(compact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expanded, full) {
+		t.Fatal("expanded circuit differs from the original one")
+	}
+	// the table holds the two distinct constants
+	if n := len(compact) - len(full) + 2048*(32-1); n != 8+2*32 {
+		t.Fatalf("unexpected table of %d bytes", n)
+	}
+
+	// the gates can be modified independently
+	lc := DeserializeRootCircuit(compact)
+	lc.Circuits[0].Mul[0].Coef.SetInt64(2)
+	if lc.Circuits[0].Mul[1].Coef.Int64() != 1 {
+		t.Fatal("expected the coefficients not to be shared")
+	}
+}
+
+func TestExpandCompactInvalidIndex(t *testing.T) {
+	rc := sampleRootCircuit()
+	buf := rc.SerializeCompact()
+	// the table holds 1, 3 and 5, the last coefficient is the index of 5
+	if buf[len(buf)-25] != 2 {
+		t.Fatal("unexpected layout")
+	}
+	buf[len(buf)-25] = 3
+	if _, err := ExpandCompact(buf); err == nil {
+		t.Fatal("expected an out of range index to be rejected")
+	}
+}
@@ -0,0 +1,65 @@
+package layered
+
+import (
+	"fmt"
+	"time"
+)
+
+// Cost is a predicted cost of proving a circuit.
+type Cost struct {
+	Time time.Duration
+	// Memory is in bytes.
+	Memory uint64
+}
+
+func (c Cost) String() string {
+	return fmt.Sprintf("time: %v, memory: %d bytes", c.Time, c.Memory)
+}
+
+// CostModel predicts the proving cost of the layers of a circuit on some hardware. The cost of a
+// circuit is the sum of the costs of its layers, since the prover evaluates every layer before
+// proving them, and holds their values and gates until the end.
+type CostModel interface {
+	LayerCost(l *LayerStats) Cost
+}
+
+// CostCoef is the cost of a single element of a layer in a LinearCostModel.
+type CostCoef struct {
+	Nanoseconds float64
+	Bytes       float64
+}
+
+// LinearCostModel is a CostModel linear in the gates of each kind, the input wires and the
+// number of layers, e.g. fitted on benchmarks of the prover on the target hardware. It can be
+// read from JSON, like {"MulGate": {"Nanoseconds": 30, "Bytes": 40}, "Layer": {"Nanoseconds": 1e5}}.
+type LinearCostModel struct {
+	MulGate    CostCoef
+	AddGate    CostCoef
+	CstGate    CostCoef
+	CustomGate CostCoef
+	InputWire  CostCoef
+	Layer      CostCoef
+}
+
+func (m *LinearCostModel) LayerCost(l *LayerStats) Cost {
+	ns, bytes := m.Layer.Nanoseconds, m.Layer.Bytes
+	for _, t := range []struct {
+		n uint64
+		c CostCoef
+	}{{l.NumMul, m.MulGate}, {l.NumAdd, m.AddGate}, {l.NumCst, m.CstGate}, {l.NumCustom, m.CustomGate}, {l.InputLen, m.InputWire}} {
+		ns += float64(t.n) * t.c.Nanoseconds
+		bytes += float64(t.n) * t.c.Bytes
+	}
+	return Cost{Time: time.Duration(ns), Memory: uint64(bytes)}
+}
+
+// Cost returns the cost of proving the circuit predicted by m, the sum of the costs of its layers.
+func (s *Stats) Cost(m CostModel) Cost {
+	var res Cost
+	for i := range s.Layers {
+		c := m.LayerCost(&s.Layers[i])
+		res.Time += c.Time
+		res.Memory += c.Memory
+	}
+	return res
+}
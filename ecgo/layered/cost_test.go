@@ -0,0 +1,20 @@
+package layered
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCost(t *testing.T) {
+	m := &LinearCostModel{
+		MulGate:   CostCoef{Nanoseconds: 10, Bytes: 1},
+		AddGate:   CostCoef{Nanoseconds: 1},
+		InputWire: CostCoef{Bytes: 8},
+		Layer:     CostCoef{Nanoseconds: 100},
+	}
+	// 1 mul, 2 add, 4 inputs then 2 add, 2 inputs, the custom and constant gates being free
+	c := sampleRootCircuit().Stats().Cost(m)
+	if c.Time != 214*time.Nanosecond || c.Memory != 49 {
+		t.Fatalf("unexpected cost %v", c)
+	}
+}
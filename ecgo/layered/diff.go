@@ -0,0 +1,216 @@
+package layered
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// CircuitDiff holds the structural differences between two layered circuits, A and B, see Diff.
+type CircuitDiff struct {
+	A, B *Stats
+	// Layers compares the layers of the same index. A layer missing from a circuit is nil.
+	Layers []LayerDiff
+	// SubCircuits compares the circuits of A and B which differ in their gates or instances.
+	SubCircuits []SubCircuitDiff
+}
+
+// LayerDiff compares a layer of two circuits.
+type LayerDiff struct {
+	Index int
+	A, B  *LayerStats
+}
+
+// SubCircuitDiff compares a circuit of A with the matching circuit of B. Circuits with the same
+// gates and subcircuits are matched, then the remaining circuits of the same layer, then the
+// remaining circuits with the same number of inputs and outputs, in the order of their ids.
+type SubCircuitDiff struct {
+	// IdA and IdB are the ids of the circuits in RootCircuit.Circuits, or -1 if there's no
+	// matching circuit.
+	IdA, IdB            int
+	InputLen, OutputLen uint64
+	// GatesA and GatesB are the numbers of gates of the circuits, excluding the ones of their
+	// subcircuits.
+	GatesA, GatesB uint64
+	// InstancesA and InstancesB are the number of times the circuits are instantiated.
+	InstancesA, InstancesB uint64
+}
+
+// Delta returns the difference between the total numbers of gates of the instances of the
+// circuits in B and in A.
+func (d *SubCircuitDiff) Delta() int64 {
+	return int64(d.GatesB*d.InstancesB) - int64(d.GatesA*d.InstancesA)
+}
+
+// Diff compares the layers and the circuits of a and b, e.g. the same circuit compiled by two
+// versions of the compiler, to track regressions of their sizes. Circuits are compared by
+// structure, since their ids don't necessarily match.
+func Diff(a, b *RootCircuit) *CircuitDiff {
+	d := &CircuitDiff{A: a.Stats(), B: b.Stats()}
+	for i := 0; i < max(len(d.A.Layers), len(d.B.Layers)); i++ {
+		l := LayerDiff{Index: i}
+		if i < len(d.A.Layers) {
+			l.A = &d.A.Layers[i]
+		}
+		if i < len(d.B.Layers) {
+			l.B = &d.B.Layers[i]
+		}
+		if l.A == nil || l.B == nil || *l.A != *l.B {
+			d.Layers = append(d.Layers, l)
+		}
+	}
+
+	ha, hb := a.circuitHashes(), b.circuitHashes()
+	match := make([]int, len(a.Circuits))
+	matched := make([]bool, len(b.Circuits))
+	byHash := make(map[[32]byte][]int)
+	for j := range b.Circuits {
+		byHash[hb[j]] = append(byHash[hb[j]], j)
+	}
+	for i := range a.Circuits {
+		match[i] = -1
+		if js := byHash[ha[i]]; len(js) != 0 {
+			match[i] = js[0]
+			matched[js[0]] = true
+			byHash[ha[i]] = js[1:]
+		}
+	}
+	pair := func(i, j int) {
+		if match[i] == -1 && !matched[j] {
+			match[i] = j
+			matched[j] = true
+		}
+	}
+	for k := 0; k < min(len(a.Layers), len(b.Layers)); k++ {
+		pair(int(a.Layers[k]), int(b.Layers[k]))
+	}
+	for i, c := range a.Circuits {
+		if match[i] != -1 {
+			continue
+		}
+		for j, cb := range b.Circuits {
+			if !matched[j] && c.InputLen == cb.InputLen && c.OutputLen == cb.OutputLen {
+				pair(i, j)
+				break
+			}
+		}
+	}
+	for i, c := range a.Circuits {
+		s := SubCircuitDiff{IdA: i, IdB: match[i], InputLen: c.InputLen, OutputLen: c.OutputLen, GatesA: c.numGates(), InstancesA: d.A.Instances[i]}
+		if j := match[i]; j != -1 {
+			s.GatesB, s.InstancesB = b.Circuits[j].numGates(), d.B.Instances[j]
+		}
+		if s.GatesA != s.GatesB || s.InstancesA != s.InstancesB {
+			d.SubCircuits = append(d.SubCircuits, s)
+		}
+	}
+	for j, c := range b.Circuits {
+		if !matched[j] {
+			d.SubCircuits = append(d.SubCircuits, SubCircuitDiff{IdA: -1, IdB: j, InputLen: c.InputLen, OutputLen: c.OutputLen, GatesB: c.numGates(), InstancesB: d.B.Instances[j]})
+		}
+	}
+	return d
+}
+
+// Empty returns whether the circuits have the same layers and circuits.
+func (d *CircuitDiff) Empty() bool {
+	return len(d.Layers) == 0 && len(d.SubCircuits) == 0
+}
+
+func (c *Circuit) numGates() uint64 {
+	return uint64(len(c.Mul) + len(c.Add) + len(c.Cst) + len(c.Custom))
+}
+
+// circuitHashes returns a hash of the gates and subcircuits of each circuit, which doesn't
+// depend on the ids of the circuits
+func (rc *RootCircuit) circuitHashes() [][32]byte {
+	res := make([][32]byte, len(rc.Circuits))
+	done := make([]bool, len(rc.Circuits))
+	var visit func(id uint64)
+	visit = func(id uint64) {
+		if done[id] {
+			return
+		}
+		c := rc.Circuits[id]
+		h := sha256.New()
+		word := func(x uint64) {
+			var buf [8]byte
+			binary.LittleEndian.PutUint64(buf[:], x)
+			h.Write(buf[:])
+		}
+		word(c.InputLen)
+		word(c.OutputLen)
+		for _, sub := range c.SubCircuits {
+			visit(sub.Id)
+			h.Write(res[sub.Id][:])
+			word(uint64(len(sub.Allocations)))
+			for _, a := range sub.Allocations {
+				word(a.InputOffset)
+				word(a.OutputOffset)
+			}
+		}
+		for _, g := range c.Mul {
+			fmt.Fprintf(h, "m%d,%d,%d,%s,%d,%d;", g.In0, g.In1, g.Out, g.Coef, g.CoefType, g.PublicInputId)
+		}
+		for _, g := range c.Add {
+			fmt.Fprintf(h, "a%d,%d,%s,%d,%d;", g.In, g.Out, g.Coef, g.CoefType, g.PublicInputId)
+		}
+		for _, g := range c.Cst {
+			fmt.Fprintf(h, "c%d,%s,%d,%d;", g.Out, g.Coef, g.CoefType, g.PublicInputId)
+		}
+		for _, g := range c.Custom {
+			fmt.Fprintf(h, "g%d,%v,%d,%s,%d,%d;", g.GateType, g.In, g.Out, g.Coef, g.CoefType, g.PublicInputId)
+		}
+		copy(res[id][:], h.Sum(nil))
+		done[id] = true
+	}
+	for id := range rc.Circuits {
+		visit(uint64(id))
+	}
+	return res
+}
+
+// String returns a human-readable report of the differences, "A -> B" for each changed value.
+func (d *CircuitDiff) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "layers: %s, max width: %s, total gates: %s\n",
+		change(uint64(d.A.NumLayers), uint64(d.B.NumLayers)), change(d.A.MaxWidth, d.B.MaxWidth), change(d.A.TotalGates(), d.B.TotalGates()))
+	for _, l := range d.Layers {
+		switch {
+		case l.A == nil:
+			fmt.Fprintf(&sb, "layer %d: added, in=%d out=%d gates=%d\n", l.Index, l.B.InputLen, l.B.OutputLen, l.B.numGates())
+		case l.B == nil:
+			fmt.Fprintf(&sb, "layer %d: removed, in=%d out=%d gates=%d\n", l.Index, l.A.InputLen, l.A.OutputLen, l.A.numGates())
+		default:
+			fmt.Fprintf(&sb, "layer %d: in=%s out=%s mul=%s add=%s cst=%s custom=%s\n", l.Index,
+				change(l.A.InputLen, l.B.InputLen), change(l.A.OutputLen, l.B.OutputLen), change(l.A.NumMul, l.B.NumMul),
+				change(l.A.NumAdd, l.B.NumAdd), change(l.A.NumCst, l.B.NumCst), change(l.A.NumCustom, l.B.NumCustom))
+		}
+	}
+	for _, s := range d.SubCircuits {
+		switch {
+		case s.IdB == -1:
+			fmt.Fprintf(&sb, "circuit %d (in=%d out=%d): removed, gates=%d instances=%d", s.IdA, s.InputLen, s.OutputLen, s.GatesA, s.InstancesA)
+		case s.IdA == -1:
+			fmt.Fprintf(&sb, "circuit %d (in=%d out=%d): added, gates=%d instances=%d", s.IdB, s.InputLen, s.OutputLen, s.GatesB, s.InstancesB)
+		default:
+			fmt.Fprintf(&sb, "circuit %d -> %d (in=%d out=%d): gates=%s instances=%s", s.IdA, s.IdB, s.InputLen, s.OutputLen,
+				change(s.GatesA, s.GatesB), change(s.InstancesA, s.InstancesB))
+		}
+		fmt.Fprintf(&sb, ", total %+d gates\n", s.Delta())
+	}
+	return sb.String()
+}
+
+func (l *LayerStats) numGates() uint64 {
+	return l.NumMul + l.NumAdd + l.NumCst + l.NumCustom
+}
+
+// change formats a change from a to b
+func change(a, b uint64) string {
+	if a == b {
+		return fmt.Sprint(a)
+	}
+	return fmt.Sprintf("%d -> %d (%+d)", a, b, int64(b)-int64(a))
+}
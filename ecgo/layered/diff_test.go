@@ -0,0 +1,44 @@
+package layered
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := sampleRootCircuit()
+	if d := Diff(a, sampleRootCircuit()); !d.Empty() {
+		t.Fatalf("expected no difference, got %s", d)
+	}
+
+	// b calls the subcircuit twice, with an additional gate, from a reordered list of circuits
+	b := sampleRootCircuit()
+	sub, l0, l1 := b.Circuits[0], b.Circuits[1], b.Circuits[2]
+	sub.Add = []GateAdd{{In: 0, Out: 0, Coef: big.NewInt(1), CoefType: 1}}
+	l0.SubCircuits = []SubCircuit{{Id: 2, Allocations: []Allocation{{InputOffset: 0, OutputOffset: 0}, {InputOffset: 2, OutputOffset: 1}}}}
+	l0.Add = l0.Add[:0]
+	l1.Custom = nil
+	b.Circuits = []*Circuit{l0, l1, sub}
+	b.Layers = []uint64{0, 1}
+
+	d := Diff(a, b)
+	if d.Empty() || len(d.Layers) != 2 {
+		t.Fatalf("unexpected layers %+v", d.Layers)
+	}
+	if d.Layers[1].A.NumCustom != 1 || d.Layers[1].B.NumCustom != 0 {
+		t.Fatalf("unexpected second layer %+v", d.Layers[1])
+	}
+	var subDiff *SubCircuitDiff
+	for i := range d.SubCircuits {
+		if d.SubCircuits[i].IdA == 0 {
+			subDiff = &d.SubCircuits[i]
+		}
+	}
+	if subDiff == nil || subDiff.IdB != 2 || subDiff.GatesA != 1 || subDiff.GatesB != 2 || subDiff.InstancesB != 2 || subDiff.Delta() != 3 {
+		t.Fatalf("unexpected subcircuit diff %+v", d.SubCircuits)
+	}
+	if s := d.String(); !strings.Contains(s, "circuit 0 -> 2 (in=2 out=1): gates=1 -> 2 (+1)") {
+		t.Fatalf("unexpected report\n%s", s)
+	}
+}
@@ -0,0 +1,121 @@
+package layered
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/customgates"
+)
+
+// Eval evaluates the circuit layer by layer on the given inputs and public inputs, and returns
+// the values of the output layer. Random coefficients are sampled like at proving time, so the
+// outputs expected to be zero are zero on a valid witness, and the other outputs are the values
+// given to API.Output.
+func (rc *RootCircuit) Eval(input []*big.Int, publicInput []*big.Int) ([]*big.Int, error) {
+	if len(input) != int(rc.Circuits[rc.Layers[0]].InputLen) {
+		return nil, fmt.Errorf("expected %d inputs, got %d", rc.Circuits[rc.Layers[0]].InputLen, len(input))
+	}
+	cur := input
+	for i, id := range rc.Layers {
+		next := make([]*big.Int, rc.Circuits[id].OutputLen)
+		for j := range next {
+			next[j] = big.NewInt(0)
+		}
+		if err := rc.apply(rc.Circuits[id], cur, next, publicInput); err != nil {
+			return nil, fmt.Errorf("layer %d: %w", i, err)
+		}
+		cur = next
+		for j := range cur {
+			cur[j].Mod(cur[j], rc.Field)
+		}
+	}
+	return cur, nil
+}
+
+// sampleCoef returns the value of the coefficient of a gate
+func (rc *RootCircuit) sampleCoef(coef *big.Int, coefType uint8, publicInputId uint64, publicInput []*big.Int) (*big.Int, error) {
+	switch coefType {
+	case 1:
+		return coef, nil
+	case 2:
+		return rand.Int(rand.Reader, rc.Field)
+	default:
+		if publicInputId >= uint64(len(publicInput)) {
+			return nil, fmt.Errorf("public input %d out of range", publicInputId)
+		}
+		return publicInput[publicInputId], nil
+	}
+}
+
+// apply adds the gates of circuit evaluated on cur to next
+func (rc *RootCircuit) apply(circuit *Circuit, cur []*big.Int, next []*big.Int, publicInput []*big.Int) error {
+	tmp := big.NewInt(0)
+	for _, m := range circuit.Mul {
+		coef, err := rc.sampleCoef(m.Coef, m.CoefType, m.PublicInputId, publicInput)
+		if err != nil {
+			return err
+		}
+		tmp.Mul(cur[m.In0], cur[m.In1])
+		next[m.Out].Add(next[m.Out], tmp.Mul(tmp, coef))
+	}
+	for _, a := range circuit.Add {
+		coef, err := rc.sampleCoef(a.Coef, a.CoefType, a.PublicInputId, publicInput)
+		if err != nil {
+			return err
+		}
+		next[a.Out].Add(next[a.Out], tmp.Mul(cur[a.In], coef))
+	}
+	for _, c := range circuit.Cst {
+		coef, err := rc.sampleCoef(c.Coef, c.CoefType, c.PublicInputId, publicInput)
+		if err != nil {
+			return err
+		}
+		next[c.Out].Add(next[c.Out], coef)
+	}
+	for _, ct := range circuit.Custom {
+		inB := make([]*big.Int, len(ct.In))
+		for i, e := range ct.In {
+			inB[i] = cur[e]
+		}
+		outB := []*big.Int{big.NewInt(0)}
+		if err := customgates.GetFunc(ct.GateType)(rc.Field, inB, outB); err != nil {
+			return fmt.Errorf("custom gate %d: %w", ct.GateType, err)
+		}
+		coef, err := rc.sampleCoef(ct.Coef, ct.CoefType, ct.PublicInputId, publicInput)
+		if err != nil {
+			return err
+		}
+		next[ct.Out].Add(next[ct.Out], tmp.Mul(outB[0], coef))
+	}
+	for _, sub := range circuit.SubCircuits {
+		sc := rc.Circuits[sub.Id]
+		for _, alloc := range sub.Allocations {
+			err := rc.apply(sc,
+				cur[alloc.InputOffset:alloc.InputOffset+sc.InputLen],
+				next[alloc.OutputOffset:alloc.OutputOffset+sc.OutputLen],
+				publicInput,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Outputs evaluates the circuit like Eval, and returns the values given to API.Output, which
+// follow the outputs expected to be zero in the output layer. It returns an error if one of those
+// isn't zero, in which case the outputs can't be trusted.
+func (rc *RootCircuit) Outputs(input []*big.Int, publicInput []*big.Int) ([]*big.Int, error) {
+	out, err := rc.Eval(input, publicInput)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < rc.ExpectedNumOutputZeroes; i++ {
+		if out[i].Sign() != 0 {
+			return nil, fmt.Errorf("output %d is expected to be zero", i)
+		}
+	}
+	return out[rc.ExpectedNumOutputZeroes:rc.NumActualOutputs], nil
+}
@@ -0,0 +1,70 @@
+package layered
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+)
+
+// outputsSample computes (x0*x1 + p0, x2*x2 + 1) on 4 inputs, the first output being expected to
+// be zero
+func outputsSample() *RootCircuit {
+	sub := &Circuit{
+		InputLen:  2,
+		OutputLen: 1,
+		Mul:       []GateMul{{In0: 0, In1: 1, Out: 0, Coef: big.NewInt(1), CoefType: 1}},
+	}
+	l0 := &Circuit{
+		InputLen:    4,
+		OutputLen:   2,
+		SubCircuits: []SubCircuit{{Id: 0, Allocations: []Allocation{{InputOffset: 0, OutputOffset: 0}}}},
+		Mul:         []GateMul{{In0: 2, In1: 2, Out: 1, Coef: big.NewInt(1), CoefType: 1}},
+		Cst: []GateCst{
+			{Out: 0, Coef: big.NewInt(0), CoefType: 3, PublicInputId: 0},
+			{Out: 1, Coef: big.NewInt(1), CoefType: 1},
+		},
+	}
+	l1 := &Circuit{
+		InputLen:  2,
+		OutputLen: 2,
+		Add: []GateAdd{
+			{In: 0, Out: 0, Coef: big.NewInt(1), CoefType: 1},
+			{In: 1, Out: 1, Coef: big.NewInt(1), CoefType: 1},
+		},
+	}
+	return &RootCircuit{
+		NumPublicInputs:         1,
+		NumActualOutputs:        2,
+		ExpectedNumOutputZeroes: 1,
+		Circuits:                []*Circuit{sub, l0, l1},
+		Layers:                  []uint64{1, 2},
+		Field:                   m31.ScalarField,
+	}
+}
+
+func bigInts(xs ...int64) []*big.Int {
+	res := make([]*big.Int, len(xs))
+	for i, x := range xs {
+		res[i] = big.NewInt(x)
+	}
+	return res
+}
+
+func TestOutputs(t *testing.T) {
+	rc := outputsSample()
+	p := new(big.Int).Sub(m31.ScalarField, big.NewInt(12))
+	out, err := rc.Outputs(bigInts(3, 4, 5, 0), []*big.Int{p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Int64() != 26 {
+		t.Fatalf("unexpected outputs %v", out)
+	}
+	if _, err := rc.Outputs(bigInts(3, 4, 5, 0), bigInts(12)); err == nil {
+		t.Fatal("expected an output expected to be zero to be checked")
+	}
+	if _, err := rc.Eval(bigInts(3, 4, 5), []*big.Int{p}); err == nil {
+		t.Fatal("expected the number of inputs to be checked")
+	}
+}
@@ -0,0 +1,128 @@
+package layered
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ExportFormat selects the rendering produced by RootCircuit.Export.
+type ExportFormat int
+
+const (
+	// ExportGraphviz renders the wires and gates of every layer as a DOT graph, see Graphviz.
+	ExportGraphviz ExportFormat = iota
+	// ExportCallGraph renders the subcircuit hierarchy as a DOT graph: one node per circuit,
+	// one edge per called subcircuit labelled with its number of allocations.
+	ExportCallGraph
+	// ExportJSON dumps the whole circuit structure as JSON.
+	ExportJSON
+)
+
+type jsonGate struct {
+	Type          string   `json:"type"`
+	In            []uint64 `json:"in,omitempty"`
+	Out           uint64   `json:"out"`
+	Coef          string   `json:"coef"`
+	CoefType      uint8    `json:"coefType"`
+	PublicInputId uint64   `json:"publicInputId,omitempty"`
+	GateType      uint64   `json:"gateType,omitempty"`
+}
+
+type jsonSubCircuit struct {
+	Id          uint64       `json:"id"`
+	Allocations []Allocation `json:"allocations"`
+}
+
+type jsonCircuit struct {
+	Id          int              `json:"id"`
+	InputLen    uint64           `json:"inputLen"`
+	OutputLen   uint64           `json:"outputLen"`
+	SubCircuits []jsonSubCircuit `json:"subCircuits"`
+	Gates       []jsonGate       `json:"gates"`
+}
+
+type jsonRootCircuit struct {
+	Field                   string        `json:"field"`
+	NumPublicInputs         int           `json:"numPublicInputs"`
+	NumActualOutputs        int           `json:"numActualOutputs"`
+	ExpectedNumOutputZeroes int           `json:"expectedNumOutputZeroes"`
+	Layers                  []uint64      `json:"layers"`
+	Circuits                []jsonCircuit `json:"circuits"`
+}
+
+// Export writes a rendering of the circuit to w, for debugging and visualization.
+func (rc *RootCircuit) Export(w io.Writer, format ExportFormat) error {
+	switch format {
+	case ExportGraphviz:
+		_, err := io.WriteString(w, rc.Graphviz())
+		return err
+	case ExportCallGraph:
+		return rc.exportCallGraph(w)
+	case ExportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rc.toJSON())
+	}
+	return fmt.Errorf("unknown export format %d", format)
+}
+
+func (rc *RootCircuit) exportCallGraph(w io.Writer) error {
+	isLayer := make(map[uint64]int)
+	for i, id := range rc.Layers {
+		isLayer[id] = i + 1
+	}
+	if _, err := fmt.Fprintln(w, "digraph G{"); err != nil {
+		return err
+	}
+	for i, c := range rc.Circuits {
+		label := fmt.Sprintf("circuit %d\\nin=%d out=%d", i, c.InputLen, c.OutputLen)
+		style := ""
+		if l, ok := isLayer[uint64(i)]; ok {
+			label += fmt.Sprintf("\\nlayer %d", l-1)
+			style = " style=filled fillcolor=lightskyblue"
+		}
+		if _, err := fmt.Fprintf(w, "	C_%d[label=\"%s\" shape=box%s];\n", i, label, style); err != nil {
+			return err
+		}
+	}
+	for i, c := range rc.Circuits {
+		for _, sub := range c.SubCircuits {
+			if _, err := fmt.Fprintf(w, "	C_%d -> C_%d [label=\"x%d\"];\n", i, sub.Id, len(sub.Allocations)); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+func (rc *RootCircuit) toJSON() *jsonRootCircuit {
+	res := &jsonRootCircuit{
+		Field:                   rc.Field.String(),
+		NumPublicInputs:         rc.NumPublicInputs,
+		NumActualOutputs:        rc.NumActualOutputs,
+		ExpectedNumOutputZeroes: rc.ExpectedNumOutputZeroes,
+		Layers:                  rc.Layers,
+	}
+	for i, c := range rc.Circuits {
+		jc := jsonCircuit{Id: i, InputLen: c.InputLen, OutputLen: c.OutputLen}
+		for _, sub := range c.SubCircuits {
+			jc.SubCircuits = append(jc.SubCircuits, jsonSubCircuit(sub))
+		}
+		for _, g := range c.Mul {
+			jc.Gates = append(jc.Gates, jsonGate{Type: "mul", In: []uint64{g.In0, g.In1}, Out: g.Out, Coef: g.Coef.String(), CoefType: g.CoefType, PublicInputId: g.PublicInputId})
+		}
+		for _, g := range c.Add {
+			jc.Gates = append(jc.Gates, jsonGate{Type: "add", In: []uint64{g.In}, Out: g.Out, Coef: g.Coef.String(), CoefType: g.CoefType, PublicInputId: g.PublicInputId})
+		}
+		for _, g := range c.Cst {
+			jc.Gates = append(jc.Gates, jsonGate{Type: "cst", Out: g.Out, Coef: g.Coef.String(), CoefType: g.CoefType, PublicInputId: g.PublicInputId})
+		}
+		for _, g := range c.Custom {
+			jc.Gates = append(jc.Gates, jsonGate{Type: "custom", In: g.In, Out: g.Out, Coef: g.Coef.String(), CoefType: g.CoefType, PublicInputId: g.PublicInputId, GateType: g.GateType})
+		}
+		res.Circuits = append(res.Circuits, jc)
+	}
+	return res
+}
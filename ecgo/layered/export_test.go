@@ -0,0 +1,36 @@
+package layered
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	rc := sampleRootCircuit()
+
+	var buf bytes.Buffer
+	if err := rc.Export(&buf, ExportCallGraph); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "C_1 -> C_0 [label=\"x1\"]") {
+		t.Fatalf("missing subcircuit edge in\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := rc.Export(&buf, ExportJSON); err != nil {
+		t.Fatal(err)
+	}
+	var res jsonRootCircuit
+	if err := json.Unmarshal(buf.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Circuits) != 3 || len(res.Circuits[1].Gates) != 3 || res.Circuits[2].Gates[2].GateType != 12345 {
+		t.Fatalf("unexpected JSON export %s", buf.String())
+	}
+
+	if err := rc.Export(&buf, ExportFormat(42)); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
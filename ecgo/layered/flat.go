@@ -0,0 +1,45 @@
+package layered
+
+// FlatLayer returns layer i of the circuit with the gates of its subcircuits inlined at their
+// allocations, for the provers and formats without subcircuits. The gates are copies, which
+// share their coefficients with the circuit.
+func (rc *RootCircuit) FlatLayer(i int) *Circuit {
+	c := rc.Circuits[rc.Layers[i]]
+	res := &Circuit{InputLen: c.InputLen, OutputLen: c.OutputLen}
+	rc.inline(res, rc.Layers[i], 0, func(o uint64) uint64 { return o })
+	return res
+}
+
+// inline appends the gates of circuit id and its subcircuits to dst, with the inputs shifted by
+// inOffset and the outputs mapped by out
+func (rc *RootCircuit) inline(dst *Circuit, id uint64, inOffset uint64, out func(uint64) uint64) {
+	c := rc.Circuits[id]
+	for _, g := range c.Mul {
+		g.In0 += inOffset
+		g.In1 += inOffset
+		g.Out = out(g.Out)
+		dst.Mul = append(dst.Mul, g)
+	}
+	for _, g := range c.Add {
+		g.In += inOffset
+		g.Out = out(g.Out)
+		dst.Add = append(dst.Add, g)
+	}
+	for _, g := range c.Cst {
+		g.Out = out(g.Out)
+		dst.Cst = append(dst.Cst, g)
+	}
+	for _, g := range c.Custom {
+		g.In = append([]uint64(nil), g.In...)
+		for i := range g.In {
+			g.In[i] += inOffset
+		}
+		g.Out = out(g.Out)
+		dst.Custom = append(dst.Custom, g)
+	}
+	for _, sub := range c.SubCircuits {
+		for _, alloc := range sub.Allocations {
+			rc.inline(dst, sub.Id, inOffset+alloc.InputOffset, func(o uint64) uint64 { return out(alloc.OutputOffset + o) })
+		}
+	}
+}
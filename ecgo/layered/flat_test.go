@@ -0,0 +1,18 @@
+package layered
+
+import "testing"
+
+func TestFlatLayer(t *testing.T) {
+	rc := sampleRootCircuit()
+	rc.Circuits[1].SubCircuits[0].Allocations = append(rc.Circuits[1].SubCircuits[0].Allocations, Allocation{InputOffset: 2, OutputOffset: 1})
+	c := rc.FlatLayer(0)
+	if len(c.SubCircuits) != 0 || len(c.Mul) != 2 || len(c.Add) != 2 || len(c.Cst) != 1 {
+		t.Fatalf("unexpected flat layer %+v", c)
+	}
+	if g := c.Mul[1]; g.In0 != 2 || g.In1 != 3 || g.Out != 1 {
+		t.Fatalf("unexpected inlined gate %+v", g)
+	}
+	if c.InputLen != 4 || c.OutputLen != 2 {
+		t.Fatalf("unexpected lengths %d and %d", c.InputLen, c.OutputLen)
+	}
+}
@@ -0,0 +1,339 @@
+package layered
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils"
+)
+
+// MappedCircuit is a layered circuit read in place from its file, mapped in memory, instead of
+// deserialized into a RootCircuit, so that opening a large circuit doesn't allocate its gates.
+// Opening scans the file once to find the circuits; their gates are decoded when they're
+// visited. The pages of the file are loaded by the OS when they're read, and shared between the
+// processes mapping the same file.
+//
+// A MappedCircuit must not be used after Close.
+type MappedCircuit struct {
+	Header                  Header
+	NumPublicInputs         int
+	NumActualOutputs        int
+	ExpectedNumOutputZeroes int
+	Field                   *big.Int
+	Layers                  []uint64
+
+	// payload is the circuit in the format of Serialize, in the mapped file
+	payload  []byte
+	bnlen    int
+	circuits []CircuitView
+	unmap    func() error
+}
+
+// CircuitView is a circuit of a MappedCircuit. Its gates are decoded by the visiting methods.
+type CircuitView struct {
+	InputLen, OutputLen uint64
+	NumSubCircuits      int
+	NumMul              int
+	NumAdd              int
+	NumCst              int
+	NumCustom           int
+
+	m *MappedCircuit
+	// offsets of the first subcircuit and gates of each kind in the payload
+	sub, mul, add, cst, custom int
+}
+
+// OpenMapped maps the circuit file written by Serialize or SerializeVersioned, whose header must
+// pass Header.Check with Supported.
+func OpenMapped(path string) (*MappedCircuit, error) {
+	data, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newMapped(data)
+	if err != nil {
+		unmap()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	m.unmap = unmap
+	return m, nil
+}
+
+// Close unmaps the file.
+func (m *MappedCircuit) Close() error {
+	m.circuits, m.payload = nil, nil
+	return m.unmap()
+}
+
+// Bytes returns the circuit in the format of Serialize, in place, e.g. for the Rust prover. It's
+// only valid until Close and must not be modified.
+func (m *MappedCircuit) Bytes() []byte {
+	return m.payload
+}
+
+// ContentHash returns the content hash of the circuit, like RootCircuit.ContentHash of Load. The
+// mapped payload is in the format of Serialize, so it's hashed in place, without decoding the
+// gates or holding the circuit in the Go heap.
+func (m *MappedCircuit) ContentHash() [32]byte {
+	return sha256.Sum256(m.payload)
+}
+
+// NumCircuits returns the number of circuits, like len(RootCircuit.Circuits).
+func (m *MappedCircuit) NumCircuits() int {
+	return len(m.circuits)
+}
+
+// Circuit returns the circuit of the given id.
+func (m *MappedCircuit) Circuit(id uint64) *CircuitView {
+	return &m.circuits[id]
+}
+
+// Layer returns the circuit of layer i.
+func (m *MappedCircuit) Layer(i int) *CircuitView {
+	return &m.circuits[m.Layers[i]]
+}
+
+// Load deserializes the whole circuit, like DeserializeRootCircuit.
+func (m *MappedCircuit) Load() *RootCircuit {
+	return DeserializeRootCircuit(m.payload)
+}
+
+// newMapped scans the circuit file data, checking that its records are within bounds
+func newMapped(data []byte) (*MappedCircuit, error) {
+	if IsCompact(data) {
+		return nil, errors.New("compact circuits can't be mapped, see ExpandCompact")
+	}
+	h, payload, err := ReadHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Check(Supported); err != nil {
+		return nil, err
+	}
+	r := &scanner{buf: payload}
+	if r.uint64() != MAGIC {
+		return nil, errors.New("invalid file header")
+	}
+	r.need(32)
+	if r.err != nil {
+		return nil, r.err
+	}
+	m := &MappedCircuit{Header: *h, payload: payload}
+	m.Field = utils.NewInputBuf(payload[8:]).ReadBigInt(32)
+	r.pos += 32
+	id, err := fieldIdOf(m.Field)
+	if err != nil {
+		return nil, err
+	}
+	m.bnlen = field.GetFieldById(id).SerializedLen()
+	m.NumPublicInputs = int(r.uint64())
+	m.NumActualOutputs = int(r.uint64())
+	m.ExpectedNumOutputZeroes = int(r.uint64())
+	nbCircuits := r.count(16)
+	m.circuits = make([]CircuitView, 0, nbCircuits)
+	for i := 0; i < nbCircuits && r.err == nil; i++ {
+		c := CircuitView{m: m}
+		c.InputLen = r.uint64()
+		c.OutputLen = r.uint64()
+		c.NumSubCircuits = r.count(16)
+		c.sub = r.pos
+		for j := 0; j < c.NumSubCircuits && r.err == nil; j++ {
+			r.uint64()
+			r.skip(16 * r.count(16))
+		}
+		c.NumMul = r.count(25)
+		c.mul = r.pos
+		for j := 0; j < c.NumMul && r.err == nil; j++ {
+			r.skip(24)
+			r.coef(m.bnlen)
+		}
+		c.NumAdd = r.count(17)
+		c.add = r.pos
+		for j := 0; j < c.NumAdd && r.err == nil; j++ {
+			r.skip(16)
+			r.coef(m.bnlen)
+		}
+		c.NumCst = r.count(9)
+		c.cst = r.pos
+		for j := 0; j < c.NumCst && r.err == nil; j++ {
+			r.skip(8)
+			r.coef(m.bnlen)
+		}
+		c.NumCustom = r.count(25)
+		c.custom = r.pos
+		for j := 0; j < c.NumCustom && r.err == nil; j++ {
+			r.uint64()
+			r.skip(8 * r.count(8))
+			r.uint64()
+			r.coef(m.bnlen)
+		}
+		m.circuits = append(m.circuits, c)
+	}
+	m.Layers = make([]uint64, r.count(8))
+	for i := range m.Layers {
+		m.Layers[i] = r.uint64()
+	}
+	if r.err == nil && r.pos != len(payload) {
+		r.err = errors.New("invalid binary format")
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	for _, l := range m.Layers {
+		if l >= uint64(len(m.circuits)) {
+			return nil, fmt.Errorf("layer of unknown circuit %d", l)
+		}
+	}
+	return m, nil
+}
+
+// scanner reads the records of a circuit file, recording the first out of bounds read
+type scanner struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *scanner) need(n int) bool {
+	if r.err == nil && (n < 0 || n > len(r.buf)-r.pos) {
+		r.err = errors.New("truncated circuit")
+	}
+	return r.err == nil
+}
+
+func (r *scanner) uint64() uint64 {
+	if !r.need(8) {
+		return 0
+	}
+	x := binary.LittleEndian.Uint64(r.buf[r.pos:])
+	r.pos += 8
+	return x
+}
+
+func (r *scanner) skip(n int) {
+	if r.need(n) {
+		r.pos += n
+	}
+}
+
+// count reads the length of a list whose records have at least size bytes
+func (r *scanner) count(size int) int {
+	n := r.uint64()
+	if r.err == nil && n > uint64(len(r.buf)-r.pos)/uint64(size) {
+		r.err = errors.New("truncated circuit")
+	}
+	if r.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// coef skips a coefficient, see serializeCoef
+func (r *scanner) coef(bnlen int) {
+	if !r.need(1) {
+		return
+	}
+	t := r.buf[r.pos]
+	r.pos++
+	switch t {
+	case 1:
+		r.skip(bnlen)
+	case 2:
+	case 3:
+		r.skip(8)
+	default:
+		r.err = errors.New("invalid coefficient type")
+	}
+}
+
+// SubCircuits calls f on the subcircuit calls of the circuit, until it returns false.
+func (c *CircuitView) SubCircuits(f func(SubCircuit) bool) {
+	in := utils.NewInputBuf(c.m.payload[c.sub:])
+	for i := 0; i < c.NumSubCircuits; i++ {
+		sub := SubCircuit{Id: in.ReadUint64()}
+		sub.Allocations = make([]Allocation, in.ReadUint64())
+		for k := range sub.Allocations {
+			sub.Allocations[k].InputOffset = in.ReadUint64()
+			sub.Allocations[k].OutputOffset = in.ReadUint64()
+		}
+		if !f(sub) {
+			return
+		}
+	}
+}
+
+// Mul calls f on the multiplication gates of the circuit, until it returns false.
+func (c *CircuitView) Mul(f func(GateMul) bool) {
+	in := utils.NewInputBuf(c.m.payload[c.mul:])
+	for i := 0; i < c.NumMul; i++ {
+		g := GateMul{In0: in.ReadUint64(), In1: in.ReadUint64(), Out: in.ReadUint64()}
+		g.Coef, g.CoefType, g.PublicInputId = deserializeCoef(in, c.m.bnlen)
+		if !f(g) {
+			return
+		}
+	}
+}
+
+// Add calls f on the addition gates of the circuit, until it returns false.
+func (c *CircuitView) Add(f func(GateAdd) bool) {
+	in := utils.NewInputBuf(c.m.payload[c.add:])
+	for i := 0; i < c.NumAdd; i++ {
+		g := GateAdd{In: in.ReadUint64(), Out: in.ReadUint64()}
+		g.Coef, g.CoefType, g.PublicInputId = deserializeCoef(in, c.m.bnlen)
+		if !f(g) {
+			return
+		}
+	}
+}
+
+// Cst calls f on the constant gates of the circuit, until it returns false.
+func (c *CircuitView) Cst(f func(GateCst) bool) {
+	in := utils.NewInputBuf(c.m.payload[c.cst:])
+	for i := 0; i < c.NumCst; i++ {
+		g := GateCst{Out: in.ReadUint64()}
+		g.Coef, g.CoefType, g.PublicInputId = deserializeCoef(in, c.m.bnlen)
+		if !f(g) {
+			return
+		}
+	}
+}
+
+// Custom calls f on the custom gates of the circuit, until it returns false.
+func (c *CircuitView) Custom(f func(GateCustom) bool) {
+	in := utils.NewInputBuf(c.m.payload[c.custom:])
+	for i := 0; i < c.NumCustom; i++ {
+		g := GateCustom{GateType: in.ReadUint64()}
+		g.In = make([]uint64, in.ReadUint64())
+		for k := range g.In {
+			g.In[k] = in.ReadUint64()
+		}
+		g.Out = in.ReadUint64()
+		g.Coef, g.CoefType, g.PublicInputId = deserializeCoef(in, c.m.bnlen)
+		if !f(g) {
+			return
+		}
+	}
+}
+
+// Circuit decodes the whole circuit, like an element of RootCircuit.Circuits.
+func (c *CircuitView) Circuit() *Circuit {
+	res := &Circuit{
+		InputLen:    c.InputLen,
+		OutputLen:   c.OutputLen,
+		SubCircuits: make([]SubCircuit, 0, c.NumSubCircuits),
+		Mul:         make([]GateMul, 0, c.NumMul),
+		Add:         make([]GateAdd, 0, c.NumAdd),
+		Cst:         make([]GateCst, 0, c.NumCst),
+		Custom:      make([]GateCustom, 0, c.NumCustom),
+	}
+	c.SubCircuits(func(s SubCircuit) bool { res.SubCircuits = append(res.SubCircuits, s); return true })
+	c.Mul(func(g GateMul) bool { res.Mul = append(res.Mul, g); return true })
+	c.Add(func(g GateAdd) bool { res.Add = append(res.Add, g); return true })
+	c.Cst(func(g GateCst) bool { res.Cst = append(res.Cst, g); return true })
+	c.Custom(func(g GateCustom) bool { res.Custom = append(res.Custom, g); return true })
+	return res
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package layered
+
+import "os"
+
+// mapFile reads the file, on systems without mmap support in the syscall package
+func mapFile(path string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,61 @@
+package layered
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenMapped(t *testing.T) {
+	rc := sampleRootCircuit()
+	for _, buf := range [][]byte{rc.Serialize(), rc.SerializeVersioned(FeatureLookups, nil)} {
+		fn := filepath.Join(t.TempDir(), "circuit.txt")
+		if err := os.WriteFile(fn, buf, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		m, err := OpenMapped(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.NumCircuits() != len(rc.Circuits) || m.Layer(1).NumCustom != 1 || m.NumPublicInputs != 1 || m.Field.Cmp(rc.Field) != 0 {
+			t.Fatalf("unexpected mapped circuit %+v", m)
+		}
+		loaded := &RootCircuit{
+			NumPublicInputs:         m.NumPublicInputs,
+			NumActualOutputs:        m.NumActualOutputs,
+			ExpectedNumOutputZeroes: m.ExpectedNumOutputZeroes,
+			Layers:                  m.Layers,
+			Field:                   m.Field,
+		}
+		for id := 0; id < m.NumCircuits(); id++ {
+			loaded.Circuits = append(loaded.Circuits, m.Circuit(uint64(id)).Circuit())
+		}
+		if !bytes.Equal(loaded.Serialize(), rc.Serialize()) || !bytes.Equal(m.Bytes(), rc.Serialize()) || !bytes.Equal(m.Load().Serialize(), rc.Serialize()) {
+			t.Fatal("the mapped circuit differs from the original one")
+		}
+		if m.ContentHash() != rc.ContentHash() {
+			t.Fatal("the content hash of the mapped circuit differs from the original one")
+		}
+		visited := 0
+		m.Layer(0).Add(func(g GateAdd) bool {
+			visited++
+			return false
+		})
+		if visited != 1 {
+			t.Fatalf("expected the visit to stop after the first gate, got %d gates", visited)
+		}
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fn := filepath.Join(t.TempDir(), "truncated.txt")
+	buf := rc.Serialize()
+	if err := os.WriteFile(fn, buf[:len(buf)-5], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenMapped(fn); err == nil {
+		t.Fatal("expected a truncated circuit to fail")
+	}
+}
@@ -0,0 +1,130 @@
+package layered
+
+import "math/big"
+
+// PaddingStrategy selects the widths of the layers of a padded circuit.
+type PaddingStrategy int
+
+const (
+	// PadPerLayer pads each layer to the next power of 2 of its own width, as the compiler does.
+	PadPerLayer PaddingStrategy = iota
+	// PadToMaxWidth pads every layer to the width of the widest one, except the inputs of the
+	// first layer, which are the inputs of the witnesses. Some provers handle uniform layers
+	// better, at the cost of more padding wires.
+	PadToMaxWidth
+)
+
+// PaddingGates selects the gates written to the padding wires.
+type PaddingGates int
+
+const (
+	// NoPaddingGates leaves the padding wires without gates, so they are zero.
+	NoPaddingGates PaddingGates = iota
+	// RelayPaddingGates writes each unused output wire of a layer from the input wire at the same
+	// position with an add gate, so that the layer is dense. Wires read by the next layer and the
+	// outputs of the last layer are left alone, since they may rely on being zero.
+	RelayPaddingGates
+)
+
+// Padding configures RootCircuit.Pad.
+type Padding struct {
+	Strategy PaddingStrategy
+	Gates    PaddingGates
+}
+
+// IsDefault returns whether the padding is the one of the compiler, for which Pad returns an
+// equivalent circuit.
+func (p Padding) IsDefault() bool {
+	return p.Strategy == PadPerLayer && p.Gates == NoPaddingGates
+}
+
+// Pad returns the circuit with its layers padded according to p. The circuits of rc are shared
+// with the result: each modified layer calls the original one as a subcircuit, and adds the
+// padding gates. The outputs and the inputs of the witnesses are unchanged.
+func (rc *RootCircuit) Pad(p Padding) *RootCircuit {
+	res := &RootCircuit{
+		NumPublicInputs:         rc.NumPublicInputs,
+		NumActualOutputs:        rc.NumActualOutputs,
+		ExpectedNumOutputZeroes: rc.ExpectedNumOutputZeroes,
+		Circuits:                append([]*Circuit(nil), rc.Circuits...),
+		Field:                   rc.Field,
+	}
+	var width uint64
+	if p.Strategy == PadToMaxWidth {
+		for _, id := range rc.Layers {
+			c := rc.Circuits[id]
+			width = max(width, c.InputLen, c.OutputLen)
+		}
+	}
+	for i, id := range rc.Layers {
+		c := rc.Circuits[id]
+		lc := &Circuit{
+			InputLen:    max(c.InputLen, width),
+			OutputLen:   max(c.OutputLen, width),
+			SubCircuits: []SubCircuit{{Id: id, Allocations: []Allocation{{}}}},
+		}
+		if i == 0 {
+			lc.InputLen = c.InputLen
+		}
+		if p.Gates == RelayPaddingGates && i != len(rc.Layers)-1 {
+			written := rc.writtenWires(id)
+			read := rc.readWires(rc.Layers[i+1])
+			for j := uint64(0); j < lc.OutputLen && j < lc.InputLen; j++ {
+				if (j < uint64(len(written)) && written[j]) || (j < uint64(len(read)) && read[j]) {
+					continue
+				}
+				lc.Add = append(lc.Add, GateAdd{In: j, Out: j, Coef: big.NewInt(1), CoefType: 1})
+			}
+		}
+		if lc.InputLen == c.InputLen && lc.OutputLen == c.OutputLen && len(lc.Add) == 0 {
+			res.Layers = append(res.Layers, id)
+			continue
+		}
+		res.Layers = append(res.Layers, uint64(len(res.Circuits)))
+		res.Circuits = append(res.Circuits, lc)
+	}
+	return res
+}
+
+// visitGates calls f on every gate of circuit id and its subcircuits, with the offsets of the
+// instance the gate belongs to
+func (rc *RootCircuit) visitGates(id uint64, inOffset, outOffset uint64, f func(g Gate, inOffset, outOffset uint64)) {
+	c := rc.Circuits[id]
+	for _, g := range c.Mul {
+		f(g, inOffset, outOffset)
+	}
+	for _, g := range c.Add {
+		f(g, inOffset, outOffset)
+	}
+	for _, g := range c.Cst {
+		f(g, inOffset, outOffset)
+	}
+	for _, g := range c.Custom {
+		f(g, inOffset, outOffset)
+	}
+	for _, sub := range c.SubCircuits {
+		for _, a := range sub.Allocations {
+			rc.visitGates(sub.Id, inOffset+a.InputOffset, outOffset+a.OutputOffset, f)
+		}
+	}
+}
+
+// writtenWires returns whether each output wire of circuit id is written by a gate
+func (rc *RootCircuit) writtenWires(id uint64) []bool {
+	res := make([]bool, rc.Circuits[id].OutputLen)
+	rc.visitGates(id, 0, 0, func(g Gate, _, outOffset uint64) {
+		res[outOffset+g.OutWire()] = true
+	})
+	return res
+}
+
+// readWires returns whether each input wire of circuit id is read by a gate
+func (rc *RootCircuit) readWires(id uint64) []bool {
+	res := make([]bool, rc.Circuits[id].InputLen)
+	rc.visitGates(id, 0, 0, func(g Gate, inOffset, _ uint64) {
+		for _, x := range g.InWires() {
+			res[inOffset+x] = true
+		}
+	})
+	return res
+}
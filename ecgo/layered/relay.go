@@ -0,0 +1,587 @@
+package layered
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// RelayStrategy selects how RootCircuit.Reroute handles the values carried across layers by
+// relay gates, the add gates copying an input wire to an output wire with coefficient 1.
+type RelayStrategy int
+
+const (
+	// KeepRelays leaves the relay gates of the compiler.
+	KeepRelays RelayStrategy = iota
+	// ShareRelays restructures the relays: when several wires of a layer carry the same value,
+	// the next layer reads one of them, and the relay gates of the others are removed, as well as
+	// the relay gates whose outputs are no longer read.
+	ShareRelays
+	// RecomputeRelays recomputes the linear combinations relayed across several layers in the
+	// layer reading them, from their terms, when the terms reach that layer and it takes fewer
+	// gates than relaying the combination. The relays are then shared like with ShareRelays.
+	RecomputeRelays
+)
+
+// RelayStats describes the relay gates of a circuit, see RootCircuit.RelayStats.
+type RelayStats struct {
+	// RelayGates is the number of relay gates, and RelayedValues the number of distinct values
+	// they carry.
+	RelayGates    uint64
+	RelayedValues uint64
+	// DuplicateRelays is the number of relay gates carrying a value carried by another wire of
+	// their layer, which ShareRelays removes unless they're in subcircuits.
+	DuplicateRelays uint64
+	// MaxLifetime is the largest number of layers a value is relayed through.
+	MaxLifetime int
+	// Layers holds the number of relay gates of each layer.
+	Layers []uint64
+}
+
+// String returns a human-readable report of the statistics.
+func (s *RelayStats) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "relay gates: %d, relayed values: %d, duplicate relays: %d, max lifetime: %d layers\n",
+		s.RelayGates, s.RelayedValues, s.DuplicateRelays, s.MaxLifetime)
+	for i, n := range s.Layers {
+		if n != 0 {
+			fmt.Fprintf(&sb, "layer %d: %d relays\n", i, n)
+		}
+	}
+	return sb.String()
+}
+
+func isRelay(g GateAdd) bool {
+	return g.CoefType == 1 && g.Coef.Cmp(big.NewInt(1)) == 0
+}
+
+// relayAnalysis follows the values of a circuit across its layers. Boundary i is the inputs of
+// layer i, and boundary len(Layers) the outputs of the last layer.
+type relayAnalysis struct {
+	// values[b][w] identifies the value carried by wire w of boundary b: a wire written only by
+	// a relay gate carries the value of its input, and the other wires carry new values
+	values [][]uint64
+	// src[i][w] is the input wire copied to output wire w of layer i, if it's written only by a
+	// relay gate, or -1
+	src [][]int64
+	// top[i][w] is the index in Add of that relay gate, or -1 if it's in a subcircuit
+	top [][]int
+	// subRead[i][w] is whether input wire w of layer i is read by a gate of a subcircuit
+	subRead [][]bool
+	// birth[v] is the boundary where value v appears
+	birth []int
+}
+
+func (rc *RootCircuit) analyzeRelays() *relayAnalysis {
+	a := &relayAnalysis{
+		values:  make([][]uint64, len(rc.Layers)+1),
+		src:     make([][]int64, len(rc.Layers)),
+		top:     make([][]int, len(rc.Layers)),
+		subRead: make([][]bool, len(rc.Layers)),
+	}
+	fresh := func(b int) uint64 {
+		a.birth = append(a.birth, b)
+		return uint64(len(a.birth) - 1)
+	}
+	a.values[0] = make([]uint64, rc.Circuits[rc.Layers[0]].InputLen)
+	for w := range a.values[0] {
+		a.values[0][w] = fresh(0)
+	}
+	for i, id := range rc.Layers {
+		c := rc.Circuits[id]
+		writers := make([]int, c.OutputLen)
+		src := make([]int64, c.OutputLen)
+		top := make([]int, c.OutputLen)
+		subRead := make([]bool, c.InputLen)
+		for w := range src {
+			src[w], top[w] = -1, -1
+		}
+		for _, g := range c.Mul {
+			writers[g.Out]++
+		}
+		for j, g := range c.Add {
+			writers[g.Out]++
+			if isRelay(g) {
+				src[g.Out], top[g.Out] = int64(g.In), j
+			}
+		}
+		for _, g := range c.Cst {
+			writers[g.Out]++
+		}
+		for _, g := range c.Custom {
+			writers[g.Out]++
+		}
+		for _, sub := range c.SubCircuits {
+			for _, alloc := range sub.Allocations {
+				rc.visitGates(sub.Id, alloc.InputOffset, alloc.OutputOffset, func(g Gate, inOffset, outOffset uint64) {
+					out := outOffset + g.OutWire()
+					writers[out]++
+					if add, ok := g.(GateAdd); ok && isRelay(add) {
+						src[out], top[out] = int64(inOffset+add.In), -1
+					}
+					for _, x := range g.InWires() {
+						subRead[inOffset+x] = true
+					}
+				})
+			}
+		}
+		values := make([]uint64, c.OutputLen)
+		for w := range values {
+			if writers[w] != 1 || src[w] < 0 || src[w] >= int64(len(a.values[i])) {
+				src[w], top[w] = -1, -1
+				values[w] = fresh(i + 1)
+			} else {
+				values[w] = a.values[i][src[w]]
+			}
+		}
+		a.values[i+1], a.src[i], a.top[i], a.subRead[i] = values, src, top, subRead
+	}
+	return a
+}
+
+// RelayStats returns the statistics of the relay gates of the circuit, including those of its
+// subcircuits.
+func (rc *RootCircuit) RelayStats() *RelayStats {
+	a := rc.analyzeRelays()
+	res := &RelayStats{Layers: make([]uint64, len(rc.Layers))}
+	relayed := make(map[uint64]bool)
+	for i := range rc.Layers {
+		seen := make(map[uint64]bool)
+		for w, v := range a.values[i+1] {
+			if seen[v] {
+				res.DuplicateRelays++
+			}
+			seen[v] = true
+			if a.src[i][w] < 0 {
+				continue
+			}
+			res.RelayGates++
+			res.Layers[i]++
+			relayed[v] = true
+			res.MaxLifetime = max(res.MaxLifetime, i+1-a.birth[v])
+		}
+	}
+	res.RelayedValues = uint64(len(relayed))
+	return res
+}
+
+// Reroute returns the circuit with its relay gates handled according to s. The circuits of rc
+// are shared with the result, and only the gates of the layers themselves are modified, so the
+// relays inside subcircuits are kept. The inputs and outputs are unchanged. Reroute must be
+// applied before Pad, since it removes the relay padding gates, whose outputs aren't read.
+func (rc *RootCircuit) Reroute(s RelayStrategy) *RootCircuit {
+	if s == KeepRelays {
+		return rc
+	}
+	res := rc
+	if s == RecomputeRelays {
+		res = res.recomputeRelays()
+	}
+	return res.shareRelays().removeDeadRelays()
+}
+
+// layerEditor modifies the layers of a circuit, each copied on its first modification, since
+// layers may share their circuit
+type layerEditor struct {
+	res       *RootCircuit
+	copied    []bool
+	removeAdd []map[int]bool
+	removeMul []map[int]bool
+}
+
+func newLayerEditor(rc *RootCircuit) *layerEditor {
+	return &layerEditor{
+		res: &RootCircuit{
+			NumPublicInputs:         rc.NumPublicInputs,
+			NumActualOutputs:        rc.NumActualOutputs,
+			ExpectedNumOutputZeroes: rc.ExpectedNumOutputZeroes,
+			Circuits:                append([]*Circuit(nil), rc.Circuits...),
+			Layers:                  append([]uint64(nil), rc.Layers...),
+			Field:                   rc.Field,
+		},
+		copied:    make([]bool, len(rc.Layers)),
+		removeAdd: make([]map[int]bool, len(rc.Layers)),
+		removeMul: make([]map[int]bool, len(rc.Layers)),
+	}
+}
+
+// layer returns the circuit of layer i, which may be modified
+func (e *layerEditor) layer(i int) *Circuit {
+	if !e.copied[i] {
+		c := e.res.Circuits[e.res.Layers[i]]
+		e.res.Layers[i] = uint64(len(e.res.Circuits))
+		e.res.Circuits = append(e.res.Circuits, &Circuit{
+			InputLen:    c.InputLen,
+			OutputLen:   c.OutputLen,
+			SubCircuits: c.SubCircuits,
+			Mul:         append([]GateMul(nil), c.Mul...),
+			Add:         append([]GateAdd(nil), c.Add...),
+			Cst:         append([]GateCst(nil), c.Cst...),
+			Custom:      append([]GateCustom(nil), c.Custom...),
+		})
+		e.copied[i] = true
+		e.removeAdd[i] = make(map[int]bool)
+		e.removeMul[i] = make(map[int]bool)
+	}
+	return e.res.Circuits[e.res.Layers[i]]
+}
+
+// redirect makes the gates of layer i read the input wires given by m instead of its keys
+func (e *layerEditor) redirect(i int, m map[uint64]uint64) {
+	c := e.layer(i)
+	to := func(x uint64) uint64 {
+		if y, ok := m[x]; ok {
+			return y
+		}
+		return x
+	}
+	for j := range c.Mul {
+		c.Mul[j].In0, c.Mul[j].In1 = to(c.Mul[j].In0), to(c.Mul[j].In1)
+	}
+	for j := range c.Add {
+		c.Add[j].In = to(c.Add[j].In)
+	}
+	for j := range c.Custom {
+		in := make([]uint64, len(c.Custom[j].In))
+		for k, x := range c.Custom[j].In {
+			in[k] = to(x)
+		}
+		c.Custom[j].In = in
+	}
+}
+
+// readWires returns whether each input wire of layer i is read by a gate which isn't removed
+func (e *layerEditor) readWires(i int) []bool {
+	c := e.res.Circuits[e.res.Layers[i]]
+	res := make([]bool, c.InputLen)
+	for j, g := range c.Mul {
+		if !e.removeMul[i][j] {
+			res[g.In0], res[g.In1] = true, true
+		}
+	}
+	for j, g := range c.Add {
+		if !e.removeAdd[i][j] {
+			res[g.In] = true
+		}
+	}
+	for _, g := range c.Custom {
+		for _, x := range g.In {
+			res[x] = true
+		}
+	}
+	for _, sub := range c.SubCircuits {
+		for _, alloc := range sub.Allocations {
+			e.res.visitGates(sub.Id, alloc.InputOffset, alloc.OutputOffset, func(g Gate, inOffset, _ uint64) {
+				for _, x := range g.InWires() {
+					res[inOffset+x] = true
+				}
+			})
+		}
+	}
+	return res
+}
+
+// finish removes the gates marked for removal, and returns the modified circuit
+func (e *layerEditor) finish() *RootCircuit {
+	for i, copied := range e.copied {
+		if !copied {
+			continue
+		}
+		c := e.res.Circuits[e.res.Layers[i]]
+		add := c.Add[:0]
+		for j, g := range c.Add {
+			if !e.removeAdd[i][j] {
+				add = append(add, g)
+			}
+		}
+		c.Add = add
+		mul := c.Mul[:0]
+		for j, g := range c.Mul {
+			if !e.removeMul[i][j] {
+				mul = append(mul, g)
+			}
+		}
+		c.Mul = mul
+	}
+	return e.res
+}
+
+// shareRelays removes the relay gates carrying a value carried by another wire of their layer,
+// the next layer reading the other wire
+func (rc *RootCircuit) shareRelays() *RootCircuit {
+	a := rc.analyzeRelays()
+	e := newLayerEditor(rc)
+	for i := 0; i+1 < len(rc.Layers); i++ {
+		// the wire kept for each value: the one computing it, or the first relaying it
+		kept := make(map[uint64]uint64)
+		for pass := 0; pass < 2; pass++ {
+			for w, v := range a.values[i+1] {
+				if _, ok := kept[v]; !ok && (pass == 1 || a.src[i][w] < 0) {
+					kept[v] = uint64(w)
+				}
+			}
+		}
+		m := make(map[uint64]uint64)
+		for w, v := range a.values[i+1] {
+			if k := kept[v]; k != uint64(w) && a.top[i][w] >= 0 && !a.subRead[i+1][w] {
+				m[uint64(w)] = k
+				e.layer(i)
+				e.removeAdd[i][a.top[i][w]] = true
+			}
+		}
+		if len(m) != 0 {
+			e.redirect(i+1, m)
+		}
+	}
+	return e.finish()
+}
+
+// removeDeadRelays removes the relay gates of the layers whose outputs aren't read by the next
+// layer, from the last layer to the first, since removing relays leaves their inputs unread
+func (rc *RootCircuit) removeDeadRelays() *RootCircuit {
+	e := newLayerEditor(rc)
+	for i := len(rc.Layers) - 2; i >= 0; i-- {
+		read := e.readWires(i + 1)
+		c := e.res.Circuits[e.res.Layers[i]]
+		for j, g := range c.Add {
+			if isRelay(g) && !read[g.Out] {
+				e.layer(i)
+				e.removeAdd[i][j] = true
+			}
+		}
+	}
+	return e.finish()
+}
+
+// linearValue is a value computed by a layer as a linear combination of its inputs, a candidate
+// for recomputation
+type linearValue struct {
+	layer int
+	terms []GateAdd
+	cst   *big.Int
+	// relays is the number of relay gates carrying the value, which are all in the layers
+	relays int
+	// readers are the gates reading the value, other than the relays, all in layer reader
+	reader  int
+	readers []gateRef
+	// invalid is set if the value can't be recomputed
+	invalid bool
+}
+
+// gateRef refers to a mul or add gate of a layer
+type gateRef struct {
+	mul   bool
+	index int
+}
+
+// recomputeRelays recomputes the relayed linear combinations in the layer reading them, when
+// it's cheaper, and leaves their relays unread
+func (rc *RootCircuit) recomputeRelays() *RootCircuit {
+	a := rc.analyzeRelays()
+	last := len(rc.Layers)
+	// the linear combinations computed by the layers, by value
+	linear := make(map[uint64]*linearValue)
+	for i, id := range rc.Layers {
+		c := rc.Circuits[id]
+		values := make(map[uint64]*linearValue)
+		get := func(w uint64) *linearValue {
+			if a.src[i][w] >= 0 {
+				return nil
+			}
+			v := a.values[i+1][w]
+			if values[v] == nil {
+				values[v] = &linearValue{layer: i, cst: big.NewInt(0), reader: -1}
+			}
+			return values[v]
+		}
+		for _, g := range c.Add {
+			if l := get(g.Out); l != nil {
+				l.terms = append(l.terms, g)
+				l.invalid = l.invalid || g.CoefType != 1
+			}
+		}
+		for _, g := range c.Cst {
+			if l := get(g.Out); l != nil {
+				l.cst.Add(l.cst, g.Coef)
+				l.invalid = l.invalid || g.CoefType != 1
+			}
+		}
+		for _, g := range c.Mul {
+			if l := get(g.Out); l != nil {
+				l.invalid = true
+			}
+		}
+		for _, g := range c.Custom {
+			if l := get(g.Out); l != nil {
+				l.invalid = true
+			}
+		}
+		for _, sub := range c.SubCircuits {
+			for _, alloc := range sub.Allocations {
+				rc.visitGates(sub.Id, alloc.InputOffset, alloc.OutputOffset, func(g Gate, _, outOffset uint64) {
+					if l := get(outOffset + g.OutWire()); l != nil {
+						l.invalid = true
+					}
+				})
+			}
+		}
+		for v, l := range values {
+			linear[v] = l
+		}
+	}
+	for _, v := range a.values[last] {
+		if l := linear[v]; l != nil {
+			l.invalid = true
+		}
+	}
+
+	// the readers of the linear combinations
+	read := func(l *linearValue, i int, ref gateRef) {
+		if l == nil || l.invalid {
+			return
+		}
+		if l.reader >= 0 && l.reader != i {
+			l.invalid = true
+			return
+		}
+		l.reader = i
+		l.readers = append(l.readers, ref)
+	}
+	for i := 1; i < last; i++ {
+		c := rc.Circuits[rc.Layers[i]]
+		value := func(x uint64) *linearValue {
+			return linear[a.values[i][x]]
+		}
+		for w, r := range a.subRead[i] {
+			if l := value(uint64(w)); r && l != nil {
+				l.invalid = true
+			}
+		}
+		for j, g := range c.Add {
+			l := value(g.In)
+			if l == nil {
+				continue
+			}
+			if a.top[i][g.Out] == j {
+				l.relays++
+				continue
+			}
+			if g.CoefType != 1 {
+				l.invalid = true
+			}
+			read(l, i, gateRef{index: j})
+		}
+		for j, g := range c.Mul {
+			l0, l1 := value(g.In0), value(g.In1)
+			if l0 == nil && l1 == nil {
+				continue
+			}
+			if l0 == l1 || g.CoefType != 1 {
+				// squares are left alone
+				for _, l := range []*linearValue{l0, l1} {
+					if l != nil {
+						l.invalid = true
+					}
+				}
+				continue
+			}
+			read(l0, i, gateRef{mul: true, index: j})
+			read(l1, i, gateRef{mul: true, index: j})
+		}
+		for _, g := range c.Custom {
+			for _, x := range g.In {
+				if l := value(x); l != nil {
+					l.invalid = true
+				}
+			}
+		}
+	}
+
+	// recompute the combinations whose terms reach their reader, in the order of the values
+	e := newLayerEditor(rc)
+	recomputed := make(map[uint64]bool)
+	used := make(map[uint64]bool)
+	// the gates rewritten, since a product may read two combinations
+	claimed := make(map[[2]int]bool)
+	key := func(layer int, ref gateRef) [2]int {
+		if ref.mul {
+			return [2]int{layer, ref.index}
+		}
+		return [2]int{layer, -ref.index - 1}
+	}
+	wires := make(map[int]map[uint64]uint64)
+	for v := uint64(0); v < uint64(len(a.birth)); v++ {
+		l := linear[v]
+		if l == nil || l.invalid || l.relays == 0 || l.reader <= l.layer+1 || used[v] {
+			continue
+		}
+		extra := len(l.terms) - 1
+		if l.cst.Sign() != 0 {
+			extra++
+		}
+		if l.relays <= extra*len(l.readers) {
+			continue
+		}
+		at, ok := wires[l.reader]
+		if !ok {
+			at = make(map[uint64]uint64)
+			for w, x := range a.values[l.reader] {
+				if _, ok := at[x]; !ok {
+					at[x] = uint64(w)
+				}
+			}
+			wires[l.reader] = at
+		}
+		available := true
+		for _, t := range l.terms {
+			x := a.values[l.layer][t.In]
+			if _, ok := at[x]; !ok || recomputed[x] {
+				available = false
+			}
+		}
+		for _, ref := range l.readers {
+			available = available && !claimed[key(l.reader, ref)]
+		}
+		if !available {
+			continue
+		}
+		for _, ref := range l.readers {
+			claimed[key(l.reader, ref)] = true
+		}
+		recomputed[v] = true
+		for _, t := range l.terms {
+			used[a.values[l.layer][t.In]] = true
+		}
+		c := e.layer(l.reader)
+		coef := func(x, y *big.Int) *big.Int {
+			res := new(big.Int).Mul(x, y)
+			return res.Mod(res, rc.Field)
+		}
+		for _, ref := range l.readers {
+			if !ref.mul {
+				g := c.Add[ref.index]
+				e.removeAdd[l.reader][ref.index] = true
+				for _, t := range l.terms {
+					c.Add = append(c.Add, GateAdd{In: at[a.values[l.layer][t.In]], Out: g.Out, Coef: coef(g.Coef, t.Coef), CoefType: 1})
+				}
+				if l.cst.Sign() != 0 {
+					c.Cst = append(c.Cst, GateCst{Out: g.Out, Coef: coef(g.Coef, l.cst), CoefType: 1})
+				}
+				continue
+			}
+			g := c.Mul[ref.index]
+			e.removeMul[l.reader][ref.index] = true
+			other := g.In1
+			if linear[a.values[l.reader][g.In0]] != l {
+				other = g.In0
+			}
+			for _, t := range l.terms {
+				c.Mul = append(c.Mul, GateMul{In0: at[a.values[l.layer][t.In]], In1: other, Out: g.Out, Coef: coef(g.Coef, t.Coef), CoefType: 1})
+			}
+			if l.cst.Sign() != 0 {
+				c.Add = append(c.Add, GateAdd{In: other, Out: g.Out, Coef: coef(g.Coef, l.cst), CoefType: 1})
+			}
+		}
+	}
+	return e.finish()
+}
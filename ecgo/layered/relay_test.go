@@ -0,0 +1,100 @@
+package layered
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+)
+
+// relaySample computes v = x0+x1 and w = x0*x1, relays them with x0 and x1 through two layers,
+// w twice, and outputs v*w and x0+2w. If sub is set, the first relay layer is a subcircuit.
+func relaySample(sub bool) *RootCircuit {
+	one := func() *big.Int { return big.NewInt(1) }
+	// relays from the input to the output of each pair of wires
+	relays := func(wires ...uint64) []GateAdd {
+		res := make([]GateAdd, len(wires)/2)
+		for i := range res {
+			res[i] = GateAdd{In: wires[2*i], Out: wires[2*i+1], Coef: one(), CoefType: 1}
+		}
+		return res
+	}
+	l0 := &Circuit{
+		InputLen:  2,
+		OutputLen: 4,
+		Add:       relays(0, 0, 1, 0, 0, 1, 1, 2),
+		Mul:       []GateMul{{In0: 0, In1: 1, Out: 3, Coef: one(), CoefType: 1}},
+	}
+	l1 := &Circuit{InputLen: 4, OutputLen: 8, Add: relays(0, 0, 1, 1, 2, 2, 3, 3, 3, 4)}
+	l2 := &Circuit{InputLen: 8, OutputLen: 8, Add: relays(0, 0, 1, 1, 2, 2, 3, 3, 4, 4)}
+	l3 := &Circuit{
+		InputLen:  8,
+		OutputLen: 2,
+		Mul:       []GateMul{{In0: 0, In1: 3, Out: 0, Coef: one(), CoefType: 1}},
+		Add:       []GateAdd{{In: 4, Out: 1, Coef: big.NewInt(2), CoefType: 1}, {In: 1, Out: 1, Coef: one(), CoefType: 1}},
+	}
+	rc := &RootCircuit{
+		NumActualOutputs: 2,
+		Circuits:         []*Circuit{l0, l1, l2, l3},
+		Layers:           []uint64{0, 1, 2, 3},
+		Field:            m31.ScalarField,
+	}
+	if sub {
+		rc.Circuits = append(rc.Circuits, &Circuit{InputLen: 4, OutputLen: 8, SubCircuits: []SubCircuit{{Id: 1, Allocations: []Allocation{{}}}}})
+		rc.Layers[1] = 4
+	}
+	return rc
+}
+
+func TestRelayStats(t *testing.T) {
+	s := relaySample(false).RelayStats()
+	if s.RelayGates != 12 || s.RelayedValues != 4 || s.DuplicateRelays != 2 || s.MaxLifetime != 3 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if s.Layers[0] != 2 || s.Layers[1] != 5 || s.Layers[2] != 5 || s.Layers[3] != 0 {
+		t.Fatalf("unexpected relays per layer %v", s.Layers)
+	}
+	if sub := relaySample(true).RelayStats(); sub.RelayGates != s.RelayGates || sub.DuplicateRelays != s.DuplicateRelays {
+		t.Fatalf("expected the relays of subcircuits to be counted, got %+v", sub)
+	}
+}
+
+func TestReroute(t *testing.T) {
+	input := bigInts(3, 5)
+	for _, test := range []struct {
+		sub      bool
+		strategy RelayStrategy
+		relays   uint64
+	}{
+		{false, KeepRelays, 12},
+		// the duplicates of w are removed, and so are the relays of x1, which isn't read
+		{false, ShareRelays, 7},
+		// v is recomputed from x0 and x1 in the last layer, and its relays removed
+		{false, RecomputeRelays, 8},
+		// the relays of the subcircuit are kept, and v is read by the subcircuit
+		{true, ShareRelays, 10},
+		{true, RecomputeRelays, 10},
+	} {
+		rc := relaySample(test.sub)
+		want, err := rc.Outputs(input, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := rc.Reroute(test.strategy)
+		if n := res.RelayStats().RelayGates; n != test.relays {
+			t.Errorf("sub=%t strategy=%d: expected %d relays, got %d", test.sub, test.strategy, test.relays, n)
+		}
+		if n := rc.RelayStats().RelayGates; n != 12 {
+			t.Errorf("sub=%t strategy=%d: the original circuit was modified, %d relays", test.sub, test.strategy, n)
+		}
+		out, err := res.Outputs(input, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range want {
+			if out[i].Cmp(want[i]) != 0 {
+				t.Fatalf("sub=%t strategy=%d: expected outputs %v, got %v", test.sub, test.strategy, want, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package layered
+
+// nextPowerOfTwo returns the smallest power of 2 which is at least n
+func nextPowerOfTwo(n uint64) uint64 {
+	p := uint64(1)
+	for p < n {
+		p *= 2
+	}
+	return p
+}
+
+// replicator builds the circuits of RootCircuit.Replicate
+type replicator struct {
+	rc  *RootCircuit
+	res *RootCircuit
+	n   int
+	// whether each circuit uses public inputs, directly or in its subcircuits
+	public map[uint64]bool
+	// variants[{id, k}] is the id of the circuit id reading the public inputs of copy k
+	variants map[[2]uint64]uint64
+}
+
+func (r *replicator) usesPublicInputs(id uint64) bool {
+	if p, ok := r.public[id]; ok {
+		return p
+	}
+	c := r.rc.Circuits[id]
+	p := false
+	for _, g := range c.Mul {
+		p = p || g.CoefType == 3
+	}
+	for _, g := range c.Add {
+		p = p || g.CoefType == 3
+	}
+	for _, g := range c.Cst {
+		p = p || g.CoefType == 3
+	}
+	for _, g := range c.Custom {
+		p = p || g.CoefType == 3
+	}
+	for _, sub := range c.SubCircuits {
+		p = p || r.usesPublicInputs(sub.Id)
+	}
+	r.public[id] = p
+	return p
+}
+
+// variant returns the id of circuit id in the result for copy k, which is the original circuit
+// unless it uses public inputs
+func (r *replicator) variant(id uint64, k int) uint64 {
+	if k == 0 || !r.usesPublicInputs(id) {
+		return id
+	}
+	key := [2]uint64{id, uint64(k)}
+	if v, ok := r.variants[key]; ok {
+		return v
+	}
+	shift := uint64(k * r.rc.NumPublicInputs)
+	c := r.rc.Circuits[id]
+	nc := &Circuit{
+		InputLen:  c.InputLen,
+		OutputLen: c.OutputLen,
+		Mul:       append([]GateMul(nil), c.Mul...),
+		Add:       append([]GateAdd(nil), c.Add...),
+		Cst:       append([]GateCst(nil), c.Cst...),
+		Custom:    append([]GateCustom(nil), c.Custom...),
+	}
+	for i := range nc.Mul {
+		if nc.Mul[i].CoefType == 3 {
+			nc.Mul[i].PublicInputId += shift
+		}
+	}
+	for i := range nc.Add {
+		if nc.Add[i].CoefType == 3 {
+			nc.Add[i].PublicInputId += shift
+		}
+	}
+	for i := range nc.Cst {
+		if nc.Cst[i].CoefType == 3 {
+			nc.Cst[i].PublicInputId += shift
+		}
+	}
+	for i := range nc.Custom {
+		if nc.Custom[i].CoefType == 3 {
+			nc.Custom[i].PublicInputId += shift
+		}
+	}
+	for _, sub := range c.SubCircuits {
+		nc.SubCircuits = append(nc.SubCircuits, SubCircuit{Id: r.variant(sub.Id, k), Allocations: sub.Allocations})
+	}
+	v := uint64(len(r.res.Circuits))
+	r.res.Circuits = append(r.res.Circuits, nc)
+	r.variants[key] = v
+	return v
+}
+
+// Replicate returns a circuit made of n independent copies of rc side by side, for data-parallel
+// proving. In each layer, copy k reads the inputs at k times the input length of the layer, and
+// its public inputs from k times NumPublicInputs. The copies call the circuits of rc, which are
+// shared with the result, so the structure is shared too, except for the circuits using public
+// inputs, which are duplicated for each copy.
+//
+// The outputs expected to be zero of all the copies come first, followed by the other outputs
+// of each copy in turn: if some but not all of the outputs are expected to be zero, the gates of
+// the output layer are duplicated to lay them out. Layers are padded to powers of 2, so their
+// inputs and outputs are unused past n copies. The inputs of each copy are laid out in the
+// witnesses by irwg.ReplicateInputs.
+func (rc *RootCircuit) Replicate(n int) *RootCircuit {
+	if n <= 0 {
+		panic("the number of copies must be positive")
+	}
+	res := &RootCircuit{
+		NumPublicInputs:         n * rc.NumPublicInputs,
+		ExpectedNumOutputZeroes: n * rc.ExpectedNumOutputZeroes,
+		Circuits:                append([]*Circuit(nil), rc.Circuits...),
+		Field:                   rc.Field,
+	}
+	r := &replicator{rc: rc, res: res, n: n, public: make(map[uint64]bool), variants: make(map[[2]uint64]uint64)}
+	nn := uint64(n)
+	for i, id := range rc.Layers {
+		c := rc.Circuits[id]
+		lc := &Circuit{
+			InputLen:  nextPowerOfTwo(nn * c.InputLen),
+			OutputLen: nextPowerOfTwo(nn * c.OutputLen),
+		}
+		zeroes := uint64(rc.ExpectedNumOutputZeroes)
+		if i == len(rc.Layers)-1 && zeroes != 0 && zeroes < c.OutputLen {
+			for k := uint64(0); k < nn; k++ {
+				v := r.variant(id, int(k))
+				r.res.inline(lc, v, k*c.InputLen, func(o uint64) uint64 {
+					if o < zeroes {
+						return k*zeroes + o
+					}
+					return nn*zeroes + k*(c.OutputLen-zeroes) + o - zeroes
+				})
+			}
+			res.NumActualOutputs = int(nn*zeroes + (nn-1)*(c.OutputLen-zeroes) + uint64(rc.NumActualOutputs) - zeroes)
+		} else {
+			subs := make(map[uint64]int)
+			for k := uint64(0); k < nn; k++ {
+				v := r.variant(id, int(k))
+				j, ok := subs[v]
+				if !ok {
+					j = len(lc.SubCircuits)
+					subs[v] = j
+					lc.SubCircuits = append(lc.SubCircuits, SubCircuit{Id: v})
+				}
+				lc.SubCircuits[j].Allocations = append(lc.SubCircuits[j].Allocations, Allocation{
+					InputOffset:  k * c.InputLen,
+					OutputOffset: k * c.OutputLen,
+				})
+			}
+			res.NumActualOutputs = int((nn-1)*c.OutputLen) + rc.NumActualOutputs
+		}
+		res.Layers = append(res.Layers, uint64(len(res.Circuits)))
+		res.Circuits = append(res.Circuits, lc)
+	}
+	return res
+}
@@ -1,6 +1,8 @@
 package layered
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
 	"math/big"
 	"os"
 
@@ -8,6 +10,8 @@ import (
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils"
 )
 
+// MAGIC identifies the binary format of serialized layered circuits. It is shared with the Rust
+// implementation and must be changed whenever the layout below changes.
 const MAGIC = 3914834606642317635
 
 func serializeCoef(o *utils.OutputBuf, bnlen int, coef *big.Int, coefType uint8, publicInputId uint64) {
@@ -28,80 +32,167 @@ func deserializeCoef(in *utils.InputBuf, bnlen int) (*big.Int, uint8, uint64) {
 		return in.ReadBigInt(bnlen), 1, 0
 	} else if coefType == 2 {
 		return big.NewInt(0), 2, 0
-	} else {
+	} else if coefType == 3 {
 		return big.NewInt(0), 3, in.ReadUint64()
 	}
+	panic("invalid coefficient type")
 }
 
 // Serialize converts a RootCircuit into a byte array for storage or transmission.
+//
+// The encoding is the one read by the Expander prover. All integers are little-endian uint64,
+// field elements are little-endian and padded to the SerializedLen of the field, and every list
+// is prefixed by its length:
+//
+//	MAGIC | field modulus (32 bytes) | NumPublicInputs | NumActualOutputs | ExpectedNumOutputZeroes
+//	len(Circuits) | Circuits... | len(Layers) | Layers...
+//
+// Each circuit is encoded as InputLen, OutputLen, its subcircuit calls (Id and allocations),
+// followed by the Mul, Add, Cst and Custom gates. A coefficient is a uint8 tag followed by
+// its payload: 1 for a constant, 2 for a random value, 3 for a public input id.
+//
+// Random values are the challenges of the circuit (see builder.API.Challenge): the prover
+// commits the input layer first, then derives the value of each gate with a random coefficient
+// from the transcript, so that no input depends on them. A verifier must derive them the same way,
+// with the same Transcript, which SerializeVersioned records.
 func (rc *RootCircuit) Serialize() []byte {
 	bnlen := field.GetFieldFromOrder(rc.Field).SerializedLen()
 	o := utils.OutputBuf{}
 	o.AppendUint64(MAGIC)
 	o.AppendBigInt(32, rc.Field)
+	rc.serializeBody(&o, func(o *utils.OutputBuf, coef *big.Int, coefType uint8, publicInputId uint64) {
+		serializeCoef(o, bnlen, coef, coefType, publicInputId)
+	})
+	return o.Bytes()
+}
+
+// serializeBody appends the circuit after the field modulus, encoding the coefficients with coef
+func (rc *RootCircuit) serializeBody(o *utils.OutputBuf, coef func(o *utils.OutputBuf, coef *big.Int, coefType uint8, publicInputId uint64)) {
 	o.AppendUint64(uint64(rc.NumPublicInputs))
 	o.AppendUint64(uint64(rc.NumActualOutputs))
 	o.AppendUint64(uint64(rc.ExpectedNumOutputZeroes))
 	o.AppendUint64(uint64(len(rc.Circuits)))
 	for _, c := range rc.Circuits {
-		o.AppendUint64(c.InputLen)
-		o.AppendUint64(c.OutputLen)
-		o.AppendUint64(uint64(len(c.SubCircuits)))
-		for _, sub := range c.SubCircuits {
-			o.AppendUint64(sub.Id)
-			o.AppendUint64(uint64(len(sub.Allocations)))
-			for _, a := range sub.Allocations {
-				o.AppendUint64(a.InputOffset)
-				o.AppendUint64(a.OutputOffset)
-			}
-		}
-		o.AppendUint64(uint64(len(c.Mul)))
-		for _, m := range c.Mul {
-			o.AppendUint64(m.In0)
-			o.AppendUint64(m.In1)
-			o.AppendUint64(m.Out)
-			serializeCoef(&o, bnlen, m.Coef, m.CoefType, m.PublicInputId)
+		serializeCircuit(o, c, coef)
+	}
+	o.AppendUint64(uint64(len(rc.Layers)))
+	for _, l := range rc.Layers {
+		o.AppendUint64(l)
+	}
+}
+
+// serializeCircuit appends the segment c, encoding the coefficients with coef
+func serializeCircuit(o *utils.OutputBuf, c *Circuit, coef func(o *utils.OutputBuf, coef *big.Int, coefType uint8, publicInputId uint64)) {
+	o.AppendUint64(c.InputLen)
+	o.AppendUint64(c.OutputLen)
+	o.AppendUint64(uint64(len(c.SubCircuits)))
+	for _, sub := range c.SubCircuits {
+		o.AppendUint64(sub.Id)
+		o.AppendUint64(uint64(len(sub.Allocations)))
+		for _, a := range sub.Allocations {
+			o.AppendUint64(a.InputOffset)
+			o.AppendUint64(a.OutputOffset)
 		}
-		o.AppendUint64(uint64(len(c.Add)))
-		for _, a := range c.Add {
-			o.AppendUint64(a.In)
-			o.AppendUint64(a.Out)
-			serializeCoef(&o, bnlen, a.Coef, a.CoefType, a.PublicInputId)
+	}
+	o.AppendUint64(uint64(len(c.Mul)))
+	for _, m := range c.Mul {
+		o.AppendUint64(m.In0)
+		o.AppendUint64(m.In1)
+		o.AppendUint64(m.Out)
+		coef(o, m.Coef, m.CoefType, m.PublicInputId)
+	}
+	o.AppendUint64(uint64(len(c.Add)))
+	for _, a := range c.Add {
+		o.AppendUint64(a.In)
+		o.AppendUint64(a.Out)
+		coef(o, a.Coef, a.CoefType, a.PublicInputId)
+	}
+	o.AppendUint64(uint64(len(c.Cst)))
+	for _, cst := range c.Cst {
+		o.AppendUint64(cst.Out)
+		coef(o, cst.Coef, cst.CoefType, cst.PublicInputId)
+	}
+	o.AppendUint64(uint64(len(c.Custom)))
+	for _, cu := range c.Custom {
+		o.AppendUint64(cu.GateType)
+		o.AppendUint64(uint64(len(cu.In)))
+		for _, in := range cu.In {
+			o.AppendUint64(in)
 		}
-		o.AppendUint64(uint64(len(c.Cst)))
-		for _, cst := range c.Cst {
-			o.AppendUint64(cst.Out)
-			serializeCoef(&o, bnlen, cst.Coef, cst.CoefType, cst.PublicInputId)
+		o.AppendUint64(cu.Out)
+		coef(o, cu.Coef, cu.CoefType, cu.PublicInputId)
+	}
+}
+
+// ContentHash returns the SHA-256 hash of the serialized circuit. Since compilation is
+// deterministic, it identifies the circuit a witness was solved for, see irwg.Witness.CircuitHash.
+func (rc *RootCircuit) ContentHash() [32]byte {
+	return sha256.Sum256(rc.Serialize())
+}
+
+// LayerDigests returns a SHA-256 digest of the structure of each layer: its segment, as encoded by
+// Serialize, followed by the digests of the segments it calls, in the order of its calls. A
+// layer can be checked against its digest with the segments it reaches only, without the rest
+// of the circuit.
+func (rc *RootCircuit) LayerDigests() [][32]byte {
+	bnlen := field.GetFieldFromOrder(rc.Field).SerializedLen()
+	coef := func(o *utils.OutputBuf, coef *big.Int, coefType uint8, publicInputId uint64) {
+		serializeCoef(o, bnlen, coef, coefType, publicInputId)
+	}
+	digests := make(map[uint64][32]byte)
+	var digest func(id uint64) [32]byte
+	digest = func(id uint64) [32]byte {
+		if d, ok := digests[id]; ok {
+			return d
 		}
-		o.AppendUint64(uint64(len(c.Custom)))
-		for _, cu := range c.Custom {
-			o.AppendUint64(cu.GateType)
-			o.AppendUint64(uint64(len(cu.In)))
-			for _, in := range cu.In {
-				o.AppendUint64(in)
-			}
-			o.AppendUint64(cu.Out)
-			serializeCoef(&o, bnlen, cu.Coef, cu.CoefType, cu.PublicInputId)
+		c := rc.Circuits[id]
+		o := utils.OutputBuf{}
+		serializeCircuit(&o, c, coef)
+		for _, sub := range c.SubCircuits {
+			d := digest(sub.Id)
+			o.AppendBytes(d[:])
 		}
+		d := sha256.Sum256(o.Bytes())
+		digests[id] = d
+		return d
 	}
-	o.AppendUint64(uint64(len(rc.Layers)))
-	for _, l := range rc.Layers {
-		o.AppendUint64(l)
+	res := make([][32]byte, len(rc.Layers))
+	for i, id := range rc.Layers {
+		res[i] = digest(id)
 	}
-	return o.Bytes()
+	return res
 }
 
+// DeserializeRootCircuit reads a RootCircuit produced by Serialize, either from Go or from the Rust compiler,
+// by SerializeCompact, or by SerializeVersioned, whose header must pass Header.Check with Supported.
 func DeserializeRootCircuit(buf []byte) *RootCircuit {
+	if len(buf) >= 8 && binary.LittleEndian.Uint64(buf) == HeaderMagic {
+		h, payload, err := ReadHeader(buf)
+		if err == nil {
+			err = h.Check(Supported)
+		}
+		if err != nil {
+			panic(err.Error())
+		}
+		buf = payload
+	}
 	in := utils.NewInputBuf(buf)
-	if in.ReadUint64() != MAGIC {
+	magic := in.ReadUint64()
+	if magic != MAGIC && magic != CompactMagic {
 		panic("invalid file header")
 	}
 	rc := &RootCircuit{}
 	rc.Field = in.ReadBigInt(32)
+	bnlen := field.GetFieldFromOrder(rc.Field).SerializedLen()
+	coef := func(in *utils.InputBuf) (*big.Int, uint8, uint64) {
+		return deserializeCoef(in, bnlen)
+	}
+	if magic == CompactMagic {
+		coef = readCoefTable(in, bnlen)
+	}
 	rc.NumPublicInputs = int(in.ReadUint64())
 	rc.NumActualOutputs = int(in.ReadUint64())
 	rc.ExpectedNumOutputZeroes = int(in.ReadUint64())
-	bnlen := field.GetFieldFromOrder(rc.Field).SerializedLen()
 	nbCircuits := in.ReadUint64()
 	rc.Circuits = make([]*Circuit, nbCircuits)
 	for i := uint64(0); i < nbCircuits; i++ {
@@ -127,20 +218,20 @@ func DeserializeRootCircuit(buf []byte) *RootCircuit {
 			c.Mul[j].In0 = in.ReadUint64()
 			c.Mul[j].In1 = in.ReadUint64()
 			c.Mul[j].Out = in.ReadUint64()
-			c.Mul[j].Coef, c.Mul[j].CoefType, c.Mul[j].PublicInputId = deserializeCoef(in, bnlen)
+			c.Mul[j].Coef, c.Mul[j].CoefType, c.Mul[j].PublicInputId = coef(in)
 		}
 		nbAdd := in.ReadUint64()
 		c.Add = make([]GateAdd, nbAdd)
 		for j := uint64(0); j < nbAdd; j++ {
 			c.Add[j].In = in.ReadUint64()
 			c.Add[j].Out = in.ReadUint64()
-			c.Add[j].Coef, c.Add[j].CoefType, c.Add[j].PublicInputId = deserializeCoef(in, bnlen)
+			c.Add[j].Coef, c.Add[j].CoefType, c.Add[j].PublicInputId = coef(in)
 		}
 		nbCst := in.ReadUint64()
 		c.Cst = make([]GateCst, nbCst)
 		for j := uint64(0); j < nbCst; j++ {
 			c.Cst[j].Out = in.ReadUint64()
-			c.Cst[j].Coef, c.Cst[j].CoefType, c.Cst[j].PublicInputId = deserializeCoef(in, bnlen)
+			c.Cst[j].Coef, c.Cst[j].CoefType, c.Cst[j].PublicInputId = coef(in)
 		}
 		nbCustom := in.ReadUint64()
 		c.Custom = make([]GateCustom, nbCustom)
@@ -152,7 +243,7 @@ func DeserializeRootCircuit(buf []byte) *RootCircuit {
 				c.Custom[j].In[k] = in.ReadUint64()
 			}
 			c.Custom[j].Out = in.ReadUint64()
-			c.Custom[j].Coef, c.Custom[j].CoefType, c.Custom[j].PublicInputId = deserializeCoef(in, bnlen)
+			c.Custom[j].Coef, c.Custom[j].CoefType, c.Custom[j].PublicInputId = coef(in)
 		}
 		rc.Circuits[i] = c
 	}
@@ -167,6 +258,7 @@ func DeserializeRootCircuit(buf []byte) *RootCircuit {
 	return rc
 }
 
+// DetectFieldIdFromFile reads the header of a serialized layered circuit and returns the id of its field.
 func DetectFieldIdFromFile(fn string) uint64 {
 	// Read the first 4 bytes of the file
 	file, err := os.Open(fn)
@@ -179,8 +271,18 @@ func DetectFieldIdFromFile(fn string) uint64 {
 	if err != nil || n != 40 {
 		panic(err)
 	}
+	return DetectFieldId(buf)
+}
+
+// DetectFieldId reads the header of a serialized layered circuit and returns the id of its field.
+func DetectFieldId(buf []byte) uint64 {
 	in := utils.NewInputBuf(buf)
-	if in.ReadUint64() != MAGIC {
+	if len(buf) >= headerLen && binary.LittleEndian.Uint64(buf) == HeaderMagic {
+		in.ReadUint64()
+		in.ReadUint64()
+		return in.ReadUint64()
+	}
+	if magic := in.ReadUint64(); magic != MAGIC && magic != CompactMagic {
 		panic("invalid file header")
 	}
 	f := in.ReadBigInt(32)
@@ -0,0 +1,102 @@
+package layered
+
+import (
+	"bytes"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+)
+
+// sampleRootCircuit returns a two-layer circuit computing (x0*x1 + 3*x2, x3 + r) with a subcircuit call.
+func sampleRootCircuit() *RootCircuit {
+	sub := &Circuit{
+		InputLen:  2,
+		OutputLen: 1,
+		Mul:       []GateMul{{In0: 0, In1: 1, Out: 0, Coef: big.NewInt(1), CoefType: 1}},
+	}
+	l0 := &Circuit{
+		InputLen:  4,
+		OutputLen: 2,
+		SubCircuits: []SubCircuit{
+			{Id: 0, Allocations: []Allocation{{InputOffset: 0, OutputOffset: 0}}},
+		},
+		Add: []GateAdd{
+			{In: 2, Out: 0, Coef: big.NewInt(3), CoefType: 1},
+			{In: 3, Out: 1, Coef: big.NewInt(1), CoefType: 1},
+		},
+		Cst: []GateCst{{Out: 1, Coef: big.NewInt(0), CoefType: 2}},
+	}
+	l1 := &Circuit{
+		InputLen:  2,
+		OutputLen: 1,
+		Add: []GateAdd{
+			{In: 0, Out: 0, Coef: big.NewInt(1), CoefType: 1},
+			{In: 1, Out: 0, Coef: big.NewInt(0), CoefType: 3, PublicInputId: 0},
+		},
+		Custom: []GateCustom{{GateType: 12345, In: []uint64{0, 1}, Out: 0, Coef: big.NewInt(5), CoefType: 1}},
+	}
+	return &RootCircuit{
+		NumPublicInputs:         1,
+		NumActualOutputs:        1,
+		ExpectedNumOutputZeroes: 1,
+		Circuits:                []*Circuit{sub, l0, l1},
+		Layers:                  []uint64{1, 2},
+		Field:                   m31.ScalarField,
+	}
+}
+
+func TestSerializeRoundTrip(t *testing.T) {
+	rc := sampleRootCircuit()
+	buf := rc.Serialize()
+	rc2 := DeserializeRootCircuit(buf)
+	if !bytes.Equal(rc2.Serialize(), buf) {
+		t.Fatal("deserialized circuit differs from the original one")
+	}
+	if !reflect.DeepEqual(rc.Layers, rc2.Layers) || rc2.Circuits[2].Custom[0].In[1] != 1 || rc2.Circuits[1].Add[0].Coef.Int64() != 3 {
+		t.Fatal("deserialized circuit differs from the original one")
+	}
+
+	fn := filepath.Join(t.TempDir(), "circuit.txt")
+	if err := os.WriteFile(fn, buf, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if id := DetectFieldIdFromFile(fn); id != 1 {
+		t.Fatalf("expected field id 1, got %d", id)
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	rc := sampleRootCircuit()
+	h := rc.ContentHash()
+	if DeserializeRootCircuit(rc.Serialize()).ContentHash() != h {
+		t.Fatal("hash changed after a serialization round trip")
+	}
+	rc.Circuits[0].Mul[0].Coef = big.NewInt(2)
+	if rc.ContentHash() == h {
+		t.Fatal("hash didn't change with the circuit")
+	}
+}
+
+func TestDeserializeInvalidCoefType(t *testing.T) {
+	rc := &RootCircuit{
+		Circuits: []*Circuit{{InputLen: 1, OutputLen: 1, Cst: []GateCst{{Out: 0, Coef: big.NewInt(0), CoefType: 2}}}},
+		Layers:   []uint64{0},
+		Field:    m31.ScalarField,
+	}
+	buf := rc.Serialize()
+	// the tag of the coefficient is followed by the number of custom gates and the layers
+	if buf[len(buf)-25] != 2 {
+		t.Fatal("unexpected layout")
+	}
+	buf[len(buf)-25] = 4
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected an unknown coefficient type to be rejected")
+		}
+	}()
+	DeserializeRootCircuit(buf)
+}
@@ -0,0 +1,175 @@
+package layered
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LayerStats holds the gate counts of a single layer, including the gates of the subcircuits
+// it calls.
+type LayerStats struct {
+	InputLen   uint64
+	OutputLen  uint64
+	NumMul     uint64
+	NumAdd     uint64
+	NumCst     uint64
+	NumCustom  uint64
+	UsedOutput uint64 // number of output wires written by at least one gate
+	// number of gates writing an output wire which isn't read by the next layer, always 0 for the
+	// last layer
+	WastedGates uint64
+}
+
+// Stats summarizes the size of a layered circuit, which determines the proving cost.
+type Stats struct {
+	NumLayers int
+	Layers    []LayerStats
+	MaxWidth  uint64
+	// total number of output wires which are only there to pad layers to a power of two
+	PaddingWires uint64
+	// total number of gates whose result is never read, see LayerStats.WastedGates
+	WastedGates uint64
+	// number of times each circuit is instantiated, indexed like RootCircuit.Circuits
+	Instances []uint64
+}
+
+// Stats computes the statistics of the circuit.
+func (rc *RootCircuit) Stats() *Stats {
+	res := &Stats{
+		NumLayers: len(rc.Layers),
+		Instances: make([]uint64, len(rc.Circuits)),
+	}
+	var visit func(id uint64, offset uint64, ls *LayerStats, used []bool, read []bool)
+	visit = func(id uint64, offset uint64, ls *LayerStats, used []bool, read []bool) {
+		c := rc.Circuits[id]
+		res.Instances[id]++
+		ls.NumMul += uint64(len(c.Mul))
+		ls.NumAdd += uint64(len(c.Add))
+		ls.NumCst += uint64(len(c.Cst))
+		ls.NumCustom += uint64(len(c.Custom))
+		for _, g := range c.Mul {
+			used[offset+g.Out] = true
+		}
+		for _, g := range c.Add {
+			used[offset+g.Out] = true
+		}
+		for _, g := range c.Cst {
+			used[offset+g.Out] = true
+		}
+		for _, g := range c.Custom {
+			used[offset+g.Out] = true
+		}
+		if read != nil {
+			for _, g := range c.Mul {
+				if !read[offset+g.Out] {
+					ls.WastedGates++
+				}
+			}
+			for _, g := range c.Add {
+				if !read[offset+g.Out] {
+					ls.WastedGates++
+				}
+			}
+			for _, g := range c.Cst {
+				if !read[offset+g.Out] {
+					ls.WastedGates++
+				}
+			}
+			for _, g := range c.Custom {
+				if !read[offset+g.Out] {
+					ls.WastedGates++
+				}
+			}
+		}
+		for _, sub := range c.SubCircuits {
+			for _, a := range sub.Allocations {
+				visit(sub.Id, offset+a.OutputOffset, ls, used, read)
+			}
+		}
+	}
+	for i, id := range rc.Layers {
+		c := rc.Circuits[id]
+		ls := LayerStats{InputLen: c.InputLen, OutputLen: c.OutputLen}
+		used := make([]bool, c.OutputLen)
+		var read []bool
+		if i+1 < len(rc.Layers) {
+			read = rc.readWires(rc.Layers[i+1])
+		}
+		visit(id, 0, &ls, used, read)
+		for _, u := range used {
+			if u {
+				ls.UsedOutput++
+			}
+		}
+		res.PaddingWires += ls.OutputLen - ls.UsedOutput
+		res.WastedGates += ls.WastedGates
+		if ls.InputLen > res.MaxWidth {
+			res.MaxWidth = ls.InputLen
+		}
+		if ls.OutputLen > res.MaxWidth {
+			res.MaxWidth = ls.OutputLen
+		}
+		res.Layers = append(res.Layers, ls)
+	}
+	return res
+}
+
+// TotalGates returns the number of gates of all layers.
+func (s *Stats) TotalGates() uint64 {
+	var res uint64
+	for _, l := range s.Layers {
+		res += l.NumMul + l.NumAdd + l.NumCst + l.NumCustom
+	}
+	return res
+}
+
+// paddingRatio returns the fraction of the output wires of all layers which are padding
+func (s *Stats) paddingRatio() float64 {
+	var total uint64
+	for _, l := range s.Layers {
+		total += l.OutputLen
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(s.PaddingWires) / float64(total)
+}
+
+// String returns a human-readable report of the statistics.
+func (s *Stats) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "layers: %d, max width: %d, total gates: %d, padding wires: %d (%.1f%%), wasted gates: %d\n",
+		s.NumLayers, s.MaxWidth, s.TotalGates(), s.PaddingWires, s.paddingRatio()*100, s.WastedGates)
+	for i, l := range s.Layers {
+		fmt.Fprintf(&sb, "layer %d: in=%d out=%d (used %d) mul=%d add=%d cst=%d custom=%d wasted=%d\n",
+			i, l.InputLen, l.OutputLen, l.UsedOutput, l.NumMul, l.NumAdd, l.NumCst, l.NumCustom, l.WastedGates)
+	}
+	reused := []int{}
+	for id, n := range s.Instances {
+		if n > 1 {
+			reused = append(reused, id)
+		}
+	}
+	sort.Slice(reused, func(i, j int) bool { return s.Instances[reused[i]] > s.Instances[reused[j]] })
+	for _, id := range reused {
+		fmt.Fprintf(&sb, "circuit %d: %d instances\n", id, s.Instances[id])
+	}
+	return sb.String()
+}
+
+// CustomGateTypes returns the sorted types of the custom gates used by the circuit.
+func (rc *RootCircuit) CustomGateTypes() []uint64 {
+	seen := make(map[uint64]bool)
+	res := []uint64{}
+	for _, c := range rc.Circuits {
+		for _, g := range c.Custom {
+			if !seen[g.GateType] {
+				seen[g.GateType] = true
+				res = append(res, g.GateType)
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
@@ -0,0 +1,35 @@
+package layered
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	s := sampleRootCircuit().Stats()
+	if s.NumLayers != 2 || s.MaxWidth != 4 {
+		t.Fatalf("unexpected layers %d or width %d", s.NumLayers, s.MaxWidth)
+	}
+	l0 := s.Layers[0]
+	if l0.NumMul != 1 || l0.NumAdd != 2 || l0.NumCst != 1 || l0.UsedOutput != 2 {
+		t.Fatalf("unexpected first layer %+v", l0)
+	}
+	if s.Layers[1].NumCustom != 1 || s.TotalGates() != 7 || s.PaddingWires != 0 {
+		t.Fatalf("unexpected totals %+v", s)
+	}
+	if s.Instances[0] != 1 || s.Instances[1] != 1 {
+		t.Fatalf("unexpected instances %v", s.Instances)
+	}
+	if !strings.Contains(s.String(), "layers: 2") {
+		t.Fatal("missing summary line")
+	}
+}
+
+func TestCustomGateTypes(t *testing.T) {
+	rc := sampleRootCircuit()
+	rc.Circuits[1].Custom = []GateCustom{{GateType: 7}, {GateType: 12345}}
+	types := rc.CustomGateTypes()
+	if len(types) != 2 || types[0] != 7 || types[1] != 12345 {
+		t.Fatalf("unexpected gate types %v", types)
+	}
+}
@@ -0,0 +1,59 @@
+package layered
+
+import "fmt"
+
+// Transcript is the hash of the Fiat-Shamir transcript from which the prover derives the random
+// coefficients of the circuit, its challenges and the challenges of its lookups. It's recorded
+// in the header of SerializeVersioned, so that the prover and the verifier hash the same
+// transcript. The prover of this package, through the Rust library, only hashes the default
+// transcript of each field, see Supported.
+type Transcript uint64
+
+const (
+	// TranscriptDefault is the transcript Expander uses for the field of the circuit, see
+	// DefaultTranscript. It's the transcript of the files of Serialize and of format version 1.
+	TranscriptDefault Transcript = iota
+	TranscriptSHA256
+	TranscriptKeccak
+	TranscriptPoseidon2
+	TranscriptMiMC5
+)
+
+var transcriptNames = []string{"default", "sha256", "keccak", "poseidon2", "mimc5"}
+
+// String returns the name of the transcript, e.g. "keccak", as read by ParseTranscript.
+func (t Transcript) String() string {
+	if t < Transcript(len(transcriptNames)) {
+		return transcriptNames[t]
+	}
+	return fmt.Sprintf("transcript %d", uint64(t))
+}
+
+// ParseTranscript returns the transcript named name: default, sha256, keccak, poseidon2 or
+// mimc5.
+func ParseTranscript(name string) (Transcript, error) {
+	for i, n := range transcriptNames {
+		if n == name {
+			return Transcript(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown transcript %q, expected default, sha256, keccak, poseidon2 or mimc5", name)
+}
+
+// DefaultTranscript returns the transcript Expander uses for the field fieldId, see
+// field.GetFieldId: MiMC5 for BN254, and SHA-256 for the other fields.
+func DefaultTranscript(fieldId uint64) Transcript {
+	if fieldId == 2 {
+		return TranscriptMiMC5
+	}
+	return TranscriptSHA256
+}
+
+// Resolve returns the transcript t for the field fieldId, DefaultTranscript if t is
+// TranscriptDefault.
+func (t Transcript) Resolve(fieldId uint64) Transcript {
+	if t == TranscriptDefault {
+		return DefaultTranscript(fieldId)
+	}
+	return t
+}
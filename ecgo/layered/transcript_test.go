@@ -0,0 +1,17 @@
+package layered
+
+import "testing"
+
+func TestTranscript(t *testing.T) {
+	for _, tr := range []Transcript{TranscriptDefault, TranscriptSHA256, TranscriptKeccak, TranscriptPoseidon2, TranscriptMiMC5} {
+		if p, err := ParseTranscript(tr.String()); err != nil || p != tr {
+			t.Fatalf("expected %s to be parsed, got %v, error %v", tr, p, err)
+		}
+	}
+	if _, err := ParseTranscript("md5"); err == nil {
+		t.Fatal("expected an unknown transcript to fail")
+	}
+	if TranscriptDefault.Resolve(2) != TranscriptMiMC5 || TranscriptDefault.Resolve(1) != TranscriptSHA256 || TranscriptKeccak.Resolve(2) != TranscriptKeccak {
+		t.Fatal("unexpected resolved transcripts")
+	}
+}
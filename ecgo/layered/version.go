@@ -0,0 +1,314 @@
+package layered
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"strings"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils"
+)
+
+// HeaderMagic identifies the versioned circuit files written by SerializeVersioned. It differs
+// from MAGIC, so that readers tell them apart from the files of Serialize.
+const HeaderMagic = 3914834606642317636
+
+// FormatVersion is the version of the circuit files written by SerializeVersioned. It's increased
+// whenever a reader of the previous version can't read the files correctly. Version 2 adds the
+// transcript to the header, and version 3 the tags.
+const FormatVersion = 3
+
+// headerLen is the length of the header of SerializeVersioned in version 1: HeaderMagic, Version,
+// FieldId and Features. Version 2 adds the Transcript, and version 3 the tags, see readHeader.
+const headerLen = 32
+
+// Features are the features of the proving protocol a circuit relies on. A prover must support
+// all of them to prove the circuit, see Capabilities.
+type Features uint64
+
+const (
+	// FeatureCustomGates is set when the circuit has custom gates, see GateCustom.
+	FeatureCustomGates Features = 1 << iota
+	// FeatureChallenges is set when the circuit has random coefficients, derived by the prover
+	// from the transcript, see Serialize and Transcript.
+	FeatureChallenges
+	// FeatureLookups is set when the circuit checks lookup tables with a LogUp argument, which
+	// also draws challenges. It's declared by the compiler, since it can't be told from the gates.
+	FeatureLookups
+)
+
+var featureNames = []string{"custom gates", "challenges", "lookups"}
+
+// String returns the names of the features separated by commas, e.g. "custom gates, lookups".
+// Features unknown to this version are shown as "feature 0x...".
+func (f Features) String() string {
+	if f == 0 {
+		return "none"
+	}
+	names := []string{}
+	for f != 0 {
+		i := bits.TrailingZeros64(uint64(f))
+		if i < len(featureNames) {
+			names = append(names, featureNames[i])
+		} else {
+			names = append(names, fmt.Sprintf("feature %#x", uint64(1)<<i))
+		}
+		f &^= 1 << i
+	}
+	return strings.Join(names, ", ")
+}
+
+// Features returns the features detected from the gates of the circuit: custom gates and random
+// coefficients.
+func (rc *RootCircuit) Features() Features {
+	var res Features
+	for _, c := range rc.Circuits {
+		if len(c.Custom) != 0 {
+			res |= FeatureCustomGates
+		}
+		for _, g := range c.Mul {
+			res |= coefFeatures(g.CoefType)
+		}
+		for _, g := range c.Add {
+			res |= coefFeatures(g.CoefType)
+		}
+		for _, g := range c.Cst {
+			res |= coefFeatures(g.CoefType)
+		}
+		for _, g := range c.Custom {
+			res |= coefFeatures(g.CoefType)
+		}
+	}
+	return res
+}
+
+func coefFeatures(coefType uint8) Features {
+	if coefType == 2 {
+		return FeatureChallenges
+	}
+	return 0
+}
+
+// Tag names a wire of the output layer, see ecgo.CompileResult.Tags.
+type Tag struct {
+	Name string `json:"name"`
+	// Output is the index of the wire in the output layer after the outputs expected to be zero.
+	Output int `json:"output"`
+}
+
+// Header describes a circuit file, see ReadHeader.
+type Header struct {
+	// Version is the FormatVersion of the file, 0 for the files of Serialize, which have no header.
+	Version uint64
+	// FieldId is the id of the field of the circuit, see field.GetFieldId.
+	FieldId uint64
+	// Features are the features the circuit relies on, none for the files of Serialize.
+	Features Features
+	// Transcript is the transcript of the challenges of the circuit, TranscriptDefault for the
+	// files of Serialize and of version 1.
+	Transcript Transcript
+	// Tags name wires of the output layer, none for the files of Serialize and of versions 1 and 2.
+	Tags []Tag
+}
+
+// SerializeVersioned serializes the circuit like Serialize, after a header holding FormatVersion,
+// the field id and the features of the circuit, the ones detected from its gates and the declared
+// ones, e.g. FeatureLookups, see ecgo.CompileResult.Features, the transcript the prover of this
+// package derives the challenges with, the default one of the field, and the tags of the outputs.
+// Provers check the header with Header.Check to reject circuits they can't prove with a clear
+// message, instead of failing or producing invalid proofs:
+//
+//	HeaderMagic | FormatVersion | field id | features | transcript | tags | output of Serialize
+//
+// The tags are their number, followed by the length of the name, the name and the output of each.
+//
+// The Expander prover, and the files passed to rust.ProveFile, read the format of Serialize, which
+// is the payload returned by ReadHeader.
+func (rc *RootCircuit) SerializeVersioned(declared Features, tags []Tag) []byte {
+	fieldId := field.GetFieldId(field.GetFieldFromOrder(rc.Field))
+	o := utils.OutputBuf{}
+	o.AppendUint64(HeaderMagic)
+	o.AppendUint64(FormatVersion)
+	o.AppendUint64(fieldId)
+	o.AppendUint64(uint64(rc.Features() | declared))
+	o.AppendUint64(uint64(DefaultTranscript(fieldId)))
+	o.AppendUint64(uint64(len(tags)))
+	for _, t := range tags {
+		o.AppendUint64(uint64(len(t.Name)))
+		o.AppendBytes([]byte(t.Name))
+		o.AppendUint64(uint64(t.Output))
+	}
+	return append(o.Bytes(), rc.Serialize()...)
+}
+
+// ReadHeader returns the header of a circuit file written by SerializeVersioned or by Serialize,
+// and its payload in the format of Serialize. The files of Serialize have version 0 and no
+// features, since they can only be detected by deserializing the circuit, see RootCircuit.Features.
+// The files of SerializeCompact are read like the ones of Serialize, and expanded by ExpandCompact.
+func ReadHeader(buf []byte) (*Header, []byte, error) {
+	if len(buf) < 8 {
+		return nil, nil, errors.New("invalid file header")
+	}
+	switch binary.LittleEndian.Uint64(buf) {
+	case HeaderMagic:
+		h, n, err := readHeader(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		return h, buf[n:], nil
+	case MAGIC, CompactMagic:
+		if len(buf) < 40 {
+			return nil, nil, errors.New("truncated file header")
+		}
+		id, err := fieldIdOf(utils.NewInputBuf(buf[8:40]).ReadBigInt(32))
+		if err != nil {
+			return nil, nil, err
+		}
+		payload, err := ExpandCompact(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &Header{FieldId: id}, payload, nil
+	}
+	return nil, nil, errors.New("invalid file header")
+}
+
+var errTruncatedHeader = errors.New("truncated file header")
+
+// readHeader reads the header of a file of SerializeVersioned, and returns its length
+func readHeader(buf []byte) (*Header, int, error) {
+	if len(buf) < headerLen {
+		return nil, 0, errTruncatedHeader
+	}
+	h := &Header{
+		Version:  binary.LittleEndian.Uint64(buf[8:]),
+		FieldId:  binary.LittleEndian.Uint64(buf[16:]),
+		Features: Features(binary.LittleEndian.Uint64(buf[24:])),
+	}
+	n := headerLen
+	next := func() (uint64, error) {
+		if len(buf) < n+8 {
+			return 0, errTruncatedHeader
+		}
+		n += 8
+		return binary.LittleEndian.Uint64(buf[n-8:]), nil
+	}
+	if h.Version >= 2 {
+		t, err := next()
+		if err != nil {
+			return nil, 0, err
+		}
+		h.Transcript = Transcript(t)
+	}
+	if h.Version >= 3 {
+		count, err := next()
+		if err != nil {
+			return nil, 0, err
+		}
+		// each tag takes at least 16 bytes
+		if count > uint64(len(buf)-n)/16 {
+			return nil, 0, errTruncatedHeader
+		}
+		h.Tags = make([]Tag, count)
+		for i := range h.Tags {
+			l, err := next()
+			if err != nil {
+				return nil, 0, err
+			}
+			if l > uint64(len(buf)-n) {
+				return nil, 0, errTruncatedHeader
+			}
+			h.Tags[i].Name = string(buf[n : n+int(l)])
+			n += int(l)
+			output, err := next()
+			if err != nil {
+				return nil, 0, err
+			}
+			if output > math.MaxInt32 {
+				return nil, 0, fmt.Errorf("invalid output %d of tag %q", output, h.Tags[i].Name)
+			}
+			h.Tags[i].Output = int(output)
+		}
+	}
+	return h, n, nil
+}
+
+// fieldIdOf returns the id of the field of the given order
+func fieldIdOf(order *big.Int) (id uint64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return field.GetFieldId(field.GetFieldFromOrder(order)), nil
+}
+
+// StripHeader returns the payload of a circuit file written by SerializeVersioned, in the format
+// of Serialize, without checking the header, or buf itself if it has no header.
+func StripHeader(buf []byte) []byte {
+	if len(buf) >= headerLen && binary.LittleEndian.Uint64(buf) == HeaderMagic {
+		if _, n, err := readHeader(buf); err == nil {
+			return buf[n:]
+		}
+	}
+	return buf
+}
+
+// ErrUnsupportedCircuit is returned by Header.Check when a circuit can't be proven with the
+// given capabilities.
+var ErrUnsupportedCircuit = errors.New("unsupported circuit")
+
+// Capabilities describe the circuits a prover supports.
+type Capabilities struct {
+	// MaxVersion is the highest FormatVersion the prover reads.
+	MaxVersion uint64
+	// FieldIds are the ids of the supported fields, any field if empty.
+	FieldIds []uint64
+	Features Features
+	// Transcripts are the supported transcripts, any if empty. TranscriptDefault stands for the
+	// default transcript of the field of the circuit, see Transcript.Resolve.
+	Transcripts []Transcript
+}
+
+// Supported are the capabilities of this package, whose prover derives the challenges with the
+// default transcript of each field.
+var Supported = Capabilities{
+	MaxVersion:  FormatVersion,
+	Features:    FeatureCustomGates | FeatureChallenges | FeatureLookups,
+	Transcripts: []Transcript{TranscriptDefault},
+}
+
+// Check returns an error wrapping ErrUnsupportedCircuit if the circuit can't be proven with c,
+// naming the missing capabilities.
+func (h *Header) Check(c Capabilities) error {
+	if h.Version > c.MaxVersion {
+		return fmt.Errorf("%w: format version %d, the prover reads up to version %d", ErrUnsupportedCircuit, h.Version, c.MaxVersion)
+	}
+	if len(c.FieldIds) != 0 {
+		found := false
+		for _, id := range c.FieldIds {
+			found = found || id == h.FieldId
+		}
+		if !found {
+			return fmt.Errorf("%w: field %d isn't supported by the prover", ErrUnsupportedCircuit, h.FieldId)
+		}
+	}
+	if missing := h.Features &^ c.Features; missing != 0 {
+		return fmt.Errorf("%w: the circuit uses %s, which the prover doesn't support", ErrUnsupportedCircuit, missing)
+	}
+	if len(c.Transcripts) != 0 {
+		t := h.Transcript.Resolve(h.FieldId)
+		found := false
+		for _, s := range c.Transcripts {
+			found = found || s.Resolve(h.FieldId) == t
+		}
+		if !found {
+			return fmt.Errorf("%w: the circuit uses the %s transcript, which the prover doesn't support", ErrUnsupportedCircuit, t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package layered
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSerializeVersioned(t *testing.T) {
+	rc := sampleRootCircuit()
+	if f := rc.Features(); f != FeatureCustomGates|FeatureChallenges {
+		t.Fatalf("unexpected features %s", f)
+	}
+	tags := []Tag{{Name: "root_hash", Output: 0}, {Name: "nullifier", Output: 3}}
+	buf := rc.SerializeVersioned(FeatureLookups, tags)
+	h, payload, err := ReadHeader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*h, Header{Version: FormatVersion, FieldId: 1, Features: FeatureCustomGates | FeatureChallenges | FeatureLookups, Transcript: TranscriptSHA256, Tags: tags}) {
+		t.Fatalf("unexpected header %+v", h)
+	}
+	if !bytes.Equal(payload, rc.Serialize()) || !bytes.Equal(StripHeader(buf), payload) {
+		t.Fatal("expected the payload to be the output of Serialize")
+	}
+	if !bytes.Equal(DeserializeRootCircuit(buf).Serialize(), payload) || DetectFieldId(buf) != 1 {
+		t.Fatal("expected versioned circuits to be read like the other ones")
+	}
+
+	h, payload, err = ReadHeader(rc.Serialize())
+	if err != nil || !reflect.DeepEqual(*h, Header{FieldId: 1}) || !bytes.Equal(payload, rc.Serialize()) {
+		t.Fatalf("unexpected header %+v of a circuit without header, error %v", h, err)
+	}
+	if _, _, err := ReadHeader([]byte{1, 2, 3}); err == nil {
+		t.Fatal("expected an invalid header to fail")
+	}
+	// the tags are read up to the end of the header
+	for _, n := range []int{headerLen + 12, headerLen + 28, len(buf) - len(payload) - 1} {
+		if _, _, err := ReadHeader(buf[:n]); err == nil {
+			t.Fatalf("expected a header truncated to %d bytes to fail", n)
+		}
+		if !bytes.Equal(StripHeader(buf[:n]), buf[:n]) {
+			t.Fatal("expected a truncated header not to be stripped")
+		}
+	}
+}
+
+func TestSerializeVersionedTags(t *testing.T) {
+	rc := sampleRootCircuit()
+	buf := rc.SerializeVersioned(0, []Tag{{Name: "x", Output: 1}})
+	payload := rc.Serialize()
+	// version 2 headers end before the tags
+	v2 := append(append([]byte{}, buf[:headerLen+8]...), payload...)
+	binary.LittleEndian.PutUint64(v2[8:], 2)
+	h, p, err := ReadHeader(v2)
+	if err != nil || h.Version != 2 || h.Tags != nil || !bytes.Equal(p, payload) || !bytes.Equal(StripHeader(v2), payload) {
+		t.Fatalf("unexpected version 2 header %+v, error %v", h, err)
+	}
+	// a tag count larger than the file is rejected without allocating the tags
+	huge := append([]byte{}, buf...)
+	binary.LittleEndian.PutUint64(huge[headerLen+8:], 1<<62)
+	if _, _, err := ReadHeader(huge); err == nil {
+		t.Fatal("expected an invalid tag count to fail")
+	}
+}
+
+func TestSerializeVersionedTranscript(t *testing.T) {
+	rc := sampleRootCircuit()
+	buf := rc.SerializeVersioned(0, nil)
+	h, payload, err := ReadHeader(buf)
+	if err != nil || h.Transcript != DefaultTranscript(1) || !bytes.Equal(payload, rc.Serialize()) {
+		t.Fatalf("unexpected header %+v, error %v", h, err)
+	}
+	// the files written by this package are accepted
+	if err := h.Check(Supported); err != nil {
+		t.Fatal(err)
+	}
+	h.Transcript = TranscriptDefault
+	if err := h.Check(Supported); err != nil {
+		t.Fatal(err)
+	}
+	// a transcript the prover doesn't hash is rejected
+	keccak := append([]byte{}, buf...)
+	binary.LittleEndian.PutUint64(keccak[headerLen:], uint64(TranscriptKeccak))
+	h, _, err = ReadHeader(keccak)
+	if err != nil || h.Transcript != TranscriptKeccak {
+		t.Fatalf("unexpected header %+v, error %v", h, err)
+	}
+	err = h.Check(Supported)
+	if !errors.Is(err, ErrUnsupportedCircuit) || !strings.Contains(err.Error(), "the keccak transcript") {
+		t.Fatalf("expected the keccak transcript to be rejected, got %v", err)
+	}
+	if err := h.Check(Capabilities{MaxVersion: FormatVersion, Features: Supported.Features, Transcripts: []Transcript{TranscriptKeccak}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// version 1 headers have no transcript
+	v1 := append(append([]byte{}, buf[:headerLen]...), payload...)
+	binary.LittleEndian.PutUint64(v1[8:], 1)
+	h, p, err := ReadHeader(v1)
+	if err != nil || !reflect.DeepEqual(*h, Header{Version: 1, FieldId: 1, Features: rc.Features()}) || !bytes.Equal(p, payload) || !bytes.Equal(StripHeader(v1), payload) {
+		t.Fatalf("unexpected version 1 header %+v, error %v", h, err)
+	}
+}
+
+func TestHeaderCheck(t *testing.T) {
+	h := &Header{Version: FormatVersion, FieldId: 1, Features: FeatureCustomGates | FeatureLookups}
+	if err := h.Check(Supported); err != nil {
+		t.Fatal(err)
+	}
+	for c, msg := range map[*Capabilities]string{
+		{MaxVersion: FormatVersion, Features: FeatureCustomGates}:                        "the circuit uses lookups, which the prover doesn't support",
+		{MaxVersion: 0, Features: Supported.Features}:                                    "format version 3, the prover reads up to version 0",
+		{MaxVersion: FormatVersion, FieldIds: []uint64{2}, Features: Supported.Features}: "field 1 isn't supported",
+	} {
+		if err := h.Check(*c); !errors.Is(err, ErrUnsupportedCircuit) || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("expected %q, got %v", msg, err)
+		}
+	}
+
+	// a feature of a newer compiler is rejected by DeserializeRootCircuit
+	buf := sampleRootCircuit().SerializeVersioned(0, nil)
+	binary.LittleEndian.PutUint64(buf[24:], 1<<10)
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "feature 0x400") {
+			t.Fatalf("expected an unknown feature to be rejected, got %v", r)
+		}
+	}()
+	DeserializeRootCircuit(buf)
+}
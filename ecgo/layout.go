@@ -0,0 +1,79 @@
+package ecgo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// publicInputLayout holds the constraints on the order of the public inputs, set by
+// WithPublicInputSlot and WithPublicInputGroup.
+type publicInputLayout struct {
+	slots  map[string]int
+	groups []string
+}
+
+// inGroup reports whether the public input name belongs to group, i.e. is the group itself or
+// one of its fields or elements, named as in schema.LeafInfo.FullName.
+func inGroup(name string, group string) bool {
+	return name == group || strings.HasPrefix(name, group+"_")
+}
+
+// order returns the slot of each public input, given their names in declaration order. Pinned
+// public inputs are placed first, then the remaining slots are filled by the groups in the order
+// they were given, and finally by the other public inputs in declaration order.
+func (l *publicInputLayout) order(names []string) ([]int, error) {
+	n := len(names)
+	index := make(map[string]int, n)
+	for i, name := range names {
+		index[name] = i
+	}
+	slots := make([]int, n)
+	for i := range slots {
+		slots[i] = -1
+	}
+	used := make([]bool, n)
+	for name, slot := range l.slots {
+		i, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("public input %q pinned to slot %d doesn't exist", name, slot)
+		}
+		if slot < 0 || slot >= n {
+			return nil, fmt.Errorf("slot %d of public input %q is out of range, there are %d public inputs", slot, name, n)
+		}
+		if used[slot] {
+			return nil, fmt.Errorf("slot %d is assigned to several public inputs", slot)
+		}
+		used[slot] = true
+		slots[i] = slot
+	}
+
+	next := 0
+	place := func(i int) {
+		for used[next] {
+			next++
+		}
+		used[next] = true
+		slots[i] = next
+	}
+	for _, group := range l.groups {
+		found := false
+		for i, name := range names {
+			if !inGroup(name, group) {
+				continue
+			}
+			found = true
+			if slots[i] == -1 {
+				place(i)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("public input group %q doesn't exist", group)
+		}
+	}
+	for i := range names {
+		if slots[i] == -1 {
+			place(i)
+		}
+	}
+	return slots, nil
+}
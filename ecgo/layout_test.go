@@ -0,0 +1,53 @@
+package ecgo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPublicInputLayout(t *testing.T) {
+	names := []string{"A", "B_0", "B_1", "C", "D_X", "D_Y"}
+	l := &publicInputLayout{
+		slots:  map[string]int{"C": 0},
+		groups: []string{"D", "B"},
+	}
+	slots, err := l.order(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{5, 3, 4, 0, 1, 2}; !reflect.DeepEqual(slots, expected) {
+		t.Fatalf("expected slots %v, got %v", expected, slots)
+	}
+
+	// a pinned public input splits a group
+	slots, err = (&publicInputLayout{slots: map[string]int{"A": 1}, groups: []string{"B"}}).order(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{1, 0, 2, 3, 4, 5}; !reflect.DeepEqual(slots, expected) {
+		t.Fatalf("expected slots %v, got %v", expected, slots)
+	}
+
+	// without constraints, the declaration order is kept
+	slots, err = (&publicInputLayout{}).order(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{0, 1, 2, 3, 4, 5}; !reflect.DeepEqual(slots, expected) {
+		t.Fatalf("expected slots %v, got %v", expected, slots)
+	}
+}
+
+func TestPublicInputLayoutErrors(t *testing.T) {
+	names := []string{"A", "B"}
+	for _, l := range []*publicInputLayout{
+		{slots: map[string]int{"E": 0}},
+		{slots: map[string]int{"A": 2}},
+		{slots: map[string]int{"A": 1, "B": 1}},
+		{groups: []string{"C"}},
+	} {
+		if _, err := l.order(names); err == nil {
+			t.Fatalf("expected an error for layout %+v", l)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package ecgo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/frontend"
+)
+
+// Limits bounds the size of the layered circuit, e.g. to the sizes supported by the prover. A
+// zero field means no limit.
+type Limits struct {
+	MaxLayers int
+	// MaxLayerWidth bounds the number of input and output wires of each layer, after padding.
+	MaxLayerWidth uint64
+	MaxGates      uint64
+}
+
+// Names of the limits, see LimitError.Limit
+const (
+	LimitLayers     = "layers"
+	LimitLayerWidth = "layer width"
+	LimitGates      = "gates"
+)
+
+// maxLimitContributors is the number of contributors reported by a LimitError
+const maxLimitContributors = 10
+
+// ErrLimitExceeded is wrapped by the errors of Compile for circuits exceeding their Limits.
+var ErrLimitExceeded = errors.New("circuit exceeds the compile limits")
+
+// LimitContributor is a source location contributing to an exceeded limit, see LimitError.
+type LimitContributor struct {
+	Location irsource.SourceLocation
+	// Value is the number of gates of the location, or the number of layers it adds to the
+	// deepest path of the circuit for LimitLayers, counting subcircuits once per call.
+	Value uint64
+}
+
+// LimitError is returned by Compile for a circuit exceeding one of its Limits.
+type LimitError struct {
+	// Limit is the exceeded limit: LimitLayers, LimitLayerWidth or LimitGates.
+	Limit string
+	Value uint64
+	Max   uint64
+	// Layer is the widest layer for LimitLayerWidth.
+	Layer int
+	// Estimated is set when the limit is exceeded by the estimate of the optimized IR, before
+	// the layering, see WithLimits.
+	Estimated bool
+	// Contributors are the source locations contributing the most to Value, by decreasing
+	// value. They're estimated from the optimized IR, the gates of the widest layer being the
+	// ones of the widest depth of the IR.
+	Contributors []LimitContributor
+}
+
+func (e *LimitError) Error() string {
+	var sb strings.Builder
+	approx := ""
+	if e.Estimated {
+		approx = "~"
+	}
+	switch e.Limit {
+	case LimitLayerWidth:
+		fmt.Fprintf(&sb, "layer %d of the circuit has %s%d wires, more than the limit of %d", e.Layer, approx, e.Value, e.Max)
+	default:
+		fmt.Fprintf(&sb, "circuit has %s%d %s, more than the limit of %d", approx, e.Value, e.Limit, e.Max)
+	}
+	if e.Estimated {
+		sb.WriteString(" (estimated before layering)")
+	}
+	if len(e.Contributors) != 0 {
+		sb.WriteString(", biggest contributors:")
+	}
+	unit := LimitGates
+	if e.Limit == LimitLayers {
+		unit = LimitLayers
+	}
+	for _, c := range e.Contributors {
+		fmt.Fprintf(&sb, "\n\t%d %s: %s", c.Value, unit, c.Location)
+	}
+	return sb.String()
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// WithLimits makes Compile fail with a *LimitError if the layered circuit exceeds limits. The
+// number of layers, the width of the layers and the number of gates are first estimated from the
+// optimized IR like in EstimateResources, and the compilation fails before the layering if an
+// estimate exceeds its limit. The estimates ignore the relay gates between layers and the
+// padding, so the layered circuit is checked again once it's layered and padded.
+func WithLimits(limits Limits) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.limits = limits
+	})
+}
+
+func (l *Limits) isZero() bool {
+	return *l == Limits{}
+}
+
+// checkEstimate checks the estimated size of the layered circuit of rc
+func (l *Limits) checkEstimate(rc *irsource.RootCircuit) error {
+	if l.isZero() {
+		return nil
+	}
+	p := newProfiler(rc)
+	if l.MaxLayers > 0 {
+		if n := estimatedLayers(rc); n > l.MaxLayers {
+			return limitExceeded(p, &LimitError{Limit: LimitLayers, Value: uint64(n), Max: uint64(l.MaxLayers), Estimated: true})
+		}
+	}
+	if l.MaxLayerWidth > 0 {
+		if d, w := p.widestDepth(); w > l.MaxLayerWidth {
+			return limitExceeded(p, &LimitError{Limit: LimitLayerWidth, Value: w, Max: l.MaxLayerWidth, Layer: d, Estimated: true})
+		}
+	}
+	if l.MaxGates > 0 {
+		if gates, _ := estimatedGateCounts(rc); gates > l.MaxGates {
+			return limitExceeded(p, &LimitError{Limit: LimitGates, Value: gates, Max: l.MaxGates, Estimated: true})
+		}
+	}
+	return nil
+}
+
+// check checks the size of the layered circuit of res
+func (l *Limits) check(res *CompileResult) error {
+	if l.isZero() {
+		return nil
+	}
+	rc, s := res.irs, res.Stats()
+	if l.MaxLayers > 0 && s.NumLayers > l.MaxLayers {
+		return limitExceeded(newProfiler(rc), &LimitError{Limit: LimitLayers, Value: uint64(s.NumLayers), Max: uint64(l.MaxLayers)})
+	}
+	if l.MaxLayerWidth > 0 && s.MaxWidth > l.MaxLayerWidth {
+		e := &LimitError{Limit: LimitLayerWidth, Value: s.MaxWidth, Max: l.MaxLayerWidth}
+		for i, ls := range s.Layers {
+			if max(ls.InputLen, ls.OutputLen) == s.MaxWidth {
+				e.Layer = i
+				break
+			}
+		}
+		return limitExceeded(newProfiler(rc), e)
+	}
+	if l.MaxGates > 0 && s.TotalGates() > l.MaxGates {
+		return limitExceeded(newProfiler(rc), &LimitError{Limit: LimitGates, Value: s.TotalGates(), Max: l.MaxGates})
+	}
+	return nil
+}
+
+// limitExceeded sets the contributors of e from the profile of the IR
+func limitExceeded(p *profiler, e *LimitError) error {
+	values := make(map[uint32]uint64)
+	switch e.Limit {
+	case LimitLayerWidth:
+		d, _ := p.widestDepth()
+		p.gatesAtDepth(0, d, values)
+	default:
+		i := 2
+		if e.Limit == LimitLayers {
+			i = 3
+		}
+		p.build()
+		for loc, s := range p.samples {
+			if s.Value[i] > 0 {
+				values[loc] = uint64(s.Value[i])
+			}
+		}
+	}
+	locs := make([]uint32, 0, len(values))
+	for loc := range values {
+		locs = append(locs, loc)
+	}
+	sort.Slice(locs, func(i, j int) bool {
+		if values[locs[i]] != values[locs[j]] {
+			return values[locs[i]] > values[locs[j]]
+		}
+		return locs[i] < locs[j]
+	})
+	if len(locs) > maxLimitContributors {
+		locs = locs[:maxLimitContributors]
+	}
+	for _, loc := range locs {
+		e.Contributors = append(e.Contributors, LimitContributor{Location: p.rc.Location(loc), Value: values[loc]})
+	}
+	return e
+}
@@ -0,0 +1,37 @@
+package ecgo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+)
+
+func TestLimits(t *testing.T) {
+	for _, tc := range []struct {
+		limits Limits
+		limit  string
+		value  uint64
+	}{
+		{Limits{MaxLayers: 2}, LimitLayers, 3},
+		// the 2 multiplications of each of the 4 calls of cube, and the additions of the sum
+		{Limits{MaxGates: 8}, LimitGates, 0},
+		{Limits{MaxLayerWidth: 4}, LimitLayerWidth, 0},
+	} {
+		_, err := Compile(m31.ScalarField, &estimateCircuit{}, WithLimits(tc.limits), WithSourceLocations(1))
+		var le *LimitError
+		if !errors.As(err, &le) || !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("expected a limit error for %+v, got %v", tc.limits, err)
+		}
+		if le.Limit != tc.limit || !le.Estimated || le.Value <= le.Max || (tc.value != 0 && le.Value != tc.value) {
+			t.Fatalf("unexpected error %+v", le)
+		}
+		if len(le.Contributors) == 0 || !strings.HasPrefix(le.Contributors[0].Location.String(), "estimate_test.go:") {
+			t.Fatalf("expected cube to contribute the most, got %v", le)
+		}
+		if !strings.Contains(le.Error(), "biggest contributors") {
+			t.Fatalf("unexpected message %q", le.Error())
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package ecgo
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// CompileMany compiles several top-level circuits into a single layered circuit, for systems
+// proving many related statements at once. The circuits are defined one after the other in the
+// same root, so they share its subcircuit registry: a gadget memorized by several circuits, with
+// arguments of the same shape, is built and laid out once, and identical subcircuit bodies are
+// deduplicated across the circuits as within one.
+//
+// The inputs of circuit k are named "Circuits_k_Name" in PublicInputLayout and in the public
+// input options, e.g. WithPublicInputSlot, and its tags "k/Name" in Tags. The outputs of the
+// circuits follow each other in the output layer. The witness is solved from the assignments of
+// all the circuits, joined by ManyAssignment.
+func CompileMany(field *big.Int, circuits []frontend.Circuit, opts ...frontend.CompileOption) (*CompileResult, error) {
+	if len(circuits) == 0 {
+		return nil, errors.New("no circuit to compile")
+	}
+	return Compile(field, &multiCircuit{Circuits: circuits}, opts...)
+}
+
+// ManyAssignment returns the assignment of circuits compiled by CompileMany, given the
+// assignment of each circuit, in the same order, e.g. for irwg.RootCircuit.SolveInputAuto.
+func ManyAssignment(assignments ...frontend.Circuit) frontend.Circuit {
+	return &multiCircuit{Circuits: assignments}
+}
+
+// multiCircuit is the root circuit of CompileMany
+type multiCircuit struct {
+	Circuits []frontend.Circuit
+}
+
+// tagPrefixer is implemented by builder.Root
+type tagPrefixer interface {
+	SetTagPrefix(prefix string)
+}
+
+func (c *multiCircuit) Define(api frontend.API) error {
+	r, _ := api.(tagPrefixer)
+	for k, circuit := range c.Circuits {
+		if r != nil {
+			r.SetTagPrefix(fmt.Sprintf("%d/", k))
+		}
+		if err := circuit.Define(api); err != nil {
+			return fmt.Errorf("circuit %d: %w", k, err)
+		}
+	}
+	if r != nil {
+		r.SetTagPrefix("")
+	}
+	return nil
+}
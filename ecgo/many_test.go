@@ -0,0 +1,73 @@
+package ecgo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/consensys/gnark/frontend"
+)
+
+// cubeCircuit and offsetCircuit are different statements on the same gadget, cube
+type cubeCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *cubeCircuit) Define(api frontend.API) error {
+	y := builder.MemorizedSimpleFunc(cube)(api, []frontend.Variable{c.X})[0]
+	api.AssertIsEqual(y, c.Y)
+	api.(API).Tag(y, "cube")
+	return nil
+}
+
+type offsetCircuit struct {
+	X, Offset frontend.Variable
+}
+
+func (c *offsetCircuit) Define(api frontend.API) error {
+	y := builder.MemorizedSimpleFunc(cube)(api, []frontend.Variable{api.Add(c.X, c.Offset)})[0]
+	api.(API).Tag(y, "cube")
+	return nil
+}
+
+func TestCompileMany(t *testing.T) {
+	circuit := &multiCircuit{Circuits: []frontend.Circuit{&cubeCircuit{}, &offsetCircuit{}}}
+	root, layout, _, err := defineRoot(m31.ScalarField, circuit, frontend.CompileConfig{}, defaultCompileConfig(), &progress{ctx: context.Background()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := root.Finalize()
+	// the root circuit and the gadget, shared by both circuits
+	if len(rc.Circuits) != 2 || rc.Circuits[0].NumInputs != 3 || rc.NumPublicInputs != 1 {
+		t.Fatalf("expected a single subcircuit, got %d circuits with %d inputs", len(rc.Circuits), rc.Circuits[0].NumInputs)
+	}
+	tags := []builder.OutputTag{{Name: "0/cube", Output: 0}, {Name: "1/cube", Output: 1}}
+	if !reflect.DeepEqual(root.Tags(), tags) {
+		t.Fatalf("unexpected tags %v", root.Tags())
+	}
+	if !reflect.DeepEqual(layout, []string{"Circuits_0_Y"}) {
+		t.Fatalf("unexpected public inputs %v", layout)
+	}
+
+	s, err := circuitSchema(circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.NbPublic != 1 || s.NbSecret != 3 || len(s.Fields[0].SubFields) != 2 || s.Fields[0].SubFields[1].SubFields[1].FullName != "Circuits_1_Offset" {
+		t.Fatalf("unexpected schema %+v", s)
+	}
+
+	// the values of the assignment are in the order of the inputs
+	public, secret := irwg.GetCircuitVariables(ManyAssignment(&cubeCircuit{X: 2, Y: 8}, &offsetCircuit{X: 3, Offset: 4}), rc.Field)
+	if len(public) != 1 || len(secret) != 3 || rc.Field.ToBigInt(secret[2]).Int64() != 4 {
+		t.Fatalf("unexpected values %v %v", public, secret)
+	}
+
+	if _, err := CompileMany(m31.ScalarField, nil); err == nil {
+		t.Fatal("expected no circuit to be rejected")
+	}
+}
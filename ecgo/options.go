@@ -0,0 +1,336 @@
+package ecgo
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/passes"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/rust"
+	"github.com/consensys/gnark/frontend"
+)
+
+// compileConfig holds the ecgo specific compile options. They are set by options of the same
+// type as gnark's frontend.CompileOption, so both kinds can be passed to Compile.
+type compileConfig struct {
+	extractMinLength  int
+	extractMinRepeats int
+	disableCSE        bool
+	disableFolding    bool
+	disableDCE        bool
+	reassociate       bool
+	relays            layered.RelayStrategy
+	padding           layered.Padding
+	workers           int
+	lowMemory         bool
+	spillDir          string
+	publicLayout      publicInputLayout
+	cacheDir          string
+	locationDepth     int
+	noDebugPrints     bool
+	growth            builder.Growth
+	hooks             builder.Hooks
+	outputClaims      int
+	profilePath       string
+	progress          func(Progress)
+	trace             slog.Handler
+	snapshotDir       string
+	limits            Limits
+	plonk             bool
+	// options of the Rust compiler, see rust.Options
+	rust rust.Options
+	// pipeline replaces the default passes, see passes
+	pipeline passes.Pipeline
+	// root to define the circuit with instead of a new one, and the callback receiving the
+	// defined root, see CompileVariants
+	fork    *builder.Root
+	defined func(*builder.Root)
+}
+
+func defaultCompileConfig() *compileConfig {
+	return &compileConfig{workers: runtime.GOMAXPROCS(0), locationDepth: builder.DefaultSourceLocationDepth}
+}
+
+// compileConfigs maps the gnark config currently being built by Compile to its ecgo config
+var compileConfigs sync.Map
+
+var errNotEcgoCompile = errors.New("ecgo compile options can only be used with ecgo.Compile")
+
+func ecgoOption(f func(*compileConfig)) frontend.CompileOption {
+	return func(opt *frontend.CompileConfig) error {
+		c, ok := compileConfigs.Load(opt)
+		if !ok {
+			return errNotEcgoCompile
+		}
+		f(c.(*compileConfig))
+		return nil
+	}
+}
+
+// WithSubCircuitExtraction enables the detection of repeated fragments of at least minLength
+// instructions, occurring at least minRepeats times in the root circuit. Each such fragment is
+// extracted into a subcircuit, as if it had been written with MemorizedSimpleCall.
+func WithSubCircuitExtraction(minLength int, minRepeats int) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.extractMinLength = minLength
+		c.extractMinRepeats = minRepeats
+	})
+}
+
+// WithCommonSubexpressionElimination enables or disables the removal of instructions computing
+// the same value as a previous one. It's enabled by default.
+func WithCommonSubexpressionElimination(enabled bool) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.disableCSE = !enabled
+	})
+}
+
+// WithConstantFolding enables or disables the evaluation at compile time of instructions whose
+// operands are constants. It's enabled by default, and can be disabled for debugging.
+func WithConstantFolding(enabled bool) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.disableFolding = !enabled
+	})
+}
+
+// WithDeadCodeElimination enables or disables the removal of instructions whose results never
+// reach a constraint or an output. It's enabled by default.
+func WithDeadCodeElimination(enabled bool) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.disableDCE = !enabled
+	})
+}
+
+// WithReassociation enables or disables the merging of chains of additions into wide linear
+// combinations, and of chains of multiplications into balanced trees, which reduces the depth of
+// the layered circuit. It's disabled by default, since it changes the layered circuit of existing
+// circuits.
+func WithReassociation(enabled bool) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.reassociate = enabled
+	})
+}
+
+// WithOptimizationLevel sets the passes run on the circuit before the layering to the ones of
+// the given level, see passes.Level. The default is level 1, or level 2 with WithReassociation.
+func WithOptimizationLevel(level int) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.pipeline = passes.Level(level)
+	})
+}
+
+// WithPipeline sets the passes run on the circuit before the layering, in order, e.g. to reorder
+// them or to add custom passes, see passes.Pass. The options disabling a pass still remove it
+// from the pipeline, and WithSubCircuitExtraction still runs first.
+func WithPipeline(p passes.Pipeline) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.pipeline = append(passes.Pipeline{}, p...)
+	})
+}
+
+// passes returns the pipeline run on the circuit
+func (c *compileConfig) passes() passes.Pipeline {
+	p := c.pipeline
+	if p == nil {
+		p = passes.Level(1)
+		if c.reassociate {
+			p = passes.Level(2)
+		}
+	}
+	for name, disabled := range map[string]bool{passes.Fold.Name(): c.disableFolding, passes.CSE.Name(): c.disableCSE, passes.DCE.Name(): c.disableDCE} {
+		if disabled {
+			p = p.Without(name)
+		}
+	}
+	if c.extractMinLength > 0 {
+		p = append(passes.Pipeline{passes.Extraction(c.extractMinLength, c.extractMinRepeats)}, p...)
+	}
+	return p
+}
+
+// WithRelays sets how the values used many layers after they're computed are carried across the
+// layers, see layered.RootCircuit.Reroute. The default keeps the relay gates of the compiler,
+// which copy them through every layer. layered.RootCircuit.RelayStats reports the relay gates.
+func WithRelays(s layered.RelayStrategy) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.relays = s
+	})
+}
+
+// WithPadding sets how the layers of the layered circuit are padded, see layered.RootCircuit.Pad.
+// The default is the padding of the compiler, each layer being padded to the next power of 2 of
+// its width without padding gates. CompileResult.Stats reports the padding wires and the gates
+// whose results are never read.
+func WithPadding(strategy layered.PaddingStrategy, gates layered.PaddingGates) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.padding = layered.Padding{Strategy: strategy, Gates: gates}
+	})
+}
+
+// WithOutputAggregation combines the outputs expected to be zero into the given number of random
+// linear combinations, in a layer appended to the layered circuit, see
+// layered.RootCircuit.AggregateOutputs. It reduces the verifier work and the proof size of
+// circuits with very wide output layers, e.g. over GF2, where each assertion is an output, where
+// claims around 100 keep the soundness error negligible. With CompileBatch, the outputs of all the
+// copies are combined.
+func WithOutputAggregation(claims int) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.outputClaims = claims
+	})
+}
+
+// WithMaxQuadraticTerms sets the number of degree 2 terms of an expression above which the
+// final build of the Rust compiler compresses it into a new variable, splitting wider quadratic
+// expressions into balanced chunks. The default depends on the costs of the gates of the field:
+// smaller values trade mul gates of the layered circuit for depth.
+func WithMaxQuadraticTerms(n int) frontend.CompileOption {
+	if n <= 0 {
+		return func(*frontend.CompileConfig) error {
+			return fmt.Errorf("the maximum number of quadratic terms must be positive, got %d", n)
+		}
+	}
+	return ecgoOption(func(c *compileConfig) {
+		c.rust.MaxQuadraticTerms = uint64(n)
+	})
+}
+
+// WithCompressThresholds sets the number of terms, and of degree 2 terms among them, of an
+// expression above which the final build of the Rust compiler compresses it into a new variable;
+// zero keeps the threshold unchanged, see WithMaxQuadraticTerms. Larger thresholds make fewer
+// variables and layers, at the cost of copying the terms of an expression into each of its uses.
+func WithCompressThresholds(maxTerms int, maxQuadraticTerms int) frontend.CompileOption {
+	if maxTerms < 0 || maxQuadraticTerms < 0 {
+		return func(*frontend.CompileConfig) error {
+			return fmt.Errorf("the compress thresholds can't be negative, got %d and %d", maxTerms, maxQuadraticTerms)
+		}
+	}
+	return ecgoOption(func(c *compileConfig) {
+		if maxTerms > 0 {
+			c.rust.MaxTerms = uint64(maxTerms)
+		}
+		if maxQuadraticTerms > 0 {
+			c.rust.MaxQuadraticTerms = uint64(maxQuadraticTerms)
+		}
+	})
+}
+
+// WithWorkers sets the number of goroutines used to optimize independent subcircuits concurrently.
+// It defaults to GOMAXPROCS.
+func WithWorkers(n int) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.workers = n
+	})
+}
+
+// WithLowMemory makes Compile write the layered circuit to a temporary file in dir instead of
+// keeping it in memory; an empty dir means the default directory for temporary files. The Rust
+// compiler writes the file itself, so the serialized circuit is never held by Go, and large
+// circuits can be compiled and proved from CompileResult.LayeredCircuitFile without holding their
+// layered form. Its content hash is computed in the mapped file, see layered.MappedCircuit.
+// CompileResult.GetLayeredCircuit loads the circuit the first time it's called, and
+// CompileResult.Close removes the file.
+//
+// Only the memory held by Go is bounded: the Rust compiler still holds the whole expression DAG
+// and layered circuit in memory while compiling, and nothing is spilled per layer or processed
+// in chunks.
+func WithLowMemory(dir string) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.lowMemory = true
+		c.spillDir = dir
+	})
+}
+
+// WithCompileCache makes Compile store its results in dir, and reuse them instead of running the
+// Rust compiler when the same circuit is compiled again with the same compiler and options. A
+// circuit is identified by the constraints produced by Define, so any change to it is detected.
+// With WithLowMemory, the layered circuit is read from the cache entry instead of a temporary file.
+func WithCompileCache(dir string) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.cacheDir = dir
+	})
+}
+
+// WithSnapshots makes Compile write a snapshot of the compilation to dir once the circuit is
+// built, optimized and layered, and resume from the last snapshot when it's called again, e.g.
+// after a crash. The circuit isn't defined when resuming, so the snapshots are only matched with
+// its type, its variables and the options: dir must be cleared after any other change to the
+// circuit. The layered circuit is read from the snapshot instead of the compile cache, and
+// source locations are unknown in a resumed compilation.
+func WithSnapshots(dir string) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.snapshotDir = dir
+	})
+}
+
+// WithPublicInputSlot pins the public input with the given name, as returned by
+// schema.LeafInfo.FullName (e.g. "Header_Root" or "Hash_3"), to the given slot of the public
+// inputs of the final circuit. The slots of the other public inputs are shifted accordingly.
+func WithPublicInputSlot(name string, slot int) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		if c.publicLayout.slots == nil {
+			c.publicLayout.slots = make(map[string]int)
+		}
+		c.publicLayout.slots[name] = slot
+	})
+}
+
+// WithPublicInputGroup places the public inputs of the given group, the name of a field of the
+// circuit holding several public inputs, in declaration order in the first slots left free by
+// pinned public inputs and earlier groups. The group is contiguous unless one of its public
+// inputs is pinned, or another public input is pinned to a slot within its range. Groups are
+// laid out in the order of the options, before the public inputs in no group. Together with
+// WithPublicInputSlot, it gives a layout that doesn't depend on the declaration order of the
+// circuit, see CompileResult.PublicInputLayout.
+func WithPublicInputGroup(group string) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.publicLayout.groups = append(c.publicLayout.groups, group)
+	})
+}
+
+// WithSourceLocations sets the number of call frames recorded for each instruction and constraint,
+// innermost first, up to builder.MaxSourceLocationDepth. They are reported by CheckWitness and
+// by the source statistics of the circuit, see irsource.RootCircuit.SourceStats. The default
+// is builder.DefaultSourceLocationDepth, and 0 disables the recording to build large circuits faster.
+func WithSourceLocations(depth int) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.locationDepth = depth
+	})
+}
+
+// WithDebugPrints sets whether the Println calls of the circuit are compiled. They print during
+// witness solving, at the cost of a hint and a constraint each. Enabled by default.
+func WithDebugPrints(enabled bool) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.noDebugPrints = !enabled
+	})
+}
+
+// WithGrowth sets the strategy with which the builders grow their instructions, constraints and
+// variables, see builder.Growth. The root circuit preallocates frontend.WithCapacity of them.
+func WithGrowth(g builder.Growth) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.growth = g
+	})
+}
+
+// WithHooks sets callbacks observing the builders while the circuit is defined, e.g. for
+// coverage tools or constraint auditors, see builder.Hooks.
+func WithHooks(h builder.Hooks) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.hooks = h
+	})
+}
+
+// WithProfile writes a pprof profile of the compiled circuit to the given file, see
+// CompileResult.WriteProfile. It records the whole call stacks of the instructions and
+// constraints, unless it's followed by WithSourceLocations.
+func WithProfile(path string) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.profilePath = path
+		c.locationDepth = builder.MaxSourceLocationDepth
+	})
+}
@@ -0,0 +1,143 @@
+package passes
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint"
+)
+
+// EliminateCommonSubexpressions canonicalizes the instructions of every circuit, and replaces
+// each pure instruction computing the same value as a previous one by the previous result.
+// Duplicate constraints are removed as well. It returns the number of removed instructions.
+func EliminateCommonSubexpressions(rc *irsource.RootCircuit, opts ...Option) int {
+	return newConfig(opts).forEachCircuit(rc, func(_ uint64, c *irsource.Circuit) int {
+		return eliminateCommonSubexpressions(c, rc.Field)
+	})
+}
+
+func eliminateCommonSubexpressions(c *irsource.Circuit, f field.Field) int {
+	res := 0
+	seen := make(map[string][]int)
+	nextVar := c.NumInputs + 1
+	rewriteCircuit(c, func(in *irsource.Instruction) (*irsource.Instruction, []int) {
+		canonicalizeInstruction(in, f)
+		if in.Type == irsource.LinComb && len(in.Inputs) == 1 && in.LinCombCoef[0] == f.One() && in.Const.IsZero() {
+			// a copy of a variable
+			res++
+			return nil, []int{in.Inputs[0]}
+		}
+		if isPure(in) {
+			key := instructionKey(in)
+			if vars, ok := seen[key]; ok {
+				res++
+				return nil, vars
+			}
+			vars := make([]int, in.OutputCount())
+			for i := range vars {
+				vars[i] = nextVar + i
+			}
+			seen[key] = vars
+		}
+		nextVar += in.OutputCount()
+		return in, nil
+	})
+
+	constraints := c.Constraints[:0]
+	seenConstraints := make(map[irsource.Constraint]bool)
+	for _, con := range c.Constraints {
+		// duplicates may come from different source locations
+		key := irsource.Constraint{Typ: con.Typ, Var: con.Var}
+		if !seenConstraints[key] {
+			seenConstraints[key] = true
+			constraints = append(constraints, con)
+		}
+	}
+	c.Constraints = constraints
+	return res
+}
+
+// isPure returns whether the result of the instruction only depends on its operands.
+// Hints may be used for unconstrained witnesses, so they are not pure, except the builtin
+// division hint, whose result is checked by the constraints of LowerDivisions.
+func isPure(in *irsource.Instruction) bool {
+	switch in.Type {
+	case irsource.LinComb, irsource.Mul, irsource.Div, irsource.BoolBinOp, irsource.IsZero,
+		irsource.UnconstrainedBinOp, irsource.UnconstrainedSelect, irsource.CustomGate:
+		return true
+	case irsource.ConstantLike:
+		// random values are independent from each other
+		return in.ExtraId != 1
+	case irsource.Hint:
+		return in.ExtraId == irsource.DivHintId
+	}
+	return false
+}
+
+// canonicalizeInstruction merges and sorts the terms of linear combinations, and sorts the
+// operands of commutative operations.
+func canonicalizeInstruction(in *irsource.Instruction, f field.Field) {
+	switch in.Type {
+	case irsource.LinComb:
+		idx := make([]int, len(in.Inputs))
+		for i := range idx {
+			idx[i] = i
+		}
+		sort.SliceStable(idx, func(i, j int) bool { return in.Inputs[idx[i]] < in.Inputs[idx[j]] })
+		inputs := []int{}
+		coefs := []constraint.Element{}
+		for _, i := range idx {
+			if n := len(inputs); n > 0 && inputs[n-1] == in.Inputs[i] {
+				coefs[n-1] = f.Add(coefs[n-1], in.LinCombCoef[i])
+			} else {
+				inputs = append(inputs, in.Inputs[i])
+				coefs = append(coefs, in.LinCombCoef[i])
+			}
+		}
+		k := 0
+		for i := range inputs {
+			if !coefs[i].IsZero() {
+				inputs[k] = inputs[i]
+				coefs[k] = coefs[i]
+				k++
+			}
+		}
+		if k == 0 {
+			// keep the instruction as is, a linear combination needs at least one term
+			return
+		}
+		in.Inputs = inputs[:k]
+		in.LinCombCoef = coefs[:k]
+	case irsource.Mul:
+		inputs := append([]int{}, in.Inputs...)
+		sort.Ints(inputs)
+		in.Inputs = inputs
+	case irsource.BoolBinOp:
+		if in.X > in.Y {
+			in.X, in.Y = in.Y, in.X
+		}
+	}
+}
+
+func instructionKey(in *irsource.Instruction) string {
+	buf := make([]byte, 0, 64)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(in.Type))
+	buf = binary.LittleEndian.AppendUint64(buf, in.ExtraId)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(in.NumOutputs))
+	ops := in.Operands()
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(ops)))
+	for _, x := range ops {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(x))
+	}
+	for _, e := range in.LinCombCoef {
+		for _, x := range e {
+			buf = binary.LittleEndian.AppendUint64(buf, x)
+		}
+	}
+	for _, x := range in.Const {
+		buf = binary.LittleEndian.AppendUint64(buf, x)
+	}
+	return string(buf)
+}
@@ -0,0 +1,77 @@
+package passes
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func TestEliminateCommonSubexpressions(t *testing.T) {
+	root := builder.NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	y := root.SecretVariable(schema.LeafInfo{})
+	a := root.Mul(x, y)
+	b := root.Mul(y, x)
+	c := root.Add(a, x, y)
+	d := root.Add(y, b, x)
+	root.AssertIsEqual(c, 5)
+	root.AssertIsEqual(d, 5)
+	root.Output(root.Mul(c, d))
+	rc := root.Finalize()
+
+	inputs := []constraint.Element{rc.Field.FromInterface(3), rc.Field.FromInterface(5)}
+	expected := evalCircuit(t, rc, 0, inputs)
+	before := len(rc.Circuits[0].Instructions)
+	n := EliminateCommonSubexpressions(rc)
+	if n == 0 || len(rc.Circuits[0].Instructions) != before-n {
+		t.Fatalf("expected instructions to be removed, %d before, %d removed", before, n)
+	}
+	nbMul := 0
+	for _, in := range rc.Circuits[0].Instructions {
+		if in.Type == irsource.Mul {
+			nbMul++
+		}
+	}
+	if nbMul != 2 {
+		t.Fatalf("expected 2 multiplications, got %d", nbMul)
+	}
+	got := evalCircuit(t, rc, 0, inputs)
+	if got[0] != expected[0] {
+		t.Fatal("output differs after elimination")
+	}
+	for _, v := range got[1:] {
+		if v != expected[1] {
+			t.Fatal("constraint differs after elimination")
+		}
+	}
+}
+
+func TestPassesWithWorkers(t *testing.T) {
+	build := func() *irsource.RootCircuit {
+		root := builder.NewRoot(m31.ScalarField, frontend.CompileConfig{})
+		x := root.SecretVariable(schema.LeafInfo{})
+		for i := 0; i < 8; i++ {
+			sq := func(api frontend.API, input []frontend.Variable) []frontend.Variable {
+				return []frontend.Variable{api.Add(api.Mul(input[0], input[0]), api.Mul(input[0], input[0]), i)}
+			}
+			x = root.MemorizedSimpleCall(sq, []frontend.Variable{x})[0]
+		}
+		root.AssertIsEqual(x, 1)
+		return root.Finalize()
+	}
+	rc1, rc2 := build(), build()
+	n1 := EliminateCommonSubexpressions(rc1)
+	n2 := EliminateCommonSubexpressions(rc2, WithWorkers(4))
+	if n1 != n2 || n1 == 0 {
+		t.Fatalf("expected the same number of eliminated instructions, got %d and %d", n1, n2)
+	}
+	inputs := []constraint.Element{rc1.Field.FromInterface(7)}
+	if evalCircuit(t, rc1, 0, inputs)[0] != evalCircuit(t, rc2, 0, inputs)[0] {
+		t.Fatal("results differ")
+	}
+}
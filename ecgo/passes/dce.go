@@ -0,0 +1,109 @@
+package passes
+
+import "github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+
+// EliminateDeadCode removes the instructions whose results never reach a constraint or an output,
+// and the subcircuits which are no longer called. Instructions which constrain their operands,
+// like checked divisions, are always kept. It returns the number of removed instructions.
+func EliminateDeadCode(rc *irsource.RootCircuit, opts ...Option) int {
+	constrained := make(map[uint64]bool)
+	var hasConstraints func(id uint64) bool
+	var keep func(in *irsource.Instruction) bool
+	hasConstraints = func(id uint64) bool {
+		if r, ok := constrained[id]; ok {
+			return r
+		}
+		c := rc.Circuits[id]
+		r := len(c.Constraints) > 0
+		for i := range c.Instructions {
+			if r {
+				break
+			}
+			r = keep(&c.Instructions[i])
+		}
+		constrained[id] = r
+		return r
+	}
+	keep = func(in *irsource.Instruction) bool {
+		return hasSideEffect(in) || (in.Type == irsource.SubCircuitCall && hasConstraints(in.ExtraId))
+	}
+	// compute it for every circuit first, since the map can't be written concurrently
+	for id := range rc.Circuits {
+		hasConstraints(id)
+	}
+	res := newConfig(opts).forEachCircuit(rc, func(_ uint64, c *irsource.Circuit) int {
+		return eliminateDeadCode(c, keep)
+	})
+
+	// remove unreachable subcircuits
+	reachable := map[uint64]bool{0: true}
+	queue := []uint64{0}
+	for len(queue) > 0 {
+		c := rc.Circuits[queue[0]]
+		queue = queue[1:]
+		for _, in := range c.Instructions {
+			if in.Type == irsource.SubCircuitCall && !reachable[in.ExtraId] {
+				reachable[in.ExtraId] = true
+				queue = append(queue, in.ExtraId)
+			}
+		}
+	}
+	for id := range rc.Circuits {
+		if !reachable[id] {
+			delete(rc.Circuits, id)
+		}
+	}
+	return res
+}
+
+// hasSideEffect returns whether the instruction implicitly constrains its operands
+func hasSideEffect(in *irsource.Instruction) bool {
+	switch in.Type {
+	case irsource.Div:
+		return in.ExtraId == 0
+	case irsource.BoolBinOp, irsource.Commit:
+		return true
+	}
+	return false
+}
+
+func eliminateDeadCode(c *irsource.Circuit, keep func(in *irsource.Instruction) bool) int {
+	n := len(c.Instructions)
+	varStart := make([]int, n+1)
+	varStart[0] = c.NumInputs + 1
+	for i := range c.Instructions {
+		varStart[i+1] = varStart[i] + c.Instructions[i].OutputCount()
+	}
+	live := make([]bool, varStart[n])
+	for _, con := range c.Constraints {
+		live[con.Var] = true
+	}
+	for _, v := range c.Outputs {
+		live[v] = true
+	}
+	alive := make([]bool, n)
+	for i := n - 1; i >= 0; i-- {
+		in := &c.Instructions[i]
+		alive[i] = keep(in)
+		for v := varStart[i]; v < varStart[i+1] && !alive[i]; v++ {
+			alive[i] = live[v]
+		}
+		if alive[i] {
+			for _, x := range in.Operands() {
+				live[x] = true
+			}
+		}
+	}
+
+	res := 0
+	i := 0
+	rewriteCircuit(c, func(in *irsource.Instruction) (*irsource.Instruction, []int) {
+		i++
+		if alive[i-1] {
+			return in, nil
+		}
+		res++
+		return nil, make([]int, in.OutputCount())
+	})
+	return res
+}
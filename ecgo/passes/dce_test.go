@@ -0,0 +1,52 @@
+package passes
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func unusedSquare(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	return []frontend.Variable{api.Mul(input[0], input[0])}
+}
+
+func TestEliminateDeadCode(t *testing.T) {
+	root := builder.NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	y := root.SecretVariable(schema.LeafInfo{})
+	root.Mul(x, y, y)
+	root.MemorizedSimpleCall(unusedSquare, []frontend.Variable{x})
+	root.Div(x, y)
+	root.AssertIsEqual(root.Add(x, y), 5)
+	rc := root.Finalize()
+
+	inputs := []constraint.Element{rc.Field.FromInterface(2), rc.Field.FromInterface(3)}
+	expected := evalCircuit(t, rc, 0, inputs)
+	if n := EliminateDeadCode(rc); n == 0 {
+		t.Fatal("expected dead instructions to be removed")
+	}
+	if len(rc.Circuits) != 1 {
+		t.Fatal("expected the unused subcircuit to be removed")
+	}
+	nbDiv := 0
+	for _, in := range rc.Circuits[0].Instructions {
+		switch in.Type {
+		case irsource.Mul, irsource.SubCircuitCall:
+			t.Fatalf("instruction of type %d should have been removed", in.Type)
+		case irsource.Div:
+			nbDiv++
+		}
+	}
+	if nbDiv != 1 {
+		t.Fatal("checked division should be kept")
+	}
+	got := evalCircuit(t, rc, 0, inputs)
+	if len(got) != 1 || got[0] != expected[len(expected)-1] {
+		t.Fatal("constraint differs after elimination")
+	}
+}
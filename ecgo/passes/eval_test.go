@@ -0,0 +1,65 @@
+package passes
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint"
+)
+
+// evalCircuit evaluates the arithmetic subset of the source IR used in the tests of this package
+func evalCircuit(t *testing.T, rc *irsource.RootCircuit, id uint64, inputs []constraint.Element) []constraint.Element {
+	f := rc.Field
+	c := rc.Circuits[id]
+	values := append([]constraint.Element{{}}, inputs...)
+	for _, in := range c.Instructions {
+		switch in.Type {
+		case irsource.LinComb:
+			r := in.Const
+			for i, x := range in.Inputs {
+				r = f.Add(r, f.Mul(in.LinCombCoef[i], values[x]))
+			}
+			values = append(values, r)
+		case irsource.Mul:
+			r := f.One()
+			for _, x := range in.Inputs {
+				r = f.Mul(r, values[x])
+			}
+			values = append(values, r)
+		case irsource.Div:
+			inv, _ := f.Inverse(values[in.Y])
+			values = append(values, f.Mul(values[in.X], inv))
+		case irsource.IsZero:
+			if values[in.X].IsZero() {
+				values = append(values, f.One())
+			} else {
+				values = append(values, f.Zero())
+			}
+		case irsource.Hint:
+			if in.ExtraId != irsource.DivHintId {
+				t.Fatalf("unsupported hint %d", in.ExtraId)
+			}
+			// x / y, or 0 when y is zero
+			inv, _ := f.Inverse(values[in.Inputs[1]])
+			values = append(values, f.Mul(values[in.Inputs[0]], inv))
+		case irsource.ConstantLike:
+			values = append(values, in.Const)
+		case irsource.SubCircuitCall:
+			subInputs := []constraint.Element{}
+			for _, x := range in.Inputs {
+				subInputs = append(subInputs, values[x])
+			}
+			values = append(values, evalCircuit(t, rc, in.ExtraId, subInputs)...)
+		default:
+			t.Fatalf("unsupported instruction type %d", in.Type)
+		}
+	}
+	res := []constraint.Element{}
+	for _, x := range c.Outputs {
+		res = append(res, values[x])
+	}
+	for _, con := range c.Constraints {
+		res = append(res, values[con.Var])
+	}
+	return res
+}
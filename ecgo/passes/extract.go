@@ -0,0 +1,397 @@
+// Package passes contains transformations and analyses of the source IR. They are applied by
+// ecgo.Compile to the circuit produced by the builder, before it is handed to the layering backend.
+package passes
+
+import (
+	"sort"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+)
+
+// maxFragmentLength bounds the length of the fragments searched by ExtractRepeatedFragments
+const maxFragmentLength = 1 << 12
+
+// ExtractRepeatedFragments detects fragments of consecutive instructions that are repeated in the
+// root circuit, e.g. the rounds of a hash function that is not wrapped in MemorizedSimpleCall,
+// and hoists them into subcircuits. Only fragments of at least minLength instructions occurring
+// at least minRepeats times are considered. It returns the number of extracted subcircuits.
+func ExtractRepeatedFragments(rc *irsource.RootCircuit, minLength int, minRepeats int) int {
+	if minLength < 2 {
+		minLength = 2
+	}
+	if minRepeats < 2 {
+		minRepeats = 2
+	}
+	res := 0
+	for {
+		f := newFragmentFinder(rc.Circuits[0], rc)
+		best := f.findBest(minLength, minRepeats)
+		if best == nil {
+			return res
+		}
+		f.extract(best)
+		res++
+	}
+}
+
+type fragmentFinder struct {
+	rc        *irsource.RootCircuit
+	c         *irsource.Circuit
+	shape     []uint64 // hash of each instruction, ignoring its operands
+	varStart  []int    // first variable defined by each instruction, varStart[n] is the total
+	defInsn   []int    // instruction defining each variable, -1 for inputs
+	maxUse    []int    // last instruction using each variable
+	usedAtEnd []bool   // variable is used by a constraint or an output
+	badPrefix []int    // number of instructions which can't be extracted in each prefix
+	nbInsns   int
+	nbVars    int
+}
+
+type fragmentGroup struct {
+	length  int
+	starts  []int
+	outputs []int // local indices of variables used outside the fragment
+	savings int
+}
+
+func mix(h uint64, x uint64) uint64 {
+	h ^= x + 0x9e3779b97f4a7c15 + (h << 6) + (h >> 2)
+	return h * 0xff51afd7ed558ccd
+}
+
+func instructionShape(in *irsource.Instruction) uint64 {
+	h := mix(0, uint64(in.Type))
+	h = mix(h, in.ExtraId)
+	h = mix(h, uint64(in.NumOutputs))
+	h = mix(h, uint64(len(in.Operands())))
+	for _, e := range in.LinCombCoef {
+		for _, x := range e {
+			h = mix(h, x)
+		}
+	}
+	for _, x := range in.Const {
+		h = mix(h, x)
+	}
+	return h
+}
+
+func newFragmentFinder(c *irsource.Circuit, rc *irsource.RootCircuit) *fragmentFinder {
+	n := len(c.Instructions)
+	f := &fragmentFinder{
+		rc:        rc,
+		c:         c,
+		shape:     make([]uint64, n),
+		varStart:  make([]int, n+1),
+		badPrefix: make([]int, n+1),
+		nbInsns:   n,
+	}
+	nv := c.NumInputs + 1
+	for i := range c.Instructions {
+		in := &c.Instructions[i]
+		f.shape[i] = instructionShape(in)
+		f.varStart[i] = nv
+		nv += in.OutputCount()
+		f.badPrefix[i+1] = f.badPrefix[i]
+		if in.Type == irsource.ConstantLike && in.ExtraId != 0 {
+			// public inputs and random values stay in the root circuit
+			f.badPrefix[i+1]++
+		}
+	}
+	f.varStart[n] = nv
+	f.nbVars = nv
+	f.defInsn = make([]int, nv)
+	f.maxUse = make([]int, nv)
+	f.usedAtEnd = make([]bool, nv)
+	for v := 0; v <= c.NumInputs; v++ {
+		f.defInsn[v] = -1
+	}
+	for i := range c.Instructions {
+		for v := f.varStart[i]; v < f.varStart[i+1]; v++ {
+			f.defInsn[v] = i
+		}
+		for _, v := range c.Instructions[i].Operands() {
+			f.maxUse[v] = i
+		}
+	}
+	for _, con := range c.Constraints {
+		f.usedAtEnd[con.Var] = true
+	}
+	for _, v := range c.Outputs {
+		f.usedAtEnd[v] = true
+	}
+	return f
+}
+
+// signature is a position independent hash of instruction i, assuming it's in a fragment of length l
+func (f *fragmentFinder) signature(i int, l int) uint64 {
+	h := f.shape[i]
+	for _, v := range f.c.Instructions[i].Operands() {
+		j := f.defInsn[v]
+		if j >= 0 && i-j < l {
+			h = mix(h, uint64(i-j)<<20|uint64(v-f.varStart[j]))
+		} else {
+			h = mix(h, 0xfffff)
+		}
+	}
+	return h
+}
+
+// externalInputs returns operands of the fragment [s, s+l) which are defined outside, in order of first use
+func (f *fragmentFinder) externalInputs(s int, l int) []int {
+	lo, hi := f.varStart[s], f.varStart[s+l]
+	seen := make(map[int]bool)
+	res := []int{}
+	for i := s; i < s+l; i++ {
+		for _, v := range f.c.Instructions[i].Operands() {
+			if (v < lo || v >= hi) && !seen[v] {
+				seen[v] = true
+				res = append(res, v)
+			}
+		}
+	}
+	return res
+}
+
+// sameFragment checks whether [s, s+l) and [t, t+l) compute the same function of their external inputs
+func (f *fragmentFinder) sameFragment(s int, t int, l int) bool {
+	sLo, sHi := f.varStart[s], f.varStart[s+l]
+	tLo, tHi := f.varStart[t], f.varStart[t+l]
+	if sHi-sLo != tHi-tLo {
+		return false
+	}
+	extS := make(map[int]int)
+	extT := make(map[int]int)
+	for k := 0; k < l; k++ {
+		a := &f.c.Instructions[s+k]
+		b := &f.c.Instructions[t+k]
+		if f.shape[s+k] != f.shape[t+k] || !sameInstructionIgnoringOperands(a, b) {
+			return false
+		}
+		oa, ob := a.Operands(), b.Operands()
+		for j := range oa {
+			x, y := oa[j], ob[j]
+			xIn := x >= sLo && x < sHi
+			yIn := y >= tLo && y < tHi
+			if xIn != yIn {
+				return false
+			}
+			if xIn {
+				if x-sLo != y-tLo {
+					return false
+				}
+				continue
+			}
+			ix, okx := extS[x]
+			iy, oky := extT[y]
+			if okx != oky || (okx && ix != iy) {
+				return false
+			}
+			if !okx {
+				extS[x] = len(extS)
+				extT[y] = len(extT)
+			}
+		}
+	}
+	return true
+}
+
+func sameInstructionIgnoringOperands(a, b *irsource.Instruction) bool {
+	if a.Type != b.Type || a.ExtraId != b.ExtraId || a.NumOutputs != b.NumOutputs || a.Const != b.Const {
+		return false
+	}
+	if len(a.Operands()) != len(b.Operands()) || len(a.LinCombCoef) != len(b.LinCombCoef) {
+		return false
+	}
+	for i := range a.LinCombCoef {
+		if a.LinCombCoef[i] != b.LinCombCoef[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// neededOutputs appends to res the local indices of variables of [s, s+l) which are used outside
+func (f *fragmentFinder) neededOutputs(s int, l int, res map[int]bool) {
+	for v := f.varStart[s]; v < f.varStart[s+l]; v++ {
+		if f.maxUse[v] >= s+l || f.usedAtEnd[v] {
+			res[v-f.varStart[s]] = true
+		}
+	}
+}
+
+func (f *fragmentFinder) findBest(minLength int, minRepeats int) *fragmentGroup {
+	var best *fragmentGroup
+	maxLength := f.nbInsns / minRepeats
+	if maxLength > maxFragmentLength {
+		maxLength = maxFragmentLength
+	}
+	for l := maxLength; l >= minLength; {
+		// skip lengths which can't beat the current best even if every window matched
+		if best == nil || (f.nbInsns/l)*(l-1)-l > best.savings {
+			f.findBestWithLength(l, minRepeats, &best)
+		}
+		if next := l * 7 / 8; next < l-1 {
+			l = next
+		} else {
+			l--
+		}
+	}
+	return best
+}
+
+func (f *fragmentFinder) findBestWithLength(l int, minRepeats int, best **fragmentGroup) {
+	n := f.nbInsns
+	if n < l*minRepeats {
+		return
+	}
+	sig := make([]uint64, n)
+	for i := 0; i < n; i++ {
+		sig[i] = f.signature(i, l)
+	}
+	const base = 0x100000001b3
+	pw := uint64(1)
+	for i := 0; i < l; i++ {
+		pw *= base
+	}
+	groups := make(map[uint64][]int)
+	var h uint64
+	for i := 0; i < n; i++ {
+		h = h*base + sig[i]
+		if i >= l {
+			h -= pw * sig[i-l]
+		}
+		s := i - l + 1
+		if s >= 0 && f.badPrefix[s+l] == f.badPrefix[s] {
+			groups[h] = append(groups[h], s)
+		}
+	}
+	keys := make([]uint64, 0, len(groups))
+	for k, starts := range groups {
+		if len(starts) >= minRepeats {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return groups[keys[i]][0] < groups[keys[j]][0] })
+	for _, k := range keys {
+		starts := groups[k]
+		if *best != nil && len(starts)*(l-1)-l <= (*best).savings {
+			continue
+		}
+		// split the candidates into classes of equal fragments, keeping non-overlapping ones
+		used := make([]bool, len(starts))
+		for i := range starts {
+			if used[i] {
+				continue
+			}
+			class := []int{starts[i]}
+			used[i] = true
+			last := starts[i]
+			for j := i + 1; j < len(starts); j++ {
+				if used[j] || starts[j] < last+l {
+					continue
+				}
+				if f.sameFragment(starts[i], starts[j], l) {
+					used[j] = true
+					class = append(class, starts[j])
+					last = starts[j]
+				}
+			}
+			savings := len(class)*(l-1) - l
+			if len(class) < minRepeats || (*best != nil && savings <= (*best).savings) || savings <= 0 {
+				continue
+			}
+			needed := make(map[int]bool)
+			for _, s := range class {
+				f.neededOutputs(s, l, needed)
+			}
+			if len(needed) == 0 || len(f.externalInputs(class[0], l)) == 0 {
+				continue
+			}
+			outputs := make([]int, 0, len(needed))
+			for x := range needed {
+				outputs = append(outputs, x)
+			}
+			sort.Ints(outputs)
+			*best = &fragmentGroup{length: l, starts: class, outputs: outputs, savings: savings}
+		}
+	}
+}
+
+// extract replaces each fragment of the group with a call to a new subcircuit
+func (f *fragmentFinder) extract(g *fragmentGroup) {
+	c := f.c
+	l := g.length
+	s0 := g.starts[0]
+
+	// build the subcircuit from the first fragment
+	ext := f.externalInputs(s0, l)
+	subVar := make(map[int]int)
+	for i, v := range ext {
+		subVar[v] = i + 1
+	}
+	lo := f.varStart[s0]
+	for v := lo; v < f.varStart[s0+l]; v++ {
+		subVar[v] = len(ext) + 1 + v - lo
+	}
+	sub := &irsource.Circuit{NumInputs: len(ext)}
+	var h uint64
+	for i := s0; i < s0+l; i++ {
+		sub.Instructions = append(sub.Instructions, c.Instructions[i].MapOperands(func(x int) int { return subVar[x] }))
+		h = mix(h, f.shape[i])
+	}
+	for _, x := range g.outputs {
+		sub.Outputs = append(sub.Outputs, len(ext)+1+x)
+	}
+	subId := h | 1
+	for {
+		if _, ok := f.rc.Circuits[subId]; !ok {
+			break
+		}
+		subId++
+	}
+	f.rc.Circuits[subId] = sub
+
+	// rewrite the root circuit
+	newVar := make([]int, f.nbVars)
+	for v := 0; v <= c.NumInputs; v++ {
+		newVar[v] = v
+	}
+	mapVar := func(x int) int { return newVar[x] }
+	next := c.NumInputs + 1
+	insns := make([]irsource.Instruction, 0, len(c.Instructions))
+	k := 0
+	for i := 0; i < len(c.Instructions); {
+		if k < len(g.starts) && g.starts[k] == i {
+			inputs := f.externalInputs(i, l)
+			for j := range inputs {
+				inputs[j] = newVar[inputs[j]]
+			}
+			insns = append(insns, irsource.Instruction{
+				Type:       irsource.SubCircuitCall,
+				ExtraId:    subId,
+				Inputs:     inputs,
+				NumOutputs: len(g.outputs),
+			})
+			for _, x := range g.outputs {
+				newVar[f.varStart[i]+x] = next
+				next++
+			}
+			i += l
+			k++
+			continue
+		}
+		insns = append(insns, c.Instructions[i].MapOperands(mapVar))
+		for v := f.varStart[i]; v < f.varStart[i+1]; v++ {
+			newVar[v] = next
+			next++
+		}
+		i++
+	}
+	c.Instructions = insns
+	for i := range c.Constraints {
+		c.Constraints[i].Var = newVar[c.Constraints[i].Var]
+	}
+	for i := range c.Outputs {
+		c.Outputs[i] = newVar[c.Outputs[i]]
+	}
+}
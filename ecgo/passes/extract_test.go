@@ -0,0 +1,62 @@
+package passes
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func repeatedRoundsCircuit(nbRounds int) *irsource.RootCircuit {
+	root := builder.NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	y := root.SecretVariable(schema.LeafInfo{})
+	for i := 0; i < nbRounds; i++ {
+		t := root.Mul(x, x, x)
+		t = root.Add(root.Mul(t, y), x, 7)
+		y = root.Mul(x, 3)
+		x = t
+	}
+	root.AssertIsEqual(x, y)
+	return root.Finalize()
+}
+
+func TestExtractRepeatedFragments(t *testing.T) {
+	rc := repeatedRoundsCircuit(20)
+	before := len(rc.Circuits[0].Instructions)
+	inputs := []constraint.Element{rc.Field.FromInterface(3), rc.Field.FromInterface(5)}
+	expected := evalCircuit(t, rc, 0, inputs)
+
+	n := ExtractRepeatedFragments(rc, 3, 4)
+	if n == 0 || len(rc.Circuits) <= 1 {
+		t.Fatal("expected repeated rounds to be extracted")
+	}
+	if after := len(rc.Circuits[0].Instructions); after*2 > before {
+		t.Fatalf("root circuit has %d instructions, %d before extraction", after, before)
+	}
+	for id, c := range rc.Circuits {
+		if id != 0 && c.NumVariables() <= c.NumInputs {
+			t.Fatalf("subcircuit %d has no instructions", id)
+		}
+	}
+	got := evalCircuit(t, rc, 0, inputs)
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d values, got %d", len(expected), len(got))
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Fatalf("value %d differs after extraction", i)
+		}
+	}
+}
+
+func TestExtractRepeatedFragmentsNoRepeats(t *testing.T) {
+	rc := repeatedRoundsCircuit(2)
+	if n := ExtractRepeatedFragments(rc, 3, 4); n != 0 || len(rc.Circuits) != 1 {
+		t.Fatalf("expected nothing to be extracted, got %d subcircuits", n)
+	}
+}
@@ -0,0 +1,157 @@
+package passes
+
+import (
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint"
+)
+
+// FoldConstants evaluates the instructions of every circuit whose operands are known constants,
+// and propagates the results to the following instructions. Multiplications and divisions by a
+// constant are turned into linear combinations, and constraints on constants which are satisfied
+// are removed. Constants are not propagated into subcircuits.
+// It returns the number of folded instructions and constraints.
+func FoldConstants(rc *irsource.RootCircuit, opts ...Option) int {
+	return newConfig(opts).forEachCircuit(rc, func(id uint64, c *irsource.Circuit) int {
+		return foldConstants(c, rc.Field, id == 0)
+	})
+}
+
+func foldConstants(c *irsource.Circuit, f field.Field, isRoot bool) int {
+	res := 0
+	constVal := make(map[int]constraint.Element)
+	nextVar := c.NumInputs + 1
+	rewriteCircuit(c, func(in *irsource.Instruction) (*irsource.Instruction, []int) {
+		out, alias := foldInstruction(in, f, constVal)
+		if alias != 0 {
+			res++
+			return nil, []int{alias}
+		}
+		if out != in {
+			out.Loc = in.Loc
+			res++
+		}
+		if out.Type == irsource.ConstantLike && out.ExtraId == 0 {
+			constVal[nextVar] = out.Const
+		}
+		nextVar += out.OutputCount()
+		return out, nil
+	})
+
+	constraints := c.Constraints[:0]
+	for _, con := range c.Constraints {
+		if x, ok := constVal[con.Var]; ok && constraintHolds(con.Typ, x, f) {
+			res++
+			continue
+		}
+		constraints = append(constraints, con)
+	}
+	if isRoot && len(constraints) == 0 && len(c.Outputs) == 0 && len(c.Constraints) > 0 {
+		// the root circuit must keep at least one constraint or output
+		constraints = append(constraints, c.Constraints[0])
+		res--
+	}
+	c.Constraints = constraints
+	return res
+}
+
+func constant(x constraint.Element) *irsource.Instruction {
+	return &irsource.Instruction{Type: irsource.ConstantLike, Const: x}
+}
+
+func constraintHolds(typ irsource.ConstraintType, x constraint.Element, f field.Field) bool {
+	switch typ {
+	case irsource.Zero:
+		return x.IsZero()
+	case irsource.NonZero:
+		return !x.IsZero()
+	case irsource.Bool:
+		return x.IsZero() || x == f.One()
+	}
+	return false
+}
+
+// foldInstruction returns the simplified instruction, or in itself if nothing can be folded.
+// If the result is an existing variable, it's returned as alias.
+func foldInstruction(in *irsource.Instruction, f field.Field, constVal map[int]constraint.Element) (*irsource.Instruction, int) {
+	isBool := func(x constraint.Element) bool { return x.IsZero() || x == f.One() }
+	switch in.Type {
+	case irsource.LinComb:
+		sum := in.Const
+		inputs := []int{}
+		coefs := []constraint.Element{}
+		for i, x := range in.Inputs {
+			if v, ok := constVal[x]; ok {
+				sum = f.Add(sum, f.Mul(v, in.LinCombCoef[i]))
+			} else {
+				inputs = append(inputs, x)
+				coefs = append(coefs, in.LinCombCoef[i])
+			}
+		}
+		if len(inputs) == 0 {
+			return constant(sum), 0
+		}
+		if len(inputs) == len(in.Inputs) {
+			return in, 0
+		}
+		return &irsource.Instruction{Type: irsource.LinComb, Inputs: inputs, LinCombCoef: coefs, Const: sum}, 0
+	case irsource.Mul:
+		prod := f.One()
+		inputs := []int{}
+		for _, x := range in.Inputs {
+			if v, ok := constVal[x]; ok {
+				prod = f.Mul(prod, v)
+			} else {
+				inputs = append(inputs, x)
+			}
+		}
+		switch {
+		case prod.IsZero() || len(inputs) == 0:
+			return constant(prod), 0
+		case len(inputs) == 1 && prod == f.One():
+			return nil, inputs[0]
+		case len(inputs) == 1:
+			return &irsource.Instruction{Type: irsource.LinComb, Inputs: inputs, LinCombCoef: []constraint.Element{prod}}, 0
+		}
+		return in, 0
+	case irsource.Div:
+		y, ok := constVal[in.Y]
+		if !ok || y.IsZero() {
+			return in, 0
+		}
+		inv, _ := f.Inverse(y)
+		if x, ok := constVal[in.X]; ok {
+			return constant(f.Mul(x, inv)), 0
+		}
+		return &irsource.Instruction{Type: irsource.LinComb, Inputs: []int{in.X}, LinCombCoef: []constraint.Element{inv}}, 0
+	case irsource.IsZero:
+		if x, ok := constVal[in.X]; ok {
+			if x.IsZero() {
+				return constant(f.One()), 0
+			}
+			return constant(f.Zero()), 0
+		}
+	case irsource.BoolBinOp:
+		x, okx := constVal[in.X]
+		y, oky := constVal[in.Y]
+		if !okx || !oky || !isBool(x) || !isBool(y) {
+			return in, 0
+		}
+		var r bool
+		switch in.ExtraId {
+		case 1:
+			r = x != y
+		case 2:
+			r = !x.IsZero() || !y.IsZero()
+		case 3:
+			r = !x.IsZero() && !y.IsZero()
+		default:
+			return in, 0
+		}
+		if r {
+			return constant(f.One()), 0
+		}
+		return constant(f.Zero()), 0
+	}
+	return in, 0
+}
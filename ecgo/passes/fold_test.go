@@ -0,0 +1,47 @@
+package passes
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint"
+)
+
+func TestFoldConstants(t *testing.T) {
+	f := &m31.Field{}
+	c := &irsource.Circuit{
+		NumInputs: 1,
+		Instructions: []irsource.Instruction{
+			{Type: irsource.ConstantLike, Const: f.FromInterface(3)},                                                              // 2 = 3
+			{Type: irsource.ConstantLike, Const: f.FromInterface(4)},                                                              // 3 = 4
+			{Type: irsource.Mul, Inputs: []int{2, 3}},                                                                             // 4 = 12
+			{Type: irsource.IsZero, X: 4},                                                                                         // 5 = 0
+			{Type: irsource.LinComb, Inputs: []int{1, 4}, LinCombCoef: []constraint.Element{f.One(), f.One()}},                    // 6 = x + 12
+			{Type: irsource.Mul, Inputs: []int{6, 2}},                                                                             // 7 = 3x + 36
+			{Type: irsource.Div, X: 7, Y: 3, ExtraId: 0},                                                                          // 8 = (3x + 36) / 4
+			{Type: irsource.LinComb, Inputs: []int{4, 5}, LinCombCoef: []constraint.Element{f.One(), f.Neg(f.FromInterface(12))}}, // 9 = 12
+		},
+		Constraints: []irsource.Constraint{{Typ: irsource.Zero, Var: 5}, {Typ: irsource.Zero, Var: 8}},
+		Outputs:     []int{9},
+	}
+	rc := &irsource.RootCircuit{Circuits: map[uint64]*irsource.Circuit{0: c}, Field: f}
+	inputs := []constraint.Element{f.FromInterface(8)}
+	expected := evalCircuit(t, rc, 0, inputs)
+
+	if n := FoldConstants(rc); n == 0 {
+		t.Fatal("expected constants to be folded")
+	}
+	for _, in := range c.Instructions {
+		if in.Type == irsource.Mul || in.Type == irsource.Div || in.Type == irsource.IsZero {
+			t.Fatalf("instruction of type %d should have been folded", in.Type)
+		}
+	}
+	if len(c.Constraints) != 1 {
+		t.Fatalf("expected the satisfied constraint to be removed, got %v", c.Constraints)
+	}
+	got := evalCircuit(t, rc, 0, inputs)
+	if got[0] != expected[0] || got[1] != expected[2] {
+		t.Fatal("values differ after folding")
+	}
+}
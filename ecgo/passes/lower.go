@@ -0,0 +1,104 @@
+package passes
+
+import (
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint"
+)
+
+// LowerDivisions replaces the divisions and IsZero instructions whose operand isn't a constant by
+// the builtin division hint, irsource.DivHintId, and the multiplications checking its result,
+// which the layered compiler would otherwise generate itself. Lowering them in the source IR
+// lets the other passes see their gates: the inverse of a denominator is computed by a single
+// hint, in the first layer, whatever the number of divisions and IsZero on it once the common
+// subexpressions are eliminated. It returns the number of lowered instructions.
+//
+// The hint returns 0 for a zero divisor, and the constraints decide the semantics:
+//   - a checked Div x / y computes inv = 1 / y, asserts y * inv = 1 and returns x * inv, so a
+//     zero divisor makes the witness unsatisfiable, even when x is zero;
+//   - an unchecked Div computes q = x / y and asserts y * q = x, so any division of a nonzero
+//     value by zero is unsatisfiable, and the quotient of 0 / 0 is unconstrained, as in gnark's
+//     DivUnchecked: the hint gives 0, but a prover may choose any value;
+//   - IsZero x computes inv = 1 / x, m = 1 - x * inv and asserts x * m = 0, so m is 1 if x is zero
+//     and 0 otherwise.
+func LowerDivisions(rc *irsource.RootCircuit, opts ...Option) int {
+	return newConfig(opts).forEachCircuit(rc, func(_ uint64, c *irsource.Circuit) int {
+		return lowerDivisions(c, rc.Field)
+	})
+}
+
+func lowerDivisions(c *irsource.Circuit, f field.Field) int {
+	res := 0
+	constant := make(map[int]bool)
+	var constraints []irsource.Constraint
+	next := c.NumInputs + 1
+	expandCircuit(c, func(in *irsource.Instruction) ([]irsource.Instruction, []int) {
+		e := &emitter{f: f, next: next, loc: in.Loc}
+		switch {
+		case in.Type == irsource.Div && !constant[in.Y] && in.ExtraId == 0:
+			one := e.constant(f.One())
+			inv := e.hint(one, in.Y)
+			e.assertZero(e.linComb([]int{e.mul(in.Y, inv)}, []constraint.Element{f.One()}, f.Neg(f.One())))
+			e.mul(in.X, inv)
+		case in.Type == irsource.Div && !constant[in.Y]:
+			q := e.hint(in.X, in.Y)
+			e.assertZero(e.linComb([]int{e.mul(in.Y, q), in.X}, []constraint.Element{f.One(), f.Neg(f.One())}, f.Zero()))
+			e.linComb([]int{q}, []constraint.Element{f.One()}, f.Zero())
+		case in.Type == irsource.IsZero && !constant[in.X]:
+			one := e.constant(f.One())
+			inv := e.hint(one, in.X)
+			m := e.linComb([]int{e.mul(in.X, inv)}, []constraint.Element{f.Neg(f.One())}, f.One())
+			e.assertZero(e.mul(in.X, m))
+			e.linComb([]int{m}, []constraint.Element{f.One()}, f.Zero())
+		default:
+			if in.Type == irsource.ConstantLike && in.ExtraId == 0 {
+				constant[next] = true
+			}
+			next += in.OutputCount()
+			return []irsource.Instruction{*in}, nil
+		}
+		res++
+		constraints = append(constraints, e.constraints...)
+		next = e.next
+		return e.insns, nil
+	})
+	// the constraints refer to the new variables, they're added after the renumbering
+	c.Constraints = append(c.Constraints, constraints...)
+	return res
+}
+
+// emitter appends the instructions lowering an instruction, whose first output is the variable next
+type emitter struct {
+	f           field.Field
+	insns       []irsource.Instruction
+	constraints []irsource.Constraint
+	next        int
+	loc         uint32
+}
+
+func (e *emitter) emit(in irsource.Instruction) int {
+	in.Loc = e.loc
+	e.insns = append(e.insns, in)
+	e.next++
+	return e.next - 1
+}
+
+func (e *emitter) constant(v constraint.Element) int {
+	return e.emit(irsource.Instruction{Type: irsource.ConstantLike, Const: v})
+}
+
+func (e *emitter) hint(x, y int) int {
+	return e.emit(irsource.Instruction{Type: irsource.Hint, ExtraId: irsource.DivHintId, Inputs: []int{x, y}, NumOutputs: 1})
+}
+
+func (e *emitter) mul(x, y int) int {
+	return e.emit(irsource.Instruction{Type: irsource.Mul, Inputs: []int{x, y}})
+}
+
+func (e *emitter) linComb(inputs []int, coefs []constraint.Element, cst constraint.Element) int {
+	return e.emit(irsource.Instruction{Type: irsource.LinComb, Inputs: inputs, LinCombCoef: coefs, Const: cst})
+}
+
+func (e *emitter) assertZero(x int) {
+	e.constraints = append(e.constraints, irsource.Constraint{Typ: irsource.Zero, Var: x, Loc: e.loc})
+}
@@ -0,0 +1,113 @@
+package passes
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint"
+)
+
+func newDivisionCircuit() *irsource.RootCircuit {
+	f := &m31.Field{}
+	c := &irsource.Circuit{
+		NumInputs: 2,
+		Instructions: []irsource.Instruction{
+			{Type: irsource.Div, X: 1, Y: 2},                         // 3 = x / y
+			{Type: irsource.Div, X: 1, Y: 2, ExtraId: 1},             // 4 = x / y unchecked
+			{Type: irsource.IsZero, X: 2},                            // 5 = y == 0
+			{Type: irsource.ConstantLike, Const: f.FromInterface(5)}, // 6 = 5
+			{Type: irsource.Div, X: 1, Y: 6},                         // 7 = x / 5
+		},
+		Outputs: []int{3, 4, 5, 7},
+	}
+	return &irsource.RootCircuit{Circuits: map[uint64]*irsource.Circuit{0: c}, Field: f}
+}
+
+// satisfied evaluates the circuit and returns its outputs, and whether its constraints hold
+func satisfied(t *testing.T, rc *irsource.RootCircuit, inputs ...int) ([]constraint.Element, bool) {
+	f := rc.Field
+	in := []constraint.Element{}
+	for _, x := range inputs {
+		in = append(in, f.FromInterface(x))
+	}
+	values := evalCircuit(t, rc, 0, in)
+	n := len(rc.Circuits[0].Outputs)
+	for _, x := range values[n:] {
+		if !x.IsZero() {
+			return values[:n], false
+		}
+	}
+	return values[:n], true
+}
+
+func TestLowerDivisions(t *testing.T) {
+	rc := newDivisionCircuit()
+	if n := LowerDivisions(rc); n != 3 {
+		t.Fatalf("expected 3 lowered instructions, got %d", n)
+	}
+	divs := 0
+	for _, in := range rc.Circuits[0].Instructions {
+		if in.Type == irsource.IsZero {
+			t.Fatal("IsZero should have been lowered")
+		}
+		if in.Type == irsource.Div {
+			divs++
+		}
+	}
+	// the division by a constant is left to FoldConstants
+	if divs != 1 {
+		t.Fatalf("expected a single division left, got %d", divs)
+	}
+
+	f := rc.Field
+	expected, _ := satisfied(t, newDivisionCircuit(), 12, 4)
+	got, ok := satisfied(t, rc, 12, 4)
+	if !ok {
+		t.Fatal("expected the constraints to hold")
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("output %d differs after lowering", i)
+		}
+	}
+	if got[2] != f.Zero() {
+		t.Fatal("expected IsZero of a nonzero value to be 0")
+	}
+
+	// the checked division rejects any zero divisor, the unchecked one accepts 0 / 0
+	if _, ok := satisfied(t, rc, 12, 0); ok {
+		t.Fatal("expected a division by zero to be unsatisfiable")
+	}
+	unchecked := newDivisionCircuit()
+	c := unchecked.Circuits[0]
+	c.Instructions = c.Instructions[1:3]
+	c.Outputs = []int{3, 4}
+	LowerDivisions(unchecked)
+	if got, ok := satisfied(t, unchecked, 0, 0); !ok || got[0] != f.Zero() || got[1] != f.One() {
+		t.Fatalf("expected the hint to give 0 / 0 = 0 and IsZero(0) to be 1, got %v, satisfied %v", got, ok)
+	}
+	if _, ok := satisfied(t, unchecked, 1, 0); ok {
+		t.Fatal("expected an unchecked division of a nonzero value by zero to be unsatisfiable")
+	}
+}
+
+func TestLowerDivisionsShareInverse(t *testing.T) {
+	rc := newDivisionCircuit()
+	LowerDivisions(rc)
+	EliminateCommonSubexpressions(rc)
+	EliminateDeadCode(rc)
+	hints := 0
+	for _, in := range rc.Circuits[0].Instructions {
+		if in.Type == irsource.Hint {
+			hints++
+		}
+	}
+	// 1 / y is shared by the checked division and IsZero
+	if hints != 2 {
+		t.Fatalf("expected 2 hints, got %d", hints)
+	}
+	if got, ok := satisfied(t, rc, 12, 4); !ok || got[0] != rc.Field.FromInterface(3) {
+		t.Fatalf("unexpected outputs %v, satisfied %v", got, ok)
+	}
+}
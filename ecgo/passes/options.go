@@ -0,0 +1,67 @@
+package passes
+
+import (
+	"sync"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+)
+
+type config struct {
+	workers int
+}
+
+// Option configures how a pass is run.
+type Option func(*config)
+
+// WithWorkers sets the number of goroutines processing independent circuits concurrently.
+// The default is 1.
+func WithWorkers(n int) Option {
+	return func(c *config) {
+		c.workers = n
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{workers: 1}
+	for _, o := range opts {
+		o(c)
+	}
+	if c.workers < 1 {
+		c.workers = 1
+	}
+	return c
+}
+
+// forEachCircuit calls f on every circuit of rc, and returns the sum of the results.
+// f must only modify the circuit it's given.
+func (cfg *config) forEachCircuit(rc *irsource.RootCircuit, f func(id uint64, c *irsource.Circuit) int) int {
+	ids := rc.CircuitIds()
+	results := make([]int, len(ids))
+	if cfg.workers == 1 || len(ids) == 1 {
+		for i, id := range ids {
+			results[i] = f(id, rc.Circuits[id])
+		}
+	} else {
+		var wg sync.WaitGroup
+		jobs := make(chan int)
+		for w := 0; w < cfg.workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					results[i] = f(ids[i], rc.Circuits[ids[i]])
+				}
+			}()
+		}
+		for i := range ids {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+	}
+	res := 0
+	for _, r := range results {
+		res += r
+	}
+	return res
+}
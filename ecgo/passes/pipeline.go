@@ -0,0 +1,151 @@
+package passes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+)
+
+// Pass is a transformation of the source IR, run by a Pipeline. Run returns the number of
+// changes made to the circuit, e.g. the number of removed instructions, which is logged. A pass
+// must keep the circuit equivalent, and may leave dead instructions for EliminateDeadCode.
+type Pass interface {
+	Name() string
+	Run(rc *irsource.RootCircuit, opts ...Option) int
+}
+
+type funcPass struct {
+	name string
+	run  func(rc *irsource.RootCircuit, opts ...Option) int
+}
+
+func (p *funcPass) Name() string {
+	return p.name
+}
+
+func (p *funcPass) Run(rc *irsource.RootCircuit, opts ...Option) int {
+	return p.run(rc, opts...)
+}
+
+// NewPass returns a pass with the given name running f.
+func NewPass(name string, f func(rc *irsource.RootCircuit, opts ...Option) int) Pass {
+	return &funcPass{name: name, run: f}
+}
+
+// The passes of this package, registered under their names
+var (
+	Fold          = NewPass("fold", FoldConstants)
+	Reassociation = NewPass("reassociate", Reassociate)
+	CSE           = NewPass("cse", EliminateCommonSubexpressions)
+	DCE           = NewPass("dce", EliminateDeadCode)
+	DivLowering   = NewPass("lower-div", LowerDivisions)
+)
+
+// Extraction returns the pass running ExtractRepeatedFragments, named "extract". It isn't
+// registered, since it depends on its parameters.
+func Extraction(minLength int, minRepeats int) Pass {
+	return NewPass("extract", func(rc *irsource.RootCircuit, _ ...Option) int {
+		return ExtractRepeatedFragments(rc, minLength, minRepeats)
+	})
+}
+
+// Pipeline is a sequence of passes, run in order by ecgo.Compile, see ecgo.WithPipeline.
+type Pipeline []Pass
+
+// Names returns the names of the passes.
+func (p Pipeline) Names() []string {
+	res := make([]string, len(p))
+	for i, pass := range p {
+		res[i] = pass.Name()
+	}
+	return res
+}
+
+// Without returns the pipeline without the passes with the given name.
+func (p Pipeline) Without(name string) Pipeline {
+	res := Pipeline{}
+	for _, pass := range p {
+		if pass.Name() != name {
+			res = append(res, pass)
+		}
+	}
+	return res
+}
+
+// MaxLevel is the highest optimization level, see Level.
+const MaxLevel = 2
+
+// Level returns the pipeline of an optimization level, like the -O flags of a C compiler:
+//   - 0 runs no pass;
+//   - 1 folds constants, lowers the divisions, then eliminates common subexpressions and dead
+//     code, the default of ecgo.Compile;
+//   - 2 also reassociates the chains of additions and multiplications after folding them, which
+//     reduces the depth of the layered circuit.
+//
+// Levels above MaxLevel are the same as MaxLevel.
+func Level(level int) Pipeline {
+	switch {
+	case level <= 0:
+		return Pipeline{}
+	case level == 1:
+		return Pipeline{Fold, DivLowering, CSE, DCE}
+	default:
+		return Pipeline{Fold, Reassociation, DivLowering, CSE, DCE}
+	}
+}
+
+var (
+	registered  = map[string]Pass{Fold.Name(): Fold, Reassociation.Name(): Reassociation, CSE.Name(): CSE, DCE.Name(): DCE, DivLowering.Name(): DivLowering}
+	registeredM sync.RWMutex
+)
+
+// Register adds a custom pass, so that it can be named in a pipeline by ParsePipeline, e.g. from
+// the init function of a Go plugin of the ecc command. It panics if the name is already taken.
+func Register(p Pass) {
+	registeredM.Lock()
+	defer registeredM.Unlock()
+	if _, ok := registered[p.Name()]; ok {
+		panic(fmt.Sprintf("pass %q is already registered", p.Name()))
+	}
+	registered[p.Name()] = p
+}
+
+// Get returns the pass registered with the given name.
+func Get(name string) (Pass, bool) {
+	registeredM.RLock()
+	defer registeredM.RUnlock()
+	p, ok := registered[name]
+	return p, ok
+}
+
+// Names returns the names of the registered passes in increasing order.
+func Names() []string {
+	registeredM.RLock()
+	defer registeredM.RUnlock()
+	res := make([]string, 0, len(registered))
+	for name := range registered {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// ParsePipeline returns the pipeline of the registered passes named in s, separated by commas,
+// e.g. "fold,cse,dce". An empty s is the empty pipeline.
+func ParsePipeline(s string) (Pipeline, error) {
+	res := Pipeline{}
+	if s == "" {
+		return res, nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		p, ok := Get(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown pass %q, registered passes: %s", name, strings.Join(Names(), ", "))
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
@@ -0,0 +1,42 @@
+package passes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+)
+
+func TestPipeline(t *testing.T) {
+	if got := strings.Join(Level(2).Names(), ","); got != "fold,reassociate,lower-div,cse,dce" {
+		t.Fatalf("unexpected level 2 %s", got)
+	}
+	if len(Level(0)) != 0 || len(Level(MaxLevel+1)) != len(Level(MaxLevel)) {
+		t.Fatal("unexpected levels")
+	}
+	if got := strings.Join(Level(1).Without("cse").Names(), ","); got != "fold,lower-div,dce" {
+		t.Fatalf("unexpected pipeline %s", got)
+	}
+
+	calls := 0
+	Register(NewPass("test_count", func(rc *irsource.RootCircuit, _ ...Option) int {
+		calls++
+		return len(rc.Circuits)
+	}))
+	p, err := ParsePipeline("dce, test_count")
+	if err != nil || len(p) != 2 || p[1].Name() != "test_count" {
+		t.Fatalf("unexpected pipeline %v, %v", p, err)
+	}
+	if n := p[1].Run(&irsource.RootCircuit{Circuits: map[uint64]*irsource.Circuit{0: {}}}); n != 1 || calls != 1 {
+		t.Fatalf("unexpected run %d with %d calls", n, calls)
+	}
+	if _, err := ParsePipeline("fold,nope"); err == nil || !strings.Contains(err.Error(), "test_count") {
+		t.Fatalf("expected an unknown pass listing the registered ones, got %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a duplicate pass to panic")
+		}
+	}()
+	Register(NewPass("fold", FoldConstants))
+}
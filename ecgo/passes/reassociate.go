@@ -0,0 +1,239 @@
+package passes
+
+import (
+	"container/heap"
+	"math/bits"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint"
+)
+
+// Reassociate reduces the depth of every circuit. Linear combinations whose result is only read
+// by another linear combination are merged into it, so that a chain of additions becomes a
+// single wide linear combination. Likewise, products only read by another product are merged
+// into it, and products of more than two factors are rebuilt as balanced trees of binary
+// multiplications, pairing the shallowest factors first. The merged instructions are left in
+// place, for EliminateDeadCode to remove. It returns the number of merged instructions.
+func Reassociate(rc *irsource.RootCircuit, opts ...Option) int {
+	return newConfig(opts).forEachCircuit(rc, func(_ uint64, c *irsource.Circuit) int {
+		return reassociate(c, rc.Field)
+	})
+}
+
+// linearTerms is a linear combination being merged
+type linearTerms struct {
+	inputs []int
+	coefs  []constraint.Element
+	cst    constraint.Element
+}
+
+func reassociate(c *irsource.Circuit, f field.Field) int {
+	// uses[v] is the number of reads of v, and userType[v] the type of the last instruction
+	// reading it; constraints and outputs count as two reads, so that their variables are kept
+	n := c.NumVariables()
+	uses := make([]int, n+1)
+	userType := make([]irsource.InstructionType, n+1)
+	for i := range c.Instructions {
+		for _, x := range c.Instructions[i].Operands() {
+			uses[x]++
+			userType[x] = c.Instructions[i].Type
+		}
+	}
+	for _, con := range c.Constraints {
+		uses[con.Var] += 2
+	}
+	for _, x := range c.Outputs {
+		uses[x] += 2
+	}
+	absorbed := func(v int, typ irsource.InstructionType) bool {
+		return uses[v] == 1 && userType[v] == typ
+	}
+
+	res := 0
+	// sums and products are the merged forms of the instructions absorbed by their reader, by
+	// renumbered variable
+	sums := make(map[int]*linearTerms)
+	products := make(map[int][]int)
+	depth := make([]int, c.NumInputs+1)
+	oldVar := c.NumInputs + 1
+	expandCircuit(c, func(in *irsource.Instruction) ([]irsource.Instruction, []int) {
+		nextVar := len(depth)
+		var out []irsource.Instruction
+		switch in.Type {
+		case irsource.LinComb:
+			t, fused := mergeSums(in, sums, f)
+			res += fused
+			if absorbed(oldVar, irsource.LinComb) {
+				sums[nextVar] = t
+			}
+			if fused != 0 && !absorbed(oldVar, irsource.LinComb) {
+				out = []irsource.Instruction{{Type: irsource.LinComb, Inputs: t.inputs, LinCombCoef: t.coefs, Const: t.cst, Loc: in.Loc}}
+			}
+		case irsource.Mul:
+			factors, fused := mergeProducts(in, products)
+			res += fused
+			if absorbed(oldVar, irsource.Mul) {
+				products[nextVar] = factors
+			} else if fused != 0 || len(factors) > 2 {
+				out = balancedProduct(factors, depth, nextVar, in.Loc)
+			}
+		}
+		if out == nil {
+			out = []irsource.Instruction{*in}
+		}
+		for i := range out {
+			d := instructionDepth(&out[i], depth)
+			for j := 0; j < out[i].OutputCount(); j++ {
+				depth = append(depth, d)
+			}
+		}
+		oldVar += in.OutputCount()
+		return out, nil
+	})
+	return res
+}
+
+// mergeSums returns the terms of the linear combination in with the absorbed sums of its inputs
+// expanded, and the number of absorbed sums. The terms of the largest one are reused, so that
+// chains are merged in linear time.
+func mergeSums(in *irsource.Instruction, sums map[int]*linearTerms, f field.Field) (*linearTerms, int) {
+	base := -1
+	for i, x := range in.Inputs {
+		if s, ok := sums[x]; ok && (base < 0 || len(s.inputs) > len(sums[in.Inputs[base]].inputs)) {
+			base = i
+		}
+	}
+	if base < 0 {
+		// copied, since the terms may be extended or scaled when they are absorbed
+		return &linearTerms{
+			inputs: append([]int(nil), in.Inputs...),
+			coefs:  append([]constraint.Element(nil), in.LinCombCoef...),
+			cst:    in.Const,
+		}, 0
+	}
+	t := sums[in.Inputs[base]]
+	if coef := in.LinCombCoef[base]; coef != f.One() {
+		for i := range t.coefs {
+			t.coefs[i] = f.Mul(t.coefs[i], coef)
+		}
+		t.cst = f.Mul(t.cst, coef)
+	}
+	t.cst = f.Add(t.cst, in.Const)
+	delete(sums, in.Inputs[base])
+	fused := 1
+	for i, x := range in.Inputs {
+		if i == base {
+			continue
+		}
+		s, ok := sums[x]
+		if !ok {
+			t.inputs = append(t.inputs, x)
+			t.coefs = append(t.coefs, in.LinCombCoef[i])
+			continue
+		}
+		coef := in.LinCombCoef[i]
+		for j, y := range s.inputs {
+			t.inputs = append(t.inputs, y)
+			t.coefs = append(t.coefs, f.Mul(s.coefs[j], coef))
+		}
+		t.cst = f.Add(t.cst, f.Mul(s.cst, coef))
+		delete(sums, x)
+		fused++
+	}
+	return t, fused
+}
+
+// mergeProducts returns the factors of the product in with the absorbed products of its inputs
+// expanded, and the number of absorbed products, like mergeSums.
+func mergeProducts(in *irsource.Instruction, products map[int][]int) ([]int, int) {
+	base := -1
+	for i, x := range in.Inputs {
+		if p, ok := products[x]; ok && (base < 0 || len(p) > len(products[in.Inputs[base]])) {
+			base = i
+		}
+	}
+	if base < 0 {
+		return append([]int(nil), in.Inputs...), 0
+	}
+	factors := products[in.Inputs[base]]
+	delete(products, in.Inputs[base])
+	fused := 1
+	for i, x := range in.Inputs {
+		if i == base {
+			continue
+		}
+		if p, ok := products[x]; ok {
+			factors = append(factors, p...)
+			delete(products, x)
+			fused++
+		} else {
+			factors = append(factors, x)
+		}
+	}
+	return factors, fused
+}
+
+// instructionDepth estimates the number of layers between the inputs and the result of in, from
+// the depths of its operands. Only multiplications need a new layer; hints and constants are
+// inputs of the layered circuit, and other instructions are assumed to take one layer.
+func instructionDepth(in *irsource.Instruction, depth []int) int {
+	d := 0
+	for _, x := range in.Operands() {
+		if depth[x] > d {
+			d = depth[x]
+		}
+	}
+	switch in.Type {
+	case irsource.LinComb:
+		return d
+	case irsource.Mul:
+		return d + bits.Len(uint(len(in.Inputs)-1))
+	case irsource.Hint, irsource.ConstantLike:
+		return 0
+	}
+	return d + 1
+}
+
+type factor struct {
+	depth, seq, v int
+}
+
+type factorHeap []factor
+
+func (h factorHeap) Len() int { return len(h) }
+func (h factorHeap) Less(i, j int) bool {
+	if h[i].depth != h[j].depth {
+		return h[i].depth < h[j].depth
+	}
+	return h[i].seq < h[j].seq
+}
+func (h factorHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *factorHeap) Push(x interface{}) { *h = append(*h, x.(factor)) }
+func (h *factorHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// balancedProduct returns the binary multiplications computing the product of the factors, the
+// first one defining variable next, by multiplying the two shallowest factors first
+func balancedProduct(factors []int, depth []int, next int, loc uint32) []irsource.Instruction {
+	h := make(factorHeap, len(factors))
+	for i, x := range factors {
+		h[i] = factor{depth: depth[x], seq: i, v: x}
+	}
+	heap.Init(&h)
+	res := make([]irsource.Instruction, 0, len(factors)-1)
+	seq := len(factors)
+	for h.Len() > 1 {
+		a := heap.Pop(&h).(factor)
+		b := heap.Pop(&h).(factor)
+		res = append(res, irsource.Instruction{Type: irsource.Mul, Inputs: []int{a.v, b.v}, Loc: loc})
+		heap.Push(&h, factor{depth: max(a.depth, b.depth) + 1, seq: seq, v: next})
+		seq++
+		next++
+	}
+	return res
+}
@@ -0,0 +1,83 @@
+package passes
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// circuitDepth returns the depth of the outputs of the circuit, see instructionDepth
+func circuitDepth(c *irsource.Circuit) int {
+	depth := make([]int, c.NumInputs+1)
+	for i := range c.Instructions {
+		d := instructionDepth(&c.Instructions[i], depth)
+		for j := 0; j < c.Instructions[i].OutputCount(); j++ {
+			depth = append(depth, d)
+		}
+	}
+	res := 0
+	for _, x := range c.Outputs {
+		res = max(res, depth[x])
+	}
+	return res
+}
+
+func TestReassociate(t *testing.T) {
+	root := builder.NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	var xs []frontend.Variable
+	for i := 0; i < 16; i++ {
+		xs = append(xs, root.SecretVariable(schema.LeafInfo{}))
+	}
+	prod := xs[0]
+	sum := xs[0]
+	for i, x := range xs[1:] {
+		prod = root.Mul(prod, x)
+		sum = root.Add(root.Mul(sum, 2), x, i)
+	}
+	root.AssertIsEqual(root.Sub(sum, prod), 1)
+	root.Output(prod)
+	root.Output(sum)
+	rc := root.Finalize()
+	c := rc.Circuits[0]
+
+	inputs := []constraint.Element{}
+	for i := range xs {
+		inputs = append(inputs, rc.Field.FromInterface(i+3))
+	}
+	expected := evalCircuit(t, rc, 0, inputs)
+	// the multiplications by constants become linear combinations
+	FoldConstants(rc)
+	if d := circuitDepth(c); d != 15 {
+		t.Fatalf("expected a depth of 15 before the pass, got %d", d)
+	}
+	if n := Reassociate(rc); n == 0 {
+		t.Fatal("expected instructions to be merged")
+	}
+	EliminateDeadCode(rc)
+	if d := circuitDepth(c); d != 4 {
+		t.Fatalf("expected a depth of 4 after the pass, got %d", d)
+	}
+	nbLinComb, nbMul := 0, 0
+	for _, in := range c.Instructions {
+		switch in.Type {
+		case irsource.LinComb:
+			nbLinComb++
+		case irsource.Mul:
+			nbMul++
+		}
+	}
+	if nbMul != 15 || nbLinComb > 2 {
+		t.Fatalf("expected 15 multiplications and at most 2 linear combinations, got %d and %d", nbMul, nbLinComb)
+	}
+	got := evalCircuit(t, rc, 0, inputs)
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("value %d differs after the pass", i)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package passes
+
+import "github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+
+// rewriteCircuit rebuilds the instructions of c in order. The operands of the instruction passed
+// to f are already renumbered. f either returns the instruction to emit, or nil together with
+// the (renumbered) variables replacing the outputs of the dropped instruction.
+// Constraints and outputs are renumbered accordingly.
+func rewriteCircuit(c *irsource.Circuit, f func(in *irsource.Instruction) (*irsource.Instruction, []int)) {
+	expandCircuit(c, func(in *irsource.Instruction) ([]irsource.Instruction, []int) {
+		res, replaced := f(in)
+		if res == nil {
+			return nil, replaced
+		}
+		return []irsource.Instruction{*res}, nil
+	})
+}
+
+// expandCircuit is like rewriteCircuit, but f may replace an instruction by several ones: the
+// outputs of the dropped instruction are replaced by the outputs of the last one, which must have
+// the same number of outputs.
+func expandCircuit(c *irsource.Circuit, f func(in *irsource.Instruction) ([]irsource.Instruction, []int)) {
+	newVar := make([]int, c.NumVariables()+1)
+	for v := 0; v <= c.NumInputs; v++ {
+		newVar[v] = v
+	}
+	mapVar := func(x int) int { return newVar[x] }
+	old := c.NumInputs + 1
+	next := c.NumInputs + 1
+	insns := make([]irsource.Instruction, 0, len(c.Instructions))
+	for i := range c.Instructions {
+		in := c.Instructions[i].MapOperands(mapVar)
+		n := in.OutputCount()
+		res, replaced := f(&in)
+		if len(res) != 0 {
+			insns = append(insns, res...)
+			for j := range res {
+				next += res[j].OutputCount()
+			}
+			for j := 0; j < n; j++ {
+				newVar[old+j] = next - n + j
+			}
+		} else {
+			for j := 0; j < n; j++ {
+				newVar[old+j] = replaced[j]
+			}
+		}
+		old += n
+	}
+	c.Instructions = insns
+	for i := range c.Constraints {
+		c.Constraints[i].Var = newVar[c.Constraints[i].Var]
+	}
+	for i := range c.Outputs {
+		c.Outputs[i] = newVar[c.Outputs[i]]
+	}
+}
@@ -0,0 +1,128 @@
+package passes
+
+import (
+	"sort"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+)
+
+// UnderConstrainedVar is a variable found by FindUnderConstrained.
+type UnderConstrainedVar struct {
+	// Circuit is the id of the circuit of the variable.
+	Circuit uint64
+	// Var is the variable in its circuit.
+	Var int
+	// Instruction is the index of the hint computing the variable, or -1 for an input of the
+	// root circuit.
+	Instruction int
+}
+
+// FindUnderConstrained returns the secret inputs of the root circuit and the outputs of hints
+// which reach no constraint: whatever their values, the circuit is satisfied, so that a prover
+// may choose them freely, which is a common soundness bug. The outputs of the root circuit don't
+// count as constraints. The outputs of a subcircuit reach a constraint if they do in one of its
+// calls, and its inputs are assumed to reach each of its outputs. Unreachable subcircuits are
+// ignored. The variables are sorted by circuit id and variable.
+func FindUnderConstrained(rc *irsource.RootCircuit) []UnderConstrainedVar {
+	// constrained[id][j] is whether the input j of the circuit id reaches its constraints
+	constrained := make(map[uint64][]bool)
+	// the circuits reachable from the root circuit, in post-order: callees first
+	var order []uint64
+	var visit func(id uint64)
+	visit = func(id uint64) {
+		if _, ok := constrained[id]; ok {
+			return
+		}
+		constrained[id] = nil
+		c := rc.Circuits[id]
+		for i := range c.Instructions {
+			if in := &c.Instructions[i]; in.Type == irsource.SubCircuitCall {
+				visit(in.ExtraId)
+			}
+		}
+		live, _ := liveVariables(c, constrained, nil)
+		constrained[id] = live[1 : c.NumInputs+1]
+		order = append(order, id)
+	}
+	visit(0)
+
+	// used[id][j] is whether the output j of the circuit id reaches a constraint in a call
+	used := make(map[uint64][]bool)
+	var res []UnderConstrainedVar
+	for k := len(order) - 1; k >= 0; k-- {
+		id := order[k]
+		c := rc.Circuits[id]
+		live, varStart := liveVariables(c, constrained, used[id])
+		if id == 0 {
+			for v := 1; v <= c.NumInputs; v++ {
+				if !live[v] {
+					res = append(res, UnderConstrainedVar{Circuit: id, Var: v, Instruction: -1})
+				}
+			}
+		}
+		for i := range c.Instructions {
+			in := &c.Instructions[i]
+			switch in.Type {
+			case irsource.Hint:
+				for v := varStart[i]; v < varStart[i+1]; v++ {
+					if !live[v] {
+						res = append(res, UnderConstrainedVar{Circuit: id, Var: v, Instruction: i})
+					}
+				}
+			case irsource.SubCircuitCall:
+				u := used[in.ExtraId]
+				if u == nil {
+					u = make([]bool, in.NumOutputs)
+					used[in.ExtraId] = u
+				}
+				for j := range u {
+					u[j] = u[j] || live[varStart[i]+j]
+				}
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Circuit != res[j].Circuit {
+			return res[i].Circuit < res[j].Circuit
+		}
+		return res[i].Var < res[j].Var
+	})
+	return res
+}
+
+// liveVariables returns whether each variable of c reaches a constraint, or an output j of c for
+// which outputs[j] is set, and the first variable defined by each instruction
+func liveVariables(c *irsource.Circuit, constrained map[uint64][]bool, outputs []bool) ([]bool, []int) {
+	n := len(c.Instructions)
+	varStart := make([]int, n+1)
+	varStart[0] = c.NumInputs + 1
+	for i := range c.Instructions {
+		varStart[i+1] = varStart[i] + c.Instructions[i].OutputCount()
+	}
+	live := make([]bool, varStart[n])
+	for _, con := range c.Constraints {
+		live[con.Var] = true
+	}
+	for j, ok := range outputs {
+		if ok {
+			live[c.Outputs[j]] = true
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		in := &c.Instructions[i]
+		out := hasSideEffect(in)
+		for v := varStart[i]; v < varStart[i+1] && !out; v++ {
+			out = live[v]
+		}
+		if in.Type == irsource.SubCircuitCall {
+			for j, x := range in.Inputs {
+				live[x] = live[x] || out || constrained[in.ExtraId][j]
+			}
+		} else if out {
+			for _, x := range in.Operands() {
+				live[x] = true
+			}
+		}
+	}
+	return live, varStart
+}
@@ -0,0 +1,63 @@
+package passes
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+func pairHint(_ *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	outputs[0].Set(inputs[0])
+	outputs[1].Set(inputs[0])
+	return nil
+}
+
+// hintedPair returns both outputs of a hint, the caller only constraining the first one
+func hintedPair(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	out, err := api.Compiler().NewHint(pairHint, 2, input[0])
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func TestFindUnderConstrained(t *testing.T) {
+	root := builder.NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	x := root.SecretVariable(schema.LeafInfo{})
+	root.SecretVariable(schema.LeafInfo{})
+	h, err := root.NewHint(pairHint, 2, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.AssertIsEqual(root.Mul(h[0], h[0]), x)
+	p := root.MemorizedSimpleCall(hintedPair, []frontend.Variable{x})
+	root.AssertIsEqual(p[0], 1)
+	rc := root.Finalize()
+
+	got := FindUnderConstrained(rc)
+	var sub uint64
+	for id := range rc.Circuits {
+		if id != 0 {
+			sub = id
+		}
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 under-constrained variables, got %+v", got)
+	}
+	// the second input, the second output of the hint, and the second output of the hint of the
+	// subcircuit
+	if got[0] != (UnderConstrainedVar{Circuit: 0, Var: 2, Instruction: -1}) {
+		t.Fatalf("expected the unused input, got %+v", got[0])
+	}
+	if in := rc.Circuits[0].Instructions[got[1].Instruction]; got[1].Circuit != 0 || in.Type != irsource.Hint || got[1].Var != 4 {
+		t.Fatalf("expected the second output of the hint, got %+v", got[1])
+	}
+	if got[2].Circuit != sub || got[2].Var != 3 {
+		t.Fatalf("expected the second output of the hint of the subcircuit, got %+v", got[2])
+	}
+}
@@ -0,0 +1,54 @@
+package ecgo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+)
+
+// WithPlonk also compiles the circuit with gnark's SparseR1CS builder, so that the same circuit
+// definition can be proven with Expander or with gnark's PLONK, e.g. to compare the backends,
+// see CompileResult.Plonk. The circuit must not call API, which gnark doesn't implement, and its
+// field must be supported by gnark.
+func WithPlonk() frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.plonk = true
+	})
+}
+
+// compilePlonk compiles circuit with gnark's SparseR1CS builder, turning panics into errors like
+// compileR1CS
+func compilePlonk(field *big.Int, circuit frontend.Circuit) (ccs constraint.SparseR1CS, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	cs, err := frontend.Compile(field, scs.NewBuilder, circuit)
+	if err != nil {
+		return nil, err
+	}
+	return cs.(constraint.SparseR1CS), nil
+}
+
+// Plonk returns the PLONK constraint system of the circuit compiled with WithPlonk, or nil
+// without it. It can be given to gnark's plonk.Setup, and its witnesses are gnark's witnesses of
+// the circuit.
+func (c *CompileResult) Plonk() constraint.SparseR1CS {
+	return c.plonk
+}
+
+// WritePlonk writes the PLONK constraint system of the circuit compiled with WithPlonk, in the
+// binary format of gnark, read by its ReadFrom.
+func (c *CompileResult) WritePlonk(w io.Writer) error {
+	if c.plonk == nil {
+		return errors.New("the circuit wasn't compiled with WithPlonk")
+	}
+	_, err := c.plonk.WriteTo(w)
+	return err
+}
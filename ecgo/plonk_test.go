@@ -0,0 +1,41 @@
+package ecgo
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/plonk"
+)
+
+func TestCompilePlonk(t *testing.T) {
+	ccs, err := compilePlonk(ecc.BN254.ScalarField(), &equivalenceCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := solveR1CS(ccs, []*big.Int{big.NewInt(27)}, []*big.Int{big.NewInt(3)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := solveR1CS(ccs, []*big.Int{big.NewInt(28)}, []*big.Int{big.NewInt(3)}); err == nil {
+		t.Fatal("expected an unsatisfied assignment to be rejected")
+	}
+	if _, err := compilePlonk(ecc.BN254.ScalarField(), &checkCircuit{}); err == nil {
+		t.Fatal("expected a circuit calling API to be rejected")
+	}
+
+	if err := (&CompileResult{}).WritePlonk(&bytes.Buffer{}); err == nil {
+		t.Fatal("expected an error without WithPlonk")
+	}
+	var buf bytes.Buffer
+	if err := (&CompileResult{plonk: ccs}).WritePlonk(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read := plonk.NewCS(ecc.BN254)
+	if _, err := read.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if read.GetNbConstraints() != ccs.GetNbConstraints() {
+		t.Fatalf("read %d constraints, wrote %d", read.GetNbConstraints(), ccs.GetNbConstraints())
+	}
+}
@@ -0,0 +1,413 @@
+package ecgo
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"strings"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/google/pprof/profile"
+)
+
+// Profile samples, in the order of the values of each sample
+var profileSampleTypes = []*profile.ValueType{
+	{Type: "instructions", Unit: "count"},
+	{Type: "constraints", Unit: "count"},
+	{Type: "gates", Unit: "count"},
+	{Type: "layers", Unit: "count"},
+}
+
+// WriteProfile writes a pprof profile of the circuit to w, which attributes its cost to the call
+// stacks that built it, e.g. for a flame graph with go tool pprof. Each instruction and constraint
+// of the optimized IR is a sample, counted once per call of its subcircuit, with these values:
+//   - instructions and constraints: 1 for the instructions and constraints, respectively;
+//   - gates: an estimate of the number of gates of the layered circuit;
+//   - layers: the number of layers added by the instruction, if it's on the deepest path of the
+//     circuit, so that the total is an estimate of the depth of the layered circuit.
+//
+// Only the frames recorded at build time are known: compile with WithProfile, or a large enough
+// WithSourceLocations, to get the whole call stacks. The builder also records the constraints in
+// the profiling sessions of gnark's profile package, so that it can be used like with gnark.
+func (c *CompileResult) WriteProfile(w io.Writer) error {
+	return newProfiler(c.irs).build().Write(w)
+}
+
+type profiler struct {
+	rc *irsource.RootCircuit
+	p  *profile.Profile
+
+	functions map[string]*profile.Function
+	locations map[irsource.Frame]*profile.Location
+	// stacks[loc] is the pprof stack of the source location loc
+	stacks map[uint32][]*profile.Location
+	// samples by source location, to merge the samples of the same stack
+	samples map[uint32]*profile.Sample
+
+	// number of times each circuit is instantiated
+	calls map[uint64]uint64
+	// depths[id] is the depth of each variable of circuit id, with inputs at depth 0
+	depths map[uint64][]int
+	// defs[id] is the instruction defining each variable of circuit id, and its index among the
+	// outputs of the instruction
+	defs map[uint64][][2]int
+	// widths[id] is the estimated gates of circuit id by depth, see layerGates
+	widths map[uint64][]layered.LayerStats
+}
+
+func newProfiler(rc *irsource.RootCircuit) *profiler {
+	return &profiler{
+		rc:        rc,
+		p:         &profile.Profile{SampleType: profileSampleTypes, DefaultSampleType: "gates"},
+		functions: make(map[string]*profile.Function),
+		locations: make(map[irsource.Frame]*profile.Location),
+		stacks:    make(map[uint32][]*profile.Location),
+		samples:   make(map[uint32]*profile.Sample),
+		depths:    make(map[uint64][]int),
+		defs:      make(map[uint64][][2]int),
+		widths:    make(map[uint64][]layered.LayerStats),
+	}
+}
+
+func (p *profiler) stack(loc uint32) []*profile.Location {
+	if s, ok := p.stacks[loc]; ok {
+		return s
+	}
+	frames := p.rc.Location(loc)
+	if len(frames) == 0 {
+		frames = irsource.SourceLocation{{Function: "unknown"}}
+	}
+	s := make([]*profile.Location, len(frames))
+	for i, f := range frames {
+		l, ok := p.locations[f]
+		if !ok {
+			fn, ok := p.functions[f.File+f.Function]
+			if !ok {
+				fe := strings.Split(f.Function, "/")
+				fn = &profile.Function{
+					ID:         uint64(len(p.p.Function) + 1),
+					Name:       fe[len(fe)-1],
+					SystemName: f.Function,
+					Filename:   f.File,
+				}
+				p.functions[f.File+f.Function] = fn
+				p.p.Function = append(p.p.Function, fn)
+			}
+			l = &profile.Location{
+				ID:   uint64(len(p.p.Location) + 1),
+				Line: []profile.Line{{Function: fn, Line: int64(f.Line)}},
+			}
+			p.locations[f] = l
+			p.p.Location = append(p.p.Location, l)
+		}
+		s[i] = l
+	}
+	p.stacks[loc] = s
+	return s
+}
+
+func (p *profiler) add(loc uint32, values ...int64) {
+	s, ok := p.samples[loc]
+	if !ok {
+		s = &profile.Sample{Location: p.stack(loc), Value: make([]int64, len(profileSampleTypes))}
+		p.samples[loc] = s
+		p.p.Sample = append(p.p.Sample, s)
+	}
+	for i, v := range values {
+		s.Value[i] += v
+	}
+}
+
+func (p *profiler) build() *profile.Profile {
+	p.calls = p.rc.CallCounts()
+	for _, id := range p.rc.CircuitIds() {
+		n := int64(p.calls[id])
+		if n == 0 {
+			continue
+		}
+		c := p.rc.Circuits[id]
+		for i := range c.Instructions {
+			in := &c.Instructions[i]
+			if in.Type != irsource.SubCircuitCall {
+				p.add(in.Loc, n, 0, n*int64(estimatedGates(in)))
+			}
+		}
+		for _, con := range c.Constraints {
+			p.add(con.Loc, 0, n, n)
+		}
+	}
+
+	// attribute the layers to the deepest path of the root circuit
+	root := p.rc.Circuits[0]
+	depth := p.variableDepths(0)
+	deepest := 0
+	for _, x := range root.Outputs {
+		if depth[x] > depth[deepest] {
+			deepest = x
+		}
+	}
+	for _, con := range root.Constraints {
+		if depth[con.Var] > depth[deepest] {
+			deepest = con.Var
+		}
+	}
+	p.traceLayers(0, deepest)
+	return p.p
+}
+
+// estimatedGates returns the number of gates of the layered circuit computing the instruction,
+// ignoring the relay gates between layers.
+func estimatedGates(in *irsource.Instruction) int {
+	g := estimatedGateKinds(in)
+	return int(gateCount(&g))
+}
+
+// estimatedGateKinds returns the gates of estimatedGates by kind. The results of hints are
+// inputs of the layered circuit, counted as the add gates relaying them.
+func estimatedGateKinds(in *irsource.Instruction) layered.LayerStats {
+	switch in.Type {
+	case irsource.LinComb:
+		if in.Const.IsZero() {
+			return layered.LayerStats{NumAdd: uint64(len(in.Inputs))}
+		}
+		return layered.LayerStats{NumAdd: uint64(len(in.Inputs)), NumCst: 1}
+	case irsource.Mul:
+		if len(in.Inputs) == 0 {
+			return layered.LayerStats{}
+		}
+		return layered.LayerStats{NumMul: uint64(len(in.Inputs) - 1)}
+	case irsource.Div:
+		// lowered like passes.LowerDivisions: the inverse, or the quotient if unchecked, is an
+		// input, checked by a multiplication
+		if in.ExtraId == 0 {
+			return layered.LayerStats{NumMul: 2, NumAdd: 2, NumCst: 1}
+		}
+		return layered.LayerStats{NumMul: 1, NumAdd: 3}
+	case irsource.BoolBinOp:
+		return layered.LayerStats{NumMul: 1, NumAdd: 2}
+	case irsource.IsZero:
+		// the inverse is an input, x * inv and x * (1 - x * inv) are multiplications
+		return layered.LayerStats{NumMul: 2, NumAdd: 2, NumCst: 1}
+	case irsource.Hint:
+		return layered.LayerStats{NumAdd: uint64(in.NumOutputs)}
+	case irsource.ConstantLike:
+		return layered.LayerStats{NumCst: 1}
+	case irsource.CustomGate:
+		return layered.LayerStats{NumCustom: 1}
+	}
+	return layered.LayerStats{}
+}
+
+func gateCount(l *layered.LayerStats) uint64 {
+	return l.NumMul + l.NumAdd + l.NumCst + l.NumCustom
+}
+
+// addedLayers returns the number of multiplication layers between the operands and the result
+func addedLayers(in *irsource.Instruction) int {
+	switch in.Type {
+	case irsource.Mul:
+		if len(in.Inputs) < 2 {
+			return 0
+		}
+		return bits.Len(uint(len(in.Inputs) - 1))
+	case irsource.Div, irsource.BoolBinOp, irsource.IsZero, irsource.CustomGate:
+		return 1
+	}
+	return 0
+}
+
+// definesInput returns whether the results of the instruction are inputs of the layered circuit
+func definesInput(in *irsource.Instruction) bool {
+	return in.Type == irsource.Hint || in.Type == irsource.ConstantLike
+}
+
+// variableDepths returns the number of layers needed to compute each variable of the circuit
+// from its inputs. The outputs of a subcircuit call are as deep as its deepest input plus the
+// depth of the output in the subcircuit.
+func (p *profiler) variableDepths(id uint64) []int {
+	if d, ok := p.depths[id]; ok {
+		return d
+	}
+	c := p.rc.Circuits[id]
+	depth := make([]int, c.NumInputs+1, c.NumVariables()+1)
+	for i := range c.Instructions {
+		in := &c.Instructions[i]
+		d := 0
+		for _, x := range in.Operands() {
+			d = max(d, depth[x])
+		}
+		switch {
+		case in.Type == irsource.SubCircuitCall:
+			sub := p.variableDepths(in.ExtraId)
+			for _, x := range p.rc.Circuits[in.ExtraId].Outputs {
+				depth = append(depth, d+sub[x])
+			}
+		case definesInput(in):
+			for j := 0; j < in.OutputCount(); j++ {
+				depth = append(depth, 0)
+			}
+		default:
+			depth = append(depth, d+addedLayers(in))
+		}
+	}
+	p.depths[id] = depth
+	return depth
+}
+
+func (p *profiler) definitions(id uint64) [][2]int {
+	if d, ok := p.defs[id]; ok {
+		return d
+	}
+	c := p.rc.Circuits[id]
+	def := make([][2]int, c.NumInputs+1, c.NumVariables()+1)
+	for i := range c.Instructions {
+		for j := 0; j < c.Instructions[i].OutputCount(); j++ {
+			def = append(def, [2]int{i, j})
+		}
+	}
+	p.defs[id] = def
+	return def
+}
+
+// traceLayers attributes the layers of the deepest path to variable x of circuit id, and returns
+// the input of the circuit the path starts from, or 0 if it starts inside the circuit.
+func (p *profiler) traceLayers(id uint64, x int) int {
+	c := p.rc.Circuits[id]
+	depth := p.variableDepths(id)
+	def := p.definitions(id)
+	for x > c.NumInputs {
+		in := &c.Instructions[def[x][0]]
+		if definesInput(in) {
+			return 0
+		}
+		next := 0
+		if in.Type == irsource.SubCircuitCall {
+			sub := p.rc.Circuits[in.ExtraId]
+			k := p.traceLayers(in.ExtraId, sub.Outputs[def[x][1]])
+			if k == 0 {
+				return 0
+			}
+			next = in.Inputs[k-1]
+		} else {
+			if n := addedLayers(in); n > 0 {
+				p.add(in.Loc, 0, 0, 0, int64(n))
+			}
+			for _, y := range in.Operands() {
+				if next == 0 || depth[y] > depth[next] {
+					next = y
+				}
+			}
+			if next == 0 {
+				return 0
+			}
+		}
+		x = next
+	}
+	return x
+}
+
+// layerGates returns the estimated gates of circuit id by depth of their results, see
+// variableDepths, the gates of the subcircuits called being counted at their depth in the caller.
+// Only the gate counts of the layer stats are set.
+func (p *profiler) layerGates(id uint64) []layered.LayerStats {
+	if w, ok := p.widths[id]; ok {
+		return w
+	}
+	c := p.rc.Circuits[id]
+	depth := p.variableDepths(id)
+	var res []layered.LayerStats
+	add := func(d int, g *layered.LayerStats) {
+		for len(res) <= d {
+			res = append(res, layered.LayerStats{})
+		}
+		res[d].NumMul += g.NumMul
+		res[d].NumAdd += g.NumAdd
+		res[d].NumCst += g.NumCst
+		res[d].NumCustom += g.NumCustom
+	}
+	x := c.NumInputs + 1
+	for i := range c.Instructions {
+		in := &c.Instructions[i]
+		if in.Type == irsource.SubCircuitCall {
+			d := p.callDepth(id, in)
+			sub := p.layerGates(in.ExtraId)
+			for k := range sub {
+				add(d+k, &sub[k])
+			}
+		} else if g := estimatedGateKinds(in); gateCount(&g) > 0 {
+			add(depth[x], &g)
+		}
+		x += in.OutputCount()
+	}
+	p.widths[id] = res
+	return res
+}
+
+// estimatedLayerStats estimates the layers of the layered circuit, the layer of depth d computing
+// the variables of the root circuit of depth d, see layerGates. Its output wires are the results
+// of its gates, and the inputs of the root circuit for the first layer.
+func (p *profiler) estimatedLayerStats() []layered.LayerStats {
+	layers := append([]layered.LayerStats(nil), p.layerGates(0)...)
+	if len(layers) == 0 {
+		layers = append(layers, layered.LayerStats{})
+	}
+	in := uint64(p.rc.Circuits[0].NumInputs)
+	layers[0].OutputLen = in
+	for i := range layers {
+		layers[i].InputLen = in
+		layers[i].OutputLen += gateCount(&layers[i])
+		in = layers[i].OutputLen
+	}
+	return layers
+}
+
+// widestDepth returns the depth of the widest estimated layer, and its number of output wires
+func (p *profiler) widestDepth() (int, uint64) {
+	d, w := 0, uint64(0)
+	for k, l := range p.estimatedLayerStats() {
+		if l.OutputLen > w {
+			d, w = k, l.OutputLen
+		}
+	}
+	return d, w
+}
+
+// gatesAtDepth adds the estimated gates of circuit id at depth d to values, by source location
+func (p *profiler) gatesAtDepth(id uint64, d int, values map[uint32]uint64) {
+	c := p.rc.Circuits[id]
+	depth := p.variableDepths(id)
+	x := c.NumInputs + 1
+	for i := range c.Instructions {
+		in := &c.Instructions[i]
+		if in.Type == irsource.SubCircuitCall {
+			k := d - p.callDepth(id, in)
+			if w := p.layerGates(in.ExtraId); k >= 0 && k < len(w) && gateCount(&w[k]) > 0 {
+				p.gatesAtDepth(in.ExtraId, k, values)
+			}
+		} else if n := estimatedGates(in); n > 0 && depth[x] == d {
+			values[in.Loc] += uint64(n)
+		}
+		x += in.OutputCount()
+	}
+}
+
+// callDepth returns the depth of the deepest operand of the call in of circuit id
+func (p *profiler) callDepth(id uint64, in *irsource.Instruction) int {
+	depth := p.variableDepths(id)
+	d := 0
+	for _, x := range in.Operands() {
+		d = max(d, depth[x])
+	}
+	return d
+}
+
+func (c *compileConfig) writeProfile(res *CompileResult) error {
+	f, err := os.Create(c.profilePath)
+	if err != nil {
+		return fmt.Errorf("create profile: %w", err)
+	}
+	defer f.Close()
+	return res.WriteProfile(f)
+}
@@ -0,0 +1,73 @@
+package ecgo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+	gnarkprofile "github.com/consensys/gnark/profile"
+	"github.com/google/pprof/profile"
+)
+
+func profiledCube(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	return []frontend.Variable{api.Mul(input[0], input[0], input[0])}
+}
+
+func buildProfiled() *builder.Root {
+	root := builder.NewRoot(m31.ScalarField, frontend.CompileConfig{})
+	root.SetSourceLocationDepth(builder.MaxSourceLocationDepth)
+	x := root.SecretVariable(schema.LeafInfo{})
+	for i := 0; i < 3; i++ {
+		x = root.MemorizedSimpleCall(profiledCube, []frontend.Variable{x})[0]
+	}
+	root.AssertIsEqual(x, root.Add(x, x))
+	return root
+}
+
+func TestWriteProfile(t *testing.T) {
+	res := &CompileResult{irs: buildProfiled().Finalize()}
+	var buf bytes.Buffer
+	if err := res.WriteProfile(&buf); err != nil {
+		t.Fatal(err)
+	}
+	p, err := profile.Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := make([]int64, len(p.SampleType))
+	cube := make([]int64, len(p.SampleType))
+	for _, s := range p.Sample {
+		inCube, inTest := false, false
+		for _, l := range s.Location {
+			name := l.Line[0].Function.Name
+			inCube = inCube || strings.HasSuffix(name, ".profiledCube")
+			inTest = inTest || strings.HasSuffix(name, ".TestWriteProfile")
+		}
+		if !inTest {
+			t.Fatalf("expected the whole call stack, got %v", s.Location)
+		}
+		for i, v := range s.Value {
+			total[i] += v
+			if inCube {
+				cube[i] += v
+			}
+		}
+	}
+	// instructions, constraints, gates, layers
+	if cube[0] != 3 || cube[2] != 6 || cube[3] != 6 || total[1] != 1 || total[3] != 6 {
+		t.Fatalf("unexpected profile: total %v, cube %v", total, cube)
+	}
+}
+
+func TestGnarkProfile(t *testing.T) {
+	p := gnarkprofile.Start(gnarkprofile.WithNoOutput())
+	buildProfiled()
+	p.Stop()
+	if p.NbConstraints() != 1 {
+		t.Fatalf("expected 1 constraint, got %d", p.NbConstraints())
+	}
+}
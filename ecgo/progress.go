@@ -0,0 +1,114 @@
+package ecgo
+
+import (
+	"context"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/consensys/gnark/frontend"
+)
+
+// Progress is the state of a compilation, reported to the callback given to WithProgress.
+type Progress struct {
+	// Phase is the phase of the compilation being started: "define", "finalize", the name of
+	// each optimization pass, e.g. "extract", "fold", "reassociate", "cse" or "dce", then
+	// "layering", "padding", "profile", or "done" once the compilation succeeded.
+	Phase string
+	// Percent is an estimate of the share of the compilation done, from 0 to 100.
+	Percent int
+	// Gates is the number of instructions of the circuit so far, or the number of gates of the
+	// layered circuit once it's layered.
+	Gates int
+}
+
+// phasePercent is the share of the compilation done at the start of each phase, the circuit
+// being usually defined and layered in comparable times
+var phasePercent = map[string]int{
+	"define":      0,
+	"finalize":    40,
+	"extract":     45,
+	"fold":        48,
+	"reassociate": 51,
+	"cse":         54,
+	"dce":         57,
+	"layering":    60,
+	"padding":     90,
+	"profile":     95,
+	"done":        100,
+}
+
+// WithProgress sets a callback receiving the progress of the compilation, at the start of each
+// phase and periodically while the circuit is defined. It's called by the goroutine compiling.
+func WithProgress(f func(Progress)) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.progress = f
+	})
+}
+
+// canceled is the panic aborting the definition of a circuit once its context is done
+type canceled struct {
+	err error
+}
+
+// progress reports the progress of a compilation and checks its cancellation
+type progress struct {
+	ctx     context.Context
+	f       func(Progress)
+	percent int
+	// trace writes the events of WithTrace, if set
+	trace *tracer
+}
+
+// report reports the start of phase, and returns the error of the context if it's done. Custom
+// passes keep the percentage of the previous phase.
+func (p *progress) report(phase string, gates int) error {
+	if percent, ok := phasePercent[phase]; ok {
+		p.percent = percent
+	}
+	if p.f != nil {
+		p.f(Progress{Phase: phase, Percent: p.percent, Gates: gates})
+	}
+	if p.trace != nil {
+		p.trace.startPhase(phase, gates)
+	}
+	return p.ctx.Err()
+}
+
+// reported reports whether the progress is reported or traced, so that the gates are counted
+func (p *progress) reported() bool {
+	return p.f != nil || p.trace != nil
+}
+
+// ran records the changes made by the optimization pass being run
+func (p *progress) ran(changes int) {
+	if p.trace != nil {
+		p.trace.changes = changes
+	}
+}
+
+// done reports the end of a successful compilation, whose layered circuit has the given gates
+func (p *progress) done(gates int) {
+	if p.f != nil {
+		p.f(Progress{Phase: "done", Percent: phasePercent["done"], Gates: gates})
+	}
+	if p.trace != nil {
+		p.trace.startPhase("done", gates)
+	}
+}
+
+// building is the callback of builder.Root.SetProgress while the circuit is defined
+func (p *progress) building(nbInstructions int) {
+	if p.f != nil {
+		p.f(Progress{Phase: "define", Gates: nbInstructions})
+	}
+	if err := p.ctx.Err(); err != nil {
+		panic(canceled{err: err})
+	}
+}
+
+func numInstructions(rc *irsource.RootCircuit) int {
+	n := 0
+	for _, c := range rc.Circuits {
+		n += len(c.Instructions)
+	}
+	return n
+}
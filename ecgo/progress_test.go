@@ -0,0 +1,46 @@
+package ecgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+)
+
+type longCircuit struct {
+	X frontend.Variable
+}
+
+func (c *longCircuit) Define(api frontend.API) error {
+	x := c.X
+	for i := 0; i < 3*builder.ProgressInterval; i++ {
+		x = api.Mul(x, c.X)
+	}
+	api.AssertIsEqual(x, 1)
+	return nil
+}
+
+func TestCompileContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var reports []Progress
+	_, err := CompileContext(ctx, m31.ScalarField, &longCircuit{}, WithProgress(func(p Progress) {
+		reports = append(reports, p)
+		if p.Gates != 0 {
+			cancel()
+		}
+	}))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the compilation to be canceled, got %v", err)
+	}
+	if len(reports) != 2 || reports[0].Phase != "define" || reports[1].Gates != builder.ProgressInterval {
+		t.Fatalf("unexpected progress reports %v", reports)
+	}
+
+	_, err = CompileContext(ctx, m31.ScalarField, &longCircuit{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled context to stop the compilation, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+// Package prover proves witnesses of layered circuits with the Expander prover. The prover is
+// called in-process through the Rust library, with the circuit and the witness passed in memory,
+// so there are no circuit, witness or proof files to hand over. Proofs are checked with the
+// verifier package.
+package prover
+
+import (
+	"fmt"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/rust"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/verifier"
+)
+
+// Prover proves witnesses of a layered circuit. It keeps the serialized circuit, so that several
+// witnesses can be proven without serializing it again.
+type Prover struct {
+	*verifier.Verifier
+}
+
+// New returns a Prover for the layered circuit.
+func New(lc *layered.RootCircuit) *Prover {
+	return &Prover{verifier.New(lc)}
+}
+
+// NewSerialized returns a Prover for a layered circuit serialized by layered.RootCircuit.Serialize,
+// e.g. read from the circuit file written by the compiler.
+func NewSerialized(circuit []byte) *Prover {
+	return &Prover{verifier.NewSerialized(circuit)}
+}
+
+// NewMapped returns a Prover for a layered circuit mapped in memory by layered.OpenMapped, which
+// is passed to the prover in place, without deserializing it or copying it. The Prover must not
+// be used after m is closed.
+func NewMapped(m *layered.MappedCircuit) *Prover {
+	return &Prover{verifier.NewMapped(m)}
+}
+
+// Prove proves the witness, and returns the serialized proof and claimed value, as read by
+// Verify and by the Expander verifier.
+func (p *Prover) Prove(w *irwg.Witness) ([]byte, error) {
+	if err := p.CheckWitness(w); err != nil {
+		return nil, err
+	}
+	proof, err := rust.Prove(p.Circuit(), w.Serialize(), p.FieldId())
+	if err != nil {
+		return nil, fmt.Errorf("prove: %w", err)
+	}
+	return proof, nil
+}
+
+// Prove proves the witness for the layered circuit, see Prover.Prove.
+func Prove(lc *layered.RootCircuit, w *irwg.Witness) ([]byte, error) {
+	return New(lc).Prove(w)
+}
+
+// Verify checks a proof returned by Prove, see verifier.Verifier.Verify.
+func Verify(lc *layered.RootCircuit, w *irwg.Witness, proof []byte) (bool, error) {
+	return verifier.Verify(lc, w, proof)
+}
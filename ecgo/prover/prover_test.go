@@ -0,0 +1,74 @@
+package prover
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/bn254"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+// the circuit x0 + x1
+func sampleCircuit() *layered.RootCircuit {
+	return &layered.RootCircuit{
+		NumActualOutputs: 1,
+		Circuits: []*layered.Circuit{{
+			InputLen:  2,
+			OutputLen: 1,
+			Add: []layered.GateAdd{
+				{In: 0, Out: 0, Coef: big.NewInt(1), CoefType: 1},
+				{In: 1, Out: 0, Coef: big.NewInt(1), CoefType: 1},
+			},
+		}},
+		Layers: []uint64{0},
+		Field:  m31.ScalarField,
+	}
+}
+
+func sampleWitness(f *big.Int) *irwg.Witness {
+	return &irwg.Witness{
+		NumWitnesses:        1,
+		NumInputsPerWitness: 2,
+		Field:               f,
+		Values:              []*big.Int{big.NewInt(1), big.NewInt(2)},
+	}
+}
+
+// The checks before calling the Rust library
+func TestCheckWitness(t *testing.T) {
+	lc := sampleCircuit()
+	p := New(lc)
+	if p.FieldId() != 1 {
+		t.Fatalf("field id %d, expected 1", p.FieldId())
+	}
+
+	_, err := p.Prove(sampleWitness(bn254.ScalarField))
+	if err == nil || !strings.Contains(err.Error(), "field") {
+		t.Fatalf("expected a field mismatch, got %v", err)
+	}
+
+	w := sampleWitness(m31.ScalarField)
+	other := sampleCircuit()
+	other.Circuits[0].Add[1].Coef = big.NewInt(2)
+	hash := other.ContentHash()
+	w.CircuitHash = hash[:]
+	if _, err := p.Prove(w); !errors.Is(err, irwg.ErrCircuitMismatch) {
+		t.Fatalf("expected ErrCircuitMismatch, got %v", err)
+	}
+	if _, err := p.Verify(w, nil); !errors.Is(err, irwg.ErrCircuitMismatch) {
+		t.Fatalf("expected ErrCircuitMismatch, got %v", err)
+	}
+
+	hash = lc.ContentHash()
+	w.CircuitHash = hash[:]
+	if err := p.CheckWitness(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSerialized(lc.Serialize()).CheckWitness(w); err != nil {
+		t.Fatal(err)
+	}
+}
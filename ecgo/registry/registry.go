@@ -0,0 +1,65 @@
+// Package registry holds named circuits, so that tools like the ecc command can compile them
+// without a compile harness. Circuits register themselves in an init function of their package,
+// which is linked into the tool, or built as a Go plugin loaded by it.
+package registry
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// Circuit describes a registered circuit.
+type Circuit struct {
+	// Name identifies the circuit.
+	Name string
+	// Field is the scalar field the circuit is compiled for, e.g. m31.ScalarField.
+	Field *big.Int
+	// New returns an empty circuit, used both for compiling and as the assignment when solving
+	// a witness. Arrays and slices must have their final size.
+	New func() frontend.Circuit
+	// Options are passed to ecgo.Compile.
+	Options []frontend.CompileOption
+}
+
+var (
+	circuits  = make(map[string]Circuit)
+	circuitsM sync.RWMutex
+)
+
+// Register adds a circuit to the registry. It panics if the name is already taken, or if the
+// circuit has no field or constructor.
+func Register(c Circuit) {
+	if c.Field == nil || c.New == nil {
+		panic(fmt.Sprintf("circuit %q must have a field and a constructor", c.Name))
+	}
+	circuitsM.Lock()
+	defer circuitsM.Unlock()
+	if _, ok := circuits[c.Name]; ok {
+		panic(fmt.Sprintf("circuit %q is already registered", c.Name))
+	}
+	circuits[c.Name] = c
+}
+
+// Get returns the circuit registered with the given name.
+func Get(name string) (Circuit, bool) {
+	circuitsM.RLock()
+	defer circuitsM.RUnlock()
+	c, ok := circuits[name]
+	return c, ok
+}
+
+// Names returns the names of the registered circuits in increasing order.
+func Names() []string {
+	circuitsM.RLock()
+	defer circuitsM.RUnlock()
+	res := make([]string, 0, len(circuits))
+	for name := range circuits {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,38 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+)
+
+type testCircuit struct {
+	X frontend.Variable
+}
+
+func (c *testCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(c.X, 1)
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	Register(Circuit{Name: "registry_test", Field: m31.ScalarField, New: func() frontend.Circuit { return &testCircuit{} }})
+	c, ok := Get("registry_test")
+	if !ok || c.Field != m31.ScalarField {
+		t.Fatal("circuit not found")
+	}
+	found := false
+	for _, name := range Names() {
+		found = found || name == "registry_test"
+	}
+	if !found {
+		t.Fatalf("circuit not listed in %v", Names())
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a duplicate name")
+		}
+	}()
+	Register(c)
+}
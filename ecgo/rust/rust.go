@@ -8,17 +8,52 @@ import (
 	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/rust/wrapper"
 )
 
-func Compile(rc *irsource.RootCircuit) (*irwg.RootCircuit, *layered.RootCircuit, error) {
-	s := irsource.SerializeRootCircuit(rc)
-	irWgSer, lcSer, err := wrapper.CompileWithRustLib(s, field.GetFieldId(rc.Field))
+// Options are the options of the Rust compiler. The zero value compiles with the defaults.
+type Options = wrapper.CompileOptions
+
+func Compile(rc *irsource.RootCircuit, opts Options) (*irwg.RootCircuit, *layered.RootCircuit, error) {
+	irWg, lcSer, err := CompileSerialized(rc, opts)
 	if err != nil {
 		return nil, nil, err
 	}
-	irWg := irwg.DeserializeRootCircuit(irWgSer)
 	lc := layered.DeserializeRootCircuit(lcSer)
 	return irWg, lc, nil
 }
 
+// CompileSerialized is like Compile, but returns the layered circuit in its serialized form,
+// which is much smaller than the deserialized one. It fails for the fields the Rust library has no
+// config for, see field.CheckCompilable.
+func CompileSerialized(rc *irsource.RootCircuit, opts Options) (*irwg.RootCircuit, []byte, error) {
+	if err := field.CheckCompilable(rc.Field.Field()); err != nil {
+		return nil, nil, err
+	}
+	s := irsource.SerializeRootCircuit(rc)
+	irWgSer, lcSer, err := wrapper.CompileWithRustLib(s, field.GetFieldId(rc.Field), opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return irwg.DeserializeRootCircuit(irWgSer), lcSer, nil
+}
+
+// CompileToFile is like CompileSerialized, but writes the serialized layered circuit to the file at
+// path instead of returning it.
+func CompileToFile(rc *irsource.RootCircuit, opts Options, path string) (*irwg.RootCircuit, error) {
+	if err := field.CheckCompilable(rc.Field.Field()); err != nil {
+		return nil, err
+	}
+	s := irsource.SerializeRootCircuit(rc)
+	irWgSer, err := wrapper.CompileToFileWithRustLib(s, field.GetFieldId(rc.Field), opts, path)
+	if err != nil {
+		return nil, err
+	}
+	return irwg.DeserializeRootCircuit(irWgSer), nil
+}
+
+// Version returns the version of the Rust compiler, which changes whenever its output may change.
+func Version() string {
+	return wrapper.LibVersion()
+}
+
 func ProveFile(circuitFilename string, witnessBytes []byte) []byte {
 	return wrapper.ProveCircuitFile(circuitFilename, witnessBytes, layered.DetectFieldIdFromFile(circuitFilename))
 }
@@ -26,3 +61,14 @@ func ProveFile(circuitFilename string, witnessBytes []byte) []byte {
 func VerifyFile(circuitFilename string, witnessBytes []byte, proofBytes []byte) bool {
 	return wrapper.VerifyCircuitFile(circuitFilename, witnessBytes, proofBytes, layered.DetectFieldIdFromFile(circuitFilename))
 }
+
+// Prove proves the witness for the serialized layered circuit in-process, without writing them to
+// files, and returns the serialized proof and claimed value.
+func Prove(circuit []byte, witnessBytes []byte, fieldId uint64) ([]byte, error) {
+	return wrapper.ProveCircuit(circuit, witnessBytes, fieldId)
+}
+
+// Verify checks a proof returned by Prove.
+func Verify(circuit []byte, witnessBytes []byte, proofBytes []byte, fieldId uint64) (bool, error) {
+	return wrapper.VerifyCircuit(circuit, witnessBytes, proofBytes, fieldId)
+}
@@ -35,9 +35,27 @@ func getCacheDir() (string, error) {
 	return cacheDir, err
 }
 
+// LibVersion identifies the Rust library compilations will use: its ABI version and the
+// modification time of the local copy, if already downloaded. It doesn't load the library.
+func LibVersion() string {
+	cacheDir, err := getCacheDir()
+	if err != nil {
+		return fmt.Sprintf("abi%d", ABI_VERSION)
+	}
+	stat, err := os.Stat(filepath.Join(cacheDir, getLibName()))
+	if err != nil {
+		return fmt.Sprintf("abi%d", ABI_VERSION)
+	}
+	return fmt.Sprintf("abi%d-%d", ABI_VERSION, stat.ModTime().UnixNano())
+}
+
 var compilePtr unsafe.Pointer = nil
+var compileWithOptionsPtr unsafe.Pointer = nil
+var compileToFilePtr unsafe.Pointer = nil
 var proveCircuitFilePtr unsafe.Pointer = nil
 var verifyCircuitFilePtr unsafe.Pointer = nil
+var proveCircuitPtr unsafe.Pointer = nil
+var verifyCircuitPtr unsafe.Pointer = nil
 var compilePtrLock sync.Mutex
 
 func downloadFile(url string, filepath string) error {
@@ -182,6 +200,13 @@ func initCompilePtr() {
 	if compilePtr == nil {
 		panic("failed to load compile function")
 	}
+	// missing from libraries built before them, see CompileWithRustLib, CompileToFileWithRustLib,
+	// ProveCircuit and VerifyCircuit. They only add symbols, so they don't change the ABI version,
+	// and the libraries published for it still load.
+	compileWithOptionsPtr = C.dlsym(handle, C.CString("compile_with_options"))
+	compileToFilePtr = C.dlsym(handle, C.CString("compile_to_file"))
+	proveCircuitPtr = C.dlsym(handle, C.CString("prove_circuit"))
+	verifyCircuitPtr = C.dlsym(handle, C.CString("verify_circuit"))
 	proveCircuitFilePtr = C.dlsym(handle, C.CString("prove_circuit_file"))
 	if proveCircuitFilePtr == nil {
 		panic("failed to load prove_circuit_file function")
@@ -197,13 +222,44 @@ func goBytes(data *C.uint8_t, length C.uint64_t) []byte {
 	return bytes.Clone(unsafe.Slice((*byte)(data), length))
 }
 
-func CompileWithRustLib(s []byte, configId uint64) ([]byte, []byte, error) {
+// CompileOptions are the options of the Rust compiler, zero meaning the default of each.
+type CompileOptions struct {
+	// MaxTerms is the number of terms of an expression above which the final build compresses
+	// it into a new variable.
+	MaxTerms uint64
+	// MaxQuadraticTerms is the number of degree 2 terms of an expression above which the final
+	// build compresses it into a new variable.
+	MaxQuadraticTerms uint64
+}
+
+func (o CompileOptions) toC() C.CompileOptions {
+	return C.CompileOptions{max_terms: C.uint64_t(o.MaxTerms), max_quadratic_terms: C.uint64_t(o.MaxQuadraticTerms)}
+}
+
+var errNoCompileOptions = errors.New("the Rust library doesn't support compile options, it must be updated")
+
+// ErrNoInMemoryProving is returned by ProveCircuit and VerifyCircuit with a Rust library built
+// before prove_circuit and verify_circuit, which only proves circuit files.
+var ErrNoInMemoryProving = errors.New("the Rust library doesn't support in-memory proving, it must be updated")
+
+// CompileWithRustLib compiles the serialized source circuit, and returns the serialized witness
+// generator and layered circuit. Libraries built before compile_with_options only compile with
+// the default options.
+func CompileWithRustLib(s []byte, configId uint64, opts CompileOptions) ([]byte, []byte, error) {
 	initCompilePtr()
+	if opts != (CompileOptions{}) && compileWithOptionsPtr == nil {
+		return nil, nil, errNoCompileOptions
+	}
 
 	in := C.ByteArray{data: (*C.uint8_t)(C.CBytes(s)), length: C.uint64_t(len(s))}
 	defer C.free(unsafe.Pointer(in.data))
 
-	cr := C.compile(compilePtr, in, C.uint64_t(configId))
+	var cr C.CompileResult
+	if compileWithOptionsPtr != nil {
+		cr = C.compile_with_options(compileWithOptionsPtr, in, C.uint64_t(configId), opts.toC())
+	} else {
+		cr = C.compile(compilePtr, in, C.uint64_t(configId))
+	}
 
 	defer C.free(unsafe.Pointer(cr.ir_witness_gen.data))
 	defer C.free(unsafe.Pointer(cr.layered.data))
@@ -220,6 +276,37 @@ func CompileWithRustLib(s []byte, configId uint64) ([]byte, []byte, error) {
 	return irWitnessGen, layered, nil
 }
 
+// CompileToFileWithRustLib is like CompileWithRustLib, but the Rust library writes the layered
+// circuit to the file at path, so that it's never held in Go memory. With a library built before
+// compile_to_file, the layered circuit is returned by compile and written by Go instead.
+func CompileToFileWithRustLib(s []byte, configId uint64, opts CompileOptions, path string) ([]byte, error) {
+	initCompilePtr()
+	if compileToFilePtr == nil {
+		irWitnessGen, layered, err := CompileWithRustLib(s, configId, opts)
+		if err != nil {
+			return nil, err
+		}
+		return irWitnessGen, os.WriteFile(path, layered, 0o644)
+	}
+
+	in := C.ByteArray{data: (*C.uint8_t)(C.CBytes(s)), length: C.uint64_t(len(s))}
+	defer C.free(unsafe.Pointer(in.data))
+	bytesPath := []byte(path)
+	lp := C.ByteArray{data: (*C.uint8_t)(C.CBytes(bytesPath)), length: C.uint64_t(len(bytesPath))}
+	defer C.free(unsafe.Pointer(lp.data))
+
+	cr := C.compile_to_file(compileToFilePtr, in, C.uint64_t(configId), opts.toC(), lp)
+
+	defer C.free(unsafe.Pointer(cr.ir_witness_gen.data))
+	defer C.free(unsafe.Pointer(cr.layered.data))
+	defer C.free(unsafe.Pointer(cr.error.data))
+
+	if errMsg := goBytes(cr.error.data, cr.error.length); len(errMsg) > 0 {
+		return nil, errors.New(string(errMsg))
+	}
+	return goBytes(cr.ir_witness_gen.data, cr.ir_witness_gen.length), nil
+}
+
 func ProveCircuitFile(circuitFilename string, witness []byte, configId uint64) []byte {
 	initCompilePtr()
 	bytesFn := []byte(circuitFilename)
@@ -243,3 +330,44 @@ func VerifyCircuitFile(circuitFilename string, witness []byte, proof []byte, con
 	defer C.free(unsafe.Pointer(pr.data))
 	return C.verify_circuit_file(verifyCircuitFilePtr, cf, wi, pr, C.uint64_t(configId)) != 0
 }
+
+// ProveCircuit proves the serialized witness for the serialized layered circuit, without going
+// through files, and returns the serialized proof and claimed value.
+func ProveCircuit(circuit []byte, witness []byte, configId uint64) ([]byte, error) {
+	initCompilePtr()
+	if proveCircuitPtr == nil {
+		return nil, ErrNoInMemoryProving
+	}
+	ci := C.ByteArray{data: (*C.uint8_t)(C.CBytes(circuit)), length: C.uint64_t(len(circuit))}
+	defer C.free(unsafe.Pointer(ci.data))
+	wi := C.ByteArray{data: (*C.uint8_t)(C.CBytes(witness)), length: C.uint64_t(len(witness))}
+	defer C.free(unsafe.Pointer(wi.data))
+	pr := C.prove_circuit(proveCircuitPtr, ci, wi, C.uint64_t(configId))
+	defer C.free(unsafe.Pointer(pr.proof.data))
+	defer C.free(unsafe.Pointer(pr.error.data))
+	if errMsg := goBytes(pr.error.data, pr.error.length); len(errMsg) > 0 {
+		return nil, errors.New(string(errMsg))
+	}
+	return goBytes(pr.proof.data, pr.proof.length), nil
+}
+
+// VerifyCircuit is like VerifyCircuitFile, with the serialized layered circuit instead of its file.
+// A malformed proof is invalid, while a malformed circuit or witness is an error.
+func VerifyCircuit(circuit []byte, witness []byte, proof []byte, configId uint64) (bool, error) {
+	initCompilePtr()
+	if verifyCircuitPtr == nil {
+		return false, ErrNoInMemoryProving
+	}
+	ci := C.ByteArray{data: (*C.uint8_t)(C.CBytes(circuit)), length: C.uint64_t(len(circuit))}
+	defer C.free(unsafe.Pointer(ci.data))
+	wi := C.ByteArray{data: (*C.uint8_t)(C.CBytes(witness)), length: C.uint64_t(len(witness))}
+	defer C.free(unsafe.Pointer(wi.data))
+	pr := C.ByteArray{data: (*C.uint8_t)(C.CBytes(proof)), length: C.uint64_t(len(proof))}
+	defer C.free(unsafe.Pointer(pr.data))
+	vr := C.verify_circuit(verifyCircuitPtr, ci, wi, pr, C.uint64_t(configId))
+	defer C.free(unsafe.Pointer(vr.error.data))
+	if errMsg := goBytes(vr.error.data, vr.error.length); len(errMsg) > 0 {
+		return false, errors.New(string(errMsg))
+	}
+	return vr.valid != 0, nil
+}
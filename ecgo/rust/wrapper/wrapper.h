@@ -18,6 +18,23 @@ CompileResult compile(void *f, ByteArray ir_source, uint64_t config_id) {
     return ((compile_func) f)(ir_source, config_id);
 }
 
+typedef struct {
+    uint64_t max_terms;
+    uint64_t max_quadratic_terms;
+} CompileOptions;
+
+typedef CompileResult (*compile_with_options_func)(ByteArray ir_source, uint64_t config_id, CompileOptions options);
+
+CompileResult compile_with_options(void *f, ByteArray ir_source, uint64_t config_id, CompileOptions options) {
+    return ((compile_with_options_func) f)(ir_source, config_id, options);
+}
+
+typedef CompileResult (*compile_to_file_func)(ByteArray ir_source, uint64_t config_id, CompileOptions options, ByteArray layered_path);
+
+CompileResult compile_to_file(void *f, ByteArray ir_source, uint64_t config_id, CompileOptions options, ByteArray layered_path) {
+    return ((compile_to_file_func) f)(ir_source, config_id, options, layered_path);
+}
+
 typedef ByteArray (*prove_circuit_file_func)(ByteArray circuit_filename, ByteArray witness, uint64_t config_id);
 
 ByteArray prove_circuit_file(void *f, ByteArray circuit_filename, ByteArray witness, uint64_t config_id) {
@@ -30,6 +47,28 @@ uint8_t verify_circuit_file(void *f, ByteArray circuit_filename, ByteArray witne
     return ((verify_circuit_file_func) f)(circuit_filename, witness, proof, config_id);
 }
 
+typedef struct {
+    ByteArray proof;
+    ByteArray error;
+} ProveResult;
+
+typedef ProveResult (*prove_circuit_func)(ByteArray circuit, ByteArray witness, uint64_t config_id);
+
+ProveResult prove_circuit(void *f, ByteArray circuit, ByteArray witness, uint64_t config_id) {
+    return ((prove_circuit_func) f)(circuit, witness, config_id);
+}
+
+typedef struct {
+    uint8_t valid;
+    ByteArray error;
+} VerifyResult;
+
+typedef VerifyResult (*verify_circuit_func)(ByteArray circuit, ByteArray witness, ByteArray proof, uint64_t config_id);
+
+VerifyResult verify_circuit(void *f, ByteArray circuit, ByteArray witness, ByteArray proof, uint64_t config_id) {
+    return ((verify_circuit_func) f)(circuit, witness, proof, config_id);
+}
+
 typedef uint64_t (*abi_version_func)();
 uint64_t abi_version(void *f) {
     return ((abi_version_func) f)();
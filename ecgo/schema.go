@@ -0,0 +1,162 @@
+package ecgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// Schema returns the variables of the compiled circuit, as described by gnark's schema package:
+// their names, visibilities and array shapes, in declaration order. It's nil for the circuits
+// compiled by frontend.Compile with NewBuilder, which doesn't see the circuit. For CompileBatch,
+// it's the schema of a single copy, and for CompileMany, it holds a struct Circuits with a struct
+// field k for the variables of circuit k.
+func (c *CompileResult) Schema() *schema.Schema {
+	return c.schema
+}
+
+// circuitSchema returns the schema of circuit, see CompileResult.Schema. gnark's schema package
+// doesn't look into the interfaces of the circuits of CompileMany, so their schemas are joined.
+func circuitSchema(circuit frontend.Circuit) (*schema.Schema, error) {
+	m, ok := circuit.(*multiCircuit)
+	if !ok {
+		return schema.New(circuit, irwg.TVariable)
+	}
+	res := &schema.Schema{}
+	fields := make([]schema.Field, len(m.Circuits))
+	for k, c := range m.Circuits {
+		s, err := schema.New(c, irwg.TVariable)
+		if err != nil {
+			return nil, fmt.Errorf("circuit %d: %w", k, err)
+		}
+		fields[k] = schema.Field{Name: strconv.Itoa(k), Type: schema.Struct, SubFields: prefixFullNames(s.Fields, fmt.Sprintf("Circuits_%d_", k))}
+		res.NbPublic += s.NbPublic
+		res.NbSecret += s.NbSecret
+	}
+	res.Fields = []schema.Field{{Name: "Circuits", Type: schema.Struct, SubFields: fields}}
+	return res, nil
+}
+
+// prefixFullNames adds prefix to the full names of the fields and their subfields
+func prefixFullNames(fields []schema.Field, prefix string) []schema.Field {
+	for i := range fields {
+		if fields[i].FullName != "" {
+			fields[i].FullName = prefix + fields[i].FullName
+		}
+		fields[i].SubFields = prefixFullNames(fields[i].SubFields, prefix)
+	}
+	return fields
+}
+
+// schemaJSON is the encoding of a schema.Schema by WriteSchema
+type schemaJSON struct {
+	NbPublic int         `json:"nbPublic"`
+	NbSecret int         `json:"nbSecret"`
+	Fields   []fieldJSON `json:"fields"`
+}
+
+type fieldJSON struct {
+	Name       string      `json:"name"`
+	NameTag    string      `json:"nameTag,omitempty"`
+	FullName   string      `json:"fullName,omitempty"`
+	Visibility string      `json:"visibility,omitempty"`
+	Type       string      `json:"type"`
+	ArraySize  int         `json:"arraySize,omitempty"`
+	SubFields  []fieldJSON `json:"subFields,omitempty"`
+}
+
+var (
+	fieldTypeNames  = []string{schema.Leaf: "leaf", schema.Array: "array", schema.Struct: "struct"}
+	visibilityNames = []schema.Visibility{schema.Secret, schema.Public}
+)
+
+// WriteSchema writes the schema as a JSON object with the fields nbPublic, nbSecret and fields,
+// an array of objects with the fields name, nameTag, fullName, visibility ("secret" or "public"),
+// type ("leaf", "array" or "struct"), arraySize and subFields, see schema.Field. The variables
+// are named in the assignment files as in schema.LeafInfo.FullName, the fullName of a leaf or
+// the name of an array followed by the index, e.g. "Hash_3", see irwg.ParseAssignmentsJSON.
+func (c *CompileResult) WriteSchema(w io.Writer) error {
+	if c.schema == nil {
+		return fmt.Errorf("the circuit has no schema")
+	}
+	s := schemaJSON{NbPublic: c.schema.NbPublic, NbSecret: c.schema.NbSecret, Fields: fieldsToJSON(c.schema.Fields)}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+func fieldsToJSON(fields []schema.Field) []fieldJSON {
+	res := make([]fieldJSON, len(fields))
+	for i, f := range fields {
+		res[i] = fieldJSON{
+			Name:      f.Name,
+			NameTag:   f.NameTag,
+			FullName:  f.FullName,
+			Type:      fieldTypeNames[f.Type],
+			SubFields: fieldsToJSON(f.SubFields),
+		}
+		if f.Visibility != schema.Unset {
+			res[i].Visibility = f.Visibility.String()
+		}
+		if f.Type == schema.Array {
+			res[i].ArraySize = f.ArraySize
+		}
+	}
+	return res
+}
+
+// ReadSchema reads a schema written by CompileResult.WriteSchema.
+func ReadSchema(r io.Reader) (*schema.Schema, error) {
+	var s schemaJSON
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	fields, err := fieldsFromJSON(s.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	return &schema.Schema{Fields: fields, NbPublic: s.NbPublic, NbSecret: s.NbSecret}, nil
+}
+
+func fieldsFromJSON(fields []fieldJSON) ([]schema.Field, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	res := make([]schema.Field, len(fields))
+	for i, f := range fields {
+		res[i] = schema.Field{Name: f.Name, NameTag: f.NameTag, FullName: f.FullName, ArraySize: f.ArraySize}
+		found := false
+		for t, name := range fieldTypeNames {
+			if name == f.Type {
+				res[i].Type, found = schema.FieldType(t), true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("field %s has an invalid type %q", f.Name, f.Type)
+		}
+		if res[i].Type == schema.Leaf {
+			res[i].ArraySize = 1
+		}
+		if f.Visibility != "" {
+			found = false
+			for _, v := range visibilityNames {
+				if v.String() == f.Visibility {
+					res[i].Visibility, found = v, true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("field %s has an invalid visibility %q", f.Name, f.Visibility)
+			}
+		}
+		var err error
+		if res[i].SubFields, err = fieldsFromJSON(f.SubFields); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,59 @@
+package ecgo
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+type schemaCircuit struct {
+	X    frontend.Variable
+	Hash [3]frontend.Variable `gnark:",public"`
+	Pair struct {
+		A frontend.Variable `gnark:"a"`
+		B frontend.Variable `gnark:",public"`
+	}
+}
+
+func (c *schemaCircuit) Define(api frontend.API) error {
+	return nil
+}
+
+func TestWriteSchema(t *testing.T) {
+	s, err := schema.New(&schemaCircuit{}, irwg.TVariable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := &CompileResult{schema: s}
+	var buf bytes.Buffer
+	if err := res.WriteSchema(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"arraySize": 3`, `"nameTag": "a"`, `"fullName": "Pair_a"`, `"visibility": "public"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %s in the schema\n%s", want, buf.String())
+		}
+	}
+	read, err := ReadSchema(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, s) {
+		t.Fatalf("unexpected schema %+v, expected %+v", read, s)
+	}
+	if read.NbPublic != 4 || read.NbSecret != 2 {
+		t.Fatalf("unexpected counts %d and %d", read.NbPublic, read.NbSecret)
+	}
+
+	if _, err := ReadSchema(strings.NewReader(`{"fields": [{"name": "X", "type": "map"}]}`)); err == nil {
+		t.Fatal("expected an invalid type to be rejected")
+	}
+	if err := (&CompileResult{}).WriteSchema(&buf); err == nil {
+		t.Fatal("expected a result without a schema to be rejected")
+	}
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// This file implements the parts of gRPC used by the service, over the HTTP/2 of net/http: the
+// framing of the messages, the status codes, and the protobuf encoding of the messages of
+// compiler.proto.
+
+// serviceName is the full name of the service in compiler.proto, prefixing the paths of its
+// methods
+const serviceName = "ecgo.server.Compiler"
+
+// maxMessageSize is the maximum size of a message, which bounds the assignments of a Solve call
+const maxMessageSize = 256 << 20
+
+// Code is a gRPC status code.
+type Code uint32
+
+// The status codes returned by the service
+const (
+	CodeOK              Code = 0
+	CodeUnknown         Code = 2
+	CodeInvalidArgument Code = 3
+	CodeNotFound        Code = 5
+	CodeOutOfRange      Code = 11
+	CodeUnimplemented   Code = 12
+	CodeInternal        Code = 13
+)
+
+var codeNames = map[Code]string{
+	0: "OK", 1: "Canceled", 2: "Unknown", 3: "InvalidArgument", 4: "DeadlineExceeded", 5: "NotFound",
+	6: "AlreadyExists", 7: "PermissionDenied", 8: "ResourceExhausted", 9: "FailedPrecondition",
+	10: "Aborted", 11: "OutOfRange", 12: "Unimplemented", 13: "Internal", 14: "Unavailable",
+	15: "DataLoss", 16: "Unauthenticated",
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return "Code(" + strconv.FormatUint(uint64(c), 10) + ")"
+}
+
+// Error is the status of a failed call. The methods of Service return it to set the status
+// code, other errors are returned with CodeUnknown.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Code.String() + ": " + e.Message
+}
+
+func errorf(code Code, format string, args ...interface{}) error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// status returns the code and the message of the status of err
+func status(err error) (Code, string) {
+	if err == nil {
+		return CodeOK, ""
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code, e.Message
+	}
+	return CodeUnknown, err.Error()
+}
+
+// statusError returns the error of the values of the grpc-status and grpc-message trailers
+func statusError(code, message string) error {
+	if code == "" {
+		return errors.New("the response has no grpc-status")
+	}
+	c, err := strconv.ParseUint(code, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid grpc-status %q", code)
+	}
+	if c == 0 {
+		return nil
+	}
+	if m, err := url.PathUnescape(message); err == nil {
+		message = m
+	}
+	return &Error{Code: Code(c), Message: message}
+}
+
+// encodeStatusMessage percent-encodes a status message for the grpc-message trailer
+func encodeStatusMessage(message string) string {
+	var sb strings.Builder
+	for i := 0; i < len(message); i++ {
+		if b := message[i]; b >= 0x20 && b <= 0x7e && b != '%' {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+// writeMessage writes a length-prefixed message, uncompressed
+func writeMessage(w io.Writer, m []byte) error {
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(m)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(m)
+	return err
+}
+
+// readMessage reads a length-prefixed message. It returns io.EOF at the end of the stream.
+func readMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errorf(CodeInvalidArgument, "truncated message prefix")
+		}
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, errorf(CodeUnimplemented, "compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > maxMessageSize {
+		return nil, errorf(CodeInvalidArgument, "message of %d bytes exceeds the maximum of %d", n, maxMessageSize)
+	}
+	m := make([]byte, n)
+	if _, err := io.ReadFull(r, m); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errorf(CodeInvalidArgument, "truncated message")
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+// message is a protobuf message of compiler.proto
+type message interface {
+	marshal() []byte
+	unmarshal(b []byte) error
+}
+
+// The protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// encoder appends the fields of a protobuf message. The singular fields are omitted when they
+// hold their default value, like proto3 does.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) tag(num int, typ int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(num)<<3|uint64(typ))
+}
+
+// uint appends a uint64 field, or an int64 one as its two's complement
+func (e *encoder) uint(num int, v uint64) {
+	if v != 0 {
+		e.tag(num, wireVarint)
+		e.buf = binary.AppendUvarint(e.buf, v)
+	}
+}
+
+func (e *encoder) bytes(num int, b []byte) {
+	if len(b) != 0 {
+		e.field(num, b)
+	}
+}
+
+func (e *encoder) string(num int, s string) {
+	e.bytes(num, []byte(s))
+}
+
+// field appends a length-delimited field even if it's empty, as an element of a repeated field
+func (e *encoder) field(num int, b []byte) {
+	e.tag(num, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// packed appends a packed repeated uint64 field
+func (e *encoder) packed(num int, vs []uint64) {
+	if len(vs) == 0 {
+		return
+	}
+	var b []byte
+	for _, v := range vs {
+		b = binary.AppendUvarint(b, v)
+	}
+	e.field(num, b)
+}
+
+// field is a field of a protobuf message, holding a varint or fixed value in v, or the bytes of
+// a length-delimited value in data
+type field struct {
+	num  int
+	typ  int
+	v    uint64
+	data []byte
+}
+
+var errMalformed = errors.New("malformed protobuf message")
+
+// parseMessage calls f on each field of a protobuf message. The fields unknown to f are
+// skipped by it, so that the messages can be extended.
+func parseMessage(b []byte, f func(fd field) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 || tag>>3 == 0 {
+			return errMalformed
+		}
+		b = b[n:]
+		fd := field{num: int(tag >> 3), typ: int(tag & 7)}
+		switch fd.typ {
+		case wireVarint:
+			if fd.v, n = binary.Uvarint(b); n <= 0 {
+				return errMalformed
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errMalformed
+			}
+			fd.v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errMalformed
+			}
+			fd.v, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return errMalformed
+			}
+			fd.data, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", fd.typ)
+		}
+		if err := f(fd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unpack appends the values of a packed, or unpacked, repeated uint64 field
+func unpack(vs []uint64, fd field) ([]uint64, error) {
+	if fd.typ == wireVarint {
+		return append(vs, fd.v), nil
+	}
+	for b := fd.data; len(b) > 0; {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errMalformed
+		}
+		vs, b = append(vs, v), b[n:]
+	}
+	return vs, nil
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+// The protobuf encoding of the messages, with the field numbers of compiler.proto
+
+func (fd field) is(num int, typ int) bool {
+	return fd.num == num && fd.typ == typ
+}
+
+func (a *CompileArgs) marshal() []byte {
+	var e encoder
+	e.string(1, a.Circuit)
+	return e.buf
+}
+
+func (a *CompileArgs) unmarshal(b []byte) error {
+	return parseMessage(b, func(fd field) error {
+		if fd.is(1, wireBytes) {
+			a.Circuit = string(fd.data)
+		}
+		return nil
+	})
+}
+
+func (r *CompileReply) marshal() []byte {
+	var e encoder
+	e.bytes(1, r.ContentHash[:])
+	e.string(2, r.CircuitId)
+	e.string(3, r.SolverId)
+	e.uint(4, uint64(r.CircuitSize))
+	e.uint(5, uint64(r.SolverSize))
+	for _, s := range r.PublicInputs {
+		e.field(6, []byte(s))
+	}
+	return e.buf
+}
+
+func (r *CompileReply) unmarshal(b []byte) error {
+	return parseMessage(b, func(fd field) error {
+		switch {
+		case fd.is(1, wireBytes):
+			if len(fd.data) != len(r.ContentHash) {
+				return fmt.Errorf("content hash of %d bytes", len(fd.data))
+			}
+			copy(r.ContentHash[:], fd.data)
+		case fd.is(2, wireBytes):
+			r.CircuitId = string(fd.data)
+		case fd.is(3, wireBytes):
+			r.SolverId = string(fd.data)
+		case fd.is(4, wireVarint):
+			r.CircuitSize = int64(fd.v)
+		case fd.is(5, wireVarint):
+			r.SolverSize = int64(fd.v)
+		case fd.is(6, wireBytes):
+			r.PublicInputs = append(r.PublicInputs, string(fd.data))
+		}
+		return nil
+	})
+}
+
+func (a *SolveArgs) marshal() []byte {
+	var e encoder
+	e.string(1, a.Circuit)
+	e.bytes(2, a.Assignments)
+	e.string(3, a.Format)
+	return e.buf
+}
+
+func (a *SolveArgs) unmarshal(b []byte) error {
+	return parseMessage(b, func(fd field) error {
+		switch {
+		case fd.is(1, wireBytes):
+			a.Circuit = string(fd.data)
+		case fd.is(2, wireBytes):
+			a.Assignments = fd.data
+		case fd.is(3, wireBytes):
+			a.Format = string(fd.data)
+		}
+		return nil
+	})
+}
+
+func (r *SolveReply) marshal() []byte {
+	var e encoder
+	e.string(1, r.WitnessId)
+	e.uint(2, uint64(r.Size))
+	e.uint(3, uint64(r.NumWitnesses))
+	return e.buf
+}
+
+func (r *SolveReply) unmarshal(b []byte) error {
+	return parseMessage(b, func(fd field) error {
+		switch {
+		case fd.is(1, wireBytes):
+			r.WitnessId = string(fd.data)
+		case fd.is(2, wireVarint):
+			r.Size = int64(fd.v)
+		case fd.is(3, wireVarint):
+			r.NumWitnesses = int(fd.v)
+		}
+		return nil
+	})
+}
+
+func (a *StatsArgs) marshal() []byte {
+	var e encoder
+	e.string(1, a.Circuit)
+	return e.buf
+}
+
+func (a *StatsArgs) unmarshal(b []byte) error {
+	return parseMessage(b, func(fd field) error {
+		if fd.is(1, wireBytes) {
+			a.Circuit = string(fd.data)
+		}
+		return nil
+	})
+}
+
+// stats is the Stats message of layered.Stats
+type stats struct {
+	*layered.Stats
+}
+
+func (s stats) marshal() []byte {
+	var e encoder
+	e.uint(1, uint64(s.NumLayers))
+	for _, l := range s.Layers {
+		var le encoder
+		le.uint(1, l.InputLen)
+		le.uint(2, l.OutputLen)
+		le.uint(3, l.NumMul)
+		le.uint(4, l.NumAdd)
+		le.uint(5, l.NumCst)
+		le.uint(6, l.NumCustom)
+		le.uint(7, l.UsedOutput)
+		le.uint(8, l.WastedGates)
+		e.field(2, le.buf)
+	}
+	e.uint(3, s.MaxWidth)
+	e.uint(4, s.PaddingWires)
+	e.uint(5, s.WastedGates)
+	e.packed(6, s.Instances)
+	return e.buf
+}
+
+func (s stats) unmarshal(b []byte) error {
+	return parseMessage(b, func(fd field) (err error) {
+		switch {
+		case fd.is(1, wireVarint):
+			s.NumLayers = int(fd.v)
+		case fd.is(2, wireBytes):
+			var l layered.LayerStats
+			values := []*uint64{&l.InputLen, &l.OutputLen, &l.NumMul, &l.NumAdd, &l.NumCst, &l.NumCustom, &l.UsedOutput, &l.WastedGates}
+			err = parseMessage(fd.data, func(fd field) error {
+				if fd.typ == wireVarint && fd.num <= len(values) {
+					*values[fd.num-1] = fd.v
+				}
+				return nil
+			})
+			s.Layers = append(s.Layers, l)
+		case fd.is(3, wireVarint):
+			s.MaxWidth = fd.v
+		case fd.is(4, wireVarint):
+			s.PaddingWires = fd.v
+		case fd.is(5, wireVarint):
+			s.WastedGates = fd.v
+		case fd.is(6, wireBytes), fd.is(6, wireVarint):
+			s.Instances, err = unpack(s.Instances, fd)
+		}
+		return err
+	})
+}
+
+func (a *FetchArgs) marshal() []byte {
+	var e encoder
+	e.string(1, a.Id)
+	e.uint(2, uint64(a.Offset))
+	return e.buf
+}
+
+func (a *FetchArgs) unmarshal(b []byte) error {
+	return parseMessage(b, func(fd field) error {
+		switch {
+		case fd.is(1, wireBytes):
+			a.Id = string(fd.data)
+		case fd.is(2, wireVarint):
+			a.Offset = int64(fd.v)
+		}
+		return nil
+	})
+}
+
+// chunk is the Chunk message streamed by Fetch
+type chunk struct {
+	data []byte
+}
+
+func (c *chunk) marshal() []byte {
+	var e encoder
+	e.bytes(1, c.data)
+	return e.buf
+}
+
+func (c *chunk) unmarshal(b []byte) error {
+	return parseMessage(b, func(fd field) error {
+		if fd.is(1, wireBytes) {
+			c.data = fd.data
+		}
+		return nil
+	})
+}
+
+func (a *DeleteArgs) marshal() []byte {
+	var e encoder
+	e.string(1, a.WitnessId)
+	return e.buf
+}
+
+func (a *DeleteArgs) unmarshal(b []byte) error {
+	return parseMessage(b, func(fd field) error {
+		if fd.is(1, wireBytes) {
+			a.WitnessId = string(fd.data)
+		}
+		return nil
+	})
+}
+
+// deleteReply is the empty DeleteReply message
+type deleteReply struct{}
+
+func (deleteReply) marshal() []byte { return nil }
+
+func (deleteReply) unmarshal(b []byte) error {
+	return parseMessage(b, func(field) error { return nil })
+}
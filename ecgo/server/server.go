@@ -0,0 +1,547 @@
+// Package server implements a compile service, which compiles registered circuits and solves
+// their witnesses for remote provers, so that a fleet of provers can share a single compiler.
+//
+// The service is served over gRPC, with the interface of compiler.proto, so that the provers
+// can use the gRPC stubs of their language. Since gRPC isn't a dependency of the module, the
+// server and the Go Client implement the parts of it they use, unencrypted HTTP/2 with prior
+// knowledge, over net/http. The artifacts, i.e. the layered circuits, input solvers and
+// witnesses, are held by the server and streamed by Fetch in chunks of ChunkSize bytes, so that
+// no single message holds a large artifact. Witnesses are removed once fetched, deleted by
+// Delete, or expire after Service.WitnessTTL.
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/registry"
+	"github.com/consensys/gnark/frontend"
+)
+
+// ChunkSize is the maximum size of the chunks of an artifact streamed by Fetch.
+const ChunkSize = 1 << 20
+
+// DefaultWitnessTTL is the default of Service.WitnessTTL.
+const DefaultWitnessTTL = 10 * time.Minute
+
+// The assignment formats of SolveArgs
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// CompileArgs are the arguments of the Compile method.
+type CompileArgs struct {
+	// Circuit is the name of the registered circuit.
+	Circuit string
+}
+
+// CompileReply describes a compiled circuit. Its artifacts are fetched with the Fetch method.
+type CompileReply struct {
+	// ContentHash identifies the layered circuit and its input solver, see
+	// ecgo.CompileResult.ContentHash.
+	ContentHash [32]byte
+	// CircuitId and SolverId are the artifact ids of the serialized layered circuit and input
+	// solver.
+	CircuitId, SolverId string
+	// CircuitSize and SolverSize are the sizes of the artifacts in bytes.
+	CircuitSize, SolverSize int64
+	// PublicInputs is the layout of the public inputs, see ecgo.CompileResult.PublicInputLayout.
+	PublicInputs []string
+}
+
+// SolveArgs are the arguments of the Solve method.
+type SolveArgs struct {
+	Circuit string
+	// Assignments holds the assignments of the circuit in Format, as read by
+	// irwg.ParseAssignmentsJSON or irwg.ParseAssignmentsCSV.
+	Assignments []byte
+	Format      string
+}
+
+// SolveReply describes a solved witness. The witness is removed from the server once it has
+// been fetched to its end, or deleted, or when it expires.
+type SolveReply struct {
+	WitnessId    string
+	Size         int64
+	NumWitnesses int
+}
+
+// StatsArgs are the arguments of the Stats method.
+type StatsArgs struct {
+	Circuit string
+}
+
+// FetchArgs are the arguments of the Fetch method, requesting an artifact from Offset.
+type FetchArgs struct {
+	Id     string
+	Offset int64
+}
+
+// DeleteArgs are the arguments of the Delete method.
+type DeleteArgs struct {
+	// WitnessId is the id of the witness returned by Solve.
+	WitnessId string
+}
+
+// artifact is an artifact held by the server. Witnesses are removed once fetched or at expires,
+// while the artifacts of compiled circuits are kept.
+type artifact struct {
+	data    []byte
+	witness bool
+	expires time.Time
+}
+
+// compiled holds the artifacts of a compiled circuit
+type compiled struct {
+	circuit []byte
+	solver  *irwg.RootCircuit
+	stats   *layered.Stats
+	reply   CompileReply
+}
+
+// entry is a circuit compiled at most once, by the first request
+type entry struct {
+	once sync.Once
+	res  *compiled
+	err  error
+}
+
+// Service holds the compiled circuits and the artifacts of a server. Its methods are the RPC
+// methods of the Compiler service of compiler.proto, served by ServeHTTP.
+type Service struct {
+	// WitnessTTL is how long a solved witness is held without being fetched. Expired witnesses
+	// are removed by the next call of Solve or Fetch. It must be set before serving.
+	WitnessTTL time.Duration
+
+	// compile compiles a registered circuit and now returns the time, both replaced by tests
+	compile func(c registry.Circuit) (*compiled, error)
+	now     func() time.Time
+
+	mu        sync.Mutex
+	circuits  map[string]*entry
+	artifacts map[string]*artifact
+	witnesses int
+}
+
+// NewService returns a service compiling the circuits of the registry.
+func NewService() *Service {
+	return &Service{
+		WitnessTTL: DefaultWitnessTTL,
+		compile:    compileCircuit,
+		now:        time.Now,
+		circuits:   make(map[string]*entry),
+		artifacts:  make(map[string]*artifact),
+	}
+}
+
+// removeExpired removes the expired witnesses. s.mu must be held.
+func (s *Service) removeExpired() {
+	now := s.now()
+	for id, a := range s.artifacts {
+		if a.witness && now.After(a.expires) {
+			delete(s.artifacts, id)
+		}
+	}
+}
+
+func compileCircuit(c registry.Circuit) (*compiled, error) {
+	res, err := ecgo.Compile(c.Field, c.New(), c.Options...)
+	if err != nil {
+		return nil, err
+	}
+	return &compiled{
+		circuit: res.GetLayeredCircuit().Serialize(),
+		solver:  res.GetInputSolver(),
+		stats:   res.Stats(),
+		reply:   CompileReply{ContentHash: res.ContentHash(), PublicInputs: res.PublicInputLayout()},
+	}, nil
+}
+
+// circuit returns the compiled circuit of the given name, compiling it on the first call
+func (s *Service) circuit(name string) (*compiled, error) {
+	c, ok := registry.Get(name)
+	if !ok {
+		return nil, errorf(CodeNotFound, "unknown circuit %q, registered circuits: %s", name, strings.Join(registry.Names(), ", "))
+	}
+	s.mu.Lock()
+	e, ok := s.circuits[name]
+	if !ok {
+		e = &entry{}
+		s.circuits[name] = e
+	}
+	s.mu.Unlock()
+	e.once.Do(func() {
+		e.res, e.err = s.compile(c)
+		if e.err != nil {
+			e.err = fmt.Errorf("compile %s: %w", name, e.err)
+			return
+		}
+		solver := e.res.solver.Serialize()
+		hash := sha256.Sum256(append(e.res.circuit[:len(e.res.circuit):len(e.res.circuit)], solver...))
+		prefix := name + "/" + hex.EncodeToString(hash[:8])
+		r := &e.res.reply
+		r.CircuitId, r.CircuitSize = prefix+"/circuit", int64(len(e.res.circuit))
+		r.SolverId, r.SolverSize = prefix+"/inputsolver", int64(len(solver))
+		s.mu.Lock()
+		s.artifacts[r.CircuitId] = &artifact{data: e.res.circuit}
+		s.artifacts[r.SolverId] = &artifact{data: solver}
+		s.mu.Unlock()
+	})
+	return e.res, e.err
+}
+
+// Compile compiles the circuit, if it isn't compiled yet, and describes its artifacts.
+func (s *Service) Compile(args *CompileArgs, reply *CompileReply) error {
+	c, err := s.circuit(args.Circuit)
+	if err != nil {
+		return err
+	}
+	*reply = c.reply
+	return nil
+}
+
+// Solve solves the witness of the assignments with the input solver of the circuit. The hints
+// are called on the server, so they must be registered in its process.
+func (s *Service) Solve(args *SolveArgs, reply *SolveReply) error {
+	c, err := s.circuit(args.Circuit)
+	if err != nil {
+		return err
+	}
+	rc, _ := registry.Get(args.Circuit)
+	var assignments []frontend.Circuit
+	switch args.Format {
+	case FormatJSON, "":
+		assignments, err = irwg.ParseAssignmentsJSON(rc.New, args.Assignments)
+	case FormatCSV:
+		assignments, err = irwg.ParseAssignmentsCSV(rc.New, bytes.NewReader(args.Assignments))
+	default:
+		return errorf(CodeInvalidArgument, "unknown assignment format %q", args.Format)
+	}
+	if err != nil {
+		return err
+	}
+	var w *irwg.Witness
+	switch len(assignments) {
+	case 0:
+		return errors.New("no assignments")
+	case 1:
+		w, err = c.solver.SolveInputAuto(assignments[0])
+	default:
+		w, err = c.solver.SolveInputs(assignments)
+	}
+	if err != nil {
+		return err
+	}
+	buf := w.Serialize()
+	s.mu.Lock()
+	s.removeExpired()
+	s.witnesses++
+	id := fmt.Sprintf("%s/witness/%d", args.Circuit, s.witnesses)
+	s.artifacts[id] = &artifact{data: buf, witness: true, expires: s.now().Add(s.WitnessTTL)}
+	s.mu.Unlock()
+	*reply = SolveReply{WitnessId: id, Size: int64(len(buf)), NumWitnesses: w.NumWitnesses}
+	return nil
+}
+
+// Stats returns the statistics of the layered circuit, compiling it if needed.
+func (s *Service) Stats(args *StatsArgs, reply *layered.Stats) error {
+	c, err := s.circuit(args.Circuit)
+	if err != nil {
+		return err
+	}
+	*reply = *c.stats
+	return nil
+}
+
+// Fetch streams an artifact from args.Offset to send, in chunks of ChunkSize bytes. A witness
+// is removed once it has been sent to its end, and its expiry is postponed by the fetch, so that
+// it can be fetched again from an offset if the stream fails.
+func (s *Service) Fetch(args *FetchArgs, send func(chunk []byte) error) error {
+	s.mu.Lock()
+	s.removeExpired()
+	a, ok := s.artifacts[args.Id]
+	if ok && a.witness {
+		a.expires = s.now().Add(s.WitnessTTL)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return errorf(CodeNotFound, "unknown artifact %q", args.Id)
+	}
+	buf := a.data
+	if args.Offset < 0 || args.Offset > int64(len(buf)) {
+		return errorf(CodeOutOfRange, "offset %d out of the %d bytes of %s", args.Offset, len(buf), args.Id)
+	}
+	for i := int(args.Offset); i < len(buf); i += ChunkSize {
+		if err := send(buf[i:min(i+ChunkSize, len(buf))]); err != nil {
+			return err
+		}
+	}
+	if a.witness {
+		s.mu.Lock()
+		if s.artifacts[args.Id] == a {
+			delete(s.artifacts, args.Id)
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// Delete removes a witness which won't be fetched.
+func (s *Service) Delete(args *DeleteArgs, reply *struct{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.artifacts[args.WitnessId]
+	if !ok || !a.witness {
+		return errorf(CodeNotFound, "unknown witness %q", args.WitnessId)
+	}
+	delete(s.artifacts, args.WitnessId)
+	return nil
+}
+
+// methods are the handlers of the methods of the service, which decode the request and pass
+// the messages of the response to send
+var methods = map[string]func(s *Service, req []byte, send func(m message) error) error{
+	"Compile": func(s *Service, req []byte, send func(m message) error) error {
+		var args CompileArgs
+		var reply CompileReply
+		if err := args.unmarshal(req); err != nil {
+			return errorf(CodeInvalidArgument, "%v", err)
+		}
+		if err := s.Compile(&args, &reply); err != nil {
+			return err
+		}
+		return send(&reply)
+	},
+	"Solve": func(s *Service, req []byte, send func(m message) error) error {
+		var args SolveArgs
+		var reply SolveReply
+		if err := args.unmarshal(req); err != nil {
+			return errorf(CodeInvalidArgument, "%v", err)
+		}
+		if err := s.Solve(&args, &reply); err != nil {
+			return err
+		}
+		return send(&reply)
+	},
+	"Stats": func(s *Service, req []byte, send func(m message) error) error {
+		var args StatsArgs
+		var reply layered.Stats
+		if err := args.unmarshal(req); err != nil {
+			return errorf(CodeInvalidArgument, "%v", err)
+		}
+		if err := s.Stats(&args, &reply); err != nil {
+			return err
+		}
+		return send(stats{&reply})
+	},
+	"Fetch": func(s *Service, req []byte, send func(m message) error) error {
+		var args FetchArgs
+		if err := args.unmarshal(req); err != nil {
+			return errorf(CodeInvalidArgument, "%v", err)
+		}
+		return s.Fetch(&args, func(data []byte) error { return send(&chunk{data: data}) })
+	},
+	"Delete": func(s *Service, req []byte, send func(m message) error) error {
+		var args DeleteArgs
+		if err := args.unmarshal(req); err != nil {
+			return errorf(CodeInvalidArgument, "%v", err)
+		}
+		if err := s.Delete(&args, &struct{}{}); err != nil {
+			return err
+		}
+		return send(deleteReply{})
+	},
+}
+
+// ServeHTTP serves a gRPC call of the service. The status of the call is sent in the trailers of
+// the response.
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "the compile service only serves gRPC", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	code, msg := status(s.call(w, r))
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(int(code)))
+	if msg != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", encodeStatusMessage(msg))
+	}
+}
+
+// call reads the request of a call and writes the messages of its response
+func (s *Service) call(w http.ResponseWriter, r *http.Request) error {
+	name, ok := strings.CutPrefix(r.URL.Path, "/"+serviceName+"/")
+	method := methods[name]
+	if !ok || method == nil {
+		return errorf(CodeUnimplemented, "unknown method %s", r.URL.Path)
+	}
+	req, err := readMessage(r.Body)
+	if err == io.EOF {
+		return errorf(CodeInvalidArgument, "missing request message")
+	} else if err != nil {
+		return err
+	}
+	if _, err := readMessage(r.Body); err != io.EOF {
+		return errorf(CodeInvalidArgument, "expected a single request message")
+	}
+	flusher, _ := w.(http.Flusher)
+	return method(s, req, func(m message) error {
+		if err := writeMessage(w, m.marshal()); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+}
+
+// h2c returns the protocols of the service, unencrypted HTTP/2 with prior knowledge, like the
+// plaintext connections of gRPC
+func h2c() *http.Protocols {
+	var p http.Protocols
+	p.SetUnencryptedHTTP2(true)
+	return &p
+}
+
+// Serve serves the service on connections accepted by l. It returns when l is closed.
+func Serve(s *Service, l net.Listener) error {
+	srv := &http.Server{Handler: s, Protocols: h2c()}
+	if err := srv.Serve(l); !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+// Client calls a remote compile service.
+type Client struct {
+	addr      string
+	transport *http.Transport
+}
+
+// Dial returns a client of the service listening at addr. The connection is opened by the
+// first call, and reopened by the next one if it fails.
+func Dial(addr string) (*Client, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, err
+	}
+	return &Client{addr: addr, transport: &http.Transport{Protocols: h2c()}}, nil
+}
+
+// call calls a method of the service and passes the messages of the response to recv
+func (c *Client) call(method string, req message, recv func(m []byte) error) error {
+	var body bytes.Buffer
+	if err := writeMessage(&body, req.marshal()); err != nil {
+		return err
+	}
+	hr, err := http.NewRequest(http.MethodPost, "http://"+c.addr+"/"+serviceName+"/"+method, &body)
+	if err != nil {
+		return err
+	}
+	hr.Header.Set("Content-Type", "application/grpc")
+	hr.Header.Set("Te", "trailers")
+	resp, err := c.transport.RoundTrip(hr)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected HTTP status %s", method, resp.Status)
+	}
+	// a call failing before any message may send its status in the headers
+	if code := resp.Header.Get("Grpc-Status"); code != "" {
+		return statusError(code, resp.Header.Get("Grpc-Message"))
+	}
+	for {
+		m, err := readMessage(resp.Body)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := recv(m); err != nil {
+			return err
+		}
+	}
+	return statusError(resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message"))
+}
+
+// unary calls a method of the service returning a single message
+func (c *Client) unary(method string, req message, reply message) error {
+	n := 0
+	err := c.call(method, req, func(m []byte) error {
+		if n++; n > 1 {
+			return fmt.Errorf("%s: more than one reply", method)
+		}
+		return reply.unmarshal(m)
+	})
+	if err == nil && n == 0 {
+		err = fmt.Errorf("%s: missing reply", method)
+	}
+	return err
+}
+
+// Compile compiles the registered circuit of the given name on the server.
+func (c *Client) Compile(circuit string) (*CompileReply, error) {
+	var reply CompileReply
+	if err := c.unary("Compile", &CompileArgs{Circuit: circuit}, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// Solve solves the witness of the assignments, in the given format, on the server.
+func (c *Client) Solve(circuit string, assignments []byte, format string) (*SolveReply, error) {
+	var reply SolveReply
+	if err := c.unary("Solve", &SolveArgs{Circuit: circuit, Assignments: assignments, Format: format}, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// Stats returns the statistics of the layered circuit of the registered circuit.
+func (c *Client) Stats(circuit string) (*layered.Stats, error) {
+	var reply layered.Stats
+	if err := c.unary("Stats", &StatsArgs{Circuit: circuit}, stats{&reply}); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// Fetch writes the artifact of the given id, streamed by the server, to w.
+func (c *Client) Fetch(id string, w io.Writer) error {
+	return c.call("Fetch", &FetchArgs{Id: id}, func(m []byte) error {
+		var ch chunk
+		if err := ch.unmarshal(m); err != nil {
+			return err
+		}
+		_, err := w.Write(ch.data)
+		return err
+	})
+}
+
+// Delete removes the witness of the given id from the server, if it won't be fetched.
+func (c *Client) Delete(witnessId string) error {
+	return c.unary("Delete", &DeleteArgs{WitnessId: witnessId}, deleteReply{})
+}
+
+// Close closes the connections to the service.
+func (c *Client) Close() error {
+	c.transport.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/registry"
+	"github.com/consensys/gnark/frontend"
+)
+
+type identityCircuit struct {
+	X frontend.Variable
+}
+
+func (c *identityCircuit) Define(api frontend.API) error {
+	return nil
+}
+
+func TestService(t *testing.T) {
+	registry.Register(registry.Circuit{Name: "server_identity", Field: m31.ScalarField, New: func() frontend.Circuit { return &identityCircuit{} }})
+	circuit := bytes.Repeat([]byte("layered"), ChunkSize/2)
+	compiles := 0
+	s := NewService()
+	s.compile = func(c registry.Circuit) (*compiled, error) {
+		compiles++
+		return &compiled{
+			circuit: circuit,
+			solver: &irwg.RootCircuit{
+				Circuits: map[uint64]*irwg.Circuit{0: {Outputs: []int{1}, NumInputs: 1}},
+				Field:    &m31.Field{},
+			},
+			stats: &layered.Stats{NumLayers: 3, Layers: []layered.LayerStats{{InputLen: 2}, {}, {NumMul: 5}}, Instances: []uint64{1, 0, 7}},
+		}, nil
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go Serve(s, l)
+	c, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	res, err := c.Compile("server_identity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.CircuitSize != int64(len(circuit)) {
+		t.Fatalf("unexpected circuit size %d", res.CircuitSize)
+	}
+	var buf bytes.Buffer
+	if err := c.Fetch(res.CircuitId, &buf); err != nil || !bytes.Equal(buf.Bytes(), circuit) {
+		t.Fatalf("unexpected circuit, error %v", err)
+	}
+	if stats, err := c.Stats("server_identity"); err != nil || stats.NumLayers != 3 || len(stats.Layers) != 3 ||
+		stats.Layers[0].InputLen != 2 || stats.Layers[2].NumMul != 5 || !slices.Equal(stats.Instances, []uint64{1, 0, 7}) {
+		t.Fatalf("unexpected stats %v, error %v", stats, err)
+	}
+	if compiles != 1 {
+		t.Fatalf("expected a single compilation, got %d", compiles)
+	}
+
+	w, err := c.Solve("server_identity", []byte("X\n3\n4\n"), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := c.Fetch(w.WitnessId, &buf); err != nil || int64(buf.Len()) != w.Size || w.NumWitnesses != 2 {
+		t.Fatalf("unexpected witness %+v, error %v", w, err)
+	}
+	if err := c.Fetch(w.WitnessId, &buf); err == nil {
+		t.Fatal("expected the fetched witness to be removed")
+	}
+
+	if _, err := c.Solve("server_identity", []byte(`{"Y": 1}`), FormatJSON); err == nil || !strings.Contains(err.Error(), "missing value of X") {
+		t.Fatalf("expected an invalid assignment to fail, got %v", err)
+	}
+	var status *Error
+	if _, err := c.Compile("missing"); !errors.As(err, &status) || status.Code != CodeNotFound || !strings.Contains(status.Message, "unknown circuit") {
+		t.Fatalf("expected an unknown circuit to fail, got %v", err)
+	}
+	if err := c.unary("Prove", &CompileArgs{}, deleteReply{}); !errors.As(err, &status) || status.Code != CodeUnimplemented {
+		t.Fatalf("expected an unknown method to fail, got %v", err)
+	}
+}
+
+func TestServiceWitnesses(t *testing.T) {
+	// the artifacts of this circuit have ids containing "/witness/"
+	name := "server/witness/identity"
+	registry.Register(registry.Circuit{Name: name, Field: m31.ScalarField, New: func() frontend.Circuit { return &identityCircuit{} }})
+	s := NewService()
+	s.compile = func(c registry.Circuit) (*compiled, error) {
+		return &compiled{
+			circuit: []byte("layered"),
+			solver: &irwg.RootCircuit{
+				Circuits: map[uint64]*irwg.Circuit{0: {Outputs: []int{1}, NumInputs: 1}},
+				Field:    &m31.Field{},
+			},
+		}, nil
+	}
+	// the clock is read by the goroutines of the server
+	var clock atomic.Int64
+	s.now = func() time.Time { return time.Unix(0, clock.Load()) }
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go Serve(s, l)
+	c, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	res, err := c.Compile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.Fetch(res.CircuitId, io.Discard); err != nil {
+			t.Fatalf("fetch %d of the circuit: %v", i, err)
+		}
+	}
+	if err := c.Delete(res.CircuitId); err == nil {
+		t.Fatal("expected the circuit not to be deleted")
+	}
+
+	deleted, err := c.Solve(name, []byte("X\n3\n"), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(deleted.WitnessId); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Fetch(deleted.WitnessId, io.Discard); err == nil {
+		t.Fatal("expected the deleted witness to be removed")
+	}
+	if err := c.Delete(deleted.WitnessId); err == nil {
+		t.Fatal("expected the witness to be deleted once")
+	}
+
+	expired, err := c.Solve(name, []byte("X\n3\n"), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := c.Solve(name, []byte("X\n4\n"), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(int64(s.WitnessTTL / 2))
+	// a failed stream postpones the expiry of the witness without removing it
+	errStop := errors.New("stop")
+	if err := s.Fetch(&FetchArgs{Id: kept.WitnessId}, func([]byte) error { return errStop }); err != errStop {
+		t.Fatalf("expected the stream to fail, got %v", err)
+	}
+	clock.Add(int64(s.WitnessTTL/2 + time.Second))
+	if err := c.Fetch(expired.WitnessId, io.Discard); err == nil {
+		t.Fatal("expected the unfetched witness to expire")
+	}
+	if err := c.Fetch(kept.WitnessId, io.Discard); err != nil {
+		t.Fatalf("expected the partially fetched witness to be kept: %v", err)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	// the encoding of FetchArgs{Id: "a", Offset: 300} by protoc, with an unknown field 3
+	b := []byte{0x0a, 0x01, 'a', 0x10, 0xac, 0x02, 0x1a, 0x01, 'x'}
+	var args FetchArgs
+	if err := args.unmarshal(b); err != nil || args != (FetchArgs{Id: "a", Offset: 300}) {
+		t.Fatalf("unexpected arguments %+v, error %v", args, err)
+	}
+	if m := args.marshal(); !bytes.Equal(m, b[:6]) {
+		t.Fatalf("unexpected encoding %x", m)
+	}
+	if err := args.unmarshal(b[:5]); err == nil {
+		t.Fatal("expected a truncated message to fail")
+	}
+	reply := CompileReply{ContentHash: [32]byte{1}, PublicInputs: []string{"", "P"}}
+	var decoded CompileReply
+	if err := decoded.unmarshal(reply.marshal()); err != nil || !slices.Equal(decoded.PublicInputs, reply.PublicInputs) || decoded.ContentHash != reply.ContentHash {
+		t.Fatalf("unexpected reply %+v, error %v", decoded, err)
+	}
+}
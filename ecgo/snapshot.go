@@ -0,0 +1,189 @@
+package ecgo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/rust"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+	"github.com/consensys/gnark/logger"
+)
+
+// snapshotFormatVersion must be changed whenever the content of snapshots changes
+const snapshotFormatVersion = 1
+
+// Phases after which a snapshot is written, in order
+const (
+	snapshotBuilt     = "built"
+	snapshotOptimized = "optimized"
+	snapshotLayered   = "layered"
+)
+
+// snapshotManifest describes the last snapshot of a compilation. It's written after the files
+// of the snapshot, so that it never refers to a partial snapshot.
+type snapshotManifest struct {
+	Key   string `json:"key"`
+	Phase string `json:"phase"`
+	// version of the Rust compiler that layered the circuit
+	CompilerVersion string              `json:"compilerVersion,omitempty"`
+	PublicLayout    []string            `json:"publicLayout"`
+	PublicOrder     []int               `json:"publicOrder"`
+	Tags            []builder.OutputTag `json:"tags"`
+	Features        layered.Features    `json:"features,omitempty"`
+	SkippedBooleans int                 `json:"skippedBooleans,omitempty"`
+}
+
+// snapshots are the snapshots of a compilation in dir
+type snapshots struct {
+	dir string
+	key string
+}
+
+func (s *snapshots) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// snapshotKey identifies the compilation of circuit. The circuit isn't defined yet, so it's
+// only known by its type and its variables: other changes to it aren't detected.
+func snapshotKey(fieldOrder *big.Int, circuit frontend.Circuit, config *compileConfig) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "ecgo-snapshot-%d\n%s\n%T\n", snapshotFormatVersion, fieldOrder, circuit)
+	if m, ok := circuit.(*multiCircuit); ok {
+		for _, c := range m.Circuits {
+			fmt.Fprintf(h, "%T\n", c)
+		}
+	}
+	_, err := schema.Walk(circuit, irwg.TVariable, func(f schema.LeafInfo, _ reflect.Value) error {
+		fmt.Fprintf(h, "%s %d\n", f.FullName(), f.Visibility)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	// extraction is in the pipeline, but not its parameters
+	fmt.Fprintf(h, "%d %d %v %t %v %v %+v\n", config.extractMinLength, config.extractMinRepeats, config.passes().Names(),
+		config.noDebugPrints, config.publicLayout.slots, config.publicLayout.groups, config.rust)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// load returns the manifest of the last snapshot, or nil if there's none for this compilation
+func (s *snapshots) load() *snapshotManifest {
+	buf, err := os.ReadFile(s.path("manifest.json"))
+	if err != nil {
+		return nil
+	}
+	var m snapshotManifest
+	if err := json.Unmarshal(buf, &m); err != nil || m.Key != s.key {
+		return nil
+	}
+	return &m
+}
+
+// store writes the files of a snapshot, then its manifest
+func (s *snapshots) store(m *snapshotManifest, files map[string][]byte) error {
+	for name, buf := range files {
+		if err := writeFileAtomic(s.path(name), buf); err != nil {
+			return fmt.Errorf("write snapshot: %w", err)
+		}
+	}
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path("manifest.json"), buf); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	return nil
+}
+
+// compileWithSnapshots compiles circuit like CompileContext, writing a snapshot to
+// config.snapshotDir once the circuit is built, optimized and layered, and resuming from the last
+// snapshot of the same compilation.
+func compileWithSnapshots(fieldOrder *big.Int, circuit frontend.Circuit, opt frontend.CompileConfig, config *compileConfig, p *progress) (*CompileResult, error) {
+	log := logger.Logger()
+	if err := os.MkdirAll(config.snapshotDir, 0o755); err != nil {
+		return nil, err
+	}
+	key, err := snapshotKey(fieldOrder, circuit, config)
+	if err != nil {
+		return nil, err
+	}
+	s := &snapshots{dir: config.snapshotDir, key: key}
+
+	var rc *irsource.RootCircuit
+	m := s.load()
+	if m == nil {
+		root, layout, publicOrder, err := defineRoot(fieldOrder, circuit, opt, config, p)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.report("finalize", 0); err != nil {
+			return nil, err
+		}
+		rc = root.Finalize()
+		root.ResetArena()
+		m = &snapshotManifest{Key: key, Phase: snapshotBuilt, PublicLayout: layout, PublicOrder: publicOrder, Tags: root.Tags(), Features: rootFeatures(root), SkippedBooleans: root.SkippedBooleanAssertions()}
+		if err := s.store(m, map[string][]byte{"ir.bin": irsource.SerializeRootCircuit(rc)}); err != nil {
+			return nil, err
+		}
+	} else {
+		log.Info().Str("phase", m.Phase).Msg("resuming compilation from snapshot")
+		buf, err := os.ReadFile(s.path("ir.bin"))
+		if err != nil {
+			return nil, fmt.Errorf("read snapshot: %w", err)
+		}
+		rc = irsource.DeserializeRootCircuit(buf)
+	}
+
+	if m.Phase == snapshotBuilt {
+		if err := optimize(rc, config, p); err != nil {
+			return nil, err
+		}
+		m.Phase = snapshotOptimized
+		if err := s.store(m, map[string][]byte{"ir.bin": irsource.SerializeRootCircuit(rc)}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := config.limits.checkEstimate(rc); err != nil {
+		return nil, err
+	}
+	if err := p.report("layering", numInstructions(rc)); err != nil {
+		return nil, err
+	}
+	var res *CompileResult
+	if m.Phase == snapshotLayered && m.CompilerVersion == rust.Version() {
+		irwgSer, err := os.ReadFile(s.path("irwg.bin"))
+		if err != nil {
+			return nil, fmt.Errorf("read snapshot: %w", err)
+		}
+		lcSer, err := os.ReadFile(s.path("lc.bin"))
+		if err != nil {
+			return nil, fmt.Errorf("read snapshot: %w", err)
+		}
+		res = cachedResult(rc, irwg.DeserializeRootCircuit(irwgSer), lcSer, s.path("lc.bin"), config.lowMemory)
+	} else {
+		solver, lcSer, err := rust.CompileSerialized(rc, config.rust)
+		if err != nil {
+			return nil, err
+		}
+		m.Phase = snapshotLayered
+		m.CompilerVersion = rust.Version()
+		if err := s.store(m, map[string][]byte{"irwg.bin": solver.Serialize(), "lc.bin": lcSer}); err != nil {
+			return nil, err
+		}
+		res = cachedResult(rc, solver, lcSer, s.path("lc.bin"), config.lowMemory)
+	}
+	return finishCompile(res, m.Tags, m.Features, m.SkippedBooleans, config, p, m.PublicLayout, m.PublicOrder)
+}
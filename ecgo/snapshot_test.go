@@ -0,0 +1,59 @@
+package ecgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+)
+
+// compileUntilLayering compiles circuit with snapshots in dir, stopping before the layering, and
+// returns the phases reported
+func compileUntilLayering(t *testing.T, dir string, circuit *estimateCircuit) []string {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var phases []string
+	_, err := CompileContext(ctx, m31.ScalarField, circuit, WithSnapshots(dir), WithProgress(func(p Progress) {
+		if len(phases) == 0 || phases[len(phases)-1] != p.Phase {
+			phases = append(phases, p.Phase)
+		}
+		if p.Phase == "layering" {
+			cancel()
+		}
+	}))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the compilation to stop before the layering, got %v", err)
+	}
+	return phases
+}
+
+func TestSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	phases := compileUntilLayering(t, dir, &estimateCircuit{})
+	if len(phases) < 3 || phases[0] != "define" || phases[len(phases)-1] != "layering" {
+		t.Fatalf("unexpected phases %v", phases)
+	}
+	s := &snapshots{dir: dir}
+	s.key, _ = snapshotKey(m31.ScalarField, &estimateCircuit{}, defaultCompileConfig())
+	m := s.load()
+	if m == nil || m.Phase != snapshotOptimized || len(m.PublicLayout) != 1 || m.PublicLayout[0] != "Y" {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+
+	// the optimized circuit is resumed
+	phases = compileUntilLayering(t, dir, &estimateCircuit{})
+	if len(phases) != 1 || phases[0] != "layering" {
+		t.Fatalf("expected the compilation to resume before the layering, got %v", phases)
+	}
+
+	// the snapshots of another circuit, or with other options, are ignored
+	if k, _ := snapshotKey(m31.ScalarField, &checkCircuit{}, defaultCompileConfig()); k == s.key {
+		t.Fatal("snapshot keys of different circuits are equal")
+	}
+	config := defaultCompileConfig()
+	config.reassociate = true
+	if k, _ := snapshotKey(m31.ScalarField, &estimateCircuit{}, config); k == s.key {
+		t.Fatal("snapshot keys of different options are equal")
+	}
+}
@@ -0,0 +1,189 @@
+// Package solidity generates Solidity contracts to verify the proofs of a compiled circuit on
+// chain. The generated contract pins the content hash of the layered circuit, checks and lays out
+// the public inputs in the order of their slots in the circuit, and forwards the proof to a
+// deployed Expander verifier implementing the IExpanderVerifier interface of the contract.
+package solidity
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo"
+)
+
+// Circuit describes the compiled circuit verified by the contract.
+type Circuit struct {
+	// ContractName is the name of the generated contract.
+	ContractName string
+	// Field is the modulus of the field of the circuit. Public inputs must be reduced.
+	Field *big.Int
+	// ContentHash is the content hash of the layered circuit, see ecgo.CompileResult.ContentHash.
+	ContentHash [32]byte
+	// PublicInputs are the names of the public inputs in the order of their slots, see
+	// ecgo.CompileResult.PublicInputLayout.
+	PublicInputs []string
+}
+
+// FromCompileResult returns the description of a compiled circuit.
+func FromCompileResult(contractName string, field *big.Int, res *ecgo.CompileResult) Circuit {
+	return Circuit{
+		ContractName: contractName,
+		Field:        field,
+		ContentHash:  res.ContentHash(),
+		PublicInputs: res.PublicInputLayout(),
+	}
+}
+
+// ContractName returns a contract name for the circuit with the given name, e.g. "MimcHashVerifier"
+// for "mimc_hash".
+func ContractName(circuitName string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range circuitName {
+		if !isIdentRune(r) || r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	name := sb.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "Circuit" + name
+	}
+	return name + "Verifier"
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '$' || (r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)))
+}
+
+// fieldName returns the Solidity identifier of a public input, as a member of the PublicInputs
+// struct. Characters which can't be in an identifier are replaced by underscores.
+func fieldName(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if isIdentRune(r) {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	s := sb.String()
+	if s == "" || unicode.IsDigit(rune(s[0])) {
+		s = "_" + s
+	}
+	return s
+}
+
+func (c *Circuit) check() error {
+	if c.Field == nil {
+		return fmt.Errorf("missing field")
+	}
+	if c.ContractName == "" || fieldName(c.ContractName) != c.ContractName {
+		return fmt.Errorf("invalid contract name %q", c.ContractName)
+	}
+	names := make(map[string]string, len(c.PublicInputs))
+	for _, name := range c.PublicInputs {
+		f := fieldName(name)
+		if other, ok := names[f]; ok {
+			return fmt.Errorf("public inputs %q and %q have the same Solidity name %s", other, name, f)
+		}
+		names[f] = name
+	}
+	return nil
+}
+
+// Generate writes the Solidity source of the verifier contract of the circuit to w.
+//
+// The contract takes the address of the Expander verifier in its constructor. Its verifyProof
+// function takes the public inputs of each witness of the proof as PublicInputs structs, whose
+// members are the public inputs in slot order, and returns whether the Expander verifier accepts
+// the proof for the flattened public inputs. encodePublicInputs gives that calldata layout: the
+// public inputs of each witness in turn, in slot order, one uint256 each.
+func Generate(w io.Writer, c Circuit) error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	n := len(c.PublicInputs)
+	var sb strings.Builder
+	p := func(format string, args ...interface{}) {
+		fmt.Fprintf(&sb, format, args...)
+		sb.WriteByte('\n')
+	}
+	p("// SPDX-License-Identifier: MIT")
+	p("// Code generated by ExpanderCompilerCollection. DO NOT EDIT.")
+	p("pragma solidity ^0.8.20;")
+	p("")
+	p("/// @notice Verifies the GKR proofs of the Expander prover for registered circuits.")
+	p("interface IExpanderVerifier {")
+	p("    function verify(bytes32 circuitHash, uint256[] calldata publicInputs, bytes calldata proof) external view returns (bool);")
+	p("}")
+	p("")
+	p("/// @notice Verifies the proofs of the circuit with content hash 0x%x.", c.ContentHash)
+	p("contract %s {", c.ContractName)
+	p("    bytes32 public constant CIRCUIT_HASH = 0x%x;", c.ContentHash)
+	p("    uint256 public constant FIELD_MODULUS = %s;", c.Field)
+	p("    uint256 public constant NUM_PUBLIC_INPUTS = %d;", n)
+	p("")
+	p("    IExpanderVerifier public immutable verifier;")
+	p("")
+	if n != 0 {
+		p("    /// @notice The public inputs of a witness, in the order of their slots in the circuit.")
+		p("    struct PublicInputs {")
+		for _, name := range c.PublicInputs {
+			p("        uint256 %s;", fieldName(name))
+		}
+		p("    }")
+		p("")
+		p("    error PublicInputOutOfRange(uint256 index);")
+		p("")
+	}
+	p("    constructor(IExpanderVerifier verifier_) {")
+	p("        verifier = verifier_;")
+	p("    }")
+	p("")
+	if n == 0 {
+		p("    /// @notice Returns whether the proof is valid.")
+		p("    function verifyProof(bytes calldata proof) external view returns (bool) {")
+		p("        return verifier.verify(CIRCUIT_HASH, new uint256[](0), proof);")
+		p("    }")
+		p("}")
+		_, err := io.WriteString(w, sb.String())
+		return err
+	}
+	p("    /// @notice Returns the public inputs of the witnesses as passed to the Expander verifier: the")
+	p("    /// public inputs of each witness in turn, in slot order. Reverts if one isn't reduced.")
+	p("    function encodePublicInputs(PublicInputs[] calldata inputs) public pure returns (uint256[] memory encoded) {")
+	p("        encoded = new uint256[](inputs.length * NUM_PUBLIC_INPUTS);")
+	p("        for (uint256 i = 0; i < inputs.length; i++) {")
+	p("            uint256 o = i * NUM_PUBLIC_INPUTS;")
+	for i, name := range c.PublicInputs {
+		if f := fieldName(name); f != name {
+			p("            encoded[o + %d] = inputs[i].%s; // %s", i, f, name)
+		} else {
+			p("            encoded[o + %d] = inputs[i].%s;", i, f)
+		}
+	}
+	p("        }")
+	p("        for (uint256 i = 0; i < encoded.length; i++) {")
+	p("            if (encoded[i] >= FIELD_MODULUS) {")
+	p("                revert PublicInputOutOfRange(i);")
+	p("            }")
+	p("        }")
+	p("    }")
+	p("")
+	p("    /// @notice Returns whether the proof is valid for the public inputs of its witnesses.")
+	p("    function verifyProof(PublicInputs[] calldata inputs, bytes calldata proof) external view returns (bool) {")
+	p("        return verifier.verify(CIRCUIT_HASH, encodePublicInputs(inputs), proof);")
+	p("    }")
+	p("}")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,70 @@
+package solidity
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+)
+
+func TestContractName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"mimc_hash":  "MimcHashVerifier",
+		"keccak-256": "Keccak256Verifier",
+		"2fa":        "Circuit2faVerifier",
+		"":           "CircuitVerifier",
+	} {
+		if got := ContractName(name); got != expected {
+			t.Errorf("ContractName(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	c := Circuit{
+		ContractName: "SumVerifier",
+		Field:        m31.ScalarField,
+		ContentHash:  [32]byte{0xab, 1},
+		PublicInputs: []string{"Sum", "Y_0", "Y.1"},
+	}
+	var buf bytes.Buffer
+	if err := Generate(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.String()
+	for _, s := range []string{
+		"contract SumVerifier {",
+		"bytes32 public constant CIRCUIT_HASH = 0xab01000000000000000000000000000000000000000000000000000000000000;",
+		"uint256 public constant FIELD_MODULUS = 2147483647;",
+		"uint256 public constant NUM_PUBLIC_INPUTS = 3;",
+		"        uint256 Y_1;\n    }",
+		"encoded[o + 0] = inputs[i].Sum;",
+		"encoded[o + 2] = inputs[i].Y_1; // Y.1",
+	} {
+		if !strings.Contains(src, s) {
+			t.Errorf("missing %q in\n%s", s, src)
+		}
+	}
+	if strings.Count(src, "{") != strings.Count(src, "}") {
+		t.Errorf("unbalanced braces in\n%s", src)
+	}
+
+	c.PublicInputs = nil
+	buf.Reset()
+	if err := Generate(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+	if src := buf.String(); strings.Contains(src, "struct PublicInputs") || !strings.Contains(src, "function verifyProof(bytes calldata proof)") {
+		t.Errorf("unexpected contract without public inputs\n%s", src)
+	}
+
+	c.PublicInputs = []string{"Y_1", "Y.1"}
+	if err := Generate(&buf, c); err == nil || !strings.Contains(err.Error(), "same Solidity name") {
+		t.Errorf("expected a name collision, got %v", err)
+	}
+	c.ContractName = "1Verifier"
+	if err := Generate(&buf, c); err == nil || !strings.Contains(err.Error(), "invalid contract name") {
+		t.Errorf("expected an invalid contract name, got %v", err)
+	}
+}
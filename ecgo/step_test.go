@@ -0,0 +1,45 @@
+package ecgo
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+)
+
+// squareStep maps x to x^2 + y
+type squareStep struct{}
+
+func (squareStep) Step(api frontend.API, state []frontend.Variable, input []frontend.Variable) []frontend.Variable {
+	return []frontend.Variable{api.Add(api.Mul(state[0], state[0]), input[0])}
+}
+
+type stepCircuit struct {
+	X0 frontend.Variable
+	Y  []frontend.Variable
+	Z  frontend.Variable `gnark:",public"`
+}
+
+func (c *stepCircuit) Define(api frontend.API) error {
+	inputs := make([][]frontend.Variable, len(c.Y))
+	for i, y := range c.Y {
+		inputs[i] = []frontend.Variable{y}
+	}
+	api.AssertIsEqual(builder.RunSteps(api, squareStep{}, []frontend.Variable{c.X0}, inputs)[0], c.Z)
+	return nil
+}
+
+func TestRunStepsDepth(t *testing.T) {
+	layers := 0
+	for _, n := range []int{2, 16} {
+		e, err := EstimateResources(m31.ScalarField, &stepCircuit{Y: make([]frontend.Variable, n)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if layers != 0 && e.Layers != layers {
+			t.Fatalf("expected the depth not to depend on the number of steps, got %d and %d layers", layers, e.Layers)
+		}
+		layers = e.Layers
+	}
+}
@@ -0,0 +1,54 @@
+package ecgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+// WriteTags writes the tags as a JSON array of objects with the fields name and output.
+func (c *CompileResult) WriteTags(w io.Writer) error {
+	tags := c.tags
+	if tags == nil {
+		tags = []builder.OutputTag{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tags)
+}
+
+// SerializeVersioned serializes the layered circuit after a header holding its features and its
+// tags, which ReadHeader of layered returns, see layered.RootCircuit.SerializeVersioned.
+func (c *CompileResult) SerializeVersioned() []byte {
+	tags := make([]layered.Tag, len(c.tags))
+	for i, t := range c.tags {
+		tags[i] = layered.Tag{Name: t.Name, Output: t.Output}
+	}
+	return c.GetLayeredCircuit().SerializeVersioned(c.Features(), tags)
+}
+
+// ReadTags reads tags written by CompileResult.WriteTags.
+func ReadTags(r io.Reader) ([]builder.OutputTag, error) {
+	var tags []builder.OutputTag
+	if err := json.NewDecoder(r).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+	return tags, nil
+}
+
+// IRNames names the tagged variables of the root circuit of GetCircuitIr after their tags, for
+// the printers of irsource, e.g. irsource.RootCircuit.Dump and ExplainConstraint.
+func (c *CompileResult) IRNames() irsource.Names {
+	names := irsource.Names{}
+	root := c.GetCircuitIr().Circuits[0]
+	for _, t := range c.tags {
+		if x := root.Outputs[t.Output]; names[x] == "" {
+			names[x] = t.Name
+		}
+	}
+	return names
+}
@@ -0,0 +1,63 @@
+package ecgo
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+func TestWriteTags(t *testing.T) {
+	res := &CompileResult{tags: []builder.OutputTag{{Name: "root_hash", Output: 0}, {Name: "nullifier", Output: 3}}}
+	var buf bytes.Buffer
+	if err := res.WriteTags(&buf); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := ReadTags(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags, res.Tags()) {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+
+	buf.Reset()
+	if err := (&CompileResult{}).WriteTags(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[]\n" {
+		t.Fatalf("unexpected encoding of no tags %q", buf.String())
+	}
+}
+
+func TestIRNames(t *testing.T) {
+	res := &CompileResult{
+		tags: []builder.OutputTag{{Name: "sum", Output: 1}, {Name: "x", Output: 0}, {Name: "again", Output: 2}},
+		irs:  &irsource.RootCircuit{Circuits: map[uint64]*irsource.Circuit{0: {Outputs: []int{1, 3, 3}}}},
+	}
+	if names := res.IRNames(); !reflect.DeepEqual(names, irsource.Names{1: "x", 3: "sum"}) {
+		t.Fatalf("unexpected names %v", names)
+	}
+}
+
+func TestSerializeVersionedTags(t *testing.T) {
+	res := &CompileResult{
+		lc: &layered.RootCircuit{
+			Circuits: []*layered.Circuit{{InputLen: 2, OutputLen: 2}},
+			Layers:   []uint64{0},
+			Field:    m31.ScalarField,
+		},
+		tags: []builder.OutputTag{{Name: "root_hash", Output: 0}, {Name: "nullifier", Output: 1}},
+	}
+	h, _, err := layered.ReadHeader(res.SerializeVersioned())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(h.Tags, []layered.Tag{{Name: "root_hash", Output: 0}, {Name: "nullifier", Output: 1}}) {
+		t.Fatalf("unexpected tags %v", h.Tags)
+	}
+}
@@ -0,0 +1,44 @@
+package test
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo"
+	"github.com/consensys/gnark-crypto/ecc"
+)
+
+func compileHash(t *testing.T, conf *randomCircuitConfig) [32]byte {
+	circuit := newRandomCircuitGenerator(conf).circuit()
+	c, err := ecgo.Compile(ecc.BN254.ScalarField(), circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.New()
+	h.Write(c.GetLayeredCircuit().Serialize())
+	h.Write(c.GetInputSolver().Serialize())
+	var res [32]byte
+	copy(res[:], h.Sum(nil))
+	return res
+}
+
+func TestCompileDeterministic(t *testing.T) {
+	conf := &randomCircuitConfig{
+		seed:       7,
+		scNum:      randRange{10, 20},
+		scInput:    randRange{5, 50},
+		scOutput:   randRange{5, 30},
+		scInsn:     randRange{20, 50},
+		rootInsn:   randRange{30, 200},
+		field:      ecc.BN254.ScalarField(),
+		addPercent: 60,
+		mulPercent: 90,
+		divPercent: 97,
+	}
+	h := compileHash(t, conf)
+	for i := 0; i < 3; i++ {
+		if compileHash(t, conf) != h {
+			t.Fatal("compiling the same circuit twice gave different outputs")
+		}
+	}
+}
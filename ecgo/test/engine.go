@@ -0,0 +1,797 @@
+// Some content of this file is copied from gnark/test/engine.go
+
+package test
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/customgates"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// Engine implements ecgo.API by evaluating every operation on concrete values, without building
+// or layering a circuit. Assertions are checked as soon as they are made, hints and custom gates
+// are called directly, and subcircuit calls are plain function calls, so circuit logic can be
+// unit tested in milliseconds. Lookup queries are checked against their table after the deferred
+// functions have run, and random values are sampled when they are requested.
+//
+// The engine doesn't check anything that's specific to the compiled circuit, like the absence of
+// random values in hints, so a circuit should still be compiled and checked with CheckCircuit.
+type Engine struct {
+	field   *big.Int
+	defers  []func(frontend.API) error
+	tables  []*builder.LookupTable
+	db      map[any]any
+	outputs []*big.Int
+	// challenges drawn by name, see Challenge
+	challenges map[string]*big.Int
+}
+
+var _ ecgo.API = &Engine{}
+var _ frontend.Rangechecker = &Engine{}
+
+// NewEngine returns an engine evaluating circuits over the given field.
+func NewEngine(field *big.Int) *Engine {
+	return &Engine{field: new(big.Int).Set(field)}
+}
+
+// IsSolved runs circuit with the values of assignment in a new engine, see Engine.Run.
+func IsSolved(circuit, assignment frontend.Circuit, field *big.Int) error {
+	return NewEngine(field).Run(circuit, assignment)
+}
+
+// Run calls circuit.Define with the variables set to the values of assignment, then runs the
+// deferred functions and checks the lookup queries. It returns the first assertion that
+// doesn't hold, or any other error raised by the circuit.
+func (e *Engine) Run(circuit, assignment frontend.Circuit) (err error) {
+	e.defers = nil
+	e.tables = nil
+	e.db = make(map[any]any)
+	e.outputs = nil
+	e.challenges = make(map[string]*big.Int)
+
+	c, err := assign(circuit, assignment)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if rErr, ok := r.(error); ok {
+				err = rErr
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+	if err := c.Define(e); err != nil {
+		return fmt.Errorf("define: %w", err)
+	}
+	for i := 0; i < len(e.defers); i++ {
+		if err := e.defers[i](e); err != nil {
+			return fmt.Errorf("defer fn %d: %w", i, err)
+		}
+	}
+	for i, t := range e.tables {
+		if err := e.checkTable(t); err != nil {
+			return fmt.Errorf("lookup table %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Outputs returns the values passed to Output during the last run.
+func (e *Engine) Outputs() []*big.Int {
+	return e.outputs
+}
+
+// assign returns a shallow copy of circuit whose variables are set to the values of assignment
+func assign(circuit, assignment frontend.Circuit) (frontend.Circuit, error) {
+	var values []reflect.Value
+	_, err := schema.Walk(assignment, irwg.TVariable, func(f schema.LeafInfo, v reflect.Value) error {
+		if v.IsNil() {
+			return fmt.Errorf("missing assignment of %s", f.FullName())
+		}
+		values = append(values, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cValue := reflect.ValueOf(circuit).Elem()
+	copied := reflect.New(cValue.Type())
+	copied.Elem().Set(cValue)
+	c := copied.Interface().(frontend.Circuit)
+	i := 0
+	_, err = schema.Walk(c, irwg.TVariable, func(f schema.LeafInfo, v reflect.Value) error {
+		if i >= len(values) {
+			return errors.New("the assignment has less variables than the circuit")
+		}
+		v.Set(values[i])
+		i++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if i != len(values) {
+		return nil, errors.New("the assignment has more variables than the circuit")
+	}
+	return c, nil
+}
+
+func (e *Engine) checkTable(t *builder.LookupTable) error {
+	key := func(row []frontend.Variable) string {
+		var sb strings.Builder
+		for _, x := range row {
+			sb.WriteString(e.toBigInt(x).String())
+			sb.WriteByte(',')
+		}
+		return sb.String()
+	}
+	rows := make(map[string]bool, len(t.Rows()))
+	for _, row := range t.Rows() {
+		rows[key(row)] = true
+	}
+	for i, q := range t.Queries() {
+		if !rows[key(q)] {
+			return fmt.Errorf("query %d %s is not in the table", i, e.format(q))
+		}
+	}
+	return nil
+}
+
+// toBigInt returns the canonical value of v. The result must not be modified.
+func (e *Engine) toBigInt(v frontend.Variable) *big.Int {
+	if b, ok := v.(*big.Int); ok && b.Sign() >= 0 && b.Cmp(e.field) < 0 {
+		return b
+	}
+	b := utils.FromInterface(v)
+	return b.Mod(&b, e.field)
+}
+
+func (e *Engine) mustBeBoolean(name string, b *big.Int) {
+	if !b.IsUint64() || b.Uint64() > 1 {
+		panic(fmt.Sprintf("%s: %s is not boolean", name, b.String()))
+	}
+}
+
+// ---------------------------------------------------------------------------------------------
+// Arithmetic
+
+// Add computes the sum i1+i2+...in and returns the result.
+func (e *Engine) Add(i1, i2 frontend.Variable, in ...frontend.Variable) frontend.Variable {
+	res := new(big.Int).Add(e.toBigInt(i1), e.toBigInt(i2))
+	for _, x := range in {
+		res.Add(res, e.toBigInt(x))
+	}
+	return res.Mod(res, e.field)
+}
+
+// MulAcc computes a + b * c and returns the result.
+func (e *Engine) MulAcc(a, b, c frontend.Variable) frontend.Variable {
+	res := new(big.Int).Mul(e.toBigInt(b), e.toBigInt(c))
+	res.Add(res, e.toBigInt(a))
+	return res.Mod(res, e.field)
+}
+
+// Sub computes the difference between the given variables.
+func (e *Engine) Sub(i1, i2 frontend.Variable, in ...frontend.Variable) frontend.Variable {
+	res := new(big.Int).Sub(e.toBigInt(i1), e.toBigInt(i2))
+	for _, x := range in {
+		res.Sub(res, e.toBigInt(x))
+	}
+	return res.Mod(res, e.field)
+}
+
+// Neg returns -i.
+func (e *Engine) Neg(i frontend.Variable) frontend.Variable {
+	res := new(big.Int).Neg(e.toBigInt(i))
+	return res.Mod(res, e.field)
+}
+
+// Mul computes the product of the given variables.
+func (e *Engine) Mul(i1, i2 frontend.Variable, in ...frontend.Variable) frontend.Variable {
+	res := new(big.Int).Mul(e.toBigInt(i1), e.toBigInt(i2))
+	res.Mod(res, e.field)
+	for _, x := range in {
+		res.Mul(res, e.toBigInt(x))
+		res.Mod(res, e.field)
+	}
+	return res
+}
+
+// DivUnchecked returns i1 / i2, and 0 if both are 0.
+func (e *Engine) DivUnchecked(i1, i2 frontend.Variable) frontend.Variable {
+	b1, b2 := e.toBigInt(i1), e.toBigInt(i2)
+	if b1.Sign() == 0 && b2.Sign() == 0 {
+		return big.NewInt(0)
+	}
+	return e.div("DivUnchecked", b1, b2)
+}
+
+// Div returns i1 / i2.
+func (e *Engine) Div(i1, i2 frontend.Variable) frontend.Variable {
+	return e.div("Div", e.toBigInt(i1), e.toBigInt(i2))
+}
+
+func (e *Engine) div(name string, b1, b2 *big.Int) *big.Int {
+	res := new(big.Int)
+	if res.ModInverse(b2, e.field) == nil {
+		panic(fmt.Sprintf("%s: division by zero", name))
+	}
+	res.Mul(res, b1)
+	return res.Mod(res, e.field)
+}
+
+// Inverse returns 1 / i1.
+func (e *Engine) Inverse(i1 frontend.Variable) frontend.Variable {
+	return e.div("Inverse", big.NewInt(1), e.toBigInt(i1))
+}
+
+// BatchInvert returns the inverses of the elements of i1.
+func (e *Engine) BatchInvert(i1 []frontend.Variable) []frontend.Variable {
+	res := make([]frontend.Variable, len(i1))
+	for i, x := range i1 {
+		res[i] = e.div("BatchInvert", big.NewInt(1), e.toBigInt(x))
+	}
+	return res
+}
+
+// ---------------------------------------------------------------------------------------------
+// Bit operations
+
+// ToBinary unpacks a variable in binary, n is the number of bits of the variable.
+// It panics if the value doesn't fit in n bits.
+func (e *Engine) ToBinary(i1 frontend.Variable, n ...int) []frontend.Variable {
+	nbBits := e.FieldBitLen()
+	if len(n) == 1 {
+		nbBits = n[0]
+		if nbBits < 0 {
+			panic("invalid n")
+		}
+	}
+	b := e.toBigInt(i1)
+	if b.BitLen() > nbBits {
+		panic(fmt.Sprintf("ToBinary: %s doesn't fit in %d bits", b.String(), nbBits))
+	}
+	res := make([]frontend.Variable, nbBits)
+	for i := range res {
+		res[i] = big.NewInt(int64(b.Bit(i)))
+	}
+	return res
+}
+
+// FromBinary packs the given variables, seen as a fieldElement in little endian, into a single variable.
+func (e *Engine) FromBinary(b ...frontend.Variable) frontend.Variable {
+	res := new(big.Int)
+	for i := len(b) - 1; i >= 0; i-- {
+		x := e.toBigInt(b[i])
+		e.mustBeBoolean("FromBinary", x)
+		res.Lsh(res, 1)
+		res.Or(res, x)
+	}
+	return res.Mod(res, e.field)
+}
+
+// word returns the value of x, panicking if it doesn't fit in width bits
+func (e *Engine) word(name string, x frontend.Variable, width int) *big.Int {
+	if width < 0 {
+		panic(fmt.Sprintf("%s: invalid number of bits", name))
+	}
+	b := e.toBigInt(x)
+	if b.BitLen() > width {
+		panic(fmt.Sprintf("%s: %s doesn't fit in %d bits", name, b.String(), width))
+	}
+	return b
+}
+
+// mask returns x truncated to width bits
+func mask(x *big.Int, width int) *big.Int {
+	m := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(width)), big.NewInt(1))
+	return m.And(m, x)
+}
+
+// Lshift returns x shifted left by k bits, truncated to width bits. It panics if x doesn't fit
+// in width bits.
+func (e *Engine) Lshift(x frontend.Variable, k, width int) frontend.Variable {
+	b := e.word("Lshift", x, width)
+	if k < 0 {
+		panic("Lshift: invalid shift")
+	}
+	return mask(new(big.Int).Lsh(b, uint(k)), width)
+}
+
+// Rshift returns x shifted right by k bits. It panics if x doesn't fit in width bits.
+func (e *Engine) Rshift(x frontend.Variable, k, width int) frontend.Variable {
+	b := e.word("Rshift", x, width)
+	if k < 0 {
+		panic("Rshift: invalid shift")
+	}
+	return new(big.Int).Rsh(b, uint(k))
+}
+
+// Rotate returns x rotated left by k bits in a word of width bits, or right if k is negative.
+// It panics if x doesn't fit in width bits.
+func (e *Engine) Rotate(x frontend.Variable, k, width int) frontend.Variable {
+	b := e.word("Rotate", x, width)
+	if width == 0 {
+		return b
+	}
+	k = ((k % width) + width) % width
+	res := mask(new(big.Int).Lsh(b, uint(k)), width)
+	return res.Or(res, new(big.Int).Rsh(b, uint(width-k)))
+}
+
+// SliceBits returns the bits lo to hi (excluded) of x as a number. It panics if x doesn't fit
+// in width bits.
+func (e *Engine) SliceBits(x frontend.Variable, lo, hi, width int) frontend.Variable {
+	b := e.word("SliceBits", x, width)
+	if lo < 0 || hi < lo || hi > width {
+		panic(fmt.Sprintf("SliceBits: invalid range [%d, %d) of a word of %d bits", lo, hi, width))
+	}
+	return mask(new(big.Int).Rsh(b, uint(lo)), hi-lo)
+}
+
+// checkVecLen panics if the operands of a vector operation have different lengths
+func checkVecLen(name string, a, b []frontend.Variable) {
+	if len(a) != len(b) {
+		panic(fmt.Sprintf("%s: vectors of different lengths %d and %d", name, len(a), len(b)))
+	}
+}
+
+// AddVec returns the element-wise sum of a and b.
+func (e *Engine) AddVec(a, b []frontend.Variable) []frontend.Variable {
+	checkVecLen("AddVec", a, b)
+	res := make([]frontend.Variable, len(a))
+	for i := range a {
+		res[i] = e.Add(a[i], b[i])
+	}
+	return res
+}
+
+// MulVec returns the element-wise product of a and b.
+func (e *Engine) MulVec(a, b []frontend.Variable) []frontend.Variable {
+	checkVecLen("MulVec", a, b)
+	res := make([]frontend.Variable, len(a))
+	for i := range a {
+		res[i] = e.Mul(a[i], b[i])
+	}
+	return res
+}
+
+// AssertIsEqualVec checks that a and b are equal element-wise.
+func (e *Engine) AssertIsEqualVec(a, b []frontend.Variable) {
+	checkVecLen("AssertIsEqualVec", a, b)
+	for i := range a {
+		e.AssertIsEqual(a[i], b[i])
+	}
+}
+
+// InnerProduct returns the sum of the products a[i]*b[i].
+func (e *Engine) InnerProduct(a, b []frontend.Variable) frontend.Variable {
+	checkVecLen("InnerProduct", a, b)
+	res := new(big.Int)
+	for i := range a {
+		res.Add(res, new(big.Int).Mul(e.toBigInt(a[i]), e.toBigInt(b[i])))
+	}
+	return res.Mod(res, e.field)
+}
+
+// Xor returns a ^ b, a and b must be 0 or 1.
+func (e *Engine) Xor(a, b frontend.Variable) frontend.Variable {
+	x, y := e.toBigInt(a), e.toBigInt(b)
+	e.mustBeBoolean("Xor", x)
+	e.mustBeBoolean("Xor", y)
+	return new(big.Int).Xor(x, y)
+}
+
+// Or returns a | b, a and b must be 0 or 1.
+func (e *Engine) Or(a, b frontend.Variable) frontend.Variable {
+	x, y := e.toBigInt(a), e.toBigInt(b)
+	e.mustBeBoolean("Or", x)
+	e.mustBeBoolean("Or", y)
+	return new(big.Int).Or(x, y)
+}
+
+// And returns a & b, a and b must be 0 or 1.
+func (e *Engine) And(a, b frontend.Variable) frontend.Variable {
+	x, y := e.toBigInt(a), e.toBigInt(b)
+	e.mustBeBoolean("And", x)
+	e.mustBeBoolean("And", y)
+	return new(big.Int).And(x, y)
+}
+
+// ---------------------------------------------------------------------------------------------
+// Conditionals
+
+// Select yields the second variable if the first is true, otherwise yields the third variable.
+func (e *Engine) Select(i0, i1, i2 frontend.Variable) frontend.Variable {
+	b := e.toBigInt(i0)
+	e.mustBeBoolean("Select", b)
+	if b.Sign() != 0 {
+		return e.toBigInt(i1)
+	}
+	return e.toBigInt(i2)
+}
+
+// Lookup2 performs a 2-bit lookup based on the given bits and values.
+func (e *Engine) Lookup2(b0, b1 frontend.Variable, i0, i1, i2, i3 frontend.Variable) frontend.Variable {
+	s0, s1 := e.toBigInt(b0), e.toBigInt(b1)
+	e.mustBeBoolean("Lookup2", s0)
+	e.mustBeBoolean("Lookup2", s1)
+	return e.toBigInt([]frontend.Variable{i0, i1, i2, i3}[s0.Uint64()+2*s1.Uint64()])
+}
+
+// IsZero returns 1 if the given variable is zero, otherwise returns 0.
+func (e *Engine) IsZero(i1 frontend.Variable) frontend.Variable {
+	if e.toBigInt(i1).Sign() == 0 {
+		return big.NewInt(1)
+	}
+	return big.NewInt(0)
+}
+
+// Cmp returns 1 if i1 > i2, 0 if i1 == i2 and -1 if i1 < i2, comparing canonical values.
+func (e *Engine) Cmp(i1, i2 frontend.Variable) frontend.Variable {
+	res := big.NewInt(int64(e.toBigInt(i1).Cmp(e.toBigInt(i2))))
+	return res.Mod(res, e.field)
+}
+
+// ---------------------------------------------------------------------------------------------
+// Assertions
+
+// AssertIsEqual panics if i1 != i2.
+func (e *Engine) AssertIsEqual(i1, i2 frontend.Variable) {
+	b1, b2 := e.toBigInt(i1), e.toBigInt(i2)
+	if b1.Cmp(b2) != 0 {
+		panic(fmt.Sprintf("AssertIsEqual: %s != %s", b1.String(), b2.String()))
+	}
+}
+
+// AssertIsDifferent panics if i1 == i2.
+func (e *Engine) AssertIsDifferent(i1, i2 frontend.Variable) {
+	b1, b2 := e.toBigInt(i1), e.toBigInt(i2)
+	if b1.Cmp(b2) == 0 {
+		panic(fmt.Sprintf("AssertIsDifferent: both values are %s", b1.String()))
+	}
+}
+
+// AssertIsBoolean panics if i1 isn't 0 or 1.
+func (e *Engine) AssertIsBoolean(i1 frontend.Variable) {
+	e.mustBeBoolean("AssertIsBoolean", e.toBigInt(i1))
+}
+
+// AssertIsCrumb panics if i1 isn't 0, 1, 2 or 3.
+func (e *Engine) AssertIsCrumb(i1 frontend.Variable) {
+	if b := e.toBigInt(i1); !b.IsUint64() || b.Uint64() > 3 {
+		panic(fmt.Sprintf("AssertIsCrumb: %s is not in [0, 3]", b.String()))
+	}
+}
+
+// AssertIsLessOrEqual panics if v > bound.
+func (e *Engine) AssertIsLessOrEqual(v frontend.Variable, bound frontend.Variable) {
+	b1, b2 := e.toBigInt(v), e.toBigInt(bound)
+	if b1.Cmp(b2) > 0 {
+		panic(fmt.Sprintf("AssertIsLessOrEqual: %s > %s", b1.String(), b2.String()))
+	}
+}
+
+// Check panics if v doesn't fit in nbBits bits. It implements frontend.Rangechecker.
+func (e *Engine) Check(v frontend.Variable, nbBits int) {
+	if b := e.toBigInt(v); b.BitLen() > nbBits {
+		panic(fmt.Sprintf("range check: %s doesn't fit in %d bits", b.String(), nbBits))
+	}
+}
+
+// ---------------------------------------------------------------------------------------------
+// Hints, custom gates and subcircuits
+
+// NewHint calls f on the values of the inputs.
+func (e *Engine) NewHint(f solver.Hint, nbOutputs int, inputs ...frontend.Variable) ([]frontend.Variable, error) {
+	if nbOutputs <= 0 {
+		return nil, errors.New("hint function must return at least one output")
+	}
+	in := make([]*big.Int, len(inputs))
+	for i, x := range inputs {
+		in[i] = new(big.Int).Set(e.toBigInt(x))
+	}
+	out := make([]*big.Int, nbOutputs)
+	for i := range out {
+		out[i] = new(big.Int)
+	}
+	if err := f(e.field, in, out); err != nil {
+		panic(fmt.Errorf("hint %s: %w", solver.GetHintName(f), err))
+	}
+	res := make([]frontend.Variable, nbOutputs)
+	for i, x := range out {
+		if x == nil {
+			panic(fmt.Sprintf("hint %s returned a nil output", solver.GetHintName(f)))
+		}
+		res[i] = x.Mod(x, e.field)
+	}
+	return res, nil
+}
+
+// NewHintForId calls the registered hint with the given id.
+func (e *Engine) NewHintForId(id solver.HintID, nbOutputs int, inputs ...frontend.Variable) ([]frontend.Variable, error) {
+	f := solver.GetRegisteredHint(id)
+	if f == nil {
+		return nil, fmt.Errorf("no hint registered with id #%d. Use solver.RegisterHint or solver.RegisterNamedHint", id)
+	}
+	return e.NewHint(f, nbOutputs, inputs...)
+}
+
+// CustomGate evaluates the registered function of the custom gate.
+func (e *Engine) CustomGate(gateType uint64, inputs ...frontend.Variable) frontend.Variable {
+	if err := customgates.CheckArity(gateType, len(inputs)); err != nil {
+		panic(err)
+	}
+	res, err := e.NewHint(customgates.GetFunc(gateType), 1, inputs...)
+	if err != nil {
+		panic(err)
+	}
+	return res[0]
+}
+
+// MemorizedSimpleCall calls f directly.
+func (e *Engine) MemorizedSimpleCall(f builder.SubCircuitSimpleFunc, input []frontend.Variable) []frontend.Variable {
+	return f(e, input)
+}
+
+// MemorizedCall calls fn directly, with the engine as its frontend.API.
+func (e *Engine) MemorizedCall(fn builder.SubCircuitFunc, inputs ...interface{}) interface{} {
+	fnVal := reflect.ValueOf(fn)
+	if fnVal.Kind() != reflect.Func {
+		panic("f is not a function")
+	}
+	args := []reflect.Value{reflect.ValueOf(frontend.API(e))}
+	for i, x := range inputs {
+		if x == nil {
+			// nil slices keep the type of the parameter
+			t := fnVal.Type()
+			if t.IsVariadic() && i+1 >= t.NumIn()-1 {
+				args = append(args, reflect.Zero(t.In(t.NumIn()-1).Elem()))
+			} else {
+				args = append(args, reflect.Zero(t.In(i+1)))
+			}
+			continue
+		}
+		args = append(args, reflect.ValueOf(x))
+	}
+	out := fnVal.Call(args)
+	if len(out) == 0 {
+		return nil
+	}
+	return out[0].Interface()
+}
+
+// ---------------------------------------------------------------------------------------------
+// ecgo specific
+
+// ToSingleVariable returns the value of the expression.
+func (e *Engine) ToSingleVariable(v frontend.Variable) frontend.Variable {
+	return e.toBigInt(v)
+}
+
+// Output appends the value of x to the outputs, see Outputs.
+func (e *Engine) Output(x frontend.Variable) {
+	e.outputs = append(e.outputs, e.toBigInt(x))
+}
+
+// Tag appends the value of x to the outputs like Output, the name is ignored.
+func (e *Engine) Tag(x frontend.Variable, name string) {
+	e.Output(x)
+}
+
+// LayerOf always returns 0, since the engine doesn't layer the circuit.
+func (e *Engine) LayerOf(v frontend.Variable) int {
+	return 0
+}
+
+// ToFirstLayer returns the value of v.
+func (e *Engine) ToFirstLayer(v frontend.Variable) frontend.Variable {
+	return e.toBigInt(v)
+}
+
+// GetRandomValue samples a uniformly random field element.
+func (e *Engine) GetRandomValue() frontend.Variable {
+	r, err := rand.Int(rand.Reader, e.field)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Challenge samples a uniformly random field element the first time name is requested, and
+// returns the same value afterwards.
+func (e *Engine) Challenge(name string) frontend.Variable {
+	if r, ok := e.challenges[name]; ok {
+		return r
+	}
+	r := e.GetRandomValue().(*big.Int)
+	e.challenges[name] = r
+	return r
+}
+
+// NewTable returns a lookup table whose queries are checked at the end of the run.
+func (e *Engine) NewTable(width int) *builder.LookupTable {
+	t := builder.NewDetachedTable(width)
+	e.tables = append(e.tables, t)
+	return t
+}
+
+// AssertPermutation panics if b isn't a permutation of a.
+func (e *Engine) AssertPermutation(a, b []frontend.Variable) {
+	rows := func(x []frontend.Variable) [][]frontend.Variable {
+		res := make([][]frontend.Variable, len(x))
+		for i := range x {
+			res[i] = x[i : i+1]
+		}
+		return res
+	}
+	e.AssertRowPermutation(rows(a), rows(b))
+}
+
+// AssertRowPermutation panics if the rows of b aren't a permutation of the rows of a.
+func (e *Engine) AssertRowPermutation(a, b [][]frontend.Variable) {
+	if len(a) != len(b) {
+		panic(fmt.Sprintf("AssertPermutation: %d rows can't be a permutation of %d rows", len(b), len(a)))
+	}
+	key := func(row []frontend.Variable) string {
+		s := make([]string, len(row))
+		for i, x := range row {
+			s[i] = e.toBigInt(x).String()
+		}
+		return strings.Join(s, ",")
+	}
+	count := make(map[string]int)
+	for _, row := range a {
+		count[key(row)]++
+	}
+	for _, row := range b {
+		k := key(row)
+		if count[k] == 0 {
+			panic(fmt.Sprintf("AssertPermutation: row (%s) isn't a row of the permuted rows", k))
+		}
+		count[k]--
+	}
+}
+
+// Commit returns a random value, like the ecgo builder.
+func (e *Engine) Commit(v ...frontend.Variable) (frontend.Variable, error) {
+	return e.GetRandomValue(), nil
+}
+
+// ---------------------------------------------------------------------------------------------
+// frontend.Compiler
+
+// Println prints the values of the variables, prefixed by the location of the call.
+func (e *Engine) Println(a ...frontend.Variable) {
+	var sb strings.Builder
+	sb.WriteString("(ecgo test engine) ")
+	if _, file, line, ok := runtime.Caller(1); ok {
+		sb.WriteString(filepath.Base(file))
+		sb.WriteByte(':')
+		sb.WriteString(strconv.Itoa(line))
+		sb.WriteByte(' ')
+	}
+	for _, x := range a {
+		if s, ok := x.(string); ok {
+			sb.WriteString(s)
+		} else {
+			sb.WriteString(e.format(x))
+		}
+		sb.WriteByte(' ')
+	}
+	fmt.Println(sb.String())
+}
+
+// format prints values close to p as negative numbers
+func (e *Engine) format(x interface{}) string {
+	if v, ok := x.([]frontend.Variable); ok {
+		s := make([]string, len(v))
+		for i := range v {
+			s[i] = e.format(v[i])
+		}
+		return "[" + strings.Join(s, ",") + "]"
+	}
+	b := e.toBigInt(x)
+	neg := new(big.Int).Sub(b, e.field)
+	if neg.IsInt64() {
+		return strconv.FormatInt(neg.Int64(), 10)
+	}
+	return b.String()
+}
+
+// Compiler returns itself as it implements the frontend.Compiler interface.
+func (e *Engine) Compiler() frontend.Compiler {
+	return e
+}
+
+// MarkBoolean panics if v isn't 0 or 1.
+func (e *Engine) MarkBoolean(v frontend.Variable) {
+	e.mustBeBoolean("MarkBoolean", e.toBigInt(v))
+}
+
+// IsBoolean returns true if v is 0 or 1.
+func (e *Engine) IsBoolean(v frontend.Variable) bool {
+	b := e.toBigInt(v)
+	return b.IsUint64() && b.Uint64() <= 1
+}
+
+// ConstantValue returns the value of v, but reports it as unknown at compile time, so that
+// gadgets don't skip their constraints.
+func (e *Engine) ConstantValue(v frontend.Variable) (*big.Int, bool) {
+	return e.toBigInt(v), false
+}
+
+// Field returns the modulus of the field.
+func (e *Engine) Field() *big.Int {
+	return e.field
+}
+
+// FieldBitLen returns the number of bits of the modulus.
+func (e *Engine) FieldBitLen() int {
+	return e.field.BitLen()
+}
+
+// Defer adds a callback function, called after Define.
+func (e *Engine) Defer(cb func(frontend.API) error) {
+	e.defers = append(e.defers, cb)
+}
+
+// SetKeyValue implements kvstore for the gnark frontend.
+func (e *Engine) SetKeyValue(key, value any) {
+	if !reflect.TypeOf(key).Comparable() {
+		panic("key type not comparable")
+	}
+	e.db[key] = value
+}
+
+// GetKeyValue implements kvstore for the gnark frontend.
+func (e *Engine) GetKeyValue(key any) any {
+	if !reflect.TypeOf(key).Comparable() {
+		panic("key type not comparable")
+	}
+	return e.db[key]
+}
+
+// AddInstruction is not implemented and will panic if called.
+func (e *Engine) AddInstruction(bID constraint.BlueprintID, calldata []uint32) []uint32 {
+	panic("unimplemented")
+}
+
+// AddBlueprint is not implemented and will panic if called.
+func (e *Engine) AddBlueprint(b constraint.Blueprint) constraint.BlueprintID {
+	panic("unimplemented")
+}
+
+// InternalVariable is not implemented and will panic if called.
+func (e *Engine) InternalVariable(wireID uint32) frontend.Variable {
+	panic("unimplemented")
+}
+
+// ToCanonicalVariable is not implemented and will panic if called.
+func (e *Engine) ToCanonicalVariable(in frontend.Variable) frontend.CanonicalVariable {
+	panic("unimplemented")
+}
+
+// SetGkrInfo is not implemented and will panic if called.
+func (e *Engine) SetGkrInfo(info constraint.GkrInfo) error {
+	panic("unimplemented")
+}
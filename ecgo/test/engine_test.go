@@ -0,0 +1,246 @@
+package test
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils/customgates"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/emulated"
+)
+
+const engineCubeGateType = 4242
+
+func engineCube(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	outputs[0].Exp(inputs[0], big.NewInt(3), field)
+	return nil
+}
+
+func engineSquareSum(api frontend.API, input []frontend.Variable) []frontend.Variable {
+	return []frontend.Variable{api.Add(api.Mul(input[0], input[0]), input[1])}
+}
+
+func engineScaled(api frontend.API, k int, x []frontend.Variable) []frontend.Variable {
+	res := make([]frontend.Variable, len(x))
+	for i := range x {
+		res[i] = api.Mul(x[i], k)
+	}
+	return res
+}
+
+type engineCircuit struct {
+	X, Y frontend.Variable
+	Z    frontend.Variable `gnark:",public"`
+}
+
+func (c *engineCircuit) Define(api frontend.API) error {
+	e := api.(ecgo.API)
+	s := e.MemorizedSimpleCall(engineSquareSum, []frontend.Variable{c.X, c.Y})[0]
+	d := e.MemorizedCall(engineScaled, 2, []frontend.Variable{s}).([]frontend.Variable)[0]
+	q, err := api.NewHint(solver.InvZeroHint, 1, c.X)
+	if err != nil {
+		return err
+	}
+	api.AssertIsEqual(api.Mul(q[0], c.X), 1)
+	cube := e.CustomGate(engineCubeGateType, c.X)
+	t := e.NewTable(1)
+	for i := 0; i < 16; i++ {
+		t.Insert(i)
+	}
+	t.Query(c.Y)
+	e.Output(d)
+	api.AssertIsEqual(api.Add(d, cube), c.Z)
+	return nil
+}
+
+func TestEngine(t *testing.T) {
+	customgates.Register(engineCubeGateType, engineCube, 10)
+	// 2 * (3^2 + 5) + 3^3
+	assignment := &engineCircuit{X: 3, Y: 5, Z: 55}
+	e := NewEngine(m31.ScalarField)
+	if err := e.Run(&engineCircuit{}, assignment); err != nil {
+		t.Fatal(err)
+	}
+	if out := e.Outputs(); len(out) != 1 || out[0].Int64() != 28 {
+		t.Fatalf("unexpected outputs %v", out)
+	}
+
+	assignment.Z = 56
+	err := IsSolved(&engineCircuit{}, assignment, m31.ScalarField)
+	if err == nil || !strings.Contains(err.Error(), "AssertIsEqual") {
+		t.Fatalf("expected a failed assertion, got %v", err)
+	}
+	// Y isn't in the table
+	assignment = &engineCircuit{X: 1, Y: 20, Z: 2*(1+20) + 1}
+	err = IsSolved(&engineCircuit{}, assignment, m31.ScalarField)
+	if err == nil || !strings.Contains(err.Error(), "not in the table") {
+		t.Fatalf("expected a failed lookup, got %v", err)
+	}
+	if err := IsSolved(&engineCircuit{}, &engineCircuit{X: 1}, m31.ScalarField); err == nil {
+		t.Fatal("expected an error for a missing assignment")
+	}
+}
+
+type engineMemorizedNCircuit struct {
+	X [3]frontend.Variable
+	Y frontend.Variable
+}
+
+type engineSum struct {
+	Sum frontend.Variable
+}
+
+func engineSumN(api frontend.API, offset int, in [3]frontend.Variable) engineSum {
+	return engineSum{Sum: api.Add(in[0], in[1], in[2], offset)}
+}
+
+func (c *engineMemorizedNCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(builder.MemorizedCallN(api, engineSumN, 1, c.X).Sum, c.Y)
+	return nil
+}
+
+func TestEngineMemorizedCallN(t *testing.T) {
+	assignment := &engineMemorizedNCircuit{X: [3]frontend.Variable{1, 2, 3}, Y: 7}
+	if err := IsSolved(&engineMemorizedNCircuit{}, assignment, m31.ScalarField); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type engineEmulatedCircuit struct {
+	A, B, C emulated.Element[emulated.Secp256k1Fp]
+}
+
+func (c *engineEmulatedCircuit) Define(api frontend.API) error {
+	f, err := emulated.NewField[emulated.Secp256k1Fp](api)
+	if err != nil {
+		return err
+	}
+	f.AssertIsEqual(f.Mul(&c.A, &c.B), &c.C)
+	return nil
+}
+
+func TestEngineEmulated(t *testing.T) {
+	p := emulated.Secp256k1Fp{}.Modulus()
+	a, _ := new(big.Int).SetString("123456789123456789123456789123456789", 10)
+	b := new(big.Int).Sub(p, big.NewInt(12345))
+	c := new(big.Int).Mul(a, b)
+	c.Mod(c, p)
+	assignment := &engineEmulatedCircuit{
+		A: emulated.ValueOf[emulated.Secp256k1Fp](a),
+		B: emulated.ValueOf[emulated.Secp256k1Fp](b),
+		C: emulated.ValueOf[emulated.Secp256k1Fp](c),
+	}
+	if err := IsSolved(&engineEmulatedCircuit{}, assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+	assignment.C = emulated.ValueOf[emulated.Secp256k1Fp](new(big.Int).Add(c, big.NewInt(1)))
+	if err := IsSolved(&engineEmulatedCircuit{}, assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("expected a wrong product to be rejected")
+	}
+}
+
+type engineBitOpsCircuit struct {
+	X frontend.Variable
+}
+
+func (c *engineBitOpsCircuit) Define(api frontend.API) error {
+	e := api.(ecgo.API)
+	e.Output(e.Lshift(c.X, 3, 16))
+	e.Output(e.Rshift(c.X, 5, 16))
+	e.Output(e.Rotate(c.X, -4, 16))
+	e.Output(e.SliceBits(c.X, 2, 9, 16))
+	return nil
+}
+
+func TestEngineBitOperations(t *testing.T) {
+	e := NewEngine(m31.ScalarField)
+	if err := e.Run(&engineBitOpsCircuit{}, &engineBitOpsCircuit{X: 0xb65c}); err != nil {
+		t.Fatal(err)
+	}
+	out := e.Outputs()
+	for i, expected := range []int64{0xb2e0, 0x5b2, 0xcb65, 0x17} {
+		if out[i].Int64() != expected {
+			t.Fatalf("output %d: expected %x, got %x", i, expected, out[i])
+		}
+	}
+	if err := IsSolved(&engineBitOpsCircuit{}, &engineBitOpsCircuit{X: 1 << 16}, m31.ScalarField); err == nil {
+		t.Fatal("expected a word wider than 16 bits to be rejected")
+	}
+}
+
+type engineVectorCircuit struct {
+	A [3]frontend.Variable
+	B [3]frontend.Variable
+}
+
+func (c *engineVectorCircuit) Define(api frontend.API) error {
+	e := api.(ecgo.API)
+	for _, x := range e.AddVec(c.A[:], c.B[:]) {
+		e.Output(x)
+	}
+	for _, x := range e.MulVec(c.A[:], c.B[:]) {
+		e.Output(x)
+	}
+	e.Output(e.InnerProduct(c.A[:], c.B[:]))
+	return nil
+}
+
+func TestEngineVectorOperations(t *testing.T) {
+	e := NewEngine(m31.ScalarField)
+	if err := e.Run(&engineVectorCircuit{}, &engineVectorCircuit{A: [3]frontend.Variable{1, 2, 3}, B: [3]frontend.Variable{4, 5, 6}}); err != nil {
+		t.Fatal(err)
+	}
+	out := e.Outputs()
+	for i, expected := range []int64{5, 7, 9, 4, 10, 18, 32} {
+		if out[i].Int64() != expected {
+			t.Fatalf("output %d: expected %d, got %d", i, expected, out[i])
+		}
+	}
+}
+
+type enginePermutationCircuit struct {
+	A [3]frontend.Variable
+	B [3]frontend.Variable
+}
+
+func (c *enginePermutationCircuit) Define(api frontend.API) error {
+	api.(ecgo.API).AssertPermutation(c.A[:], c.B[:])
+	return nil
+}
+
+func TestEnginePermutation(t *testing.T) {
+	a := [3]frontend.Variable{1, 2, 2}
+	if err := IsSolved(&enginePermutationCircuit{}, &enginePermutationCircuit{A: a, B: [3]frontend.Variable{2, 1, 2}}, m31.ScalarField); err != nil {
+		t.Fatal(err)
+	}
+	if err := IsSolved(&enginePermutationCircuit{}, &enginePermutationCircuit{A: a, B: [3]frontend.Variable{2, 1, 1}}, m31.ScalarField); err == nil {
+		t.Fatal("expected a wrong permutation to be rejected")
+	}
+}
+
+type engineChallengeCircuit struct {
+	X frontend.Variable
+}
+
+func (c *engineChallengeCircuit) Define(api frontend.API) error {
+	a := api.(ecgo.API).Challenge("alpha")
+	api.AssertIsEqual(a, api.(ecgo.API).Challenge("alpha"))
+	api.(ecgo.API).Output(api.Sub(a, api.(ecgo.API).Challenge("beta")))
+	return nil
+}
+
+func TestEngineChallenge(t *testing.T) {
+	e := NewEngine(m31.ScalarField)
+	if err := e.Run(&engineChallengeCircuit{}, &engineChallengeCircuit{X: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if e.Outputs()[0].Sign() == 0 {
+		t.Fatal("expected independent challenges for different names")
+	}
+}
@@ -0,0 +1,50 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+)
+
+type gnarkCircuit struct {
+	X   [2]frontend.Variable
+	Sum frontend.Variable `gnark:",public"`
+	Y   frontend.Variable
+	P   frontend.Variable `gnark:",public"`
+}
+
+func (c *gnarkCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Add(c.X[0], c.X[1], c.Y), c.Sum)
+	api.AssertIsEqual(api.Mul(c.X[0], c.Y), c.P)
+	return nil
+}
+
+func TestNewBuilder(t *testing.T) {
+	field := ecc.BN254.ScalarField()
+	c, err := ecgo.Compile(field, &gnarkCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs, err := frontend.Compile(field, ecgo.NewBuilder, &gnarkCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := cs.(*ecgo.ConstraintSystem).Result()
+	if res.ContentHash() != c.ContentHash() {
+		t.Fatal("expected frontend.Compile to give the same circuit as ecgo.Compile")
+	}
+	if cs.GetNbPublicVariables() != 2 || cs.GetNbSecretVariables() != 3 {
+		t.Fatalf("unexpected numbers of variables %d and %d", cs.GetNbPublicVariables(), cs.GetNbSecretVariables())
+	}
+
+	reordered := ecgo.NewBuilderWithOptions(ecgo.WithPublicInputSlot("P", 0))
+	cs, err = frontend.Compile(field, reordered, &gnarkCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layout := cs.(*ecgo.ConstraintSystem).Result().PublicInputLayout(); len(layout) != 2 || layout[0] != "P" || layout[1] != "Sum" {
+		t.Fatalf("unexpected public input layout %v", layout)
+	}
+}
@@ -0,0 +1,90 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+)
+
+type outputsCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable
+	Z frontend.Variable `gnark:",public"`
+}
+
+func (c *outputsCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Add(c.X, c.Y), c.Z)
+	api.(ecgo.API).Output(api.Mul(c.X, c.Y))
+	api.(ecgo.API).Output(api.Add(api.Mul(c.X, c.X), 1))
+	return nil
+}
+
+func TestOutputs(t *testing.T) {
+	c, err := ecgo.Compile(m31.ScalarField, &outputsCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := c.GetInputSolver().SolveInputs([]frontend.Circuit{
+		&outputsCircuit{X: 3, Y: 4, Z: 7},
+		&outputsCircuit{X: 5, Y: 6, Z: 11},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs, err := c.Outputs(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]int64{{12, 10}, {30, 26}}
+	for i, out := range outputs {
+		if len(out) != len(expected[i]) {
+			t.Fatalf("witness %d: expected %d outputs, got %d", i, len(expected[i]), len(out))
+		}
+		for j, x := range out {
+			if !x.IsInt64() || x.Int64() != expected[i][j] {
+				t.Fatalf("witness %d: expected output %d to be %d, got %s", i, j, expected[i][j], x)
+			}
+		}
+	}
+
+	w, err = c.GetInputSolver().SolveInput(&outputsCircuit{X: 3, Y: 4, Z: 8}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Outputs(w); err == nil {
+		t.Fatal("expected the outputs of an unsatisfied witness to be rejected")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	c, err := ecgo.Compile(m31.ScalarField, &outputsCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lc := c.GetLayeredCircuit()
+	out, err := ecgo.Evaluate(c, &outputsCircuit{X: 3, Y: 4, Z: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != int(lc.Circuits[lc.Layers[len(lc.Layers)-1]].OutputLen) {
+		t.Fatalf("expected the whole output layer, got %d values", len(out))
+	}
+	for i := 0; i < lc.ExpectedNumOutputZeroes; i++ {
+		if out[i].Sign() != 0 {
+			t.Fatalf("expected output %d of a satisfied assignment to be zero, got %s", i, out[i])
+		}
+	}
+	if x := out[lc.ExpectedNumOutputZeroes]; x.Int64() != 12 {
+		t.Fatalf("expected the first output to be 12, got %s", x)
+	}
+
+	out, err = ecgo.Evaluate(c, &outputsCircuit{X: 3, Y: 4, Z: 8})
+	if err != nil || out[0].Sign() == 0 {
+		t.Fatalf("expected a non-zero output for an unsatisfied assignment, got %v, %v", out, err)
+	}
+	if _, err := ecgo.Evaluate(c); err == nil {
+		t.Fatal("expected a missing assignment to be rejected")
+	}
+}
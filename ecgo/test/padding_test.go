@@ -0,0 +1,59 @@
+package test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+func TestPad(t *testing.T) {
+	rc := replicateSample()
+	// a narrower last layer, reading the outputs 0 and 2 of the first one
+	rc.Circuits = append(rc.Circuits, &layered.Circuit{
+		InputLen:  4,
+		OutputLen: 2,
+		Add: []layered.GateAdd{
+			{In: 0, Out: 0, Coef: big.NewInt(1), CoefType: 1},
+			{In: 2, Out: 1, Coef: big.NewInt(1), CoefType: 1},
+		},
+	})
+	rc.Layers = append(rc.Layers, 4)
+	rc.NumActualOutputs = 2
+	w := &irwg.Witness{NumWitnesses: 1, NumInputsPerWitness: 4, NumPublicInputsPerWitness: 2, Field: m31.ScalarField}
+	for _, x := range []int64{2, 3, 5, 7, 6, 10} {
+		w.Values = append(w.Values, big.NewInt(x))
+	}
+	expected := EvalCircuit(rc, w)
+
+	if p := rc.Pad(layered.Padding{}); len(p.Circuits) != len(rc.Circuits) {
+		t.Fatal("the default padding shouldn't change the circuit")
+	}
+	for _, p := range []layered.Padding{
+		{Strategy: layered.PadToMaxWidth},
+		{Gates: layered.RelayPaddingGates},
+		{Strategy: layered.PadToMaxWidth, Gates: layered.RelayPaddingGates},
+	} {
+		padded := rc.Pad(p)
+		last := padded.Circuits[padded.Layers[2]]
+		if p.Strategy == layered.PadToMaxWidth && last.OutputLen != 4 {
+			t.Errorf("%+v: expected the last layer to be padded to 4 outputs, got %d", p, last.OutputLen)
+		}
+		s := padded.Stats()
+		if p.Gates == layered.RelayPaddingGates {
+			// the output 3 of the first layer is read by the second layer, the outputs 1 and 3
+			// of the second layer aren't read by the last one
+			if s.Layers[0].OutputLen != s.Layers[0].UsedOutput+1 || s.Layers[1].UsedOutput != 4 || s.Layers[1].WastedGates == 0 {
+				t.Errorf("%+v: unexpected stats %+v", p, s.Layers)
+			}
+		}
+		out := EvalCircuit(padded, w)
+		for i := range expected {
+			if out[i].Cmp(expected[i]) != 0 {
+				t.Errorf("%+v: output %d is %v, expected %v", p, i, out[i], expected[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+// replicateSample computes (x0*x1 - p0, x2 + x3*p1 + 1, x0) over 4 inputs and 2 public inputs,
+// the first output being expected to be zero
+func replicateSample() *layered.RootCircuit {
+	sub := &layered.Circuit{
+		InputLen:  2,
+		OutputLen: 1,
+		Mul:       []layered.GateMul{{In0: 0, In1: 1, Out: 0, Coef: big.NewInt(1), CoefType: 1}},
+	}
+	l0 := &layered.Circuit{
+		InputLen:    4,
+		OutputLen:   4,
+		SubCircuits: []layered.SubCircuit{{Id: 0, Allocations: []layered.Allocation{{InputOffset: 0, OutputOffset: 0}}}},
+		Add: []layered.GateAdd{
+			{In: 2, Out: 1, Coef: big.NewInt(1), CoefType: 1},
+			{In: 3, Out: 1, Coef: big.NewInt(0), CoefType: 3, PublicInputId: 1},
+			{In: 0, Out: 2, Coef: big.NewInt(1), CoefType: 1},
+		},
+		Cst: []layered.GateCst{
+			{Out: 0, Coef: big.NewInt(0), CoefType: 3, PublicInputId: 0},
+			{Out: 1, Coef: big.NewInt(1), CoefType: 1},
+		},
+	}
+	l1 := &layered.Circuit{
+		InputLen:    4,
+		OutputLen:   4,
+		SubCircuits: []layered.SubCircuit{{Id: 3, Allocations: []layered.Allocation{{InputOffset: 0, OutputOffset: 0}, {InputOffset: 2, OutputOffset: 2}}}},
+	}
+	relay := &layered.Circuit{
+		InputLen:  2,
+		OutputLen: 2,
+		Add: []layered.GateAdd{
+			{In: 0, Out: 0, Coef: big.NewInt(1), CoefType: 1},
+			{In: 1, Out: 1, Coef: big.NewInt(1), CoefType: 1},
+		},
+	}
+	return &layered.RootCircuit{
+		NumPublicInputs:         2,
+		NumActualOutputs:        3,
+		ExpectedNumOutputZeroes: 1,
+		Circuits:                []*layered.Circuit{sub, l0, l1, relay},
+		Layers:                  []uint64{1, 2},
+		Field:                   m31.ScalarField,
+	}
+}
+
+func TestReplicate(t *testing.T) {
+	rc := replicateSample()
+	instances := [][]int64{
+		{2, 3, 5, 7, 6, 10},
+		{1, 4, 0, 2, 5, 3},
+		{3, 3, 1, 1, 0, 2},
+	}
+	w := &irwg.Witness{NumWitnesses: len(instances), NumInputsPerWitness: 4, NumPublicInputsPerWitness: 2, Field: m31.ScalarField}
+	expected := [][]int64{}
+	for _, v := range instances {
+		for _, x := range v {
+			w.Values = append(w.Values, big.NewInt(x))
+		}
+		single := &irwg.Witness{NumWitnesses: 1, NumInputsPerWitness: 4, NumPublicInputsPerWitness: 2, Field: m31.ScalarField, Values: w.Values[len(w.Values)-6:]}
+		out := EvalCircuit(rc, single)
+		e := []int64{}
+		for _, o := range out {
+			e = append(e, o.Int64())
+		}
+		expected = append(expected, e)
+	}
+
+	n := len(instances)
+	rep := rc.Replicate(n)
+	if rep.NumPublicInputs != 2*n || rep.ExpectedNumOutputZeroes != n || rep.NumActualOutputs != 3+2*3+2 {
+		t.Fatalf("unexpected replicated circuit %+v", rep)
+	}
+	// the first layer doesn't use public inputs in the subcircuit, which is shared
+	if len(rep.Circuits[rep.Layers[0]].SubCircuits) != n || rep.Circuits[rep.Layers[0]].InputLen != 16 {
+		t.Fatalf("unexpected first layer %+v", rep.Circuits[rep.Layers[0]])
+	}
+	rw, err := irwg.ReplicateInputs(w, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rw.NumWitnesses != 1 || rw.NumInputsPerWitness != 16 || rw.NumPublicInputsPerWitness != 2*n {
+		t.Fatalf("unexpected replicated witness %+v", rw)
+	}
+	out := EvalCircuit(rep, rw)
+	for k := 0; k < n; k++ {
+		if out[k].Int64() != expected[k][0] {
+			t.Errorf("zero output of copy %d is %v, expected %d", k, out[k], expected[k][0])
+		}
+		for j := 1; j < 4; j++ {
+			if got := out[n+k*3+j-1].Int64(); got != expected[k][j] {
+				t.Errorf("output %d of copy %d is %d, expected %d", j, k, got, expected[k][j])
+			}
+		}
+	}
+
+	if _, err := irwg.ReplicateInputs(w, 2); err == nil {
+		t.Fatal("expected an error grouping 3 witnesses by 2")
+	}
+}
@@ -0,0 +1,163 @@
+package ecgo
+
+import (
+	"context"
+	"log/slog"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// traceSampleInterval is the interval at which the Go heap is sampled for the high-water marks
+const traceSampleInterval = 10 * time.Millisecond
+
+// heapMetric is the memory occupied by the live and not yet swept objects of the Go heap
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// WithTrace writes a structured trace of the compilation to h, e.g. slog.NewJSONHandler for one
+// JSON object per line, so that CI dashboards can track the growth of a circuit over time. The
+// events are:
+//   - "phase start", with the phase, as in Progress, and its gates, the number of instructions or
+//     gates at its start if known;
+//   - "phase end", with the phase, its duration, its gatesBefore and gatesAfter if known, the
+//     changes made by an optimization pass, and heapBytes and peakHeapBytes, the Go heap at its
+//     end and its high-water mark during the phase;
+//   - "compile end", with the duration of the compilation, the gates of the layered circuit,
+//     peakHeapBytes over the whole compilation, and the error if it failed.
+//
+// The heap is sampled every 10ms, so that the high-water marks may miss short peaks, and it
+// doesn't include the memory of the Rust compiler. It's only traced by Compile and
+// CompileContext.
+func WithTrace(h slog.Handler) frontend.CompileOption {
+	return ecgoOption(func(c *compileConfig) {
+		c.trace = h
+	})
+}
+
+// tracer writes the events of WithTrace
+type tracer struct {
+	log   *slog.Logger
+	start time.Time
+
+	// the phase being run, and its start
+	phase      string
+	phaseStart time.Time
+	gates      int
+	changes    int
+	// gates of the layered circuit, once done
+	total int
+
+	mu sync.Mutex
+	// high-water marks of the heap during the compilation and the phase
+	peak, phasePeak uint64
+	sample          []metrics.Sample
+	stop, stopped   chan struct{}
+}
+
+func newTracer(h slog.Handler) *tracer {
+	t := &tracer{
+		log:     slog.New(h),
+		start:   time.Now(),
+		sample:  []metrics.Sample{{Name: heapMetric}},
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	t.heap()
+	go t.sampleHeap()
+	return t
+}
+
+// heap reads the heap and updates the high-water marks
+func (t *tracer) heap() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	metrics.Read(t.sample)
+	var res uint64
+	if t.sample[0].Value.Kind() == metrics.KindUint64 {
+		res = t.sample[0].Value.Uint64()
+	}
+	t.peak = max(t.peak, res)
+	t.phasePeak = max(t.phasePeak, res)
+	return res
+}
+
+func (t *tracer) sampleHeap() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(traceSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			t.heap()
+		}
+	}
+}
+
+// gatesAttr returns an attribute of a number of gates, unless it's unknown
+func gatesAttr(key string, gates int) slog.Attr {
+	if gates <= 0 {
+		return slog.Attr{}
+	}
+	return slog.Int(key, gates)
+}
+
+// endPhase writes the end of the current phase, if any
+func (t *tracer) endPhase(gates int) {
+	if t.phase == "" {
+		return
+	}
+	heap := t.heap()
+	t.mu.Lock()
+	peak := t.phasePeak
+	t.phasePeak = heap
+	t.mu.Unlock()
+	attrs := []slog.Attr{
+		slog.String("phase", t.phase),
+		slog.Duration("duration", time.Since(t.phaseStart)),
+		gatesAttr("gatesBefore", t.gates),
+		gatesAttr("gatesAfter", gates),
+		slog.Uint64("heapBytes", heap),
+		slog.Uint64("peakHeapBytes", peak),
+	}
+	if t.changes >= 0 {
+		attrs = append(attrs, slog.Int("changes", t.changes))
+	}
+	t.log.LogAttrs(context.Background(), slog.LevelInfo, "phase end", attrs...)
+	t.phase = ""
+}
+
+// startPhase ends the current phase and starts the given one, unless it's "done"
+func (t *tracer) startPhase(phase string, gates int) {
+	// the gates of the phases starting with 0 are unknown, rather than the ones of the previous
+	t.endPhase(gates)
+	if phase == "done" {
+		t.total = gates
+		return
+	}
+	t.phase, t.phaseStart, t.gates, t.changes = phase, time.Now(), gates, -1
+	t.log.LogAttrs(context.Background(), slog.LevelInfo, "phase start", slog.String("phase", phase), gatesAttr("gates", gates))
+}
+
+// end ends the current phase, stops the sampling of the heap and writes the end of the
+// compilation, which failed with err if it's not nil
+func (t *tracer) end(err error) {
+	t.endPhase(0)
+	close(t.stop)
+	<-t.stopped
+	attrs := []slog.Attr{
+		slog.Duration("duration", time.Since(t.start)),
+		slog.Uint64("peakHeapBytes", t.peak),
+	}
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", err.Error()))
+	} else {
+		attrs = append(attrs, slog.Int("gates", t.total))
+	}
+	t.log.LogAttrs(context.Background(), level, "compile end", attrs...)
+}
@@ -0,0 +1,91 @@
+package ecgo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+)
+
+type traceCircuit struct {
+	X, Y frontend.Variable
+}
+
+func (c *traceCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.Y), api.Add(c.X, 0))
+	return nil
+}
+
+// traceEvents parses the events of a JSON trace
+func traceEvents(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var res []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e map[string]any
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+func TestTrace(t *testing.T) {
+	// the compilation is stopped before the layering, which needs the Rust compiler
+	ctx, cancel := context.WithCancel(context.Background())
+	var buf bytes.Buffer
+	_, err := CompileContext(ctx, m31.ScalarField, &traceCircuit{}, WithTrace(slog.NewJSONHandler(&buf, nil)), WithProgress(func(p Progress) {
+		if p.Phase == "layering" {
+			cancel()
+		}
+	}))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the compilation to be canceled, got %v", err)
+	}
+	events := traceEvents(t, &buf)
+	var phases []string
+	for _, e := range events {
+		if e["msg"] == "phase start" {
+			phases = append(phases, e["phase"].(string))
+		}
+	}
+	if strings.Join(phases, ",") != "define,finalize,fold,lower-div,cse,dce,layering" {
+		t.Fatalf("unexpected phases %v", phases)
+	}
+	for i, e := range events[:len(events)-1] {
+		if i%2 == 1 && (e["msg"] != "phase end" || e["phase"] != phases[i/2] || e["peakHeapBytes"].(float64) < e["heapBytes"].(float64)) {
+			t.Fatalf("unexpected end of phase %v", e)
+		}
+	}
+	if fold := events[5]; fold["gatesBefore"] == nil || fold["changes"] == nil {
+		t.Fatalf("expected the gates and changes of the pass, got %v", fold)
+	}
+	last := events[len(events)-1]
+	if last["msg"] != "compile end" || last["level"] != "ERROR" || last["error"] != "context canceled" || last["peakHeapBytes"].(float64) == 0 {
+		t.Fatalf("unexpected end of compilation %v", last)
+	}
+}
+
+func TestTraceDone(t *testing.T) {
+	var buf bytes.Buffer
+	p := &progress{ctx: context.Background(), trace: newTracer(slog.NewJSONHandler(&buf, nil))}
+	p.report("layering", 10)
+	p.report("padding", 12)
+	p.done(16)
+	p.trace.end(nil)
+	events := traceEvents(t, &buf)
+	if len(events) != 5 {
+		t.Fatalf("expected 5 events, got %v", events)
+	}
+	if e := events[3]; e["phase"] != "padding" || e["gatesBefore"] != 12.0 || e["gatesAfter"] != 16.0 || e["changes"] != nil {
+		t.Fatalf("unexpected end of padding %v", e)
+	}
+	if e := events[4]; e["msg"] != "compile end" || e["level"] != "INFO" || e["gates"] != 16.0 {
+		t.Fatalf("unexpected end of compilation %v", e)
+	}
+}
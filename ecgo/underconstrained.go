@@ -0,0 +1,83 @@
+package ecgo
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irsource"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/passes"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// UnderConstrainedVariable is a variable which no constraint depends on, see FindUnderConstrained.
+type UnderConstrainedVariable struct {
+	// SubCircuit is the name of the subcircuit of the variable, empty for the root circuit.
+	SubCircuit string
+	// Var is the variable in the source IR of its circuit, before optimization.
+	Var int
+	// Input is the full name of the secret input, empty for the outputs of hints.
+	Input string
+	// Location is the source location of the hint, see WithSourceLocations.
+	Location irsource.SourceLocation
+}
+
+func (v *UnderConstrainedVariable) String() string {
+	if v.Input != "" {
+		return fmt.Sprintf("secret input %s reaches no constraint", v.Input)
+	}
+	s := fmt.Sprintf("%s: output v%d of a hint reaches no constraint", v.Location, v.Var)
+	if v.SubCircuit != "" {
+		s += " in subcircuit " + v.SubCircuit
+	}
+	return s
+}
+
+// FindUnderConstrained defines the circuit like Compile, without optimizing it, and returns its
+// secret inputs and the outputs of its hints which no constraint depends on, see
+// passes.FindUnderConstrained: the prover may choose their values freely, which is rarely
+// intended. The outputs of the circuit given to API.Output don't count as constraints, since
+// they don't bind the values they're computed from.
+func FindUnderConstrained(fieldOrder *big.Int, circuit frontend.Circuit, opts ...frontend.CompileOption) ([]UnderConstrainedVariable, error) {
+	opt, config, err := applyOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	p := &progress{ctx: context.Background(), f: config.progress}
+	root, _, _, err := defineRoot(fieldOrder, circuit, opt, config, p)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.report("finalize", 0); err != nil {
+		return nil, err
+	}
+	rc := root.Finalize()
+	root.ResetArena()
+
+	// the secret inputs, in the order of their variables
+	var secrets []string
+	schema.Walk(circuit, irwg.TVariable, func(f schema.LeafInfo, _ reflect.Value) error {
+		if f.Visibility == schema.Secret {
+			secrets = append(secrets, f.FullName())
+		}
+		return nil
+	})
+	found := passes.FindUnderConstrained(rc)
+	res := make([]UnderConstrainedVariable, len(found))
+	for i, f := range found {
+		res[i] = UnderConstrainedVariable{SubCircuit: root.SubCircuitName(f.Circuit), Var: f.Var}
+		if f.Instruction < 0 {
+			if f.Var <= len(secrets) {
+				res[i].Input = secrets[f.Var-1]
+			} else {
+				res[i].Input = fmt.Sprintf("v%d", f.Var)
+			}
+		} else {
+			res[i].Location = rc.Location(rc.Circuits[f.Circuit].Instructions[f.Instruction].Loc)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,48 @@
+package ecgo
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+)
+
+func halfHint(_ *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	outputs[0].Rsh(inputs[0], 1)
+	return nil
+}
+
+type underConstrainedCircuit struct {
+	X      frontend.Variable
+	Unused frontend.Variable
+	Y      frontend.Variable `gnark:",public"`
+}
+
+func (c *underConstrainedCircuit) Define(api frontend.API) error {
+	// the half of X is given to the verifier, but never checked
+	h, err := api.Compiler().NewHint(halfHint, 1, c.X)
+	if err != nil {
+		return err
+	}
+	api.(API).Output(h[0])
+	api.AssertIsEqual(c.X, c.Y)
+	return nil
+}
+
+func TestFindUnderConstrained(t *testing.T) {
+	vars, err := FindUnderConstrained(m31.ScalarField, &underConstrainedCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != 2 {
+		t.Fatalf("expected 2 under-constrained variables, got %+v", vars)
+	}
+	if vars[0].Input != "Unused" || vars[0].String() != "secret input Unused reaches no constraint" {
+		t.Fatalf("expected the unused input, got %+v", vars[0])
+	}
+	if vars[1].Input != "" || !strings.HasPrefix(vars[1].String(), "underconstrained_test.go:") {
+		t.Fatalf("expected the hint, got %q", vars[1].String())
+	}
+}
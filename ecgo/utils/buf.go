@@ -46,6 +46,10 @@ func (o *OutputBuf) AppendUint8(x uint8) {
 	o.buf = append(o.buf, x)
 }
 
+func (o *OutputBuf) AppendUvarint(x uint64) {
+	o.buf = binary.AppendUvarint(o.buf, x)
+}
+
 func (o *OutputBuf) AppendIntSlice(x []int) {
 	o.AppendUint64(uint64(len(x)))
 	for _, v := range x {
@@ -53,6 +57,10 @@ func (o *OutputBuf) AppendIntSlice(x []int) {
 	}
 }
 
+func (o *OutputBuf) AppendBytes(x []byte) {
+	o.buf = append(o.buf, x...)
+}
+
 func (o *OutputBuf) Bytes() []byte {
 	res := o.buf
 	o.buf = nil
@@ -85,6 +93,19 @@ func (i *InputBuf) ReadUint8() uint8 {
 	return x
 }
 
+func (i *InputBuf) ReadUvarint() uint64 {
+	x, n := binary.Uvarint(i.buf)
+	if n <= 0 {
+		panic("invalid varint")
+	}
+	i.buf = i.buf[n:]
+	return x
+}
+
+func (i *InputBuf) PeekUint8() uint8 {
+	return i.buf[0]
+}
+
 func (i *InputBuf) ReadIntSlice() []int {
 	n := i.ReadUint64()
 	x := make([]int, n)
@@ -104,6 +125,12 @@ func (i *InputBuf) ReadBigInt(n int) *big.Int {
 	return x
 }
 
+func (i *InputBuf) ReadBytes(n int) []byte {
+	x := append([]byte{}, i.buf[:n]...)
+	i.buf = i.buf[n:]
+	return x
+}
+
 func (i *InputBuf) ReadFieldElement(field SimpleField) constraint.Element {
 	return field.FromInterface(i.ReadBigInt(field.SerializedLen()))
 }
@@ -1,37 +1,125 @@
+// Package customgates is the registry of the custom gates supported by the prover. A custom gate
+// is evaluated by its hint function during input solving, and emitted as is in the layered circuit.
 package customgates
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/utils"
 	"github.com/consensys/gnark/constraint/solver"
 )
 
-type customGateEntry struct {
-	hintFunc solver.Hint
-	cost     int
+// Spec describes a custom gate. Degree and Arity are optional: zero means unknown, and any number
+// of inputs is accepted.
+type Spec struct {
+	Func   solver.Hint
+	Cost   int
+	Degree int
+	Arity  int
 }
 
-var customGateHintFunc = make(map[uint64]customGateEntry)
+var customGateHintFunc = make(map[uint64]Spec)
 
 // Register a custom gate. It also registers in the gnark hint registry
 func Register(customGateType uint64, f solver.Hint, cost int) {
-	customGateHintFunc[customGateType] = customGateEntry{
-		hintFunc: f,
-		cost:     cost,
+	RegisterSpec(customGateType, Spec{Func: f, Cost: cost})
+}
+
+// RegisterSpec registers a custom gate with its degree and arity, which are included in the
+// metadata of the circuits using it. The arity is checked when the gate is used, see CheckArity.
+// The degree can't be checked against the hint function: it's only required to be non-negative,
+// and the prover relies on it as declared.
+func RegisterSpec(customGateType uint64, spec Spec) {
+	if spec.Func == nil {
+		panic("custom gate function must not be nil")
+	}
+	if spec.Degree < 0 || spec.Arity < 0 {
+		panic("custom gate degree and arity must not be negative")
 	}
-	solver.RegisterHint(f)
+	customGateHintFunc[customGateType] = spec
+	solver.RegisterHint(spec.Func)
 }
 
 func GetFunc(customGateType uint64) solver.Hint {
 	if h, ok := customGateHintFunc[customGateType]; ok {
-		return h.hintFunc
+		return h.Func
 	}
 	panic(fmt.Sprintf("custom gate %d not registered", customGateType))
 }
 
 func GetCost(customGateType uint64) int {
 	if h, ok := customGateHintFunc[customGateType]; ok {
-		return h.cost
+		return h.Cost
 	}
 	panic(fmt.Sprintf("custom gate %d not registered", customGateType))
 }
+
+// GetSpec returns the specification of a registered custom gate.
+func GetSpec(customGateType uint64) (Spec, bool) {
+	s, ok := customGateHintFunc[customGateType]
+	return s, ok
+}
+
+// CheckArity returns an error if the gate is registered with an arity different from nbInputs.
+func CheckArity(customGateType uint64, nbInputs int) error {
+	if s, ok := customGateHintFunc[customGateType]; ok && s.Arity != 0 && s.Arity != nbInputs {
+		return fmt.Errorf("custom gate %d expects %d inputs, got %d", customGateType, s.Arity, nbInputs)
+	}
+	return nil
+}
+
+// Metadata describes a custom gate used by a circuit, for the prover.
+type Metadata struct {
+	GateType uint64
+	Cost     uint64
+	Degree   uint64
+	Arity    uint64
+}
+
+// GetMetadata returns the metadata of the given gate types, sorted by type.
+// All gates must be registered.
+func GetMetadata(gateTypes []uint64) ([]Metadata, error) {
+	res := make([]Metadata, 0, len(gateTypes))
+	for _, t := range gateTypes {
+		s, ok := customGateHintFunc[t]
+		if !ok {
+			return nil, fmt.Errorf("custom gate %d not registered", t)
+		}
+		res = append(res, Metadata{GateType: t, Cost: uint64(s.Cost), Degree: uint64(s.Degree), Arity: uint64(s.Arity)})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].GateType < res[j].GateType })
+	return res, nil
+}
+
+// SerializeMetadata encodes the metadata as the number of gates followed by the type, cost,
+// degree and arity of each gate, all as little-endian uint64.
+func SerializeMetadata(m []Metadata) []byte {
+	o := utils.OutputBuf{}
+	o.AppendUint64(uint64(len(m)))
+	for _, g := range m {
+		o.AppendUint64(g.GateType)
+		o.AppendUint64(g.Cost)
+		o.AppendUint64(g.Degree)
+		o.AppendUint64(g.Arity)
+	}
+	return o.Bytes()
+}
+
+// DeserializeMetadata decodes the output of SerializeMetadata.
+func DeserializeMetadata(buf []byte) ([]Metadata, error) {
+	if len(buf) < 8 || (len(buf)-8)%32 != 0 {
+		return nil, errors.New("invalid custom gate metadata length")
+	}
+	in := utils.NewInputBuf(buf)
+	n := in.ReadUint64()
+	if uint64(len(buf)-8) != n*32 {
+		return nil, errors.New("invalid custom gate metadata length")
+	}
+	res := make([]Metadata, n)
+	for i := range res {
+		res[i] = Metadata{GateType: in.ReadUint64(), Cost: in.ReadUint64(), Degree: in.ReadUint64(), Arity: in.ReadUint64()}
+	}
+	return res, nil
+}
@@ -0,0 +1,42 @@
+package customgates
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func cube(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	outputs[0] = new(big.Int).Exp(inputs[0], big.NewInt(3), field)
+	return nil
+}
+
+func TestRegisterSpec(t *testing.T) {
+	RegisterSpec(777, Spec{Func: cube, Cost: 10, Degree: 3, Arity: 1})
+	if err := CheckArity(777, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckArity(777, 2); err == nil {
+		t.Fatal("expected an arity error")
+	}
+	if _, err := GetMetadata([]uint64{778}); err == nil {
+		t.Fatal("expected an error for an unregistered gate")
+	}
+	m, err := GetMetadata([]uint64{777})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, err := DeserializeMetadata(SerializeMetadata(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, m2) || m2[0].Degree != 3 {
+		t.Fatalf("unexpected metadata %v", m2)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a negative degree to panic")
+		}
+	}()
+	RegisterSpec(779, Spec{Func: cube, Degree: -1})
+}
@@ -0,0 +1,75 @@
+package gnarkexpr
+
+import (
+	"reflect"
+	"sync"
+)
+
+const arenaChunkBits = 12
+
+var (
+	termOnce sync.Once
+	termType reflect.Type
+	// the term returned by NewVar for variable 0, copied into the chunks
+	termTemplate reflect.Value
+	termVID      int
+)
+
+// Arena allocates the variables of a circuit by chunks of consecutive ids. Var returns a pointer
+// to the term of the variable, like the exprs returned by NewVar but without allocating, and
+// always the same pointer for the same id, so that variables can still be compared with ==.
+// An Arena must not be used concurrently.
+type Arena struct {
+	chunks [][]Expr
+}
+
+// NewArena returns an empty arena.
+func NewArena() *Arena {
+	return &Arena{}
+}
+
+// Var returns the variable with id x.
+func (a *Arena) Var(x int) Expr {
+	if x < 0 || x >= MaxVariables {
+		panic("variable id out of range")
+	}
+	c := x >> arenaChunkBits
+	for c >= len(a.chunks) {
+		a.chunks = append(a.chunks, nil)
+	}
+	if a.chunks[c] == nil {
+		a.chunks[c] = newChunk(c << arenaChunkBits)
+	}
+	return a.chunks[c][x&(1<<arenaChunkBits-1)]
+}
+
+// Reset drops the chunks of the arena, so that they can be collected once the variables they
+// hold are no longer referenced. The variables returned before stay valid, but Var returns new
+// pointers for them, so Reset must only be called once the circuit is built.
+func (a *Arena) Reset() {
+	a.chunks = nil
+}
+
+func newChunk(start int) []Expr {
+	termOnce.Do(func() {
+		termTemplate = reflect.ValueOf(NewVar(0))
+		termType = termTemplate.Type()
+		f, ok := termType.FieldByName("VID")
+		if !ok {
+			panic("missing variable id in gnark terms, please check gnark version")
+		}
+		termVID = f.Index[0]
+	})
+	terms := reflect.New(reflect.ArrayOf(1<<arenaChunkBits, termType)).Elem()
+	res := make([]Expr, 1<<arenaChunkBits)
+	for i := range res {
+		t := terms.Index(i)
+		t.Set(termTemplate)
+		t.Field(termVID).SetInt(int64(start + i))
+		res[i] = t.Addr().Interface().(Expr)
+	}
+	if res[len(res)-1].WireID() != start+len(res)-1 {
+		panic("variable id mismatch, please check gnark version")
+	}
+	return res
+}
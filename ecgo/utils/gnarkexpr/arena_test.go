@@ -0,0 +1,43 @@
+package gnarkexpr
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+func TestArena(t *testing.T) {
+	a := NewArena()
+	for _, x := range []int{0, 1, 4095, 4096, 100000} {
+		v := a.Var(x)
+		if v.WireID() != x {
+			t.Fatalf("variable %d has id %d", x, v.WireID())
+		}
+		if a.Var(x) != v {
+			t.Fatalf("variable %d is not unique", x)
+		}
+		if !frontend.IsCanonical(v) {
+			t.Fatalf("variable %d is not canonical for gnark", x)
+		}
+	}
+	v := a.Var(7)
+	a.Reset()
+	if v.WireID() != 7 || a.Var(7).WireID() != 7 {
+		t.Fatal("variables changed by Reset")
+	}
+}
+
+func BenchmarkNewVar(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewVar(i % MaxVariables)
+	}
+}
+
+func BenchmarkArenaVar(b *testing.B) {
+	b.ReportAllocs()
+	a := NewArena()
+	for i := 0; i < b.N; i++ {
+		a.Var(i % MaxVariables)
+	}
+}
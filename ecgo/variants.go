@@ -0,0 +1,61 @@
+package ecgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/builder"
+	"github.com/consensys/gnark/frontend"
+)
+
+// Variant is a circuit compiled under a name by CompileVariants, e.g. a Go circuit definition
+// with its compile-time parameters, like a tree depth or a batch size.
+type Variant struct {
+	Name    string
+	Circuit frontend.Circuit
+	// Options are applied after the options of CompileVariants.
+	Options []frontend.CompileOption
+}
+
+// CompileVariants compiles several variants of a circuit one after the other, and returns the
+// result of each by name. Each variant is defined with a fork of the root builder of the previous
+// one, see builder.Root.Fork, so the variants share their subcircuit registry: a gadget memorized
+// by several variants with arguments of the same shape is only built once. With
+// WithCompileCache, they also share the cache, which the variants left unchanged since a
+// previous compilation hit.
+func CompileVariants(field *big.Int, variants []Variant, opts ...frontend.CompileOption) (map[string]*CompileResult, error) {
+	if len(variants) == 0 {
+		return nil, errors.New("no variant to compile")
+	}
+	opt := make([]frontend.CompileConfig, len(variants))
+	configs := make([]*compileConfig, len(variants))
+	for i, v := range variants {
+		for _, w := range variants[:i] {
+			if w.Name == v.Name {
+				return nil, fmt.Errorf("variant %q is repeated", v.Name)
+			}
+		}
+		var err error
+		opt[i], configs[i], err = applyOptions(append(opts[:len(opts):len(opts)], v.Options...))
+		if err != nil {
+			return nil, fmt.Errorf("variant %q: %w", v.Name, err)
+		}
+	}
+	res := make(map[string]*CompileResult, len(variants))
+	for i, v := range variants {
+		if i+1 < len(variants) {
+			next, nextOpt := configs[i+1], opt[i+1]
+			configs[i].defined = func(root *builder.Root) {
+				next.fork = root.Fork(nextOpt)
+			}
+		}
+		r, err := compileConfigured(context.Background(), field, v.Circuit, opt[i], configs[i])
+		if err != nil {
+			return nil, fmt.Errorf("variant %q: %w", v.Name, err)
+		}
+		res[v.Name] = r
+	}
+	return res, nil
+}
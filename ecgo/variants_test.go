@@ -0,0 +1,26 @@
+package ecgo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/consensys/gnark/frontend"
+)
+
+func TestCompileVariants(t *testing.T) {
+	variants := []Variant{
+		{Name: "small", Circuit: &estimateCircuit{}, Options: []frontend.CompileOption{WithLimits(Limits{MaxLayers: 2})}},
+		{Name: "small", Circuit: &estimateCircuit{}},
+	}
+	if _, err := CompileVariants(m31.ScalarField, variants); err == nil || !strings.Contains(err.Error(), `variant "small" is repeated`) {
+		t.Fatalf("expected a repeated variant, got %v", err)
+	}
+	// the limits of the first variant stop the compilation
+	variants[1].Name = "large"
+	_, err := CompileVariants(m31.ScalarField, variants)
+	if !errors.Is(err, ErrLimitExceeded) || !strings.HasPrefix(err.Error(), `variant "small": `) {
+		t.Fatalf("expected the limits of the first variant to be exceeded, got %v", err)
+	}
+}
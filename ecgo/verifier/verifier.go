@@ -0,0 +1,151 @@
+// Package verifier verifies the proofs generated by the Expander prover for layered circuits
+// compiled by this package, e.g. with the prover package. The verification runs in-process
+// through the Rust library, so services don't need to run the Expander binary.
+package verifier
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/rust"
+)
+
+// Verifier verifies proofs for a layered circuit. It keeps the serialized circuit, so that several
+// proofs can be verified without serializing it again.
+type Verifier struct {
+	circuit         []byte
+	hash            [32]byte
+	field           *big.Int
+	numInputs       int
+	numPublicInputs int
+}
+
+// New returns a Verifier for the layered circuit.
+func New(lc *layered.RootCircuit) *Verifier {
+	return newVerifier(lc, lc.Serialize())
+}
+
+// NewSerialized returns a Verifier for a layered circuit serialized by layered.RootCircuit.Serialize,
+// e.g. read from the circuit file written by the compiler, by SerializeVersioned or by
+// SerializeCompact. It panics if the header of a versioned circuit doesn't pass
+// layered.Header.Check with layered.Supported.
+func NewSerialized(circuit []byte) *Verifier {
+	lc := layered.DeserializeRootCircuit(circuit)
+	if layered.IsCompact(circuit) {
+		return New(lc)
+	}
+	// the Rust library reads the format of Serialize, which the hashes of the witnesses are of
+	return newVerifier(lc, layered.StripHeader(circuit))
+}
+
+// NewMapped returns a Verifier for a layered circuit mapped in memory, without deserializing it.
+// The Verifier reads the mapped file, so it must not be used after m is closed.
+func NewMapped(m *layered.MappedCircuit) *Verifier {
+	circuit := m.Bytes()
+	return &Verifier{
+		circuit:         circuit,
+		hash:            sha256.Sum256(circuit),
+		field:           m.Field,
+		numInputs:       int(m.Layer(0).InputLen),
+		numPublicInputs: m.NumPublicInputs,
+	}
+}
+
+func newVerifier(lc *layered.RootCircuit, circuit []byte) *Verifier {
+	return &Verifier{
+		circuit:         circuit,
+		hash:            sha256.Sum256(circuit),
+		field:           lc.Field,
+		numInputs:       int(lc.Circuits[lc.Layers[0]].InputLen),
+		numPublicInputs: lc.NumPublicInputs,
+	}
+}
+
+// Circuit returns the serialized circuit.
+func (v *Verifier) Circuit() []byte {
+	return v.circuit
+}
+
+// FieldId returns the id of the field of the circuit, as expected by the Rust library.
+func (v *Verifier) FieldId() uint64 {
+	return field.GetFieldId(field.GetFieldFromOrder(v.field))
+}
+
+// CheckWitness returns an error if the witness can't be one of the circuit: if its field or its
+// number of inputs differ, or if it was solved for another circuit, see irwg.Witness.CheckCircuitHash.
+func (v *Verifier) CheckWitness(w *irwg.Witness) error {
+	if w.Field.Cmp(v.field) != 0 {
+		return fmt.Errorf("witness field %s doesn't match the circuit field %s", w.Field, v.field)
+	}
+	if w.NumInputsPerWitness != v.numInputs || w.NumPublicInputsPerWitness != v.numPublicInputs {
+		return fmt.Errorf("witness has %d inputs and %d public inputs, the circuit expects %d and %d",
+			w.NumInputsPerWitness, w.NumPublicInputsPerWitness, v.numInputs, v.numPublicInputs)
+	}
+	return w.CheckCircuitHash(v.hash)
+}
+
+// Verify returns whether the proof is valid for the witness. Only the public inputs of the
+// witness are used by the verification, see VerifyPublic. An error means the verification couldn't
+// be run, e.g. because the witness doesn't fit the circuit.
+func (v *Verifier) Verify(w *irwg.Witness, proof []byte) (bool, error) {
+	if err := v.CheckWitness(w); err != nil {
+		return false, err
+	}
+	ok, err := rust.Verify(v.circuit, w.Serialize(), proof, v.FieldId())
+	if err != nil {
+		return false, fmt.Errorf("verify: %w", err)
+	}
+	return ok, nil
+}
+
+// VerifyPublic returns whether the proof is valid for the public inputs, given for each witness
+// the proof was generated for, so the verifier doesn't need the secret inputs.
+func (v *Verifier) VerifyPublic(publicInputs [][]*big.Int, proof []byte) (bool, error) {
+	return v.Verify(v.PublicWitness(publicInputs), proof)
+}
+
+// VerifyPublicWitness returns whether the proof is valid for the public inputs of pw, e.g. read
+// from a file written by irwg.PublicWitness.Serialize. Like CheckWitness, it returns an error if
+// pw doesn't fit the circuit.
+func (v *Verifier) VerifyPublicWitness(pw *irwg.PublicWitness, proof []byte) (bool, error) {
+	if pw.NumPublicInputsPerWitness != v.numPublicInputs {
+		return false, fmt.Errorf("public witness has %d public inputs, the circuit expects %d", pw.NumPublicInputsPerWitness, v.numPublicInputs)
+	}
+	if pw.Field.Cmp(v.field) != 0 {
+		return false, fmt.Errorf("witness field %s doesn't match the circuit field %s", pw.Field, v.field)
+	}
+	w := v.PublicWitness(pw.Inputs())
+	w.CircuitHash = pw.CircuitHash
+	return v.Verify(w, proof)
+}
+
+// PublicWitness returns a witness with the public inputs, given for each witness, and zero secret
+// inputs. It panics if a witness doesn't have the number of public inputs of the circuit.
+func (v *Verifier) PublicWitness(publicInputs [][]*big.Int) *irwg.Witness {
+	w := &irwg.Witness{
+		NumWitnesses:              len(publicInputs),
+		NumInputsPerWitness:       v.numInputs,
+		NumPublicInputsPerWitness: v.numPublicInputs,
+		Field:                     v.field,
+		Values:                    make([]*big.Int, 0, len(publicInputs)*(v.numInputs+v.numPublicInputs)),
+	}
+	for i, public := range publicInputs {
+		if len(public) != v.numPublicInputs {
+			panic(fmt.Sprintf("witness %d has %d public inputs, expected %d", i, len(public), v.numPublicInputs))
+		}
+		for j := 0; j < v.numInputs; j++ {
+			w.Values = append(w.Values, big.NewInt(0))
+		}
+		w.Values = append(w.Values, public...)
+	}
+	return w
+}
+
+// Verify checks a proof for the layered circuit and the witness, see Verifier.Verify.
+func Verify(lc *layered.RootCircuit, w *irwg.Witness, proof []byte) (bool, error) {
+	return New(lc).Verify(w, proof)
+}
@@ -0,0 +1,87 @@
+package verifier
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/bn254"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+// the circuit x0 + x1 + p0
+func sampleCircuit() *layered.RootCircuit {
+	return &layered.RootCircuit{
+		NumPublicInputs:  1,
+		NumActualOutputs: 1,
+		Circuits: []*layered.Circuit{{
+			InputLen:  2,
+			OutputLen: 1,
+			Add: []layered.GateAdd{
+				{In: 0, Out: 0, Coef: big.NewInt(1), CoefType: 1},
+				{In: 1, Out: 0, Coef: big.NewInt(1), CoefType: 1},
+			},
+			Cst: []layered.GateCst{{Out: 0, Coef: big.NewInt(0), CoefType: 3, PublicInputId: 0}},
+		}},
+		Layers: []uint64{0},
+		Field:  m31.ScalarField,
+	}
+}
+
+func sampleWitness(f *big.Int) *irwg.Witness {
+	return &irwg.Witness{
+		NumWitnesses:              1,
+		NumInputsPerWitness:       2,
+		NumPublicInputsPerWitness: 1,
+		Field:                     f,
+		Values:                    []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)},
+	}
+}
+
+// The checks before calling the Rust library
+func TestCheckWitness(t *testing.T) {
+	lc := sampleCircuit()
+	v := New(lc)
+	if v.FieldId() != 1 {
+		t.Fatalf("field id %d, expected 1", v.FieldId())
+	}
+
+	if err := v.CheckWitness(sampleWitness(bn254.ScalarField)); err == nil || !strings.Contains(err.Error(), "field") {
+		t.Fatalf("expected a field mismatch, got %v", err)
+	}
+
+	w := sampleWitness(m31.ScalarField)
+	w.NumPublicInputsPerWitness = 0
+	if err := v.CheckWitness(w); err == nil || !strings.Contains(err.Error(), "public inputs") {
+		t.Fatalf("expected an input count mismatch, got %v", err)
+	}
+
+	w = sampleWitness(m31.ScalarField)
+	other := sampleCircuit()
+	other.Circuits[0].Add[1].Coef = big.NewInt(2)
+	hash := other.ContentHash()
+	w.CircuitHash = hash[:]
+	if _, err := v.Verify(w, nil); !errors.Is(err, irwg.ErrCircuitMismatch) {
+		t.Fatalf("expected ErrCircuitMismatch, got %v", err)
+	}
+
+	hash = lc.ContentHash()
+	w.CircuitHash = hash[:]
+	if err := v.CheckWitness(w); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublicWitness(t *testing.T) {
+	v := NewSerialized(sampleCircuit().Serialize())
+	w := v.PublicWitness([][]*big.Int{{big.NewInt(3)}, {big.NewInt(5)}})
+	if err := v.CheckWitness(w); err != nil {
+		t.Fatal(err)
+	}
+	if w.NumWitnesses != 2 || len(w.Values) != 6 || w.Values[2].Int64() != 3 || w.Values[5].Int64() != 5 || w.Values[3].Sign() != 0 {
+		t.Fatalf("unexpected witness %+v", w)
+	}
+}
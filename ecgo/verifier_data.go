@@ -0,0 +1,143 @@
+package ecgo
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+// VerifierDataVersion is the version of the documents written by VerifierData.Write. It's
+// increased on incompatible changes.
+const VerifierDataVersion = 1
+
+// VerifierData is the part of a compiled circuit needed by verifiers, see ExtractVerifierData:
+// a few kilobytes identifying the layered circuit, instead of the circuit itself.
+type VerifierData struct {
+	Version int `json:"version"`
+	// Field is the modulus of the field, in decimal.
+	Field string `json:"field"`
+	// CircuitHash is the content hash of the layered circuit, in hex, see
+	// CompileResult.ContentHash.
+	CircuitHash             string          `json:"circuitHash"`
+	NumInputs               uint64          `json:"numInputs"`
+	NumPublicInputs         int             `json:"numPublicInputs"`
+	NumActualOutputs        int             `json:"numActualOutputs"`
+	ExpectedNumOutputZeroes int             `json:"expectedNumOutputZeroes"`
+	PublicInputLayout       []string        `json:"publicInputLayout,omitempty"`
+	BatchSize               int             `json:"batchSize"`
+	Features                string          `json:"features,omitempty"`
+	Layers                  []VerifierLayer `json:"layers"`
+}
+
+// VerifierLayer describes a layer of the circuit of a VerifierData.
+type VerifierLayer struct {
+	InputLen  uint64 `json:"inputLen"`
+	OutputLen uint64 `json:"outputLen"`
+	// Digest is the digest of the structure of the layer, in hex, see
+	// layered.RootCircuit.LayerDigests.
+	Digest string `json:"digest"`
+}
+
+// ExtractVerifierData returns the verifier data of the compiled circuit c: the structure of its
+// layers, as their sizes and digests, the layout of its public inputs and its content hash. It's
+// enough to check the public witnesses given with a proof, and the circuit a prover claims to
+// have used, layer by layer, so that verifiers don't need to store the full circuit.
+func ExtractVerifierData(c *CompileResult) *VerifierData {
+	lc := c.GetLayeredCircuit()
+	hash := c.ContentHash()
+	d := &VerifierData{
+		Version:                 VerifierDataVersion,
+		Field:                   lc.Field.String(),
+		CircuitHash:             hex.EncodeToString(hash[:]),
+		NumInputs:               lc.Circuits[lc.Layers[0]].InputLen,
+		NumPublicInputs:         lc.NumPublicInputs,
+		NumActualOutputs:        lc.NumActualOutputs,
+		ExpectedNumOutputZeroes: lc.ExpectedNumOutputZeroes,
+		PublicInputLayout:       c.PublicInputLayout(),
+		BatchSize:               c.BatchSize(),
+		Layers:                  make([]VerifierLayer, len(lc.Layers)),
+	}
+	if f := c.Features(); f != 0 {
+		d.Features = f.String()
+	}
+	digests := lc.LayerDigests()
+	for i, id := range lc.Layers {
+		d.Layers[i] = VerifierLayer{
+			InputLen:  lc.Circuits[id].InputLen,
+			OutputLen: lc.Circuits[id].OutputLen,
+			Digest:    hex.EncodeToString(digests[i][:]),
+		}
+	}
+	return d
+}
+
+// Write writes the verifier data as indented JSON.
+func (d *VerifierData) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// ReadVerifierData reads verifier data written by VerifierData.Write.
+func ReadVerifierData(r io.Reader) (*VerifierData, error) {
+	var d VerifierData
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return nil, fmt.Errorf("failed to read verifier data: %w", err)
+	}
+	if d.Version != VerifierDataVersion {
+		return nil, fmt.Errorf("verifier data of version %d, expected %d", d.Version, VerifierDataVersion)
+	}
+	if _, ok := new(big.Int).SetString(d.Field, 10); !ok {
+		return nil, fmt.Errorf("verifier data: invalid field %q", d.Field)
+	}
+	return &d, nil
+}
+
+// field returns the modulus of the field
+func (d *VerifierData) field() *big.Int {
+	f, _ := new(big.Int).SetString(d.Field, 10)
+	return f
+}
+
+// CheckPublicWitness returns an error if pw can't be the public witness of the circuit: if its
+// field or its number of public inputs differ, or if it was solved for another circuit.
+func (d *VerifierData) CheckPublicWitness(pw *irwg.PublicWitness) error {
+	if pw.Field.Cmp(d.field()) != 0 {
+		return fmt.Errorf("witness field %s doesn't match the circuit field %s", pw.Field, d.Field)
+	}
+	if pw.NumPublicInputsPerWitness != d.NumPublicInputs {
+		return fmt.Errorf("public witness has %d public inputs, the circuit expects %d", pw.NumPublicInputsPerWitness, d.NumPublicInputs)
+	}
+	if pw.CircuitHash != nil && hex.EncodeToString(pw.CircuitHash) != d.CircuitHash {
+		return irwg.ErrCircuitMismatch
+	}
+	return nil
+}
+
+// CheckCircuit returns an error if lc isn't the circuit of the verifier data, naming the first
+// layer that differs, e.g. to check a circuit fetched from an untrusted store before verifying
+// proofs with it.
+func (d *VerifierData) CheckCircuit(lc *layered.RootCircuit) error {
+	if lc.Field.Cmp(d.field()) != 0 {
+		return fmt.Errorf("circuit field %s doesn't match %s", lc.Field, d.Field)
+	}
+	if len(lc.Layers) != len(d.Layers) {
+		return fmt.Errorf("circuit has %d layers, expected %d", len(lc.Layers), len(d.Layers))
+	}
+	for i, digest := range lc.LayerDigests() {
+		if hex.EncodeToString(digest[:]) != d.Layers[i].Digest {
+			return fmt.Errorf("layer %d of the circuit doesn't match its digest", i)
+		}
+	}
+	hash := lc.ContentHash()
+	if want, err := hex.DecodeString(d.CircuitHash); err != nil || !bytes.Equal(hash[:], want) {
+		return fmt.Errorf("circuit hash %x doesn't match %s", hash, d.CircuitHash)
+	}
+	return nil
+}
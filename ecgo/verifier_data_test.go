@@ -0,0 +1,66 @@
+package ecgo
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/m31"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/irwg"
+	"github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/layered"
+)
+
+// verifierCircuit multiplies its 2 inputs, then adds a public input
+func verifierCircuit() *layered.RootCircuit {
+	return &layered.RootCircuit{
+		NumPublicInputs:  1,
+		NumActualOutputs: 1,
+		Circuits: []*layered.Circuit{
+			{InputLen: 2, OutputLen: 1, Mul: []layered.GateMul{{In0: 0, In1: 1, Out: 0, Coef: big.NewInt(1), CoefType: 1}}},
+			{InputLen: 1, OutputLen: 1, Add: []layered.GateAdd{{In: 0, Out: 0, Coef: big.NewInt(1), CoefType: 1}}, Cst: []layered.GateCst{{Out: 0, Coef: big.NewInt(0), CoefType: 3}}},
+		},
+		Layers: []uint64{0, 1},
+		Field:  m31.ScalarField,
+	}
+}
+
+func TestVerifierData(t *testing.T) {
+	res := &CompileResult{publicLayout: []string{"Y"}}
+	if err := res.setLayeredCircuit(verifierCircuit()); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := ExtractVerifierData(res).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ReadVerifierData(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.NumInputs != 2 || d.NumPublicInputs != 1 || len(d.Layers) != 2 || d.Layers[1].InputLen != 1 || d.PublicInputLayout[0] != "Y" {
+		t.Fatalf("unexpected verifier data %+v", d)
+	}
+	if err := d.CheckCircuit(verifierCircuit()); err != nil {
+		t.Fatal(err)
+	}
+	altered := verifierCircuit()
+	altered.Circuits[1].Add[0].Coef = big.NewInt(2)
+	if err := d.CheckCircuit(altered); err == nil || !strings.Contains(err.Error(), "layer 1") {
+		t.Fatalf("expected the altered layer to be found, got %v", err)
+	}
+
+	hash := res.ContentHash()
+	pw := &irwg.PublicWitness{NumWitnesses: 1, NumPublicInputsPerWitness: 1, Field: m31.ScalarField, Values: []*big.Int{big.NewInt(5)}, CircuitHash: hash[:]}
+	if err := d.CheckPublicWitness(pw); err != nil {
+		t.Fatal(err)
+	}
+	pw.CircuitHash = make([]byte, 32)
+	if err := d.CheckPublicWitness(pw); !errors.Is(err, irwg.ErrCircuitMismatch) {
+		t.Fatalf("expected a circuit mismatch, got %v", err)
+	}
+	if _, err := ReadVerifierData(strings.NewReader(`{"version": 2}`)); err == nil {
+		t.Fatal("expected an unsupported version")
+	}
+}
@@ -1,10 +1,14 @@
 use expander_compiler::circuit::layered::NormalInputType;
 use libc::{c_ulong, malloc};
+use std::fs::File;
+use std::io::{BufWriter, Write};
 use std::ptr;
 use std::slice;
 
 use expander_compiler::{
+    builder::final_build_opt::CompressThresholds,
     circuit::{config, ir},
+    compile::CompileOptions as CompilerOptions,
     utils::serde::Serde,
 };
 
@@ -17,15 +21,43 @@ pub struct CompileResult {
     error: ByteArray,
 }
 
-fn compile_inner_with_config<C>(ir_source: Vec<u8>) -> Result<(Vec<u8>, Vec<u8>), String>
+// options of the compilation, zero meaning the default of each
+#[repr(C)]
+#[derive(Clone, Copy, Default)]
+pub struct CompileOptions {
+    max_terms: c_ulong,
+    max_quadratic_terms: c_ulong,
+}
+
+impl CompileOptions {
+    fn to_compiler_options<C: config::Config>(self) -> CompilerOptions {
+        let mut options = CompilerOptions::default();
+        if self.max_terms > 0 {
+            let mut thresholds = CompressThresholds::default_for::<C>();
+            thresholds.max_terms = self.max_terms as usize;
+            options = options.with_compress_thresholds(thresholds);
+        }
+        if self.max_quadratic_terms > 0 {
+            options = options.with_max_quadratic_terms(self.max_quadratic_terms as usize);
+        }
+        options
+    }
+}
+
+fn compile_inner_with_config<C>(
+    ir_source: Vec<u8>,
+    options: CompileOptions,
+) -> Result<(Vec<u8>, Vec<u8>), String>
 where
     C: config::Config,
 {
     let ir_source = ir::source::RootCircuit::<C>::deserialize_from(&ir_source[..])
         .map_err(|e| format!("failed to deserialize the source circuit: {}", e))?;
-    let (ir_witness_gen, layered) =
-        expander_compiler::compile::compile::<_, NormalInputType>(&ir_source)
-            .map_err(|e| e.to_string())?;
+    let (ir_witness_gen, layered) = expander_compiler::compile::compile_with_options::<
+        _,
+        NormalInputType,
+    >(&ir_source, options.to_compiler_options::<C>())
+    .map_err(|e| e.to_string())?;
     let mut ir_wg_s: Vec<u8> = Vec::new();
     ir_witness_gen
         .serialize_into(&mut ir_wg_s)
@@ -37,8 +69,57 @@ where
     Ok((ir_wg_s, layered_s))
 }
 
-fn compile_inner(ir_source: Vec<u8>, config_id: u64) -> Result<(Vec<u8>, Vec<u8>), String> {
-    match_config_id!(config_id, compile_inner_with_config, (ir_source))
+fn compile_inner(
+    ir_source: Vec<u8>,
+    config_id: u64,
+    options: CompileOptions,
+) -> Result<(Vec<u8>, Vec<u8>), String> {
+    match_config_id!(config_id, compile_inner_with_config, (ir_source, options))
+}
+
+// like compile_inner_with_config, but streams the layered circuit to the file at layered_path
+// instead of returning it, so that it's never held serialized in memory
+fn compile_to_file_inner_with_config<C>(
+    ir_source: Vec<u8>,
+    options: CompileOptions,
+    layered_path: &str,
+) -> Result<Vec<u8>, String>
+where
+    C: config::Config,
+{
+    let ir_source = ir::source::RootCircuit::<C>::deserialize_from(&ir_source[..])
+        .map_err(|e| format!("failed to deserialize the source circuit: {}", e))?;
+    let (ir_witness_gen, layered) = expander_compiler::compile::compile_with_options::<
+        _,
+        NormalInputType,
+    >(&ir_source, options.to_compiler_options::<C>())
+    .map_err(|e| e.to_string())?;
+    let mut ir_wg_s: Vec<u8> = Vec::new();
+    ir_witness_gen
+        .serialize_into(&mut ir_wg_s)
+        .map_err(|e| format!("failed to serialize the witness generator: {}", e))?;
+    let file = File::create(layered_path)
+        .map_err(|e| format!("failed to create the layered circuit file: {}", e))?;
+    let mut writer = BufWriter::new(file);
+    layered
+        .serialize_into(&mut writer)
+        .and_then(|_| writer.flush())
+        .map_err(|e| format!("failed to write the layered circuit file: {}", e))?;
+    Ok(ir_wg_s)
+}
+
+fn compile_to_file_inner(
+    ir_source: Vec<u8>,
+    config_id: u64,
+    options: CompileOptions,
+    layered_path: &str,
+) -> Result<(Vec<u8>, Vec<u8>), String> {
+    match_config_id!(
+        config_id,
+        compile_to_file_inner_with_config,
+        (ir_source, options, layered_path)
+    )
+    .map(|ir_witness_gen| (ir_witness_gen, Vec::new()))
 }
 
 fn to_compile_result(result: Result<(Vec<u8>, Vec<u8>), String>) -> CompileResult {
@@ -111,6 +192,34 @@ fn to_compile_result(result: Result<(Vec<u8>, Vec<u8>), String>) -> CompileResul
 #[no_mangle]
 pub extern "C" fn compile(ir_source: ByteArray, config_id: c_ulong) -> CompileResult {
     let ir_source = unsafe { slice::from_raw_parts(ir_source.data, ir_source.length as usize) };
-    let result = compile_inner(ir_source.to_vec(), config_id);
+    let result = compile_inner(ir_source.to_vec(), config_id, CompileOptions::default());
+    to_compile_result(result)
+}
+
+#[no_mangle]
+pub extern "C" fn compile_with_options(
+    ir_source: ByteArray,
+    config_id: c_ulong,
+    options: CompileOptions,
+) -> CompileResult {
+    let ir_source = unsafe { slice::from_raw_parts(ir_source.data, ir_source.length as usize) };
+    let result = compile_inner(ir_source.to_vec(), config_id, options);
+    to_compile_result(result)
+}
+
+#[no_mangle]
+pub extern "C" fn compile_to_file(
+    ir_source: ByteArray,
+    config_id: c_ulong,
+    options: CompileOptions,
+    layered_path: ByteArray,
+) -> CompileResult {
+    let ir_source = unsafe { slice::from_raw_parts(ir_source.data, ir_source.length as usize) };
+    let layered_path =
+        unsafe { slice::from_raw_parts(layered_path.data, layered_path.length as usize) };
+    let result = match std::str::from_utf8(layered_path) {
+        Ok(path) => compile_to_file_inner(ir_source.to_vec(), config_id, options, path),
+        Err(e) => Err(format!("invalid layered circuit path: {}", e)),
+    };
     to_compile_result(result)
 }
@@ -1,4 +1,4 @@
-use expander_compiler::circuit::layered;
+use expander_compiler::circuit::layered::{self, NormalInputType};
 use libc::{c_uchar, c_ulong, malloc};
 use std::ptr;
 use std::slice;
@@ -7,14 +7,92 @@ use expander_compiler::{circuit::config, utils::serde::Serde};
 
 use super::*;
 
+#[repr(C)]
+pub struct ProveResult {
+    proof: ByteArray,
+    error: ByteArray,
+}
+
+#[repr(C)]
+pub struct VerifyResult {
+    valid: c_uchar,
+    error: ByteArray,
+}
+
+fn to_byte_array(data: &[u8]) -> ByteArray {
+    let len = data.len();
+    let ptr = if len > 0 {
+        unsafe {
+            let ptr = malloc(len) as *mut u8;
+            ptr.copy_from(data.as_ptr(), len);
+            ptr
+        }
+    } else {
+        ptr::null_mut()
+    };
+    ByteArray {
+        data: ptr,
+        length: len as c_ulong,
+    }
+}
+
+fn gkr_config<C: config::Config>() -> expander_config::Config<C::DefaultGKRConfig> {
+    expander_config::Config::<C::DefaultGKRConfig>::new(
+        expander_config::GKRScheme::Vanilla,
+        mpi_config::MPIConfig::new(),
+    )
+}
+
+// loads the serialized layered circuit into Expander, with the inputs of the witness
+fn load_circuit_with_witness<C: config::Config>(
+    circuit: &[u8],
+    witness: &[u8],
+) -> Result<expander_circuit::Circuit<C::DefaultGKRFieldConfig>, String> {
+    let layered_circuit = layered::Circuit::<C, NormalInputType>::deserialize_from(circuit)
+        .map_err(|e| format!("failed to deserialize the layered circuit: {}", e))?;
+    let mut circuit = layered_circuit
+        .export_to_expander::<C::DefaultGKRFieldConfig>()
+        .flatten();
+    circuit.identify_rnd_coefs();
+    circuit.identify_structure_info();
+    let witness = layered::witness::Witness::<C>::deserialize_from(witness)
+        .map_err(|e| format!("failed to deserialize the witness: {}", e))?;
+    let (simd_input, simd_public_input) = witness.to_simd::<C::DefaultSimdField>();
+    circuit.layers[0].input_vals = simd_input;
+    circuit.public_input = simd_public_input;
+    Ok(circuit)
+}
+
+fn prove_circuit_inner<C: config::Config>(
+    circuit: &[u8],
+    witness: &[u8],
+) -> Result<Vec<u8>, String> {
+    let mut circuit = load_circuit_with_witness::<C>(circuit, witness)?;
+    circuit.evaluate();
+    let (claimed_v, proof) = gkr::executor::prove(&mut circuit, &gkr_config::<C>());
+    gkr::executor::dump_proof_and_claimed_v(&proof, &claimed_v).map_err(|e| e.to_string())
+}
+
+fn verify_circuit_inner<C: config::Config>(
+    circuit: &[u8],
+    witness: &[u8],
+    proof_and_claimed_v: &[u8],
+) -> Result<u8, String> {
+    let mut circuit = load_circuit_with_witness::<C>(circuit, witness)?;
+    let (proof, claimed_v) = match gkr::executor::load_proof_and_claimed_v(proof_and_claimed_v) {
+        Ok((proof, claimed_v)) => (proof, claimed_v),
+        Err(_) => {
+            return Ok(0);
+        }
+    };
+    Ok(gkr::executor::verify(&mut circuit, &gkr_config::<C>(), &proof, &claimed_v) as u8)
+}
+
 fn prove_circuit_file_inner<C: config::Config>(
     circuit_filename: &str,
     witness: &[u8],
 ) -> Result<Vec<u8>, String> {
-    let config = expander_config::Config::<C::DefaultGKRConfig>::new(
-        expander_config::GKRScheme::Vanilla,
-        mpi_config::MPIConfig::new(),
-    );
+    let config = gkr_config::<C>();
     let mut circuit = expander_circuit::Circuit::<C::DefaultGKRFieldConfig>::load_circuit::<
         C::DefaultGKRConfig,
     >(circuit_filename);
@@ -33,10 +111,7 @@ fn verify_circuit_file_inner<C: config::Config>(
     witness: &[u8],
     proof_and_claimed_v: &[u8],
 ) -> Result<u8, String> {
-    let config = expander_config::Config::<C::DefaultGKRConfig>::new(
-        expander_config::GKRScheme::Vanilla,
-        mpi_config::MPIConfig::new(),
-    );
+    let config = gkr_config::<C>();
     let mut circuit = expander_circuit::Circuit::<C::DefaultGKRFieldConfig>::load_circuit::<
         C::DefaultGKRConfig,
     >(circuit_filename);
@@ -107,3 +182,45 @@ pub extern "C" fn verify_circuit_file(
     )
     .unwrap() // TODO: handle error
 }
+
+#[no_mangle]
+pub extern "C" fn prove_circuit(
+    circuit: ByteArray,
+    witness: ByteArray,
+    config_id: c_ulong,
+) -> ProveResult {
+    let circuit = unsafe { slice::from_raw_parts(circuit.data, circuit.length as usize) };
+    let witness = unsafe { slice::from_raw_parts(witness.data, witness.length as usize) };
+    match match_config_id!(config_id, prove_circuit_inner, (circuit, witness)) {
+        Ok(proof) => ProveResult {
+            proof: to_byte_array(&proof),
+            error: to_byte_array(&[]),
+        },
+        Err(error) => ProveResult {
+            proof: to_byte_array(&[]),
+            error: to_byte_array(error.as_bytes()),
+        },
+    }
+}
+
+#[no_mangle]
+pub extern "C" fn verify_circuit(
+    circuit: ByteArray,
+    witness: ByteArray,
+    proof: ByteArray,
+    config_id: c_ulong,
+) -> VerifyResult {
+    let circuit = unsafe { slice::from_raw_parts(circuit.data, circuit.length as usize) };
+    let witness = unsafe { slice::from_raw_parts(witness.data, witness.length as usize) };
+    let proof = unsafe { slice::from_raw_parts(proof.data, proof.length as usize) };
+    match match_config_id!(config_id, verify_circuit_inner, (circuit, witness, proof)) {
+        Ok(valid) => VerifyResult {
+            valid,
+            error: to_byte_array(&[]),
+        },
+        Err(error) => VerifyResult {
+            valid: 0,
+            error: to_byte_array(error.as_bytes()),
+        },
+    }
+}
@@ -23,11 +23,42 @@ use crate::{
 
 use super::basic::LinMeta;
 
-const COMPRESS_THRESHOLD: usize = 64;
+const DEFAULT_MAX_TERMS: usize = 64;
+
+/// Limits on the size of the expressions kept symbolic by the builder. An expression exceeding
+/// them is compressed into a new variable, even if the cost model of its references would keep
+/// it symbolic, so that expressions built from each other don't grow without bound. The degree 2
+/// terms of an expression exceeding max_quadratic_terms are first split into balanced chunks,
+/// each compressed into a new variable, so that no gate sums more products than the limit.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct CompressThresholds {
+    // maximum number of terms
+    pub max_terms: usize,
+    // maximum number of degree 2 terms
+    pub max_quadratic_terms: usize,
+}
+
+impl CompressThresholds {
+    // The default thresholds give degree 2 terms the budget of DEFAULT_MAX_TERMS linear terms,
+    // weighted by their costs: each copy of a degree 2 term costs a mul gate instead of an add
+    // gate, so they are compressed much earlier.
+    pub fn default_for<C: Config>() -> Self {
+        CompressThresholds {
+            max_terms: DEFAULT_MAX_TERMS,
+            max_quadratic_terms: (DEFAULT_MAX_TERMS * C::COST_ADD / C::COST_MUL).max(1),
+        }
+    }
+
+    fn exceeded_by(&self, degree_count: &[usize; 3]) -> bool {
+        degree_count.iter().sum::<usize>() > self.max_terms
+            || degree_count[2] > self.max_quadratic_terms
+    }
+}
 
 struct RootBuilder<C: Config> {
     builders: HashMap<usize, Builder<C>>,
     out_circuits: HashMap<usize, OutCircuit<C>>,
+    thresholds: CompressThresholds,
 }
 
 struct Builder<C: Config> {
@@ -50,6 +81,8 @@ struct Builder<C: Config> {
     out_insns: Vec<(usize, OutInstruction<C>)>,
 
     output_layer: usize,
+
+    thresholds: CompressThresholds,
 }
 
 #[derive(Hash, PartialEq, Eq, Clone)]
@@ -83,7 +116,7 @@ impl<C: Config> Default for MidVarCoef<C> {
 }
 
 impl<C: Config> Builder<C> {
-    fn new() -> Self {
+    fn new(thresholds: CompressThresholds) -> Self {
         let mut res = Builder {
             in_var_ref_counts: vec![InVarRefCounts::default()],
             in_var_exprs: vec![Expression::default()],
@@ -92,6 +125,7 @@ impl<C: Config> Builder<C> {
             mid_var_layer: vec![0],
             out_insns: Vec::new(),
             output_layer: 0,
+            thresholds,
         };
         res.stripped_mid_vars.add(&MidVarKey {
             expr: Expression::invalid(),
@@ -128,6 +162,7 @@ impl<C: Config> Builder<C> {
     }
 
     fn make_single(&mut self, expr: Expression<C>) -> Expression<C> {
+        let expr = self.split_quadratic(expr);
         let (e, coef, constant) = strip_constants(&expr);
         if e.len() == 1 && e.degree() <= 1 {
             return expr;
@@ -148,6 +183,30 @@ impl<C: Config> Builder<C> {
         unstrip_constants_single(idx, coef, constant, &self.mid_var_coefs[idx])
     }
 
+    // Splits the degree 2 terms of expr into new variables if there are more than
+    // max_quadratic_terms of them, so that no gate sums too many products. The terms are split
+    // into as few chunks as possible, of sizes differing by at most one.
+    fn split_quadratic(&mut self, expr: Expression<C>) -> Expression<C> {
+        let max = self.thresholds.max_quadratic_terms.max(1);
+        if expr.count_of_degrees()[2] <= max {
+            return expr;
+        }
+        let (quad, mut res): (Vec<Term<C>>, Vec<Term<C>>) = expr
+            .iter()
+            .cloned()
+            .partition(|term| matches!(term.vars, VarSpec::Quad(..) | VarSpec::Custom { .. }));
+        let n = quad.len();
+        let chunks = n.div_ceil(max);
+        let mut start = 0;
+        for i in 0..chunks {
+            let end = start + (n - start) / (chunks - i);
+            let chunk = self.make_single(Expression::from_terms(quad[start..end].to_vec()));
+            res.extend(chunk.to_terms());
+            start = end;
+        }
+        Expression::from_terms(res)
+    }
+
     fn try_make_single(&self, expr: Expression<C>) -> Expression<C> {
         let (e, coef, constant) = strip_constants(&expr);
         if e.len() == 1 && e.degree() <= 1 {
@@ -470,9 +529,10 @@ impl<C: Config> Builder<C> {
 
     fn add_and_check_if_should_make_single(&mut self, e: Expression<C>) {
         let ref_count = self.in_var_ref_counts[self.in_var_exprs.len()].clone();
+        let e = self.split_quadratic(e);
         let degree_count = e.count_of_degrees();
         let mut should_compress = ref_count.single > 0;
-        should_compress |= degree_count.iter().sum::<usize>() > COMPRESS_THRESHOLD;
+        should_compress |= self.thresholds.exceeded_by(&degree_count);
         let cost_no_compress =
             cost_of_possible_references::<C>(&degree_count, ref_count.add, ref_count.mul);
         let cost_compress = cost_of_compress::<C>(&degree_count)
@@ -536,7 +596,7 @@ fn process_circuit<C: Config>(
     root: &mut RootBuilder<C>,
     circuit: &InCircuit<C>,
 ) -> Result<(OutCircuit<C>, Builder<C>), Error> {
-    let mut builder = Builder::new();
+    let mut builder = Builder::new(root.thresholds);
 
     // initialize in_var_ref_counts
     for _ in 0..circuit.get_num_inputs_all() {
@@ -718,9 +778,17 @@ fn process_circuit<C: Config>(
 }
 
 pub fn process<C: Config>(rc: &InRootCircuit<C>) -> Result<OutRootCircuit<C>, Error> {
+    process_with_thresholds(rc, CompressThresholds::default_for::<C>())
+}
+
+pub fn process_with_thresholds<C: Config>(
+    rc: &InRootCircuit<C>,
+    thresholds: CompressThresholds,
+) -> Result<OutRootCircuit<C>, Error> {
     let mut root: RootBuilder<C> = RootBuilder {
         builders: HashMap::new(),
         out_circuits: HashMap::new(),
+        thresholds,
     };
     let order = rc.topo_order();
     for &circuit_id in order.iter().rev() {
@@ -965,4 +1033,102 @@ mod tests {
         assert_eq!(out, out2);
         assert_eq!(ok, ok2);
     }
+
+    #[test]
+    fn compress_thresholds() {
+        // a sum of 8 products, referenced by two linear combinations
+        let n = 8;
+        let mut instructions: Vec<super::InInstruction<C>> = (1..=n)
+            .map(|i| super::InInstruction::<C>::Mul(vec![2 * i - 1, 2 * i]))
+            .collect();
+        instructions.push(super::InInstruction::<C>::LinComb(ir::expr::LinComb {
+            terms: (1..=n)
+                .map(|i| ir::expr::LinCombTerm {
+                    coef: CField::one(),
+                    var: 2 * n + i,
+                })
+                .collect(),
+            constant: CField::zero(),
+        }));
+        let sum = 3 * n + 1;
+        for x in [1, 2] {
+            instructions.push(super::InInstruction::<C>::LinComb(ir::expr::LinComb {
+                terms: vec![
+                    ir::expr::LinCombTerm {
+                        coef: CField::one(),
+                        var: sum,
+                    },
+                    ir::expr::LinCombTerm {
+                        coef: CField::one(),
+                        var: x,
+                    },
+                ],
+                constant: CField::zero(),
+            }));
+        }
+        let mut root = super::InRootCircuit::<C>::default();
+        root.circuits.insert(
+            0,
+            super::InCircuit::<C> {
+                instructions,
+                constraints: vec![sum + 1, sum + 2],
+                outputs: vec![],
+                num_inputs: 2 * n,
+            },
+        );
+        assert_eq!(root.validate(), Ok(()));
+        let quadratic_terms = |r: &super::OutRootCircuit<C>| {
+            r.circuits[&0]
+                .instructions
+                .iter()
+                .map(|insn| match insn {
+                    ir::dest::Instruction::InternalVariable { expr } => expr.count_of_degrees()[2],
+                    _ => 0,
+                })
+                .sum::<usize>()
+        };
+        let inputs: Vec<CField> = (1..=2 * n).map(|i| CField::from(i as u32)).collect();
+        let (out, ok) = root.eval_unsafe(inputs.clone());
+
+        // the references are cheaper than a new variable, so the sum is kept symbolic and copied
+        // into both linear combinations
+        let root_default = super::process(&root).unwrap();
+        assert_eq!(root_default.validate(), Ok(()));
+        assert_eq!(quadratic_terms(&root_default), 2 * n);
+
+        let thresholds = super::CompressThresholds {
+            max_terms: 64,
+            max_quadratic_terms: 4,
+        };
+        let root_processed = super::process_with_thresholds(&root, thresholds).unwrap();
+        assert_eq!(root_processed.validate(), Ok(()));
+        assert_eq!(quadratic_terms(&root_processed), n);
+        let (out2, ok2) = root_processed.eval_unsafe(inputs.clone());
+        assert_eq!(out, out2);
+        assert_eq!(ok, ok2);
+
+        // 8 products over a limit of 3 are split into chunks of 3, 3 and 2
+        let thresholds = super::CompressThresholds {
+            max_terms: 64,
+            max_quadratic_terms: 3,
+        };
+        let root_processed = super::process_with_thresholds(&root, thresholds).unwrap();
+        assert_eq!(root_processed.validate(), Ok(()));
+        let mut chunks: Vec<usize> = root_processed.circuits[&0]
+            .instructions
+            .iter()
+            .filter_map(|insn| match insn {
+                ir::dest::Instruction::InternalVariable { expr } => {
+                    Some(expr.count_of_degrees()[2])
+                }
+                _ => None,
+            })
+            .filter(|&c| c > 0)
+            .collect();
+        chunks.sort();
+        assert_eq!(chunks, vec![2, 3, 3]);
+        let (out2, ok2) = root_processed.eval_unsafe(inputs);
+        assert_eq!(out, out2);
+        assert_eq!(ok, ok2);
+    }
 }
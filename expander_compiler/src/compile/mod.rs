@@ -1,5 +1,5 @@
 use crate::{
-    builder,
+    builder::{self, final_build_opt::CompressThresholds},
     circuit::{
         config::Config,
         input_mapping::InputMapping,
@@ -18,6 +18,10 @@ mod tests;
 #[derive(Default)]
 pub struct CompileOptions {
     pub mul_fanout_limit: Option<usize>,
+    // thresholds of the final build, see CompressThresholds::default_for for the defaults
+    pub compress_thresholds: Option<CompressThresholds>,
+    // overrides the max_quadratic_terms of the thresholds
+    pub max_quadratic_terms: Option<usize>,
 }
 
 impl CompileOptions {
@@ -25,6 +29,16 @@ impl CompileOptions {
         self.mul_fanout_limit = Some(mul_fanout_limit);
         self
     }
+
+    pub fn with_compress_thresholds(mut self, compress_thresholds: CompressThresholds) -> Self {
+        self.compress_thresholds = Some(compress_thresholds);
+        self
+    }
+
+    pub fn with_max_quadratic_terms(mut self, max_quadratic_terms: usize) -> Self {
+        self.max_quadratic_terms = Some(max_quadratic_terms);
+        self
+    }
 }
 
 fn optimize_until_fixed_point<T, F>(x: &T, im: &mut InputMapping, f: F) -> T
@@ -76,6 +90,18 @@ pub fn compile_with_options<C: Config, I: InputType>(
 ) -> Result<(ir::hint_normalized::RootCircuit<C>, layered::Circuit<C, I>), Error> {
     r_source.validate()?;
 
+    let mut compress_thresholds = options
+        .compress_thresholds
+        .unwrap_or_else(CompressThresholds::default_for::<C>);
+    if let Some(max_quadratic_terms) = options.max_quadratic_terms {
+        if max_quadratic_terms == 0 {
+            return Err(Error::UserError(
+                "max_quadratic_terms must be positive".to_string(),
+            ));
+        }
+        compress_thresholds.max_quadratic_terms = max_quadratic_terms;
+    }
+
     let mut src_im = InputMapping::new_identity(r_source.input_size());
 
     let mut r_source = r_source.clone();
@@ -126,8 +152,9 @@ pub fn compile_with_options<C: Config, I: InputType>(
         .validate()
         .map_err(|e| e.prepend("hint less ir circuit invalid"))?;
 
-    let r_dest_relaxed = builder::final_build_opt::process(&r_hint_less_opt)
-        .map_err(|e| e.prepend("final build failed"))?;
+    let r_dest_relaxed =
+        builder::final_build_opt::process_with_thresholds(&r_hint_less_opt, compress_thresholds)
+            .map_err(|e| e.prepend("final build failed"))?;
 
     let r_dest_relaxed_opt = optimize_until_fixed_point(&r_dest_relaxed, &mut hl_im, |r| {
         let (mut r, im) = r.remove_unreachable();
@@ -541,6 +541,40 @@ mod tests {
         assert_eq!(cond, cond2);
     }
 
+    #[test]
+    fn combined_constraints() {
+        // the assertions of the root circuit are checked by a single random linear combination
+        let n = 100;
+        let mut root = IrRootCircuit::<M31Config>::default();
+        root.circuits.insert(
+            0,
+            IrCircuit {
+                instructions: (0..n)
+                    .map(|i| InternalVariable {
+                        expr: Expression::from_terms(vec![
+                            Term::new_linear(M31::from(i as u32 + 1), 1),
+                            Term::new_linear(M31::from(1), 2),
+                        ]),
+                    })
+                    .collect(),
+                constraints: (3..n + 3).collect(),
+                outputs: vec![1],
+                num_inputs: 2,
+            },
+        );
+        assert_eq!(root.validate(), Ok(()));
+        let new_root = split_to_single_layer(&root);
+        assert_eq!(new_root.validate(), Ok(()));
+        assert_eq!(new_root.expected_num_output_zeroes, 1);
+        assert_eq!(new_root.circuits[&0].outputs.len(), 2);
+        for inputs in [vec![M31::from(0), M31::from(0)], vec![M31::from(1), M31::from(0)]] {
+            let (out, cond) = root.eval_unsafe(inputs.clone());
+            let (out2, cond2) = new_root.eval_unsafe(inputs);
+            assert_eq!(out, out2);
+            assert_eq!(cond, cond2);
+        }
+    }
+
     fn rand_test<C: Config>() {
         let mut config = RandomCircuitConfig {
             seed: 0,
@@ -0,0 +1,7 @@
+package babybear
+
+import "github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/babybear"
+
+// ScalarField is the BabyBear field. Circuits over it can be defined and checked with
+// CheckWitness, but Compile rejects them, since the Rust compiler has no config for it.
+var ScalarField = babybear.ScalarField
@@ -0,0 +1,7 @@
+package bls12381
+
+import "github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/bls12381"
+
+// ScalarField is the scalar field of BLS12-381. Circuits over it can be defined and checked with
+// CheckWitness, but Compile rejects them, since the Rust compiler has no config for it.
+var ScalarField = bls12381.ScalarField
@@ -0,0 +1,7 @@
+package goldilocks
+
+import "github.com/PolyhedraZK/ExpanderCompilerCollection/ecgo/field/goldilocks"
+
+// ScalarField is the Goldilocks field. Circuits over it can be defined and checked with
+// CheckWitness, but Compile rejects them, since the Rust compiler has no config for it.
+var ScalarField = goldilocks.ScalarField